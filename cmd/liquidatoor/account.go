@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+)
+
+// account prints as JSON the positions, health and price sensitivities
+// of an account in every configured Compound pool.
+func account(ctx context.Context, cfg *liquidatoor.Config, args []string) error {
+	if len(args) != 1 || !common.IsHexAddress(args[0]) {
+		return errors.New("expected the address of the account")
+	}
+	conn, err := liquidatoor.Connect(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("cannot connect: %w", err)
+	}
+	defer conn.Close()
+	report, err := conn.Account(ctx, common.HexToAddress(args[0]))
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+)
+
+// cooldowns lists the accounts the running instance cools down, or
+// clears the cooldowns of one, on the instance answering at
+// READINESS_ADDRESS, as cooldowns are not persisted.
+func cooldowns(ctx context.Context, cfg *liquidatoor.Config, args []string) error {
+	url, err := instanceURL(cfg, "/cooldowns")
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 0:
+		var list []liquidatoor.Cooldown
+		if err := request(ctx, http.MethodGet, url, &list); err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("No accounts cooling down")
+		}
+		for _, cooldown := range list {
+			fmt.Printf("%s\t%s\tuntil %s\t%d %s failures\t%s\n", cooldown.Account, cooldown.Pool, cooldown.Until.Format("2006-01-02 15:04:05"), cooldown.Failures, cooldown.Class, cooldown.Err)
+		}
+		return nil
+
+	case args[0] == "clear":
+		if len(args) != 2 || !common.IsHexAddress(args[1]) {
+			return errors.New("expected the address of the account")
+		}
+		account := common.HexToAddress(args[1])
+		var cleared liquidatoor.ClearedCooldowns
+		if err := request(ctx, http.MethodDelete, url+"/"+account.Hex(), &cleared); err != nil {
+			return err
+		}
+		fmt.Printf("Cleared %d cooldowns of %s\n", cleared.Cleared, account)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown cooldowns command %q", args[0])
+	}
+}
+
+// instanceURL returns the URL of path on the running instance, which
+// answers at READINESS_ADDRESS.
+func instanceURL(cfg *liquidatoor.Config, path string) (string, error) {
+	if cfg.ReadinessAddress == "" {
+		return "", errors.New("READINESS_ADDRESS needs to be set to reach the running instance")
+	}
+	address := cfg.ReadinessAddress
+	if strings.HasPrefix(address, ":") {
+		address = "localhost" + address
+	}
+	return "http://" + address + path, nil
+}
+
+// request decodes the JSON answer of the instance to a request.
+func request(ctx context.Context, method, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach the running instance: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
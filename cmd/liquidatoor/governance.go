@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+)
+
+// governance lists the pools the running instance holds liquidations
+// in since a governance change, or acknowledges the changes of one, on
+// the instance answering at READINESS_ADDRESS.
+func governance(ctx context.Context, cfg *liquidatoor.Config, args []string) error {
+	url, err := instanceURL(cfg, "/governance")
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 0:
+		var holds []liquidatoor.GovernanceHold
+		if err := request(ctx, http.MethodGet, url, &holds); err != nil {
+			return err
+		}
+		if len(holds) == 0 {
+			fmt.Println("No pools holding liquidations")
+		}
+		for _, held := range holds {
+			for _, change := range held.Changes {
+				fmt.Printf("%s\t%s\n", held.Pool, change)
+			}
+		}
+		return nil
+
+	case args[0] == "ack":
+		if len(args) != 2 || !common.IsHexAddress(args[1]) {
+			return errors.New("expected the address of the pool")
+		}
+		pool := common.HexToAddress(args[1])
+		var acknowledged liquidatoor.AcknowledgedChanges
+		if err := request(ctx, http.MethodPost, url+"/"+pool.Hex(), &acknowledged); err != nil {
+			return err
+		}
+		fmt.Printf("Acknowledged %d changes of %s\n", acknowledged.Acknowledged, pool)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown governance command %q", args[0])
+	}
+}
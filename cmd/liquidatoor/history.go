@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+)
+
+// history indexes the past liquidations of the configured pools into
+// the history store at HISTORY_PATH, or summarizes them, or their
+// liquidators.
+func history(ctx context.Context, cfg *liquidatoor.Config, args []string) error {
+	if cfg.HistoryPath == "" {
+		return errors.New("HISTORY_PATH cannot be empty")
+	}
+	if len(args) == 0 {
+		return errors.New("expected index, summary or competitors")
+	}
+	logger := liquidatoor.NewStdLogger()
+	h, err := liquidatoor.OpenHistory(logger, cfg.HistoryPath)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	presenter, err := liquidatoor.NewPresenter(logger, cfg)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "index":
+		conn, err := liquidatoor.Connect(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("cannot connect: %w", err)
+		}
+		defer conn.Close()
+		return conn.IndexHistory(ctx, h)
+
+	case "summary":
+		flags := flag.NewFlagSet("history summary", flag.ContinueOnError)
+		by := flags.String("by", string(liquidatoor.ByLiquidator), "Group liquidations by liquidator, market or week")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		summaries, err := h.Summary(ctx, liquidatoor.HistoryGrouping(*by))
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\tLIQUIDATIONS\tBORROWERS\tREPAID\tGAS PAID\n", strings.ToUpper(*by))
+		for _, s := range summaries {
+			repaid := "-"
+			if s.RepayAmount != nil {
+				repaid = s.RepayAmount.String()
+			}
+			key := s.Key
+			if common.IsHexAddress(key) {
+				key = presenter.Address(common.HexToAddress(key))
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%v\n", key, s.Liquidations, s.Borrowers, repaid, s.GasPaid)
+		}
+		return w.Flush()
+
+	case "competitors":
+		stats, err := h.Competitors(ctx, cfg.JournalPath)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "LIQUIDATOR\tLIQUIDATIONS\tSHARE\tMEDIAN TIP\tDETECTED\tMEDIAN BLOCKS TO STRIKE\n")
+		for _, s := range stats {
+			tip, strike := "-", "-"
+			if s.MedianGasTip != nil {
+				tip = s.MedianGasTip.String()
+			}
+			if s.MedianBlocksToStrike != nil {
+				strike = fmt.Sprint(*s.MedianBlocksToStrike)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%s\n", presenter.Address(s.Liquidator), s.Liquidations, s.Share.Percent(), tip, s.Detected, strike)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown history command %q", args[0])
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+)
+
+// liquidate liquidates an underwater account once, in the pool of
+// -pool or the only configured one, and waits for the transaction to
+// be mined.
+func liquidate(ctx context.Context, cfg *liquidatoor.Config, args []string) error {
+	flags := flag.NewFlagSet("liquidate", flag.ContinueOnError)
+	pool := flags.String("pool", "", "Comptroller of the pool of the account, if several are configured")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || !common.IsHexAddress(flags.Arg(0)) {
+		return errors.New("expected the address of the account")
+	}
+	var comptroller common.Address
+	switch {
+	case *pool != "":
+		if !common.IsHexAddress(*pool) {
+			return fmt.Errorf("invalid -pool %q", *pool)
+		}
+		comptroller = common.HexToAddress(*pool)
+	case len(cfg.Comptrollers) == 1:
+		comptroller = cfg.Comptrollers[0]
+	default:
+		return fmt.Errorf("expected -pool, %d pools are configured", len(cfg.Comptrollers))
+	}
+
+	conn, err := liquidatoor.Connect(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("cannot connect: %w", err)
+	}
+	defer conn.Close()
+	receipt, err := conn.Liquidate(ctx, comptroller, common.HexToAddress(flags.Arg(0)))
+	if err != nil {
+		return err
+	}
+	log.Printf("Liquidated account %s in transaction %s, mined in block %v", flags.Arg(0), receipt.TxHash, receipt.BlockNumber)
+	return nil
+}
@@ -1,16 +1,160 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
 )
 
 func main() {
-	l, err := liquidatoor.New()
+	resetKillSwitch := flag.Bool("reset-kill-switch", false, "Disengage the kill switch persisted at LEDGER_PATH and exit")
+	demote := flag.Bool("demote", false, "Make the instance whose role is persisted at ROLE_PATH stand by, even while running, and exit")
+	chainsFile := flag.String("chains", "", "Run every chain of the chains file in one process, rather than the chain of the environment")
+	chain := flag.String("chain", "", "Chain of the chains file every other flag and command applies to")
+	accounts := flag.String("accounts", "", "Comma-separated accounts to monitor instead of the borrowers of the pools, as ACCOUNTS")
+	accountsFile := flag.String("accounts-file", "", "File of accounts to monitor instead of the borrowers of the pools, as ACCOUNTS_PATH")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [scan [-execute] [-timeout duration]|history index|history summary [-by liquidator|market|week]|history competitors|state snapshot <path>|state restore [-force] <path>|account <address>|liquidate [-pool comptroller] <address>|cooldowns [clear <address>]|governance [ack <pool>]|preflight]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfgs, err := configs(*chainsFile, *chain)
 	if err != nil {
-		log.Fatalf("Failed to instantiate liquidatoor: %v", err)
+		log.Fatalf("Failed to read config: %v", err)
+	}
+	for _, cfg := range cfgs {
+		if *accounts != "" {
+			if cfg.Accounts, err = liquidatoor.ParseAddresses(*accounts); err != nil {
+				log.Fatalf("Invalid -accounts: %v", err)
+			}
+		}
+		if *accountsFile != "" {
+			cfg.AccountsPath = *accountsFile
+		}
+	}
+	cfg := cfgs[0]
+	if len(cfgs) > 1 && (*resetKillSwitch || *demote || flag.NArg() > 0) {
+		log.Fatal("Every chain has its own state; select one with -chain")
+	}
+
+	if *resetKillSwitch {
+		ledger, err := liquidatoor.OpenLedger(liquidatoor.NewStdLogger(), cfg.LedgerPath, cfg.DailyLossLimit)
+		if err != nil {
+			log.Fatalf("Failed to open ledger: %v", err)
+		}
+		if err := ledger.Reset(); err != nil {
+			log.Fatalf("Failed to reset kill switch: %v", err)
+		}
+		return
+	}
+	if *demote {
+		role, err := liquidatoor.Demote(cfg)
+		if err != nil {
+			log.Fatalf("Failed to demote: %v", err)
+		}
+		if role == liquidatoor.RoleStandby {
+			log.Print("Already standing by")
+		} else {
+			log.Print("Demoted; the instance stands by within a heartbeat interval")
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if flag.Arg(0) == "scan" {
+		code, err := scan(ctx, cfg, flag.Args()[1:])
+		if err != nil {
+			log.Printf("Failed to scan: %v", err)
+		}
+		stop()
+		os.Exit(code)
+	}
+	if flag.Arg(0) == "history" {
+		if err := history(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to run history: %v", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "account" {
+		if err := account(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to read account: %v", err)
+		}
+		return
 	}
+	if flag.Arg(0) == "liquidate" {
+		if err := liquidate(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to liquidate: %v", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "cooldowns" {
+		if err := cooldowns(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to run cooldowns: %v", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "governance" {
+		if err := governance(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to run governance: %v", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "preflight" {
+		if err := liquidatoor.Preflight(ctx, cfg); err != nil {
+			log.Fatalf("Failed preflight: %v", err)
+		}
+		log.Print("Preflight passed")
+		return
+	}
+	if flag.Arg(0) == "state" {
+		if err := state(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to run state: %v", err)
+		}
+		return
+	}
+
+	if len(cfgs) > 1 {
+		if err := liquidatoor.RunChains(ctx, cfgs); err != nil {
+			log.Fatalf("Failed to run: %v", err)
+		}
+		return
+	}
+	if err := liquidatoor.Run(ctx, cfg); err != nil {
+		log.Fatalf("Failed to run: %v", err)
+	}
+}
 
-	l.SubscribeToBlocks()
+// configs returns the config of the environment, or of every chain of
+// chainsFile, or of chain only if set.
+func configs(chainsFile, chain string) ([]*liquidatoor.Config, error) {
+	if chainsFile == "" {
+		if chain != "" {
+			return nil, errors.New("-chain needs -chains")
+		}
+		cfg, err := liquidatoor.ConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return []*liquidatoor.Config{cfg}, nil
+	}
+	cfgs, err := liquidatoor.ConfigsFromFile(chainsFile)
+	if err != nil || chain == "" {
+		return cfgs, err
+	}
+	for _, cfg := range cfgs {
+		if cfg.Chain == chain {
+			return []*liquidatoor.Config{cfg}, nil
+		}
+	}
+	return nil, fmt.Errorf("no chain %s in %s", chain, chainsFile)
 }
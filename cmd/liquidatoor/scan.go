@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+)
+
+// Exit codes of scan, for cron jobs to act on; failures exit with 1 as
+// every other command
+const (
+	scanNoCandidates = 0
+	scanFailed       = 1
+	scanCandidates   = 2
+	scanExecuted     = 3
+)
+
+// Wait past the timeout of scan for it to return before exiting anyway
+const scanGrace = 30 * time.Second
+
+// scan checks every configured pool once, executing the candidates
+// found with -execute, prints the summary as JSON and returns the exit
+// code.
+func scan(ctx context.Context, cfg *liquidatoor.Config, args []string) (int, error) {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	execute := flags.Bool("execute", false, "Execute the liquidatable candidates found")
+	timeout := flags.Duration("timeout", 4*time.Minute, "Maximum duration of the scan, including connecting and executing")
+	if err := flags.Parse(args); err != nil {
+		return scanFailed, err
+	}
+	if *timeout <= 0 {
+		return scanFailed, fmt.Errorf("-timeout needs to be positive, got %v", *timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	// Calls that do not honor the deadline cannot hang the scan
+	watchdog := time.AfterFunc(*timeout+scanGrace, func() {
+		log.Printf("Scan did not return within %v, exiting", *timeout+scanGrace)
+		os.Exit(scanFailed)
+	})
+	defer watchdog.Stop()
+
+	conn, err := liquidatoor.Connect(ctx, cfg)
+	if err != nil {
+		return scanFailed, fmt.Errorf("cannot connect: %w", err)
+	}
+	defer conn.Close()
+	summary, err := conn.Scan(ctx, *execute)
+	if summary != nil {
+		out, jsonErr := json.MarshalIndent(summary, "", "  ")
+		if jsonErr != nil {
+			return scanFailed, jsonErr
+		}
+		fmt.Println(string(out))
+	}
+	switch {
+	case err != nil:
+		return scanFailed, err
+	case summary.Unscanned > 0:
+		return scanFailed, fmt.Errorf("%d pools could not be scanned", summary.Unscanned)
+	case summary.Executed > 0:
+		return scanExecuted, nil
+	case summary.Candidates > 0:
+		return scanCandidates, nil
+	}
+	return scanNoCandidates, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+)
+
+// state snapshots the data directory at DATA_DIR into an archive, or
+// restores it from one and reconciles it against the chain.
+func state(ctx context.Context, cfg *liquidatoor.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected snapshot or restore")
+	}
+	flags := flag.NewFlagSet("state "+args[0], flag.ContinueOnError)
+	force := flags.Bool("force", false, "Replace a non-empty data directory, moving it aside")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected the path of the archive")
+	}
+	path := flags.Arg(0)
+	warnOutsideDataDir(cfg)
+
+	switch args[0] {
+	case "snapshot":
+		tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+		if err != nil {
+			return fmt.Errorf("cannot create snapshot: %w", err)
+		}
+		defer os.Remove(tmp.Name())
+		manifest, err := liquidatoor.SnapshotState(cfg, tmp)
+		if err == nil {
+			err = tmp.Sync()
+		}
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return fmt.Errorf("cannot create snapshot: %w", err)
+		}
+		fmt.Printf("Archived %d files of %s to %s\n", len(manifest.Files), cfg.DataDir, path)
+		return nil
+
+	case "restore":
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("cannot open snapshot: %w", err)
+		}
+		defer file.Close()
+		manifest, err := liquidatoor.RestoreState(cfg, file, *force)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored %d files of a snapshot taken at %s to %s\n", len(manifest.Files), manifest.CreatedAt, cfg.DataDir)
+
+		// Reconciles pending transactions as on any start
+		conn, err := liquidatoor.Connect(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("restored, but cannot reconcile until the next start: %w", err)
+		}
+		conn.Close()
+		return nil
+
+	default:
+		return fmt.Errorf("unknown state command %q", args[0])
+	}
+}
+
+// warnOutsideDataDir warns about state configured outside the data
+// directory, which snapshots do not include.
+func warnOutsideDataDir(cfg *liquidatoor.Config) {
+	for env, path := range map[string]string{
+		"LEDGER_PATH":         cfg.LedgerPath,
+		"PENDING_TX_PATH":     cfg.PendingTxPath,
+		"JOURNAL_PATH":        cfg.JournalPath,
+		"BORROWER_CACHE_PATH": cfg.BorrowerCachePath,
+		"HISTORY_PATH":        cfg.HistoryPath,
+		"ALERTS_PATH":         cfg.AlertsPath,
+	} {
+		rel, err := filepath.Rel(cfg.DataDir, path)
+		if path != "" && (err != nil || strings.HasPrefix(rel, "..")) {
+			fmt.Fprintf(os.Stderr, "%s=%s is outside DATA_DIR and is not included\n", env, path)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package abis
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// DataTypesReserveConfigurationMap is an auto generated low-level Go binding around an user-defined struct.
+type DataTypesReserveConfigurationMap struct {
+	Data *big.Int
+}
+
+// DataTypesReserveData is an auto generated low-level Go binding around an user-defined struct.
+type DataTypesReserveData struct {
+	Configuration               DataTypesReserveConfigurationMap
+	LiquidityIndex              *big.Int
+	CurrentLiquidityRate        *big.Int
+	VariableBorrowIndex         *big.Int
+	CurrentVariableBorrowRate   *big.Int
+	CurrentStableBorrowRate     *big.Int
+	LastUpdateTimestamp         *big.Int
+	Id                          uint16
+	ATokenAddress               common.Address
+	StableDebtTokenAddress      common.Address
+	VariableDebtTokenAddress    common.Address
+	InterestRateStrategyAddress common.Address
+	AccruedToTreasury           *big.Int
+	Unbacked                    *big.Int
+	IsolationModeTotalDebt      *big.Int
+}
+
+// AaveV3PoolMetaData contains all meta data concerning the AaveV3Pool contract.
+var AaveV3PoolMetaData = &bind.MetaData{
+	ABI: "[{\"inputs\":[],\"name\":\"FLASHLOAN_PREMIUM_TOTAL\",\"outputs\":[{\"internalType\":\"uint128\",\"name\":\"\",\"type\":\"uint128\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"receiverAddress\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"amount\",\"type\":\"uint256\"},{\"internalType\":\"bytes\",\"name\":\"params\",\"type\":\"bytes\"},{\"internalType\":\"uint16\",\"name\":\"referralCode\",\"type\":\"uint16\"}],\"name\":\"flashLoanSimple\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"}],\"name\":\"getReserveData\",\"outputs\":[{\"components\":[{\"components\":[{\"internalType\":\"uint256\",\"name\":\"data\",\"type\":\"uint256\"}],\"internalType\":\"structDataTypes.ReserveConfigurationMap\",\"name\":\"configuration\",\"type\":\"tuple\"},{\"internalType\":\"uint128\",\"name\":\"liquidityIndex\",\"type\":\"uint128\"},{\"internalType\":\"uint128\",\"name\":\"currentLiquidityRate\",\"type\":\"uint128\"},{\"internalType\":\"uint128\",\"name\":\"variableBorrowIndex\",\"type\":\"uint128\"},{\"internalType\":\"uint128\",\"name\":\"currentVariableBorrowRate\",\"type\":\"uint128\"},{\"internalType\":\"uint128\",\"name\":\"currentStableBorrowRate\",\"type\":\"uint128\"},{\"internalType\":\"uint40\",\"name\":\"lastUpdateTimestamp\",\"type\":\"uint40\"},{\"internalType\":\"uint16\",\"name\":\"id\",\"type\":\"uint16\"},{\"internalType\":\"address\",\"name\":\"aTokenAddress\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"stableDebtTokenAddress\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"variableDebtTokenAddress\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"interestRateStrategyAddress\",\"type\":\"address\"},{\"internalType\":\"uint128\",\"name\":\"accruedToTreasury\",\"type\":\"uint128\"},{\"internalType\":\"uint128\",\"name\":\"unbacked\",\"type\":\"uint128\"},{\"internalType\":\"uint128\",\"name\":\"isolationModeTotalDebt\",\"type\":\"uint128\"}],\"internalType\":\"structDataTypes.ReserveData\",\"name\":\"\",\"type\":\"tuple\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]",
+}
+
+// AaveV3PoolABI is the input ABI used to generate the binding from.
+// Deprecated: Use AaveV3PoolMetaData.ABI instead.
+var AaveV3PoolABI = AaveV3PoolMetaData.ABI
+
+// AaveV3Pool is an auto generated Go binding around an Ethereum contract.
+type AaveV3Pool struct {
+	AaveV3PoolCaller     // Read-only binding to the contract
+	AaveV3PoolTransactor // Write-only binding to the contract
+	AaveV3PoolFilterer   // Log filterer for contract events
+}
+
+// AaveV3PoolCaller is an auto generated read-only Go binding around an Ethereum contract.
+type AaveV3PoolCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// AaveV3PoolTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type AaveV3PoolTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// AaveV3PoolFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type AaveV3PoolFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// AaveV3PoolSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type AaveV3PoolSession struct {
+	Contract     *AaveV3Pool       // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// AaveV3PoolCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type AaveV3PoolCallerSession struct {
+	Contract *AaveV3PoolCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts     // Call options to use throughout this session
+}
+
+// AaveV3PoolTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type AaveV3PoolTransactorSession struct {
+	Contract     *AaveV3PoolTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts     // Transaction auth options to use throughout this session
+}
+
+// AaveV3PoolRaw is an auto generated low-level Go binding around an Ethereum contract.
+type AaveV3PoolRaw struct {
+	Contract *AaveV3Pool // Generic contract binding to access the raw methods on
+}
+
+// AaveV3PoolCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type AaveV3PoolCallerRaw struct {
+	Contract *AaveV3PoolCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// AaveV3PoolTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type AaveV3PoolTransactorRaw struct {
+	Contract *AaveV3PoolTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewAaveV3Pool creates a new instance of AaveV3Pool, bound to a specific deployed contract.
+func NewAaveV3Pool(address common.Address, backend bind.ContractBackend) (*AaveV3Pool, error) {
+	contract, err := bindAaveV3Pool(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &AaveV3Pool{AaveV3PoolCaller: AaveV3PoolCaller{contract: contract}, AaveV3PoolTransactor: AaveV3PoolTransactor{contract: contract}, AaveV3PoolFilterer: AaveV3PoolFilterer{contract: contract}}, nil
+}
+
+// NewAaveV3PoolCaller creates a new read-only instance of AaveV3Pool, bound to a specific deployed contract.
+func NewAaveV3PoolCaller(address common.Address, caller bind.ContractCaller) (*AaveV3PoolCaller, error) {
+	contract, err := bindAaveV3Pool(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &AaveV3PoolCaller{contract: contract}, nil
+}
+
+// NewAaveV3PoolTransactor creates a new write-only instance of AaveV3Pool, bound to a specific deployed contract.
+func NewAaveV3PoolTransactor(address common.Address, transactor bind.ContractTransactor) (*AaveV3PoolTransactor, error) {
+	contract, err := bindAaveV3Pool(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &AaveV3PoolTransactor{contract: contract}, nil
+}
+
+// NewAaveV3PoolFilterer creates a new log filterer instance of AaveV3Pool, bound to a specific deployed contract.
+func NewAaveV3PoolFilterer(address common.Address, filterer bind.ContractFilterer) (*AaveV3PoolFilterer, error) {
+	contract, err := bindAaveV3Pool(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &AaveV3PoolFilterer{contract: contract}, nil
+}
+
+// bindAaveV3Pool binds a generic wrapper to an already deployed contract.
+func bindAaveV3Pool(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(AaveV3PoolABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_AaveV3Pool *AaveV3PoolRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _AaveV3Pool.Contract.AaveV3PoolCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_AaveV3Pool *AaveV3PoolRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _AaveV3Pool.Contract.AaveV3PoolTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_AaveV3Pool *AaveV3PoolRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _AaveV3Pool.Contract.AaveV3PoolTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_AaveV3Pool *AaveV3PoolCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _AaveV3Pool.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_AaveV3Pool *AaveV3PoolTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _AaveV3Pool.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_AaveV3Pool *AaveV3PoolTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _AaveV3Pool.Contract.contract.Transact(opts, method, params...)
+}
+
+// FLASHLOANPREMIUMTOTAL is a free data retrieval call binding the contract method 0x074b2e43.
+//
+// Solidity: function FLASHLOAN_PREMIUM_TOTAL() view returns(uint128)
+func (_AaveV3Pool *AaveV3PoolCaller) FLASHLOANPREMIUMTOTAL(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _AaveV3Pool.contract.Call(opts, &out, "FLASHLOAN_PREMIUM_TOTAL")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// FLASHLOANPREMIUMTOTAL is a free data retrieval call binding the contract method 0x074b2e43.
+//
+// Solidity: function FLASHLOAN_PREMIUM_TOTAL() view returns(uint128)
+func (_AaveV3Pool *AaveV3PoolSession) FLASHLOANPREMIUMTOTAL() (*big.Int, error) {
+	return _AaveV3Pool.Contract.FLASHLOANPREMIUMTOTAL(&_AaveV3Pool.CallOpts)
+}
+
+// FLASHLOANPREMIUMTOTAL is a free data retrieval call binding the contract method 0x074b2e43.
+//
+// Solidity: function FLASHLOAN_PREMIUM_TOTAL() view returns(uint128)
+func (_AaveV3Pool *AaveV3PoolCallerSession) FLASHLOANPREMIUMTOTAL() (*big.Int, error) {
+	return _AaveV3Pool.Contract.FLASHLOANPREMIUMTOTAL(&_AaveV3Pool.CallOpts)
+}
+
+// GetReserveData is a free data retrieval call binding the contract method 0x35ea6a75.
+//
+// Solidity: function getReserveData(address asset) view returns(((uint256),uint128,uint128,uint128,uint128,uint128,uint40,uint16,address,address,address,address,uint128,uint128,uint128))
+func (_AaveV3Pool *AaveV3PoolCaller) GetReserveData(opts *bind.CallOpts, asset common.Address) (DataTypesReserveData, error) {
+	var out []interface{}
+	err := _AaveV3Pool.contract.Call(opts, &out, "getReserveData", asset)
+
+	if err != nil {
+		return *new(DataTypesReserveData), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(DataTypesReserveData)).(*DataTypesReserveData)
+
+	return out0, err
+
+}
+
+// GetReserveData is a free data retrieval call binding the contract method 0x35ea6a75.
+//
+// Solidity: function getReserveData(address asset) view returns(((uint256),uint128,uint128,uint128,uint128,uint128,uint40,uint16,address,address,address,address,uint128,uint128,uint128))
+func (_AaveV3Pool *AaveV3PoolSession) GetReserveData(asset common.Address) (DataTypesReserveData, error) {
+	return _AaveV3Pool.Contract.GetReserveData(&_AaveV3Pool.CallOpts, asset)
+}
+
+// GetReserveData is a free data retrieval call binding the contract method 0x35ea6a75.
+//
+// Solidity: function getReserveData(address asset) view returns(((uint256),uint128,uint128,uint128,uint128,uint128,uint40,uint16,address,address,address,address,uint128,uint128,uint128))
+func (_AaveV3Pool *AaveV3PoolCallerSession) GetReserveData(asset common.Address) (DataTypesReserveData, error) {
+	return _AaveV3Pool.Contract.GetReserveData(&_AaveV3Pool.CallOpts, asset)
+}
+
+// FlashLoanSimple is a paid mutator transaction binding the contract method 0x42b0b77c.
+//
+// Solidity: function flashLoanSimple(address receiverAddress, address asset, uint256 amount, bytes params, uint16 referralCode) returns()
+func (_AaveV3Pool *AaveV3PoolTransactor) FlashLoanSimple(opts *bind.TransactOpts, receiverAddress common.Address, asset common.Address, amount *big.Int, params []byte, referralCode uint16) (*types.Transaction, error) {
+	return _AaveV3Pool.contract.Transact(opts, "flashLoanSimple", receiverAddress, asset, amount, params, referralCode)
+}
+
+// FlashLoanSimple is a paid mutator transaction binding the contract method 0x42b0b77c.
+//
+// Solidity: function flashLoanSimple(address receiverAddress, address asset, uint256 amount, bytes params, uint16 referralCode) returns()
+func (_AaveV3Pool *AaveV3PoolSession) FlashLoanSimple(receiverAddress common.Address, asset common.Address, amount *big.Int, params []byte, referralCode uint16) (*types.Transaction, error) {
+	return _AaveV3Pool.Contract.FlashLoanSimple(&_AaveV3Pool.TransactOpts, receiverAddress, asset, amount, params, referralCode)
+}
+
+// FlashLoanSimple is a paid mutator transaction binding the contract method 0x42b0b77c.
+//
+// Solidity: function flashLoanSimple(address receiverAddress, address asset, uint256 amount, bytes params, uint16 referralCode) returns()
+func (_AaveV3Pool *AaveV3PoolTransactorSession) FlashLoanSimple(receiverAddress common.Address, asset common.Address, amount *big.Int, params []byte, referralCode uint16) (*types.Transaction, error) {
+	return _AaveV3Pool.Contract.FlashLoanSimple(&_AaveV3Pool.TransactOpts, receiverAddress, asset, amount, params, referralCode)
+}
@@ -0,0 +1,243 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package abis
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// CEtherMetaData contains all meta data concerning the CEther contract.
+var CEtherMetaData = &bind.MetaData{
+	ABI: "[{\"inputs\":[{\"internalType\":\"address\",\"name\":\"borrower\",\"type\":\"address\"},{\"internalType\":\"contractCToken\",\"name\":\"cTokenCollateral\",\"type\":\"address\"}],\"name\":\"liquidateBorrow\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"mint\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"borrower\",\"type\":\"address\"}],\"name\":\"repayBorrowBehalf\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"}]",
+}
+
+// CEtherABI is the input ABI used to generate the binding from.
+// Deprecated: Use CEtherMetaData.ABI instead.
+var CEtherABI = CEtherMetaData.ABI
+
+// CEther is an auto generated Go binding around an Ethereum contract.
+type CEther struct {
+	CEtherCaller     // Read-only binding to the contract
+	CEtherTransactor // Write-only binding to the contract
+	CEtherFilterer   // Log filterer for contract events
+}
+
+// CEtherCaller is an auto generated read-only Go binding around an Ethereum contract.
+type CEtherCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// CEtherTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type CEtherTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// CEtherFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type CEtherFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// CEtherSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type CEtherSession struct {
+	Contract     *CEther           // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// CEtherCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type CEtherCallerSession struct {
+	Contract *CEtherCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts // Call options to use throughout this session
+}
+
+// CEtherTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type CEtherTransactorSession struct {
+	Contract     *CEtherTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// CEtherRaw is an auto generated low-level Go binding around an Ethereum contract.
+type CEtherRaw struct {
+	Contract *CEther // Generic contract binding to access the raw methods on
+}
+
+// CEtherCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type CEtherCallerRaw struct {
+	Contract *CEtherCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// CEtherTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type CEtherTransactorRaw struct {
+	Contract *CEtherTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewCEther creates a new instance of CEther, bound to a specific deployed contract.
+func NewCEther(address common.Address, backend bind.ContractBackend) (*CEther, error) {
+	contract, err := bindCEther(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &CEther{CEtherCaller: CEtherCaller{contract: contract}, CEtherTransactor: CEtherTransactor{contract: contract}, CEtherFilterer: CEtherFilterer{contract: contract}}, nil
+}
+
+// NewCEtherCaller creates a new read-only instance of CEther, bound to a specific deployed contract.
+func NewCEtherCaller(address common.Address, caller bind.ContractCaller) (*CEtherCaller, error) {
+	contract, err := bindCEther(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CEtherCaller{contract: contract}, nil
+}
+
+// NewCEtherTransactor creates a new write-only instance of CEther, bound to a specific deployed contract.
+func NewCEtherTransactor(address common.Address, transactor bind.ContractTransactor) (*CEtherTransactor, error) {
+	contract, err := bindCEther(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CEtherTransactor{contract: contract}, nil
+}
+
+// NewCEtherFilterer creates a new log filterer instance of CEther, bound to a specific deployed contract.
+func NewCEtherFilterer(address common.Address, filterer bind.ContractFilterer) (*CEtherFilterer, error) {
+	contract, err := bindCEther(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &CEtherFilterer{contract: contract}, nil
+}
+
+// bindCEther binds a generic wrapper to an already deployed contract.
+func bindCEther(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(CEtherABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_CEther *CEtherRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _CEther.Contract.CEtherCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_CEther *CEtherRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _CEther.Contract.CEtherTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_CEther *CEtherRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _CEther.Contract.CEtherTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_CEther *CEtherCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _CEther.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_CEther *CEtherTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _CEther.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_CEther *CEtherTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _CEther.Contract.contract.Transact(opts, method, params...)
+}
+
+// LiquidateBorrow is a paid mutator transaction binding the contract method 0xaae40a2a.
+//
+// Solidity: function liquidateBorrow(address borrower, address cTokenCollateral) payable returns()
+func (_CEther *CEtherTransactor) LiquidateBorrow(opts *bind.TransactOpts, borrower common.Address, cTokenCollateral common.Address) (*types.Transaction, error) {
+	return _CEther.contract.Transact(opts, "liquidateBorrow", borrower, cTokenCollateral)
+}
+
+// LiquidateBorrow is a paid mutator transaction binding the contract method 0xaae40a2a.
+//
+// Solidity: function liquidateBorrow(address borrower, address cTokenCollateral) payable returns()
+func (_CEther *CEtherSession) LiquidateBorrow(borrower common.Address, cTokenCollateral common.Address) (*types.Transaction, error) {
+	return _CEther.Contract.LiquidateBorrow(&_CEther.TransactOpts, borrower, cTokenCollateral)
+}
+
+// LiquidateBorrow is a paid mutator transaction binding the contract method 0xaae40a2a.
+//
+// Solidity: function liquidateBorrow(address borrower, address cTokenCollateral) payable returns()
+func (_CEther *CEtherTransactorSession) LiquidateBorrow(borrower common.Address, cTokenCollateral common.Address) (*types.Transaction, error) {
+	return _CEther.Contract.LiquidateBorrow(&_CEther.TransactOpts, borrower, cTokenCollateral)
+}
+
+// Mint is a paid mutator transaction binding the contract method 0x1249c58b.
+//
+// Solidity: function mint() payable returns()
+func (_CEther *CEtherTransactor) Mint(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _CEther.contract.Transact(opts, "mint")
+}
+
+// Mint is a paid mutator transaction binding the contract method 0x1249c58b.
+//
+// Solidity: function mint() payable returns()
+func (_CEther *CEtherSession) Mint() (*types.Transaction, error) {
+	return _CEther.Contract.Mint(&_CEther.TransactOpts)
+}
+
+// Mint is a paid mutator transaction binding the contract method 0x1249c58b.
+//
+// Solidity: function mint() payable returns()
+func (_CEther *CEtherTransactorSession) Mint() (*types.Transaction, error) {
+	return _CEther.Contract.Mint(&_CEther.TransactOpts)
+}
+
+// RepayBorrowBehalf is a paid mutator transaction binding the contract method 0xe5974619.
+//
+// Solidity: function repayBorrowBehalf(address borrower) payable returns()
+func (_CEther *CEtherTransactor) RepayBorrowBehalf(opts *bind.TransactOpts, borrower common.Address) (*types.Transaction, error) {
+	return _CEther.contract.Transact(opts, "repayBorrowBehalf", borrower)
+}
+
+// RepayBorrowBehalf is a paid mutator transaction binding the contract method 0xe5974619.
+//
+// Solidity: function repayBorrowBehalf(address borrower) payable returns()
+func (_CEther *CEtherSession) RepayBorrowBehalf(borrower common.Address) (*types.Transaction, error) {
+	return _CEther.Contract.RepayBorrowBehalf(&_CEther.TransactOpts, borrower)
+}
+
+// RepayBorrowBehalf is a paid mutator transaction binding the contract method 0xe5974619.
+//
+// Solidity: function repayBorrowBehalf(address borrower) payable returns()
+func (_CEther *CEtherTransactorSession) RepayBorrowBehalf(borrower common.Address) (*types.Transaction, error) {
+	return _CEther.Contract.RepayBorrowBehalf(&_CEther.TransactOpts, borrower)
+}
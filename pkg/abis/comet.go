@@ -0,0 +1,1683 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package abis
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// CometCoreAssetInfo is an auto generated low-level Go binding around an user-defined struct.
+type CometCoreAssetInfo struct {
+	Offset                    uint8
+	Asset                     common.Address
+	PriceFeed                 common.Address
+	Scale                     uint64
+	BorrowCollateralFactor    uint64
+	LiquidateCollateralFactor uint64
+	LiquidationFactor         uint64
+	SupplyCap                 *big.Int
+}
+
+// CometMetaData contains all meta data concerning the Comet contract.
+var CometMetaData = &bind.MetaData{
+	ABI: "[{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"absorber\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"borrower\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"collateralAbsorbed\",\"type\":\"uint256\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"usdValue\",\"type\":\"uint256\"}],\"name\":\"AbsorbCollateral\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"absorber\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"borrower\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"basePaidOut\",\"type\":\"uint256\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"usdValue\",\"type\":\"uint256\"}],\"name\":\"AbsorbDebt\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"buyer\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"baseAmount\",\"type\":\"uint256\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"collateralAmount\",\"type\":\"uint256\"}],\"name\":\"BuyCollateral\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"from\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"dst\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"amount\",\"type\":\"uint256\"}],\"name\":\"Supply\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"from\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"dst\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"amount\",\"type\":\"uint256\"}],\"name\":\"SupplyCollateral\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"src\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"to\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"amount\",\"type\":\"uint256\"}],\"name\":\"Withdraw\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"src\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"to\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"amount\",\"type\":\"uint256\"}],\"name\":\"WithdrawCollateral\",\"type\":\"event\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"absorber\",\"type\":\"address\"},{\"internalType\":\"address[]\",\"name\":\"accounts\",\"type\":\"address[]\"}],\"name\":\"absorb\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"baseToken\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"account\",\"type\":\"address\"}],\"name\":\"borrowBalanceOf\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"minAmount\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"baseAmount\",\"type\":\"uint256\"},{\"internalType\":\"address\",\"name\":\"recipient\",\"type\":\"address\"}],\"name\":\"buyCollateral\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"account\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"}],\"name\":\"collateralBalanceOf\",\"outputs\":[{\"internalType\":\"uint128\",\"name\":\"\",\"type\":\"uint128\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint8\",\"name\":\"i\",\"type\":\"uint8\"}],\"name\":\"getAssetInfo\",\"outputs\":[{\"components\":[{\"internalType\":\"uint8\",\"name\":\"offset\",\"type\":\"uint8\"},{\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"priceFeed\",\"type\":\"address\"},{\"internalType\":\"uint64\",\"name\":\"scale\",\"type\":\"uint64\"},{\"internalType\":\"uint64\",\"name\":\"borrowCollateralFactor\",\"type\":\"uint64\"},{\"internalType\":\"uint64\",\"name\":\"liquidateCollateralFactor\",\"type\":\"uint64\"},{\"internalType\":\"uint64\",\"name\":\"liquidationFactor\",\"type\":\"uint64\"},{\"internalType\":\"uint128\",\"name\":\"supplyCap\",\"type\":\"uint128\"}],\"internalType\":\"structCometCore.AssetInfo\",\"name\":\"\",\"type\":\"tuple\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"}],\"name\":\"getCollateralReserves\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getReserves\",\"outputs\":[{\"internalType\":\"int256\",\"name\":\"\",\"type\":\"int256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"account\",\"type\":\"address\"}],\"name\":\"isLiquidatable\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"numAssets\",\"outputs\":[{\"internalType\":\"uint8\",\"name\":\"\",\"type\":\"uint8\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"baseAmount\",\"type\":\"uint256\"}],\"name\":\"quoteCollateral\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"storeFrontPriceFactor\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"targetReserves\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]",
+}
+
+// CometABI is the input ABI used to generate the binding from.
+// Deprecated: Use CometMetaData.ABI instead.
+var CometABI = CometMetaData.ABI
+
+// Comet is an auto generated Go binding around an Ethereum contract.
+type Comet struct {
+	CometCaller     // Read-only binding to the contract
+	CometTransactor // Write-only binding to the contract
+	CometFilterer   // Log filterer for contract events
+}
+
+// CometCaller is an auto generated read-only Go binding around an Ethereum contract.
+type CometCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// CometTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type CometTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// CometFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type CometFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// CometSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type CometSession struct {
+	Contract     *Comet            // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// CometCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type CometCallerSession struct {
+	Contract *CometCaller  // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts // Call options to use throughout this session
+}
+
+// CometTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type CometTransactorSession struct {
+	Contract     *CometTransactor  // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// CometRaw is an auto generated low-level Go binding around an Ethereum contract.
+type CometRaw struct {
+	Contract *Comet // Generic contract binding to access the raw methods on
+}
+
+// CometCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type CometCallerRaw struct {
+	Contract *CometCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// CometTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type CometTransactorRaw struct {
+	Contract *CometTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewComet creates a new instance of Comet, bound to a specific deployed contract.
+func NewComet(address common.Address, backend bind.ContractBackend) (*Comet, error) {
+	contract, err := bindComet(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Comet{CometCaller: CometCaller{contract: contract}, CometTransactor: CometTransactor{contract: contract}, CometFilterer: CometFilterer{contract: contract}}, nil
+}
+
+// NewCometCaller creates a new read-only instance of Comet, bound to a specific deployed contract.
+func NewCometCaller(address common.Address, caller bind.ContractCaller) (*CometCaller, error) {
+	contract, err := bindComet(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CometCaller{contract: contract}, nil
+}
+
+// NewCometTransactor creates a new write-only instance of Comet, bound to a specific deployed contract.
+func NewCometTransactor(address common.Address, transactor bind.ContractTransactor) (*CometTransactor, error) {
+	contract, err := bindComet(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CometTransactor{contract: contract}, nil
+}
+
+// NewCometFilterer creates a new log filterer instance of Comet, bound to a specific deployed contract.
+func NewCometFilterer(address common.Address, filterer bind.ContractFilterer) (*CometFilterer, error) {
+	contract, err := bindComet(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &CometFilterer{contract: contract}, nil
+}
+
+// bindComet binds a generic wrapper to an already deployed contract.
+func bindComet(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(CometABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Comet *CometRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Comet.Contract.CometCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Comet *CometRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Comet.Contract.CometTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Comet *CometRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Comet.Contract.CometTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Comet *CometCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Comet.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Comet *CometTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Comet.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Comet *CometTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Comet.Contract.contract.Transact(opts, method, params...)
+}
+
+// BaseToken is a free data retrieval call binding the contract method 0xc55dae63.
+//
+// Solidity: function baseToken() view returns(address)
+func (_Comet *CometCaller) BaseToken(opts *bind.CallOpts) (common.Address, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "baseToken")
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
+}
+
+// BaseToken is a free data retrieval call binding the contract method 0xc55dae63.
+//
+// Solidity: function baseToken() view returns(address)
+func (_Comet *CometSession) BaseToken() (common.Address, error) {
+	return _Comet.Contract.BaseToken(&_Comet.CallOpts)
+}
+
+// BaseToken is a free data retrieval call binding the contract method 0xc55dae63.
+//
+// Solidity: function baseToken() view returns(address)
+func (_Comet *CometCallerSession) BaseToken() (common.Address, error) {
+	return _Comet.Contract.BaseToken(&_Comet.CallOpts)
+}
+
+// BorrowBalanceOf is a free data retrieval call binding the contract method 0x374c49b4.
+//
+// Solidity: function borrowBalanceOf(address account) view returns(uint256)
+func (_Comet *CometCaller) BorrowBalanceOf(opts *bind.CallOpts, account common.Address) (*big.Int, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "borrowBalanceOf", account)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// BorrowBalanceOf is a free data retrieval call binding the contract method 0x374c49b4.
+//
+// Solidity: function borrowBalanceOf(address account) view returns(uint256)
+func (_Comet *CometSession) BorrowBalanceOf(account common.Address) (*big.Int, error) {
+	return _Comet.Contract.BorrowBalanceOf(&_Comet.CallOpts, account)
+}
+
+// BorrowBalanceOf is a free data retrieval call binding the contract method 0x374c49b4.
+//
+// Solidity: function borrowBalanceOf(address account) view returns(uint256)
+func (_Comet *CometCallerSession) BorrowBalanceOf(account common.Address) (*big.Int, error) {
+	return _Comet.Contract.BorrowBalanceOf(&_Comet.CallOpts, account)
+}
+
+// CollateralBalanceOf is a free data retrieval call binding the contract method 0x5c2549ee.
+//
+// Solidity: function collateralBalanceOf(address account, address asset) view returns(uint128)
+func (_Comet *CometCaller) CollateralBalanceOf(opts *bind.CallOpts, account common.Address, asset common.Address) (*big.Int, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "collateralBalanceOf", account, asset)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// CollateralBalanceOf is a free data retrieval call binding the contract method 0x5c2549ee.
+//
+// Solidity: function collateralBalanceOf(address account, address asset) view returns(uint128)
+func (_Comet *CometSession) CollateralBalanceOf(account common.Address, asset common.Address) (*big.Int, error) {
+	return _Comet.Contract.CollateralBalanceOf(&_Comet.CallOpts, account, asset)
+}
+
+// CollateralBalanceOf is a free data retrieval call binding the contract method 0x5c2549ee.
+//
+// Solidity: function collateralBalanceOf(address account, address asset) view returns(uint128)
+func (_Comet *CometCallerSession) CollateralBalanceOf(account common.Address, asset common.Address) (*big.Int, error) {
+	return _Comet.Contract.CollateralBalanceOf(&_Comet.CallOpts, account, asset)
+}
+
+// GetAssetInfo is a free data retrieval call binding the contract method 0xc8c7fe6b.
+//
+// Solidity: function getAssetInfo(uint8 i) view returns((uint8,address,address,uint64,uint64,uint64,uint64,uint128))
+func (_Comet *CometCaller) GetAssetInfo(opts *bind.CallOpts, i uint8) (CometCoreAssetInfo, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "getAssetInfo", i)
+
+	if err != nil {
+		return *new(CometCoreAssetInfo), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(CometCoreAssetInfo)).(*CometCoreAssetInfo)
+
+	return out0, err
+
+}
+
+// GetAssetInfo is a free data retrieval call binding the contract method 0xc8c7fe6b.
+//
+// Solidity: function getAssetInfo(uint8 i) view returns((uint8,address,address,uint64,uint64,uint64,uint64,uint128))
+func (_Comet *CometSession) GetAssetInfo(i uint8) (CometCoreAssetInfo, error) {
+	return _Comet.Contract.GetAssetInfo(&_Comet.CallOpts, i)
+}
+
+// GetAssetInfo is a free data retrieval call binding the contract method 0xc8c7fe6b.
+//
+// Solidity: function getAssetInfo(uint8 i) view returns((uint8,address,address,uint64,uint64,uint64,uint64,uint128))
+func (_Comet *CometCallerSession) GetAssetInfo(i uint8) (CometCoreAssetInfo, error) {
+	return _Comet.Contract.GetAssetInfo(&_Comet.CallOpts, i)
+}
+
+// GetCollateralReserves is a free data retrieval call binding the contract method 0x9ff567f8.
+//
+// Solidity: function getCollateralReserves(address asset) view returns(uint256)
+func (_Comet *CometCaller) GetCollateralReserves(opts *bind.CallOpts, asset common.Address) (*big.Int, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "getCollateralReserves", asset)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// GetCollateralReserves is a free data retrieval call binding the contract method 0x9ff567f8.
+//
+// Solidity: function getCollateralReserves(address asset) view returns(uint256)
+func (_Comet *CometSession) GetCollateralReserves(asset common.Address) (*big.Int, error) {
+	return _Comet.Contract.GetCollateralReserves(&_Comet.CallOpts, asset)
+}
+
+// GetCollateralReserves is a free data retrieval call binding the contract method 0x9ff567f8.
+//
+// Solidity: function getCollateralReserves(address asset) view returns(uint256)
+func (_Comet *CometCallerSession) GetCollateralReserves(asset common.Address) (*big.Int, error) {
+	return _Comet.Contract.GetCollateralReserves(&_Comet.CallOpts, asset)
+}
+
+// GetReserves is a free data retrieval call binding the contract method 0x0902f1ac.
+//
+// Solidity: function getReserves() view returns(int256)
+func (_Comet *CometCaller) GetReserves(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "getReserves")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// GetReserves is a free data retrieval call binding the contract method 0x0902f1ac.
+//
+// Solidity: function getReserves() view returns(int256)
+func (_Comet *CometSession) GetReserves() (*big.Int, error) {
+	return _Comet.Contract.GetReserves(&_Comet.CallOpts)
+}
+
+// GetReserves is a free data retrieval call binding the contract method 0x0902f1ac.
+//
+// Solidity: function getReserves() view returns(int256)
+func (_Comet *CometCallerSession) GetReserves() (*big.Int, error) {
+	return _Comet.Contract.GetReserves(&_Comet.CallOpts)
+}
+
+// IsLiquidatable is a free data retrieval call binding the contract method 0x042e02cf.
+//
+// Solidity: function isLiquidatable(address account) view returns(bool)
+func (_Comet *CometCaller) IsLiquidatable(opts *bind.CallOpts, account common.Address) (bool, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "isLiquidatable", account)
+
+	if err != nil {
+		return *new(bool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(bool)).(*bool)
+
+	return out0, err
+
+}
+
+// IsLiquidatable is a free data retrieval call binding the contract method 0x042e02cf.
+//
+// Solidity: function isLiquidatable(address account) view returns(bool)
+func (_Comet *CometSession) IsLiquidatable(account common.Address) (bool, error) {
+	return _Comet.Contract.IsLiquidatable(&_Comet.CallOpts, account)
+}
+
+// IsLiquidatable is a free data retrieval call binding the contract method 0x042e02cf.
+//
+// Solidity: function isLiquidatable(address account) view returns(bool)
+func (_Comet *CometCallerSession) IsLiquidatable(account common.Address) (bool, error) {
+	return _Comet.Contract.IsLiquidatable(&_Comet.CallOpts, account)
+}
+
+// NumAssets is a free data retrieval call binding the contract method 0xa46fe83b.
+//
+// Solidity: function numAssets() view returns(uint8)
+func (_Comet *CometCaller) NumAssets(opts *bind.CallOpts) (uint8, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "numAssets")
+
+	if err != nil {
+		return *new(uint8), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(uint8)).(*uint8)
+
+	return out0, err
+
+}
+
+// NumAssets is a free data retrieval call binding the contract method 0xa46fe83b.
+//
+// Solidity: function numAssets() view returns(uint8)
+func (_Comet *CometSession) NumAssets() (uint8, error) {
+	return _Comet.Contract.NumAssets(&_Comet.CallOpts)
+}
+
+// NumAssets is a free data retrieval call binding the contract method 0xa46fe83b.
+//
+// Solidity: function numAssets() view returns(uint8)
+func (_Comet *CometCallerSession) NumAssets() (uint8, error) {
+	return _Comet.Contract.NumAssets(&_Comet.CallOpts)
+}
+
+// QuoteCollateral is a free data retrieval call binding the contract method 0x7ac88ed1.
+//
+// Solidity: function quoteCollateral(address asset, uint256 baseAmount) view returns(uint256)
+func (_Comet *CometCaller) QuoteCollateral(opts *bind.CallOpts, asset common.Address, baseAmount *big.Int) (*big.Int, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "quoteCollateral", asset, baseAmount)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// QuoteCollateral is a free data retrieval call binding the contract method 0x7ac88ed1.
+//
+// Solidity: function quoteCollateral(address asset, uint256 baseAmount) view returns(uint256)
+func (_Comet *CometSession) QuoteCollateral(asset common.Address, baseAmount *big.Int) (*big.Int, error) {
+	return _Comet.Contract.QuoteCollateral(&_Comet.CallOpts, asset, baseAmount)
+}
+
+// QuoteCollateral is a free data retrieval call binding the contract method 0x7ac88ed1.
+//
+// Solidity: function quoteCollateral(address asset, uint256 baseAmount) view returns(uint256)
+func (_Comet *CometCallerSession) QuoteCollateral(asset common.Address, baseAmount *big.Int) (*big.Int, error) {
+	return _Comet.Contract.QuoteCollateral(&_Comet.CallOpts, asset, baseAmount)
+}
+
+// StoreFrontPriceFactor is a free data retrieval call binding the contract method 0x1f5954bd.
+//
+// Solidity: function storeFrontPriceFactor() view returns(uint256)
+func (_Comet *CometCaller) StoreFrontPriceFactor(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "storeFrontPriceFactor")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// StoreFrontPriceFactor is a free data retrieval call binding the contract method 0x1f5954bd.
+//
+// Solidity: function storeFrontPriceFactor() view returns(uint256)
+func (_Comet *CometSession) StoreFrontPriceFactor() (*big.Int, error) {
+	return _Comet.Contract.StoreFrontPriceFactor(&_Comet.CallOpts)
+}
+
+// StoreFrontPriceFactor is a free data retrieval call binding the contract method 0x1f5954bd.
+//
+// Solidity: function storeFrontPriceFactor() view returns(uint256)
+func (_Comet *CometCallerSession) StoreFrontPriceFactor() (*big.Int, error) {
+	return _Comet.Contract.StoreFrontPriceFactor(&_Comet.CallOpts)
+}
+
+// TargetReserves is a free data retrieval call binding the contract method 0x32176c49.
+//
+// Solidity: function targetReserves() view returns(uint256)
+func (_Comet *CometCaller) TargetReserves(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _Comet.contract.Call(opts, &out, "targetReserves")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// TargetReserves is a free data retrieval call binding the contract method 0x32176c49.
+//
+// Solidity: function targetReserves() view returns(uint256)
+func (_Comet *CometSession) TargetReserves() (*big.Int, error) {
+	return _Comet.Contract.TargetReserves(&_Comet.CallOpts)
+}
+
+// TargetReserves is a free data retrieval call binding the contract method 0x32176c49.
+//
+// Solidity: function targetReserves() view returns(uint256)
+func (_Comet *CometCallerSession) TargetReserves() (*big.Int, error) {
+	return _Comet.Contract.TargetReserves(&_Comet.CallOpts)
+}
+
+// Absorb is a paid mutator transaction binding the contract method 0xc3cecfd2.
+//
+// Solidity: function absorb(address absorber, address[] accounts) returns()
+func (_Comet *CometTransactor) Absorb(opts *bind.TransactOpts, absorber common.Address, accounts []common.Address) (*types.Transaction, error) {
+	return _Comet.contract.Transact(opts, "absorb", absorber, accounts)
+}
+
+// Absorb is a paid mutator transaction binding the contract method 0xc3cecfd2.
+//
+// Solidity: function absorb(address absorber, address[] accounts) returns()
+func (_Comet *CometSession) Absorb(absorber common.Address, accounts []common.Address) (*types.Transaction, error) {
+	return _Comet.Contract.Absorb(&_Comet.TransactOpts, absorber, accounts)
+}
+
+// Absorb is a paid mutator transaction binding the contract method 0xc3cecfd2.
+//
+// Solidity: function absorb(address absorber, address[] accounts) returns()
+func (_Comet *CometTransactorSession) Absorb(absorber common.Address, accounts []common.Address) (*types.Transaction, error) {
+	return _Comet.Contract.Absorb(&_Comet.TransactOpts, absorber, accounts)
+}
+
+// BuyCollateral is a paid mutator transaction binding the contract method 0xe4e6e779.
+//
+// Solidity: function buyCollateral(address asset, uint256 minAmount, uint256 baseAmount, address recipient) returns()
+func (_Comet *CometTransactor) BuyCollateral(opts *bind.TransactOpts, asset common.Address, minAmount *big.Int, baseAmount *big.Int, recipient common.Address) (*types.Transaction, error) {
+	return _Comet.contract.Transact(opts, "buyCollateral", asset, minAmount, baseAmount, recipient)
+}
+
+// BuyCollateral is a paid mutator transaction binding the contract method 0xe4e6e779.
+//
+// Solidity: function buyCollateral(address asset, uint256 minAmount, uint256 baseAmount, address recipient) returns()
+func (_Comet *CometSession) BuyCollateral(asset common.Address, minAmount *big.Int, baseAmount *big.Int, recipient common.Address) (*types.Transaction, error) {
+	return _Comet.Contract.BuyCollateral(&_Comet.TransactOpts, asset, minAmount, baseAmount, recipient)
+}
+
+// BuyCollateral is a paid mutator transaction binding the contract method 0xe4e6e779.
+//
+// Solidity: function buyCollateral(address asset, uint256 minAmount, uint256 baseAmount, address recipient) returns()
+func (_Comet *CometTransactorSession) BuyCollateral(asset common.Address, minAmount *big.Int, baseAmount *big.Int, recipient common.Address) (*types.Transaction, error) {
+	return _Comet.Contract.BuyCollateral(&_Comet.TransactOpts, asset, minAmount, baseAmount, recipient)
+}
+
+// CometAbsorbCollateralIterator is returned from FilterAbsorbCollateral and is used to iterate over the raw logs and unpacked data for AbsorbCollateral events raised by the Comet contract.
+type CometAbsorbCollateralIterator struct {
+	Event *CometAbsorbCollateral // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *CometAbsorbCollateralIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(CometAbsorbCollateral)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(CometAbsorbCollateral)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *CometAbsorbCollateralIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *CometAbsorbCollateralIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// CometAbsorbCollateral represents a AbsorbCollateral event raised by the Comet contract.
+type CometAbsorbCollateral struct {
+	Absorber           common.Address
+	Borrower           common.Address
+	Asset              common.Address
+	CollateralAbsorbed *big.Int
+	UsdValue           *big.Int
+	Raw                types.Log // Blockchain specific contextual infos
+}
+
+// FilterAbsorbCollateral is a free log retrieval operation binding the contract event 0x9850ab1af75177e4a9201c65a2cf7976d5d28e40ef63494b44366f86b2f9412e.
+//
+// Solidity: event AbsorbCollateral(address indexed absorber, address indexed borrower, address indexed asset, uint256 collateralAbsorbed, uint256 usdValue)
+func (_Comet *CometFilterer) FilterAbsorbCollateral(opts *bind.FilterOpts, absorber []common.Address, borrower []common.Address, asset []common.Address) (*CometAbsorbCollateralIterator, error) {
+
+	var absorberRule []interface{}
+	for _, absorberItem := range absorber {
+		absorberRule = append(absorberRule, absorberItem)
+	}
+	var borrowerRule []interface{}
+	for _, borrowerItem := range borrower {
+		borrowerRule = append(borrowerRule, borrowerItem)
+	}
+	var assetRule []interface{}
+	for _, assetItem := range asset {
+		assetRule = append(assetRule, assetItem)
+	}
+
+	logs, sub, err := _Comet.contract.FilterLogs(opts, "AbsorbCollateral", absorberRule, borrowerRule, assetRule)
+	if err != nil {
+		return nil, err
+	}
+	return &CometAbsorbCollateralIterator{contract: _Comet.contract, event: "AbsorbCollateral", logs: logs, sub: sub}, nil
+}
+
+// WatchAbsorbCollateral is a free log subscription operation binding the contract event 0x9850ab1af75177e4a9201c65a2cf7976d5d28e40ef63494b44366f86b2f9412e.
+//
+// Solidity: event AbsorbCollateral(address indexed absorber, address indexed borrower, address indexed asset, uint256 collateralAbsorbed, uint256 usdValue)
+func (_Comet *CometFilterer) WatchAbsorbCollateral(opts *bind.WatchOpts, sink chan<- *CometAbsorbCollateral, absorber []common.Address, borrower []common.Address, asset []common.Address) (event.Subscription, error) {
+
+	var absorberRule []interface{}
+	for _, absorberItem := range absorber {
+		absorberRule = append(absorberRule, absorberItem)
+	}
+	var borrowerRule []interface{}
+	for _, borrowerItem := range borrower {
+		borrowerRule = append(borrowerRule, borrowerItem)
+	}
+	var assetRule []interface{}
+	for _, assetItem := range asset {
+		assetRule = append(assetRule, assetItem)
+	}
+
+	logs, sub, err := _Comet.contract.WatchLogs(opts, "AbsorbCollateral", absorberRule, borrowerRule, assetRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(CometAbsorbCollateral)
+				if err := _Comet.contract.UnpackLog(event, "AbsorbCollateral", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseAbsorbCollateral is a log parse operation binding the contract event 0x9850ab1af75177e4a9201c65a2cf7976d5d28e40ef63494b44366f86b2f9412e.
+//
+// Solidity: event AbsorbCollateral(address indexed absorber, address indexed borrower, address indexed asset, uint256 collateralAbsorbed, uint256 usdValue)
+func (_Comet *CometFilterer) ParseAbsorbCollateral(log types.Log) (*CometAbsorbCollateral, error) {
+	event := new(CometAbsorbCollateral)
+	if err := _Comet.contract.UnpackLog(event, "AbsorbCollateral", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// CometAbsorbDebtIterator is returned from FilterAbsorbDebt and is used to iterate over the raw logs and unpacked data for AbsorbDebt events raised by the Comet contract.
+type CometAbsorbDebtIterator struct {
+	Event *CometAbsorbDebt // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *CometAbsorbDebtIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(CometAbsorbDebt)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(CometAbsorbDebt)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *CometAbsorbDebtIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *CometAbsorbDebtIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// CometAbsorbDebt represents a AbsorbDebt event raised by the Comet contract.
+type CometAbsorbDebt struct {
+	Absorber    common.Address
+	Borrower    common.Address
+	BasePaidOut *big.Int
+	UsdValue    *big.Int
+	Raw         types.Log // Blockchain specific contextual infos
+}
+
+// FilterAbsorbDebt is a free log retrieval operation binding the contract event 0x1547a878dc89ad3c367b6338b4be6a65a5dd74fb77ae044da1e8747ef1f4f62f.
+//
+// Solidity: event AbsorbDebt(address indexed absorber, address indexed borrower, uint256 basePaidOut, uint256 usdValue)
+func (_Comet *CometFilterer) FilterAbsorbDebt(opts *bind.FilterOpts, absorber []common.Address, borrower []common.Address) (*CometAbsorbDebtIterator, error) {
+
+	var absorberRule []interface{}
+	for _, absorberItem := range absorber {
+		absorberRule = append(absorberRule, absorberItem)
+	}
+	var borrowerRule []interface{}
+	for _, borrowerItem := range borrower {
+		borrowerRule = append(borrowerRule, borrowerItem)
+	}
+
+	logs, sub, err := _Comet.contract.FilterLogs(opts, "AbsorbDebt", absorberRule, borrowerRule)
+	if err != nil {
+		return nil, err
+	}
+	return &CometAbsorbDebtIterator{contract: _Comet.contract, event: "AbsorbDebt", logs: logs, sub: sub}, nil
+}
+
+// WatchAbsorbDebt is a free log subscription operation binding the contract event 0x1547a878dc89ad3c367b6338b4be6a65a5dd74fb77ae044da1e8747ef1f4f62f.
+//
+// Solidity: event AbsorbDebt(address indexed absorber, address indexed borrower, uint256 basePaidOut, uint256 usdValue)
+func (_Comet *CometFilterer) WatchAbsorbDebt(opts *bind.WatchOpts, sink chan<- *CometAbsorbDebt, absorber []common.Address, borrower []common.Address) (event.Subscription, error) {
+
+	var absorberRule []interface{}
+	for _, absorberItem := range absorber {
+		absorberRule = append(absorberRule, absorberItem)
+	}
+	var borrowerRule []interface{}
+	for _, borrowerItem := range borrower {
+		borrowerRule = append(borrowerRule, borrowerItem)
+	}
+
+	logs, sub, err := _Comet.contract.WatchLogs(opts, "AbsorbDebt", absorberRule, borrowerRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(CometAbsorbDebt)
+				if err := _Comet.contract.UnpackLog(event, "AbsorbDebt", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseAbsorbDebt is a log parse operation binding the contract event 0x1547a878dc89ad3c367b6338b4be6a65a5dd74fb77ae044da1e8747ef1f4f62f.
+//
+// Solidity: event AbsorbDebt(address indexed absorber, address indexed borrower, uint256 basePaidOut, uint256 usdValue)
+func (_Comet *CometFilterer) ParseAbsorbDebt(log types.Log) (*CometAbsorbDebt, error) {
+	event := new(CometAbsorbDebt)
+	if err := _Comet.contract.UnpackLog(event, "AbsorbDebt", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// CometBuyCollateralIterator is returned from FilterBuyCollateral and is used to iterate over the raw logs and unpacked data for BuyCollateral events raised by the Comet contract.
+type CometBuyCollateralIterator struct {
+	Event *CometBuyCollateral // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *CometBuyCollateralIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(CometBuyCollateral)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(CometBuyCollateral)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *CometBuyCollateralIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *CometBuyCollateralIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// CometBuyCollateral represents a BuyCollateral event raised by the Comet contract.
+type CometBuyCollateral struct {
+	Buyer            common.Address
+	Asset            common.Address
+	BaseAmount       *big.Int
+	CollateralAmount *big.Int
+	Raw              types.Log // Blockchain specific contextual infos
+}
+
+// FilterBuyCollateral is a free log retrieval operation binding the contract event 0xf891b2a411b0e66a5f0a6ff1368670fefa287a13f541eb633a386a1a9cc7046b.
+//
+// Solidity: event BuyCollateral(address indexed buyer, address indexed asset, uint256 baseAmount, uint256 collateralAmount)
+func (_Comet *CometFilterer) FilterBuyCollateral(opts *bind.FilterOpts, buyer []common.Address, asset []common.Address) (*CometBuyCollateralIterator, error) {
+
+	var buyerRule []interface{}
+	for _, buyerItem := range buyer {
+		buyerRule = append(buyerRule, buyerItem)
+	}
+	var assetRule []interface{}
+	for _, assetItem := range asset {
+		assetRule = append(assetRule, assetItem)
+	}
+
+	logs, sub, err := _Comet.contract.FilterLogs(opts, "BuyCollateral", buyerRule, assetRule)
+	if err != nil {
+		return nil, err
+	}
+	return &CometBuyCollateralIterator{contract: _Comet.contract, event: "BuyCollateral", logs: logs, sub: sub}, nil
+}
+
+// WatchBuyCollateral is a free log subscription operation binding the contract event 0xf891b2a411b0e66a5f0a6ff1368670fefa287a13f541eb633a386a1a9cc7046b.
+//
+// Solidity: event BuyCollateral(address indexed buyer, address indexed asset, uint256 baseAmount, uint256 collateralAmount)
+func (_Comet *CometFilterer) WatchBuyCollateral(opts *bind.WatchOpts, sink chan<- *CometBuyCollateral, buyer []common.Address, asset []common.Address) (event.Subscription, error) {
+
+	var buyerRule []interface{}
+	for _, buyerItem := range buyer {
+		buyerRule = append(buyerRule, buyerItem)
+	}
+	var assetRule []interface{}
+	for _, assetItem := range asset {
+		assetRule = append(assetRule, assetItem)
+	}
+
+	logs, sub, err := _Comet.contract.WatchLogs(opts, "BuyCollateral", buyerRule, assetRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(CometBuyCollateral)
+				if err := _Comet.contract.UnpackLog(event, "BuyCollateral", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseBuyCollateral is a log parse operation binding the contract event 0xf891b2a411b0e66a5f0a6ff1368670fefa287a13f541eb633a386a1a9cc7046b.
+//
+// Solidity: event BuyCollateral(address indexed buyer, address indexed asset, uint256 baseAmount, uint256 collateralAmount)
+func (_Comet *CometFilterer) ParseBuyCollateral(log types.Log) (*CometBuyCollateral, error) {
+	event := new(CometBuyCollateral)
+	if err := _Comet.contract.UnpackLog(event, "BuyCollateral", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// CometSupplyIterator is returned from FilterSupply and is used to iterate over the raw logs and unpacked data for Supply events raised by the Comet contract.
+type CometSupplyIterator struct {
+	Event *CometSupply // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *CometSupplyIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(CometSupply)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(CometSupply)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *CometSupplyIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *CometSupplyIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// CometSupply represents a Supply event raised by the Comet contract.
+type CometSupply struct {
+	From   common.Address
+	Dst    common.Address
+	Amount *big.Int
+	Raw    types.Log // Blockchain specific contextual infos
+}
+
+// FilterSupply is a free log retrieval operation binding the contract event 0xd1cf3d156d5f8f0d50f6c122ed609cec09d35c9b9fb3fff6ea0959134dae424e.
+//
+// Solidity: event Supply(address indexed from, address indexed dst, uint256 amount)
+func (_Comet *CometFilterer) FilterSupply(opts *bind.FilterOpts, from []common.Address, dst []common.Address) (*CometSupplyIterator, error) {
+
+	var fromRule []interface{}
+	for _, fromItem := range from {
+		fromRule = append(fromRule, fromItem)
+	}
+	var dstRule []interface{}
+	for _, dstItem := range dst {
+		dstRule = append(dstRule, dstItem)
+	}
+
+	logs, sub, err := _Comet.contract.FilterLogs(opts, "Supply", fromRule, dstRule)
+	if err != nil {
+		return nil, err
+	}
+	return &CometSupplyIterator{contract: _Comet.contract, event: "Supply", logs: logs, sub: sub}, nil
+}
+
+// WatchSupply is a free log subscription operation binding the contract event 0xd1cf3d156d5f8f0d50f6c122ed609cec09d35c9b9fb3fff6ea0959134dae424e.
+//
+// Solidity: event Supply(address indexed from, address indexed dst, uint256 amount)
+func (_Comet *CometFilterer) WatchSupply(opts *bind.WatchOpts, sink chan<- *CometSupply, from []common.Address, dst []common.Address) (event.Subscription, error) {
+
+	var fromRule []interface{}
+	for _, fromItem := range from {
+		fromRule = append(fromRule, fromItem)
+	}
+	var dstRule []interface{}
+	for _, dstItem := range dst {
+		dstRule = append(dstRule, dstItem)
+	}
+
+	logs, sub, err := _Comet.contract.WatchLogs(opts, "Supply", fromRule, dstRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(CometSupply)
+				if err := _Comet.contract.UnpackLog(event, "Supply", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseSupply is a log parse operation binding the contract event 0xd1cf3d156d5f8f0d50f6c122ed609cec09d35c9b9fb3fff6ea0959134dae424e.
+//
+// Solidity: event Supply(address indexed from, address indexed dst, uint256 amount)
+func (_Comet *CometFilterer) ParseSupply(log types.Log) (*CometSupply, error) {
+	event := new(CometSupply)
+	if err := _Comet.contract.UnpackLog(event, "Supply", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// CometSupplyCollateralIterator is returned from FilterSupplyCollateral and is used to iterate over the raw logs and unpacked data for SupplyCollateral events raised by the Comet contract.
+type CometSupplyCollateralIterator struct {
+	Event *CometSupplyCollateral // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *CometSupplyCollateralIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(CometSupplyCollateral)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(CometSupplyCollateral)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *CometSupplyCollateralIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *CometSupplyCollateralIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// CometSupplyCollateral represents a SupplyCollateral event raised by the Comet contract.
+type CometSupplyCollateral struct {
+	From   common.Address
+	Dst    common.Address
+	Asset  common.Address
+	Amount *big.Int
+	Raw    types.Log // Blockchain specific contextual infos
+}
+
+// FilterSupplyCollateral is a free log retrieval operation binding the contract event 0xfa56f7b24f17183d81894d3ac2ee654e3c26388d17a28dbd9549b8114304e1f4.
+//
+// Solidity: event SupplyCollateral(address indexed from, address indexed dst, address indexed asset, uint256 amount)
+func (_Comet *CometFilterer) FilterSupplyCollateral(opts *bind.FilterOpts, from []common.Address, dst []common.Address, asset []common.Address) (*CometSupplyCollateralIterator, error) {
+
+	var fromRule []interface{}
+	for _, fromItem := range from {
+		fromRule = append(fromRule, fromItem)
+	}
+	var dstRule []interface{}
+	for _, dstItem := range dst {
+		dstRule = append(dstRule, dstItem)
+	}
+	var assetRule []interface{}
+	for _, assetItem := range asset {
+		assetRule = append(assetRule, assetItem)
+	}
+
+	logs, sub, err := _Comet.contract.FilterLogs(opts, "SupplyCollateral", fromRule, dstRule, assetRule)
+	if err != nil {
+		return nil, err
+	}
+	return &CometSupplyCollateralIterator{contract: _Comet.contract, event: "SupplyCollateral", logs: logs, sub: sub}, nil
+}
+
+// WatchSupplyCollateral is a free log subscription operation binding the contract event 0xfa56f7b24f17183d81894d3ac2ee654e3c26388d17a28dbd9549b8114304e1f4.
+//
+// Solidity: event SupplyCollateral(address indexed from, address indexed dst, address indexed asset, uint256 amount)
+func (_Comet *CometFilterer) WatchSupplyCollateral(opts *bind.WatchOpts, sink chan<- *CometSupplyCollateral, from []common.Address, dst []common.Address, asset []common.Address) (event.Subscription, error) {
+
+	var fromRule []interface{}
+	for _, fromItem := range from {
+		fromRule = append(fromRule, fromItem)
+	}
+	var dstRule []interface{}
+	for _, dstItem := range dst {
+		dstRule = append(dstRule, dstItem)
+	}
+	var assetRule []interface{}
+	for _, assetItem := range asset {
+		assetRule = append(assetRule, assetItem)
+	}
+
+	logs, sub, err := _Comet.contract.WatchLogs(opts, "SupplyCollateral", fromRule, dstRule, assetRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(CometSupplyCollateral)
+				if err := _Comet.contract.UnpackLog(event, "SupplyCollateral", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseSupplyCollateral is a log parse operation binding the contract event 0xfa56f7b24f17183d81894d3ac2ee654e3c26388d17a28dbd9549b8114304e1f4.
+//
+// Solidity: event SupplyCollateral(address indexed from, address indexed dst, address indexed asset, uint256 amount)
+func (_Comet *CometFilterer) ParseSupplyCollateral(log types.Log) (*CometSupplyCollateral, error) {
+	event := new(CometSupplyCollateral)
+	if err := _Comet.contract.UnpackLog(event, "SupplyCollateral", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// CometWithdrawIterator is returned from FilterWithdraw and is used to iterate over the raw logs and unpacked data for Withdraw events raised by the Comet contract.
+type CometWithdrawIterator struct {
+	Event *CometWithdraw // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *CometWithdrawIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(CometWithdraw)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(CometWithdraw)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *CometWithdrawIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *CometWithdrawIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// CometWithdraw represents a Withdraw event raised by the Comet contract.
+type CometWithdraw struct {
+	Src    common.Address
+	To     common.Address
+	Amount *big.Int
+	Raw    types.Log // Blockchain specific contextual infos
+}
+
+// FilterWithdraw is a free log retrieval operation binding the contract event 0x9b1bfa7fa9ee420a16e124f794c35ac9f90472acc99140eb2f6447c714cad8eb.
+//
+// Solidity: event Withdraw(address indexed src, address indexed to, uint256 amount)
+func (_Comet *CometFilterer) FilterWithdraw(opts *bind.FilterOpts, src []common.Address, to []common.Address) (*CometWithdrawIterator, error) {
+
+	var srcRule []interface{}
+	for _, srcItem := range src {
+		srcRule = append(srcRule, srcItem)
+	}
+	var toRule []interface{}
+	for _, toItem := range to {
+		toRule = append(toRule, toItem)
+	}
+
+	logs, sub, err := _Comet.contract.FilterLogs(opts, "Withdraw", srcRule, toRule)
+	if err != nil {
+		return nil, err
+	}
+	return &CometWithdrawIterator{contract: _Comet.contract, event: "Withdraw", logs: logs, sub: sub}, nil
+}
+
+// WatchWithdraw is a free log subscription operation binding the contract event 0x9b1bfa7fa9ee420a16e124f794c35ac9f90472acc99140eb2f6447c714cad8eb.
+//
+// Solidity: event Withdraw(address indexed src, address indexed to, uint256 amount)
+func (_Comet *CometFilterer) WatchWithdraw(opts *bind.WatchOpts, sink chan<- *CometWithdraw, src []common.Address, to []common.Address) (event.Subscription, error) {
+
+	var srcRule []interface{}
+	for _, srcItem := range src {
+		srcRule = append(srcRule, srcItem)
+	}
+	var toRule []interface{}
+	for _, toItem := range to {
+		toRule = append(toRule, toItem)
+	}
+
+	logs, sub, err := _Comet.contract.WatchLogs(opts, "Withdraw", srcRule, toRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(CometWithdraw)
+				if err := _Comet.contract.UnpackLog(event, "Withdraw", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseWithdraw is a log parse operation binding the contract event 0x9b1bfa7fa9ee420a16e124f794c35ac9f90472acc99140eb2f6447c714cad8eb.
+//
+// Solidity: event Withdraw(address indexed src, address indexed to, uint256 amount)
+func (_Comet *CometFilterer) ParseWithdraw(log types.Log) (*CometWithdraw, error) {
+	event := new(CometWithdraw)
+	if err := _Comet.contract.UnpackLog(event, "Withdraw", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// CometWithdrawCollateralIterator is returned from FilterWithdrawCollateral and is used to iterate over the raw logs and unpacked data for WithdrawCollateral events raised by the Comet contract.
+type CometWithdrawCollateralIterator struct {
+	Event *CometWithdrawCollateral // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *CometWithdrawCollateralIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(CometWithdrawCollateral)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(CometWithdrawCollateral)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *CometWithdrawCollateralIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *CometWithdrawCollateralIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// CometWithdrawCollateral represents a WithdrawCollateral event raised by the Comet contract.
+type CometWithdrawCollateral struct {
+	Src    common.Address
+	To     common.Address
+	Asset  common.Address
+	Amount *big.Int
+	Raw    types.Log // Blockchain specific contextual infos
+}
+
+// FilterWithdrawCollateral is a free log retrieval operation binding the contract event 0xd6d480d5b3068db003533b170d67561494d72e3bf9fa40a266471351ebba9e16.
+//
+// Solidity: event WithdrawCollateral(address indexed src, address indexed to, address indexed asset, uint256 amount)
+func (_Comet *CometFilterer) FilterWithdrawCollateral(opts *bind.FilterOpts, src []common.Address, to []common.Address, asset []common.Address) (*CometWithdrawCollateralIterator, error) {
+
+	var srcRule []interface{}
+	for _, srcItem := range src {
+		srcRule = append(srcRule, srcItem)
+	}
+	var toRule []interface{}
+	for _, toItem := range to {
+		toRule = append(toRule, toItem)
+	}
+	var assetRule []interface{}
+	for _, assetItem := range asset {
+		assetRule = append(assetRule, assetItem)
+	}
+
+	logs, sub, err := _Comet.contract.FilterLogs(opts, "WithdrawCollateral", srcRule, toRule, assetRule)
+	if err != nil {
+		return nil, err
+	}
+	return &CometWithdrawCollateralIterator{contract: _Comet.contract, event: "WithdrawCollateral", logs: logs, sub: sub}, nil
+}
+
+// WatchWithdrawCollateral is a free log subscription operation binding the contract event 0xd6d480d5b3068db003533b170d67561494d72e3bf9fa40a266471351ebba9e16.
+//
+// Solidity: event WithdrawCollateral(address indexed src, address indexed to, address indexed asset, uint256 amount)
+func (_Comet *CometFilterer) WatchWithdrawCollateral(opts *bind.WatchOpts, sink chan<- *CometWithdrawCollateral, src []common.Address, to []common.Address, asset []common.Address) (event.Subscription, error) {
+
+	var srcRule []interface{}
+	for _, srcItem := range src {
+		srcRule = append(srcRule, srcItem)
+	}
+	var toRule []interface{}
+	for _, toItem := range to {
+		toRule = append(toRule, toItem)
+	}
+	var assetRule []interface{}
+	for _, assetItem := range asset {
+		assetRule = append(assetRule, assetItem)
+	}
+
+	logs, sub, err := _Comet.contract.WatchLogs(opts, "WithdrawCollateral", srcRule, toRule, assetRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(CometWithdrawCollateral)
+				if err := _Comet.contract.UnpackLog(event, "WithdrawCollateral", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseWithdrawCollateral is a log parse operation binding the contract event 0xd6d480d5b3068db003533b170d67561494d72e3bf9fa40a266471351ebba9e16.
+//
+// Solidity: event WithdrawCollateral(address indexed src, address indexed to, address indexed asset, uint256 amount)
+func (_Comet *CometFilterer) ParseWithdrawCollateral(log types.Log) (*CometWithdrawCollateral, error) {
+	event := new(CometWithdrawCollateral)
+	if err := _Comet.contract.UnpackLog(event, "WithdrawCollateral", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
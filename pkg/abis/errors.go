@@ -0,0 +1,479 @@
+package abis
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Enums of the ErrorReporter contracts of Compound and its forks, eg.,
+// Fuse, whose codes are appended to those of Compound.
+
+// ComptrollerError is the Error enum of the comptroller, as returned by
+// getAccountLiquidity and emitted in its Failure events. Codes from
+// ComptrollerSupplierNotWhitelisted on are of Fuse pools.
+type ComptrollerError uint64
+
+const (
+	ComptrollerNoError ComptrollerError = iota
+	ComptrollerUnauthorized
+	ComptrollerComptrollerMismatch
+	ComptrollerInsufficientShortfall
+	ComptrollerInsufficientLiquidity
+	ComptrollerInvalidCloseFactor
+	ComptrollerInvalidCollateralFactor
+	ComptrollerInvalidLiquidationIncentive
+	ComptrollerMarketNotEntered
+	ComptrollerMarketNotListed
+	ComptrollerMarketAlreadyListed
+	ComptrollerMathError
+	ComptrollerNonzeroBorrowBalance
+	ComptrollerPriceError
+	ComptrollerRejection
+	ComptrollerSnapshotError
+	ComptrollerTooManyAssets
+	ComptrollerTooMuchRepay
+	ComptrollerSupplierNotWhitelisted
+	ComptrollerBorrowBelowMin
+	ComptrollerSupplyAboveMax
+	ComptrollerNonzeroTotalSupply
+)
+
+var comptrollerErrorNames = []string{
+	"NO_ERROR",
+	"UNAUTHORIZED",
+	"COMPTROLLER_MISMATCH",
+	"INSUFFICIENT_SHORTFALL",
+	"INSUFFICIENT_LIQUIDITY",
+	"INVALID_CLOSE_FACTOR",
+	"INVALID_COLLATERAL_FACTOR",
+	"INVALID_LIQUIDATION_INCENTIVE",
+	"MARKET_NOT_ENTERED",
+	"MARKET_NOT_LISTED",
+	"MARKET_ALREADY_LISTED",
+	"MATH_ERROR",
+	"NONZERO_BORROW_BALANCE",
+	"PRICE_ERROR",
+	"REJECTION",
+	"SNAPSHOT_ERROR",
+	"TOO_MANY_ASSETS",
+	"TOO_MUCH_REPAY",
+	"SUPPLIER_NOT_WHITELISTED",
+	"BORROW_BELOW_MIN",
+	"SUPPLY_ABOVE_MAX",
+	"NONZERO_TOTAL_SUPPLY",
+}
+
+func (e ComptrollerError) String() string {
+	return enumName(comptrollerErrorNames, uint64(e))
+}
+
+// ComptrollerFailureInfo is the FailureInfo enum of the comptroller, the
+// function that failed.
+type ComptrollerFailureInfo uint64
+
+const (
+	ComptrollerInfoAcceptAdminPendingAdminCheck ComptrollerFailureInfo = iota
+	ComptrollerInfoAcceptPendingImplementationAddressCheck
+	ComptrollerInfoExitMarketBalanceOwed
+	ComptrollerInfoExitMarketRejection
+	ComptrollerInfoSetCloseFactorOwnerCheck
+	ComptrollerInfoSetCloseFactorValidation
+	ComptrollerInfoSetCollateralFactorOwnerCheck
+	ComptrollerInfoSetCollateralFactorNoExists
+	ComptrollerInfoSetCollateralFactorValidation
+	ComptrollerInfoSetCollateralFactorWithoutPrice
+	ComptrollerInfoSetImplementationOwnerCheck
+	ComptrollerInfoSetLiquidationIncentiveOwnerCheck
+	ComptrollerInfoSetLiquidationIncentiveValidation
+	ComptrollerInfoSetMaxAssetsOwnerCheck
+	ComptrollerInfoSetPendingAdminOwnerCheck
+	ComptrollerInfoSetPendingImplementationOwnerCheck
+	ComptrollerInfoSetPriceOracleOwnerCheck
+	ComptrollerInfoSupportMarketExists
+	ComptrollerInfoSupportMarketOwnerCheck
+	ComptrollerInfoSetPauseGuardianOwnerCheck
+)
+
+var comptrollerFailureInfoNames = []string{
+	"ACCEPT_ADMIN_PENDING_ADMIN_CHECK",
+	"ACCEPT_PENDING_IMPLEMENTATION_ADDRESS_CHECK",
+	"EXIT_MARKET_BALANCE_OWED",
+	"EXIT_MARKET_REJECTION",
+	"SET_CLOSE_FACTOR_OWNER_CHECK",
+	"SET_CLOSE_FACTOR_VALIDATION",
+	"SET_COLLATERAL_FACTOR_OWNER_CHECK",
+	"SET_COLLATERAL_FACTOR_NO_EXISTS",
+	"SET_COLLATERAL_FACTOR_VALIDATION",
+	"SET_COLLATERAL_FACTOR_WITHOUT_PRICE",
+	"SET_IMPLEMENTATION_OWNER_CHECK",
+	"SET_LIQUIDATION_INCENTIVE_OWNER_CHECK",
+	"SET_LIQUIDATION_INCENTIVE_VALIDATION",
+	"SET_MAX_ASSETS_OWNER_CHECK",
+	"SET_PENDING_ADMIN_OWNER_CHECK",
+	"SET_PENDING_IMPLEMENTATION_OWNER_CHECK",
+	"SET_PRICE_ORACLE_OWNER_CHECK",
+	"SUPPORT_MARKET_EXISTS",
+	"SUPPORT_MARKET_OWNER_CHECK",
+	"SET_PAUSE_GUARDIAN_OWNER_CHECK",
+}
+
+func (e ComptrollerFailureInfo) String() string {
+	return enumName(comptrollerFailureInfoNames, uint64(e))
+}
+
+// TokenError is the Error enum of the markets, as emitted in their
+// Failure events. TokenUtilizationAboveMax is of Fuse pools.
+type TokenError uint64
+
+const (
+	TokenNoError TokenError = iota
+	TokenUnauthorized
+	TokenBadInput
+	TokenComptrollerRejection
+	TokenComptrollerCalculationError
+	TokenInterestRateModelError
+	TokenInvalidAccountPair
+	TokenInvalidCloseAmountRequested
+	TokenInvalidCollateralFactor
+	TokenMathError
+	TokenMarketNotFresh
+	TokenMarketNotListed
+	TokenTokenInsufficientAllowance
+	TokenTokenInsufficientBalance
+	TokenTokenInsufficientCash
+	TokenTokenTransferInFailed
+	TokenTokenTransferOutFailed
+	TokenUtilizationAboveMax
+)
+
+var tokenErrorNames = []string{
+	"NO_ERROR",
+	"UNAUTHORIZED",
+	"BAD_INPUT",
+	"COMPTROLLER_REJECTION",
+	"COMPTROLLER_CALCULATION_ERROR",
+	"INTEREST_RATE_MODEL_ERROR",
+	"INVALID_ACCOUNT_PAIR",
+	"INVALID_CLOSE_AMOUNT_REQUESTED",
+	"INVALID_COLLATERAL_FACTOR",
+	"MATH_ERROR",
+	"MARKET_NOT_FRESH",
+	"MARKET_NOT_LISTED",
+	"TOKEN_INSUFFICIENT_ALLOWANCE",
+	"TOKEN_INSUFFICIENT_BALANCE",
+	"TOKEN_INSUFFICIENT_CASH",
+	"TOKEN_TRANSFER_IN_FAILED",
+	"TOKEN_TRANSFER_OUT_FAILED",
+	"UTILIZATION_ABOVE_MAX",
+}
+
+func (e TokenError) String() string {
+	return enumName(tokenErrorNames, uint64(e))
+}
+
+// TokenFailureInfo is the FailureInfo enum of the markets, the step of
+// the function that failed.
+type TokenFailureInfo uint64
+
+const (
+	TokenInfoAcceptAdminPendingAdminCheck TokenFailureInfo = iota
+	TokenInfoAccrueInterestAccumulatedInterestCalculationFailed
+	TokenInfoAccrueInterestBorrowRateCalculationFailed
+	TokenInfoAccrueInterestNewBorrowIndexCalculationFailed
+	TokenInfoAccrueInterestNewTotalBorrowsCalculationFailed
+	TokenInfoAccrueInterestNewTotalReservesCalculationFailed
+	TokenInfoAccrueInterestSimpleInterestFactorCalculationFailed
+	TokenInfoBorrowAccumulatedBalanceCalculationFailed
+	TokenInfoBorrowAccrueInterestFailed
+	TokenInfoBorrowCashNotAvailable
+	TokenInfoBorrowFreshnessCheck
+	TokenInfoBorrowNewTotalBalanceCalculationFailed
+	TokenInfoBorrowNewAccountBorrowBalanceCalculationFailed
+	TokenInfoBorrowMarketNotListed
+	TokenInfoBorrowComptrollerRejection
+	TokenInfoLiquidateAccrueBorrowInterestFailed
+	TokenInfoLiquidateAccrueCollateralInterestFailed
+	TokenInfoLiquidateCollateralFreshnessCheck
+	TokenInfoLiquidateComptrollerRejection
+	TokenInfoLiquidateComptrollerSeizeAmountCalculationFailed
+	TokenInfoLiquidateCloseAmountIsUintMax
+	TokenInfoLiquidateCloseAmountIsZero
+	TokenInfoLiquidateFreshnessCheck
+	TokenInfoLiquidateLiquidatorIsBorrower
+	TokenInfoLiquidateRepayBorrowFreshFailed
+	TokenInfoLiquidateSeizeBalanceIncrementFailed
+	TokenInfoLiquidateSeizeBalanceDecrementFailed
+	TokenInfoLiquidateSeizeComptrollerRejection
+	TokenInfoLiquidateSeizeLiquidatorIsBorrower
+	TokenInfoLiquidateSeizeTooMuch
+	TokenInfoMintAccrueInterestFailed
+	TokenInfoMintComptrollerRejection
+	TokenInfoMintExchangeCalculationFailed
+	TokenInfoMintExchangeRateReadFailed
+	TokenInfoMintFreshnessCheck
+	TokenInfoMintNewAccountBalanceCalculationFailed
+	TokenInfoMintNewTotalSupplyCalculationFailed
+	TokenInfoMintTransferInFailed
+	TokenInfoMintTransferInNotPossible
+	TokenInfoRedeemAccrueInterestFailed
+	TokenInfoRedeemComptrollerRejection
+	TokenInfoRedeemExchangeTokensCalculationFailed
+	TokenInfoRedeemExchangeAmountCalculationFailed
+	TokenInfoRedeemExchangeRateReadFailed
+	TokenInfoRedeemFreshnessCheck
+	TokenInfoRedeemNewAccountBalanceCalculationFailed
+	TokenInfoRedeemNewTotalSupplyCalculationFailed
+	TokenInfoRedeemTransferOutNotPossible
+	TokenInfoReduceReservesAccrueInterestFailed
+	TokenInfoReduceReservesAdminCheck
+	TokenInfoReduceReservesCashNotAvailable
+	TokenInfoReduceReservesFreshCheck
+	TokenInfoReduceReservesValidation
+	TokenInfoRepayBehalfAccrueInterestFailed
+	TokenInfoRepayBorrowAccrueInterestFailed
+	TokenInfoRepayBorrowAccumulatedBalanceCalculationFailed
+	TokenInfoRepayBorrowComptrollerRejection
+	TokenInfoRepayBorrowFreshnessCheck
+	TokenInfoRepayBorrowNewAccountBorrowBalanceCalculationFailed
+	TokenInfoRepayBorrowNewTotalBalanceCalculationFailed
+	TokenInfoRepayBorrowTransferInNotPossible
+	TokenInfoSetCollateralFactorOwnerCheck
+	TokenInfoSetCollateralFactorValidation
+	TokenInfoSetComptrollerOwnerCheck
+	TokenInfoSetInterestRateModelAccrueInterestFailed
+	TokenInfoSetInterestRateModelFreshCheck
+	TokenInfoSetInterestRateModelOwnerCheck
+	TokenInfoSetMaxAssetsOwnerCheck
+	TokenInfoSetOriginationFeeOwnerCheck
+	TokenInfoSetPendingAdminOwnerCheck
+	TokenInfoSetReserveFactorAccrueInterestFailed
+	TokenInfoSetReserveFactorAdminCheck
+	TokenInfoSetReserveFactorFreshCheck
+	TokenInfoSetReserveFactorBoundsCheck
+	TokenInfoTransferComptrollerRejection
+	TokenInfoTransferNotAllowed
+	TokenInfoTransferNotEnough
+	TokenInfoTransferTooMuch
+	TokenInfoAddReservesAccrueInterestFailed
+	TokenInfoAddReservesFreshCheck
+	TokenInfoAddReservesTransferInNotPossible
+)
+
+var tokenFailureInfoNames = []string{
+	"ACCEPT_ADMIN_PENDING_ADMIN_CHECK",
+	"ACCRUE_INTEREST_ACCUMULATED_INTEREST_CALCULATION_FAILED",
+	"ACCRUE_INTEREST_BORROW_RATE_CALCULATION_FAILED",
+	"ACCRUE_INTEREST_NEW_BORROW_INDEX_CALCULATION_FAILED",
+	"ACCRUE_INTEREST_NEW_TOTAL_BORROWS_CALCULATION_FAILED",
+	"ACCRUE_INTEREST_NEW_TOTAL_RESERVES_CALCULATION_FAILED",
+	"ACCRUE_INTEREST_SIMPLE_INTEREST_FACTOR_CALCULATION_FAILED",
+	"BORROW_ACCUMULATED_BALANCE_CALCULATION_FAILED",
+	"BORROW_ACCRUE_INTEREST_FAILED",
+	"BORROW_CASH_NOT_AVAILABLE",
+	"BORROW_FRESHNESS_CHECK",
+	"BORROW_NEW_TOTAL_BALANCE_CALCULATION_FAILED",
+	"BORROW_NEW_ACCOUNT_BORROW_BALANCE_CALCULATION_FAILED",
+	"BORROW_MARKET_NOT_LISTED",
+	"BORROW_COMPTROLLER_REJECTION",
+	"LIQUIDATE_ACCRUE_BORROW_INTEREST_FAILED",
+	"LIQUIDATE_ACCRUE_COLLATERAL_INTEREST_FAILED",
+	"LIQUIDATE_COLLATERAL_FRESHNESS_CHECK",
+	"LIQUIDATE_COMPTROLLER_REJECTION",
+	"LIQUIDATE_COMPTROLLER_SEIZE_AMOUNT_CALCULATION_FAILED",
+	"LIQUIDATE_CLOSE_AMOUNT_IS_UINT_MAX",
+	"LIQUIDATE_CLOSE_AMOUNT_IS_ZERO",
+	"LIQUIDATE_FRESHNESS_CHECK",
+	"LIQUIDATE_LIQUIDATOR_IS_BORROWER",
+	"LIQUIDATE_REPAY_BORROW_FRESH_FAILED",
+	"LIQUIDATE_SEIZE_BALANCE_INCREMENT_FAILED",
+	"LIQUIDATE_SEIZE_BALANCE_DECREMENT_FAILED",
+	"LIQUIDATE_SEIZE_COMPTROLLER_REJECTION",
+	"LIQUIDATE_SEIZE_LIQUIDATOR_IS_BORROWER",
+	"LIQUIDATE_SEIZE_TOO_MUCH",
+	"MINT_ACCRUE_INTEREST_FAILED",
+	"MINT_COMPTROLLER_REJECTION",
+	"MINT_EXCHANGE_CALCULATION_FAILED",
+	"MINT_EXCHANGE_RATE_READ_FAILED",
+	"MINT_FRESHNESS_CHECK",
+	"MINT_NEW_ACCOUNT_BALANCE_CALCULATION_FAILED",
+	"MINT_NEW_TOTAL_SUPPLY_CALCULATION_FAILED",
+	"MINT_TRANSFER_IN_FAILED",
+	"MINT_TRANSFER_IN_NOT_POSSIBLE",
+	"REDEEM_ACCRUE_INTEREST_FAILED",
+	"REDEEM_COMPTROLLER_REJECTION",
+	"REDEEM_EXCHANGE_TOKENS_CALCULATION_FAILED",
+	"REDEEM_EXCHANGE_AMOUNT_CALCULATION_FAILED",
+	"REDEEM_EXCHANGE_RATE_READ_FAILED",
+	"REDEEM_FRESHNESS_CHECK",
+	"REDEEM_NEW_ACCOUNT_BALANCE_CALCULATION_FAILED",
+	"REDEEM_NEW_TOTAL_SUPPLY_CALCULATION_FAILED",
+	"REDEEM_TRANSFER_OUT_NOT_POSSIBLE",
+	"REDUCE_RESERVES_ACCRUE_INTEREST_FAILED",
+	"REDUCE_RESERVES_ADMIN_CHECK",
+	"REDUCE_RESERVES_CASH_NOT_AVAILABLE",
+	"REDUCE_RESERVES_FRESH_CHECK",
+	"REDUCE_RESERVES_VALIDATION",
+	"REPAY_BEHALF_ACCRUE_INTEREST_FAILED",
+	"REPAY_BORROW_ACCRUE_INTEREST_FAILED",
+	"REPAY_BORROW_ACCUMULATED_BALANCE_CALCULATION_FAILED",
+	"REPAY_BORROW_COMPTROLLER_REJECTION",
+	"REPAY_BORROW_FRESHNESS_CHECK",
+	"REPAY_BORROW_NEW_ACCOUNT_BORROW_BALANCE_CALCULATION_FAILED",
+	"REPAY_BORROW_NEW_TOTAL_BALANCE_CALCULATION_FAILED",
+	"REPAY_BORROW_TRANSFER_IN_NOT_POSSIBLE",
+	"SET_COLLATERAL_FACTOR_OWNER_CHECK",
+	"SET_COLLATERAL_FACTOR_VALIDATION",
+	"SET_COMPTROLLER_OWNER_CHECK",
+	"SET_INTEREST_RATE_MODEL_ACCRUE_INTEREST_FAILED",
+	"SET_INTEREST_RATE_MODEL_FRESH_CHECK",
+	"SET_INTEREST_RATE_MODEL_OWNER_CHECK",
+	"SET_MAX_ASSETS_OWNER_CHECK",
+	"SET_ORIGINATION_FEE_OWNER_CHECK",
+	"SET_PENDING_ADMIN_OWNER_CHECK",
+	"SET_RESERVE_FACTOR_ACCRUE_INTEREST_FAILED",
+	"SET_RESERVE_FACTOR_ADMIN_CHECK",
+	"SET_RESERVE_FACTOR_FRESH_CHECK",
+	"SET_RESERVE_FACTOR_BOUNDS_CHECK",
+	"TRANSFER_COMPTROLLER_REJECTION",
+	"TRANSFER_NOT_ALLOWED",
+	"TRANSFER_NOT_ENOUGH",
+	"TRANSFER_TOO_MUCH",
+	"ADD_RESERVES_ACCRUE_INTEREST_FAILED",
+	"ADD_RESERVES_FRESH_CHECK",
+	"ADD_RESERVES_TRANSFER_IN_NOT_POSSIBLE",
+}
+
+func (e TokenFailureInfo) String() string {
+	return enumName(tokenFailureInfoNames, uint64(e))
+}
+
+// MathError is the error of the Compound math library, the detail
+// of a MATH_ERROR.
+type MathError uint64
+
+const (
+	MathNoError MathError = iota
+	MathDivisionByZero
+	MathIntegerOverflow
+	MathIntegerUnderflow
+)
+
+var mathErrorNames = []string{
+	"NO_ERROR",
+	"DIVISION_BY_ZERO",
+	"INTEGER_OVERFLOW",
+	"INTEGER_UNDERFLOW",
+}
+
+func (e MathError) String() string {
+	return enumName(mathErrorNames, uint64(e))
+}
+
+// Reporter is the contract whose enums a failure is of.
+type Reporter int
+
+const (
+	ComptrollerReporter Reporter = iota
+	TokenReporter
+)
+
+// Failure is a failure of a Compound contract, as emitted in a Failure
+// event or returned as an error code, its error, info and detail
+// rendered by name.
+type Failure struct {
+	Reporter Reporter
+	Error    *big.Int
+	// Nil if the failure is an error code only
+	Info   *big.Int
+	Detail *big.Int
+}
+
+// Decode returns the failure of error, info and detail reported by r.
+// info and detail may be nil for a bare error code.
+func (r Reporter) Decode(err, info, detail *big.Int) Failure {
+	return Failure{Reporter: r, Error: err, Info: info, Detail: detail}
+}
+
+// ErrorName returns the name of the error; codes outside the enum are
+// rendered as unknown(n).
+func (f Failure) ErrorName() string {
+	code, ok := enumCode(f.Error)
+	if !ok {
+		return unknownCode(f.Error)
+	}
+	if f.Reporter == TokenReporter {
+		return TokenError(code).String()
+	}
+	return ComptrollerError(code).String()
+}
+
+// InfoName returns the name of the failed function, empty if unset.
+func (f Failure) InfoName() string {
+	if f.Info == nil {
+		return ""
+	}
+	code, ok := enumCode(f.Info)
+	if !ok {
+		return unknownCode(f.Info)
+	}
+	if f.Reporter == TokenReporter {
+		return TokenFailureInfo(code).String()
+	}
+	return ComptrollerFailureInfo(code).String()
+}
+
+// DetailName returns the detail, by name if it is the comptroller error
+// of a rejection or the math error of a MATH_ERROR, or as a number
+// otherwise; empty if unset.
+func (f Failure) DetailName() string {
+	if f.Detail == nil {
+		return ""
+	}
+	errCode, _ := enumCode(f.Error)
+	code, ok := enumCode(f.Detail)
+	switch {
+	case !ok:
+		return f.Detail.String()
+	case f.Reporter == TokenReporter && TokenError(errCode) == TokenComptrollerRejection:
+		return ComptrollerError(code).String()
+	case f.Reporter == TokenReporter && TokenError(errCode) == TokenMathError,
+		f.Reporter == ComptrollerReporter && ComptrollerError(errCode) == ComptrollerMathError:
+		return MathError(code).String()
+	}
+	return f.Detail.String()
+}
+
+// String renders the failure as ERROR, or ERROR(INFO, DETAIL) if it has
+// an info.
+func (f Failure) String() string {
+	if f.Info == nil {
+		return f.ErrorName()
+	}
+	if f.Detail == nil {
+		return fmt.Sprintf("%s(%s)", f.ErrorName(), f.InfoName())
+	}
+	return fmt.Sprintf("%s(%s, %s)", f.ErrorName(), f.InfoName(), f.DetailName())
+}
+
+// MarshalText renders the failure as String, so it is reported by name.
+func (f Failure) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func enumCode(n *big.Int) (uint64, bool) {
+	if n == nil || n.Sign() < 0 || !n.IsUint64() {
+		return 0, false
+	}
+	return n.Uint64(), true
+}
+
+func enumName(names []string, code uint64) string {
+	if code >= uint64(len(names)) {
+		return fmt.Sprintf("unknown(%d)", code)
+	}
+	return names[code]
+}
+
+func unknownCode(n *big.Int) string {
+	return fmt.Sprintf("unknown(%v)", n)
+}
@@ -0,0 +1,510 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package abis
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// FusePoolDirectoryFusePool is an auto generated low-level Go binding around an user-defined struct.
+type FusePoolDirectoryFusePool struct {
+	Name            string
+	Creator         common.Address
+	Comptroller     common.Address
+	BlockPosted     *big.Int
+	TimestampPosted *big.Int
+}
+
+// FusePoolDirectoryMetaData contains all meta data concerning the FusePoolDirectory contract.
+var FusePoolDirectoryMetaData = &bind.MetaData{
+	ABI: "[{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"index\",\"type\":\"uint256\"},{\"indexed\":false,\"components\":[{\"internalType\":\"string\",\"name\":\"name\",\"type\":\"string\"},{\"internalType\":\"address\",\"name\":\"creator\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"comptroller\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"blockPosted\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"timestampPosted\",\"type\":\"uint256\"}],\"internalType\":\"structFusePoolDirectory.FusePool\",\"name\":\"pool\",\"type\":\"tuple\"}],\"name\":\"PoolRegistered\",\"type\":\"event\"},{\"inputs\":[],\"name\":\"getAllPools\",\"outputs\":[{\"components\":[{\"internalType\":\"string\",\"name\":\"name\",\"type\":\"string\"},{\"internalType\":\"address\",\"name\":\"creator\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"comptroller\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"blockPosted\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"timestampPosted\",\"type\":\"uint256\"}],\"internalType\":\"structFusePoolDirectory.FusePool[]\",\"name\":\"\",\"type\":\"tuple[]\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"account\",\"type\":\"address\"}],\"name\":\"getPoolsByAccount\",\"outputs\":[{\"internalType\":\"uint256[]\",\"name\":\"\",\"type\":\"uint256[]\"},{\"components\":[{\"internalType\":\"string\",\"name\":\"name\",\"type\":\"string\"},{\"internalType\":\"address\",\"name\":\"creator\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"comptroller\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"blockPosted\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"timestampPosted\",\"type\":\"uint256\"}],\"internalType\":\"structFusePoolDirectory.FusePool[]\",\"name\":\"\",\"type\":\"tuple[]\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getPublicPools\",\"outputs\":[{\"internalType\":\"uint256[]\",\"name\":\"\",\"type\":\"uint256[]\"},{\"components\":[{\"internalType\":\"string\",\"name\":\"name\",\"type\":\"string\"},{\"internalType\":\"address\",\"name\":\"creator\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"comptroller\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"blockPosted\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"timestampPosted\",\"type\":\"uint256\"}],\"internalType\":\"structFusePoolDirectory.FusePool[]\",\"name\":\"\",\"type\":\"tuple[]\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"name\":\"poolExists\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"pools\",\"outputs\":[{\"internalType\":\"string\",\"name\":\"name\",\"type\":\"string\"},{\"internalType\":\"address\",\"name\":\"creator\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"comptroller\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"blockPosted\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"timestampPosted\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]",
+}
+
+// FusePoolDirectoryABI is the input ABI used to generate the binding from.
+// Deprecated: Use FusePoolDirectoryMetaData.ABI instead.
+var FusePoolDirectoryABI = FusePoolDirectoryMetaData.ABI
+
+// FusePoolDirectory is an auto generated Go binding around an Ethereum contract.
+type FusePoolDirectory struct {
+	FusePoolDirectoryCaller     // Read-only binding to the contract
+	FusePoolDirectoryTransactor // Write-only binding to the contract
+	FusePoolDirectoryFilterer   // Log filterer for contract events
+}
+
+// FusePoolDirectoryCaller is an auto generated read-only Go binding around an Ethereum contract.
+type FusePoolDirectoryCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// FusePoolDirectoryTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type FusePoolDirectoryTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// FusePoolDirectoryFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type FusePoolDirectoryFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// FusePoolDirectorySession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type FusePoolDirectorySession struct {
+	Contract     *FusePoolDirectory // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts      // Call options to use throughout this session
+	TransactOpts bind.TransactOpts  // Transaction auth options to use throughout this session
+}
+
+// FusePoolDirectoryCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type FusePoolDirectoryCallerSession struct {
+	Contract *FusePoolDirectoryCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts            // Call options to use throughout this session
+}
+
+// FusePoolDirectoryTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type FusePoolDirectoryTransactorSession struct {
+	Contract     *FusePoolDirectoryTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts            // Transaction auth options to use throughout this session
+}
+
+// FusePoolDirectoryRaw is an auto generated low-level Go binding around an Ethereum contract.
+type FusePoolDirectoryRaw struct {
+	Contract *FusePoolDirectory // Generic contract binding to access the raw methods on
+}
+
+// FusePoolDirectoryCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type FusePoolDirectoryCallerRaw struct {
+	Contract *FusePoolDirectoryCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// FusePoolDirectoryTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type FusePoolDirectoryTransactorRaw struct {
+	Contract *FusePoolDirectoryTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewFusePoolDirectory creates a new instance of FusePoolDirectory, bound to a specific deployed contract.
+func NewFusePoolDirectory(address common.Address, backend bind.ContractBackend) (*FusePoolDirectory, error) {
+	contract, err := bindFusePoolDirectory(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &FusePoolDirectory{FusePoolDirectoryCaller: FusePoolDirectoryCaller{contract: contract}, FusePoolDirectoryTransactor: FusePoolDirectoryTransactor{contract: contract}, FusePoolDirectoryFilterer: FusePoolDirectoryFilterer{contract: contract}}, nil
+}
+
+// NewFusePoolDirectoryCaller creates a new read-only instance of FusePoolDirectory, bound to a specific deployed contract.
+func NewFusePoolDirectoryCaller(address common.Address, caller bind.ContractCaller) (*FusePoolDirectoryCaller, error) {
+	contract, err := bindFusePoolDirectory(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &FusePoolDirectoryCaller{contract: contract}, nil
+}
+
+// NewFusePoolDirectoryTransactor creates a new write-only instance of FusePoolDirectory, bound to a specific deployed contract.
+func NewFusePoolDirectoryTransactor(address common.Address, transactor bind.ContractTransactor) (*FusePoolDirectoryTransactor, error) {
+	contract, err := bindFusePoolDirectory(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &FusePoolDirectoryTransactor{contract: contract}, nil
+}
+
+// NewFusePoolDirectoryFilterer creates a new log filterer instance of FusePoolDirectory, bound to a specific deployed contract.
+func NewFusePoolDirectoryFilterer(address common.Address, filterer bind.ContractFilterer) (*FusePoolDirectoryFilterer, error) {
+	contract, err := bindFusePoolDirectory(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &FusePoolDirectoryFilterer{contract: contract}, nil
+}
+
+// bindFusePoolDirectory binds a generic wrapper to an already deployed contract.
+func bindFusePoolDirectory(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(FusePoolDirectoryABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_FusePoolDirectory *FusePoolDirectoryRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _FusePoolDirectory.Contract.FusePoolDirectoryCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_FusePoolDirectory *FusePoolDirectoryRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _FusePoolDirectory.Contract.FusePoolDirectoryTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_FusePoolDirectory *FusePoolDirectoryRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _FusePoolDirectory.Contract.FusePoolDirectoryTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_FusePoolDirectory *FusePoolDirectoryCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _FusePoolDirectory.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_FusePoolDirectory *FusePoolDirectoryTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _FusePoolDirectory.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_FusePoolDirectory *FusePoolDirectoryTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _FusePoolDirectory.Contract.contract.Transact(opts, method, params...)
+}
+
+// GetAllPools is a free data retrieval call binding the contract method 0xd88ff1f4.
+//
+// Solidity: function getAllPools() view returns((string,address,address,uint256,uint256)[])
+func (_FusePoolDirectory *FusePoolDirectoryCaller) GetAllPools(opts *bind.CallOpts) ([]FusePoolDirectoryFusePool, error) {
+	var out []interface{}
+	err := _FusePoolDirectory.contract.Call(opts, &out, "getAllPools")
+
+	if err != nil {
+		return *new([]FusePoolDirectoryFusePool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([]FusePoolDirectoryFusePool)).(*[]FusePoolDirectoryFusePool)
+
+	return out0, err
+
+}
+
+// GetAllPools is a free data retrieval call binding the contract method 0xd88ff1f4.
+//
+// Solidity: function getAllPools() view returns((string,address,address,uint256,uint256)[])
+func (_FusePoolDirectory *FusePoolDirectorySession) GetAllPools() ([]FusePoolDirectoryFusePool, error) {
+	return _FusePoolDirectory.Contract.GetAllPools(&_FusePoolDirectory.CallOpts)
+}
+
+// GetAllPools is a free data retrieval call binding the contract method 0xd88ff1f4.
+//
+// Solidity: function getAllPools() view returns((string,address,address,uint256,uint256)[])
+func (_FusePoolDirectory *FusePoolDirectoryCallerSession) GetAllPools() ([]FusePoolDirectoryFusePool, error) {
+	return _FusePoolDirectory.Contract.GetAllPools(&_FusePoolDirectory.CallOpts)
+}
+
+// GetPoolsByAccount is a free data retrieval call binding the contract method 0xa3ed91c6.
+//
+// Solidity: function getPoolsByAccount(address account) view returns(uint256[], (string,address,address,uint256,uint256)[])
+func (_FusePoolDirectory *FusePoolDirectoryCaller) GetPoolsByAccount(opts *bind.CallOpts, account common.Address) ([]*big.Int, []FusePoolDirectoryFusePool, error) {
+	var out []interface{}
+	err := _FusePoolDirectory.contract.Call(opts, &out, "getPoolsByAccount", account)
+
+	if err != nil {
+		return *new([]*big.Int), *new([]FusePoolDirectoryFusePool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([]*big.Int)).(*[]*big.Int)
+	out1 := *abi.ConvertType(out[1], new([]FusePoolDirectoryFusePool)).(*[]FusePoolDirectoryFusePool)
+
+	return out0, out1, err
+
+}
+
+// GetPoolsByAccount is a free data retrieval call binding the contract method 0xa3ed91c6.
+//
+// Solidity: function getPoolsByAccount(address account) view returns(uint256[], (string,address,address,uint256,uint256)[])
+func (_FusePoolDirectory *FusePoolDirectorySession) GetPoolsByAccount(account common.Address) ([]*big.Int, []FusePoolDirectoryFusePool, error) {
+	return _FusePoolDirectory.Contract.GetPoolsByAccount(&_FusePoolDirectory.CallOpts, account)
+}
+
+// GetPoolsByAccount is a free data retrieval call binding the contract method 0xa3ed91c6.
+//
+// Solidity: function getPoolsByAccount(address account) view returns(uint256[], (string,address,address,uint256,uint256)[])
+func (_FusePoolDirectory *FusePoolDirectoryCallerSession) GetPoolsByAccount(account common.Address) ([]*big.Int, []FusePoolDirectoryFusePool, error) {
+	return _FusePoolDirectory.Contract.GetPoolsByAccount(&_FusePoolDirectory.CallOpts, account)
+}
+
+// GetPublicPools is a free data retrieval call binding the contract method 0x4ae26ea1.
+//
+// Solidity: function getPublicPools() view returns(uint256[], (string,address,address,uint256,uint256)[])
+func (_FusePoolDirectory *FusePoolDirectoryCaller) GetPublicPools(opts *bind.CallOpts) ([]*big.Int, []FusePoolDirectoryFusePool, error) {
+	var out []interface{}
+	err := _FusePoolDirectory.contract.Call(opts, &out, "getPublicPools")
+
+	if err != nil {
+		return *new([]*big.Int), *new([]FusePoolDirectoryFusePool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([]*big.Int)).(*[]*big.Int)
+	out1 := *abi.ConvertType(out[1], new([]FusePoolDirectoryFusePool)).(*[]FusePoolDirectoryFusePool)
+
+	return out0, out1, err
+
+}
+
+// GetPublicPools is a free data retrieval call binding the contract method 0x4ae26ea1.
+//
+// Solidity: function getPublicPools() view returns(uint256[], (string,address,address,uint256,uint256)[])
+func (_FusePoolDirectory *FusePoolDirectorySession) GetPublicPools() ([]*big.Int, []FusePoolDirectoryFusePool, error) {
+	return _FusePoolDirectory.Contract.GetPublicPools(&_FusePoolDirectory.CallOpts)
+}
+
+// GetPublicPools is a free data retrieval call binding the contract method 0x4ae26ea1.
+//
+// Solidity: function getPublicPools() view returns(uint256[], (string,address,address,uint256,uint256)[])
+func (_FusePoolDirectory *FusePoolDirectoryCallerSession) GetPublicPools() ([]*big.Int, []FusePoolDirectoryFusePool, error) {
+	return _FusePoolDirectory.Contract.GetPublicPools(&_FusePoolDirectory.CallOpts)
+}
+
+// PoolExists is a free data retrieval call binding the contract method 0x1e1c6a07.
+//
+// Solidity: function poolExists(address ) view returns(bool)
+func (_FusePoolDirectory *FusePoolDirectoryCaller) PoolExists(opts *bind.CallOpts, arg0 common.Address) (bool, error) {
+	var out []interface{}
+	err := _FusePoolDirectory.contract.Call(opts, &out, "poolExists", arg0)
+
+	if err != nil {
+		return *new(bool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(bool)).(*bool)
+
+	return out0, err
+
+}
+
+// PoolExists is a free data retrieval call binding the contract method 0x1e1c6a07.
+//
+// Solidity: function poolExists(address ) view returns(bool)
+func (_FusePoolDirectory *FusePoolDirectorySession) PoolExists(arg0 common.Address) (bool, error) {
+	return _FusePoolDirectory.Contract.PoolExists(&_FusePoolDirectory.CallOpts, arg0)
+}
+
+// PoolExists is a free data retrieval call binding the contract method 0x1e1c6a07.
+//
+// Solidity: function poolExists(address ) view returns(bool)
+func (_FusePoolDirectory *FusePoolDirectoryCallerSession) PoolExists(arg0 common.Address) (bool, error) {
+	return _FusePoolDirectory.Contract.PoolExists(&_FusePoolDirectory.CallOpts, arg0)
+}
+
+// Pools is a free data retrieval call binding the contract method 0xac4afa38.
+//
+// Solidity: function pools(uint256 ) view returns(string name, address creator, address comptroller, uint256 blockPosted, uint256 timestampPosted)
+func (_FusePoolDirectory *FusePoolDirectoryCaller) Pools(opts *bind.CallOpts, arg0 *big.Int) (struct {
+	Name            string
+	Creator         common.Address
+	Comptroller     common.Address
+	BlockPosted     *big.Int
+	TimestampPosted *big.Int
+}, error) {
+	var out []interface{}
+	err := _FusePoolDirectory.contract.Call(opts, &out, "pools", arg0)
+
+	outstruct := new(struct {
+		Name            string
+		Creator         common.Address
+		Comptroller     common.Address
+		BlockPosted     *big.Int
+		TimestampPosted *big.Int
+	})
+	if err != nil {
+		return *outstruct, err
+	}
+
+	outstruct.Name = *abi.ConvertType(out[0], new(string)).(*string)
+	outstruct.Creator = *abi.ConvertType(out[1], new(common.Address)).(*common.Address)
+	outstruct.Comptroller = *abi.ConvertType(out[2], new(common.Address)).(*common.Address)
+	outstruct.BlockPosted = *abi.ConvertType(out[3], new(*big.Int)).(**big.Int)
+	outstruct.TimestampPosted = *abi.ConvertType(out[4], new(*big.Int)).(**big.Int)
+
+	return *outstruct, err
+
+}
+
+// Pools is a free data retrieval call binding the contract method 0xac4afa38.
+//
+// Solidity: function pools(uint256 ) view returns(string name, address creator, address comptroller, uint256 blockPosted, uint256 timestampPosted)
+func (_FusePoolDirectory *FusePoolDirectorySession) Pools(arg0 *big.Int) (struct {
+	Name            string
+	Creator         common.Address
+	Comptroller     common.Address
+	BlockPosted     *big.Int
+	TimestampPosted *big.Int
+}, error) {
+	return _FusePoolDirectory.Contract.Pools(&_FusePoolDirectory.CallOpts, arg0)
+}
+
+// Pools is a free data retrieval call binding the contract method 0xac4afa38.
+//
+// Solidity: function pools(uint256 ) view returns(string name, address creator, address comptroller, uint256 blockPosted, uint256 timestampPosted)
+func (_FusePoolDirectory *FusePoolDirectoryCallerSession) Pools(arg0 *big.Int) (struct {
+	Name            string
+	Creator         common.Address
+	Comptroller     common.Address
+	BlockPosted     *big.Int
+	TimestampPosted *big.Int
+}, error) {
+	return _FusePoolDirectory.Contract.Pools(&_FusePoolDirectory.CallOpts, arg0)
+}
+
+// FusePoolDirectoryPoolRegisteredIterator is returned from FilterPoolRegistered and is used to iterate over the raw logs and unpacked data for PoolRegistered events raised by the FusePoolDirectory contract.
+type FusePoolDirectoryPoolRegisteredIterator struct {
+	Event *FusePoolDirectoryPoolRegistered // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *FusePoolDirectoryPoolRegisteredIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(FusePoolDirectoryPoolRegistered)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(FusePoolDirectoryPoolRegistered)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *FusePoolDirectoryPoolRegisteredIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *FusePoolDirectoryPoolRegisteredIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// FusePoolDirectoryPoolRegistered represents a PoolRegistered event raised by the FusePoolDirectory contract.
+type FusePoolDirectoryPoolRegistered struct {
+	Index *big.Int
+	Pool  FusePoolDirectoryFusePool
+	Raw   types.Log // Blockchain specific contextual infos
+}
+
+// FilterPoolRegistered is a free log retrieval operation binding the contract event 0x18075ab463b4dc5842f37ecd67abeb192eda5d073f2c08509e189ad173d5c020.
+//
+// Solidity: event PoolRegistered(uint256 index, (string,address,address,uint256,uint256) pool)
+func (_FusePoolDirectory *FusePoolDirectoryFilterer) FilterPoolRegistered(opts *bind.FilterOpts) (*FusePoolDirectoryPoolRegisteredIterator, error) {
+
+	logs, sub, err := _FusePoolDirectory.contract.FilterLogs(opts, "PoolRegistered")
+	if err != nil {
+		return nil, err
+	}
+	return &FusePoolDirectoryPoolRegisteredIterator{contract: _FusePoolDirectory.contract, event: "PoolRegistered", logs: logs, sub: sub}, nil
+}
+
+// WatchPoolRegistered is a free log subscription operation binding the contract event 0x18075ab463b4dc5842f37ecd67abeb192eda5d073f2c08509e189ad173d5c020.
+//
+// Solidity: event PoolRegistered(uint256 index, (string,address,address,uint256,uint256) pool)
+func (_FusePoolDirectory *FusePoolDirectoryFilterer) WatchPoolRegistered(opts *bind.WatchOpts, sink chan<- *FusePoolDirectoryPoolRegistered) (event.Subscription, error) {
+
+	logs, sub, err := _FusePoolDirectory.contract.WatchLogs(opts, "PoolRegistered")
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(FusePoolDirectoryPoolRegistered)
+				if err := _FusePoolDirectory.contract.UnpackLog(event, "PoolRegistered", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParsePoolRegistered is a log parse operation binding the contract event 0x18075ab463b4dc5842f37ecd67abeb192eda5d073f2c08509e189ad173d5c020.
+//
+// Solidity: event PoolRegistered(uint256 index, (string,address,address,uint256,uint256) pool)
+func (_FusePoolDirectory *FusePoolDirectoryFilterer) ParsePoolRegistered(log types.Log) (*FusePoolDirectoryPoolRegistered, error) {
+	event := new(FusePoolDirectoryPoolRegistered)
+	if err := _FusePoolDirectory.contract.UnpackLog(event, "PoolRegistered", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
@@ -0,0 +1,372 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package abis
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// VenusLiquidatorMetaData contains all meta data concerning the VenusLiquidator contract.
+var VenusLiquidatorMetaData = &bind.MetaData{
+	ABI: "[{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"internalType\":\"address\",\"name\":\"liquidator\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"address\",\"name\":\"borrower\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"repayAmount\",\"type\":\"uint256\"},{\"indexed\":false,\"internalType\":\"contractIVToken\",\"name\":\"vTokenBorrowed\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"contractIVToken\",\"name\":\"vTokenCollateral\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"seizeTokensForTreasury\",\"type\":\"uint256\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"seizeTokensForLiquidator\",\"type\":\"uint256\"}],\"name\":\"LiquidateBorrowedTokens\",\"type\":\"event\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"vToken\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"borrower\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"repayAmount\",\"type\":\"uint256\"},{\"internalType\":\"contractIVToken\",\"name\":\"vTokenCollateral\",\"type\":\"address\"}],\"name\":\"liquidateBorrow\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"treasuryPercentMantissa\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]",
+}
+
+// VenusLiquidatorABI is the input ABI used to generate the binding from.
+// Deprecated: Use VenusLiquidatorMetaData.ABI instead.
+var VenusLiquidatorABI = VenusLiquidatorMetaData.ABI
+
+// VenusLiquidator is an auto generated Go binding around an Ethereum contract.
+type VenusLiquidator struct {
+	VenusLiquidatorCaller     // Read-only binding to the contract
+	VenusLiquidatorTransactor // Write-only binding to the contract
+	VenusLiquidatorFilterer   // Log filterer for contract events
+}
+
+// VenusLiquidatorCaller is an auto generated read-only Go binding around an Ethereum contract.
+type VenusLiquidatorCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// VenusLiquidatorTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type VenusLiquidatorTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// VenusLiquidatorFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type VenusLiquidatorFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// VenusLiquidatorSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type VenusLiquidatorSession struct {
+	Contract     *VenusLiquidator  // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// VenusLiquidatorCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type VenusLiquidatorCallerSession struct {
+	Contract *VenusLiquidatorCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts          // Call options to use throughout this session
+}
+
+// VenusLiquidatorTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type VenusLiquidatorTransactorSession struct {
+	Contract     *VenusLiquidatorTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts          // Transaction auth options to use throughout this session
+}
+
+// VenusLiquidatorRaw is an auto generated low-level Go binding around an Ethereum contract.
+type VenusLiquidatorRaw struct {
+	Contract *VenusLiquidator // Generic contract binding to access the raw methods on
+}
+
+// VenusLiquidatorCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type VenusLiquidatorCallerRaw struct {
+	Contract *VenusLiquidatorCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// VenusLiquidatorTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type VenusLiquidatorTransactorRaw struct {
+	Contract *VenusLiquidatorTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewVenusLiquidator creates a new instance of VenusLiquidator, bound to a specific deployed contract.
+func NewVenusLiquidator(address common.Address, backend bind.ContractBackend) (*VenusLiquidator, error) {
+	contract, err := bindVenusLiquidator(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &VenusLiquidator{VenusLiquidatorCaller: VenusLiquidatorCaller{contract: contract}, VenusLiquidatorTransactor: VenusLiquidatorTransactor{contract: contract}, VenusLiquidatorFilterer: VenusLiquidatorFilterer{contract: contract}}, nil
+}
+
+// NewVenusLiquidatorCaller creates a new read-only instance of VenusLiquidator, bound to a specific deployed contract.
+func NewVenusLiquidatorCaller(address common.Address, caller bind.ContractCaller) (*VenusLiquidatorCaller, error) {
+	contract, err := bindVenusLiquidator(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &VenusLiquidatorCaller{contract: contract}, nil
+}
+
+// NewVenusLiquidatorTransactor creates a new write-only instance of VenusLiquidator, bound to a specific deployed contract.
+func NewVenusLiquidatorTransactor(address common.Address, transactor bind.ContractTransactor) (*VenusLiquidatorTransactor, error) {
+	contract, err := bindVenusLiquidator(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &VenusLiquidatorTransactor{contract: contract}, nil
+}
+
+// NewVenusLiquidatorFilterer creates a new log filterer instance of VenusLiquidator, bound to a specific deployed contract.
+func NewVenusLiquidatorFilterer(address common.Address, filterer bind.ContractFilterer) (*VenusLiquidatorFilterer, error) {
+	contract, err := bindVenusLiquidator(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &VenusLiquidatorFilterer{contract: contract}, nil
+}
+
+// bindVenusLiquidator binds a generic wrapper to an already deployed contract.
+func bindVenusLiquidator(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(VenusLiquidatorABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_VenusLiquidator *VenusLiquidatorRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _VenusLiquidator.Contract.VenusLiquidatorCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_VenusLiquidator *VenusLiquidatorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _VenusLiquidator.Contract.VenusLiquidatorTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_VenusLiquidator *VenusLiquidatorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _VenusLiquidator.Contract.VenusLiquidatorTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_VenusLiquidator *VenusLiquidatorCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _VenusLiquidator.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_VenusLiquidator *VenusLiquidatorTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _VenusLiquidator.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_VenusLiquidator *VenusLiquidatorTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _VenusLiquidator.Contract.contract.Transact(opts, method, params...)
+}
+
+// TreasuryPercentMantissa is a free data retrieval call binding the contract method 0x8e3525fc.
+//
+// Solidity: function treasuryPercentMantissa() view returns(uint256)
+func (_VenusLiquidator *VenusLiquidatorCaller) TreasuryPercentMantissa(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _VenusLiquidator.contract.Call(opts, &out, "treasuryPercentMantissa")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// TreasuryPercentMantissa is a free data retrieval call binding the contract method 0x8e3525fc.
+//
+// Solidity: function treasuryPercentMantissa() view returns(uint256)
+func (_VenusLiquidator *VenusLiquidatorSession) TreasuryPercentMantissa() (*big.Int, error) {
+	return _VenusLiquidator.Contract.TreasuryPercentMantissa(&_VenusLiquidator.CallOpts)
+}
+
+// TreasuryPercentMantissa is a free data retrieval call binding the contract method 0x8e3525fc.
+//
+// Solidity: function treasuryPercentMantissa() view returns(uint256)
+func (_VenusLiquidator *VenusLiquidatorCallerSession) TreasuryPercentMantissa() (*big.Int, error) {
+	return _VenusLiquidator.Contract.TreasuryPercentMantissa(&_VenusLiquidator.CallOpts)
+}
+
+// LiquidateBorrow is a paid mutator transaction binding the contract method 0x64fd7078.
+//
+// Solidity: function liquidateBorrow(address vToken, address borrower, uint256 repayAmount, address vTokenCollateral) payable returns()
+func (_VenusLiquidator *VenusLiquidatorTransactor) LiquidateBorrow(opts *bind.TransactOpts, vToken common.Address, borrower common.Address, repayAmount *big.Int, vTokenCollateral common.Address) (*types.Transaction, error) {
+	return _VenusLiquidator.contract.Transact(opts, "liquidateBorrow", vToken, borrower, repayAmount, vTokenCollateral)
+}
+
+// LiquidateBorrow is a paid mutator transaction binding the contract method 0x64fd7078.
+//
+// Solidity: function liquidateBorrow(address vToken, address borrower, uint256 repayAmount, address vTokenCollateral) payable returns()
+func (_VenusLiquidator *VenusLiquidatorSession) LiquidateBorrow(vToken common.Address, borrower common.Address, repayAmount *big.Int, vTokenCollateral common.Address) (*types.Transaction, error) {
+	return _VenusLiquidator.Contract.LiquidateBorrow(&_VenusLiquidator.TransactOpts, vToken, borrower, repayAmount, vTokenCollateral)
+}
+
+// LiquidateBorrow is a paid mutator transaction binding the contract method 0x64fd7078.
+//
+// Solidity: function liquidateBorrow(address vToken, address borrower, uint256 repayAmount, address vTokenCollateral) payable returns()
+func (_VenusLiquidator *VenusLiquidatorTransactorSession) LiquidateBorrow(vToken common.Address, borrower common.Address, repayAmount *big.Int, vTokenCollateral common.Address) (*types.Transaction, error) {
+	return _VenusLiquidator.Contract.LiquidateBorrow(&_VenusLiquidator.TransactOpts, vToken, borrower, repayAmount, vTokenCollateral)
+}
+
+// VenusLiquidatorLiquidateBorrowedTokensIterator is returned from FilterLiquidateBorrowedTokens and is used to iterate over the raw logs and unpacked data for LiquidateBorrowedTokens events raised by the VenusLiquidator contract.
+type VenusLiquidatorLiquidateBorrowedTokensIterator struct {
+	Event *VenusLiquidatorLiquidateBorrowedTokens // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *VenusLiquidatorLiquidateBorrowedTokensIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(VenusLiquidatorLiquidateBorrowedTokens)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(VenusLiquidatorLiquidateBorrowedTokens)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *VenusLiquidatorLiquidateBorrowedTokensIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *VenusLiquidatorLiquidateBorrowedTokensIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// VenusLiquidatorLiquidateBorrowedTokens represents a LiquidateBorrowedTokens event raised by the VenusLiquidator contract.
+type VenusLiquidatorLiquidateBorrowedTokens struct {
+	Liquidator               common.Address
+	Borrower                 common.Address
+	RepayAmount              *big.Int
+	VTokenBorrowed           common.Address
+	VTokenCollateral         common.Address
+	SeizeTokensForTreasury   *big.Int
+	SeizeTokensForLiquidator *big.Int
+	Raw                      types.Log // Blockchain specific contextual infos
+}
+
+// FilterLiquidateBorrowedTokens is a free log retrieval operation binding the contract event 0xdd091524d794aecdb5235b2d816620c2598790835e0f3849808504c2dcb4f1a9.
+//
+// Solidity: event LiquidateBorrowedTokens(address liquidator, address borrower, uint256 repayAmount, address vTokenBorrowed, address vTokenCollateral, uint256 seizeTokensForTreasury, uint256 seizeTokensForLiquidator)
+func (_VenusLiquidator *VenusLiquidatorFilterer) FilterLiquidateBorrowedTokens(opts *bind.FilterOpts) (*VenusLiquidatorLiquidateBorrowedTokensIterator, error) {
+
+	logs, sub, err := _VenusLiquidator.contract.FilterLogs(opts, "LiquidateBorrowedTokens")
+	if err != nil {
+		return nil, err
+	}
+	return &VenusLiquidatorLiquidateBorrowedTokensIterator{contract: _VenusLiquidator.contract, event: "LiquidateBorrowedTokens", logs: logs, sub: sub}, nil
+}
+
+// WatchLiquidateBorrowedTokens is a free log subscription operation binding the contract event 0xdd091524d794aecdb5235b2d816620c2598790835e0f3849808504c2dcb4f1a9.
+//
+// Solidity: event LiquidateBorrowedTokens(address liquidator, address borrower, uint256 repayAmount, address vTokenBorrowed, address vTokenCollateral, uint256 seizeTokensForTreasury, uint256 seizeTokensForLiquidator)
+func (_VenusLiquidator *VenusLiquidatorFilterer) WatchLiquidateBorrowedTokens(opts *bind.WatchOpts, sink chan<- *VenusLiquidatorLiquidateBorrowedTokens) (event.Subscription, error) {
+
+	logs, sub, err := _VenusLiquidator.contract.WatchLogs(opts, "LiquidateBorrowedTokens")
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(VenusLiquidatorLiquidateBorrowedTokens)
+				if err := _VenusLiquidator.contract.UnpackLog(event, "LiquidateBorrowedTokens", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseLiquidateBorrowedTokens is a log parse operation binding the contract event 0xdd091524d794aecdb5235b2d816620c2598790835e0f3849808504c2dcb4f1a9.
+//
+// Solidity: event LiquidateBorrowedTokens(address liquidator, address borrower, uint256 repayAmount, address vTokenBorrowed, address vTokenCollateral, uint256 seizeTokensForTreasury, uint256 seizeTokensForLiquidator)
+func (_VenusLiquidator *VenusLiquidatorFilterer) ParseLiquidateBorrowedTokens(log types.Log) (*VenusLiquidatorLiquidateBorrowedTokens, error) {
+	event := new(VenusLiquidatorLiquidateBorrowedTokens)
+	if err := _VenusLiquidator.contract.UnpackLog(event, "LiquidateBorrowedTokens", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
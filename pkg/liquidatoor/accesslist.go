@@ -0,0 +1,149 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// accessLists builds the EIP-2930 access lists of our transactions with
+// eth_createAccessList, so the storage they touch, of the comptroller,
+// the markets, the oracle and the underlyings, is paid for upfront at
+// the warm rate. A list is only used if the gas estimated with it is
+// lower. Nodes without the method are found on the first attempt and
+// sent transactions without lists from then on. A nil accessLists
+// builds none.
+type accessLists struct {
+	logger  Logger
+	client  *rpc.Client
+	chainID *big.Int
+	// Set once the node is found not to support the method
+	unsupported uint32
+}
+
+func newAccessLists(logger Logger, client *rpc.Client, chainID *big.Int) *accessLists {
+	if client == nil {
+		logger.Warn("Cannot create access lists without a JSON-RPC client; sending transactions without them")
+		return nil
+	}
+	return &accessLists{logger: logger, client: client, chainID: chainID}
+}
+
+// saving returns the access list of msg and the gas estimated with it,
+// if it is lower than gas, the estimate without it. Otherwise, or if
+// the list cannot be created, it returns no list and gas.
+func (a *accessLists) saving(ctx context.Context, client Backend, msg ethereum.CallMsg, gas uint64) (types.AccessList, uint64) {
+	if a == nil || atomic.LoadUint32(&a.unsupported) == 1 {
+		return nil, gas
+	}
+	list, err := a.create(ctx, msg)
+	if err != nil {
+		a.logger.Debug(fmt.Sprintf("Cannot create access list: %v", err), F("err", err))
+		return nil, gas
+	}
+	if len(list) == 0 {
+		return nil, gas
+	}
+	msg.AccessList = list
+	listed, err := client.EstimateGas(ctx, msg)
+	if err != nil {
+		a.logger.Debug(fmt.Sprintf("Cannot estimate gas with access list: %v", err), F("err", err))
+		return nil, gas
+	}
+	if listed >= gas {
+		return nil, gas
+	}
+	return list, listed
+}
+
+// create returns the access list of msg at the pending block.
+func (a *accessLists) create(ctx context.Context, msg ethereum.CallMsg) (types.AccessList, error) {
+	arg := map[string]interface{}{"from": msg.From, "to": msg.To, "data": hexutil.Bytes(msg.Data)}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	var result struct {
+		AccessList types.AccessList `json:"accessList"`
+		Error      string           `json:"error"`
+	}
+	if err := a.client.CallContext(ctx, &result, "eth_createAccessList", arg, "pending"); err != nil {
+		if isMethodUnsupported(err) && atomic.CompareAndSwapUint32(&a.unsupported, 0, 1) {
+			a.logger.Warn(fmt.Sprintf("Node does not support access lists; sending transactions without them: %v", err), F("err", err))
+		}
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	return result.AccessList, nil
+}
+
+// transact signs and sends call with list, as the transaction bind would
+// send without it: of dynamic fees unless opts set a gas price or the
+// chain has no base fee. opts carry the nonce and the gas limit.
+func (a *accessLists) transact(ctx context.Context, client Backend, opts *bind.TransactOpts, call *RepayCall, list types.AccessList) (*types.Transaction, error) {
+	var nonce uint64
+	if opts.Nonce != nil {
+		nonce = opts.Nonce.Uint64()
+	} else {
+		var err error
+		if nonce, err = client.PendingNonceAt(ctx, opts.From); err != nil {
+			return nil, fmt.Errorf("cannot get pending nonce: %w", err)
+		}
+	}
+
+	var data types.TxData
+	gasPrice := opts.GasPrice
+	if gasPrice == nil {
+		head, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get latest header: %w", err)
+		}
+		if head.BaseFee != nil {
+			tip := opts.GasTipCap
+			if tip == nil {
+				if tip, err = client.SuggestGasTipCap(ctx); err != nil {
+					return nil, fmt.Errorf("cannot get gas tip: %w", err)
+				}
+			}
+			feeCap := opts.GasFeeCap
+			if feeCap == nil {
+				feeCap = new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+			}
+			data = &types.DynamicFeeTx{ChainID: a.chainID, Nonce: nonce, GasTipCap: tip, GasFeeCap: feeCap, Gas: opts.GasLimit, To: &call.To, Value: call.Value, Data: call.Data, AccessList: list}
+		} else if gasPrice, err = client.SuggestGasPrice(ctx); err != nil {
+			return nil, fmt.Errorf("cannot get gas price: %w", err)
+		}
+	}
+	if data == nil {
+		data = &types.AccessListTx{ChainID: a.chainID, Nonce: nonce, GasPrice: gasPrice, Gas: opts.GasLimit, To: &call.To, Value: call.Value, Data: call.Data, AccessList: list}
+	}
+	tx, err := opts.Signer(opts.From, types.NewTx(data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign transaction: %w", err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// isMethodUnsupported reports whether err is the answer of a node
+// without the method called.
+func isMethodUnsupported(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601 {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "method not found") || strings.Contains(msg, "does not exist") || strings.Contains(msg, "not supported")
+}
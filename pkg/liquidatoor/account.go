@@ -0,0 +1,156 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// AccountReport is the detail of an account in every Compound pool
+// monitored.
+type AccountReport struct {
+	Account common.Address `json:"account"`
+	// Of the account, see Presenter
+	Label      string        `json:"label,omitempty"`
+	URL        string        `json:"url"`
+	Annotation *Annotation   `json:"annotation,omitempty"`
+	Pools      []AccountPool `json:"pools"`
+}
+
+// AccountPool is the detail of an account in a pool, read at a single
+// block. Values are in the unit of account of the oracle scaled by
+// 1e18.
+type AccountPool struct {
+	Pool     common.Address `json:"pool"`
+	URL      string         `json:"url"`
+	Protocol string         `json:"protocol"`
+	Block    *decimalInt    `json:"block"`
+	// Markets the account entered
+	Markets   []AccountMarket `json:"markets"`
+	Supplied  *decimalInt     `json:"supplied"`
+	Borrowed  *decimalInt     `json:"borrowed"`
+	Liquidity *decimalInt     `json:"liquidity"`
+	Shortfall *decimalInt     `json:"shortfall"`
+	// Nil if a market cannot be priced
+	HealthFactor *Ratio `json:"healthFactor"`
+	// Price moves of one market at a time that bring the liquidity to
+	// zero, smallest first
+	Sensitivities []PriceSensitivity `json:"sensitivities"`
+	Cooldown      *Cooldown          `json:"cooldown,omitempty"`
+	// Why the account cannot be read in the pool, if so
+	Err string `json:"error,omitempty"`
+}
+
+// AccountMarket is the position of an account in a market, in
+// underlying; its price and values are nil if the oracle cannot price
+// it.
+type AccountMarket struct {
+	Market     common.Address `json:"market"`
+	Underlying common.Address `json:"underlying"`
+	Symbol     string         `json:"symbol"`
+	Decimals   uint8          `json:"decimals"`
+	Supplied   *decimalInt    `json:"supplied"`
+	Borrowed   *decimalInt    `json:"borrowed"`
+	// Scaled by 1e(36-decimals)
+	Price         *decimalInt `json:"price"`
+	SuppliedValue *decimalInt `json:"suppliedValue"`
+	BorrowedValue *decimalInt `json:"borrowedValue"`
+}
+
+// Account reads account in the pool at the latest block: the markets
+// it entered, its balances and their prices, its liquidity and its
+// health factor, all at the same block. Accounts that never entered a
+// market have none, zero values and no health factor.
+func (l *Liquidatoor) Account(ctx context.Context, account common.Address) (AccountPool, error) {
+	header, err := l.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return AccountPool{}, fmt.Errorf("cannot get latest block: %w", err)
+	}
+	block := header.Number
+
+	assetsMethod := l.comptrollerABI.Methods["getAssetsIn"]
+	liquidityMethod := l.comptrollerABI.Methods["getAccountLiquidity"]
+	// Both methods take the account only, so the inputs are shared
+	inputs, err := assetsMethod.Inputs.Pack(account)
+	if err != nil {
+		return AccountPool{}, fmt.Errorf("cannot pack account: %w", err)
+	}
+	calls := []abis.MulticallCall{
+		{Target: l.comptrollerAddress, CallData: append(assetsMethod.ID[:len(assetsMethod.ID):len(assetsMethod.ID)], inputs...)},
+		{Target: l.comptrollerAddress, CallData: append(liquidityMethod.ID[:len(liquidityMethod.ID):len(liquidityMethod.ID)], inputs...)},
+	}
+	resp, err := l.Batcher.Aggregate(&bind.CallOpts{Context: ctx, BlockNumber: block}, calls)
+	if err != nil {
+		return AccountPool{}, fmt.Errorf("failed batch request: %v", err)
+	}
+	if !resp[0].Success || !resp[1].Success {
+		return AccountPool{}, fmt.Errorf("cannot get liquidity of account %s", account)
+	}
+	var assets []common.Address
+	if err := l.comptrollerABI.UnpackIntoInterface(&assets, assetsMethod.Name, resp[0].ReturnData); err != nil {
+		return AccountPool{}, fmt.Errorf("cannot unpack output: %v", err)
+	}
+	liquidity := accountLiquidity(resp[1].ReturnData)
+	if err := liquidity.validate(); err != nil {
+		return AccountPool{}, err
+	}
+	if liquidity.failed() {
+		return AccountPool{}, fmt.Errorf("cannot get liquidity of account %s: %s", account, liquidity.failure())
+	}
+
+	positions, err := l.positions(ctx, block, []Borrower{{Address: account, Assets: assets}})
+	if err != nil {
+		return AccountPool{}, fmt.Errorf("cannot get positions: %w", err)
+	}
+	prices, err := pricesOf(ctx, l.logger, l.priceSource, l.assets(assets), block)
+	if err != nil {
+		return AccountPool{}, fmt.Errorf("cannot get prices: %w", err)
+	}
+	snapshot := l.snapshot(block, assets, prices, nil)
+
+	report := AccountPool{
+		Pool:      l.comptrollerAddress,
+		URL:       l.presenter.AddressURL(l.comptrollerAddress),
+		Protocol:  snapshot.Protocol,
+		Block:     decimal(block),
+		Markets:   make([]AccountMarket, 0, len(positions[0].Positions)),
+		Liquidity: decimal(liquidity.liquidity()),
+		Shortfall: decimal(liquidity.shortfall()),
+		Cooldown:  l.queue.cooldowns.active(l.comptrollerAddress, account, time.Now()),
+	}
+	supplied, borrowed := new(big.Int), new(big.Int)
+	for _, position := range positions[0].Positions {
+		market := snapshot.Markets[position.Market]
+		m := AccountMarket{
+			Market:     position.Market,
+			Underlying: market.Underlying,
+			Symbol:     market.Symbol,
+			Decimals:   market.Decimals,
+			Supplied:   decimal(orZero(position.Supplied)),
+			Borrowed:   decimal(orZero(position.Borrowed)),
+		}
+		if market.Price != nil {
+			m.Price = decimal(market.Price)
+			m.SuppliedValue = decimal(market.Value(orZero(position.Supplied)))
+			m.BorrowedValue = decimal(market.Value(orZero(position.Borrowed)))
+			supplied.Add(supplied, m.SuppliedValue.int())
+			borrowed.Add(borrowed, m.BorrowedValue.int())
+		}
+		report.Markets = append(report.Markets, m)
+	}
+	report.Supplied, report.Borrowed = decimal(supplied), decimal(borrowed)
+	// The health factor of a healthy account is above 1 by its
+	// liquidity
+	positions[0].Shortfall = new(big.Int).Sub(liquidity.shortfall(), liquidity.liquidity())
+	if len(report.Markets) > 0 {
+		report.HealthFactor = healthFactor(snapshot, positions[0])
+	}
+	report.Sensitivities = sensitivities(snapshot, positions[0], new(big.Int).Neg(positions[0].Shortfall))
+	return report, nil
+}
@@ -0,0 +1,175 @@
+package liquidatoor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const accountListReloadInterval = 10 * time.Second
+
+// accountList is the fixed set of accounts monitored instead of the
+// borrowers of every pool, eg., the few accounts a researcher studies:
+// the accounts of the config and those of a file, one or more per line,
+// separated by commas or spaces, with # comments. The file is reloaded
+// when it changes. Borrowers are then neither read with getAllBorrowers
+// nor discovered from events; the rest of the checks are the same. A nil
+// accountList lists nothing. It is safe for concurrent use.
+type accountList struct {
+	logger Logger
+	path   string
+	static []common.Address
+
+	lock     sync.RWMutex
+	accounts []common.Address
+	listed   map[common.Address]bool
+	modTime  time.Time
+	// Signalled on every reload
+	reloads []chan struct{}
+}
+
+func newAccountList(logger Logger, accounts []common.Address, path string) *accountList {
+	l := &accountList{logger: logger, path: path, static: accounts}
+	l.set(nil, time.Time{})
+	return l
+}
+
+// load reads the accounts of the file, replacing the loaded ones.
+func (l *accountList) load() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return fmt.Errorf("cannot read account list: %w", err)
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("cannot read account list: %w", err)
+	}
+	accounts, err := parseAccountList(data)
+	if err != nil {
+		return fmt.Errorf("cannot parse account list %s: %w", l.path, err)
+	}
+	count := l.set(accounts, info.ModTime())
+	l.logger.Info(fmt.Sprintf("Monitoring %d listed accounts from %s", count, l.path), F("path", l.path), F("accounts", count))
+	return nil
+}
+
+// set lists the accounts of the file along with those of the config,
+// and returns how many are listed.
+func (l *accountList) set(file []common.Address, modTime time.Time) int {
+	accounts := make([]common.Address, 0, len(l.static)+len(file))
+	listed := make(map[common.Address]bool, cap(accounts))
+	for _, list := range [][]common.Address{l.static, file} {
+		for _, account := range list {
+			if !listed[account] {
+				listed[account] = true
+				accounts = append(accounts, account)
+			}
+		}
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.accounts, l.listed, l.modTime = accounts, listed, modTime
+	for _, reload := range l.reloads {
+		select {
+		case reload <- struct{}{}:
+		default:
+		}
+	}
+	return len(accounts)
+}
+
+func parseAccountList(data []byte) ([]common.Address, error) {
+	accounts := make([]common.Address, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			if !common.IsHexAddress(field) {
+				return nil, fmt.Errorf("invalid address %s on line %d", field, number)
+			}
+			accounts = append(accounts, common.HexToAddress(field))
+		}
+	}
+	return accounts, scanner.Err()
+}
+
+// run reloads the file whenever it changes, until ctx is cancelled.
+// The loaded accounts are kept if reloading fails.
+func (l *accountList) run(ctx context.Context) {
+	if l == nil || l.path == "" {
+		return
+	}
+	ticker := time.NewTicker(accountListReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		l.lock.RLock()
+		modTime := l.modTime
+		l.lock.RUnlock()
+		info, err := os.Stat(l.path)
+		if err == nil && info.ModTime().Equal(modTime) {
+			continue
+		}
+		if err == nil {
+			err = l.load()
+		}
+		if err != nil {
+			l.logger.Error(fmt.Sprintf("Failed to reload account list; keeping the loaded accounts: %v", err), F("path", l.path), F("err", err))
+		}
+	}
+}
+
+// subscribe returns a channel signalled whenever the list is reloaded,
+// or nil if there is no list.
+func (l *accountList) subscribe() <-chan struct{} {
+	if l == nil {
+		return nil
+	}
+	reload := make(chan struct{}, 1)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.reloads = append(l.reloads, reload)
+	return reload
+}
+
+// Accounts returns the accounts listed.
+func (l *accountList) Accounts() []common.Address {
+	if l == nil {
+		return nil
+	}
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	accounts := make([]common.Address, len(l.accounts))
+	copy(accounts, l.accounts)
+	return accounts
+}
+
+// filter returns the accounts listed, of accounts.
+func (l *accountList) filter(accounts []common.Address) []common.Address {
+	if l == nil {
+		return accounts
+	}
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	listed := make([]common.Address, 0, len(accounts))
+	for _, account := range accounts {
+		if l.listed[account] {
+			listed = append(listed, account)
+		}
+	}
+	return listed
+}
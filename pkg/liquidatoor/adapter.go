@@ -0,0 +1,142 @@
+package liquidatoor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+const (
+	CompoundAdapter = "compound"
+	VenusAdapter    = "venus"
+)
+
+// ProtocolAdapter encapsulates the protocol specifics of liquidations
+// so that forks with different liquidation signatures or error models
+// can be supported without touching the rest of the liquidatoor.
+type ProtocolAdapter interface {
+	// Name returns the name of the adapter as used in the config.
+	Name() string
+	// RepayCall builds the transaction that repays the borrower's debt
+	// and seizes their collateral.
+	RepayCall(params RepayParams) (*RepayCall, error)
+	// DecodeSeize returns the amount of collateral cTokens seized for
+	// the liquidator by the liquidation in the provided receipt.
+	DecodeSeize(receipt *types.Receipt) (*big.Int, error)
+	// LiquidationParams reads the close factor and the liquidation
+	// incentive mantissas of the pool.
+	LiquidationParams(opts *bind.CallOpts) (closeFactor, incentive *big.Int, err error)
+}
+
+type RepayParams struct {
+	Borrower         common.Address
+	CTokenBorrowed   common.Address
+	CTokenCollateral common.Address
+	RepayAmount      *big.Int
+	// Whether the borrowed market is a native token market
+	Native bool
+}
+
+// RepayCall is a transaction ready to be signed and sent.
+type RepayCall struct {
+	To    common.Address
+	Value *big.Int
+	Data  []byte
+}
+
+var errNoSeize = errors.New("no seize found in receipt")
+
+func newProtocolAdapter(name string, conn *Connection, comptroller Comptroller) (ProtocolAdapter, error) {
+	switch name {
+	case CompoundAdapter:
+		return newCompoundProtocolAdapter(comptroller)
+	case VenusAdapter:
+		return newVenusProtocolAdapter(conn.venusLiquidatorAddress, comptroller)
+	default:
+		return nil, fmt.Errorf("unknown protocol adapter %q", name)
+	}
+}
+
+// compoundProtocolAdapter supports Compound v2 and Fuse pools where
+// liquidations are executed directly on the borrowed cToken.
+type compoundProtocolAdapter struct {
+	comptroller Comptroller
+	cTokenABI   *abi.ABI
+	cEtherABI   *abi.ABI
+}
+
+func newCompoundProtocolAdapter(comptroller Comptroller) (*compoundProtocolAdapter, error) {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	cEtherABI, err := abis.CEtherMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get cether ABI: %w", err)
+	}
+
+	return &compoundProtocolAdapter{
+		comptroller: comptroller,
+		cTokenABI:   cTokenABI,
+		cEtherABI:   cEtherABI,
+	}, nil
+}
+
+func (a *compoundProtocolAdapter) Name() string {
+	return CompoundAdapter
+}
+
+func (a *compoundProtocolAdapter) RepayCall(params RepayParams) (*RepayCall, error) {
+	if params.Native {
+		// The repay amount is sent as value in native token markets
+		data, err := a.cEtherABI.Pack("liquidateBorrow", params.Borrower, params.CTokenCollateral)
+		if err != nil {
+			return nil, fmt.Errorf("cannot pack liquidateBorrow: %w", err)
+		}
+		return &RepayCall{To: params.CTokenBorrowed, Value: params.RepayAmount, Data: data}, nil
+	}
+
+	data, err := a.cTokenABI.Pack("liquidateBorrow", params.Borrower, params.RepayAmount, params.CTokenCollateral)
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack liquidateBorrow: %w", err)
+	}
+	return &RepayCall{To: params.CTokenBorrowed, Value: new(big.Int), Data: data}, nil
+}
+
+func (a *compoundProtocolAdapter) DecodeSeize(receipt *types.Receipt) (*big.Int, error) {
+	event := a.cTokenABI.Events["LiquidateBorrow"]
+	for _, log := range receipt.Logs {
+		if len(log.Topics) == 0 || log.Topics[0] != event.ID {
+			continue
+		}
+		out, err := event.Inputs.Unpack(log.Data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unpack LiquidateBorrow event: %w", err)
+		}
+		return *abi.ConvertType(out[4], new(*big.Int)).(**big.Int), nil
+	}
+	return nil, errNoSeize
+}
+
+func (a *compoundProtocolAdapter) LiquidationParams(opts *bind.CallOpts) (*big.Int, *big.Int, error) {
+	return readComptrollerLiquidationParams(opts, a.comptroller)
+}
+
+func readComptrollerLiquidationParams(opts *bind.CallOpts, comptroller Comptroller) (*big.Int, *big.Int, error) {
+	closeFactor, err := comptroller.CloseFactorMantissa(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get close factor: %w", err)
+	}
+	incentive, err := comptroller.LiquidationIncentiveMantissa(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get liquidation incentive: %w", err)
+	}
+	return closeFactor, incentive, nil
+}
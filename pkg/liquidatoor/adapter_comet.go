@@ -0,0 +1,83 @@
+package liquidatoor
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+const CometAdapter = "comet"
+
+// cometProtocolAdapter supports Compound v3 where liquidatable
+// accounts are absorbed by the Comet contract as a whole. The
+// absorber repays nothing and seizes nothing; the collateral is
+// taken over by the protocol and sold at a discount through
+// buyCollateral.
+type cometProtocolAdapter struct {
+	comet    common.Address
+	absorber common.Address
+	cometABI *abi.ABI
+}
+
+func newCometProtocolAdapter(comet, absorber common.Address) (*cometProtocolAdapter, error) {
+	cometABI, err := abis.CometMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get comet ABI: %w", err)
+	}
+
+	return &cometProtocolAdapter{
+		comet:    comet,
+		absorber: absorber,
+		cometABI: cometABI,
+	}, nil
+}
+
+func (a *cometProtocolAdapter) Name() string {
+	return CometAdapter
+}
+
+// RepayCall builds an absorb call; only the borrower is used.
+func (a *cometProtocolAdapter) RepayCall(params RepayParams) (*RepayCall, error) {
+	data, err := a.cometABI.Pack("absorb", a.absorber, []common.Address{params.Borrower})
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack absorb: %w", err)
+	}
+	return &RepayCall{To: a.comet, Value: new(big.Int), Data: data}, nil
+}
+
+// DecodeSeize returns the USD value, scaled by 1e8, of the collateral
+// absorbed by the protocol.
+func (a *cometProtocolAdapter) DecodeSeize(receipt *types.Receipt) (*big.Int, error) {
+	event := a.cometABI.Events["AbsorbCollateral"]
+
+	var total *big.Int
+	for _, log := range receipt.Logs {
+		if log.Address != a.comet || len(log.Topics) == 0 || log.Topics[0] != event.ID {
+			continue
+		}
+		out, err := event.Inputs.NonIndexed().Unpack(log.Data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unpack AbsorbCollateral event: %w", err)
+		}
+		if total == nil {
+			total = new(big.Int)
+		}
+		total.Add(total, *abi.ConvertType(out[1], new(*big.Int)).(**big.Int))
+	}
+	if total == nil {
+		return nil, errNoSeize
+	}
+	return total, nil
+}
+
+// LiquidationParams returns a close factor of 1 since positions are
+// absorbed as a whole, and no incentive for the absorber.
+func (a *cometProtocolAdapter) LiquidationParams(*bind.CallOpts) (*big.Int, *big.Int, error) {
+	return new(big.Int).Set(expScale), new(big.Int).Set(expScale), nil
+}
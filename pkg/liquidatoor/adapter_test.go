@@ -0,0 +1,190 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/fakes"
+)
+
+var (
+	adapterBorrower   = common.HexToAddress("0x1000")
+	adapterBorrowed   = common.HexToAddress("0xa")
+	adapterCollateral = common.HexToAddress("0xb")
+	venusLiquidator   = common.HexToAddress("0x0870793286aada55d39ce7f82fb2766e8004cf43")
+)
+
+func repayParams(native bool) RepayParams {
+	return RepayParams{
+		Borrower:         adapterBorrower,
+		CTokenBorrowed:   adapterBorrowed,
+		CTokenCollateral: adapterCollateral,
+		RepayAmount:      big.NewInt(500),
+		Native:           native,
+	}
+}
+
+// eventLog returns a log of the event of contractABI emitted by
+// address, with its non-indexed inputs.
+func eventLog(t *testing.T, contractABI *abi.ABI, address common.Address, name string, inputs ...interface{}) *types.Log {
+	t.Helper()
+	event := contractABI.Events[name]
+	data, err := event.Inputs.Pack(inputs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &types.Log{Address: address, Topics: []common.Hash{event.ID}, Data: data}
+}
+
+// checkCall checks call is sent to to with value, calling method of
+// contractABI with args.
+func checkCall(t *testing.T, call *RepayCall, contractABI *abi.ABI, to common.Address, value int64, args ...interface{}) {
+	t.Helper()
+	if call.To != to {
+		t.Fatalf("expected the call sent to %s, got %s", to, call.To)
+	}
+	if call.Value == nil || call.Value.Cmp(big.NewInt(value)) != 0 {
+		t.Fatalf("expected a value of %d, got %v", value, call.Value)
+	}
+	data, err := contractABI.Pack("liquidateBorrow", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(call.Data, data) {
+		t.Fatalf("expected calldata %x, got %x", data, call.Data)
+	}
+}
+
+func TestCompoundAdapterRepayCall(t *testing.T) {
+	adapter, err := newCompoundProtocolAdapter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, err := adapter.RepayCall(repayParams(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCall(t, call, adapter.cTokenABI, adapterBorrowed, 0, adapterBorrower, big.NewInt(500), adapterCollateral)
+
+	// The repay amount is the value of native markets
+	call, err = adapter.RepayCall(repayParams(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCall(t, call, adapter.cEtherABI, adapterBorrowed, 500, adapterBorrower, adapterCollateral)
+}
+
+func TestVenusAdapterRepayCall(t *testing.T) {
+	adapter, err := newVenusProtocolAdapter(venusLiquidator, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Through the Liquidator, which takes the borrowed vToken first
+	for _, native := range []bool{false, true} {
+		call, err := adapter.RepayCall(repayParams(native))
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := int64(0)
+		if native {
+			value = 500
+		}
+		checkCall(t, call, adapter.liquidatorABI, venusLiquidator, value, adapterBorrowed, adapterBorrower, big.NewInt(500), adapterCollateral)
+	}
+
+	if _, err := newVenusProtocolAdapter(common.Address{}, nil); err == nil {
+		t.Fatal("expected an error without the Liquidator address")
+	}
+}
+
+func TestCompoundAdapterDecodeSeize(t *testing.T) {
+	adapter, err := newCompoundProtocolAdapter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comptrollerABI, err := abis.ComptrollerMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	liquidator := common.HexToAddress("0xfeed")
+	receipt := &types.Receipt{Logs: []*types.Log{
+		// Logs of the repay and the comptroller come first
+		{Address: adapterBorrowed},
+		eventLog(t, comptrollerABI, common.HexToAddress("0xc0"), "MarketExited", adapterCollateral, adapterBorrower),
+		eventLog(t, adapter.cTokenABI, adapterBorrowed, "LiquidateBorrow", liquidator, adapterBorrower, big.NewInt(500), adapterCollateral, big.NewInt(27e8)),
+	}}
+	seized, err := adapter.DecodeSeize(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seized.Cmp(big.NewInt(27e8)) != 0 {
+		t.Fatalf("expected 27e8 seized, got %v", seized)
+	}
+
+	if _, err := adapter.DecodeSeize(&types.Receipt{Logs: receipt.Logs[:2]}); !errors.Is(err, errNoSeize) {
+		t.Fatalf("expected errNoSeize without a LiquidateBorrow event, got %v", err)
+	}
+}
+
+func TestVenusAdapterDecodeSeize(t *testing.T) {
+	adapter, err := newVenusProtocolAdapter(venusLiquidator, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := func(address common.Address, seized int64) *types.Log {
+		return eventLog(t, adapter.liquidatorABI, address, "LiquidateBorrowedTokens",
+			common.HexToAddress("0xfeed"), adapterBorrower, big.NewInt(500), adapterBorrowed, adapterCollateral, big.NewInt(1e8), big.NewInt(seized))
+	}
+	// Only the share of the liquidator, as emitted by the Liquidator
+	seized, err := adapter.DecodeSeize(&types.Receipt{Logs: []*types.Log{event(common.HexToAddress("0xbad"), 1), event(venusLiquidator, 26e8)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seized.Cmp(big.NewInt(26e8)) != 0 {
+		t.Fatalf("expected 26e8 seized for the liquidator, got %v", seized)
+	}
+
+	if _, err := adapter.DecodeSeize(&types.Receipt{Logs: []*types.Log{event(common.HexToAddress("0xbad"), 1)}}); !errors.Is(err, errNoSeize) {
+		t.Fatalf("expected errNoSeize without an event of the Liquidator, got %v", err)
+	}
+}
+
+func TestAdapterLiquidationParams(t *testing.T) {
+	comptroller := &fakes.Comptroller{CloseFactor: big.NewInt(5e17), LiquidationIncentive: big.NewInt(108e16)}
+	for _, name := range []string{CompoundAdapter, VenusAdapter} {
+		adapter, err := newProtocolAdapter(name, &Connection{venusLiquidatorAddress: venusLiquidator}, comptroller)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if adapter.Name() != name {
+			t.Fatalf("expected the %s adapter, got %s", name, adapter.Name())
+		}
+		closeFactor, incentive, err := adapter.LiquidationParams(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if closeFactor.Cmp(comptroller.CloseFactor) != 0 || incentive.Cmp(comptroller.LiquidationIncentive) != 0 {
+			t.Fatalf("%s: expected the parameters of the comptroller, got %v and %v", name, closeFactor, incentive)
+		}
+	}
+
+	comptroller.Err = errors.New("execution reverted")
+	adapter, err := newCompoundProtocolAdapter(comptroller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := adapter.LiquidationParams(nil); !errors.Is(err, comptroller.Err) {
+		t.Fatalf("expected the comptroller error, got %v", err)
+	}
+
+	if _, err := newProtocolAdapter("aave", &Connection{}, comptroller); err == nil {
+		t.Fatal("expected an unknown adapter rejected")
+	}
+}
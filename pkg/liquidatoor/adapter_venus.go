@@ -0,0 +1,77 @@
+package liquidatoor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// venusProtocolAdapter supports Venus where liquidations go through
+// the Liquidator contract, which takes the borrowed vToken as an extra
+// parameter and splits the seized vTokens with the protocol treasury.
+type venusProtocolAdapter struct {
+	liquidator    common.Address
+	comptroller   Comptroller
+	liquidatorABI *abi.ABI
+}
+
+func newVenusProtocolAdapter(liquidator common.Address, comptroller Comptroller) (*venusProtocolAdapter, error) {
+	if liquidator == (common.Address{}) {
+		return nil, errors.New("VENUS_LIQUIDATOR_ADDRESS cannot be empty")
+	}
+
+	liquidatorABI, err := abis.VenusLiquidatorMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get venus liquidator ABI: %w", err)
+	}
+
+	return &venusProtocolAdapter{
+		liquidator:    liquidator,
+		comptroller:   comptroller,
+		liquidatorABI: liquidatorABI,
+	}, nil
+}
+
+func (a *venusProtocolAdapter) Name() string {
+	return VenusAdapter
+}
+
+func (a *venusProtocolAdapter) RepayCall(params RepayParams) (*RepayCall, error) {
+	data, err := a.liquidatorABI.Pack("liquidateBorrow", params.CTokenBorrowed, params.Borrower, params.RepayAmount, params.CTokenCollateral)
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack liquidateBorrow: %w", err)
+	}
+
+	value := new(big.Int)
+	if params.Native {
+		value = params.RepayAmount
+	}
+	return &RepayCall{To: a.liquidator, Value: value, Data: data}, nil
+}
+
+func (a *venusProtocolAdapter) DecodeSeize(receipt *types.Receipt) (*big.Int, error) {
+	event := a.liquidatorABI.Events["LiquidateBorrowedTokens"]
+	for _, log := range receipt.Logs {
+		if log.Address != a.liquidator || len(log.Topics) == 0 || log.Topics[0] != event.ID {
+			continue
+		}
+		out, err := event.Inputs.Unpack(log.Data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unpack LiquidateBorrowedTokens event: %w", err)
+		}
+		// The rest is seized for the treasury
+		return *abi.ConvertType(out[6], new(*big.Int)).(**big.Int), nil
+	}
+	return nil, errNoSeize
+}
+
+func (a *venusProtocolAdapter) LiquidationParams(opts *bind.CallOpts) (*big.Int, *big.Int, error) {
+	return readComptrollerLiquidationParams(opts, a.comptroller)
+}
@@ -0,0 +1,255 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/store"
+)
+
+// AlertState is the state of the alert of an underwater account.
+type AlertState string
+
+const (
+	// Underwater since the last check, about to be notified
+	AlertNew AlertState = "new"
+	// Notified, and renotified every interval while underwater
+	AlertActive AlertState = "active"
+	// Recovered or liquidated, notified once and forgotten
+	AlertResolved AlertState = "resolved"
+)
+
+const (
+	// Interval between notifications of an account still underwater by
+	// default
+	defaultAlertRenotifyInterval = time.Hour
+	// Interval between persisting changed alerts
+	alertsPersistInterval = time.Minute
+)
+
+// Liquidity an account must regain to resolve its alert by default, 10
+// USD in the units of getAccountLiquidity
+var defaultAlertResolveMargin = new(big.Int).Mul(big.NewInt(10), big.NewInt(1e18))
+
+// accountHealth is what a check found of an account that is not
+// underwater.
+type accountHealth int
+
+const (
+	// Its check failed
+	healthUnknown accountHealth = iota
+	healthy
+	notBorrowing
+)
+
+// Alert is the alert of an underwater account.
+type Alert struct {
+	Pool      common.Address `json:"pool"`
+	Account   common.Address `json:"account"`
+	State     AlertState     `json:"state"`
+	Shortfall *big.Int       `json:"shortfall,omitempty"`
+	Since     time.Time      `json:"since"`
+	Notified  time.Time      `json:"notified"`
+	// One of our executions succeeded since it became active
+	Liquidated bool `json:"liquidated,omitempty"`
+}
+
+// Alerts notifies, as warnings, when accounts go underwater, at most
+// once per interval while they stay underwater, and once when they
+// recover or are liquidated. An account only recovers once its
+// liquidity exceeds a margin, so accounts hovering around the
+// liquidation threshold do not flap. Comet reports no liquidity, so
+// its accounts recover as soon as they are no longer liquidatable.
+// Active alerts are persisted so restarts do not notify them again. It
+// is safe for concurrent use.
+type Alerts struct {
+	logger   Logger
+	path     string
+	renotify time.Duration
+	margin   *big.Int
+	// Renders shortfalls in USD, if set
+	fiat *fiatReporter
+	// Links the accounts to the explorer, if set
+	presenter *Presenter
+
+	lock   sync.Mutex
+	alerts map[jobKey]*Alert
+	dirty  bool
+}
+
+func newAlerts(logger Logger, path string, renotify time.Duration, margin *big.Int) *Alerts {
+	if renotify <= 0 {
+		renotify = defaultAlertRenotifyInterval
+	}
+	if margin == nil {
+		margin = defaultAlertResolveMargin
+	}
+	return &Alerts{logger: logger, path: path, renotify: renotify, margin: margin, alerts: make(map[jobKey]*Alert)}
+}
+
+// load reads the persisted alerts, if any.
+func (a *Alerts) load() error {
+	if a.path == "" {
+		return nil
+	}
+	var alerts []*Alert
+	ok, err := loadState(a.logger, "alerts", a.path, alertsVersion, &alerts)
+	if err != nil || !ok {
+		return err
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, alert := range alerts {
+		a.alerts[jobKey{pool: alert.Pool, account: alert.Account}] = alert
+	}
+	a.logger.Info(fmt.Sprintf("Loaded %d active alerts", len(alerts)), F("alerts", len(alerts)))
+	return nil
+}
+
+// observe updates the alerts of a pool with the accounts found
+// underwater by a check. health returns what the check found of the
+// other accounts, and their liquidity if known.
+func (a *Alerts) observe(pool common.Address, underwater []Borrower, health func(common.Address) (accountHealth, *big.Int)) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := time.Now()
+	seen := make(map[common.Address]bool, len(underwater))
+	for _, account := range underwater {
+		seen[account.Address] = true
+		key := jobKey{pool: pool, account: account.Address}
+		alert, ok := a.alerts[key]
+		if !ok {
+			alert = &Alert{Pool: pool, Account: account.Address, State: AlertNew, Since: now}
+			a.alerts[key] = alert
+		}
+		alert.Shortfall = account.Shortfall
+		switch {
+		case alert.State == AlertNew:
+			a.logger.Warn(fmt.Sprintf("Account %s is underwater%s in pool %s: %s", account.Address, a.formatShortfall(pool, alert.Shortfall), pool, a.presenter.AddressURL(account.Address)),
+				F("pool", pool), F("account", account.Address), F("alert", AlertNew), F("shortfall", alert.Shortfall))
+		case now.Sub(alert.Notified) >= a.renotify:
+			a.logger.Warn(fmt.Sprintf("Account %s is still underwater%s in pool %s since %s: %s", account.Address, a.formatShortfall(pool, alert.Shortfall), pool, alert.Since.Format(time.RFC3339), a.presenter.AddressURL(account.Address)),
+				F("pool", pool), F("account", account.Address), F("alert", AlertActive), F("shortfall", alert.Shortfall), F("since", alert.Since))
+		default:
+			continue
+		}
+		alert.State, alert.Notified = AlertActive, now
+		a.dirty = true
+	}
+
+	for key, alert := range a.alerts {
+		if key.pool != pool || seen[key.account] {
+			continue
+		}
+		status, liquidity := health(key.account)
+		var reason string
+		switch {
+		case status == healthUnknown:
+			continue
+		case alert.Liquidated:
+			reason = "liquidated"
+		case status == notBorrowing:
+			reason = "no longer borrowing"
+		case liquidity == nil || GTE(liquidity, a.margin):
+			reason = "recovered"
+		default:
+			// Within the margin of the threshold
+			continue
+		}
+		alert.State = AlertResolved
+		a.logger.Info(fmt.Sprintf("Account %s in pool %s is no longer underwater: %s after %v; %s", key.account, pool, reason, now.Sub(alert.Since).Round(time.Second), a.presenter.AddressURL(key.account)),
+			F("pool", pool), F("account", key.account), F("alert", AlertResolved), F("reason", reason))
+		delete(a.alerts, key)
+		a.dirty = true
+	}
+}
+
+func (a *Alerts) formatShortfall(pool common.Address, shortfall *big.Int) string {
+	if shortfall == nil {
+		return ""
+	}
+	return fmt.Sprintf(" by %s%s", formatValue(shortfall), formatUSD(a.fiat.valueUSD(pool, shortfall)))
+}
+
+// liquidated marks the alert of an account, if any, to resolve as
+// liquidated once it is no longer underwater.
+func (a *Alerts) liquidated(pool, account common.Address) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if alert, ok := a.alerts[jobKey{pool: pool, account: account}]; ok && !alert.Liquidated {
+		alert.Liquidated = true
+		a.dirty = true
+	}
+}
+
+// List returns the active alerts, by pool and account.
+func (a *Alerts) List() []Alert {
+	if a == nil {
+		return nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.listLocked()
+}
+
+func (a *Alerts) listLocked() []Alert {
+	list := make([]Alert, 0, len(a.alerts))
+	for _, alert := range a.alerts {
+		list = append(list, *alert)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if c := bytes.Compare(list[i].Pool[:], list[j].Pool[:]); c != 0 {
+			return c == -1
+		}
+		return bytes.Compare(list[i].Account[:], list[j].Account[:]) == -1
+	})
+	return list
+}
+
+// run persists changed alerts periodically until ctx is cancelled.
+func (a *Alerts) run(ctx context.Context) {
+	if a == nil || a.path == "" {
+		return
+	}
+	ticker := time.NewTicker(alertsPersistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.persist()
+		}
+	}
+}
+
+// persist writes the alerts, if changed since the last write.
+func (a *Alerts) persist() {
+	if a == nil || a.path == "" {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if !a.dirty {
+		return
+	}
+	if err := store.Write(a.path, alertsVersion, a.listLocked()); err != nil {
+		a.logger.Error(fmt.Sprintf("Failed to persist alerts: %v", err), F("err", err))
+		return
+	}
+	a.dirty = false
+}
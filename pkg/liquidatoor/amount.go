@@ -0,0 +1,271 @@
+package liquidatoor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Decimals of values in the oracle's unit of account, and of the
+// native token
+const valueDecimals = 18
+
+// Symbols of values in the oracle's unit of account, and of the native
+// token of any chain, in quantities
+const (
+	unitOfAccountSymbol = "USD"
+	nativeAliasSymbol   = "NATIVE"
+)
+
+// Amount is an amount of a token, or a value, in its smallest unit
+// along with its decimals. Operations never modify their operands and
+// a nil value is zero.
+type Amount struct {
+	Value    *big.Int
+	Decimals uint8
+}
+
+// NewAmount returns the amount of value smallest units of a token of
+// decimals.
+func NewAmount(value *big.Int, decimals uint8) Amount {
+	return Amount{Value: value, Decimals: decimals}
+}
+
+// ParseAmount parses a decimal amount, eg., 1.5, of a token of
+// decimals. Amounts more precise than the token are rejected rather
+// than truncated.
+func ParseAmount(s string, decimals uint8) (Amount, error) {
+	s = strings.TrimSpace(s)
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return Amount{}, fmt.Errorf("invalid amount %q", sign+s)
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > int(decimals) {
+		return Amount{}, fmt.Errorf("amount %q has more than %d decimals", sign+s, decimals)
+	}
+	digits := whole + fraction + strings.Repeat("0", int(decimals)-len(fraction))
+	value, ok := new(big.Int).SetString(sign+digits, 10)
+	if !ok {
+		return Amount{}, fmt.Errorf("invalid amount %q", sign+s)
+	}
+	return Amount{Value: value, Decimals: decimals}, nil
+}
+
+// ParseQuantity parses an amount followed by the symbol of its token,
+// eg., 250.50 USDC, with the decimals units has for the symbol, or a
+// plain amount, eg., 250.50, with decimals. Symbols match units
+// case-insensitively; the matched symbol is returned, empty for plain
+// amounts.
+func ParseQuantity(s string, decimals uint8, units map[string]uint8) (Amount, string, error) {
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		amount, err := ParseAmount(fields[0], decimals)
+		return amount, "", err
+	case 2:
+		symbol := strings.ToUpper(fields[1])
+		decimals, ok := units[symbol]
+		if !ok {
+			symbols := make([]string, 0, len(units))
+			for symbol := range units {
+				symbols = append(symbols, symbol)
+			}
+			sort.Strings(symbols)
+			return Amount{}, "", fmt.Errorf("unknown symbol %q, expected %s", fields[1], strings.Join(symbols, " or "))
+		}
+		amount, err := ParseAmount(fields[0], decimals)
+		if err != nil {
+			return Amount{}, "", err
+		}
+		return amount, symbol, nil
+	}
+	return Amount{}, "", fmt.Errorf("invalid amount %q", s)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(n uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (a Amount) value() *big.Int {
+	return orZero(a.Value)
+}
+
+func (a Amount) Sign() int {
+	return a.value().Sign()
+}
+
+func (a Amount) check(b Amount) error {
+	if a.Decimals != b.Decimals {
+		return fmt.Errorf("%w: %d and %d", ErrDecimalsMismatch, a.Decimals, b.Decimals)
+	}
+	return nil
+}
+
+// Add returns a+b, which must have the same decimals.
+func (a Amount) Add(b Amount) (Amount, error) {
+	if err := a.check(b); err != nil {
+		return Amount{}, err
+	}
+	return Amount{Value: new(big.Int).Add(a.value(), b.value()), Decimals: a.Decimals}, nil
+}
+
+// Sub returns a-b, which must have the same decimals.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if err := a.check(b); err != nil {
+		return Amount{}, err
+	}
+	return Amount{Value: new(big.Int).Sub(a.value(), b.value()), Decimals: a.Decimals}, nil
+}
+
+// Cmp compares a and b, which must have the same decimals.
+func (a Amount) Cmp(b Amount) (int, error) {
+	if err := a.check(b); err != nil {
+		return 0, err
+	}
+	return a.value().Cmp(b.value()), nil
+}
+
+func (a Amount) IsPositive() bool {
+	return IsPositive(a.Value)
+}
+
+func (a Amount) IsZero() bool {
+	return IsZero(a.Value)
+}
+
+// GT reports whether a > b, which must have the same decimals.
+func (a Amount) GT(b Amount) (bool, error) {
+	c, err := a.Cmp(b)
+	return c == 1, err
+}
+
+// GTE reports whether a >= b, which must have the same decimals.
+func (a Amount) GTE(b Amount) (bool, error) {
+	c, err := a.Cmp(b)
+	return c >= 0, err
+}
+
+// Min returns the smaller of a and b, which must have the same
+// decimals.
+func (a Amount) Min(b Amount) (Amount, error) {
+	if err := a.check(b); err != nil {
+		return Amount{}, err
+	}
+	return Amount{Value: Min(a.value(), b.value()), Decimals: a.Decimals}, nil
+}
+
+// Max returns the larger of a and b, which must have the same
+// decimals.
+func (a Amount) Max(b Amount) (Amount, error) {
+	if err := a.check(b); err != nil {
+		return Amount{}, err
+	}
+	return Amount{Value: Max(a.value(), b.value()), Decimals: a.Decimals}, nil
+}
+
+// MulPrice returns the value of the amount at an oracle price, scaled
+// by 1e(36-decimals) as Compound oracles do, in the unit of account,
+// truncated.
+func (a Amount) MulPrice(mantissa *big.Int) Amount {
+	return Amount{Value: valueInto(new(big.Int), mantissa, a.value()), Decimals: valueDecimals}
+}
+
+// Convert returns the amount with other decimals, truncating towards
+// zero when there are fewer.
+func (a Amount) Convert(decimals uint8) Amount {
+	switch {
+	case decimals > a.Decimals:
+		return Amount{Value: new(big.Int).Mul(a.value(), pow10(decimals-a.Decimals)), Decimals: decimals}
+	case decimals < a.Decimals:
+		return Amount{Value: new(big.Int).Quo(a.value(), pow10(a.Decimals-decimals)), Decimals: decimals}
+	}
+	return Amount{Value: new(big.Int).Set(a.value()), Decimals: decimals}
+}
+
+// String formats the amount in full precision, without trailing zeros.
+func (a Amount) String() string {
+	whole, fraction := new(big.Int).QuoRem(new(big.Int).Abs(a.value()), pow10(a.Decimals), new(big.Int))
+	s := whole.String()
+	if fraction.Sign() != 0 {
+		digits := fraction.String()
+		s += "." + strings.TrimRight(strings.Repeat("0", int(a.Decimals)-len(digits))+digits, "0")
+	}
+	if a.Sign() == -1 {
+		s = "-" + s
+	}
+	return s
+}
+
+// formatValue formats a value in the unit of account, or an amount of
+// the native token.
+func formatValue(value *big.Int) string {
+	if value == nil {
+		return "<nil>"
+	}
+	return NewAmount(value, valueDecimals).String()
+}
+
+// MarshalJSON encodes the amount as its value in the smallest unit, a
+// decimal string, and its decimals.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Value    *decimalInt `json:"value"`
+		Decimals uint8       `json:"decimals"`
+	}{decimal(a.value()), a.Decimals})
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Value    *decimalInt `json:"value"`
+		Decimals uint8       `json:"decimals"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*a = Amount{Value: v.Value.int(), Decimals: v.Decimals}
+	return nil
+}
+
+// decimalInt is a big.Int encoded in JSON as a decimal string, which
+// unlike a JSON number survives decoders reading numbers as float64.
+// Numbers decode too, as written before.
+type decimalInt big.Int
+
+func decimal(x *big.Int) *decimalInt {
+	return (*decimalInt)(x)
+}
+
+func (d *decimalInt) int() *big.Int {
+	return (*big.Int)(d)
+}
+
+func (d *decimalInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.int().String())
+}
+
+func (d *decimalInt) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if _, ok := d.int().SetString(text, 10); !ok {
+		return fmt.Errorf("invalid integer %s", data)
+	}
+	return nil
+}
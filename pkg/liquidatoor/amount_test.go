@@ -0,0 +1,189 @@
+package liquidatoor
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// largeInts are integers a float64 cannot represent exactly.
+var largeInts = []*big.Int{
+	new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 53), big.NewInt(1)),
+	new(big.Int).Neg(new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 53), big.NewInt(1))),
+	// 123456789.123456789123456789 of an 18-decimal token
+	mustInt("123456789123456789123456789"),
+	new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)),
+}
+
+func mustInt(s string) *big.Int {
+	x, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid integer " + s)
+	}
+	return x
+}
+
+func TestAmountJSONRoundTrip(t *testing.T) {
+	for _, value := range largeInts {
+		for _, decimals := range []uint8{0, 6, 18} {
+			data, err := json.Marshal(NewAmount(value, decimals))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Decoders reading numbers as float64 still get the exact
+			// value
+			var generic map[string]interface{}
+			if err := json.Unmarshal(data, &generic); err != nil {
+				t.Fatal(err)
+			}
+			if s, ok := generic["value"].(string); !ok || s != value.String() {
+				t.Fatalf("expected value %v encoded as a string, got %s", value, data)
+			}
+			var decoded Amount
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Value.Cmp(value) != 0 || decoded.Decimals != decimals {
+				t.Fatalf("expected %v with %d decimals, got %v with %d decimals", value, decimals, decoded.Value, decoded.Decimals)
+			}
+		}
+	}
+}
+
+func TestDecimalIntDecodesNumbers(t *testing.T) {
+	for _, value := range largeInts {
+		// Written as numbers before they were strings
+		for _, data := range []string{value.String(), `"` + value.String() + `"`} {
+			var decoded decimalInt
+			if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.int().Cmp(value) != 0 {
+				t.Fatalf("expected %v, got %v", value, decoded.int())
+			}
+		}
+	}
+	var decoded decimalInt
+	if err := json.Unmarshal([]byte(`"1e18"`), &decoded); err == nil {
+		t.Fatal("expected an error decoding a float")
+	}
+}
+
+// randomAmount returns an amount of random decimals and sign, up to 40
+// digits long, zero or nil sometimes.
+func randomAmount(r *rand.Rand) Amount {
+	decimals := []uint8{0, 6, 8, 18, uint8(r.Intn(30))}[r.Intn(5)]
+	switch r.Intn(10) {
+	case 0:
+		return Amount{Decimals: decimals}
+	case 1:
+		return NewAmount(new(big.Int), decimals)
+	}
+	value := randomInt(r, 40)
+	if r.Intn(2) == 0 {
+		value.Neg(value)
+	}
+	return NewAmount(value, decimals)
+}
+
+// rat is the amount in whole tokens, exactly.
+func rat(a Amount) *big.Rat {
+	return new(big.Rat).SetFrac(orZero(a.Value), pow10(a.Decimals))
+}
+
+// smallestUnits is the value of whole tokens in units of decimals,
+// truncated towards zero.
+func smallestUnits(r *big.Rat, decimals uint8) *big.Int {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(decimals)))
+	return new(big.Int).Quo(scaled.Num(), scaled.Denom())
+}
+
+func checkAmount(t *testing.T, op string, a Amount, expected *big.Int, decimals uint8) {
+	t.Helper()
+	if a.Decimals != decimals || a.value().Cmp(expected) != 0 {
+		t.Fatalf("%s: expected %v with %d decimals, got %v with %d decimals", op, expected, decimals, a.Value, a.Decimals)
+	}
+}
+
+// FuzzAmountMatchesRat checks the operations of amounts against the
+// same operations on exact rationals.
+func FuzzAmountMatchesRat(f *testing.F) {
+	for _, seed := range []int64{0, 1, 2, 42, 1 << 40} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+		for i := 0; i < 200; i++ {
+			a, b := randomAmount(r), randomAmount(r)
+			if r.Intn(2) == 0 {
+				b.Decimals = a.Decimals
+			}
+			ra, rb := rat(a), rat(b)
+
+			// Formatting is exact, and parses back
+			expected := ra.FloatString(int(a.Decimals))
+			if a.Decimals > 0 {
+				expected = strings.TrimRight(strings.TrimRight(expected, "0"), ".")
+			}
+			if s := a.String(); s != expected {
+				t.Fatalf("expected %v formatted as %s, got %s", a.Value, expected, s)
+			}
+			parsed, err := ParseAmount(a.String(), a.Decimals)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkAmount(t, "parse", parsed, a.value(), a.Decimals)
+
+			// Converting truncates towards zero
+			decimals := uint8(r.Intn(30))
+			checkAmount(t, "convert", a.Convert(decimals), smallestUnits(ra, decimals), decimals)
+
+			// Prices are scaled by 1e(36-decimals), and values truncated
+			// to 18 decimals
+			price := randomInt(r, 30)
+			unitPrice := new(big.Rat).SetFrac(price, pow10(36-a.Decimals))
+			checkAmount(t, "value", a.MulPrice(price), smallestUnits(new(big.Rat).Mul(ra, unitPrice), valueDecimals), valueDecimals)
+
+			if a.Decimals != b.Decimals {
+				if _, err := a.Add(b); !errors.Is(err, ErrDecimalsMismatch) {
+					t.Fatalf("expected adding %d and %d decimals to fail, got %v", a.Decimals, b.Decimals, err)
+				}
+				if _, err := a.Cmp(b); !errors.Is(err, ErrDecimalsMismatch) {
+					t.Fatalf("expected comparing %d and %d decimals to fail, got %v", a.Decimals, b.Decimals, err)
+				}
+				continue
+			}
+			sum, err := a.Add(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkAmount(t, "add", sum, smallestUnits(new(big.Rat).Add(ra, rb), a.Decimals), a.Decimals)
+			difference, err := a.Sub(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkAmount(t, "sub", difference, smallestUnits(new(big.Rat).Sub(ra, rb), a.Decimals), a.Decimals)
+
+			c, err := a.Cmp(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := ra.Cmp(rb); c != expected {
+				t.Fatalf("expected %v and %v to compare %d, got %d", a.Value, b.Value, expected, c)
+			}
+			min, max := a, b
+			if c == 1 {
+				min, max = b, a
+			}
+			if result, err := a.Min(b); err != nil || result.value().Cmp(min.value()) != 0 {
+				t.Fatalf("expected the min of %v and %v to be %v, got %v, %v", a.Value, b.Value, min.value(), result.Value, err)
+			}
+			if result, err := a.Max(b); err != nil || result.value().Cmp(max.value()) != 0 {
+				t.Fatalf("expected the max of %v and %v to be %v, got %v, %v", a.Value, b.Value, max.value(), result.Value, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AccountHandling is a handling hint of an annotated account.
+type AccountHandling string
+
+const (
+	// Liquidated as any other account
+	HandlingDefault AccountHandling = ""
+	// Executed before other liquidations
+	HandlingPriority AccountHandling = "priority"
+	// Alerted on when liquidatable but never executed
+	HandlingAlertOnly AccountHandling = "alert-only"
+	// Never liquidated
+	HandlingNever AccountHandling = "never"
+)
+
+// Most conservative last
+var handlingOrder = map[AccountHandling]int{
+	HandlingPriority:  0,
+	HandlingDefault:   1,
+	HandlingAlertOnly: 2,
+	HandlingNever:     3,
+}
+
+// Interval between checks of the annotations file for changes
+const annotationsReloadInterval = 10 * time.Second
+
+// Ranks priority jobs above any estimated profit
+var priorityRank = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// Annotation is what we know about an account, eg., that it is the
+// protocol treasury.
+type Annotation struct {
+	Address  common.Address  `json:"address"`
+	Label    string          `json:"label"`
+	Handling AccountHandling `json:"handling,omitempty"`
+}
+
+// Annotations label accounts wherever they are rendered, see Presenter,
+// and enforce their handling hints when planning. They are loaded from
+// a JSON list of annotations, reloaded when the file changes, and from
+// the deny-list of the remote config, if any. An account annotated
+// more than once gets its most conservative handling. It is safe for
+// concurrent use.
+type Annotations struct {
+	logger Logger
+	path   string
+
+	lock        sync.RWMutex
+	annotations map[common.Address]Annotation
+	// Of the file and of the remote config
+	file    []Annotation
+	remote  []Annotation
+	modTime time.Time
+}
+
+func newAnnotations(logger Logger) *Annotations {
+	return &Annotations{logger: logger, annotations: make(map[common.Address]Annotation)}
+}
+
+// load reads the annotations at path, replacing the loaded ones.
+func (a *Annotations) load(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("cannot read annotations: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read annotations: %w", err)
+	}
+	var list []Annotation
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("cannot decode annotations %s: %w", path, err)
+	}
+	for _, annotation := range list {
+		if _, ok := handlingOrder[annotation.Handling]; !ok {
+			return fmt.Errorf("%w: unknown handling %q of account %s", ErrInvalidConfig, annotation.Handling, annotation.Address)
+		}
+	}
+
+	a.lock.Lock()
+	a.path, a.file, a.modTime = path, list, info.ModTime()
+	a.resolveLocked()
+	count := len(a.annotations)
+	a.lock.Unlock()
+	a.logger.Info(fmt.Sprintf("Loaded %d account annotations from %s", count, path), F("path", path), F("annotations", count))
+	return nil
+}
+
+// applyRemote replaces the annotations of the remote config.
+func (a *Annotations) applyRemote(list []Annotation) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.remote = list
+	a.resolveLocked()
+}
+
+// resolveLocked merges the annotations of the file and of the remote
+// config. Callers hold the lock.
+func (a *Annotations) resolveLocked() {
+	annotations := make(map[common.Address]Annotation, len(a.file)+len(a.remote))
+	for _, list := range [][]Annotation{a.file, a.remote} {
+		for _, annotation := range list {
+			existing, ok := annotations[annotation.Address]
+			if !ok {
+				annotations[annotation.Address] = annotation
+				continue
+			}
+			resolved := existing
+			if handlingOrder[annotation.Handling] > handlingOrder[existing.Handling] {
+				resolved = annotation
+			}
+			if existing.Handling != annotation.Handling {
+				a.logger.Warn(fmt.Sprintf("Account %s is annotated both %q and %q; handling it as %q", annotation.Address, existing.Handling, annotation.Handling, resolved.Handling),
+					F("account", annotation.Address), F("handling", resolved.Handling))
+			}
+			if existing.Label != annotation.Label {
+				resolved.Label = existing.Label + "; " + annotation.Label
+			}
+			annotations[annotation.Address] = resolved
+		}
+	}
+	a.annotations = annotations
+}
+
+// run reloads the annotations whenever their file changes, until ctx
+// is cancelled. The loaded annotations are kept if reloading fails.
+func (a *Annotations) run(ctx context.Context) {
+	ticker := time.NewTicker(annotationsReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		a.lock.RLock()
+		path, modTime := a.path, a.modTime
+		a.lock.RUnlock()
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err == nil && info.ModTime().Equal(modTime) {
+			continue
+		}
+		if err == nil {
+			err = a.load(path)
+		}
+		if err != nil {
+			a.logger.Error(fmt.Sprintf("Failed to reload annotations; keeping the loaded ones: %v", err), F("path", path), F("err", err))
+		}
+	}
+}
+
+// Get returns the annotation of an account, if any.
+func (a *Annotations) Get(account common.Address) (Annotation, bool) {
+	if a == nil {
+		return Annotation{}, false
+	}
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	annotation, ok := a.annotations[account]
+	return annotation, ok
+}
+
+// annotation returns a copy of the annotation of an account, or nil.
+func (a *Annotations) annotation(account common.Address) *Annotation {
+	annotation, ok := a.Get(account)
+	if !ok {
+		return nil
+	}
+	return &annotation
+}
+
+// List returns every annotation, by address.
+func (a *Annotations) List() []Annotation {
+	if a == nil {
+		return nil
+	}
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	list := make([]Annotation, 0, len(a.annotations))
+	for _, annotation := range a.annotations {
+		list = append(list, annotation)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i].Address[:], list[j].Address[:]) == -1
+	})
+	return list
+}
+
+// check returns ErrAnnotated if the handling of an account forbids
+// executing its liquidation, alerting on alert-only accounts.
+func (a *Annotations) check(pool, account common.Address) error {
+	annotation, ok := a.Get(account)
+	if !ok {
+		return nil
+	}
+	switch annotation.Handling {
+	case HandlingNever:
+		return fmt.Errorf("%w: %s is never liquidated", ErrAnnotated, annotation.Label)
+	case HandlingAlertOnly:
+		a.logger.Warn(fmt.Sprintf("Account %s is liquidatable in pool %s; alerting only", account, pool), F("pool", pool), F("account", account))
+		return fmt.Errorf("%w: %s is alert-only", ErrAnnotated, annotation.Label)
+	}
+	return nil
+}
+
+// rank ranks priority accounts first.
+func (a *Annotations) rank(account common.Address, rank *big.Int) *big.Int {
+	if annotation, ok := a.Get(account); ok && annotation.Handling == HandlingPriority {
+		return new(big.Int).Add(rank, priorityRank)
+	}
+	return rank
+}
@@ -0,0 +1,294 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// serve answers on address until ctx is cancelled:
+//
+//	/ready                answers 200 once ready and 503 until then,
+//	                      listing the pools holding liquidations and
+//	                      the accounts cooling down
+//	/account/{address}    the positions and health of an account in
+//	                      every Compound pool of manager, as JSON
+//	/pool                 the risk of every Compound pool of manager,
+//	                      as of its last check, as JSON
+//	/pool/{address}       the risk of one of them
+//	/cooldowns            the active cooldowns, as JSON
+//	/cooldowns/{address}  clears the cooldowns of an account on DELETE
+//	/governance           the governance changes every pool holds
+//	                      liquidations for, as JSON
+//	/governance/{address} acknowledges the changes of a pool on POST
+func (c *Connection) serve(ctx context.Context, address string, manager *PoolManager) {
+	if address == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		c.serveReady(w, r, manager)
+	})
+	mux.HandleFunc("/account/", func(w http.ResponseWriter, r *http.Request) {
+		c.serveAccount(w, r, manager)
+	})
+	mux.HandleFunc("/pool", func(w http.ResponseWriter, r *http.Request) {
+		c.servePools(w, r, manager)
+	})
+	mux.HandleFunc("/pool/", func(w http.ResponseWriter, r *http.Request) {
+		c.servePools(w, r, manager)
+	})
+	mux.HandleFunc("/cooldowns", c.serveCooldowns)
+	mux.HandleFunc("/cooldowns/", c.serveCooldowns)
+	mux.HandleFunc("/governance", func(w http.ResponseWriter, r *http.Request) {
+		c.serveGovernance(w, r, manager)
+	})
+	mux.HandleFunc("/governance/", func(w http.ResponseWriter, r *http.Request) {
+		c.serveGovernance(w, r, manager)
+	})
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		c.logger.Error(fmt.Sprintf("Cannot serve on %s: %v", address, err), F("address", address), F("err", err))
+		return
+	}
+	c.logger.Info(fmt.Sprintf("Serving readiness on %s/ready, accounts on %s/account/{address}, pools on %s/pool, cooldowns on %s/cooldowns and governance on %s/governance", listener.Addr(), listener.Addr(), listener.Addr(), listener.Addr(), listener.Addr()), F("address", listener.Addr()))
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		c.logger.Error(fmt.Sprintf("Stopped serving: %v", err), F("address", address), F("err", err))
+	}
+}
+
+// serveReady answers readiness probes, followed by the pools holding
+// liquidations and the accounts cooling down, so operators see why
+// they are not liquidated.
+func (c *Connection) serveReady(w http.ResponseWriter, r *http.Request, manager *PoolManager) {
+	c.readiness.handle(w, r)
+	for _, held := range governanceHolds(manager) {
+		for _, change := range held.Changes {
+			fmt.Fprintf(w, "pool %s holding liquidations until acknowledged: %s\n", held.Pool, change)
+		}
+	}
+	for _, cooldown := range c.cooldowns.List() {
+		fmt.Fprintf(w, "account %s of pool %s cooling down until %s after %d %s failures: %s\n", cooldown.Account, cooldown.Pool,
+			cooldown.Until.Format(time.RFC3339), cooldown.Failures, cooldown.Class, cooldown.Err)
+	}
+}
+
+// serveCooldowns answers the active cooldowns, or clears those of the
+// account named by the path.
+func (c *Connection) serveCooldowns(w http.ResponseWriter, r *http.Request) {
+	hex := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/cooldowns"), "/")
+	if hex == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.cooldowns.List()); err != nil {
+			c.logger.Warn(fmt.Sprintf("Cannot write cooldowns: %v", err), F("err", err))
+		}
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// The zero address would clear every account
+	if !common.IsHexAddress(hex) || common.HexToAddress(hex) == (common.Address{}) {
+		http.Error(w, fmt.Sprintf("invalid address %q", hex), http.StatusBadRequest)
+		return
+	}
+	cleared := c.cooldowns.Clear(common.HexToAddress(hex))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ClearedCooldowns{Cleared: cleared}); err != nil {
+		c.logger.Warn(fmt.Sprintf("Cannot write cleared cooldowns: %v", err), F("err", err))
+	}
+}
+
+// ClearedCooldowns answers clearing the cooldowns of an account.
+type ClearedCooldowns struct {
+	Cleared int `json:"cleared"`
+}
+
+// GovernanceHold holds the liquidations of a pool until its governance
+// changes are acknowledged.
+type GovernanceHold struct {
+	Pool    common.Address `json:"pool"`
+	Changes []string       `json:"changes"`
+}
+
+// AcknowledgedChanges answers acknowledging the governance changes of
+// a pool.
+type AcknowledgedChanges struct {
+	Acknowledged int `json:"acknowledged"`
+}
+
+// governanceHolds returns the pools of manager holding liquidations.
+func governanceHolds(manager *PoolManager) []GovernanceHold {
+	holds := make([]GovernanceHold, 0)
+	for _, l := range manager.liquidatoors() {
+		if changes := l.governance.held(); len(changes) > 0 {
+			holds = append(holds, GovernanceHold{Pool: l.comptrollerAddress, Changes: changes})
+		}
+	}
+	return holds
+}
+
+// serveGovernance answers the pools holding liquidations, or
+// acknowledges the changes of the pool named by the path.
+func (c *Connection) serveGovernance(w http.ResponseWriter, r *http.Request, manager *PoolManager) {
+	hex := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/governance"), "/")
+	var v interface{}
+	if hex == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		v = governanceHolds(manager)
+	} else {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !common.IsHexAddress(hex) {
+			http.Error(w, fmt.Sprintf("invalid address %q", hex), http.StatusBadRequest)
+			return
+		}
+		pool := common.HexToAddress(hex)
+		var l *Liquidatoor
+		for _, monitored := range manager.liquidatoors() {
+			if monitored.comptrollerAddress == pool {
+				l = monitored
+			}
+		}
+		if l == nil {
+			http.Error(w, fmt.Sprintf("no pool %s", pool.Hex()), http.StatusNotFound)
+			return
+		}
+		v = AcknowledgedChanges{Acknowledged: l.AcknowledgeGovernanceChange()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		c.logger.Warn(fmt.Sprintf("Cannot write governance: %v", err), F("err", err))
+	}
+}
+
+// serveAccount answers the report of the account named by the path.
+// Pools the account cannot be read in are reported with their error,
+// so one failing pool does not hide the others.
+func (c *Connection) serveAccount(w http.ResponseWriter, r *http.Request, manager *PoolManager) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hex := strings.TrimPrefix(r.URL.Path, "/account/")
+	if !common.IsHexAddress(hex) {
+		http.Error(w, fmt.Sprintf("invalid address %q", hex), http.StatusBadRequest)
+		return
+	}
+	account := common.HexToAddress(hex)
+	report := c.accountReport(r.Context(), account, manager.liquidatoors())
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		c.logger.Warn(fmt.Sprintf("Cannot write account %s: %v", account, err), F("account", account), F("err", err))
+	}
+}
+
+// accountReport reads account in every pool of liquidatoors. Pools the
+// account cannot be read in are reported with their error, so one
+// failing pool does not hide the others.
+func (c *Connection) accountReport(ctx context.Context, account common.Address, liquidatoors []*Liquidatoor) AccountReport {
+	report := AccountReport{Account: account, Label: c.presenter.Label(account), URL: c.presenter.AddressURL(account), Pools: make([]AccountPool, 0, len(liquidatoors))}
+	if annotation, ok := c.annotations.Get(account); ok {
+		report.Annotation = &annotation
+	}
+	for _, l := range liquidatoors {
+		pool, err := l.Account(ctx, account)
+		if err != nil {
+			c.logger.Warn(fmt.Sprintf("Cannot read account %s: %v", account, err), F("pool", l.comptrollerAddress), F("account", account), F("err", err))
+			pool = AccountPool{Pool: l.comptrollerAddress, URL: c.presenter.AddressURL(l.comptrollerAddress), Protocol: l.adapter.Name(), Markets: make([]AccountMarket, 0), Sensitivities: make([]PriceSensitivity, 0), Err: err.Error()}
+		}
+		report.Pools = append(report.Pools, pool)
+	}
+	return report
+}
+
+// Account reads account in every configured Compound pool, once. Pools
+// that cannot be instantiated fail the report; the market state is read
+// first for the sensitivities to weigh collateral.
+func (c *Connection) Account(ctx context.Context, account common.Address) (*AccountReport, error) {
+	if len(c.config.Comptrollers) == 0 {
+		return nil, fmt.Errorf("%w: COMPTROLLER_ADDRESS cannot be empty", ErrInvalidConfig)
+	}
+	liquidatoors := make([]*Liquidatoor, 0, len(c.config.Comptrollers))
+	for _, comptroller := range c.config.Comptrollers {
+		l, err := c.NewLiquidatoor(ctx, comptroller)
+		if err != nil {
+			return nil, fmt.Errorf("cannot instantiate pool %s: %w", comptroller, err)
+		}
+		if _, err := l.marketState(ctx, nil); err != nil {
+			c.logger.Warn(fmt.Sprintf("Cannot get market state, collateral factors are unknown: %v", err), F("pool", comptroller), F("err", err))
+		}
+		liquidatoors = append(liquidatoors, l)
+	}
+	report := c.accountReport(ctx, account, liquidatoors)
+	return &report, nil
+}
+
+// servePools answers the risk of every pool, or of the pool named by
+// the path. Pools without a completed check are left out.
+func (c *Connection) servePools(w http.ResponseWriter, r *http.Request, manager *PoolManager) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var pool *common.Address
+	if hex := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/pool"), "/"); hex != "" {
+		if !common.IsHexAddress(hex) {
+			http.Error(w, fmt.Sprintf("invalid address %q", hex), http.StatusBadRequest)
+			return
+		}
+		address := common.HexToAddress(hex)
+		pool = &address
+	}
+
+	risks := make([]*PoolRisk, 0)
+	for _, l := range manager.liquidatoors() {
+		if pool != nil && l.comptrollerAddress != *pool {
+			continue
+		}
+		if risk := l.risk.latest(); risk != nil {
+			risks = append(risks, risk)
+		}
+	}
+
+	var v interface{} = risks
+	if pool != nil {
+		if len(risks) == 0 {
+			http.Error(w, fmt.Sprintf("no risk of pool %s", pool.Hex()), http.StatusNotFound)
+			return
+		}
+		v = risks[0]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		c.logger.Warn(fmt.Sprintf("Cannot write pool risk: %v", err), F("err", err))
+	}
+}
@@ -0,0 +1,133 @@
+package liquidatoor
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestServeCooldowns(t *testing.T) {
+	c := &Connection{logger: quietLogger(), readiness: newReadiness(quietLogger()), cooldowns: newCooldowns(quietLogger(), time.Second)}
+	pool, other := common.HexToAddress("0xc0"), common.HexToAddress("0xc1")
+	alice, bob := common.HexToAddress("0xa"), common.HexToAddress("0xb")
+	now := time.Now()
+	c.cooldowns.record(pool, alice, errors.New("node down"), now)
+	c.cooldowns.record(other, alice, ErrSimulationReverted, now)
+	c.cooldowns.record(pool, bob, errors.New("node down"), now)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c.serveCooldowns(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	list := func() []Cooldown {
+		t.Helper()
+		w := serve(http.MethodGet, "/cooldowns")
+		var cooldowns []Cooldown
+		if err := json.NewDecoder(w.Body).Decode(&cooldowns); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("expected the cooldowns, got %d, %v", w.Code, err)
+		}
+		return cooldowns
+	}
+	if got := list(); len(got) != 3 || got[2].Account != alice || got[2].Class != FailureStructural {
+		t.Fatalf("expected 3 cooldowns, the structural one last, got %+v", got)
+	}
+	w := httptest.NewRecorder()
+	c.serveReady(w, httptest.NewRequest(http.MethodGet, "/ready", nil), NewPoolManager(c))
+	if w.Code != http.StatusServiceUnavailable || strings.Count(w.Body.String(), "cooling down") != 3 {
+		t.Fatalf("expected the status to list the cooldowns, got %d: %s", w.Code, w.Body)
+	}
+
+	// Clearing an account clears it in every pool
+	w = serve(http.MethodDelete, "/cooldowns/"+alice.Hex())
+	var cleared ClearedCooldowns
+	if err := json.NewDecoder(w.Body).Decode(&cleared); w.Code != http.StatusOK || err != nil || cleared.Cleared != 2 {
+		t.Fatalf("expected 2 cooldowns cleared, got %d, %+v, %v", w.Code, cleared, err)
+	}
+	if got := list(); len(got) != 1 || got[0].Account != bob {
+		t.Fatalf("expected the cooldown of bob left, got %+v", got)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodDelete, "/cooldowns", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/cooldowns/" + bob.Hex(), http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/cooldowns/bob", http.StatusBadRequest},
+		// Not every account
+		{http.MethodDelete, "/cooldowns/" + common.Address{}.Hex(), http.StatusBadRequest},
+	} {
+		if w := serve(tc.method, tc.path); w.Code != tc.code {
+			t.Fatalf("expected %s %s to answer %d, got %d", tc.method, tc.path, tc.code, w.Code)
+		}
+	}
+	if got := list(); len(got) != 1 {
+		t.Fatalf("expected the cooldown of bob left, got %+v", got)
+	}
+}
+
+func TestServeGovernance(t *testing.T) {
+	c := &Connection{logger: quietLogger(), readiness: newReadiness(quietLogger()), cooldowns: newCooldowns(quietLogger(), time.Second)}
+	manager := NewPoolManager(c)
+	pool, other := common.HexToAddress("0xc0"), common.HexToAddress("0xc1")
+	for _, address := range []common.Address{pool, other} {
+		manager.monitors[address] = &Liquidatoor{comptrollerAddress: address, governance: newGovernanceWatch(quietLogger(), address, nil, 1, true)}
+	}
+	governance := manager.monitors[pool].(*Liquidatoor).governance
+	governance.changed(big.NewInt(1), "admin", common.HexToAddress("0x1"), common.HexToAddress("0x2"))
+	governance.changed(big.NewInt(1), "pendingAdmin", common.Address{}, common.HexToAddress("0x3"))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c.serveGovernance(w, httptest.NewRequest(method, path, nil), manager)
+		return w
+	}
+	holds := func() []GovernanceHold {
+		t.Helper()
+		w := serve(http.MethodGet, "/governance")
+		var holds []GovernanceHold
+		if err := json.NewDecoder(w.Body).Decode(&holds); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("expected the holds, got %d, %v", w.Code, err)
+		}
+		return holds
+	}
+	if got := holds(); len(got) != 1 || got[0].Pool != pool || len(got[0].Changes) != 2 {
+		t.Fatalf("expected the pool held for 2 changes, got %+v", got)
+	}
+	w := httptest.NewRecorder()
+	c.serveReady(w, httptest.NewRequest(http.MethodGet, "/ready", nil), manager)
+	if strings.Count(w.Body.String(), "holding liquidations") != 2 {
+		t.Fatalf("expected the status to list the changes, got %s", w.Body)
+	}
+
+	w = serve(http.MethodPost, "/governance/"+pool.Hex())
+	var acknowledged AcknowledgedChanges
+	if err := json.NewDecoder(w.Body).Decode(&acknowledged); w.Code != http.StatusOK || err != nil || acknowledged.Acknowledged != 2 {
+		t.Fatalf("expected 2 changes acknowledged, got %d, %+v, %v", w.Code, acknowledged, err)
+	}
+	if got := holds(); len(got) != 0 {
+		t.Fatalf("expected no pool held, got %+v", got)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodPost, "/governance", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/governance/" + pool.Hex(), http.StatusMethodNotAllowed},
+		{http.MethodPost, "/governance/pool", http.StatusBadRequest},
+		{http.MethodPost, "/governance/" + common.HexToAddress("0xc2").Hex(), http.StatusNotFound},
+	} {
+		if w := serve(tc.method, tc.path); w.Code != tc.code {
+			t.Fatalf("expected %s %s to answer %d, got %d", tc.method, tc.path, tc.code, w.Code)
+		}
+	}
+}
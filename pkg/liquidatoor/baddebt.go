@@ -0,0 +1,133 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Value of the collateral an underwater account may hold and still be
+// bad debt, in the unit of account of the oracle scaled by 1e18
+var defaultBadDebtDust = big.NewInt(1e18)
+
+// MarketBadDebt are the borrows of the bad-debt accounts from a market:
+// what liquidating them cannot recover, in underlying and in the unit of
+// account of the oracle scaled by 1e18, nil if it cannot be priced.
+type MarketBadDebt struct {
+	Market   common.Address `json:"market"`
+	Symbol   string         `json:"symbol"`
+	Accounts int            `json:"accounts"`
+	Borrowed *big.Int       `json:"borrowed"`
+	Value    *big.Int       `json:"value"`
+}
+
+// MarshalJSON encodes the borrows as decimal strings.
+func (m MarketBadDebt) MarshalJSON() ([]byte, error) {
+	type badDebt MarketBadDebt
+	return json.Marshal(struct {
+		badDebt
+		Borrowed *decimalInt `json:"borrowed"`
+		Value    *decimalInt `json:"value"`
+	}{badDebt(m), decimal(m.Borrowed), decimal(m.Value)})
+}
+
+// seizableValue returns the value of the collateral of account, or nil
+// if a market it supplies cannot be priced.
+func seizableValue(s *Snapshot, account AccountPositions) *big.Int {
+	value := new(big.Int)
+	for _, position := range account.Positions {
+		if !IsPositive(position.Supplied) {
+			continue
+		}
+		market, ok := s.Markets[position.Market]
+		if !ok || market.Price == nil {
+			return nil
+		}
+		value.Add(value, market.Value(position.Supplied))
+	}
+	return value
+}
+
+// classifyCandidate returns the class of an underwater account: bad
+// debt if the value of its collateral is at most dust, so liquidating
+// it recovers nothing worth the gas, and AccountCandidate if actionable
+// or if its collateral cannot be priced.
+func classifyCandidate(s *Snapshot, account AccountPositions, dust *big.Int) AccountClass {
+	if class := classify(account); class != AccountCandidate {
+		return class
+	}
+	if value := seizableValue(s, account); value != nil && !GT(value, dust) {
+		return AccountBadDebt
+	}
+	return AccountCandidate
+}
+
+// classified records the class of account, from its positions last
+// read, and returns the class it had.
+func (r *riskMonitor) classified(account common.Address, class AccountClass) AccountClass {
+	if r == nil {
+		return class
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	old := AccountCandidate
+	if r.badDebt[account] {
+		old = AccountBadDebt
+	}
+	if class == AccountBadDebt {
+		r.badDebt[account] = true
+	} else {
+		delete(r.badDebt, account)
+	}
+	return old
+}
+
+// badDebtOf adds up the borrows of the bad-debt accounts by market,
+// priced at s, and returns them with their value and the number of
+// accounts. Callers hold the lock.
+func (r *riskMonitor) badDebtOf(s *Snapshot) ([]MarketBadDebt, *big.Int, int) {
+	markets := make(map[common.Address]*MarketBadDebt)
+	for account := range r.badDebt {
+		for _, position := range r.borrows[account] {
+			m, ok := markets[position.Market]
+			if !ok {
+				m = &MarketBadDebt{Market: position.Market, Borrowed: new(big.Int)}
+				markets[position.Market] = m
+			}
+			m.Accounts++
+			m.Borrowed.Add(m.Borrowed, position.Borrowed)
+		}
+	}
+	total := new(big.Int)
+	result := make([]MarketBadDebt, 0, len(markets))
+	for _, m := range markets {
+		if market, ok := s.Markets[m.Market]; ok {
+			m.Symbol = market.Symbol
+			if market.Price != nil {
+				m.Value = market.Value(m.Borrowed)
+				total.Add(total, m.Value)
+			}
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].Market[:], result[j].Market[:]) == -1
+	})
+	return result, total, len(r.badDebt)
+}
+
+// badDebtGrown records the bad debt of risk as reported and returns the
+// bad debt reported before it, and whether it grew since.
+func (r *riskMonitor) badDebtGrown(risk *PoolRisk) (*big.Int, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	last := orZero(r.badDebtReported)
+	r.badDebtReported = risk.BadDebt
+	return last, GT(risk.BadDebt, last)
+}
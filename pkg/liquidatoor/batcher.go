@@ -0,0 +1,171 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+const defaultBatchSize = 500
+
+// CallBatcher executes read calls in batches. Calls are split into
+// chunks of at most the configured batch size and every chunk is
+// executed against the same block, so results are consistent even
+// across chunks.
+type CallBatcher interface {
+	// Aggregate executes the provided calls at the block in opts, or
+	// the latest block if none is set. The results are in the same
+	// order as the calls.
+	Aggregate(opts *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error)
+}
+
+// aggregateFunc executes a single chunk of calls.
+type aggregateFunc func(opts *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error)
+
+// aggregateChunked pins the block and executes the calls in chunks,
+// at most concurrency of them at a time.
+func aggregateChunked(client headerReader, opts *bind.CallOpts, calls []abis.MulticallCall, batchSize, concurrency int, aggregate aggregateFunc) ([]CallResult, error) {
+	if len(calls) == 0 {
+		return []CallResult{}, nil
+	}
+
+	pinned := *opts
+	if pinned.Context == nil {
+		pinned.Context = context.Background()
+	}
+	if pinned.BlockNumber == nil && len(calls) > batchSize {
+		head, err := client.HeaderByNumber(pinned.Context, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get latest block: %w", err)
+		}
+		pinned.BlockNumber = head.Number
+	}
+
+	chunks := (len(calls) + batchSize - 1) / batchSize
+	chunkResults := make([][]CallResult, chunks)
+	g, gctx := errgroup.WithContext(pinned.Context)
+	g.SetLimit(concurrency)
+	pinned.Context = gctx
+	for i := 0; i < chunks; i++ {
+		i := i
+		start, end := i*batchSize, (i+1)*batchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		g.Go(func() error {
+			chunk, err := aggregate(&pinned, calls[start:end])
+			if err != nil {
+				return err
+			}
+			if len(chunk) != end-start {
+				return fmt.Errorf("expected %d results, got %d", end-start, len(chunk))
+			}
+			chunkResults[i] = chunk
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	results := make([]CallResult, 0, len(calls))
+	for _, chunk := range chunkResults {
+		results = append(results, chunk...)
+	}
+	return results, nil
+}
+
+// newCallBatcher returns a batcher reading through the read pool if
+// any, or the main connection otherwise. Batches of a legacy multicall
+// that revert are bisected by bisector.
+func newCallBatcher(ctx context.Context, logger Logger, client Backend, rpcClient *rpc.Client, readPool *ReadPool, multicallAddress common.Address, batchSize int, bisector *bisector) (CallBatcher, error) {
+	code, err := client.CodeAt(ctx, multicallAddress, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get code at multicall address %s: %w", multicallAddress, err)
+	}
+	if len(code) == 0 {
+		if rpcClient == nil && readPool == nil {
+			return nil, fmt.Errorf("no contract deployed at multicall address %s and no RPC client for JSON-RPC batches", multicallAddress)
+		}
+		logger.Warn(fmt.Sprintf("No contract deployed at multicall address %s, using JSON-RPC batches", multicallAddress), F("multicall", multicallAddress))
+		b := &rpcBatcher{client: client, batchSize: batchSize}
+		if readPool != nil {
+			for _, rc := range readPool.clients {
+				b.rpcClients = append(b.rpcClients, rc)
+			}
+		} else {
+			b.rpcClients = []batchCaller{rpcClient}
+		}
+		return b, nil
+	}
+
+	callers := []bind.ContractCaller{client}
+	if readPool != nil {
+		callers = make([]bind.ContractCaller, 0, readPool.Size())
+		for _, rc := range readPool.clients {
+			callers = append(callers, rc)
+		}
+	}
+	return newMulticaller(ctx, logger, client, callers, multicallAddress, batchSize, bisector)
+}
+
+type batchCaller interface {
+	BatchCallContext(ctx context.Context, elems []rpc.BatchElem) error
+}
+
+// rpcBatcher executes calls as batched eth_call requests for chains
+// without a multicall contract.
+type rpcBatcher struct {
+	client Backend
+	// Used round-robin
+	rpcClients []batchCaller
+	next       uint32
+	batchSize  int
+}
+
+func (b *rpcBatcher) Aggregate(opts *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error) {
+	return aggregateChunked(b.client, opts, calls, b.batchSize, len(b.rpcClients), b.aggregate)
+}
+
+func (b *rpcBatcher) aggregate(opts *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error) {
+	block := "latest"
+	if opts.BlockNumber != nil {
+		block = hexutil.EncodeBig(opts.BlockNumber)
+	}
+
+	returnData := make([]hexutil.Bytes, len(calls))
+	elems := make([]rpc.BatchElem, len(calls))
+	for i, call := range calls {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_call",
+			Args: []interface{}{
+				map[string]interface{}{
+					"to":   call.Target,
+					"data": hexutil.Bytes(call.CallData),
+				},
+				block,
+			},
+			Result: &returnData[i],
+		}
+	}
+
+	rpcClient := b.rpcClients[atomic.AddUint32(&b.next, 1)%uint32(len(b.rpcClients))]
+	if err := rpcClient.BatchCallContext(opts.Context, elems); err != nil {
+		return nil, err
+	}
+
+	results := make([]CallResult, len(calls))
+	for i, elem := range elems {
+		// Reverted calls fail individually, same as with aggregate3
+		results[i] = CallResult{Success: elem.Error == nil, ReturnData: returnData[i]}
+	}
+	return results, nil
+}
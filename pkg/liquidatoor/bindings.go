@@ -0,0 +1,71 @@
+package liquidatoor
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// The interfaces below cover exactly the methods of the generated
+// bindings the core logic uses, so they can be replaced by fakes.
+
+type Comptroller interface {
+	GetAllBorrowers(opts *bind.CallOpts) ([]common.Address, error)
+	GetAllMarkets(opts *bind.CallOpts) ([]common.Address, error)
+	Oracle(opts *bind.CallOpts) (common.Address, error)
+	CloseFactorMantissa(opts *bind.CallOpts) (*big.Int, error)
+	LiquidationIncentiveMantissa(opts *bind.CallOpts) (*big.Int, error)
+	SeizeGuardianPaused(opts *bind.CallOpts) (bool, error)
+	LiquidateCalculateSeizeTokens(opts *bind.CallOpts, cTokenBorrowed common.Address, cTokenCollateral common.Address, actualRepayAmount *big.Int) (*big.Int, *big.Int, error)
+}
+
+type PriceOracle interface {
+	GetUnderlyingPrice(opts *bind.CallOpts, cToken common.Address) (*big.Int, error)
+}
+
+type CToken interface {
+	TotalBorrows(opts *bind.CallOpts) (*big.Int, error)
+	Underlying(opts *bind.CallOpts) (common.Address, error)
+	BalanceOfUnderlying(opts *bind.CallOpts, owner common.Address) (*big.Int, error)
+	BorrowBalanceStored(opts *bind.CallOpts, account common.Address) (*big.Int, error)
+	BalanceOf(opts *bind.CallOpts, owner common.Address) (*big.Int, error)
+	ExchangeRateStored(opts *bind.CallOpts) (*big.Int, error)
+}
+
+// Multicall is the legacy multicall aggregate which fails as a whole
+// if any call reverts.
+type Multicall interface {
+	Aggregate(opts *bind.CallOpts, calls []abis.MulticallCall) (struct {
+		BlockNumber *big.Int
+		ReturnData  [][]byte
+	}, error)
+}
+
+// Backend is the node API the liquidatoor needs. Both *ethclient.Client
+// and the simulated backend of go-ethereum satisfy it.
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// headerReader returns block headers; a nil number is the latest block.
+type headerReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+var (
+	_ Comptroller = (*abis.Comptroller)(nil)
+	_ PriceOracle = (*abis.PriceOracle)(nil)
+	_ CToken      = (*abis.CToken)(nil)
+	_ Multicall   = (*abis.Multicall)(nil)
+	_ Backend     = (*ethclient.Client)(nil)
+)
@@ -0,0 +1,211 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+const (
+	// Halvings and retries a reverted batch is bisected with at most
+	bisectMaxDepth    = 12
+	bisectMaxAttempts = 64
+	// Time an isolated call is left out of batches for
+	poisonedCallCooldown = 10 * time.Minute
+)
+
+// BisectionStats are the batches bisected to isolate the calls reverting
+// them.
+type BisectionStats struct {
+	Bisections uint64
+	Attempts   uint64
+	// Calls isolated, and calls failed without being isolated as the
+	// bisection ran out of attempts or time
+	Isolated   uint64
+	Unresolved uint64
+	// Calls left out of batches now
+	Poisoned int
+}
+
+// bisector isolates the calls that revert a legacy multicall batch as a
+// whole, eg., the price of a market whose oracle reverts: the batch is
+// split in half and every half that reverts is split again, until the
+// reverting calls are alone. They fail, the rest of the batch succeeds,
+// and they are left out of batches for a cooldown. Bisection is bounded
+// in depth and attempts, waits for the rate of the endpoint before every
+// retry and takes at most the block time; calls it cannot isolate in
+// time fail without being left out. A nil bisector fails the batch.
+type bisector struct {
+	logger Logger
+	// Waits for the endpoint to allow a request
+	wait    func(ctx context.Context) error
+	timeout time.Duration
+
+	lock     sync.Mutex
+	poisoned map[common.Hash]time.Time
+	stats    BisectionStats
+}
+
+func newBisector(logger Logger, wait func(ctx context.Context) error, timeout time.Duration) *bisector {
+	return &bisector{logger: logger, wait: wait, timeout: timeout, poisoned: make(map[common.Hash]time.Time)}
+}
+
+// bisection is the state of the bisection of a batch.
+type bisection struct {
+	opts      *bind.CallOpts
+	calls     []abis.MulticallCall
+	results   []CallResult
+	aggregate aggregateFunc
+	deadline  time.Time
+	attempts  int
+	// Calls failed without being isolated
+	unresolved int
+}
+
+// aggregate executes calls with aggregate, leaving out the poisoned
+// calls, and bisects the batch if it reverts.
+func (b *bisector) aggregate(opts *bind.CallOpts, calls []abis.MulticallCall, aggregate aggregateFunc) ([]CallResult, error) {
+	if b == nil {
+		return aggregate(opts, calls)
+	}
+	results := make([]CallResult, len(calls))
+	live := make([]int, 0, len(calls))
+	now := time.Now()
+	b.lock.Lock()
+	for i, call := range calls {
+		key := callKey(call)
+		if until, ok := b.poisoned[key]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(b.poisoned, key)
+		}
+		live = append(live, i)
+	}
+	b.lock.Unlock()
+
+	s := &bisection{opts: opts, calls: calls, results: results, aggregate: aggregate, deadline: now.Add(b.timeout)}
+	if opts.Context != nil {
+		if deadline, ok := opts.Context.Deadline(); ok && deadline.Before(s.deadline) {
+			s.deadline = deadline
+		}
+	}
+	err := s.try(live)
+	if err == nil || !isRevert(err) {
+		return results, err
+	}
+
+	err = b.bisect(s, live, 0)
+	b.lock.Lock()
+	b.stats.Bisections++
+	b.stats.Attempts += uint64(s.attempts)
+	b.stats.Unresolved += uint64(s.unresolved)
+	b.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if s.unresolved > 0 {
+		b.logger.Warn(fmt.Sprintf("Cannot isolate the reverting calls of a multicall batch in %d attempts and %v; failing %d of %d calls", s.attempts, b.timeout, s.unresolved, len(calls)),
+			F("calls", len(calls)), F("unresolved", s.unresolved), F("attempts", s.attempts))
+	}
+	return results, nil
+}
+
+// try executes the calls at indices, filling in their results.
+func (s *bisection) try(indices []int) error {
+	if len(indices) == 0 {
+		return nil
+	}
+	calls := make([]abis.MulticallCall, len(indices))
+	for i, index := range indices {
+		calls[i] = s.calls[index]
+	}
+	results, err := s.aggregate(s.opts, calls)
+	if err != nil {
+		return err
+	}
+	if len(results) != len(indices) {
+		return fmt.Errorf("expected %d results, got %d", len(indices), len(results))
+	}
+	for i, index := range indices {
+		s.results[index] = results[i]
+	}
+	return nil
+}
+
+// bisect isolates the calls at indices, which revert as a batch. Errors
+// other than reverts fail the bisection.
+func (b *bisector) bisect(s *bisection, indices []int, depth int) error {
+	if len(indices) == 1 {
+		b.poison(s.calls[indices[0]])
+		return nil
+	}
+	halves := [][]int{indices[:len(indices)/2], indices[len(indices)/2:]}
+	for i, half := range halves {
+		if depth >= bisectMaxDepth || s.attempts >= bisectMaxAttempts || time.Now().After(s.deadline) {
+			// The rest fails
+			for _, rest := range halves[i:] {
+				s.unresolved += len(rest)
+			}
+			return nil
+		}
+		if err := b.wait(s.opts.Context); err != nil {
+			return err
+		}
+		s.attempts++
+		err := s.try(half)
+		if err == nil {
+			continue
+		}
+		if !isRevert(err) {
+			return err
+		}
+		if err := b.bisect(s, half, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poison leaves call out of batches for the cooldown.
+func (b *bisector) poison(call abis.MulticallCall) {
+	b.lock.Lock()
+	b.poisoned[callKey(call)] = time.Now().Add(poisonedCallCooldown)
+	b.stats.Isolated++
+	b.lock.Unlock()
+	selector := call.CallData
+	if len(selector) > 4 {
+		selector = selector[:4]
+	}
+	b.logger.Warn(fmt.Sprintf("Call %s of %s reverts its multicall batch; leaving it out of batches for %v", hexutil.Encode(selector), call.Target.Hex(), poisonedCallCooldown),
+		F("target", call.Target), F("selector", hexutil.Encode(selector)))
+}
+
+// Stats returns the bisections so far.
+func (b *bisector) Stats() BisectionStats {
+	if b == nil {
+		return BisectionStats{}
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	stats := b.stats
+	now := time.Now()
+	for _, until := range b.poisoned {
+		if now.Before(until) {
+			stats.Poisoned++
+		}
+	}
+	return stats
+}
+
+func callKey(call abis.MulticallCall) common.Hash {
+	return crypto.Keccak256Hash(call.Target[:], call.CallData)
+}
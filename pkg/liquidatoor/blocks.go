@@ -0,0 +1,76 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Number of block times without a new block before warning.
+const blocksStallFactor = 10
+
+// subscribeToBlocks calls process for every new block until ctx is
+// cancelled, subscribing again every block time while the subscription
+// fails, as log watches do.
+func subscribeToBlocks(ctx context.Context, logger Logger, client Backend, blockTime time.Duration, process func(context.Context, *types.Header)) error {
+	headers := make(chan *types.Header)
+	sub, err := client.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		return fmt.Errorf("cannot subscribe to headers: %w", err)
+	}
+	defer func() { sub.Unsubscribe() }()
+
+	// Warn when blocks stop arriving at the expected cadence
+	stallTimeout := blocksStallFactor * blockTime
+	stall := time.NewTimer(stallTimeout)
+	defer stall.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err := <-sub.Err():
+			logger.Error(fmt.Sprintf("Got subscription error: %v", err), F("err", err))
+			sub.Unsubscribe()
+			if sub = resubscribeToBlocks(ctx, logger, client, blockTime, headers); sub == nil {
+				return nil
+			}
+
+		case <-stall.C:
+			logger.Warn(fmt.Sprintf("No new block for %v", stallTimeout), F("timeout", stallTimeout))
+			stall.Reset(stallTimeout)
+
+		case header := <-headers:
+			logger.Info(fmt.Sprintf("Processing block %d", header.Number.Uint64()), F("block", header.Number.Uint64()))
+			if !stall.Stop() {
+				<-stall.C
+			}
+			stall.Reset(stallTimeout)
+
+			process(ctx, header)
+		}
+	}
+}
+
+// resubscribeToBlocks subscribes to new headers again, waiting retry
+// before every attempt, until it does or ctx is cancelled, in which
+// case it returns nil.
+func resubscribeToBlocks(ctx context.Context, logger Logger, client Backend, retry time.Duration, headers chan<- *types.Header) ethereum.Subscription {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		}
+		sub, err := client.SubscribeNewHead(ctx, headers)
+		if err == nil {
+			logger.Info("Subscribed to headers again")
+			return sub
+		}
+		logger.Warn(fmt.Sprintf("Cannot subscribe to headers again: %v", err), F("err", err))
+	}
+}
@@ -0,0 +1,117 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// headSubscription is a subscription to new headers that fails on
+// demand.
+type headSubscription struct {
+	err  chan error
+	once sync.Once
+	done chan struct{}
+}
+
+func (s *headSubscription) Err() <-chan error { return s.err }
+
+func (s *headSubscription) Unsubscribe() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// headsBackend delivers headers to its live subscription, and refuses
+// as many subscriptions as refuse says.
+type headsBackend struct {
+	Backend
+	lock    sync.Mutex
+	headers chan<- *types.Header
+	sub     *headSubscription
+	refuse  int
+	// Signals every subscription
+	subscribed chan struct{}
+}
+
+func (b *headsBackend) SubscribeNewHead(_ context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.refuse > 0 {
+		b.refuse--
+		return nil, errors.New("refused")
+	}
+	b.headers, b.sub = ch, &headSubscription{err: make(chan error, 1), done: make(chan struct{})}
+	b.subscribed <- struct{}{}
+	return b.sub, nil
+}
+
+// fail fails the live subscription, refusing the next refuse.
+func (b *headsBackend) fail(refuse int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.refuse = refuse
+	b.sub.err <- errors.New("connection lost")
+}
+
+func (b *headsBackend) emit(t *testing.T, number int64) {
+	t.Helper()
+	b.lock.Lock()
+	headers, sub := b.headers, b.sub
+	b.lock.Unlock()
+	select {
+	case headers <- &types.Header{Number: big.NewInt(number)}:
+	case <-sub.done:
+		t.Fatalf("block %d delivered to a subscription that was unsubscribed", number)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected block %d processed", number)
+	}
+}
+
+func TestSubscribeToBlocksResubscribes(t *testing.T) {
+	client := &headsBackend{subscribed: make(chan struct{}, 8)}
+	ctx, cancel := context.WithCancel(context.Background())
+	processed := make(chan int64, 8)
+	done := make(chan error, 1)
+	go func() {
+		done <- subscribeToBlocks(ctx, quietLogger(), client, 10*time.Millisecond, func(_ context.Context, header *types.Header) {
+			processed <- header.Number.Int64()
+		})
+	}()
+	waitSubscription := func() {
+		t.Helper()
+		select {
+		case <-client.subscribed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a subscription")
+		}
+	}
+
+	waitSubscription()
+	client.emit(t, 1)
+	// Subscribed again once the node accepts it, and blocks of the new
+	// subscription are processed
+	client.fail(2)
+	waitSubscription()
+	client.emit(t, 2)
+	for _, expected := range []int64{1, 2} {
+		if number := <-processed; number != expected {
+			t.Fatalf("expected block %d processed, got %d", expected, number)
+		}
+	}
+	client.lock.Lock()
+	refused := client.refuse
+	client.lock.Unlock()
+	if refused != 0 {
+		t.Fatalf("expected the refused subscriptions retried, %d left", refused)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
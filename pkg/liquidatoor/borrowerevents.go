@@ -0,0 +1,259 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// AccountClass is what a borrower can be liquidated for.
+type AccountClass string
+
+const (
+	// Borrows against collateral it can be liquidated for
+	AccountCandidate AccountClass = "candidate"
+	// Borrows without collateral, so there is nothing to seize
+	AccountBadDebt AccountClass = "bad debt"
+	// Borrows nothing
+	AccountDormant AccountClass = "dormant"
+)
+
+// classify returns the class of an account from its positions in every
+// market it entered.
+func classify(account AccountPositions) AccountClass {
+	borrows, collateral := false, false
+	for _, position := range account.Positions {
+		borrows = borrows || IsPositive(position.Borrowed)
+		collateral = collateral || IsPositive(position.Supplied)
+	}
+	switch {
+	case !borrows:
+		return AccountDormant
+	case !collateral:
+		return AccountBadDebt
+	}
+	return AccountCandidate
+}
+
+// borrowerEvents follows the Borrow and RepayBorrow events of the
+// markets of a pool, so new borrowers are checked within a block of
+// their first borrow and the assets of borrowers stay current between
+// refreshes of the borrower cache, which remain the source of truth.
+// Borrowers exiting markets are reclassified, and parked borrowers
+// whenever they borrow or supply again.
+type borrowerEvents struct {
+	// Next block to filter; zero until the first block
+	nextBlock uint64
+	// Borrowers of the events of the last logReorgDepth blocks, by
+	// block, read again if a reorg replaces their block
+	recent map[uint64][]common.Address
+
+	borrow abi.Event
+	repay  abi.Event
+	// Reclassify parked borrowers
+	mint    abi.Event
+	entered abi.Event
+	exited  abi.Event
+}
+
+func newBorrowerEvents(comptrollerABI *abi.ABI) (*borrowerEvents, error) {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	return &borrowerEvents{
+		recent:  make(map[uint64][]common.Address),
+		borrow:  cTokenABI.Events["Borrow"],
+		repay:   cTokenABI.Events["RepayBorrow"],
+		mint:    cTokenABI.Events["Mint"],
+		entered: comptrollerABI.Events["MarketEntered"],
+		exited:  comptrollerABI.Events["MarketExited"],
+	}, nil
+}
+
+// followBorrowers updates the borrower cache with the borrowers of
+// the pool events since the previous block processed, in every market
+// of the pool at the time, and reclassifies the ones that exited
+// markets or are parked. Blocks missed while the block subscription
+// was down are backfilled, and failures are retried on the next block.
+// The borrowers of blocks replaced by a reorg are read and reclassified
+// again, as their events may be gone.
+func (l *Liquidatoor) followBorrowers(ctx context.Context, block *big.Int) {
+	e := l.borrowerEvents
+	if e == nil || block == nil {
+		return
+	}
+	to := block.Uint64()
+	from := e.nextBlock
+	reorged := make(map[common.Address]bool)
+	switch {
+	case from == 0:
+		from = to
+	case from > to:
+		// A reorg, from a block that cannot be told, so every block kept
+		// is filtered and read again
+		from = 1
+		if to > logReorgDepth {
+			from = to - logReorgDepth
+		}
+		for number, accounts := range e.recent {
+			if number >= from {
+				for _, account := range accounts {
+					reorged[account] = true
+				}
+			}
+		}
+	}
+
+	addresses := []common.Address{l.comptrollerAddress}
+	for address := range l.LendMarkets {
+		addresses = append(addresses, common.HexToAddress(address))
+	}
+	var logs []types.Log
+	query := ethereum.FilterQuery{Addresses: addresses, Topics: [][]common.Hash{{e.borrow.ID, e.repay.ID, e.mint.ID, e.entered.ID, e.exited.ID}}}
+	err := l.logs.filter(ctx, "borrower events", query, from, to, func(chunk []types.Log, _ uint64) error {
+		logs = append(logs, chunk...)
+		return nil
+	})
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Failed to follow borrowers: %v", err), F("pool", l.comptrollerAddress), F("block", block), F("err", err))
+		return
+	}
+
+	accounts := make([]common.Address, 0, len(logs)+len(reorged))
+	seen := make(map[common.Address]bool)
+	exited := make(map[common.Address]bool)
+	recent := make(map[uint64][]common.Address)
+	for account := range reorged {
+		seen[account] = true
+		accounts = append(accounts, account)
+	}
+	for _, log := range logs {
+		if log.Removed || len(log.Topics) == 0 {
+			continue
+		}
+		var event *abi.Event
+		field, comptroller := "borrower", false
+		switch log.Topics[0] {
+		case e.borrow.ID:
+			event = &e.borrow
+			// Markets without borrows when loaded have some now
+			if _, ok := l.BorrowMarkets[log.Address.String()]; !ok {
+				l.BorrowMarkets[log.Address.String()] = l.LendMarkets[log.Address.String()]
+			}
+		case e.repay.ID:
+			event = &e.repay
+		case e.mint.ID:
+			event, field = &e.mint, "minter"
+		case e.entered.ID:
+			event, field, comptroller = &e.entered, "account", true
+		case e.exited.ID:
+			event, field, comptroller = &e.exited, "account", true
+		}
+		if event == nil || comptroller != (log.Address == l.comptrollerAddress) {
+			continue
+		}
+		account, err := eventAddress(event, log, field)
+		if err != nil {
+			l.logger.Warn(fmt.Sprintf("Skipping borrower event: %v", err), F("pool", l.comptrollerAddress), F("tx", log.TxHash), F("err", err))
+			continue
+		}
+		switch event {
+		case &e.mint, &e.entered:
+			// Supplying only matters to parked borrowers, which it may
+			// make candidates again
+			if l.borrowerCache.Class(account) == AccountCandidate {
+				continue
+			}
+		case &e.exited:
+			exited[account] = true
+		}
+		recent[log.BlockNumber] = append(recent[log.BlockNumber], account)
+		if !seen[account] {
+			seen[account] = true
+			accounts = append(accounts, account)
+		}
+	}
+	if len(accounts) > 0 {
+		borrowers, err := l.borrowerCache.Observe(ctx, accounts)
+		if err != nil {
+			l.logger.Warn(fmt.Sprintf("Failed to update borrowers from events: %v", err), F("pool", l.comptrollerAddress), F("block", block), F("err", err))
+			return
+		}
+		l.logger.Info(fmt.Sprintf("Updated %d borrowers from events in blocks %d-%d", len(accounts), from, to),
+			F("pool", l.comptrollerAddress), F("block", block), F("borrowers", len(accounts)))
+		if len(reorged) > 0 {
+			l.logger.Info(fmt.Sprintf("Read %d borrowers of blocks %d-%d again after a reorg", len(reorged), from, to),
+				F("pool", l.comptrollerAddress), F("block", block), F("borrowers", len(reorged)))
+		}
+
+		reclassify := make([]Borrower, 0)
+		for _, borrower := range borrowers {
+			if exited[borrower.Address] || reorged[borrower.Address] || l.borrowerCache.Class(borrower.Address) != AccountCandidate {
+				reclassify = append(reclassify, borrower)
+			}
+		}
+		if err := l.reclassify(ctx, block, reclassify); err != nil {
+			l.logger.Warn(fmt.Sprintf("Failed to reclassify borrowers: %v", err), F("pool", l.comptrollerAddress), F("block", block), F("err", err))
+			return
+		}
+	}
+	e.nextBlock = to + 1
+	for number := range e.recent {
+		if number >= from || number+logReorgDepth < to {
+			delete(e.recent, number)
+		}
+	}
+	for number, accounts := range recent {
+		e.recent[number] = accounts
+	}
+}
+
+// reclassify derives the class of borrowers from their positions,
+// parking the ones that cannot be liquidated and logging every change.
+func (l *Liquidatoor) reclassify(ctx context.Context, block *big.Int, borrowers []Borrower) error {
+	if len(borrowers) == 0 {
+		return nil
+	}
+	accounts, err := l.positions(ctx, nil, borrowers)
+	if err != nil {
+		return err
+	}
+	parked := make(map[AccountClass][]Borrower)
+	unparked := make([]Borrower, 0)
+	for i, account := range accounts {
+		borrower := borrowers[i]
+		// Positions that could not be read leave the class as is
+		if len(account.Positions) < len(borrower.Assets) {
+			continue
+		}
+		old, class := l.borrowerCache.Class(borrower.Address), classify(account)
+		l.risk.classified(borrower.Address, class)
+		if class == AccountCandidate {
+			unparked = append(unparked, borrower)
+		} else {
+			parked[class] = append(parked[class], borrower)
+		}
+		if class == old {
+			continue
+		}
+		reason := "no longer checked every block"
+		if class == AccountCandidate {
+			reason = "checked every block again"
+		}
+		l.logger.Info(fmt.Sprintf("Account %s is now %s, was %s; %s", borrower.Address, class, old, reason),
+			F("pool", l.comptrollerAddress), F("block", block), F("account", borrower.Address), F("class", class), F("previous", old))
+	}
+	for class, borrowers := range parked {
+		l.borrowerCache.Park(borrowers, class)
+	}
+	l.borrowerCache.Unpark(unparked)
+	return nil
+}
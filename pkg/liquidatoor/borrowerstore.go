@@ -0,0 +1,160 @@
+package liquidatoor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tables of the borrower store: the borrowers of every pool, and the
+// markets each entered, in order. Addresses are hex strings, compared
+// regardless of case so borrowers are ordered by address.
+var borrowerSchema = []string{
+	`CREATE TABLE IF NOT EXISTS borrowers (
+		pool TEXT NOT NULL,
+		borrower TEXT NOT NULL COLLATE NOCASE,
+		PRIMARY KEY (pool, borrower)
+	)`,
+	`CREATE TABLE IF NOT EXISTS borrower_assets (
+		pool TEXT NOT NULL,
+		borrower TEXT NOT NULL COLLATE NOCASE,
+		position INTEGER NOT NULL,
+		market TEXT NOT NULL,
+		PRIMARY KEY (pool, borrower, position)
+	)`,
+}
+
+// borrowerStore persists the borrowers of every pool in a SQLite
+// database, for pools too large to hold every borrower in memory and
+// to survive restarts. It is shared by the borrower caches of every
+// pool.
+type borrowerStore struct {
+	db *sql.DB
+}
+
+func openBorrowerStore(path string) (*borrowerStore, error) {
+	db, err := openSQLite(path, borrowerSchema)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open borrower store: %w", err)
+	}
+	return &borrowerStore{db: db}, nil
+}
+
+func (s *borrowerStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// each calls fn with every borrower of a pool, by address, without
+// loading them all at once.
+func (s *borrowerStore) each(ctx context.Context, pool common.Address, fn func(Borrower) error) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT b.borrower, a.market FROM borrowers b
+		LEFT JOIN borrower_assets a ON a.pool = b.pool AND a.borrower = b.borrower
+		WHERE b.pool = ? ORDER BY b.borrower, a.position`, pool.Hex())
+	if err != nil {
+		return fmt.Errorf("cannot read borrowers: %w", err)
+	}
+	defer rows.Close()
+	// Rows of a borrower are consecutive, one per market
+	var current *Borrower
+	for rows.Next() {
+		var (
+			borrower string
+			market   sql.NullString
+		)
+		if err := rows.Scan(&borrower, &market); err != nil {
+			return fmt.Errorf("cannot read borrowers: %w", err)
+		}
+		address := common.HexToAddress(borrower)
+		if current != nil && current.Address != address {
+			if err := fn(*current); err != nil {
+				return err
+			}
+			current = nil
+		}
+		if current == nil {
+			current = &Borrower{Address: address}
+		}
+		if market.Valid {
+			current.Assets = append(current.Assets, common.HexToAddress(market.String))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cannot read borrowers: %w", err)
+	}
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
+
+// put adds or replaces borrowers of a pool in a single transaction.
+func (s *borrowerStore) put(ctx context.Context, pool common.Address, borrowers []Borrower) error {
+	return s.write(ctx, pool, borrowers, false)
+}
+
+// replace replaces the borrowers of a pool in a single transaction, so
+// a crash mid-refresh leaves the previous set.
+func (s *borrowerStore) replace(ctx context.Context, pool common.Address, borrowers []Borrower) error {
+	return s.write(ctx, pool, borrowers, true)
+}
+
+// write stores borrowers of a pool, once the previous borrowers of the
+// pool are deleted if replacing, or else the previous markets of each.
+func (s *borrowerStore) write(ctx context.Context, pool common.Address, borrowers []Borrower, replace bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot write borrowers: %w", err)
+	}
+	defer tx.Rollback()
+	// Checksumming addresses is costly at this scale
+	poolHex := pool.Hex()
+	markets := make(map[common.Address]string)
+	if replace {
+		for _, table := range []string{"borrowers", "borrower_assets"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE pool = ?`, poolHex); err != nil {
+				return fmt.Errorf("cannot delete borrowers: %w", err)
+			}
+		}
+	}
+	// Refreshes of very large pools write hundreds of thousands of rows
+	var statements [3]*sql.Stmt
+	for i, query := range []string{
+		`DELETE FROM borrower_assets WHERE pool = ? AND borrower = ?`,
+		`INSERT OR REPLACE INTO borrowers (pool, borrower) VALUES (?, ?)`,
+		`INSERT INTO borrower_assets (pool, borrower, position, market) VALUES (?, ?, ?, ?)`,
+	} {
+		if statements[i], err = tx.PrepareContext(ctx, query); err != nil {
+			return fmt.Errorf("cannot write borrowers: %w", err)
+		}
+		defer statements[i].Close()
+	}
+	deleteAssets, insertBorrower, insertAsset := statements[0], statements[1], statements[2]
+	for _, borrower := range borrowers {
+		address := borrower.Address.Hex()
+		if !replace {
+			if _, err := deleteAssets.ExecContext(ctx, poolHex, address); err != nil {
+				return fmt.Errorf("cannot store borrower %s: %w", borrower.Address, err)
+			}
+		}
+		if _, err := insertBorrower.ExecContext(ctx, poolHex, address); err != nil {
+			return fmt.Errorf("cannot store borrower %s: %w", borrower.Address, err)
+		}
+		for i, market := range borrower.Assets {
+			if _, ok := markets[market]; !ok {
+				markets[market] = market.Hex()
+			}
+			if _, err := insertAsset.ExecContext(ctx, poolHex, address, i, markets[market]); err != nil {
+				return fmt.Errorf("cannot store borrower %s: %w", borrower.Address, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot write borrowers: %w", err)
+	}
+	return nil
+}
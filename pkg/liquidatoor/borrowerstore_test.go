@@ -0,0 +1,153 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	storePool  = common.HexToAddress("0xc0")
+	storeOther = common.HexToAddress("0xc1")
+	// Ordered by address, unlike the checksummed hex strings of alice
+	// and bob, 0x...a0 and 0x...A1
+	storeAlice = common.HexToAddress("0xa0")
+	storeBob   = common.HexToAddress("0xa1")
+	storeCarol = common.HexToAddress("0xa4")
+)
+
+func openTestBorrowerStore(t *testing.T) (*borrowerStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "borrowers")
+	s, err := openBorrowerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+func storedBorrowers(t *testing.T, s *borrowerStore, pool common.Address) []Borrower {
+	t.Helper()
+	var borrowers []Borrower
+	if err := s.each(context.Background(), pool, func(b Borrower) error {
+		borrowers = append(borrowers, b)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return borrowers
+}
+
+func TestBorrowerStore(t *testing.T) {
+	s, _ := openTestBorrowerStore(t)
+	a, b, c := common.HexToAddress("0xa"), common.HexToAddress("0xb"), common.HexToAddress("0xc")
+
+	if err := s.replace(context.Background(), storePool, []Borrower{
+		{Address: storeCarol, Assets: []common.Address{c, a}},
+		{Address: storeAlice, Assets: []common.Address{a}},
+		// Borrowers that exited every market are kept
+		{Address: storeBob},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.put(context.Background(), storeOther, []Borrower{{Address: storeAlice, Assets: []common.Address{b}}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Borrower{
+		{Address: storeAlice, Assets: []common.Address{a}},
+		{Address: storeBob},
+		{Address: storeCarol, Assets: []common.Address{c, a}},
+	}
+	if got := storedBorrowers(t, s, storePool); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %+v by address, got %+v", expected, got)
+	}
+
+	// Puts replace the markets of their borrowers only
+	if err := s.put(context.Background(), storePool, []Borrower{{Address: storeBob, Assets: []common.Address{b, c}}, {Address: storeCarol, Assets: []common.Address{b}}}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []Borrower{
+		{Address: storeAlice, Assets: []common.Address{a}},
+		{Address: storeBob, Assets: []common.Address{b, c}},
+		{Address: storeCarol, Assets: []common.Address{b}},
+	}
+	if got := storedBorrowers(t, s, storePool); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+
+	// Refreshes replace the borrowers of their pool only
+	if err := s.replace(context.Background(), storePool, []Borrower{{Address: storeBob, Assets: []common.Address{a}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := storedBorrowers(t, s, storePool), []Borrower{{Address: storeBob, Assets: []common.Address{a}}}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	if got, expected := storedBorrowers(t, s, storeOther), []Borrower{{Address: storeAlice, Assets: []common.Address{b}}}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %+v in the other pool, got %+v", expected, got)
+	}
+
+	stop := errors.New("stop")
+	if err := s.each(context.Background(), storePool, func(Borrower) error { return stop }); !errors.Is(err, stop) {
+		t.Fatalf("expected iteration to stop at the first error, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.each(ctx, storePool, func(Borrower) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected iteration to stop once cancelled, got %v", err)
+	}
+}
+
+func TestBorrowerStoreFailedRefresh(t *testing.T) {
+	s, _ := openTestBorrowerStore(t)
+	a := common.HexToAddress("0xa")
+	previous := []Borrower{{Address: storeAlice, Assets: []common.Address{a}}, {Address: storeBob, Assets: []common.Address{a}}}
+	if err := s.replace(context.Background(), storePool, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail the refresh once it replaced some of the borrowers
+	trigger := fmt.Sprintf(`CREATE TRIGGER fail BEFORE INSERT ON borrowers WHEN NEW.borrower = '%s' BEGIN SELECT RAISE(ABORT, 'failed'); END`, storeCarol.Hex())
+	if _, err := s.db.Exec(trigger); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.replace(context.Background(), storePool, []Borrower{{Address: storeAlice}, {Address: storeCarol}}); err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	if got := storedBorrowers(t, s, storePool); !reflect.DeepEqual(got, previous) {
+		t.Fatalf("expected the previous borrowers, got %+v", got)
+	}
+}
+
+func TestLockDatabases(t *testing.T) {
+	s, path := openTestBorrowerStore(t)
+	release, err := lockDatabases(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes wait for the lock, and reads do not
+	written := make(chan error, 1)
+	go func() {
+		written <- s.put(context.Background(), storePool, []Borrower{{Address: storeAlice}})
+	}()
+	storedBorrowers(t, s, storePool)
+	select {
+	case err := <-written:
+		t.Fatalf("expected the write to wait for the lock, got %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	release()
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if got := storedBorrowers(t, s, storePool); len(got) != 1 {
+		t.Fatalf("expected the write once unlocked, got %+v", got)
+	}
+}
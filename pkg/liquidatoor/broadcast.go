@@ -0,0 +1,216 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Time every endpoint is given to answer a submission, once the first
+// one accepted it
+const submissionTimeout = 30 * time.Second
+
+// SubmissionStats are the transactions submitted to an endpoint.
+type SubmissionStats struct {
+	// Host of the endpoint
+	Endpoint    string
+	Submissions uint64
+	Accepted    uint64
+	// Transactions the endpoint accepted, or knew, before any other
+	First uint64
+	// Transactions the endpoint already had
+	Known    uint64
+	Failures uint64
+	// Over the submissions answered
+	MeanLatency time.Duration
+	LastLatency time.Duration
+}
+
+// broadcaster submits every signed transaction to the main node and to
+// every submission endpoint at once, eg., public nodes or private
+// relays, so that it reaches the builders through whichever is fastest.
+// It succeeds once any endpoint accepts it; endpoints that already know
+// it, having heard of it from another, accepted it too. Transactions are
+// signed before, so their nonces are the same on every endpoint.
+type broadcaster struct {
+	logger    Logger
+	endpoints []*submissionEndpoint
+}
+
+type submissionEndpoint struct {
+	host   string
+	client *ethclient.Client
+	// Closed with the broadcaster, unless the main connection
+	rpc *rpc.Client
+
+	submissions uint64
+	accepted    uint64
+	first       uint64
+	known       uint64
+	failures    uint64
+	answered    uint64
+	latency     int64
+	lastLatency int64
+}
+
+func dialBroadcaster(ctx context.Context, logger Logger, main *ethclient.Client, mainHost string, urls []string) (*broadcaster, error) {
+	b := &broadcaster{logger: logger, endpoints: []*submissionEndpoint{{host: mainHost, client: main}}}
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("%w: invalid SUBMISSION_URLS: %v", ErrInvalidConfig, err)
+		}
+		rpcClient, err := rpc.DialContext(ctx, rawURL)
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("cannot connect to submission endpoint %s: %w", u.Hostname(), err)
+		}
+		b.endpoints = append(b.endpoints, &submissionEndpoint{host: strings.ToLower(u.Hostname()), client: ethclient.NewClient(rpcClient), rpc: rpcClient})
+	}
+	return b, nil
+}
+
+type submission struct {
+	endpoint *submissionEndpoint
+	latency  time.Duration
+	err      error
+}
+
+// SendTransaction submits tx to every endpoint and returns once one
+// accepts it, or with the error of the main node if none does. The other
+// endpoints are still waited for, off the caller, to record how long they
+// took.
+func (b *broadcaster) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	sctx, cancel := context.WithTimeout(context.Background(), submissionTimeout)
+	results := make(chan submission, len(b.endpoints))
+	for _, e := range b.endpoints {
+		go func(e *submissionEndpoint) {
+			latency, err := e.submit(sctx, tx)
+			results <- submission{endpoint: e, latency: latency, err: err}
+		}(e)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer cancel()
+		var errs []error
+		var accepted bool
+		for range b.endpoints {
+			r := <-results
+			if r.err == nil && !accepted {
+				accepted = true
+				atomic.AddUint64(&r.endpoint.first, 1)
+				done <- nil
+			}
+			if r.err == nil {
+				b.logger.Debug(fmt.Sprintf("Endpoint %s accepted transaction %s in %s", r.endpoint.host, tx.Hash().Hex(), r.latency),
+					F("endpoint", r.endpoint.host), F("tx", tx.Hash()), F("latency", r.latency))
+			} else {
+				b.logger.Debug(fmt.Sprintf("Endpoint %s did not accept transaction %s in %s: %v", r.endpoint.host, tx.Hash().Hex(), r.latency, r.err),
+					F("endpoint", r.endpoint.host), F("tx", tx.Hash()), F("latency", r.latency), F("err", r.err))
+				if r.endpoint == b.endpoints[0] {
+					errs = append([]error{r.err}, errs...)
+				} else {
+					errs = append(errs, r.err)
+				}
+			}
+		}
+		if !accepted {
+			done <- errs[0]
+		}
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// submit sends tx to the endpoint, and returns how long it took to
+// answer.
+func (e *submissionEndpoint) submit(ctx context.Context, tx *types.Transaction) (time.Duration, error) {
+	atomic.AddUint64(&e.submissions, 1)
+	start := time.Now()
+	err := e.client.SendTransaction(ctx, tx)
+	latency := time.Since(start)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		atomic.AddUint64(&e.answered, 1)
+		atomic.AddInt64(&e.latency, int64(latency))
+		atomic.StoreInt64(&e.lastLatency, int64(latency))
+	}
+	switch {
+	case err == nil:
+		atomic.AddUint64(&e.accepted, 1)
+	case isAlreadyKnown(err):
+		atomic.AddUint64(&e.known, 1)
+		return latency, nil
+	default:
+		atomic.AddUint64(&e.failures, 1)
+	}
+	return latency, err
+}
+
+// isAlreadyKnown reports whether err is the answer of a node that has
+// the transaction sent already, under the wording of the common clients.
+func isAlreadyKnown(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction") ||
+		strings.Contains(msg, "alreadyknown") || strings.Contains(msg, "already imported") || strings.Contains(msg, "already in mempool")
+}
+
+// Stats returns the stats of every endpoint, the main node first.
+func (b *broadcaster) Stats() []SubmissionStats {
+	if b == nil {
+		return nil
+	}
+	stats := make([]SubmissionStats, len(b.endpoints))
+	for i, e := range b.endpoints {
+		stats[i] = SubmissionStats{
+			Endpoint:    e.host,
+			Submissions: atomic.LoadUint64(&e.submissions),
+			Accepted:    atomic.LoadUint64(&e.accepted),
+			First:       atomic.LoadUint64(&e.first),
+			Known:       atomic.LoadUint64(&e.known),
+			Failures:    atomic.LoadUint64(&e.failures),
+			LastLatency: time.Duration(atomic.LoadInt64(&e.lastLatency)),
+		}
+		if answered := atomic.LoadUint64(&e.answered); answered > 0 {
+			stats[i].MeanLatency = time.Duration(atomic.LoadInt64(&e.latency) / int64(answered))
+		}
+	}
+	return stats
+}
+
+// Close closes the connections to the submission endpoints.
+func (b *broadcaster) Close() {
+	if b == nil {
+		return
+	}
+	for _, e := range b.endpoints {
+		if e.rpc != nil {
+			e.rpc.Close()
+		}
+	}
+}
+
+// broadcastClient is the client of the main node, sending transactions
+// through a broadcaster.
+type broadcastClient struct {
+	*ethclient.Client
+	broadcaster *broadcaster
+}
+
+func (c *broadcastClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return c.broadcaster.SendTransaction(ctx, tx)
+}
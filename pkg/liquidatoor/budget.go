@@ -0,0 +1,358 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// BudgetLimits are the process-wide limits of a Budget; zero is
+// unlimited.
+type BudgetLimits struct {
+	// Liquidations executing at once across every pool
+	ExecutionWorkers int
+	// Multicall chunks in flight at once across every pool
+	Multicalls int
+	// Requests per second to every endpoint, of multicall chunks and log
+	// queries
+	EndpointRate float64
+}
+
+func (l BudgetLimits) limited() bool {
+	return l.ExecutionWorkers > 0 || l.Multicalls > 0 || l.EndpointRate > 0
+}
+
+// Budget bounds the work of every pool of every connection sharing it,
+// eg., every chain of a process, so they do not oversubscribe the host
+// and the providers. Pools waiting for a slot are served in turn, so
+// one large pool cannot starve the others. A nil Budget is unlimited.
+type Budget struct {
+	limits     BudgetLimits
+	executions *fairSemaphore
+	multicalls *fairSemaphore
+
+	lock      sync.Mutex
+	endpoints map[string]*rateLimiter
+	usage     map[budgetKey]*BudgetUsage
+}
+
+// budgetKey is a pool of a chain, as connections sharing a budget may
+// monitor the same pool address on different chains.
+type budgetKey struct {
+	chain string
+	pool  common.Address
+}
+
+// BudgetUsage is what a pool used of the budget.
+type BudgetUsage struct {
+	// Chain of the config of the pool, if named
+	Chain string
+	Pool  common.Address
+	// Slots acquired, and the time spent waiting for them
+	Executions    uint64
+	ExecutionWait time.Duration
+	Multicalls    uint64
+	MulticallWait time.Duration
+	// Slots held now
+	Executing int
+	Calling   int
+}
+
+func NewBudget(limits BudgetLimits) *Budget {
+	b := &Budget{
+		limits:    limits,
+		endpoints: make(map[string]*rateLimiter),
+		usage:     make(map[budgetKey]*BudgetUsage),
+	}
+	if limits.ExecutionWorkers > 0 {
+		b.executions = newFairSemaphore(limits.ExecutionWorkers)
+	}
+	if limits.Multicalls > 0 {
+		b.multicalls = newFairSemaphore(limits.Multicalls)
+	}
+	return b
+}
+
+// Limits returns the limits of the budget.
+func (b *Budget) Limits() BudgetLimits {
+	if b == nil {
+		return BudgetLimits{}
+	}
+	return b.limits
+}
+
+// Usage returns what every pool used of the budget, by pool.
+func (b *Budget) Usage() []BudgetUsage {
+	if b == nil {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	usage := make([]BudgetUsage, 0, len(b.usage))
+	for _, u := range b.usage {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Chain != usage[j].Chain {
+			return usage[i].Chain < usage[j].Chain
+		}
+		return usage[i].Pool.Hex() < usage[j].Pool.Hex()
+	})
+	return usage
+}
+
+// acquire acquires a slot of s for pool, counting it with count, and
+// returns its release.
+func (b *Budget) acquire(ctx context.Context, s *fairSemaphore, pool budgetKey, count func(*BudgetUsage, time.Duration, int)) (func(), error) {
+	if b == nil || s == nil {
+		return func() {}, nil
+	}
+	began := time.Now()
+	if err := s.acquire(ctx, pool); err != nil {
+		return nil, err
+	}
+	b.lock.Lock()
+	u, ok := b.usage[pool]
+	if !ok {
+		u = &BudgetUsage{Chain: pool.chain, Pool: pool.pool}
+		b.usage[pool] = u
+	}
+	count(u, time.Since(began), 1)
+	b.lock.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.lock.Lock()
+			count(u, 0, -1)
+			b.lock.Unlock()
+			s.release()
+		})
+	}, nil
+}
+
+// execution acquires an execution slot for pool and returns its
+// release.
+func (b *Budget) execution(ctx context.Context, pool budgetKey) (func(), error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	return b.acquire(ctx, b.executions, pool, func(u *BudgetUsage, wait time.Duration, held int) {
+		if held > 0 {
+			u.Executions++
+			u.ExecutionWait += wait
+		}
+		u.Executing += held
+	})
+}
+
+// multicall acquires a multicall slot for pool and returns its
+// release.
+func (b *Budget) multicall(ctx context.Context, pool budgetKey) (func(), error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	return b.acquire(ctx, b.multicalls, pool, func(u *BudgetUsage, wait time.Duration, held int) {
+		if held > 0 {
+			u.Multicalls++
+			u.MulticallWait += wait
+		}
+		u.Calling += held
+	})
+}
+
+// wait waits for the rate of endpoint to allow a request.
+func (b *Budget) wait(ctx context.Context, endpoint string) error {
+	if b == nil || b.limits.EndpointRate <= 0 {
+		return nil
+	}
+	b.lock.Lock()
+	r, ok := b.endpoints[endpoint]
+	if !ok {
+		r = newRateLimiter(b.limits.EndpointRate)
+		b.endpoints[endpoint] = r
+	}
+	b.lock.Unlock()
+	return r.wait(ctx)
+}
+
+// batcher returns inner, executing the chunks of its calls within the
+// budget of pool and of endpoint.
+func (b *Budget) batcher(pool budgetKey, endpoint string, client headerReader, inner CallBatcher, batchSize int) CallBatcher {
+	if b == nil || (b.multicalls == nil && b.limits.EndpointRate <= 0) {
+		return inner
+	}
+	return &budgetedBatcher{budget: b, pool: pool, endpoint: endpoint, client: client, inner: inner, batchSize: batchSize}
+}
+
+// budgetedBatcher executes the chunks of every call of a pool, pinned
+// to the same block, with a multicall slot each and at the rate of the
+// endpoint.
+type budgetedBatcher struct {
+	budget    *Budget
+	pool      budgetKey
+	endpoint  string
+	client    headerReader
+	inner     CallBatcher
+	batchSize int
+}
+
+func (b *budgetedBatcher) Aggregate(opts *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error) {
+	concurrency := b.budget.limits.Multicalls
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return aggregateChunked(b.client, opts, calls, b.batchSize, concurrency, func(opts *bind.CallOpts, chunk []abis.MulticallCall) ([]CallResult, error) {
+		release, err := b.budget.multicall(opts.Context, b.pool)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		if err := b.budget.wait(opts.Context, b.endpoint); err != nil {
+			return nil, err
+		}
+		return b.inner.Aggregate(opts, chunk)
+	})
+}
+
+// fairSemaphore is a semaphore whose waiters are served one pool at a
+// time, in turn, and in order within a pool.
+type fairSemaphore struct {
+	lock sync.Mutex
+	size int
+	held int
+	// Pools with waiters, in turn
+	turns   []budgetKey
+	waiting map[budgetKey][]chan struct{}
+}
+
+func newFairSemaphore(size int) *fairSemaphore {
+	return &fairSemaphore{size: size, waiting: make(map[budgetKey][]chan struct{})}
+}
+
+func (s *fairSemaphore) acquire(ctx context.Context, pool budgetKey) error {
+	s.lock.Lock()
+	if s.held < s.size && len(s.turns) == 0 {
+		s.held++
+		s.lock.Unlock()
+		return nil
+	}
+	granted := make(chan struct{})
+	if len(s.waiting[pool]) == 0 {
+		s.turns = append(s.turns, pool)
+	}
+	s.waiting[pool] = append(s.waiting[pool], granted)
+	s.lock.Unlock()
+
+	select {
+	case <-granted:
+		return nil
+	case <-ctx.Done():
+		s.lock.Lock()
+		waiting := s.remove(pool, granted)
+		s.lock.Unlock()
+		if !waiting {
+			// Granted meanwhile
+			s.release()
+		}
+		return ctx.Err()
+	}
+}
+
+// remove removes a waiter of pool and reports whether it was waiting.
+// Callers hold the lock.
+func (s *fairSemaphore) remove(pool budgetKey, granted chan struct{}) bool {
+	waiters := s.waiting[pool]
+	for i := range waiters {
+		if waiters[i] != granted {
+			continue
+		}
+		s.waiting[pool] = append(waiters[:i], waiters[i+1:]...)
+		if len(s.waiting[pool]) == 0 {
+			delete(s.waiting, pool)
+			for j := range s.turns {
+				if s.turns[j] == pool {
+					s.turns = append(s.turns[:j], s.turns[j+1:]...)
+					break
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// release hands the slot to the first waiter of the next pool, if any.
+func (s *fairSemaphore) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.turns) == 0 {
+		s.held--
+		return
+	}
+	pool := s.turns[0]
+	s.turns = s.turns[1:]
+	waiters := s.waiting[pool]
+	granted := waiters[0]
+	if len(waiters) > 1 {
+		s.waiting[pool] = waiters[1:]
+		s.turns = append(s.turns, pool)
+	} else {
+		delete(s.waiting, pool)
+	}
+	close(granted)
+}
+
+// rateLimiter is a token bucket allowing rate requests per second, in
+// bursts of up to a second of them.
+type rateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait reserves a request and waits for its turn.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	r.lock.Lock()
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+	r.tokens--
+	delay := time.Duration(-r.tokens / r.rate * float64(time.Second))
+	r.lock.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// formatBudgetUsage formats the usage of a pool for logs.
+func formatBudgetUsage(u BudgetUsage) string {
+	return fmt.Sprintf("%d executions waiting %v, %d multicalls waiting %v", u.Executions, u.ExecutionWait.Round(time.Millisecond), u.Multicalls, u.MulticallWait.Round(time.Millisecond))
+}
@@ -1,14 +1,14 @@
 package liquidatoor
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"math/big"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 
 	"github.com/kargakis/liquidatoor/pkg/abis"
@@ -18,106 +18,426 @@ type Borrower struct {
 	Address   common.Address
 	Assets    []common.Address
 	Shortfall *big.Int
+
+	// Packed getAccountLiquidity call
+	liquidityCallData []byte
 }
 
 type BorrowerCache struct {
+	logger   Logger
 	interval time.Duration
 
 	lock      *sync.RWMutex
 	borrowers []Borrower
+	// Borrowers were read once, from the pool or the store
+	primed bool
 
-	multicall          *abis.Multicall
+	batcher            CallBatcher
 	comptrollerAddress common.Address
-	comptroller        *abis.Comptroller
+	comptroller        Comptroller
 	comptrollerABI     *abi.ABI
+	// Used instead of getAllBorrowers when the comptroller lacks it
+	scanner *accountScanner
+	// Monitored instead of the borrowers of the pool, if set
+	accounts *accountList
+	// Persists every borrower, if set; only borrowers that entered
+	// markets, the only ones that can be liquidated, are then held in
+	// memory
+	store *borrowerStore
+	// Borrowers held, and persisted, by the cache
+	shard Shard
+	// Borrowers observed from events while a refresh is running, to
+	// apply on top of it
+	observed map[common.Address]Borrower
+	// Borrowers left out of memory as they cannot be liquidated, until
+	// their assets change; see Park
+	parked map[common.Address]parkedBorrower
+}
+
+// parkedBorrower is a borrower of a class other than AccountCandidate,
+// with the assets it was classified with.
+type parkedBorrower struct {
+	class  AccountClass
+	assets []common.Address
 }
 
 func NewBorrowerCache(
+	logger Logger,
 	interval time.Duration,
-	multicall *abis.Multicall,
-	comptroller *abis.Comptroller,
+	batcher CallBatcher,
+	comptrollerAddress common.Address,
+	comptroller Comptroller,
 	comptrollerABI *abi.ABI,
 ) *BorrowerCache {
 	return &BorrowerCache{
+		logger:   logger,
 		interval: interval,
 
 		lock:      &sync.RWMutex{},
 		borrowers: make([]Borrower, 0),
+		parked:    make(map[common.Address]parkedBorrower),
 
-		multicall:          multicall,
-		comptrollerAddress: common.HexToAddress(os.Getenv("COMPTROLLER_ADDRESS")),
+		batcher:            batcher,
+		comptrollerAddress: comptrollerAddress,
 		comptroller:        comptroller,
 		comptrollerABI:     comptrollerABI,
 	}
 }
 
-func (c *BorrowerCache) Init() {
-	if err := c.run(); err != nil {
-		log.Printf("Failed to prime borrower cache: %v", err)
+// Prime populates the cache once, from the store if it has the
+// borrowers of the pool.
+func (c *BorrowerCache) Prime(ctx context.Context) {
+	// The store may hold borrowers that are not listed
+	if c.store != nil && c.accounts == nil {
+		loaded, err := c.load(ctx)
+		if err != nil {
+			c.logger.Error(fmt.Sprintf("Failed to load borrower cache: %v", err), F("pool", c.comptrollerAddress), F("err", err))
+		}
+		if loaded > 0 {
+			c.logger.Info(fmt.Sprintf("Loaded %d borrowers from the store", loaded), F("pool", c.comptrollerAddress), F("borrowers", loaded))
+			return
+		}
+	}
+	if err := c.run(ctx); err != nil {
+		c.logger.Error(fmt.Sprintf("Failed to prime borrower cache: %v", err), F("pool", c.comptrollerAddress), F("err", err))
+	}
+}
+
+// Init updates the cache periodically until ctx is cancelled, priming
+// it first unless it already is.
+func (c *BorrowerCache) Init(ctx context.Context) {
+	if !c.Primed() {
+		c.Prime(ctx)
 	}
-	for range time.Tick(c.interval) {
-		if err := c.run(); err != nil {
-			log.Printf("Failed to update borrower cache: %v", err)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	reloads := c.accounts.subscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+		case <-reloads:
+		}
+		if err := c.run(ctx); err != nil {
+			c.logger.Error(fmt.Sprintf("Failed to update borrower cache: %v", err), F("pool", c.comptrollerAddress), F("err", err))
 		}
 	}
 }
 
-func (c *BorrowerCache) run() error {
-	log.Print("Initiating a borrower cache update...")
+func (c *BorrowerCache) run(ctx context.Context) error {
+	c.logger.Info("Initiating a borrower cache update...", F("pool", c.comptrollerAddress))
+
+	c.lock.Lock()
+	c.observed = make(map[common.Address]Borrower)
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		c.observed = nil
+		c.lock.Unlock()
+	}()
 
-	borrowers, err := c.comptroller.GetAllBorrowers(noOpts)
+	borrowers, err := c.getAllBorrowers(ctx)
+	if err != nil {
+		return err
+	}
+	newBorrowers, err := c.fetch(ctx, borrowers)
 	if err != nil {
-		return fmt.Errorf("cannot get all borrowers: %w", err)
+		return err
+	}
+	if c.accounts != nil {
+		outside := 0
+		for _, borrower := range newBorrowers {
+			if len(borrower.Assets) == 0 {
+				outside++
+			}
+		}
+		if outside > 0 {
+			c.logger.Info(fmt.Sprintf("%d of %d listed accounts are in no market of the pool", outside, len(borrowers)), F("pool", c.comptrollerAddress), F("accounts", outside))
+		}
+	}
+
+	if c.store != nil {
+		if err := c.store.replace(ctx, c.comptrollerAddress, newBorrowers); err != nil {
+			return err
+		}
+	}
+
+	c.lock.Lock()
+	hot := make([]Borrower, 0, len(newBorrowers))
+	for _, borrower := range newBorrowers {
+		if c.hold(borrower) {
+			hot = append(hot, borrower)
+		}
 	}
+	c.borrowers = hot
+	c.primed = true
+	// Events may postdate the borrowers read
+	observed := make([]Borrower, 0, len(c.observed))
+	for _, borrower := range c.observed {
+		observed = append(observed, borrower)
+	}
+	c.upsert(observed)
+	c.lock.Unlock()
+	if c.store != nil && len(observed) > 0 {
+		if err := c.store.put(ctx, c.comptrollerAddress, observed); err != nil {
+			return err
+		}
+	}
+
+	c.logger.Info("Borrower cache update complete.", F("pool", c.comptrollerAddress))
+	return nil
+}
 
-	calls := []abis.MulticallCall{}
+// fetch returns the borrowers with their assets, skipping the ones
+// whose assets cannot be read.
+func (c *BorrowerCache) fetch(ctx context.Context, borrowers []common.Address) ([]Borrower, error) {
+	calls := make([]abis.MulticallCall, 0, len(borrowers))
 	method := c.comptrollerABI.Methods["getAssetsIn"]
+	liquidityMethod := c.comptrollerABI.Methods["getAccountLiquidity"]
 
+	// Both methods take the borrower only, so the inputs are shared
+	inputs := make([][]byte, 0, len(borrowers))
 	for _, borrower := range borrowers {
-		inputs, err := method.Inputs.Pack(borrower)
+		packed, err := method.Inputs.Pack(borrower)
 		if err != nil {
-			return fmt.Errorf("cannot pack borrower: %w", err)
+			return nil, fmt.Errorf("cannot pack borrower: %w", err)
 		}
+		inputs = append(inputs, packed)
 		calls = append(calls, abis.MulticallCall{
 			Target:   c.comptrollerAddress,
-			CallData: append(method.ID[:], inputs[:]...),
+			CallData: append(method.ID[:len(method.ID):len(method.ID)], packed...),
 		})
 	}
 
-	resp, err := c.multicall.Aggregate(noOpts, calls)
+	resp, err := c.batcher.Aggregate(&bind.CallOpts{Context: ctx}, calls)
 	if err != nil {
-		return fmt.Errorf("failed multicall request: %v", err)
+		return nil, fmt.Errorf("failed batch request: %v", err)
 	}
 
-	newBorrowers := make([]Borrower, len(borrowers))
-	for i, data := range resp.ReturnData {
-		out, err := method.Outputs.Unpack(data)
-		if err != nil {
-			return fmt.Errorf("cannot unpack output: %v", err)
+	newBorrowers := make([]Borrower, 0, len(borrowers))
+	for i, result := range resp {
+		if !result.Success {
+			c.logger.Warn(fmt.Sprintf("Failed to get assets of borrower %s", borrowers[i]), F("pool", c.comptrollerAddress), F("borrower", borrowers[i]))
+			continue
+		}
+		var assets []common.Address
+		if err := c.comptrollerABI.UnpackIntoInterface(&assets, method.Name, result.ReturnData); err != nil {
+			return nil, fmt.Errorf("cannot unpack output: %v", err)
+		}
+		newBorrowers = append(newBorrowers, Borrower{
+			Address:           borrowers[i],
+			Assets:            assets,
+			liquidityCallData: append(liquidityMethod.ID[:len(liquidityMethod.ID):len(liquidityMethod.ID)], inputs[i]...),
+		})
+	}
+	return newBorrowers, nil
+}
+
+// Observe adds borrowers of the shard, and of the account list if
+// any, named by pool events, or
+// refreshes their assets if cached, until the next refresh confirms
+// them, and returns them. Observing a borrower again is harmless.
+func (c *BorrowerCache) Observe(ctx context.Context, accounts []common.Address) ([]Borrower, error) {
+	accounts = c.accounts.filter(c.shard.filter(accounts))
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+	borrowers, err := c.fetch(ctx, accounts)
+	if err != nil || len(borrowers) == 0 {
+		return nil, err
+	}
+	if c.store != nil {
+		if err := c.store.put(ctx, c.comptrollerAddress, borrowers); err != nil {
+			return nil, err
 		}
-		assets := *abi.ConvertType(out[0], new([]common.Address)).(*[]common.Address)
-		newBorrowers[i] = Borrower{Address: borrowers[i], Assets: assets}
 	}
 
 	c.lock.Lock()
-	c.borrowers = newBorrowers
-	c.lock.Unlock()
+	defer c.lock.Unlock()
+	c.upsert(borrowers)
+	if c.observed != nil {
+		for _, borrower := range borrowers {
+			c.observed[borrower.Address] = borrower
+		}
+	}
+	return borrowers, nil
+}
 
-	log.Print("Borrower cache update complete.")
-	return nil
+// Park leaves borrowers out of memory as they cannot be liquidated,
+// until their assets change or Unpark. Every class but
+// AccountCandidate is parked.
+func (c *BorrowerCache) Park(borrowers []Borrower, class AccountClass) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, borrower := range borrowers {
+		c.parked[borrower.Address] = parkedBorrower{class: class, assets: borrower.Assets}
+	}
+	c.upsert(nil)
 }
 
-func (c *BorrowerCache) Read() []Borrower {
-	borrowers := make([]Borrower, len(c.borrowers))
+// Unpark holds parked borrowers in memory again.
+func (c *BorrowerCache) Unpark(borrowers []Borrower) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, borrower := range borrowers {
+		delete(c.parked, borrower.Address)
+	}
+	c.upsert(borrowers)
+}
+
+// Class returns the class of a borrower: the class it was parked
+// with, or AccountCandidate.
+func (c *BorrowerCache) Class(account common.Address) AccountClass {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if parked, ok := c.parked[account]; ok {
+		return parked.class
+	}
+	return AccountCandidate
+}
+
+// hold reports whether borrower is held in memory: with a store, only
+// borrowers that entered markets are, and parked borrowers never are
+// until their assets change. Callers hold the lock.
+func (c *BorrowerCache) hold(borrower Borrower) bool {
+	if parked, ok := c.parked[borrower.Address]; ok {
+		if sameAssets(parked.assets, borrower.Assets) {
+			return false
+		}
+		delete(c.parked, borrower.Address)
+	}
+	return c.store == nil || len(borrower.Assets) > 0
+}
+
+func sameAssets(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	assets := make(map[common.Address]bool, len(a))
+	for _, asset := range a {
+		assets[asset] = true
+	}
+	for _, asset := range b {
+		if !assets[asset] {
+			return false
+		}
+	}
+	return true
+}
+
+// upsert replaces or appends borrowers in memory, of the ones held.
+// Callers hold the lock.
+func (c *BorrowerCache) upsert(borrowers []Borrower) {
+	hold := c.hold
+	updates := make(map[common.Address]Borrower, len(borrowers))
+	for _, borrower := range borrowers {
+		updates[borrower.Address] = borrower
+	}
+	held := make([]Borrower, 0, len(c.borrowers)+len(updates))
+	for _, borrower := range c.borrowers {
+		if update, ok := updates[borrower.Address]; ok {
+			delete(updates, borrower.Address)
+			borrower = update
+		}
+		if hold(borrower) {
+			held = append(held, borrower)
+		}
+	}
+	for _, borrower := range borrowers {
+		if update, ok := updates[borrower.Address]; ok && hold(update) {
+			held = append(held, update)
+		}
+		delete(updates, borrower.Address)
+	}
+	c.borrowers = held
+}
 
+func (c *BorrowerCache) getAllBorrowers(ctx context.Context) ([]common.Address, error) {
+	if c.accounts != nil {
+		return c.shard.filter(c.accounts.Accounts()), nil
+	}
+	if c.scanner != nil {
+		borrowers, err := c.scanner.Accounts(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot scan borrowers: %w", err)
+		}
+		return borrowers, nil
+	}
+
+	borrowers, err := c.comptroller.GetAllBorrowers(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get all borrowers: %w", err)
+	}
+	return c.shard.filter(borrowers), nil
+}
+
+// Primed reports whether the borrowers were read once, from the pool
+// or the store, even if there were none.
+func (c *BorrowerCache) Primed() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.primed
+}
+
+func (c *BorrowerCache) Read() []Borrower {
 	c.lock.RLocker().Lock()
+	borrowers := make([]Borrower, len(c.borrowers))
 	for i := range c.borrowers {
 		borrowers[i] = Borrower{
-			Address: c.borrowers[i].Address,
-			Assets:  c.borrowers[i].Assets,
+			Address:           c.borrowers[i].Address,
+			Assets:            c.borrowers[i].Assets,
+			liquidityCallData: c.borrowers[i].liquidityCallData,
 		}
 	}
 	c.lock.RLocker().Unlock()
 
 	return borrowers
 }
+
+// load holds the borrowers of the store that entered markets in
+// memory and returns how many borrowers the store has.
+func (c *BorrowerCache) load(ctx context.Context) (int, error) {
+	liquidityMethod := c.comptrollerABI.Methods["getAccountLiquidity"]
+	hot := make([]Borrower, 0)
+	stored := 0
+	err := c.store.each(ctx, c.comptrollerAddress, func(borrower Borrower) error {
+		stored++
+		// The store may name borrowers of another shard
+		if len(borrower.Assets) == 0 || !c.shard.Owns(borrower.Address) {
+			return nil
+		}
+		packed, err := liquidityMethod.Inputs.Pack(borrower.Address)
+		if err != nil {
+			return fmt.Errorf("cannot pack borrower: %w", err)
+		}
+		borrower.liquidityCallData = append(liquidityMethod.ID[:len(liquidityMethod.ID):len(liquidityMethod.ID)], packed...)
+		hot = append(hot, borrower)
+		return nil
+	})
+	if err != nil || stored == 0 {
+		return 0, err
+	}
+
+	c.lock.Lock()
+	c.borrowers, c.primed = hot, true
+	c.lock.Unlock()
+	return stored, nil
+}
+
+// Each calls fn with every borrower, including the ones only in the
+// store, without loading them all at once. It stops at the first
+// error fn returns.
+func (c *BorrowerCache) Each(ctx context.Context, fn func(Borrower) error) error {
+	if c.store != nil {
+		return c.store.each(ctx, c.comptrollerAddress, fn)
+	}
+	for _, borrower := range c.Read() {
+		if err := fn(borrower); err != nil {
+			return err
+		}
+	}
+	return nil
+}
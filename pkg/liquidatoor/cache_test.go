@@ -0,0 +1,76 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/fakes"
+)
+
+// countingComptroller counts the reads of the borrowers of the pool.
+type countingComptroller struct {
+	fakes.Comptroller
+	reads int
+}
+
+func (c *countingComptroller) GetAllBorrowers(opts *bind.CallOpts) ([]common.Address, error) {
+	c.reads++
+	return c.Comptroller.GetAllBorrowers(opts)
+}
+
+func newTestBorrowerCache(t *testing.T, comptroller Comptroller) *BorrowerCache {
+	t.Helper()
+	comptrollerABI, err := abis.ComptrollerMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBorrowerCache(NewStdLogger(), time.Hour, liquidityBatcher{}, common.HexToAddress("0xc0"), comptroller, comptrollerABI)
+}
+
+// initOnce runs Init until it is done priming.
+func initOnce(c *BorrowerCache) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Init(ctx)
+}
+
+func TestBorrowerCacheInitPrimes(t *testing.T) {
+	// A pool without borrowers is primed too
+	comptroller := &countingComptroller{}
+	c := newTestBorrowerCache(t, comptroller)
+	initOnce(c)
+	if comptroller.reads != 1 {
+		t.Fatalf("expected Init to prime the cache, got %d reads", comptroller.reads)
+	}
+	initOnce(c)
+	if comptroller.reads != 1 {
+		t.Fatalf("expected Init not to prime a primed cache, got %d reads", comptroller.reads)
+	}
+}
+
+func TestBorrowerCacheInitSkipsPrimed(t *testing.T) {
+	comptroller := &countingComptroller{}
+	c := newTestBorrowerCache(t, comptroller)
+	c.Prime(context.Background())
+	initOnce(c)
+	if comptroller.reads != 1 {
+		t.Fatalf("expected Init not to prime again, got %d reads", comptroller.reads)
+	}
+}
+
+func TestBorrowerCacheInitRetriesFailedPrime(t *testing.T) {
+	comptroller := &countingComptroller{Comptroller: fakes.Comptroller{Err: errors.New("unavailable")}}
+	c := newTestBorrowerCache(t, comptroller)
+	c.Prime(context.Background())
+	comptroller.Err = nil
+	initOnce(c)
+	if comptroller.reads != 2 {
+		t.Fatalf("expected Init to prime after a failed prime, got %d reads", comptroller.reads)
+	}
+}
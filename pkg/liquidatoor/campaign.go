@@ -0,0 +1,127 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Rounds a campaign is continued for at most, in case liquidating the
+// account never brings it above water
+const maxCampaignRounds = 16
+
+// Campaign links the liquidations of an account that one repay, capped
+// by the close factor, did not bring above water. Every executed
+// liquidation is the first round of its own campaign, and the rounds
+// after it are planned against the state the previous round left.
+type Campaign struct {
+	// Transaction of the first round
+	ID    common.Hash
+	Round int
+}
+
+// nextRound evaluates the account of a successful liquidation again at
+// the latest block and, if it is still liquidatable, queues the next
+// round of its campaign, through the same checks as every candidate.
+func (l *Liquidatoor) nextRound(ctx context.Context, job Job, outcome *Outcome) {
+	campaign := outcome.Campaign
+	if campaign == nil || ctx.Err() != nil {
+		return
+	}
+	account := job.Candidate.Account
+	fields := []Field{F("pool", l.comptrollerAddress), F("account", account), F("campaign", campaign.ID), F("round", campaign.Round)}
+	if campaign.Round >= maxCampaignRounds {
+		l.logger.Warn(fmt.Sprintf("Campaign %s of account %s stopped after %d rounds", campaign.ID, account, campaign.Round), fields...)
+		return
+	}
+
+	l.checkLock.Lock()
+	defer l.checkLock.Unlock()
+	last := l.lastStart
+	if last == nil {
+		return
+	}
+	borrower, err := l.underwater(ctx, account)
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Cannot evaluate round %d of campaign %s of account %s: %v", campaign.Round+1, campaign.ID, account, err), append(fields, F("err", err))...)
+		return
+	}
+	if borrower == nil {
+		l.logger.Info(fmt.Sprintf("Campaign %s of account %s complete after %d rounds", campaign.ID, account, campaign.Round), fields...)
+		return
+	}
+
+	header, err := l.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Cannot get latest block: %v", err), append(fields, F("err", err))...)
+		return
+	}
+	prices, err := pricesOf(ctx, l.logger, l.priceSource, l.assets(last.markets), nil)
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Cannot get prices: %v", err), append(fields, F("err", err))...)
+		return
+	}
+	start := &blockStart{
+		borrowers:     []Borrower{*borrower},
+		markets:       last.markets,
+		prices:        prices,
+		averagePrices: last.averagePrices,
+		snapshot:      l.snapshot(header.Number, last.markets, prices, last),
+		inventory:     last.inventory,
+	}
+	l.campaigns[account] = Campaign{ID: campaign.ID, Round: campaign.Round + 1}
+	defer delete(l.campaigns, account)
+	f, err := l.evaluate(ctx, header.Number, start, []Borrower{*borrower}, ExecuteQueued)
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Cannot evaluate round %d of campaign %s of account %s: %v", campaign.Round+1, campaign.ID, account, err), append(fields, F("err", err))...)
+		return
+	}
+	if f.liquidatable == 0 {
+		l.logger.Info(fmt.Sprintf("Campaign %s of account %s stopped after %d rounds, still underwater by %s; dropped %s", campaign.ID, account, campaign.Round, formatValue(borrower.Shortfall), formatDropped(f.dropped)),
+			append(fields, F("shortfall", borrower.Shortfall), F("dropped", f.dropped))...)
+		return
+	}
+	l.logger.Info(fmt.Sprintf("Queued round %d of campaign %s of account %s, still underwater by %s", campaign.Round+1, campaign.ID, account, formatValue(borrower.Shortfall)),
+		append(fields, F("shortfall", borrower.Shortfall))...)
+}
+
+// underwater reads the markets and the liquidity of account at the
+// latest block, and returns it as a borrower if it is underwater.
+func (l *Liquidatoor) underwater(ctx context.Context, account common.Address) (*Borrower, error) {
+	assetsMethod := l.comptrollerABI.Methods["getAssetsIn"]
+	liquidityMethod := l.comptrollerABI.Methods["getAccountLiquidity"]
+	inputs, err := assetsMethod.Inputs.Pack(account)
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack account: %w", err)
+	}
+	calls := []abis.MulticallCall{
+		{Target: l.comptrollerAddress, CallData: append(assetsMethod.ID[:len(assetsMethod.ID):len(assetsMethod.ID)], inputs...)},
+		{Target: l.comptrollerAddress, CallData: append(liquidityMethod.ID[:len(liquidityMethod.ID):len(liquidityMethod.ID)], inputs...)},
+	}
+	resp, err := l.Batcher.Aggregate(&bind.CallOpts{Context: ctx}, calls)
+	if err != nil {
+		return nil, fmt.Errorf("failed batch request: %v", err)
+	}
+	if !resp[0].Success || !resp[1].Success {
+		return nil, fmt.Errorf("cannot get liquidity of account %s", account)
+	}
+	var assets []common.Address
+	if err := l.comptrollerABI.UnpackIntoInterface(&assets, assetsMethod.Name, resp[0].ReturnData); err != nil {
+		return nil, fmt.Errorf("cannot unpack output: %v", err)
+	}
+	liquidity := accountLiquidity(resp[1].ReturnData)
+	if err := liquidity.validate(); err != nil {
+		return nil, err
+	}
+	if liquidity.failed() {
+		return nil, fmt.Errorf("cannot get liquidity of account %s: %s", account, liquidity.failure())
+	}
+	if !liquidity.underwater() {
+		return nil, nil
+	}
+	return &Borrower{Address: account, Assets: assets, Shortfall: liquidity.shortfall(), liquidityCallData: calls[1].CallData}, nil
+}
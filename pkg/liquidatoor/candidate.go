@@ -0,0 +1,142 @@
+package liquidatoor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Candidate is an account eligible for liquidation, regardless of
+// the protocol of the pool it was found in.
+type Candidate struct {
+	// Comptroller, or Comet, address of the pool
+	Pool     common.Address
+	Protocol string
+	Account  common.Address
+	// Not every protocol reports the shortfall
+	Shortfall *big.Int
+	// Nil if unknown; see healthFactor
+	HealthFactor *Ratio
+	// Block the candidate was found in, if known
+	Block *big.Int
+
+	// Plan of the primary strategy and its predicted profit, if any,
+	// to compare against the realized profit
+	Plan     *LiquidationPlan
+	Estimate *ProfitEstimate
+	// Why the account is not liquidated, if known; see DropReason
+	Err error
+	// AccountBadDebt if the collateral of the account is not worth
+	// liquidating, once its positions are read
+	Class AccountClass `json:",omitempty"`
+	// Transfers are paused in the pool, so seized collateral cannot be
+	// moved or swapped until they resume
+	CollateralLocked bool
+	// Someone else's pending liquidation of the account, if seen; see
+	// PendingLiquidation.Outbid
+	Competitor *PendingLiquidation
+	// Bounds the swap of the seized collateral when repaying a flash
+	// loan
+	Swap *SwapLimit
+	// What we know about the account, if annotated
+	Annotation *Annotation
+	// The collateral market cannot pay out the account, if so
+	Illiquid *IlliquidCollateral
+	// How the plan was adjusted to the cash of its collateral market,
+	// if it was
+	Redemption *Redemption
+	// Round of a campaign after the first, if so
+	Campaign *Campaign
+	// Why the candidate was ranked and decided as it was
+	Explanation *Explanation
+
+	// Snapshot the plan was made from, to estimate it again if the
+	// wallet cannot repay all of it; unset in decoded candidates
+	snapshot *Snapshot
+}
+
+// MarshalJSON encodes the candidate with its integers as decimal
+// strings, and its error as its message and drop reason.
+func (c Candidate) MarshalJSON() ([]byte, error) {
+	type candidate Candidate
+	v := struct {
+		candidate
+		Shortfall *decimalInt
+		Block     *decimalInt
+		Err       string `json:",omitempty"`
+		Reason    string `json:",omitempty"`
+	}{candidate: candidate(c), Shortfall: decimal(c.Shortfall), Block: decimal(c.Block), Reason: DropReason(c.Err)}
+	if c.Err != nil {
+		v.Err = c.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a candidate encoded by MarshalJSON. Its error
+// only keeps the message.
+func (c *Candidate) UnmarshalJSON(data []byte) error {
+	type candidate Candidate
+	v := struct {
+		*candidate
+		Shortfall *decimalInt
+		Block     *decimalInt
+		Err       string
+	}{candidate: (*candidate)(c)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c.Shortfall, c.Block, c.Err = v.Shortfall.int(), v.Block.int(), nil
+	if v.Err != "" {
+		c.Err = errors.New(v.Err)
+	}
+	return nil
+}
+
+// journalCandidate records what was known of a candidate in block and
+// whether it is liquidated.
+func journalCandidate(j *Journal, block *big.Int, c Candidate) {
+	for _, entry := range candidateEntries(block, c) {
+		j.Record(entry)
+	}
+}
+
+// candidateEntries returns the candidate and decision entries of a
+// candidate in block.
+func candidateEntries(block *big.Int, c Candidate) []JournalEntry {
+	candidate := JournalEntry{Kind: JournalCandidate, Pool: c.Pool, Block: block, Account: c.Account,
+		Data: JournalCandidateData{Shortfall: c.Shortfall, HealthFactor: c.HealthFactor, Plan: c.Plan, Estimate: c.Estimate, Locked: c.CollateralLocked, Annotation: c.Annotation, Redemption: c.Redemption}}
+	decision := JournalEntry{Kind: JournalDecision, Pool: c.Pool, Block: block, Account: c.Account, Decision: "liquidate"}
+	if c.Err != nil {
+		decision.Decision, decision.Reason, decision.Err = "drop", DropReason(c.Err), c.Err.Error()
+	}
+	if c.Explanation != nil {
+		decision.Data = c.Explanation
+	}
+	return []JournalEntry{candidate, decision}
+}
+
+func reportCandidate(logger Logger, c Candidate) {
+	fields := []Field{F("pool", c.Pool), F("protocol", c.Protocol), F("account", c.Account)}
+	if c.Shortfall == nil {
+		logger.Info(fmt.Sprintf("Account %s is liquidatable in %s pool %s", c.Account, c.Protocol, c.Pool), fields...)
+	} else if c.HealthFactor == nil {
+		logger.Info(fmt.Sprintf("Account %s is underwater by %s in %s pool %s", c.Account, formatValue(c.Shortfall), c.Protocol, c.Pool), append(fields, F("shortfall", c.Shortfall))...)
+	} else {
+		logger.Info(fmt.Sprintf("Account %s is underwater by %s with a health factor of %s in %s pool %s", c.Account, formatValue(c.Shortfall), c.HealthFactor, c.Protocol, c.Pool),
+			append(fields, F("shortfall", c.Shortfall), F("healthFactor", c.HealthFactor.String()))...)
+	}
+	if c.Estimate != nil {
+		logger.Info(fmt.Sprintf("Account %s liquidation estimated by %s at %s", c.Account, c.Estimate.Estimator, c.Estimate),
+			append(fields, F("estimator", c.Estimate.Estimator), F("net", c.Estimate.Net), F("currency", c.Estimate.Currency))...)
+	}
+	if c.Err != nil {
+		logger.Info(fmt.Sprintf("Account %s dropped (%s): %v", c.Account, DropReason(c.Err), c.Err),
+			append(fields, F("reason", DropReason(c.Err)), F("err", c.Err))...)
+	}
+	if c.Explanation != nil {
+		logger.Debug(fmt.Sprintf("Account %s decided %s", c.Account, c.Explanation), append(fields, F("explanation", c.Explanation))...)
+	}
+}
@@ -0,0 +1,62 @@
+package liquidatoor
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCandidateJSONRoundTrip(t *testing.T) {
+	for _, value := range largeInts {
+		if value.Sign() < 0 {
+			continue
+		}
+		health := mantissaRatio(value)
+		c := Candidate{
+			Pool:         common.HexToAddress("0xc0"),
+			Account:      common.HexToAddress("0x1000"),
+			Shortfall:    value,
+			HealthFactor: &health,
+			Block:        value,
+			Plan: &LiquidationPlan{
+				Borrower:     common.HexToAddress("0x1000"),
+				BorrowMarket: common.HexToAddress("0xa"),
+				RepayAmount:  value,
+				RepayValue:   value,
+				SeizeValue:   value,
+			},
+			Err: ErrInsufficientInventory,
+		}
+		data, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var generic map[string]interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			t.Fatal(err)
+		}
+		if s, ok := generic["Shortfall"].(string); !ok || s != value.String() {
+			t.Fatalf("expected shortfall %v encoded as a string, got %s", value, data)
+		}
+		if s, ok := generic["Plan"].(map[string]interface{})["RepayAmount"].(string); !ok || s != value.String() {
+			t.Fatalf("expected repay amount %v encoded as a string, got %s", value, data)
+		}
+
+		var decoded Candidate
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Shortfall.Cmp(value) != 0 || decoded.Block.Cmp(value) != 0 || decoded.HealthFactor.Mantissa().Cmp(value) != 0 {
+			t.Fatalf("expected shortfall, block and health factor %v, got %v, %v and %v", value, decoded.Shortfall, decoded.Block, decoded.HealthFactor.Mantissa())
+		}
+		if decoded.Plan.String() != c.Plan.String() {
+			t.Fatalf("expected plan %v, got %v", c.Plan, decoded.Plan)
+		}
+		// Only the message of the error is kept
+		if decoded.Err == nil || decoded.Err.Error() != c.Err.Error() || errors.Is(decoded.Err, ErrInsufficientInventory) {
+			t.Fatalf("expected error message %q, got %v", c.Err, decoded.Err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package liquidatoor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Waits before running a chain again once it stops, doubling up to
+	// the maximum; a chain that ran for the maximum waits the minimum
+	chainMinRetry = 5 * time.Second
+	chainMaxRetry = 5 * time.Minute
+)
+
+var chainName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ConfigsFromFile reads the configuration of every chain of the
+// chains file at path: sections of settings, in the format of
+// .env.example, named after their chain, eg.,
+//
+//	DATA_DIR=/var/lib/liquidatoor
+//
+//	[ethereum]
+//	NODE_API_URL=wss://...
+//	COMPTROLLER_ADDRESS=0x...
+//
+//	[bsc]
+//	NODE_API_URL=wss://...
+//
+// Settings before the first section apply to every chain, and the
+// environment to the settings in neither. The state of every chain is
+// kept under DATA_DIR/<chain>, so every chain has its own kill switch.
+func ConfigsFromFile(path string) ([]*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open chains file: %w", err)
+	}
+	defer f.Close()
+
+	shared := make(map[string]string)
+	sections := make(map[string]map[string]string)
+	var names []string
+	current := shared
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if !chainName.MatchString(name) {
+				return nil, fmt.Errorf("%w: invalid chain name %q on line %d of %s", ErrInvalidConfig, name, number, path)
+			}
+			if _, ok := sections[name]; ok {
+				return nil, fmt.Errorf("%w: chain %s repeated on line %d of %s", ErrInvalidConfig, name, number, path)
+			}
+			current = make(map[string]string)
+			sections[name] = current
+			names = append(names, name)
+		default:
+			key, value, ok := cutSetting(line)
+			if !ok {
+				return nil, fmt.Errorf("%w: expected KEY=VALUE on line %d of %s", ErrInvalidConfig, number, path)
+			}
+			current[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read chains file: %w", err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no chain section in %s", ErrInvalidConfig, path)
+	}
+
+	cfgs := make([]*Config, 0, len(names))
+	for _, name := range names {
+		env := make(map[string]string, len(shared)+len(sections[name]))
+		for key, value := range shared {
+			env[key] = value
+		}
+		for key, value := range sections[name] {
+			env[key] = value
+		}
+		cfg := &Config{Chain: name, env: env}
+		if err := cfg.readEnv(); err != nil {
+			return nil, fmt.Errorf("%w: chain %s: %v", ErrInvalidConfig, name, err)
+		}
+		if cfg.DataDir != "" {
+			cfg.DataDir = filepath.Join(cfg.DataDir, name)
+		}
+		cfg.applyDataDir()
+		cfgs = append(cfgs, cfg)
+	}
+	if err := validateChains(cfgs); err != nil {
+		return nil, err
+	}
+	// The budget is process-wide
+	if limits := cfgs[0].BudgetLimits; limits.limited() {
+		budget := NewBudget(limits)
+		for _, cfg := range cfgs {
+			cfg.Budget = budget
+		}
+	}
+	return cfgs, nil
+}
+
+// cutSetting splits a KEY=VALUE line, unquoting the value.
+func cutSetting(line string) (string, string, bool) {
+	i := strings.Index(line, "=")
+	if i <= 0 {
+		return "", "", false
+	}
+	key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return key, value, true
+}
+
+// validateChains fails if chains share a state file, which would mix
+// their kill switches, pending transactions and journals, or the
+// readiness address, or if their budgets differ, as it is process-wide.
+func validateChains(cfgs []*Config) error {
+	seen := make(map[string]string)
+	for _, cfg := range cfgs {
+		if cfg.BudgetLimits != cfgs[0].BudgetLimits {
+			return fmt.Errorf("%w: chains %s and %s set different BUDGET_ settings, which are shared by every chain and belong before the first section", ErrInvalidConfig, cfgs[0].Chain, cfg.Chain)
+		}
+		for _, setting := range []struct {
+			name  string
+			value string
+		}{
+			{"LEDGER_PATH", cfg.LedgerPath},
+			{"PENDING_TX_PATH", cfg.PendingTxPath},
+			{"JOURNAL_PATH", cfg.JournalPath},
+			{"BORROWER_CACHE_PATH", cfg.BorrowerCachePath},
+			{"HISTORY_PATH", cfg.HistoryPath},
+			{"ALERTS_PATH", cfg.AlertsPath},
+			{"ROLE_PATH", cfg.RolePath},
+			{"REMOTE_CONFIG_PATH", cfg.RemoteConfigPath},
+			{"READINESS_ADDRESS", cfg.ReadinessAddress},
+		} {
+			if setting.value == "" {
+				continue
+			}
+			key := setting.name + "=" + setting.value
+			if chain, ok := seen[key]; ok {
+				return fmt.Errorf("%w: chains %s and %s share %s %s", ErrInvalidConfig, chain, cfg.Chain, setting.name, setting.value)
+			}
+			seen[key] = cfg.Chain
+		}
+	}
+	return nil
+}
+
+// RunChains runs every chain of cfgs in one process, as Run, until ctx
+// is cancelled. Chains are independent: one that fails to connect or
+// stops is logged and run again after a backoff, while the others keep
+// running. A chain whose config is invalid is not run again.
+func RunChains(ctx context.Context, cfgs []*Config, opts ...Option) error {
+	if len(cfgs) == 0 {
+		return fmt.Errorf("%w: no chain configured", ErrInvalidConfig)
+	}
+	// The logger of the options, if any
+	supervisor := &Connection{logger: NewStdLogger()}
+	for _, opt := range opts {
+		opt(supervisor)
+	}
+
+	var wg sync.WaitGroup
+	for _, cfg := range cfgs {
+		wg.Add(1)
+		go func(cfg *Config) {
+			defer wg.Done()
+			runChain(ctx, &labelLogger{logger: supervisor.logger, key: "chain", value: cfg.Chain}, cfg, opts)
+		}(cfg)
+	}
+	wg.Wait()
+	return nil
+}
+
+func runChain(ctx context.Context, logger Logger, cfg *Config, opts []Option) {
+	retry := chainMinRetry
+	for {
+		started := time.Now()
+		err := recovered(logger, func() error {
+			return Run(ctx, cfg, opts...)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, ErrInvalidConfig) {
+			logger.Error(fmt.Sprintf("Stopped chain %s: %v", cfg.Chain, err), F("err", err))
+			return
+		}
+		if time.Since(started) >= chainMaxRetry {
+			retry = chainMinRetry
+		}
+		logger.Error(fmt.Sprintf("Chain %s stopped, running it again in %v: %v", cfg.Chain, retry, err), F("retry", retry), F("err", err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		if retry *= 2; retry > chainMaxRetry {
+			retry = chainMaxRetry
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// CometMonitor monitors a Compound v3 (Comet) market and absorbs
+// liquidatable accounts.
+type CometMonitor struct {
+	client      Backend
+	presenter   *Presenter
+	blockTime   time.Duration
+	TxOpts      *bind.TransactOpts
+	gasCap      *gasCap
+	accessLists *accessLists
+	Batcher     CallBatcher
+	logger      Logger
+	queue       *ExecutionQueue
+	journal     *Journal
+	publisher   *publisher
+	readiness   *readiness
+	pending     *pendingTxs
+	annotations *Annotations
+	simulation  *simulation
+	// Block of the check in progress, if any
+	block *big.Int
+
+	address  common.Address
+	Comet    *abis.Comet
+	cometABI *abi.ABI
+	adapter  ProtocolAdapter
+
+	baseToken common.Address
+	assets    []abis.CometCoreAssetInfo
+
+	// Configured accounts; when empty accounts are discovered
+	// from Withdraw events
+	accounts      []common.Address
+	scanner       *accountScanner
+	buyCollateral bool
+}
+
+// NewCometMonitor instantiates a monitor for the Comet market at the
+// provided address, reusing the connection.
+func (c *Connection) NewCometMonitor(ctx context.Context, address common.Address) (*CometMonitor, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	m := &CometMonitor{
+		client:        c.client,
+		presenter:     c.presenter,
+		blockTime:     c.blockTime,
+		TxOpts:        c.TxOpts,
+		gasCap:        c.gasCap,
+		accessLists:   c.accessLists,
+		Batcher:       c.budget.batcher(budgetKey{chain: c.config.Chain, pool: address}, c.readEndpoint, c.client, c.Batcher, c.batchSize),
+		logger:        c.logger,
+		queue:         c.queue,
+		journal:       c.journal,
+		publisher:     c.publisher,
+		readiness:     c.readiness,
+		pending:       c.pending,
+		annotations:   c.annotations,
+		simulation:    c.simulation,
+		address:       address,
+		accounts:      c.shard.filter(c.cometAccounts),
+		buyCollateral: c.cometBuyCollateral,
+	}
+
+	comet, err := abis.NewComet(address, c.client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate comet: %w", err)
+	}
+	m.Comet = comet
+
+	cometABI, err := abis.CometMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get comet ABI: %w", err)
+	}
+	m.cometABI = cometABI
+
+	adapter, err := newCometProtocolAdapter(address, c.TxOpts.From)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate protocol adapter: %w", err)
+	}
+	m.adapter = adapter
+
+	baseToken, err := comet.BaseToken(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get base token: %w", err)
+	}
+	m.baseToken = baseToken
+
+	numAssets, err := comet.NumAssets(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get number of assets: %w", err)
+	}
+	for i := uint8(0); i < numAssets; i++ {
+		info, err := comet.GetAssetInfo(opts, i)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get asset info %d: %w", i, err)
+		}
+		m.assets = append(m.assets, info)
+	}
+
+	if len(c.cometAccounts) == 0 {
+		withdraw := cometABI.Events["Withdraw"]
+		m.scanner = &accountScanner{
+			client:    c.client,
+			logs:      newLogBackfill(m.logger, c.client, c.logLimiter, c.borrowerScanBlockRange, logRetries),
+			addresses: []common.Address{address},
+			topic:     withdraw.ID,
+			account: func(l types.Log) (common.Address, error) {
+				if len(l.Topics) < 2 {
+					return common.Address{}, fmt.Errorf("malformed Withdraw event in tx %s", l.TxHash)
+				}
+				return common.BytesToAddress(l.Topics[1].Bytes()), nil
+			},
+			shard:     c.shard,
+			nextBlock: c.borrowerScanStartBlock,
+			known:     make(map[common.Address]bool),
+			accounts:  make([]common.Address, 0),
+		}
+	}
+
+	m.logger.Info(fmt.Sprintf("Comet market: %s (base %s, %d collateral assets)", m.presenter.Link(address), m.presenter.Address(baseToken), len(m.assets)), F("pool", address))
+
+	return m, nil
+}
+
+// SubscribeToBlocks runs a liquidatable check on every new block
+// until ctx is cancelled.
+func (m *CometMonitor) SubscribeToBlocks(ctx context.Context) error {
+	return subscribeToBlocks(ctx, m.logger, m.client, m.blockTime, func(ctx context.Context, header *types.Header) {
+		m.block = header.Number
+		err := m.LiquidatableCheck(ctx)
+		switch {
+		case err == nil:
+			m.readiness.primed(m.address, header.Number)
+			m.simulation.expire(m.address, header.Number.Uint64())
+		case errors.Is(err, ErrCacheNotPrimed):
+			m.logger.Info("No accounts yet; aborting liquidatable check", F("pool", m.address), F("block", header.Number))
+			m.readiness.skip(m.address)
+		default:
+			m.logger.Error(fmt.Sprintf("Failed liquidatable check: %v", err), F("pool", m.address), F("err", err))
+			m.readiness.skip(m.address)
+		}
+	})
+}
+
+// LiquidatableCheck checks every account at the latest block. It
+// fails with ErrCacheNotPrimed until there are accounts to check.
+func (m *CometMonitor) LiquidatableCheck(ctx context.Context) error {
+	m.logger.Info("Starting liquidatable checks...", F("pool", m.address))
+
+	accounts := m.accounts
+	if m.scanner != nil {
+		var err error
+		accounts, err = m.scanner.Accounts(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot scan accounts: %w", err)
+		}
+	}
+	m.logger.Info(fmt.Sprintf("Number of accounts: %d", len(accounts)), F("pool", m.address), F("accounts", len(accounts)))
+	if len(accounts) == 0 {
+		return ErrCacheNotPrimed
+	}
+
+	calls := []abis.MulticallCall{}
+	method := m.cometABI.Methods["isLiquidatable"]
+	for _, account := range accounts {
+		inputs, err := method.Inputs.Pack(account)
+		if err != nil {
+			return fmt.Errorf("cannot pack account: %w", err)
+		}
+		calls = append(calls, abis.MulticallCall{
+			Target:   m.address,
+			CallData: append(method.ID[:], inputs[:]...),
+		})
+	}
+
+	resp, err := m.Batcher.Aggregate(&bind.CallOpts{Context: ctx}, calls)
+	if err != nil {
+		return fmt.Errorf("failed batch request: %v", err)
+	}
+
+	health := make(map[common.Address]accountHealth, len(accounts))
+	var underwater []Borrower
+	for i, result := range resp {
+		if !result.Success {
+			m.logger.Warn(fmt.Sprintf("Failed to check whether account %s is liquidatable", accounts[i]), F("pool", m.address), F("account", accounts[i]))
+			health[accounts[i]] = healthUnknown
+			continue
+		}
+		var liquidatable bool
+		if err := m.cometABI.UnpackIntoInterface(&liquidatable, method.Name, result.ReturnData); err != nil {
+			return fmt.Errorf("cannot unpack output: %v", err)
+		}
+		if !liquidatable {
+			health[accounts[i]] = healthy
+			continue
+		}
+		underwater = append(underwater, Borrower{Address: accounts[i]})
+
+		candidate := Candidate{
+			Pool:        m.address,
+			Protocol:    m.adapter.Name(),
+			Account:     accounts[i],
+			Annotation:  m.annotations.annotation(accounts[i]),
+			Explanation: &Explanation{},
+		}
+		if err := m.queue.cooldowns.check(m.address, accounts[i]); candidate.Explanation.failed("cooldown", err != nil) {
+			candidate.Err = err
+		} else if err := m.annotations.check(m.address, accounts[i]); candidate.Explanation.failed("annotation", err != nil) {
+			candidate.Err = err
+		} else if hash, ok := m.pending.intent(m.address, accounts[i], m.address); candidate.Explanation.failed("own-tx", ok) {
+			candidate.Err = fmt.Errorf("%w %s", ErrAwaitingOwnTx, hash.Hex())
+		}
+		var rank *big.Int
+		if candidate.Err == nil && m.simulation == nil {
+			rank = m.annotations.rank(accounts[i], new(big.Int))
+		}
+		candidate.Explanation.decide(candidate, "", rank)
+		reportCandidate(m.logger, candidate)
+		journalCandidate(m.journal, nil, candidate)
+		m.publisher.publishCandidate(nil, candidate)
+		switch {
+		case candidate.Err != nil:
+		case m.simulation != nil:
+			simulated := candidate
+			simulated.Block = m.block
+			m.simulation.wouldSubmit(simulated, false)
+		default:
+			m.queue.Push(Job{Candidate: candidate, Executor: ExecutorFunc(m.absorb), Rank: rank})
+		}
+	}
+
+	m.queue.alerts.observe(m.address, underwater, func(account common.Address) (accountHealth, *big.Int) {
+		if status, ok := health[account]; ok {
+			return status, nil
+		}
+		return notBorrowing, nil
+	})
+
+	m.logger.Info("Liquidatable check complete.", F("pool", m.address))
+	return nil
+}
+
+func (m *CometMonitor) absorb(ctx context.Context, c Candidate) (*Outcome, error) {
+	account := c.Account
+	call, err := m.adapter.RepayCall(RepayParams{Borrower: account})
+	if err != nil {
+		return nil, err
+	}
+	tx, err := sendCall(ctx, m.client, m.TxOpts, m.gasCap, m.pending, m.accessLists, call)
+	if err != nil {
+		return nil, fmt.Errorf("cannot send absorb transaction: %w", err)
+	}
+	m.logger.Info(fmt.Sprintf("Absorb transaction for account %s: %s", account, m.presenter.TxURL(tx.Hash())), F("pool", m.address), F("account", account), F("tx", tx.Hash()))
+	hash := tx.Hash()
+	submission := JournalEntry{Kind: JournalSubmission, Pool: m.address, Account: account, Tx: &hash}
+	m.journal.Record(submission)
+	m.publisher.publish(c, submission)
+	m.pending.sent(tx, m.address, account, m.address)
+
+	if !m.buyCollateral {
+		go m.pending.watch(ctx, m.client, hash, m.blockTime)
+		return nil, nil
+	}
+
+	receipt, err := bind.WaitMined(ctx, m.client, tx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot wait for absorb transaction: %w", err)
+	}
+	m.pending.done(hash)
+	// Absorbing only earns protocol rewards, so its gas is a loss
+	paid, err := gasPaid(ctx, m.client, tx, receipt)
+	if err != nil {
+		return nil, err
+	}
+	outcome := &Outcome{Tx: tx.Hash(), PnL: paid.Neg(paid)}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return outcome, fmt.Errorf("absorb transaction %s: %w", tx.Hash(), ErrTxReverted)
+	}
+	return outcome, m.buyAbsorbedCollateral(ctx)
+}
+
+// buyAbsorbedCollateral spends our base token balance on collateral
+// held in the protocol reserves, which is sold at a discount.
+func (m *CometMonitor) buyAbsorbedCollateral(ctx context.Context) error {
+	opts := &bind.CallOpts{Context: ctx}
+	reserves, err := m.Comet.GetReserves(opts)
+	if err != nil {
+		return fmt.Errorf("cannot get reserves: %w", err)
+	}
+	targetReserves, err := m.Comet.TargetReserves(opts)
+	if err != nil {
+		return fmt.Errorf("cannot get target reserves: %w", err)
+	}
+	if GTE(reserves, targetReserves) {
+		m.logger.Info(fmt.Sprintf("Comet %s reserves are above target; collateral is not for sale", m.address), F("pool", m.address))
+		return nil
+	}
+
+	baseToken, err := abis.NewCToken(m.baseToken, m.client)
+	if err != nil {
+		return fmt.Errorf("cannot get interface for base token %s: %w", m.baseToken, err)
+	}
+	balance, err := baseToken.BalanceOf(opts, m.TxOpts.From)
+	if err != nil {
+		return fmt.Errorf("cannot get base token balance: %w", err)
+	}
+
+	for _, asset := range m.assets {
+		if !IsPositive(balance) {
+			m.logger.Info(fmt.Sprintf("No base token balance left to buy collateral from comet %s", m.address), F("pool", m.address))
+			return nil
+		}
+
+		collateralReserves, err := m.Comet.GetCollateralReserves(opts, asset.Asset)
+		if err != nil {
+			return fmt.Errorf("cannot get collateral reserves of %s: %w", asset.Asset, err)
+		}
+		if !IsPositive(collateralReserves) {
+			continue
+		}
+
+		baseAmount := new(big.Int).Set(balance)
+		quote, err := m.Comet.QuoteCollateral(opts, asset.Asset, baseAmount)
+		if err != nil {
+			return fmt.Errorf("cannot quote collateral %s: %w", asset.Asset, err)
+		}
+		if GT(quote, collateralReserves) {
+			// Only buy what is available
+			baseAmount.Mul(baseAmount, collateralReserves)
+			baseAmount.Div(baseAmount, quote)
+			quote, err = m.Comet.QuoteCollateral(opts, asset.Asset, baseAmount)
+			if err != nil {
+				return fmt.Errorf("cannot quote collateral %s: %w", asset.Asset, err)
+			}
+		}
+
+		if err := m.approveBaseToken(ctx, baseToken, baseAmount); err != nil {
+			return err
+		}
+		txOpts, err := m.gasCap.transactOpts(ctx, m.client, m.TxOpts)
+		if err != nil {
+			return err
+		}
+		if err := m.pending.assign(ctx, m.client, txOpts); err != nil {
+			return err
+		}
+		tx, err := m.Comet.BuyCollateral(txOpts, asset.Asset, quote, baseAmount, m.TxOpts.From)
+		m.pending.release(txOpts, err)
+		if err != nil {
+			return fmt.Errorf("cannot buy collateral %s: %w", asset.Asset, err)
+		}
+		m.logger.Info(fmt.Sprintf("Buy collateral transaction for %s: %s", asset.Asset, m.presenter.TxURL(tx.Hash())), F("pool", m.address), F("asset", asset.Asset), F("tx", tx.Hash()))
+		balance.Sub(balance, baseAmount)
+	}
+	return nil
+}
+
+func (m *CometMonitor) approveBaseToken(ctx context.Context, baseToken *abis.CToken, amount *big.Int) error {
+	allowance, err := baseToken.Allowance(&bind.CallOpts{Context: ctx}, m.TxOpts.From, m.address)
+	if err != nil {
+		return fmt.Errorf("cannot get base token allowance: %w", err)
+	}
+	if GTE(allowance, amount) {
+		return nil
+	}
+
+	txOpts, err := m.gasCap.transactOpts(ctx, m.client, m.TxOpts)
+	if err != nil {
+		return err
+	}
+	if err := m.pending.assign(ctx, m.client, txOpts); err != nil {
+		return err
+	}
+	tx, err := baseToken.Approve(txOpts, m.address, amount)
+	m.pending.release(txOpts, err)
+	if err != nil {
+		return fmt.Errorf("cannot approve base token: %w", err)
+	}
+	if _, err := bind.WaitMined(ctx, m.client, tx); err != nil {
+		return fmt.Errorf("cannot wait for approval: %w", err)
+	}
+	return nil
+}
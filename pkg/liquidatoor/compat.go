@@ -0,0 +1,260 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Markets read from the allMarkets array of a comptroller without
+// getAllMarkets at most
+const maxIndexedMarkets = 1024
+
+// Capabilities describes the optional comptroller extensions
+// available in a pool. Fuse pools support all of them whereas
+// the canonical Compound v2 comptroller lacks some, and forks
+// drop others. They are named by their methods.
+type Capabilities struct {
+	// getAllBorrowers is a Fuse extension; without it borrowers
+	// are discovered by scanning Borrow events.
+	GetAllBorrowers bool `json:"getAllBorrowers"`
+	// Without getAllMarkets markets are read from the allMarkets
+	// array by index.
+	GetAllMarkets bool `json:"getAllMarkets"`
+	// Pause getters; pauses that cannot be read are only learned
+	// from ActionPaused events.
+	SeizeGuardianPaused    bool `json:"seizeGuardianPaused"`
+	TransferGuardianPaused bool `json:"transferGuardianPaused"`
+	MintGuardianPaused     bool `json:"mintGuardianPaused"`
+	BorrowGuardianPaused   bool `json:"borrowGuardianPaused"`
+	// Liquidation parameter getters, which the Compound and Venus
+	// adapters need.
+	CloseFactorMantissa          bool `json:"closeFactorMantissa"`
+	LiquidationIncentiveMantissa bool `json:"liquidationIncentiveMantissa"`
+	// Capabilities set by CAPABILITY_OVERRIDES rather than probed
+	Overridden []string `json:"overridden,omitempty"`
+}
+
+// capability is a capability probed by calling method with args.
+type capability struct {
+	method string
+	args   []interface{}
+	value  *bool
+}
+
+func (c *Capabilities) capabilities() []capability {
+	// Getters of mappings by market answer false for any address
+	market := []interface{}{common.Address{}}
+	return []capability{
+		{method: "getAllBorrowers", value: &c.GetAllBorrowers},
+		{method: "getAllMarkets", value: &c.GetAllMarkets},
+		{method: "seizeGuardianPaused", value: &c.SeizeGuardianPaused},
+		{method: "transferGuardianPaused", value: &c.TransferGuardianPaused},
+		{method: "mintGuardianPaused", args: market, value: &c.MintGuardianPaused},
+		{method: "borrowGuardianPaused", args: market, value: &c.BorrowGuardianPaused},
+		{method: "closeFactorMantissa", value: &c.CloseFactorMantissa},
+		{method: "liquidationIncentiveMantissa", value: &c.LiquidationIncentiveMantissa},
+	}
+}
+
+// isCapability reports whether name is the name of a capability.
+func isCapability(name string) bool {
+	for _, c := range new(Capabilities).capabilities() {
+		if c.method == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Capabilities) String() string {
+	parts := make([]string, 0, len(c.capabilities())+1)
+	for _, capability := range c.capabilities() {
+		parts = append(parts, fmt.Sprintf("%s=%t", capability.method, *capability.value))
+	}
+	if len(c.Overridden) > 0 {
+		parts = append(parts, fmt.Sprintf("overridden=%s", strings.Join(c.Overridden, ",")))
+	}
+	return strings.Join(parts, " ")
+}
+
+// probeCapabilities probes the capabilities of a comptroller, unless
+// overridden, in which case the override wins.
+func probeCapabilities(ctx context.Context, logger Logger, client Backend, comptrollerAddress common.Address, comptrollerABI *abi.ABI, overrides map[string]bool) Capabilities {
+	var c Capabilities
+	for _, capability := range c.capabilities() {
+		if value, ok := overrides[capability.method]; ok {
+			*capability.value = value
+			c.Overridden = append(c.Overridden, capability.method)
+			continue
+		}
+		*capability.value = probeMethod(ctx, logger, client, comptrollerAddress, comptrollerABI.Methods[capability.method], capability.args...)
+	}
+	sort.Strings(c.Overridden)
+	return c
+}
+
+// capabilityOverrides returns the overrides of cfg for pool, those of
+// every pool overridden by its own.
+func capabilityOverrides(cfg *Config, pool common.Address) map[string]bool {
+	overrides := make(map[string]bool)
+	for name, value := range cfg.CapabilityOverrides[common.Address{}] {
+		overrides[name] = value
+	}
+	for name, value := range cfg.CapabilityOverrides[pool] {
+		overrides[name] = value
+	}
+	return overrides
+}
+
+// probeMethod reports whether calling the provided method with args
+// on the target succeeds. Reverts are interpreted as the method
+// being absent.
+func probeMethod(ctx context.Context, logger Logger, client Backend, target common.Address, method abi.Method, args ...interface{}) bool {
+	inputs, err := method.Inputs.Pack(args...)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Cannot pack probe for %s: %v", method.Name, err), F("method", method.Name), F("err", err))
+		return false
+	}
+	data, err := client.CallContract(ctx, ethereum.CallMsg{
+		To:   &target,
+		Data: append(method.ID[:len(method.ID):len(method.ID)], inputs...),
+	}, nil)
+	if err != nil && isRevert(err) {
+		logger.Debug(fmt.Sprintf("Probe for %s on %s reverted: %v", method.Name, target, err), F("method", method.Name), F("target", target), F("err", err))
+		return false
+	}
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Probe for %s on %s failed: %v", method.Name, target, err), F("method", method.Name), F("target", target), F("err", err))
+		return false
+	}
+	if _, err := method.Outputs.Unpack(data); err != nil {
+		logger.Warn(fmt.Sprintf("Probe for %s on %s returned unexpected output: %v", method.Name, target, err), F("method", method.Name), F("target", target), F("err", err))
+		return false
+	}
+	return true
+}
+
+// readMarkets returns the markets of a comptroller, from getAllMarkets
+// if it has it, or else from its allMarkets array, read by index until
+// past its end.
+func readMarkets(opts *bind.CallOpts, client Backend, comptrollerAddress common.Address, comptrollerABI *abi.ABI, comptroller Comptroller, capabilities Capabilities) ([]common.Address, error) {
+	if capabilities.GetAllMarkets {
+		return comptroller.GetAllMarkets(opts)
+	}
+	method := comptrollerABI.Methods["allMarkets"]
+	markets := make([]common.Address, 0)
+	for i := int64(0); i < maxIndexedMarkets; i++ {
+		inputs, err := method.Inputs.Pack(big.NewInt(i))
+		if err != nil {
+			return nil, fmt.Errorf("cannot pack allMarkets: %w", err)
+		}
+		data, err := client.CallContract(opts.Context, ethereum.CallMsg{To: &comptrollerAddress, Data: append(method.ID[:len(method.ID):len(method.ID)], inputs...)}, nil)
+		if err != nil && isRevert(err) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot get market %d: %w", i, err)
+		}
+		out, err := method.Outputs.Unpack(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unpack market %d: %w", i, err)
+		}
+		markets = append(markets, *abi.ConvertType(out[0], new(common.Address)).(*common.Address))
+	}
+	if len(markets) == 0 {
+		return nil, fmt.Errorf("cannot read markets of comptroller %s without getAllMarkets", comptrollerAddress.Hex())
+	}
+	return markets, nil
+}
+
+// accountScanner discovers accounts by scanning events emitted by
+// the provided contracts. Each scan picks up where the previous one
+// left off.
+type accountScanner struct {
+	client    Backend
+	logs      *logBackfill
+	addresses []common.Address
+
+	topic common.Hash
+	// account extracts the account from a matching log
+	account func(types.Log) (common.Address, error)
+	// Accounts returned
+	shard Shard
+
+	nextBlock uint64
+	known     map[common.Address]bool
+	accounts  []common.Address
+}
+
+// newBorrowerScanner returns a scanner discovering borrowers from
+// the Borrow events of the provided markets.
+func newBorrowerScanner(logger Logger, client Backend, limiter *logLimiter, markets []common.Address, startBlock, blockRange uint64) (*accountScanner, error) {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	event := cTokenABI.Events["Borrow"]
+
+	return &accountScanner{
+		client:    client,
+		logs:      newLogBackfill(logger, client, limiter, blockRange, logRetries),
+		addresses: markets,
+		topic:     event.ID,
+		account: func(l types.Log) (common.Address, error) {
+			out, err := event.Inputs.Unpack(l.Data)
+			if err != nil {
+				return common.Address{}, fmt.Errorf("cannot unpack Borrow event: %w", err)
+			}
+			return *abi.ConvertType(out[0], new(common.Address)).(*common.Address), nil
+		},
+		nextBlock: startBlock,
+		known:     make(map[common.Address]bool),
+		accounts:  make([]common.Address, 0),
+	}, nil
+}
+
+// Accounts returns every account of the shard seen up to the latest
+// block.
+func (s *accountScanner) Accounts(ctx context.Context) ([]common.Address, error) {
+	header, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get latest block: %w", err)
+	}
+	head := header.Number.Uint64()
+
+	query := ethereum.FilterQuery{Addresses: s.addresses, Topics: [][]common.Hash{{s.topic}}}
+	err = s.logs.filter(ctx, "account events", query, s.nextBlock, head, func(logs []types.Log, last uint64) error {
+		for _, l := range logs {
+			account, err := s.account(l)
+			if err != nil {
+				return err
+			}
+			if !s.known[account] {
+				s.known[account] = true
+				if s.shard.Owns(account) {
+					s.accounts = append(s.accounts, account)
+				}
+			}
+		}
+		s.nextBlock = last + 1
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]common.Address, len(s.accounts))
+	copy(accounts, s.accounts)
+	return accounts, nil
+}
@@ -0,0 +1,230 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// Interval between competitor reports by default, as a daily
+	// summary
+	defaultCompetitorStatsInterval = 24 * time.Hour
+	// Liquidators logged by every report, by most liquidations
+	competitorStatsLogged = 10
+)
+
+// CompetitorStats describes how a liquidator competes in the pools of
+// the history store.
+type CompetitorStats struct {
+	Liquidator common.Address
+	// Including pruned liquidations
+	Liquidations int
+	// Of every liquidation of the history store
+	Share Ratio
+	// Lower median of the tips of its transactions, in wei per gas; nil
+	// if none is known
+	MedianGasTip *big.Int
+	// Liquidations of borrowers the journal recorded as underwater
+	// candidates, and the lower median of blocks since the first of
+	// them; nil if none was
+	Detected             int
+	MedianBlocksToStrike *uint64
+}
+
+// detectionKey is a borrower of a pool.
+type detectionKey struct {
+	pool, borrower common.Address
+}
+
+// journalDetections returns the blocks the journal at path recorded
+// each borrower as an underwater candidate in, in order.
+func journalDetections(path string) (map[detectionKey][]uint64, error) {
+	detections := make(map[detectionKey][]uint64)
+	err := scanJournals(path, func(entry JournalEntry) error {
+		if entry.Kind != JournalCandidate || entry.Block == nil {
+			return nil
+		}
+		k := detectionKey{pool: entry.Pool, borrower: entry.Account}
+		detections[k] = append(detections[k], entry.Block.Uint64())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, blocks := range detections {
+		sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+	}
+	return detections, nil
+}
+
+// Competitors returns the statistics of every liquidator of the stored
+// liquidations, and the rollups of the pruned ones, with the most
+// liquidations first. With a journal path, liquidations are timed from
+// the first block the journal recorded their borrower as underwater
+// since its previous liquidation.
+func (h *History) Competitors(ctx context.Context, journalPath string) ([]CompetitorStats, error) {
+	var detections map[detectionKey][]uint64
+	if journalPath != "" {
+		var err error
+		if detections, err = journalDetections(journalPath); err != nil {
+			return nil, err
+		}
+	}
+
+	type competitor struct {
+		stats CompetitorStats
+		tips  []*big.Int
+		delay []uint64
+		txs   map[common.Hash]bool
+	}
+	competitors := make(map[common.Address]*competitor)
+	competitorOf := func(liquidator common.Address) *competitor {
+		c, ok := competitors[liquidator]
+		if !ok {
+			c = &competitor{stats: CompetitorStats{Liquidator: liquidator}, txs: make(map[common.Hash]bool)}
+			competitors[liquidator] = c
+		}
+		return c
+	}
+	total := 0
+	err := h.Rollups(ctx, func(r HistoryRollup) error {
+		competitorOf(r.Liquidator).stats.Liquidations += r.Liquidations
+		total += r.Liquidations
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Liquidations are by pool and block, so the previous liquidation
+	// of a borrower is always seen first
+	liquidated := make(map[detectionKey]uint64)
+	err = h.Liquidations(ctx, func(l HistoricalLiquidation) error {
+		c := competitorOf(l.Liquidator)
+		c.stats.Liquidations++
+		total++
+		if l.GasTip != nil && !c.txs[l.Tx] {
+			c.txs[l.Tx] = true
+			c.tips = append(c.tips, l.GasTip)
+		}
+		k := detectionKey{pool: l.Pool, borrower: l.Borrower}
+		previous, ok := liquidated[k]
+		liquidated[k] = l.Block
+		for _, block := range detections[k] {
+			if ok && block <= previous {
+				continue
+			}
+			if block <= l.Block {
+				c.stats.Detected++
+				c.delay = append(c.delay, l.Block-block)
+			}
+			break
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]CompetitorStats, 0, len(competitors))
+	for _, c := range competitors {
+		c.stats.Share = NewRatio(big.NewInt(int64(c.stats.Liquidations)), big.NewInt(int64(total)))
+		if len(c.tips) > 0 {
+			sort.Slice(c.tips, func(i, j int) bool { return GT(c.tips[j], c.tips[i]) })
+			c.stats.MedianGasTip = c.tips[(len(c.tips)-1)/2]
+		}
+		if len(c.delay) > 0 {
+			sort.Slice(c.delay, func(i, j int) bool { return c.delay[i] < c.delay[j] })
+			c.stats.MedianBlocksToStrike = &c.delay[(len(c.delay)-1)/2]
+		}
+		stats = append(stats, c.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Liquidations != stats[j].Liquidations {
+			return stats[i].Liquidations > stats[j].Liquidations
+		}
+		return stats[i].Liquidator.String() < stats[j].Liquidator.String()
+	})
+	return stats, nil
+}
+
+// competitorReport periodically logs the competitors of the history
+// store, as kept up to date by the history subcommand.
+type competitorReport struct {
+	logger      Logger
+	historyPath string
+	journalPath string
+}
+
+func (c *Connection) newCompetitorReport() *competitorReport {
+	return &competitorReport{
+		logger:      c.logger,
+		historyPath: c.config.HistoryPath,
+		journalPath: c.config.JournalPath,
+	}
+}
+
+// run reports every interval until ctx is cancelled.
+func (r *competitorReport) run(ctx context.Context, interval time.Duration) {
+	if r.historyPath == "" {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultCompetitorStatsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.report(ctx); err != nil {
+			r.logger.Error(fmt.Sprintf("Failed to report competitors: %v", err), F("err", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *competitorReport) report(ctx context.Context) error {
+	if _, err := os.Stat(r.historyPath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	h, err := OpenHistory(r.logger, r.historyPath)
+	if err != nil {
+		// Eg., locked by an indexing run for longer than the busy
+		// timeout; reported on the next pass
+		r.logger.Warn(fmt.Sprintf("Skipping competitor report: %v", err), F("err", err))
+		return nil
+	}
+	defer h.Close()
+	stats, err := h.Competitors(ctx, r.journalPath)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info(fmt.Sprintf("Competitors: %d liquidators in the history", len(stats)), F("liquidators", len(stats)))
+	for i, s := range stats {
+		if i == competitorStatsLogged {
+			break
+		}
+		tip, strike := "unknown", "unknown"
+		if s.MedianGasTip != nil {
+			tip = s.MedianGasTip.String() + " wei"
+		}
+		if s.MedianBlocksToStrike != nil {
+			strike = fmt.Sprintf("%d blocks", *s.MedianBlocksToStrike)
+		}
+		r.logger.Info(fmt.Sprintf("Liquidator %s: %d liquidations, %s of the history, median tip %s, median strike after detection %s of %d detected",
+			s.Liquidator, s.Liquidations, s.Share.Percent(), tip, strike, s.Detected),
+			F("liquidator", s.Liquidator), F("liquidations", s.Liquidations), F("share", s.Share.String()),
+			F("tip", tip), F("strike", strike), F("detected", s.Detected))
+	}
+	return nil
+}
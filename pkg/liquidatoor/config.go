@@ -0,0 +1,1313 @@
+package liquidatoor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const defaultBorrowerScanBlockRange = 10000
+
+// Policies on liquidations in pools with transfers paused
+const (
+	// Execute them after every other liquidation, and drop them first,
+	// if exiting the collateral is needed to realize their profit
+	TransferPausedDeprioritize = "deprioritize"
+	// Execute them as usual
+	TransferPausedProceed = "proceed"
+)
+
+// Config holds the settings of a liquidatoor process. Unset optional
+// settings are filled in from the preset of the connected chain.
+type Config struct {
+	// Name of the chain section the settings were read from, labelling
+	// every log line, if any; see ConfigsFromFile
+	Chain string
+	// Not needed when connecting over an existing backend
+	NodeAPIURL string
+	// Hex-encoded private key of the liquidatoor wallet
+	PrivateKey string
+	// Blockchain explorer URL
+	ExplorerURL string
+	// Chain ID the node is expected to be connected to, if any
+	ExpectedChainID *big.Int
+
+	BlockTime        time.Duration
+	NativeSymbol     string
+	MulticallAddress *common.Address
+	BatchSize        int
+	// Dedicated HTTP connections read batches are spread over; zero
+	// reads over the main connection
+	ReadPoolSize int
+	// Defaults to NodeAPIURL
+	ReadNodeAPIURL string
+	// Endpoints every signed transaction is also submitted to, eg.,
+	// public nodes or private relays; the first to accept it wins
+	SubmissionURLs []string
+	// Limits of log queries by node host or domain, with "*" applying
+	// to any host; unset limits are filled in from the preset of the
+	// provider
+	LogLimits map[string]LogLimits
+
+	BorrowerCacheInterval time.Duration
+	// Database the borrowers of every pool persist to, for very large
+	// pools; empty holds every borrower in memory
+	BorrowerCachePath string
+	// Borrow event scanning for pools without getAllBorrowers
+	BorrowerScanStartBlock uint64
+	BorrowerScanBlockRange uint64
+	// History store of past liquidations, indexed by the history
+	// subcommand from HistoryStartBlock in chunks of HistoryBlockRange
+	// blocks, which defaults to 10000
+	HistoryPath       string
+	HistoryStartBlock uint64
+	HistoryBlockRange uint64
+	// Interval between logs of the competitors of the history store;
+	// defaults to 24h
+	CompetitorStatsInterval time.Duration
+	// Blocks between checks of every borrower; in between only accounts
+	// affected by pool events or price changes are checked. Zero checks
+	// every borrower on every block.
+	FullScanInterval uint64
+	// Blocks skipped at most in a row when no borrower needs to be
+	// checked, eg., on quiet chains; zero never skips. Only applies
+	// with a FullScanInterval.
+	ForceScanInterval uint64
+
+	// Blocks between reads of the admin and implementation of every
+	// comptroller, alerting on changes; zero reads them every block
+	GovernanceCheckInterval uint64
+	// Hold the liquidations of a pool from a change until it is
+	// acknowledged
+	PauseOnGovernanceChange bool
+
+	// Pools whose supplier whitelist does not affect liquidations, so
+	// they are executed even if we are not whitelisted
+	IgnoreWhitelistPools []common.Address
+
+	// Policy on liquidations while transfers are paused, by pool;
+	// defaults to TransferPausedDeprioritize. Their collateral is
+	// tagged as locked either way.
+	TransferPausedPolicies map[common.Address]string
+
+	// Capabilities of the comptrollers set rather than probed, by
+	// pool, those of the zero address applying to every pool
+	CapabilityOverrides map[common.Address]map[string]bool
+
+	// Protocol adapter used for every pool
+	ProtocolAdapter        string
+	VenusLiquidatorAddress common.Address
+
+	// Flash loan venue; "none" disables the preset one
+	FlashLiquiditySource string
+	AavePoolAddress      *common.Address
+
+	// Nil uses the default strategy
+	Strategy Strategy
+	// Strategies whose plans are only logged, for comparison
+	ShadowStrategies []Strategy
+	// Nil prices plans with the pool oracle
+	ProfitEstimator ProfitEstimator
+	// Executes liquidatable candidates of Compound pools; nil only
+	// reports them, unless ExecuteLiquidations
+	Executor Executor
+	// Sends liquidateBorrow from the wallet, repaying from its
+	// inventory, when Executor is nil
+	ExecuteLiquidations bool
+	// Defaults to 1 and 64
+	ExecutionWorkers   int
+	ExecutionQueueSize int
+	// Bounds the work of every pool, shared by every connection it is
+	// set on, eg., every chain of a process; defaults to a budget of
+	// BudgetLimits, if any, and nil is unlimited
+	Budget       *Budget
+	BudgetLimits BudgetLimits
+	// Coordinates executions with redundant instances so only one
+	// executes each; defaults to a Redis lock if CoordinationURL is
+	// set, and nil executes uncoordinated
+	Lock            Lock
+	CoordinationURL string
+	// How long executions are locked for; defaults to 2m and covers at
+	// least 10 blocks
+	LockTTL time.Duration
+	// Part of the borrowers of every pool checked, for instances to
+	// split large pools; the zero Shard checks every borrower
+	Shard Shard
+	// Defaults to RolePrimary; RoleStandby needs a heartbeat and
+	// RoleSimulation takes no PrivateKey
+	Role Role
+	// Wallet RoleSimulation simulates from, eg., the one to be funded,
+	// for its balances and gas estimates
+	SimulationAddress common.Address
+	// Beaten by the primary and watched by standby instances; defaults
+	// to NewHeartbeat of HeartbeatURL, if set
+	Heartbeat    Heartbeat
+	HeartbeatURL string
+	// Defaults to 10s and 1m
+	HeartbeatInterval  time.Duration
+	HeartbeatStaleness time.Duration
+	// File the role persists to, for operators to demote the instance;
+	// see Demote
+	RolePath string
+	// Realized losses over the last day, in wei of the native token,
+	// after which execution stops until the kill switch is reset; nil
+	// is unlimited. Read in native tokens, eg., 0.5 or 0.5 ETH
+	DailyLossLimit *big.Int
+	// Symbol DailyLossLimit was read with, checked against the native
+	// token of the connected chain
+	dailyLossLimitSymbol string
+	// Directory every state file and database not explicitly
+	// configured is kept under, if set
+	DataDir string
+	// File the ledger of executions and the kill switch persist to, to
+	// survive restarts; empty keeps them in memory
+	LedgerPath string
+	// JSON list of account annotations, reloaded on changes, if set;
+	// see Annotations
+	AnnotationsPath string
+	// Accounts monitored instead of the borrowers of the pools, and a
+	// file of more, reloaded on changes, if set; see accountList
+	Accounts     []common.Address
+	AccountsPath string
+	// Registry of ENS, or of a name service of the same interface,
+	// addresses without a label are named by, if set; see Presenter
+	ENSRegistryAddress *common.Address
+	// URL of the signed document of deny-lists and price deviation
+	// limits applied at runtime, if set; see RemoteConfig
+	RemoteConfigURL string
+	// Address the document needs to be signed by
+	RemoteConfigSigner common.Address
+	// Interval between fetches of the document; defaults to 30s
+	RemoteConfigInterval time.Duration
+	// File the last good document persists to, for it to apply while
+	// the URL is down after restarts
+	RemoteConfigPath string
+	// File the alerts of underwater accounts persist to, so restarts do
+	// not notify them again; see Alerts
+	AlertsPath string
+	// Interval between notifications of an account still underwater;
+	// defaults to 1h
+	AlertRenotifyInterval time.Duration
+	// Liquidity an account must regain to resolve its alert, in the
+	// units of getAccountLiquidity; defaults to 10 USD. Read in USD,
+	// eg., 10 or 10 USD
+	AlertResolveMargin *big.Int
+	// Aggregate shortfall and share of the borrows of the 5 largest
+	// borrowers a pool alerts over, if set; see PoolRisk. The shortfall
+	// is read in USD, eg., 100000, and the share as a ratio, eg., 0.5
+	RiskShortfallAlert     *big.Int
+	RiskConcentrationAlert *Ratio
+	// Share of the cash of the collateral market over which the
+	// shortfall or the planned seize of an account alerts, and plans
+	// seize within the cash; defaults to 1. Read as a ratio, eg., 0.8
+	IlliquidCollateralAlert *Ratio
+	// Value of the collateral an underwater account may hold and be
+	// bad debt, left out of execution and reported with the pool risk;
+	// defaults to 1 USD. Read in USD, eg., 1 or 1 USD
+	BadDebtDust *big.Int
+	// Append-only JSONL journal of every decision and action, if set;
+	// see JournalSchemaVersion
+	JournalPath string
+	// NATS server candidates and their outcomes are published to for
+	// an external execution pipeline, if set
+	NATSURL string
+	// Defaults to liquidatoor.candidates, and to liquidatoor.candidates.ack
+	// for acknowledgments
+	NATSSubject    string
+	NATSAckSubject string
+	// Unacknowledged events buffered while disconnected; defaults to
+	// 1024
+	NATSBuffer int
+	// Wait for an acknowledgment before publishing again; defaults to
+	// 30s
+	NATSRedelivery time.Duration
+	// Wait for candidates to be marked handled before executing them
+	// locally; zero executes them right away unless already marked
+	NATSHandoff time.Duration
+	// Address readiness probes, on /ready, account lookups, on
+	// /account/{address}, and cooldowns, on /cooldowns, are answered
+	// on, eg., :8080, if set; see Connection.Ready
+	ReadinessAddress string
+	// Bytes the journal is rotated at; defaults to 100MiB
+	JournalMaxSize int64
+	// Age after which rotated journals and indexed liquidations are
+	// rolled up into daily rollups and pruned; zero keeps them
+	RetentionMaxAge time.Duration
+	// Size of all rotated journals over which the oldest are pruned;
+	// zero is unlimited
+	RetentionMaxSize int64
+	// Interval between pruning passes; defaults to 1h
+	RetentionInterval time.Duration
+	// File our liquidation transactions in flight persist to, to be
+	// reconciled on restart; empty keeps them in memory
+	PendingTxPath string
+	// Age after which a transaction still pending on restart is
+	// abandoned; defaults to 30m
+	PendingTxTTL time.Duration
+	// Deviation of realized liquidations from their plans, scaled by
+	// 1e18, over which a warning is raised; defaults to 5%
+	OutcomeDriftTolerance *big.Int
+	// Plans estimated per pool and block, by decreasing gross profit;
+	// the rest are deferred to the next block. Zero is unlimited.
+	MaxCandidatesPerBlock int
+
+	// Hard maximum gas price of legacy transactions and max fee per gas
+	// of EIP-1559 ones, in wei, whatever the node suggests; nil is
+	// unlimited. Each falls back to the other.
+	MaxGasPrice  *big.Int
+	MaxFeePerGas *big.Int
+
+	// Follow pending transactions for liquidations of our candidates by
+	// others, on providers that can subscribe to them
+	MempoolMonitoring bool
+	// Attach EIP-2930 access lists, created with eth_createAccessList,
+	// to the transactions sent, and estimate their gas with them, when
+	// they save gas and the node can create them
+	AccessLists bool
+	// Drop candidates with a competing pending liquidation rather than
+	// leaving executors to outbid it
+	StandDownOnCompetition bool
+
+	// Maximum slippage from the oracle price of swaps of seized
+	// collateral by token class, scaled by 1e18; unset classes use
+	// defaults
+	SlippageLimits map[TokenClass]*big.Int
+	// Token classes by underlying, with the native token keyed by the
+	// zero address; others are classed by symbol
+	TokenClasses map[common.Address]TokenClass
+	// Quotes collateral swaps to enforce slippage limits when planning;
+	// nil only bounds them
+	SwapQuoter SwapQuoter
+
+	// Nil uses the oracle of each pool
+	PriceSource PriceSource
+	// Fork endpoint, eg., anvil or a hardhat node, plans are replayed
+	// on, forked from NodeAPIURL at the latest block, before they are
+	// executed, if set; see ForkValidation
+	ForkRPCURL string
+	// Of a replay; defaults to 30s
+	ForkTimeout time.Duration
+	// Share of the gross profit of a plan its replay may fall short of;
+	// defaults to 0.05
+	ForkTolerance *Ratio
+	// Blocks the fork may mine past the block it was reset to; defaults
+	// to 16
+	ForkMaxDrift uint64
+	// JSON object of the identifiers of underlyings at the FiatPricer,
+	// with the native token keyed by the zero address, if set, to report
+	// values in USD; values of unmapped tokens are reported as they are
+	FiatIDsPath string
+	// Nil uses the price API at FiatPriceURL, the simple price API of
+	// CoinGecko by default; see NewHTTPFiatPricer
+	FiatPricer   FiatPricer
+	FiatPriceURL string
+	// Age of the prices reported and requests per second to the price
+	// API; default to 5m and 0.1
+	FiatPriceTTL  time.Duration
+	FiatPriceRate float64
+	// Chainlink feeds by underlying, with the native token keyed by the
+	// zero address, to sanity-check prices against
+	ChainlinkFeeds map[common.Address]common.Address
+	// Scaled by 1e18; defaults to 5%
+	MaxPriceDeviation *big.Int
+	// Maximum deviation of the repay and collateral prices from their
+	// Chainlink or recent average price before liquidating, by
+	// underlying and scaled by 1e18; others use MaxPriceDeviation
+	PriceDeviationLimits map[common.Address]*big.Int
+	// Events posting prices, eg., of the oracle or its feeds, on which
+	// the accounts of the affected markets are checked again without
+	// waiting for the next block
+	PriceUpdateEvents []PriceUpdateEvent
+
+	// Statically monitored pools
+	Comptrollers []common.Address
+	Comets       []common.Address
+
+	// Comet markets
+	CometAccounts      []common.Address
+	CometBuyCollateral bool
+
+	// Nil disables pool discovery
+	Discovery *DiscoveryConfig
+
+	// Settings of the chain section, overriding the environment
+	env map[string]string
+}
+
+// PriceUpdateEvent is an event posting the prices of a market, or of
+// every market if the market is the zero address.
+type PriceUpdateEvent struct {
+	Emitter common.Address
+	Topic   common.Hash
+	Market  common.Address
+}
+
+type DiscoveryConfig struct {
+	PoolDirectory common.Address
+	Interval      time.Duration
+	// Minimum total borrows of a pool, denominated in the pool
+	// oracle's unit of account and scaled by 1e18. Read in the unit
+	// of account, eg., 100000
+	MinTotalBorrows *big.Int
+	// Allow-listed pool admins; empty allows any admin
+	Admins []common.Address
+}
+
+// ConfigFromEnv reads the configuration from the environment.
+func ConfigFromEnv() (*Config, error) {
+	cfg := &Config{}
+	if err := cfg.readEnv(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	cfg.applyDataDir()
+	return cfg, nil
+}
+
+// getenv returns the setting of key of the chain section, if set, or
+// of the environment.
+func (cfg *Config) getenv(key string) string {
+	if value, ok := cfg.env[key]; ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+func (cfg *Config) readEnv() error {
+	cfg.NodeAPIURL = cfg.getenv("NODE_API_URL")
+	explorerURL := cfg.getenv("BLOCKCHAIN_EXPLORER_URL")
+	if explorerURL == "" {
+		return errors.New("BLOCKCHAIN_EXPLORER_URL cannot be empty")
+	}
+	cfg.ExplorerURL = explorerURL
+
+	if cfg.getenv("BORROWER_CACHE_INTERVAL") == "" {
+		return errors.New("BORROWER_CACHE_INTERVAL cannot be empty")
+	}
+	borrowerCacheInterval, err := time.ParseDuration(cfg.getenv("BORROWER_CACHE_INTERVAL"))
+	if err != nil {
+		return err
+	}
+	cfg.BorrowerCacheInterval = borrowerCacheInterval
+	cfg.BorrowerCachePath = cfg.getenv("BORROWER_CACHE_PATH")
+
+	if startBlock := cfg.getenv("BORROWER_SCAN_START_BLOCK"); startBlock != "" {
+		value, err := strconv.ParseUint(startBlock, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid BORROWER_SCAN_START_BLOCK: %w", err)
+		}
+		cfg.BorrowerScanStartBlock = value
+	}
+
+	if blockRange := cfg.getenv("BORROWER_SCAN_BLOCK_RANGE"); blockRange != "" {
+		value, err := strconv.ParseUint(blockRange, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid BORROWER_SCAN_BLOCK_RANGE: %w", err)
+		}
+		if value == 0 {
+			return errors.New("BORROWER_SCAN_BLOCK_RANGE cannot be zero")
+		}
+		cfg.BorrowerScanBlockRange = value
+	}
+
+	cfg.HistoryPath = cfg.getenv("HISTORY_PATH")
+	if startBlock := cfg.getenv("HISTORY_START_BLOCK"); startBlock != "" {
+		value, err := strconv.ParseUint(startBlock, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid HISTORY_START_BLOCK: %w", err)
+		}
+		cfg.HistoryStartBlock = value
+	}
+	if blockRange := cfg.getenv("HISTORY_BLOCK_RANGE"); blockRange != "" {
+		value, err := strconv.ParseUint(blockRange, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid HISTORY_BLOCK_RANGE: %w", err)
+		}
+		if value == 0 {
+			return errors.New("HISTORY_BLOCK_RANGE cannot be zero")
+		}
+		cfg.HistoryBlockRange = value
+	}
+	if interval := cfg.getenv("COMPETITOR_STATS_INTERVAL"); interval != "" {
+		value, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("invalid COMPETITOR_STATS_INTERVAL: %w", err)
+		}
+		cfg.CompetitorStatsInterval = value
+	}
+
+	if fullScanInterval := cfg.getenv("FULL_SCAN_INTERVAL"); fullScanInterval != "" {
+		value, err := strconv.ParseUint(fullScanInterval, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid FULL_SCAN_INTERVAL: %w", err)
+		}
+		cfg.FullScanInterval = value
+	}
+
+	if forceScanInterval := cfg.getenv("FORCE_SCAN_INTERVAL"); forceScanInterval != "" {
+		value, err := strconv.ParseUint(forceScanInterval, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid FORCE_SCAN_INTERVAL: %w", err)
+		}
+		cfg.ForceScanInterval = value
+	}
+
+	if blockTime := cfg.getenv("BLOCK_TIME"); blockTime != "" {
+		value, err := time.ParseDuration(blockTime)
+		if err != nil {
+			return fmt.Errorf("invalid BLOCK_TIME: %w", err)
+		}
+		cfg.BlockTime = value
+	}
+
+	if expectedChainID := cfg.getenv("EXPECTED_CHAIN_ID"); expectedChainID != "" {
+		value, ok := new(big.Int).SetString(expectedChainID, 10)
+		if !ok {
+			return fmt.Errorf("invalid EXPECTED_CHAIN_ID: %s", expectedChainID)
+		}
+		cfg.ExpectedChainID = value
+	}
+
+	if multicallAddress := cfg.getenv("MULTICALL_ADDRESS"); multicallAddress != "" {
+		address := common.HexToAddress(multicallAddress)
+		cfg.MulticallAddress = &address
+	}
+
+	if batchSize := cfg.getenv("BATCH_SIZE"); batchSize != "" {
+		value, err := strconv.Atoi(batchSize)
+		if err != nil {
+			return fmt.Errorf("invalid BATCH_SIZE: %w", err)
+		}
+		if value <= 0 {
+			return errors.New("BATCH_SIZE must be positive")
+		}
+		cfg.BatchSize = value
+	}
+
+	if readPoolSize := cfg.getenv("READ_POOL_SIZE"); readPoolSize != "" {
+		value, err := strconv.Atoi(readPoolSize)
+		if err != nil {
+			return fmt.Errorf("invalid READ_POOL_SIZE: %w", err)
+		}
+		if value < 0 {
+			return errors.New("READ_POOL_SIZE cannot be negative")
+		}
+		cfg.ReadPoolSize = value
+	}
+	cfg.ReadNodeAPIURL = cfg.getenv("READ_NODE_API_URL")
+	if urls := cfg.getenv("SUBMISSION_URLS"); urls != "" {
+		for _, u := range strings.Split(urls, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				cfg.SubmissionURLs = append(cfg.SubmissionURLs, u)
+			}
+		}
+	}
+	if limits := cfg.getenv("LOG_LIMITS"); limits != "" {
+		value, err := parseLogLimits(limits)
+		if err != nil {
+			return fmt.Errorf("invalid LOG_LIMITS: %w", err)
+		}
+		cfg.LogLimits = value
+	}
+
+	if workers := cfg.getenv("EXECUTION_WORKERS"); workers != "" {
+		value, err := strconv.Atoi(workers)
+		if err != nil {
+			return fmt.Errorf("invalid EXECUTION_WORKERS: %w", err)
+		}
+		if value <= 0 {
+			return errors.New("EXECUTION_WORKERS must be positive")
+		}
+		cfg.ExecutionWorkers = value
+	}
+	for _, limit := range []struct {
+		name  string
+		value *int
+	}{
+		{"BUDGET_EXECUTION_WORKERS", &cfg.BudgetLimits.ExecutionWorkers},
+		{"BUDGET_MULTICALLS", &cfg.BudgetLimits.Multicalls},
+	} {
+		if value := cfg.getenv(limit.name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", limit.name, err)
+			}
+			if parsed < 0 {
+				return fmt.Errorf("%s cannot be negative", limit.name)
+			}
+			*limit.value = parsed
+		}
+	}
+	if rate := cfg.getenv("BUDGET_ENDPOINT_RATE"); rate != "" {
+		value, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return fmt.Errorf("invalid BUDGET_ENDPOINT_RATE: %w", err)
+		}
+		if value < 0 {
+			return errors.New("BUDGET_ENDPOINT_RATE cannot be negative")
+		}
+		cfg.BudgetLimits.EndpointRate = value
+	}
+
+	cfg.CoordinationURL = cfg.getenv("COORDINATION_URL")
+	if ttl := cfg.getenv("COORDINATION_LOCK_TTL"); ttl != "" {
+		value, err := time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("invalid COORDINATION_LOCK_TTL: %w", err)
+		}
+		cfg.LockTTL = value
+	}
+
+	cfg.Role = Role(cfg.getenv("ROLE"))
+	if address := cfg.getenv("SIMULATION_ADDRESS"); address != "" {
+		if !common.IsHexAddress(address) {
+			return fmt.Errorf("invalid SIMULATION_ADDRESS: %s", address)
+		}
+		cfg.SimulationAddress = common.HexToAddress(address)
+	}
+	cfg.HeartbeatURL = cfg.getenv("HEARTBEAT_URL")
+	for _, duration := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"HEARTBEAT_INTERVAL", &cfg.HeartbeatInterval},
+		{"HEARTBEAT_STALENESS", &cfg.HeartbeatStaleness},
+	} {
+		if value := cfg.getenv(duration.name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", duration.name, err)
+			}
+			*duration.value = parsed
+		}
+	}
+	cfg.RolePath = cfg.getenv("ROLE_PATH")
+
+	if count := cfg.getenv("SHARD_COUNT"); count != "" {
+		value, err := strconv.Atoi(count)
+		if err != nil {
+			return fmt.Errorf("invalid SHARD_COUNT: %w", err)
+		}
+		if value <= 0 {
+			return errors.New("SHARD_COUNT must be positive")
+		}
+		index := cfg.getenv("SHARD_INDEX")
+		if index == "" {
+			return errors.New("SHARD_INDEX must be set with SHARD_COUNT")
+		}
+		cfg.Shard.Count = value
+		if cfg.Shard.Index, err = strconv.Atoi(index); err != nil {
+			return fmt.Errorf("invalid SHARD_INDEX: %w", err)
+		}
+		if cfg.Shard.Index < 0 || cfg.Shard.Index >= value {
+			return errors.New("SHARD_INDEX must be below SHARD_COUNT")
+		}
+	} else if cfg.getenv("SHARD_INDEX") != "" {
+		return errors.New("SHARD_COUNT must be set with SHARD_INDEX")
+	}
+
+	if queueSize := cfg.getenv("EXECUTION_QUEUE_SIZE"); queueSize != "" {
+		value, err := strconv.Atoi(queueSize)
+		if err != nil {
+			return fmt.Errorf("invalid EXECUTION_QUEUE_SIZE: %w", err)
+		}
+		if value <= 0 {
+			return errors.New("EXECUTION_QUEUE_SIZE must be positive")
+		}
+		cfg.ExecutionQueueSize = value
+	}
+
+	if maxCandidates := cfg.getenv("MAX_CANDIDATES_PER_BLOCK"); maxCandidates != "" {
+		value, err := strconv.Atoi(maxCandidates)
+		if err != nil {
+			return fmt.Errorf("invalid MAX_CANDIDATES_PER_BLOCK: %w", err)
+		}
+		if value < 0 {
+			return errors.New("MAX_CANDIDATES_PER_BLOCK cannot be negative")
+		}
+		cfg.MaxCandidatesPerBlock = value
+	}
+
+	if lossLimit := cfg.getenv("DAILY_LOSS_LIMIT"); lossLimit != "" {
+		value, symbol, err := ParseQuantity(lossLimit, valueDecimals, nativeUnits(cfg.getenv("NATIVE_SYMBOL")))
+		if err != nil {
+			return fmt.Errorf("invalid DAILY_LOSS_LIMIT: %w", err)
+		}
+		if value.Sign() == -1 {
+			return fmt.Errorf("invalid DAILY_LOSS_LIMIT: %s", lossLimit)
+		}
+		cfg.DailyLossLimit, cfg.dailyLossLimitSymbol = value.Value, symbol
+	}
+	cfg.DataDir = cfg.getenv("DATA_DIR")
+	cfg.LedgerPath = cfg.getenv("LEDGER_PATH")
+	cfg.ForkRPCURL = cfg.getenv("FORK_RPC_URL")
+	if timeout := cfg.getenv("FORK_TIMEOUT"); timeout != "" {
+		value, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("invalid FORK_TIMEOUT: %w", err)
+		}
+		cfg.ForkTimeout = value
+	}
+	if tolerance := cfg.getenv("FORK_TOLERANCE"); tolerance != "" {
+		value, err := ParseRatio(tolerance)
+		if err != nil || IsNegative(value.Num) {
+			return fmt.Errorf("invalid FORK_TOLERANCE: %s", tolerance)
+		}
+		cfg.ForkTolerance = &value
+	}
+	if drift := cfg.getenv("FORK_MAX_DRIFT"); drift != "" {
+		value, err := strconv.ParseUint(drift, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid FORK_MAX_DRIFT: %w", err)
+		}
+		cfg.ForkMaxDrift = value
+	}
+	cfg.FiatIDsPath = cfg.getenv("FIAT_IDS_PATH")
+	cfg.FiatPriceURL = cfg.getenv("FIAT_PRICE_URL")
+	if ttl := cfg.getenv("FIAT_PRICE_TTL"); ttl != "" {
+		value, err := time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("invalid FIAT_PRICE_TTL: %w", err)
+		}
+		cfg.FiatPriceTTL = value
+	}
+	if rate := cfg.getenv("FIAT_PRICE_RATE"); rate != "" {
+		value, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return fmt.Errorf("invalid FIAT_PRICE_RATE: %w", err)
+		}
+		if value < 0 {
+			return errors.New("FIAT_PRICE_RATE cannot be negative")
+		}
+		cfg.FiatPriceRate = value
+	}
+	if tolerance := cfg.getenv("OUTCOME_DRIFT_TOLERANCE"); tolerance != "" {
+		value, ok := new(big.Int).SetString(tolerance, 10)
+		if !ok || IsNegative(value) {
+			return fmt.Errorf("invalid OUTCOME_DRIFT_TOLERANCE: %s", tolerance)
+		}
+		cfg.OutcomeDriftTolerance = value
+	}
+	cfg.AnnotationsPath = cfg.getenv("ANNOTATIONS_PATH")
+	accounts, err := ParseAddresses(cfg.getenv("ACCOUNTS"))
+	if err != nil {
+		return fmt.Errorf("invalid ACCOUNTS: %w", err)
+	}
+	cfg.Accounts = accounts
+	cfg.AccountsPath = cfg.getenv("ACCOUNTS_PATH")
+	if registry := cfg.getenv("ENS_REGISTRY_ADDRESS"); registry != "" {
+		if !common.IsHexAddress(registry) {
+			return fmt.Errorf("invalid ENS_REGISTRY_ADDRESS: %s", registry)
+		}
+		address := common.HexToAddress(registry)
+		cfg.ENSRegistryAddress = &address
+	}
+	cfg.RemoteConfigURL = cfg.getenv("REMOTE_CONFIG_URL")
+	if signer := cfg.getenv("REMOTE_CONFIG_SIGNER"); signer != "" {
+		if !common.IsHexAddress(signer) {
+			return fmt.Errorf("invalid REMOTE_CONFIG_SIGNER: %s", signer)
+		}
+		cfg.RemoteConfigSigner = common.HexToAddress(signer)
+	}
+	if interval := cfg.getenv("REMOTE_CONFIG_INTERVAL"); interval != "" {
+		value, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("invalid REMOTE_CONFIG_INTERVAL: %w", err)
+		}
+		cfg.RemoteConfigInterval = value
+	}
+	cfg.RemoteConfigPath = cfg.getenv("REMOTE_CONFIG_PATH")
+	cfg.AlertsPath = cfg.getenv("ALERTS_PATH")
+	if interval := cfg.getenv("ALERT_RENOTIFY_INTERVAL"); interval != "" {
+		value, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("invalid ALERT_RENOTIFY_INTERVAL: %w", err)
+		}
+		cfg.AlertRenotifyInterval = value
+	}
+	if margin := cfg.getenv("ALERT_RESOLVE_MARGIN"); margin != "" {
+		value, _, err := ParseQuantity(margin, valueDecimals, valueUnits)
+		if err != nil {
+			return fmt.Errorf("invalid ALERT_RESOLVE_MARGIN: %w", err)
+		}
+		if value.Sign() == -1 {
+			return fmt.Errorf("invalid ALERT_RESOLVE_MARGIN: %s", margin)
+		}
+		cfg.AlertResolveMargin = value.Value
+	}
+	if dust := cfg.getenv("BAD_DEBT_DUST"); dust != "" {
+		value, _, err := ParseQuantity(dust, valueDecimals, valueUnits)
+		if err != nil {
+			return fmt.Errorf("invalid BAD_DEBT_DUST: %w", err)
+		}
+		if value.Sign() == -1 {
+			return fmt.Errorf("invalid BAD_DEBT_DUST: %s", dust)
+		}
+		cfg.BadDebtDust = value.Value
+	}
+	if shortfall := cfg.getenv("RISK_SHORTFALL_ALERT"); shortfall != "" {
+		value, _, err := ParseQuantity(shortfall, valueDecimals, valueUnits)
+		if err != nil {
+			return fmt.Errorf("invalid RISK_SHORTFALL_ALERT: %w", err)
+		}
+		if value.Sign() == -1 {
+			return fmt.Errorf("invalid RISK_SHORTFALL_ALERT: %s", shortfall)
+		}
+		cfg.RiskShortfallAlert = value.Value
+	}
+	if concentration := cfg.getenv("RISK_CONCENTRATION_ALERT"); concentration != "" {
+		value, err := ParseRatio(concentration)
+		if err != nil || IsNegative(value.Num) {
+			return fmt.Errorf("invalid RISK_CONCENTRATION_ALERT: %s", concentration)
+		}
+		cfg.RiskConcentrationAlert = &value
+	}
+	if illiquid := cfg.getenv("ILLIQUID_COLLATERAL_ALERT"); illiquid != "" {
+		value, err := ParseRatio(illiquid)
+		if err != nil || IsNegative(value.Num) {
+			return fmt.Errorf("invalid ILLIQUID_COLLATERAL_ALERT: %s", illiquid)
+		}
+		cfg.IlliquidCollateralAlert = &value
+	}
+	cfg.JournalPath = cfg.getenv("JOURNAL_PATH")
+	cfg.NATSURL = cfg.getenv("NATS_URL")
+	cfg.NATSSubject = cfg.getenv("NATS_SUBJECT")
+	cfg.NATSAckSubject = cfg.getenv("NATS_ACK_SUBJECT")
+	if buffer := cfg.getenv("NATS_BUFFER"); buffer != "" {
+		value, err := strconv.Atoi(buffer)
+		if err != nil {
+			return fmt.Errorf("invalid NATS_BUFFER: %w", err)
+		}
+		if value <= 0 {
+			return errors.New("NATS_BUFFER must be positive")
+		}
+		cfg.NATSBuffer = value
+	}
+	for _, duration := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"NATS_REDELIVERY", &cfg.NATSRedelivery},
+		{"NATS_HANDOFF", &cfg.NATSHandoff},
+	} {
+		if value := cfg.getenv(duration.name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", duration.name, err)
+			}
+			*duration.value = parsed
+		}
+	}
+	cfg.ReadinessAddress = cfg.getenv("READINESS_ADDRESS")
+	if maxAge := cfg.getenv("RETENTION_MAX_AGE"); maxAge != "" {
+		value, err := time.ParseDuration(maxAge)
+		if err != nil {
+			return fmt.Errorf("invalid RETENTION_MAX_AGE: %w", err)
+		}
+		cfg.RetentionMaxAge = value
+	}
+	if maxSize := cfg.getenv("RETENTION_MAX_SIZE"); maxSize != "" {
+		value, err := strconv.ParseInt(maxSize, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid RETENTION_MAX_SIZE: %w", err)
+		}
+		cfg.RetentionMaxSize = value
+	}
+	if interval := cfg.getenv("RETENTION_INTERVAL"); interval != "" {
+		value, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("invalid RETENTION_INTERVAL: %w", err)
+		}
+		cfg.RetentionInterval = value
+	}
+	if maxSize := cfg.getenv("JOURNAL_MAX_SIZE"); maxSize != "" {
+		value, err := strconv.ParseInt(maxSize, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid JOURNAL_MAX_SIZE: %w", err)
+		}
+		cfg.JournalMaxSize = value
+	}
+	cfg.PendingTxPath = cfg.getenv("PENDING_TX_PATH")
+	if ttl := cfg.getenv("PENDING_TX_TTL"); ttl != "" {
+		value, err := time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("invalid PENDING_TX_TTL: %w", err)
+		}
+		cfg.PendingTxTTL = value
+	}
+
+	if interval := cfg.getenv("GOVERNANCE_CHECK_INTERVAL"); interval != "" {
+		value, err := strconv.ParseUint(interval, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid GOVERNANCE_CHECK_INTERVAL: %w", err)
+		}
+		cfg.GovernanceCheckInterval = value
+	}
+	if pause := cfg.getenv("PAUSE_ON_GOVERNANCE_CHANGE"); pause != "" {
+		value, err := strconv.ParseBool(pause)
+		if err != nil {
+			return fmt.Errorf("invalid PAUSE_ON_GOVERNANCE_CHANGE: %w", err)
+		}
+		cfg.PauseOnGovernanceChange = value
+	}
+
+	if maxGasPrice := cfg.getenv("MAX_GAS_PRICE"); maxGasPrice != "" {
+		value, ok := new(big.Int).SetString(maxGasPrice, 10)
+		if !ok || !IsPositive(value) {
+			return fmt.Errorf("invalid MAX_GAS_PRICE: %s", maxGasPrice)
+		}
+		cfg.MaxGasPrice = value
+	}
+	if maxFeePerGas := cfg.getenv("MAX_FEE_PER_GAS"); maxFeePerGas != "" {
+		value, ok := new(big.Int).SetString(maxFeePerGas, 10)
+		if !ok || !IsPositive(value) {
+			return fmt.Errorf("invalid MAX_FEE_PER_GAS: %s", maxFeePerGas)
+		}
+		cfg.MaxFeePerGas = value
+	}
+
+	if limits := cfg.getenv("SLIPPAGE_LIMITS"); limits != "" {
+		value, err := parseSlippageLimits(limits)
+		if err != nil {
+			return fmt.Errorf("invalid SLIPPAGE_LIMITS: %w", err)
+		}
+		cfg.SlippageLimits = value
+	}
+	if classes := cfg.getenv("TOKEN_CLASSES"); classes != "" {
+		value, err := parseTokenClasses(classes)
+		if err != nil {
+			return fmt.Errorf("invalid TOKEN_CLASSES: %w", err)
+		}
+		cfg.TokenClasses = value
+	}
+
+	if mempool := cfg.getenv("MEMPOOL_MONITORING"); mempool != "" {
+		value, err := strconv.ParseBool(mempool)
+		if err != nil {
+			return fmt.Errorf("invalid MEMPOOL_MONITORING: %w", err)
+		}
+		cfg.MempoolMonitoring = value
+	}
+	if accessLists := cfg.getenv("ACCESS_LISTS"); accessLists != "" {
+		value, err := strconv.ParseBool(accessLists)
+		if err != nil {
+			return fmt.Errorf("invalid ACCESS_LISTS: %w", err)
+		}
+		cfg.AccessLists = value
+	}
+	if execute := cfg.getenv("EXECUTE_LIQUIDATIONS"); execute != "" {
+		value, err := strconv.ParseBool(execute)
+		if err != nil {
+			return fmt.Errorf("invalid EXECUTE_LIQUIDATIONS: %w", err)
+		}
+		cfg.ExecuteLiquidations = value
+	}
+	if standDown := cfg.getenv("STAND_DOWN_ON_COMPETITION"); standDown != "" {
+		value, err := strconv.ParseBool(standDown)
+		if err != nil {
+			return fmt.Errorf("invalid STAND_DOWN_ON_COMPETITION: %w", err)
+		}
+		cfg.StandDownOnCompetition = value
+	}
+
+	cfg.NativeSymbol = cfg.getenv("NATIVE_SYMBOL")
+	cfg.ProtocolAdapter = cfg.getenv("PROTOCOL_ADAPTER")
+	if venusLiquidator := cfg.getenv("VENUS_LIQUIDATOR_ADDRESS"); venusLiquidator != "" {
+		cfg.VenusLiquidatorAddress = common.HexToAddress(venusLiquidator)
+	}
+
+	cfg.FlashLiquiditySource = cfg.getenv("FLASH_LIQUIDITY_SOURCE")
+	if aavePool := cfg.getenv("AAVE_POOL_ADDRESS"); aavePool != "" {
+		address := common.HexToAddress(aavePool)
+		cfg.AavePoolAddress = &address
+	}
+
+	if feeds := cfg.getenv("CHAINLINK_FEEDS"); feeds != "" {
+		value, err := parseFeeds(feeds)
+		if err != nil {
+			return fmt.Errorf("invalid CHAINLINK_FEEDS: %w", err)
+		}
+		cfg.ChainlinkFeeds = value
+	}
+
+	if maxDeviation := cfg.getenv("MAX_PRICE_DEVIATION"); maxDeviation != "" {
+		value, ok := new(big.Int).SetString(maxDeviation, 10)
+		if !ok {
+			return fmt.Errorf("invalid MAX_PRICE_DEVIATION: %s", maxDeviation)
+		}
+		cfg.MaxPriceDeviation = value
+	}
+
+	if limits := cfg.getenv("PRICE_DEVIATION_LIMITS"); limits != "" {
+		value, err := parseDeviationLimits(limits)
+		if err != nil {
+			return fmt.Errorf("invalid PRICE_DEVIATION_LIMITS: %w", err)
+		}
+		cfg.PriceDeviationLimits = value
+	}
+
+	if events := cfg.getenv("PRICE_UPDATE_EVENTS"); events != "" {
+		value, err := parsePriceUpdateEvents(events)
+		if err != nil {
+			return fmt.Errorf("invalid PRICE_UPDATE_EVENTS: %w", err)
+		}
+		cfg.PriceUpdateEvents = value
+	}
+
+	comptrollers, err := ParseAddresses(cfg.getenv("COMPTROLLER_ADDRESS"))
+	if err != nil {
+		return fmt.Errorf("invalid COMPTROLLER_ADDRESS: %w", err)
+	}
+	cfg.Comptrollers = comptrollers
+
+	ignoreWhitelist, err := ParseAddresses(cfg.getenv("IGNORE_WHITELIST_POOLS"))
+	if err != nil {
+		return fmt.Errorf("invalid IGNORE_WHITELIST_POOLS: %w", err)
+	}
+	cfg.IgnoreWhitelistPools = ignoreWhitelist
+
+	if policies := cfg.getenv("TRANSFER_PAUSED_POLICIES"); policies != "" {
+		value, err := parseTransferPausedPolicies(policies)
+		if err != nil {
+			return fmt.Errorf("invalid TRANSFER_PAUSED_POLICIES: %w", err)
+		}
+		cfg.TransferPausedPolicies = value
+	}
+
+	if overrides := cfg.getenv("CAPABILITY_OVERRIDES"); overrides != "" {
+		value, err := parseCapabilityOverrides(overrides)
+		if err != nil {
+			return fmt.Errorf("invalid CAPABILITY_OVERRIDES: %w", err)
+		}
+		cfg.CapabilityOverrides = value
+	}
+
+	comets, err := ParseAddresses(cfg.getenv("COMET_ADDRESS"))
+	if err != nil {
+		return fmt.Errorf("invalid COMET_ADDRESS: %w", err)
+	}
+	cfg.Comets = comets
+
+	cometAccounts, err := ParseAddresses(cfg.getenv("COMET_ACCOUNTS"))
+	if err != nil {
+		return fmt.Errorf("invalid COMET_ACCOUNTS: %w", err)
+	}
+	cfg.CometAccounts = cometAccounts
+
+	if buyCollateral := cfg.getenv("COMET_BUY_COLLATERAL"); buyCollateral != "" {
+		value, err := strconv.ParseBool(buyCollateral)
+		if err != nil {
+			return fmt.Errorf("invalid COMET_BUY_COLLATERAL: %w", err)
+		}
+		cfg.CometBuyCollateral = value
+	}
+
+	if cfg.getenv("POOL_DIRECTORY_ADDRESS") != "" {
+		discovery, err := discoveryConfigFromEnv(cfg.getenv)
+		if err != nil {
+			return err
+		}
+		cfg.Discovery = discovery
+	}
+
+	cfg.PrivateKey = cfg.getenv("PRIVATE_KEY")
+	switch {
+	case cfg.Role == RoleSimulation && cfg.PrivateKey != "":
+		return fmt.Errorf("ROLE %s holds no key; unset PRIVATE_KEY", RoleSimulation)
+	case cfg.Role != RoleSimulation && cfg.PrivateKey == "":
+		return errors.New("PRIVATE_KEY cannot be empty")
+	}
+
+	return nil
+}
+
+func discoveryConfigFromEnv(getenv func(string) string) (*DiscoveryConfig, error) {
+	d := &DiscoveryConfig{
+		PoolDirectory: common.HexToAddress(getenv("POOL_DIRECTORY_ADDRESS")),
+	}
+
+	if getenv("POOL_DISCOVERY_INTERVAL") == "" {
+		return nil, errors.New("POOL_DISCOVERY_INTERVAL cannot be empty")
+	}
+	interval, err := time.ParseDuration(getenv("POOL_DISCOVERY_INTERVAL"))
+	if err != nil {
+		return nil, err
+	}
+	d.Interval = interval
+
+	if minTotalBorrows := getenv("POOL_DISCOVERY_MIN_TOTAL_BORROWS"); minTotalBorrows != "" {
+		value, _, err := ParseQuantity(minTotalBorrows, valueDecimals, valueUnits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid POOL_DISCOVERY_MIN_TOTAL_BORROWS: %w", err)
+		}
+		d.MinTotalBorrows = value.Value
+	}
+
+	admins, err := ParseAddresses(getenv("POOL_DISCOVERY_ADMINS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid POOL_DISCOVERY_ADMINS: %w", err)
+	}
+	d.Admins = admins
+
+	return d, nil
+}
+
+// ParseAddresses parses a comma-separated list of addresses.
+func ParseAddresses(value string) ([]common.Address, error) {
+	addresses := make([]common.Address, 0)
+	for _, address := range strings.Split(value, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid address %s", address)
+		}
+		addresses = append(addresses, common.HexToAddress(address))
+	}
+	return addresses, nil
+}
+
+// parseFeeds parses a comma-separated list of underlying:feed address
+// pairs.
+func parseFeeds(value string) (map[common.Address]common.Address, error) {
+	feeds := make(map[common.Address]common.Address)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid feed %s", pair)
+		}
+		addresses, err := ParseAddresses(strings.Join(parts, ","))
+		if err != nil {
+			return nil, err
+		}
+		if len(addresses) != 2 {
+			return nil, fmt.Errorf("invalid feed %s", pair)
+		}
+		feeds[addresses[0]] = addresses[1]
+	}
+	return feeds, nil
+}
+
+// Units of settings in the oracle's unit of account
+var valueUnits = map[string]uint8{unitOfAccountSymbol: valueDecimals}
+
+// nativeUnits returns the units of settings in the native token: its
+// symbol if configured, or else that of any preset, to be checked once
+// the chain is known.
+func nativeUnits(symbol string) map[string]uint8 {
+	units := map[string]uint8{nativeAliasSymbol: valueDecimals}
+	if symbol != "" {
+		units[strings.ToUpper(symbol)] = valueDecimals
+		return units
+	}
+	for _, preset := range chainPresets {
+		units[strings.ToUpper(preset.NativeSymbol)] = valueDecimals
+	}
+	return units
+}
+
+// parseDeviationLimits parses a comma-separated list of
+// underlying:limit pairs.
+func parseDeviationLimits(value string) (map[common.Address]*big.Int, error) {
+	limits := make(map[common.Address]*big.Int)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 || !common.IsHexAddress(strings.TrimSpace(parts[0])) {
+			return nil, fmt.Errorf("invalid limit %s", pair)
+		}
+		limit, ok := new(big.Int).SetString(strings.TrimSpace(parts[1]), 10)
+		if !ok || IsNegative(limit) {
+			return nil, fmt.Errorf("invalid limit %s", pair)
+		}
+		limits[common.HexToAddress(strings.TrimSpace(parts[0]))] = limit
+	}
+	return limits, nil
+}
+
+// parseTransferPausedPolicies parses a comma-separated list of
+// pool:policy pairs.
+func parseTransferPausedPolicies(value string) (map[common.Address]string, error) {
+	policies := make(map[common.Address]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 || !common.IsHexAddress(strings.TrimSpace(parts[0])) {
+			return nil, fmt.Errorf("invalid policy %s", pair)
+		}
+		policy := strings.TrimSpace(parts[1])
+		if policy != TransferPausedDeprioritize && policy != TransferPausedProceed {
+			return nil, fmt.Errorf("unknown policy %q", policy)
+		}
+		policies[common.HexToAddress(strings.TrimSpace(parts[0]))] = policy
+	}
+	return policies, nil
+}
+
+// parseCapabilityOverrides parses a comma-separated list of
+// [pool:]capability=bool pairs; those without a pool apply to every
+// pool.
+func parseCapabilityOverrides(value string) (map[common.Address]map[string]bool, error) {
+	overrides := make(map[common.Address]map[string]bool)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid override %s", pair)
+		}
+		var pool common.Address
+		name := strings.TrimSpace(parts[0])
+		if i := strings.Index(name, ":"); i >= 0 {
+			if !common.IsHexAddress(strings.TrimSpace(name[:i])) {
+				return nil, fmt.Errorf("invalid override %s", pair)
+			}
+			pool, name = common.HexToAddress(strings.TrimSpace(name[:i])), strings.TrimSpace(name[i+1:])
+		}
+		if !isCapability(name) {
+			return nil, fmt.Errorf("unknown capability %q", name)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid override %s: %w", pair, err)
+		}
+		if overrides[pool] == nil {
+			overrides[pool] = make(map[string]bool)
+		}
+		overrides[pool][name] = enabled
+	}
+	return overrides, nil
+}
+
+// parsePriceUpdateEvents parses a semicolon-separated list of
+// emitter:event[:market] triples, as event signatures have commas. The
+// event is a signature, eg., AnswerUpdated(int256,uint256,uint256), or
+// its topic.
+func parsePriceUpdateEvents(value string) ([]PriceUpdateEvent, error) {
+	events := make([]PriceUpdateEvent, 0)
+	for _, triple := range strings.Split(value, ";") {
+		triple = strings.TrimSpace(triple)
+		if triple == "" {
+			continue
+		}
+		parts := strings.Split(triple, ":")
+		if len(parts) < 2 || len(parts) > 3 || !common.IsHexAddress(strings.TrimSpace(parts[0])) {
+			return nil, fmt.Errorf("invalid event %s", triple)
+		}
+		event := PriceUpdateEvent{Emitter: common.HexToAddress(strings.TrimSpace(parts[0]))}
+		switch signature := strings.TrimSpace(parts[1]); {
+		case strings.HasPrefix(signature, "0x") && len(signature) == 2+2*common.HashLength:
+			event.Topic = common.HexToHash(signature)
+		case strings.Contains(signature, "(") && strings.HasSuffix(signature, ")"):
+			event.Topic = crypto.Keccak256Hash([]byte(signature))
+		default:
+			return nil, fmt.Errorf("invalid event %s", triple)
+		}
+		if len(parts) == 3 {
+			if !common.IsHexAddress(strings.TrimSpace(parts[2])) {
+				return nil, fmt.Errorf("invalid market in event %s", triple)
+			}
+			event.Market = common.HexToAddress(strings.TrimSpace(parts[2]))
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// parseSlippageLimits parses a comma-separated list of class:limit
+// pairs.
+func parseSlippageLimits(value string) (map[TokenClass]*big.Int, error) {
+	limits := make(map[TokenClass]*big.Int)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid limit %s", pair)
+		}
+		class := TokenClass(strings.TrimSpace(parts[0]))
+		if _, ok := defaultSlippageLimits[class]; !ok {
+			return nil, fmt.Errorf("unknown token class %q", class)
+		}
+		limit, ok := new(big.Int).SetString(strings.TrimSpace(parts[1]), 10)
+		if !ok || IsNegative(limit) || GT(limit, expScale) {
+			return nil, fmt.Errorf("invalid limit %s", pair)
+		}
+		limits[class] = limit
+	}
+	return limits, nil
+}
+
+// parseTokenClasses parses a comma-separated list of underlying:class
+// pairs.
+func parseTokenClasses(value string) (map[common.Address]TokenClass, error) {
+	classes := make(map[common.Address]TokenClass)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 || !common.IsHexAddress(strings.TrimSpace(parts[0])) {
+			return nil, fmt.Errorf("invalid token class %s", pair)
+		}
+		class := TokenClass(strings.TrimSpace(parts[1]))
+		if _, ok := defaultSlippageLimits[class]; !ok {
+			return nil, fmt.Errorf("unknown token class %q", class)
+		}
+		classes[common.HexToAddress(strings.TrimSpace(parts[0]))] = class
+	}
+	return classes, nil
+}
+
+// parseLogLimits parses semicolon-separated
+// host=range[,addresses[,concurrency]] entries, eg.,
+// *=2000;node.example.com=10000,50,4. Zero leaves a limit unset.
+func parseLogLimits(value string) (map[string]LogLimits, error) {
+	limits := make(map[string]LogLimits)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid limits %s", entry)
+		}
+		values := strings.Split(parts[1], ",")
+		if len(values) > 3 {
+			return nil, fmt.Errorf("invalid limits %s", entry)
+		}
+		numbers := make([]uint64, 3)
+		for i, v := range values {
+			number, err := strconv.ParseUint(strings.TrimSpace(v), 10, 31)
+			if err != nil {
+				return nil, fmt.Errorf("invalid limits %s: %w", entry, err)
+			}
+			numbers[i] = number
+		}
+		limits[strings.ToLower(strings.TrimSpace(parts[0]))] = LogLimits{BlockRange: numbers[0], AddressBatch: int(numbers[1]), Concurrency: int(numbers[2])}
+	}
+	return limits, nil
+}
@@ -0,0 +1,798 @@
+package liquidatoor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Connection holds the node connection and the wallet shared by
+// every pool monitored from the same process.
+type Connection struct {
+	config *Config
+	logger Logger
+
+	// Node connection
+	client    Backend
+	rpcClient *rpc.Client
+	// Set when rpcClient was dialed by Connect rather than provided
+	dialed bool
+	// Connections for read batches, if any
+	readPool *ReadPool
+	chainID  *big.Int
+	preset   ChainPreset
+	// Renders addresses and links to the blockchain explorer
+	presenter *Presenter
+	TxOpts    *bind.TransactOpts
+
+	Batcher        CallBatcher
+	l1FeeEstimator L1FeeEstimator
+	gasCap         *gasCap
+	// Of the transactions sent, if enabled
+	accessLists *accessLists
+	// Submits the transactions sent to every submission endpoint, if any
+	broadcaster *broadcaster
+	// Of the batcher dialed, if any
+	bisector *bisector
+	// Log limits of the node, shared by every log query
+	logLimiter *logLimiter
+	// Host of the endpoint read calls are sent to
+	readEndpoint string
+	// Work of every pool, shared with other connections, if bounded
+	budget *Budget
+	// Follows the events of every pool
+	bus *eventBus
+
+	borrowerCacheInterval time.Duration
+	blockTime             time.Duration
+	nativeSymbol          string
+	multicallAddress      *common.Address
+	batchSize             int
+
+	// Protocol adapter used for every pool
+	adapterName            string
+	venusLiquidatorAddress common.Address
+
+	// Flash loan venue, if any
+	flashLiquidityName string
+	aavePoolAddress    *common.Address
+	flashLiquidity     FlashLiquiditySource
+
+	// Selects liquidations in every pool
+	strategy Strategy
+	// Shared by every pool so it can be changed at runtime
+	maxCandidates *int64
+	// Executes the liquidations of every pool; started by Run
+	queue     *ExecutionQueue
+	ledger    *Ledger
+	cooldowns *Cooldowns
+	alerts    *Alerts
+	journal   *Journal
+	// Renders reported values in USD; nil unless configured
+	fiat *fiatReporter
+	// Replays plans before they are executed; nil unless configured
+	fork *forkValidator
+	// Labels accounts in every log line of the connection
+	annotations *Annotations
+	// Nil unless configured
+	remoteConfig *RemoteConfig
+	// Nil unless RoleSimulation
+	simulation *simulation
+	// Borrowers of every pool, if persisted
+	borrowerStore *borrowerStore
+	// Accounts monitored instead of the borrowers of the pools, if set
+	accountList *accountList
+	// Our liquidation transactions in flight; the ones left by a
+	// previous instance are waited for by Run
+	pending        *pendingTxs
+	pendingResumed []PendingTx
+	// Pending liquidations by others; nil unless monitoring
+	mempool *mempoolWatch
+	// Part of the borrowers checked
+	shard Shard
+	// Primary or standby; nil is primary without a heartbeat
+	roles *roles
+	// Candidates and their outcomes, if published
+	publisher *publisher
+	// Whether every pool completed a check
+	readiness *readiness
+
+	// Comet markets
+	cometAccounts      []common.Address
+	cometBuyCollateral bool
+
+	// Borrow event scanning for pools without getAllBorrowers
+	borrowerScanStartBlock uint64
+	borrowerScanBlockRange uint64
+}
+
+// Connect dials the configured node and connects to it.
+func Connect(ctx context.Context, cfg *Config, opts ...Option) (*Connection, error) {
+	if cfg.NodeAPIURL == "" {
+		return nil, fmt.Errorf("%w: NODE_API_URL cannot be empty", ErrInvalidConfig)
+	}
+	c := newConnection(cfg, opts)
+
+	// Connect to node
+	rpcClient, err := rpc.DialContext(ctx, cfg.NodeAPIURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to node: %w", err)
+	}
+	client := ethclient.NewClient(rpcClient)
+	c.rpcClient = rpcClient
+	c.dialed = true
+
+	if cfg.ReadPoolSize > 0 {
+		readURL := cfg.ReadNodeAPIURL
+		if readURL == "" {
+			readURL = cfg.NodeAPIURL
+		}
+		readPool, err := dialReadPool(ctx, readURL, cfg.ReadPoolSize)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.readPool = readPool
+		c.logger.Info(fmt.Sprintf("Reading over %d dedicated connections", readPool.Size()), F("connections", readPool.Size()))
+	}
+
+	chainID, err := client.NetworkID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get chain id: %w", err)
+	}
+
+	var backend Backend = client
+	if len(cfg.SubmissionURLs) > 0 {
+		c.broadcaster, err = dialBroadcaster(ctx, c.logger, client, c.logLimiter.host, cfg.SubmissionURLs)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		backend = &broadcastClient{Client: client, broadcaster: c.broadcaster}
+		c.logger.Info(fmt.Sprintf("Submitting transactions to %d endpoints at once", len(c.broadcaster.endpoints)), F("endpoints", len(c.broadcaster.endpoints)))
+	}
+
+	if err := c.connect(ctx, backend, chainID, nil); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the connections dialed by Connect, the journal and the
+// borrower store. The read pool and submission stats are logged first.
+func (c *Connection) Close() {
+	c.alerts.persist()
+	c.journal.Close()
+	c.borrowerStore.Close()
+	if c.readPool != nil {
+		for i, stats := range c.readPool.Stats() {
+			c.logger.Info(fmt.Sprintf("Read connection %d: %d requests, %d failures", i, stats.Requests, stats.Failures),
+				F("connection", i), F("requests", stats.Requests), F("failures", stats.Failures))
+		}
+		c.readPool.Close()
+	}
+	if stats := c.bisector.Stats(); stats.Bisections > 0 {
+		c.logger.Info(fmt.Sprintf("Bisected %d reverted batches in %d attempts: %d calls isolated, %d unresolved, %d left out now",
+			stats.Bisections, stats.Attempts, stats.Isolated, stats.Unresolved, stats.Poisoned),
+			F("bisections", stats.Bisections), F("attempts", stats.Attempts), F("isolated", stats.Isolated), F("unresolved", stats.Unresolved), F("poisoned", stats.Poisoned))
+	}
+	for _, stats := range c.broadcaster.Stats() {
+		c.logger.Info(fmt.Sprintf("Submissions to %s: %d sent, %d accepted, %d first, %d known, %d failures, %s mean latency",
+			stats.Endpoint, stats.Submissions, stats.Accepted, stats.First, stats.Known, stats.Failures, stats.MeanLatency),
+			F("endpoint", stats.Endpoint), F("submissions", stats.Submissions), F("accepted", stats.Accepted), F("first", stats.First),
+			F("known", stats.Known), F("failures", stats.Failures), F("latency", stats.MeanLatency))
+	}
+	c.broadcaster.Close()
+	stats := c.logLimiter.stats()
+	c.logger.Info(fmt.Sprintf("Log queries: %d requests, %d failures, limits shrunk %d times to %d blocks and %s",
+		stats.Requests, stats.Failures, stats.Shrinks, stats.BlockRange, formatAddressBatch(stats.AddressBatch)),
+		F("endpoint", stats.Endpoint), F("requests", stats.Requests), F("failures", stats.Failures), F("shrinks", stats.Shrinks),
+		F("range", stats.BlockRange), F("addresses", stats.AddressBatch), F("concurrency", stats.Concurrency))
+	if c.publisher != nil {
+		stats := c.publisher.stats()
+		c.logger.Info(fmt.Sprintf("Published %d events, %d again, %d acknowledged, %d handled externally, %d dropped, %d buffered",
+			stats.Published, stats.Redelivered, stats.Acked, stats.Handled, stats.Dropped, stats.Buffered),
+			F("published", stats.Published), F("redelivered", stats.Redelivered), F("acked", stats.Acked), F("handled", stats.Handled),
+			F("dropped", stats.Dropped), F("buffered", stats.Buffered))
+	}
+	c.simulation.report()
+	for _, u := range c.BudgetUsage() {
+		c.logger.Info(fmt.Sprintf("Budget of pool %s: %s", u.Pool, formatBudgetUsage(u)),
+			F("pool", u.Pool), F("executions", u.Executions), F("executionWait", u.ExecutionWait), F("multicalls", u.Multicalls), F("multicallWait", u.MulticallWait))
+	}
+	for _, stats := range c.EventBusStats() {
+		c.logger.Info(fmt.Sprintf("Event subscriber %s: %d delivered, %d dropped", stats.Name, stats.Delivered, stats.Dropped),
+			F("logs", stats.Name), F("delivered", stats.Delivered), F("dropped", stats.Dropped))
+	}
+	if c.dialed {
+		c.rpcClient.Close()
+	}
+}
+
+// SetMaxCandidates changes the per-block candidate limit of every pool
+// from the next block on. Zero is unlimited.
+func (c *Connection) SetMaxCandidates(k int) {
+	atomic.StoreInt64(c.maxCandidates, int64(k))
+	c.logger.Info(fmt.Sprintf("Candidate limit set to %d per block", k), F("limit", k))
+}
+
+// Ledger returns the ledger of executions, whose kill switch stops
+// every pool on excessive losses.
+func (c *Connection) Ledger() *Ledger {
+	return c.ledger
+}
+
+// Journal returns the journal, if any, for executors to record their
+// submissions in.
+func (c *Connection) Journal() *Journal {
+	return c.journal
+}
+
+// Submitted records a liquidation transaction an executor sent for a
+// candidate, so that the account is not liquidated again in the same
+// market, also across restarts, until the transaction is mined or
+// abandoned. It is watched until ctx is cancelled.
+func (c *Connection) Submitted(ctx context.Context, tx *types.Transaction, candidate Candidate) {
+	var market common.Address
+	if candidate.Plan != nil {
+		market = candidate.Plan.BorrowMarket
+	}
+	c.pending.sent(tx, candidate.Pool, candidate.Account, market)
+	go c.pending.watch(ctx, c.client, tx.Hash(), c.blockTime)
+}
+
+// Annotations returns the account annotations.
+func (c *Connection) Annotations() *Annotations {
+	return c.annotations
+}
+
+// RemoteConfig returns the remote config, if configured.
+func (c *Connection) RemoteConfig() *RemoteConfig {
+	return c.remoteConfig
+}
+
+// SimulationStats returns what the simulation found, with
+// RoleSimulation.
+func (c *Connection) SimulationStats() SimulationStats {
+	return c.simulation.stats()
+}
+
+// Alerts returns the alerts of underwater accounts.
+func (c *Connection) Alerts() *Alerts {
+	return c.alerts
+}
+
+// Cooldowns returns the cooldowns of accounts whose executions failed,
+// to list or clear them.
+func (c *Connection) Cooldowns() *Cooldowns {
+	return c.cooldowns
+}
+
+// PublisherStats returns the counts of the events published, if
+// publishing.
+func (c *Connection) PublisherStats() PublisherStats {
+	return c.publisher.stats()
+}
+
+// Ready reports whether every pool monitored completed a check, with
+// its borrowers cached, its markets read and their prices fetched.
+// Blocks are skipped until then; see SkippedBlocks.
+func (c *Connection) Ready() bool {
+	ready, _, _ := c.readiness.status()
+	return ready
+}
+
+// SkippedBlocks returns the blocks pools skipped before being ready.
+func (c *Connection) SkippedBlocks() uint64 {
+	_, _, skipped := c.readiness.status()
+	return skipped
+}
+
+// BudgetUsage returns what every pool of the chain of the connection
+// used of the budget, if bounded.
+func (c *Connection) BudgetUsage() []BudgetUsage {
+	usage := make([]BudgetUsage, 0)
+	for _, u := range c.budget.Usage() {
+		if u.Chain == c.config.Chain {
+			usage = append(usage, u)
+		}
+	}
+	return usage
+}
+
+// Role returns whether the connection submits liquidations or stands
+// by.
+func (c *Connection) Role() Role {
+	return c.roles.role()
+}
+
+// ReadPoolStats returns the stats of every read connection, if any.
+func (c *Connection) ReadPoolStats() []ReadClientStats {
+	if c.readPool == nil {
+		return nil
+	}
+	return c.readPool.Stats()
+}
+
+// BisectionStats returns the bisections of the reverted batches of the
+// legacy multicall, if the batcher is dialed.
+func (c *Connection) BisectionStats() BisectionStats {
+	return c.bisector.Stats()
+}
+
+// SubmissionStats returns the stats of every endpoint transactions are
+// submitted to, the main node first, if any besides it.
+func (c *Connection) SubmissionStats() []SubmissionStats {
+	return c.broadcaster.Stats()
+}
+
+// LogLimitStats returns the log limits of the node in use, as shrunk
+// so far, and the log queries sent to it.
+func (c *Connection) LogLimitStats() LogLimitStats {
+	return c.logLimiter.stats()
+}
+
+// EventBusStats returns the stats of every subscriber of the events of
+// the pools, if connected.
+func (c *Connection) EventBusStats() []EventSubscriptionStats {
+	if c.bus == nil {
+		return nil
+	}
+	return c.bus.stats()
+}
+
+// BackendOptions configure a connection over an existing backend.
+type BackendOptions struct {
+	// Overrides the chain ID reported by the backend. Required for
+	// backends that cannot report it, eg., the simulated backend.
+	ChainID *big.Int
+	// Used for JSON-RPC batches when no multicall is deployed
+	RPCClient *rpc.Client
+	// Replaces the multicall or JSON-RPC batches
+	Batcher CallBatcher
+}
+
+// ConnectBackend connects over an already dialed backend instead of
+// the configured node. The configuration is validated against the
+// provided backend as in Connect.
+func ConnectBackend(ctx context.Context, cfg *Config, backend Backend, backendOpts BackendOptions, opts ...Option) (*Connection, error) {
+	c := newConnection(cfg, opts)
+	c.rpcClient = backendOpts.RPCClient
+
+	chainID := backendOpts.ChainID
+	if chainID == nil {
+		reader, ok := backend.(interface {
+			ChainID(ctx context.Context) (*big.Int, error)
+		})
+		if !ok {
+			return nil, errors.New("backend cannot report its chain id; set ChainID")
+		}
+		var err error
+		chainID, err = reader.ChainID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get chain id: %w", err)
+		}
+	}
+
+	if err := c.connect(ctx, backend, chainID, backendOpts.Batcher); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect sets up the wallet and every chain dependency on top of
+// the backend. A nil batcher is replaced by one matching the chain.
+func (c *Connection) connect(ctx context.Context, client Backend, chainID *big.Int, batcher CallBatcher) error {
+	c.client = client
+
+	c.logger.Info(fmt.Sprint("Chain ID: ", chainID), F("chain", chainID))
+	c.chainID = chainID
+	c.applyPreset()
+	c.logger.Info("Chain preset: "+c.preset.Name, F("preset", c.preset.Name))
+	// The preset multicall may be missing, and is replaced by JSON-RPC
+	// batches; only a configured one is checked
+	var multicall *common.Address
+	if batcher == nil {
+		multicall = c.config.MulticallAddress
+	}
+	if err := preflight(ctx, client, chainID, c.config, multicall); err != nil {
+		return err
+	}
+	if symbol := c.config.dailyLossLimitSymbol; symbol != "" && symbol != nativeAliasSymbol && symbol != strings.ToUpper(c.nativeSymbol) {
+		return fmt.Errorf("invalid DAILY_LOSS_LIMIT: %s is not the native token of chain %v, %s", symbol, chainID, c.nativeSymbol)
+	}
+	c.logger.Info("Protocol adapter: "+c.adapterName, F("adapter", c.adapterName))
+	if err := c.shard.validate(); err != nil {
+		return err
+	}
+	if c.shard.sharded() {
+		c.logger.Info(fmt.Sprintf("Checking shard %s of the borrowers of every pool", c.shard))
+	}
+	if limits := c.budget.Limits(); limits.limited() {
+		c.logger.Info(fmt.Sprintf("Budget: %d executions, %d multicalls at once and %g requests per second to every endpoint, zero unlimited", limits.ExecutionWorkers, limits.Multicalls, limits.EndpointRate),
+			F("executions", limits.ExecutionWorkers), F("multicalls", limits.Multicalls), F("rate", limits.EndpointRate))
+	}
+	limits := c.logLimiter.current()
+	c.logger.Info(fmt.Sprintf("Log limits: %d blocks and %s per query, %d at once", limits.BlockRange, formatAddressBatch(limits.AddressBatch), limits.Concurrency),
+		F("endpoint", c.logLimiter.host), F("provider", c.logLimiter.provider), F("range", limits.BlockRange), F("addresses", limits.AddressBatch), F("concurrency", limits.Concurrency))
+
+	if err := c.transactor(chainID); err != nil {
+		return err
+	}
+	address := c.TxOpts.From
+
+	l1FeeEstimator, err := newL1FeeEstimator(c.preset.L1Fee, client)
+	if err != nil {
+		return err
+	}
+	c.l1FeeEstimator = l1FeeEstimator
+
+	if c.config.AccessLists {
+		c.accessLists = newAccessLists(c.logger, c.rpcClient, chainID)
+	}
+
+	if c.config.MempoolMonitoring {
+		if c.mempool, err = newMempoolWatch(c.logger, c.rpcClient, chainID, address, c.blockTime); err != nil {
+			return err
+		}
+	}
+
+	if c.config.DataDir != "" {
+		if err := os.MkdirAll(c.config.DataDir, 0o700); err != nil {
+			return fmt.Errorf("cannot create data directory: %w", err)
+		}
+	}
+	if c.config.ForkRPCURL != "" {
+		if c.fork, err = newForkValidator(c.logger, c.config.ForkRPCURL, c.config.NodeAPIURL, c.config.ForkTimeout, c.config.ForkTolerance, c.config.ForkMaxDrift); err != nil {
+			return err
+		}
+	}
+	if c.config.FiatIDsPath != "" {
+		ids, err := loadFiatIDs(c.config.FiatIDsPath)
+		if err != nil {
+			return err
+		}
+		pricer := c.config.FiatPricer
+		if pricer == nil {
+			pricer = NewHTTPFiatPricer(c.config.FiatPriceURL, c.config.FiatPriceTTL, c.config.FiatPriceRate)
+		}
+		c.fiat = newFiatReporter(c.logger, pricer, ids, c.config.FiatPriceTTL)
+	}
+	ledger, err := OpenLedger(c.logger, c.config.LedgerPath, c.config.DailyLossLimit)
+	if err != nil {
+		return err
+	}
+	ledger.fiat = c.fiat
+	c.ledger = ledger
+	c.queue.ledger = ledger
+	if c.config.AnnotationsPath != "" {
+		if err := c.annotations.load(c.config.AnnotationsPath); err != nil {
+			return err
+		}
+	}
+	if c.config.ENSRegistryAddress != nil {
+		if c.presenter.names, err = newNameResolver(c.logger, client, *c.config.ENSRegistryAddress); err != nil {
+			return err
+		}
+	}
+	if c.config.RemoteConfigURL != "" {
+		if c.remoteConfig, err = newRemoteConfig(c.logger, c.config.RemoteConfigURL, c.config.RemoteConfigSigner, c.config.RemoteConfigInterval, c.config.RemoteConfigPath, c.annotations); err != nil {
+			return err
+		}
+		if err := c.remoteConfig.load(ctx); err != nil {
+			return err
+		}
+	}
+	if len(c.config.Accounts) > 0 || c.config.AccountsPath != "" {
+		c.accountList = newAccountList(c.logger, c.config.Accounts, c.config.AccountsPath)
+		if c.config.AccountsPath != "" {
+			if err := c.accountList.load(); err != nil {
+				return err
+			}
+		}
+	}
+	if c.config.BorrowerCachePath != "" {
+		if c.borrowerStore, err = openBorrowerStore(c.config.BorrowerCachePath); err != nil {
+			return err
+		}
+	}
+	if c.pending, err = openPendingTxs(c.logger, c.config.PendingTxPath, c.config.PendingTxTTL); err != nil {
+		return err
+	}
+	if err := c.setupRoles(); err != nil {
+		return err
+	}
+	// Standby instances reconcile once they take over, as the primary
+	// may still be sending
+	if !c.roles.standby() {
+		if c.pendingResumed, err = c.pending.reconcile(ctx, client, ledger); err != nil {
+			return err
+		}
+	}
+	c.cooldowns = newCooldowns(c.logger, c.blockTime)
+	c.queue.cooldowns = c.cooldowns
+	c.alerts = newAlerts(c.logger, c.config.AlertsPath, c.config.AlertRenotifyInterval, c.config.AlertResolveMargin)
+	c.alerts.fiat, c.alerts.presenter = c.fiat, c.presenter
+	if err := c.alerts.load(); err != nil {
+		return err
+	}
+	c.queue.alerts = c.alerts
+	if c.config.JournalPath != "" {
+		if c.journal, err = OpenJournal(c.logger, c.config.JournalPath, c.config.JournalMaxSize); err != nil {
+			return err
+		}
+		c.queue.journal = c.journal
+		if c.simulation != nil {
+			c.simulation.journal = c.journal
+		}
+	}
+	if c.config.NATSURL != "" {
+		if c.publisher, err = newPublisher(c.logger, c.config.NATSURL, c.config.NATSSubject, c.config.NATSAckSubject, c.config.NATSBuffer, c.config.NATSRedelivery, c.config.NATSHandoff); err != nil {
+			return err
+		}
+		c.queue.publisher = c.publisher
+	}
+	if err := c.coordinate(); err != nil {
+		return err
+	}
+
+	flashLiquidity, err := newFlashLiquiditySource(c.flashLiquidityName, client, *c.aavePoolAddress)
+	if err != nil {
+		return fmt.Errorf("cannot instantiate flash liquidity source: %w", err)
+	}
+	c.flashLiquidity = flashLiquidity
+	if flashLiquidity != nil {
+		c.logger.Info("Flash liquidity source: "+flashLiquidity.Name(), F("source", flashLiquidity.Name()))
+	}
+
+	// Instantiate call batcher
+	if batcher == nil {
+		c.bisector = newBisector(c.logger, func(ctx context.Context) error {
+			return c.budget.wait(ctx, c.readEndpoint)
+		}, c.blockTime)
+		batcher, err = newCallBatcher(ctx, c.logger, client, c.rpcClient, c.readPool, *c.multicallAddress, c.batchSize, c.bisector)
+		if err != nil {
+			return err
+		}
+	}
+	c.Batcher = batcher
+	c.bus = newEventBus(c.logger, client, newLogBackfill(c.logger, client, c.logLimiter, c.borrowerScanBlockRange, blockLogRetries), c.blockTime)
+
+	return nil
+}
+
+func newConnection(cfg *Config, opts []Option) *Connection {
+	c := &Connection{
+		config:                 cfg,
+		logger:                 NewStdLogger(),
+		borrowerCacheInterval:  cfg.BorrowerCacheInterval,
+		blockTime:              cfg.BlockTime,
+		nativeSymbol:           cfg.NativeSymbol,
+		multicallAddress:       cfg.MulticallAddress,
+		batchSize:              cfg.BatchSize,
+		adapterName:            cfg.ProtocolAdapter,
+		venusLiquidatorAddress: cfg.VenusLiquidatorAddress,
+		flashLiquidityName:     cfg.FlashLiquiditySource,
+		aavePoolAddress:        cfg.AavePoolAddress,
+		cometAccounts:          cfg.CometAccounts,
+		cometBuyCollateral:     cfg.CometBuyCollateral,
+		borrowerScanStartBlock: cfg.BorrowerScanStartBlock,
+		borrowerScanBlockRange: cfg.BorrowerScanBlockRange,
+		shard:                  cfg.Shard,
+	}
+	if c.batchSize == 0 {
+		c.batchSize = defaultBatchSize
+	}
+	if c.borrowerScanBlockRange == 0 {
+		c.borrowerScanBlockRange = defaultBorrowerScanBlockRange
+	}
+	var host string
+	if u, err := url.Parse(cfg.NodeAPIURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	c.logLimiter = newLogLimiter(host, cfg.LogLimits)
+	c.readEndpoint = host
+	if u, err := url.Parse(cfg.ReadNodeAPIURL); err == nil && cfg.ReadPoolSize > 0 && cfg.ReadNodeAPIURL != "" {
+		c.readEndpoint = strings.ToLower(u.Hostname())
+	}
+	c.budget = cfg.Budget
+	if c.budget == nil && cfg.BudgetLimits.limited() {
+		c.budget = NewBudget(cfg.BudgetLimits)
+	}
+	c.logLimiter.budget = c.budget
+	c.strategy = cfg.Strategy
+	if c.strategy == nil {
+		c.strategy = NewDefaultStrategy()
+	}
+	maxCandidates := int64(cfg.MaxCandidatesPerBlock)
+	c.maxCandidates = &maxCandidates
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.annotations = newAnnotations(c.logger)
+	c.presenter = newPresenter(cfg.ExplorerURL, c.annotations)
+	c.logger = &presentingLogger{logger: c.logger, presenter: c.presenter}
+	if c.shard.sharded() {
+		c.logger = &labelLogger{logger: c.logger, key: "shard", value: c.shard.String()}
+	}
+	if cfg.Chain != "" {
+		c.logger = &labelLogger{logger: c.logger, key: "chain", value: cfg.Chain}
+	}
+	c.queue = NewExecutionQueue(c.logger, cfg.ExecutionWorkers, cfg.ExecutionQueueSize)
+	c.queue.budget, c.queue.chain = c.budget, cfg.Chain
+	c.readiness = newReadiness(c.logger)
+	c.gasCap = newGasCap(c.logger, cfg.MaxGasPrice, cfg.MaxFeePerGas)
+	return c
+}
+
+// transactor sets up the transact options of the private key, or the
+// keyless ones of the simulated wallet with RoleSimulation, which also
+// needs every component submitting transactions unset.
+func (c *Connection) transactor(chainID *big.Int) error {
+	if c.config.Role == RoleSimulation {
+		switch {
+		case c.config.PrivateKey != "":
+			return fmt.Errorf("%w: ROLE %s holds no key; unset PRIVATE_KEY", ErrInvalidConfig, RoleSimulation)
+		case c.config.SimulationAddress == (common.Address{}):
+			return fmt.Errorf("%w: ROLE %s needs SIMULATION_ADDRESS", ErrInvalidConfig, RoleSimulation)
+		case c.config.Executor != nil || c.config.ExecuteLiquidations:
+			return fmt.Errorf("%w: ROLE %s cannot execute liquidations", ErrInvalidConfig, RoleSimulation)
+		case c.config.NATSURL != "":
+			return fmt.Errorf("%w: ROLE %s cannot hand candidates off to NATS_URL", ErrInvalidConfig, RoleSimulation)
+		case c.config.Heartbeat != nil || c.config.HeartbeatURL != "":
+			return fmt.Errorf("%w: ROLE %s cannot beat the heartbeat of a primary", ErrInvalidConfig, RoleSimulation)
+		}
+		c.TxOpts = keylessTransactOpts(c.config.SimulationAddress)
+		c.simulation = newSimulation(c.logger, nil)
+		c.presenter.label(c.config.SimulationAddress, ownLabel)
+		c.logger.Info(fmt.Sprintf("Simulating liquidations of %s without submitting them", c.presenter.Link(c.config.SimulationAddress)), F("address", c.config.SimulationAddress))
+		return nil
+	}
+
+	privateKey, err := crypto.HexToECDSA(c.config.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("cannot load private key: %w", err)
+	}
+	publicKey, ok := privateKey.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("cannot cast public key to ECDSA")
+	}
+	address := crypto.PubkeyToAddress(*publicKey)
+	c.presenter.label(address, ownLabel)
+	c.logger.Info(fmt.Sprintf("Liquidatoor address: %s", c.presenter.Link(address)), F("address", address))
+
+	txOpts, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	if err != nil {
+		return fmt.Errorf("cannot create authorized transactor: %w", err)
+	}
+	c.TxOpts = txOpts
+	return nil
+}
+
+// coordinate sets up the lock executions are coordinated with, if
+// any.
+func (c *Connection) coordinate() error {
+	lock := c.config.Lock
+	if lock == nil && c.config.CoordinationURL != "" {
+		var err error
+		if lock, err = NewRedisLock(c.config.CoordinationURL); err != nil {
+			return err
+		}
+	}
+	if lock == nil {
+		return nil
+	}
+	ttl := c.config.LockTTL
+	if ttl == 0 {
+		ttl = defaultLockTTL
+	}
+	if ttl < minLockBlocks*c.blockTime {
+		return fmt.Errorf("%w: COORDINATION_LOCK_TTL of %v is shorter than %d blocks of %v", ErrInvalidConfig, ttl, minLockBlocks, c.blockTime)
+	}
+	c.queue.coordinator = newCoordinator(c.logger, lock, ttl, fmt.Sprintf("liquidatoor:%v", c.chainID))
+	c.logger.Info(fmt.Sprintf("Coordinating executions with redundant instances, locking them for %v", ttl), F("chain", c.chainID), F("ttl", ttl))
+	return nil
+}
+
+// setupRoles sets up the heartbeat and the role of the instance, if
+// any.
+func (c *Connection) setupRoles() error {
+	role := c.config.Role
+	switch role {
+	case "":
+		role = RolePrimary
+	case RolePrimary, RoleStandby:
+	case RoleSimulation:
+		// Submits nothing to stand by or take over
+		return nil
+	default:
+		return fmt.Errorf("%w: ROLE needs to be %s, %s or %s, got %q", ErrInvalidConfig, RolePrimary, RoleStandby, RoleSimulation, role)
+	}
+	heartbeat := c.config.Heartbeat
+	if heartbeat == nil && c.config.HeartbeatURL != "" {
+		key := fmt.Sprintf("liquidatoor:%v:heartbeat", c.chainID)
+		if c.shard.sharded() {
+			key += ":" + c.shard.String()
+		}
+		var err error
+		if heartbeat, err = NewHeartbeat(c.config.HeartbeatURL, key); err != nil {
+			return err
+		}
+	}
+	if heartbeat == nil {
+		if role == RoleStandby {
+			return fmt.Errorf("%w: ROLE %s needs HEARTBEAT_URL", ErrInvalidConfig, role)
+		}
+		return nil
+	}
+
+	var err error
+	c.roles, err = newRoles(c.logger, heartbeat, role, c.config.HeartbeatInterval, c.config.HeartbeatStaleness, c.config.RolePath, c.Ready, func(ctx context.Context) error {
+		if err := c.pending.load(); err != nil {
+			return err
+		}
+		txs, err := c.pending.reconcile(ctx, c.client, c.ledger)
+		if err != nil {
+			return fmt.Errorf("cannot reconcile pending transactions: %w", err)
+		}
+		c.pending.resume(ctx, c.client, c.ledger, txs, c.blockTime)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.queue.roles = c.roles
+	if role == RoleStandby {
+		c.logger.Info(fmt.Sprintf("Standing by, taking over once the heartbeat of the primary is stale for %v", c.roles.staleness), F("role", role))
+	} else {
+		c.logger.Info(fmt.Sprintf("Primary, beating the heartbeat every %v", c.roles.interval), F("role", role))
+	}
+	return nil
+}
+
+// applyPreset fills in every setting that is not explicitly
+// configured from the preset of the connected chain.
+func (c *Connection) applyPreset() {
+	preset, ok := presetFor(c.chainID.Uint64())
+	if !ok {
+		c.logger.Warn(fmt.Sprintf("No preset for chain %v, using defaults", c.chainID), F("chain", c.chainID))
+	}
+	c.preset = preset
+
+	if c.blockTime == 0 {
+		c.blockTime = preset.BlockTime
+	}
+	if c.multicallAddress == nil {
+		c.multicallAddress = &preset.MulticallAddress
+	}
+	if c.nativeSymbol == "" {
+		c.nativeSymbol = preset.NativeSymbol
+	}
+	if c.adapterName == "" {
+		c.adapterName = preset.ProtocolAdapter
+	}
+	switch c.flashLiquidityName {
+	case "":
+		c.flashLiquidityName = preset.FlashLiquiditySource
+	case "none":
+		c.flashLiquidityName = ""
+	}
+	if c.aavePoolAddress == nil {
+		c.aavePoolAddress = &preset.AavePoolAddress
+	}
+}
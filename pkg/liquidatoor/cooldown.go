@@ -0,0 +1,209 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// FailureClass groups the failures of executions by how long they are
+// expected to last.
+type FailureClass string
+
+const (
+	// Someone else, or a redundant instance, liquidated the account
+	// first
+	FailureRace FailureClass = "race"
+	// Seizing was briefly paused
+	FailurePaused FailureClass = "paused"
+	// The liquidation cannot succeed as planned, eg., it reverts when
+	// simulated
+	FailureStructural FailureClass = "structural"
+	// Anything else, eg., a node error
+	FailureTransient FailureClass = "transient"
+)
+
+// First and longest cooldown of each class, in blocks
+var cooldownBlocks = map[FailureClass][2]uint64{
+	FailureRace:       {1, 8},
+	FailurePaused:     {4, 64},
+	FailureTransient:  {2, 32},
+	FailureStructural: {16, 512},
+}
+
+// ClassifyFailure returns the class of an execution failure. Failures
+// that are not specific to the account, eg., the gas price cap, have
+// no class and no cooldown.
+func ClassifyFailure(err error) (FailureClass, bool) {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, ErrGasPriceCap), errors.Is(err, ErrStandby), errors.Is(err, ErrHandledExternally):
+		return "", false
+	case errors.Is(err, ErrCompeting), errors.Is(err, ErrHeldByPeer), errors.Is(err, ErrTxReverted):
+		return FailureRace, true
+	case errors.Is(err, ErrMarketPaused):
+		return FailurePaused, true
+	case errors.Is(err, ErrSimulationReverted), errors.Is(err, ErrNotWhitelisted), errors.Is(err, ErrInsufficientInventory), isRevert(err):
+		return FailureStructural, true
+	default:
+		return FailureTransient, true
+	}
+}
+
+// Cooldown holds off the liquidation of an account after failed
+// executions.
+type Cooldown struct {
+	Pool    common.Address `json:"pool"`
+	Account common.Address `json:"account"`
+	Class   FailureClass   `json:"class"`
+	// In a row, doubling the cooldown each time
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until"`
+	// Last failure
+	Err string `json:"error"`
+}
+
+type cooldownKey struct {
+	jobKey
+	class FailureClass
+}
+
+// Cooldowns backs off the accounts whose executions failed,
+// exponentially and up to a cap per failure class, so failures are not
+// repeated every block. A success clears the cooldowns of the account.
+// It is safe for concurrent use.
+type Cooldowns struct {
+	logger Logger
+	// Multiplies cooldownBlocks
+	blockTime time.Duration
+
+	lock      sync.Mutex
+	cooldowns map[cooldownKey]*Cooldown
+}
+
+func newCooldowns(logger Logger, blockTime time.Duration) *Cooldowns {
+	return &Cooldowns{logger: logger, blockTime: blockTime, cooldowns: make(map[cooldownKey]*Cooldown)}
+}
+
+// record updates the cooldowns of an account with the result of its
+// execution.
+func (c *Cooldowns) record(pool, account common.Address, err error, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for k, cooldown := range c.cooldowns {
+		if now.After(cooldown.Until.Add(time.Duration(cooldownBlocks[k.class][1]) * c.blockTime)) {
+			delete(c.cooldowns, k)
+		}
+	}
+	key := jobKey{pool: pool, account: account}
+	if err == nil {
+		for k := range c.cooldowns {
+			if k.jobKey == key {
+				delete(c.cooldowns, k)
+			}
+		}
+		return
+	}
+	class, ok := ClassifyFailure(err)
+	if !ok {
+		return
+	}
+
+	blocks := cooldownBlocks[class]
+	// Failures long after the last cooldown were pruned and start over
+	cooldown, ok := c.cooldowns[cooldownKey{jobKey: key, class: class}]
+	if !ok {
+		cooldown = &Cooldown{Pool: pool, Account: account, Class: class}
+		c.cooldowns[cooldownKey{jobKey: key, class: class}] = cooldown
+	}
+	cooldown.Failures++
+	wait := blocks[1]
+	if cooldown.Failures <= 32 && blocks[0]<<(cooldown.Failures-1) < wait {
+		wait = blocks[0] << (cooldown.Failures - 1)
+	}
+	duration := time.Duration(wait) * c.blockTime
+	cooldown.Until = now.Add(duration)
+	cooldown.Err = err.Error()
+	c.logger.Info(fmt.Sprintf("Cooling down account %s for %v after %d %s failures", account, duration, cooldown.Failures, class),
+		F("pool", pool), F("account", account), F("class", class), F("failures", cooldown.Failures), F("until", cooldown.Until))
+}
+
+// active returns the cooldown of an account that lasts the longest, if
+// any is active.
+func (c *Cooldowns) active(pool, account common.Address, now time.Time) *Cooldown {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var longest *Cooldown
+	for k, cooldown := range c.cooldowns {
+		if k.jobKey != (jobKey{pool: pool, account: account}) || !now.Before(cooldown.Until) {
+			continue
+		}
+		if longest == nil || cooldown.Until.After(longest.Until) {
+			longest = cooldown
+		}
+	}
+	if longest == nil {
+		return nil
+	}
+	active := *longest
+	return &active
+}
+
+// List returns the active cooldowns, ending soonest first.
+func (c *Cooldowns) List() []Cooldown {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	list := make([]Cooldown, 0, len(c.cooldowns))
+	for _, cooldown := range c.cooldowns {
+		if now.Before(cooldown.Until) {
+			list = append(list, *cooldown)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Until.Before(list[j].Until)
+	})
+	return list
+}
+
+// Clear drops every cooldown of an account, in every pool, and returns
+// how many. The zero address clears every account.
+func (c *Cooldowns) Clear(account common.Address) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cleared := 0
+	for k := range c.cooldowns {
+		if account == (common.Address{}) || k.account == account {
+			delete(c.cooldowns, k)
+			cleared++
+		}
+	}
+	if cleared > 0 {
+		c.logger.Info(fmt.Sprintf("Cleared %d cooldowns of account %s", cleared, account), F("account", account), F("cleared", cleared))
+	}
+	return cleared
+}
+
+// check returns ErrCoolingDown if the account is cooling down.
+func (c *Cooldowns) check(pool, account common.Address) error {
+	if c == nil {
+		return nil
+	}
+	cooldown := c.active(pool, account, time.Now())
+	if cooldown == nil {
+		return nil
+	}
+	return fmt.Errorf("%w after %d %s failures until %s: %s", ErrCoolingDown, cooldown.Failures, cooldown.Class,
+		cooldown.Until.Format(time.RFC3339), cooldown.Err)
+}
@@ -0,0 +1,357 @@
+package liquidatoor
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultLockTTL = 2 * time.Minute
+	// Block times a lock is held for at least, as executions wait for
+	// their transactions to be mined
+	minLockBlocks = 10
+	// Wait for the coordination backend at most before executing
+	// uncoordinated
+	lockTimeout = 2 * time.Second
+)
+
+// Lock coordinates executions across redundant instances, so only one
+// of them submits liquidations of an account at a time.
+type Lock interface {
+	// Acquire acquires key for ttl and reports whether it did, or
+	// false if another instance holds it.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Renew extends key to expire in ttl and reports whether it did,
+	// or false if it expired or another instance holds it.
+	Renew(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release releases key if this instance holds it, leaving it to
+	// another instance otherwise.
+	Release(ctx context.Context, key string) error
+}
+
+// coordinator acquires the lock of every execution, executing
+// uncoordinated while the lock backend fails rather than halting.
+type coordinator struct {
+	logger Logger
+	lock   Lock
+	ttl    time.Duration
+	// Namespaces the keys, eg., by chain
+	prefix string
+
+	// Set while the backend fails
+	failing int32
+}
+
+func newCoordinator(logger Logger, lock Lock, ttl time.Duration, prefix string) *coordinator {
+	if ttl == 0 {
+		ttl = defaultLockTTL
+	}
+	return &coordinator{logger: logger, lock: lock, ttl: ttl, prefix: prefix}
+}
+
+// acquire acquires the lock of the executions of the account of c,
+// whatever the block, failing with ErrHeldByPeer if another instance
+// holds it, and renews it until unlock is called. unlock releases it,
+// unless a transaction was sent, so the peers skip the account on
+// later blocks until it expires, while the state they read may not
+// reflect the transaction yet. A lock this instance kept is acquired
+// again. A nil coordinator always acquires it.
+func (co *coordinator) acquire(ctx context.Context, c Candidate) (unlock func(sent bool), err error) {
+	unlock = func(bool) {}
+	if co == nil {
+		return unlock, nil
+	}
+	key := fmt.Sprintf("%s:%s:%s", co.prefix, c.Pool, c.Account)
+
+	acquireCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+	acquired, err := co.lock.Acquire(acquireCtx, key, co.ttl)
+	if err == nil && !acquired {
+		// Kept since a transaction of this instance was sent
+		acquired, err = co.lock.Renew(acquireCtx, key, co.ttl)
+	}
+	if err != nil {
+		if atomic.CompareAndSwapInt32(&co.failing, 0, 1) {
+			co.logger.Error(fmt.Sprintf("Coordination backend failed, executing uncoordinated: %v", err), F("pool", c.Pool), F("account", c.Account), F("err", err))
+		}
+		return unlock, nil
+	}
+	if atomic.CompareAndSwapInt32(&co.failing, 1, 0) {
+		co.logger.Info("Coordination backend recovered", F("pool", c.Pool), F("account", c.Account))
+	}
+	if !acquired {
+		return unlock, fmt.Errorf("%w: %s", ErrHeldByPeer, key)
+	}
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		co.renew(stop, key, c)
+	}()
+	return func(sent bool) {
+		close(stop)
+		<-stopped
+		if sent {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+		defer cancel()
+		if err := co.lock.Release(ctx, key); err != nil {
+			co.logger.Warn(fmt.Sprintf("Cannot release lock %s: %v", key, err), F("pool", c.Pool), F("account", c.Account), F("err", err))
+		}
+	}, nil
+}
+
+// renew renews the lock of key, held for the execution of c, every
+// half of its TTL until stop is closed or it is lost.
+func (co *coordinator) renew(stop <-chan struct{}, key string, c Candidate) {
+	ticker := time.NewTicker(co.ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+		renewed, err := co.lock.Renew(ctx, key, co.ttl)
+		cancel()
+		switch {
+		case err != nil:
+			// Retried on the next tick, before the lock expires
+			co.logger.Warn(fmt.Sprintf("Cannot renew lock %s: %v", key, err), F("pool", c.Pool), F("account", c.Account), F("err", err))
+		case !renewed:
+			co.logger.Error(fmt.Sprintf("Lost lock %s during execution, a peer may execute it too", key), F("pool", c.Pool), F("account", c.Account))
+			return
+		}
+	}
+}
+
+// claim acquires name, namespaced like the locks of executions, for
+// ttl and reports whether it did.
+func (co *coordinator) claim(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+	return co.lock.Acquire(ctx, co.prefix+":"+name, ttl)
+}
+
+// redisLock is a Lock over Redis keys set with NX and a TTL.
+type redisLock struct {
+	client *redisClient
+	// Value of the keys set, to tell instances apart
+	owner string
+}
+
+// NewRedisLock returns a Lock over the Redis server of rawURL, eg.,
+// redis://:password@localhost:6379/0, or rediss:// over TLS.
+func NewRedisLock(rawURL string) (Lock, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := instanceID()
+	if err != nil {
+		return nil, err
+	}
+	return &redisLock{client: client, owner: owner}, nil
+}
+
+func (l *redisLock) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	reply, err := l.client.command(ctx, "SET", key, l.owner, "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply == "OK", nil
+}
+
+const (
+	// Scripts changing a key only while it holds the owner, atomically
+	redisRenewScript   = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`
+	redisReleaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+)
+
+func (l *redisLock) Renew(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	reply, err := l.client.command(ctx, "EVAL", redisRenewScript, "1", key, l.owner, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply == "1", nil
+}
+
+func (l *redisLock) Release(ctx context.Context, key string) error {
+	_, err := l.client.command(ctx, "EVAL", redisReleaseScript, "1", key, l.owner)
+	return err
+}
+
+// instanceID returns an identifier of this instance, unique across
+// hosts and restarts.
+func instanceID() (string, error) {
+	host, _ := os.Hostname()
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("cannot generate instance id: %w", err)
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(id)), nil
+}
+
+// redisClient sends commands to a Redis server over a single
+// connection, dialed again on failure.
+type redisClient struct {
+	address  string
+	tls      bool
+	password string
+	username string
+	db       int
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newRedisClient returns a client of the Redis server of rawURL,
+// without dialing it yet.
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid Redis URL: %v", ErrInvalidConfig, err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("%w: Redis URL needs to be a redis:// or rediss:// URL, got %q", ErrInvalidConfig, u.Scheme)
+	}
+	c := &redisClient{address: u.Host, tls: u.Scheme == "rediss"}
+	if u.Port() == "" {
+		c.address = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("%w: invalid Redis database %q", ErrInvalidConfig, db)
+		}
+	}
+	return c, nil
+}
+
+// command sends a command and returns its reply, empty for a nil
+// reply.
+func (c *redisClient) command(ctx context.Context, args ...string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reply, err := c.do(ctx, args...)
+	if err != nil {
+		// Dialed again on the next call
+		c.close()
+	}
+	return reply, err
+}
+
+// do sends a command and returns its reply, dialing first if needed.
+// Callers hold the lock.
+func (c *redisClient) do(ctx context.Context, args ...string) (string, error) {
+	if c.conn == nil {
+		if err := c.dial(ctx); err != nil {
+			return "", err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Time{})
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		return "", fmt.Errorf("cannot send %s: %w", args[0], err)
+	}
+	return c.read()
+}
+
+// read reads a simple string, error, integer or bulk string reply.
+func (c *redisClient) read() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("cannot read reply: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid reply %q", line)
+		}
+		if size < 0 {
+			return "", nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return "", fmt.Errorf("cannot read reply: %w", err)
+		}
+		return string(data[:size]), nil
+	default:
+		return "", fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+func (c *redisClient) dial(ctx context.Context) error {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return fmt.Errorf("cannot connect to Redis: %w", err)
+	}
+	if c.tls {
+		host, _, _ := net.SplitHostPort(c.address)
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	c.conn, c.reader = conn, bufio.NewReader(conn)
+
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := c.do(ctx, args...); err != nil {
+			c.close()
+			return fmt.Errorf("cannot authenticate to Redis: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := c.do(ctx, "SELECT", strconv.Itoa(c.db)); err != nil {
+			c.close()
+			return fmt.Errorf("cannot select Redis database: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *redisClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.reader = nil, nil
+	}
+}
@@ -0,0 +1,497 @@
+package liquidatoor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// redisKey is a key of the fake Redis server.
+type redisKey struct {
+	value   string
+	expires time.Time
+}
+
+// fakeRedis is a Redis server speaking enough of RESP for redisLock:
+// AUTH and SELECT, SET with NX and PX, GET, and EVAL of the renew and
+// release scripts. Keys expire on a clock advanced by the tests.
+type fakeRedis struct {
+	t        *testing.T
+	listener net.Listener
+	// Accepted password, if any
+	password string
+
+	lock     sync.Mutex
+	keys     map[string]redisKey
+	now      time.Time
+	conns    []net.Conn
+	commands []string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{t: t, listener: listener, keys: make(map[string]redisKey), now: time.Unix(1700000000, 0)}
+	t.Cleanup(func() {
+		listener.Close()
+		s.kill()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.conns = append(s.conns, conn)
+			s.lock.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) url() string {
+	return "redis://" + s.listener.Addr().String()
+}
+
+// newLock returns a lock of the server, owned by owner.
+func (s *fakeRedis) newLock(owner string) *redisLock {
+	s.t.Helper()
+	client, err := newRedisClient(s.url())
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return &redisLock{client: client, owner: owner}
+}
+
+// advance advances the clock keys expire on by d.
+func (s *fakeRedis) advance(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.now = s.now.Add(d)
+}
+
+// owner returns the value of key, or empty if it is not set.
+func (s *fakeRedis) owner(key string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.get(key)
+}
+
+// kill drops every connection.
+func (s *fakeRedis) kill() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// received returns the names of the commands received.
+func (s *fakeRedis) received() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// get returns the value of key, unless expired. Callers hold the
+// lock.
+func (s *fakeRedis) get(key string) string {
+	k, ok := s.keys[key]
+	if !ok {
+		return ""
+	}
+	if !k.expires.IsZero() && !s.now.Before(k.expires) {
+		delete(s.keys, key)
+		return ""
+	}
+	return k.value
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := false
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.commands = append(s.commands, strings.ToUpper(args[0]))
+		if s.password != "" && !authenticated && strings.ToUpper(args[0]) != "AUTH" {
+			s.lock.Unlock()
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		reply := s.execute(args)
+		if strings.ToUpper(args[0]) == "AUTH" && reply == "+OK\r\n" {
+			authenticated = true
+		}
+		s.lock.Unlock()
+		io.WriteString(conn, reply)
+	}
+}
+
+// execute executes a command and returns its encoded reply. Callers
+// hold the lock.
+func (s *fakeRedis) execute(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if args[len(args)-1] != s.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if value := s.get(args[1]); value != "" {
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+		}
+		return "$-1\r\n"
+	case "SET":
+		key, value := args[1], args[2]
+		var nx bool
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				i++
+				ms, err := strconv.ParseInt(args[i], 10, 64)
+				if err != nil || ms <= 0 {
+					return "-ERR invalid expire time in 'set' command\r\n"
+				}
+				ttl = time.Duration(ms) * time.Millisecond
+			}
+		}
+		if nx && s.get(key) != "" {
+			return "$-1\r\n"
+		}
+		k := redisKey{value: value}
+		if ttl > 0 {
+			k.expires = s.now.Add(ttl)
+		}
+		s.keys[key] = k
+		return "+OK\r\n"
+	case "EVAL":
+		script, key, owner := args[1], args[3], args[4]
+		if s.get(key) != owner {
+			return ":0\r\n"
+		}
+		switch script {
+		case redisRenewScript:
+			ms, err := strconv.ParseInt(args[5], 10, 64)
+			if err != nil {
+				return "-ERR value is not an integer or out of range\r\n"
+			}
+			k := s.keys[key]
+			k.expires = s.now.Add(time.Duration(ms) * time.Millisecond)
+			s.keys[key] = k
+		case redisReleaseScript:
+			delete(s.keys, key)
+		default:
+			return "-NOSCRIPT unknown script\r\n"
+		}
+		return ":1\r\n"
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+// readRedisCommand reads a command sent as an array of bulk strings.
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected command %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestRedisLock(t *testing.T) {
+	server := newFakeRedis(t)
+	ctx := context.Background()
+	a, b := server.newLock("a"), server.newLock("b")
+	check := func(name string, expected bool, got bool, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got != expected {
+			t.Fatalf("%s: expected %v, got %v", name, expected, got)
+		}
+	}
+
+	// Only one instance acquires a key
+	acquired, err := a.Acquire(ctx, "key", time.Minute)
+	check("a acquires", true, acquired, err)
+	acquired, err = b.Acquire(ctx, "key", time.Minute)
+	check("b acquires a held key", false, acquired, err)
+	acquired, err = a.Acquire(ctx, "key", time.Minute)
+	check("a acquires its own key again", false, acquired, err)
+
+	// Only the owner renews or releases it
+	renewed, err := b.Renew(ctx, "key", time.Hour)
+	check("b renews the key of a", false, renewed, err)
+	if err := b.Release(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if owner := server.owner("key"); owner != "a" {
+		t.Fatalf("expected the key of a left alone, held by %q", owner)
+	}
+
+	// Renewing extends the key from now
+	server.advance(50 * time.Second)
+	renewed, err = a.Renew(ctx, "key", time.Minute)
+	check("a renews", true, renewed, err)
+	server.advance(50 * time.Second)
+	acquired, err = b.Acquire(ctx, "key", time.Minute)
+	check("b acquires a renewed key", false, acquired, err)
+
+	// An expired key is acquired by the next instance, and cannot be
+	// renewed or released by its former owner
+	server.advance(10 * time.Second)
+	acquired, err = b.Acquire(ctx, "key", time.Minute)
+	check("b acquires an expired key", true, acquired, err)
+	renewed, err = a.Renew(ctx, "key", time.Minute)
+	check("a renews a key it lost", false, renewed, err)
+	if err := a.Release(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if owner := server.owner("key"); owner != "b" {
+		t.Fatalf("expected the key of b left alone, held by %q", owner)
+	}
+
+	// A released key is acquired right away
+	if err := b.Release(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	acquired, err = a.Acquire(ctx, "key", time.Minute)
+	check("a acquires a released key", true, acquired, err)
+	renewed, err = a.Renew(ctx, "missing", time.Minute)
+	check("a renews a key never set", false, renewed, err)
+}
+
+func TestRedisClient(t *testing.T) {
+	server := newFakeRedis(t)
+	server.password = "secret"
+	client, err := newRedisClient("redis://:secret@" + server.listener.Addr().String() + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := &redisLock{client: client, owner: "a"}
+	ctx := context.Background()
+	if acquired, err := lock.Acquire(ctx, "key", time.Minute); err != nil || !acquired {
+		t.Fatalf("expected the key acquired, got %v, %v", acquired, err)
+	}
+	if commands := strings.Join(server.received(), " "); commands != "AUTH SELECT SET" {
+		t.Fatalf("expected to authenticate and select the database first, got %s", commands)
+	}
+
+	// Dialed again after the connection is lost
+	server.kill()
+	if _, err := lock.Acquire(ctx, "other", time.Minute); err == nil {
+		t.Fatal("expected the command on the lost connection to fail")
+	}
+	if acquired, err := lock.Acquire(ctx, "other", time.Minute); err != nil || !acquired {
+		t.Fatalf("expected the key acquired on a new connection, got %v, %v", acquired, err)
+	}
+
+	// Errors of the server are returned
+	wrong, err := newRedisClient("redis://:wrong@" + server.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.command(ctx, "GET", "key"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("expected the password refused, got %v", err)
+	}
+
+	for _, rawURL := range []string{"http://localhost", "redis://localhost/db", "://"} {
+		if _, err := newRedisClient(rawURL); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("%s: expected ErrInvalidConfig, got %v", rawURL, err)
+		}
+	}
+	if client, err := newRedisClient("rediss://localhost"); err != nil || client.address != "localhost:6379" || !client.tls {
+		t.Fatalf("expected the default port over TLS, got %+v, %v", client, err)
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	server := newFakeRedis(t)
+	ctx := context.Background()
+	candidate := Candidate{Pool: common.HexToAddress("0x1"), Account: common.HexToAddress("0x2"), Block: big.NewInt(100)}
+	key := fmt.Sprintf("liquidatoor:1:%s:%s", candidate.Pool, candidate.Account)
+	a := newCoordinator(quietLogger(), server.newLock("a"), time.Minute, "liquidatoor:1")
+	b := newCoordinator(quietLogger(), server.newLock("b"), time.Minute, "liquidatoor:1")
+
+	unlock, err := a.acquire(ctx, candidate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.acquire(ctx, candidate); !errors.Is(err, ErrHeldByPeer) {
+		t.Fatalf("expected ErrHeldByPeer, got %v", err)
+	}
+	// Released for the peers when nothing was sent
+	unlock(false)
+	if owner := server.owner(key); owner != "" {
+		t.Fatalf("expected the lock released, held by %q", owner)
+	}
+
+	// Kept until it expires when a transaction was sent
+	unlock, err = b.acquire(ctx, candidate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock(true)
+	if _, err := a.acquire(ctx, candidate); !errors.Is(err, ErrHeldByPeer) {
+		t.Fatalf("expected the lock of a sent transaction kept, got %v", err)
+	}
+	// The instance that kept it acquires it again
+	unlock, err = b.acquire(ctx, candidate)
+	if err != nil {
+		t.Fatalf("expected the kept lock acquired again, got %v", err)
+	}
+	unlock(true)
+	server.advance(time.Minute)
+	unlock, err = a.acquire(ctx, candidate)
+	if err != nil {
+		t.Fatalf("expected the lock acquired once expired, got %v", err)
+	}
+	unlock(false)
+
+	// Executions are uncoordinated while the backend fails
+	down := newCoordinator(quietLogger(), &redisLock{client: &redisClient{address: "127.0.0.1:1"}, owner: "a"}, time.Minute, "liquidatoor:1")
+	unlock, err = down.acquire(ctx, candidate)
+	if err != nil {
+		t.Fatalf("expected to execute uncoordinated, got %v", err)
+	}
+	unlock(false)
+
+	var none *coordinator
+	unlock, err = none.acquire(ctx, candidate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock(true)
+}
+
+func TestCoordinatorAcrossBlocks(t *testing.T) {
+	server := newFakeRedis(t)
+	ctx := context.Background()
+	pool, account := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	a := newCoordinator(quietLogger(), server.newLock("a"), time.Minute, "liquidatoor:1")
+	b := newCoordinator(quietLogger(), server.newLock("b"), time.Minute, "liquidatoor:1")
+
+	// a sends the liquidation of the account in block 100
+	unlock, err := a.acquire(ctx, Candidate{Pool: pool, Account: account, Block: big.NewInt(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock(true)
+	// b still sees the account underwater in block 101
+	next := Candidate{Pool: pool, Account: account, Block: big.NewInt(101)}
+	if _, err := b.acquire(ctx, next); !errors.Is(err, ErrHeldByPeer) {
+		t.Fatalf("expected the lock of the sent transaction held in the next block, got %v", err)
+	}
+	// Other accounts are not locked
+	unlock, err = b.acquire(ctx, Candidate{Pool: pool, Account: common.HexToAddress("0x3"), Block: big.NewInt(101)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock(false)
+	server.advance(time.Minute)
+	unlock, err = b.acquire(ctx, next)
+	if err != nil {
+		t.Fatalf("expected the lock acquired once expired, got %v", err)
+	}
+	unlock(false)
+}
+
+func TestCoordinatorRenews(t *testing.T) {
+	server := newFakeRedis(t)
+	candidate := Candidate{Pool: common.HexToAddress("0x1"), Account: common.HexToAddress("0x2"), Block: big.NewInt(100)}
+	key := fmt.Sprintf("liquidatoor:1:%s:%s", candidate.Pool, candidate.Account)
+	a := newCoordinator(quietLogger(), server.newLock("a"), 100*time.Millisecond, "liquidatoor:1")
+	b := newCoordinator(quietLogger(), server.newLock("b"), 100*time.Millisecond, "liquidatoor:1")
+
+	unlock, err := a.acquire(context.Background(), candidate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Renewed every half of its TTL, from the clock of the server,
+	// while the execution lasts
+	for i := 0; i < 5; i++ {
+		renewed := renews(server)
+		server.advance(60 * time.Millisecond)
+		deadline := time.Now().Add(5 * time.Second)
+		for renews(server) == renewed {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected renewal %d", i+1)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if _, err := b.acquire(context.Background(), candidate); !errors.Is(err, ErrHeldByPeer) {
+			t.Fatalf("expected the renewed lock held, got %v", err)
+		}
+	}
+	unlock(true)
+	renewed := renews(server)
+	time.Sleep(200 * time.Millisecond)
+	if renews(server) != renewed {
+		t.Fatal("expected no renewal once unlocked")
+	}
+	server.advance(100 * time.Millisecond)
+	if owner := server.owner(key); owner != "" {
+		t.Fatalf("expected the lock expired, held by %q", owner)
+	}
+}
+
+// renews returns the number of renewals and releases received.
+func renews(server *fakeRedis) int {
+	n := 0
+	for _, command := range server.received() {
+		if command == "EVAL" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,352 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Events and the inputs naming the accounts whose liquidity they
+// change. Transfer names them in its indexed topics instead.
+var (
+	cTokenAccountEvents = map[string][]string{
+		"Borrow":          {"borrower"},
+		"RepayBorrow":     {"borrower"},
+		"LiquidateBorrow": {"borrower"},
+		"Mint":            {"minter"},
+		"Redeem":          {"redeemer"},
+		"Transfer":        nil,
+	}
+	comptrollerAccountEvents = map[string][]string{
+		"MarketEntered": {"account"},
+		"MarketExited":  {"account"},
+	}
+	// Comptroller events changing the liquidity of every account
+	comptrollerRescanEvents = []string{"NewPriceOracle", "MarketListed", "MarketUnlisted"}
+)
+
+// deltaTracker remembers the liquidity of every borrower so that only
+// accounts that may have changed since the previous block are checked
+// again: accounts with activity in any market of the pool and accounts
+// in markets whose price or collateral factor changed. Interest accrual
+// changes every borrow without naming accounts, so every borrower is
+// still checked every fullScanInterval blocks.
+type deltaTracker struct {
+	fullScanInterval uint64
+
+	primed       bool
+	lastBlock    uint64
+	lastFullScan uint64
+
+	// Price of every market, by market
+	prices map[common.Address]*big.Int
+	// Liquidity of every borrower at the last block, by account
+	results map[common.Address]accountLiquidity
+
+	// Blocks skipped in a row as nothing changed, at most
+	// forceScanInterval
+	forceScanInterval uint64
+	skipped           uint64
+
+	cTokenABI      *abi.ABI
+	comptrollerABI *abi.ABI
+}
+
+func newDeltaTracker(fullScanInterval, forceScanInterval uint64, comptrollerABI *abi.ABI) (*deltaTracker, error) {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	return &deltaTracker{
+		fullScanInterval:  fullScanInterval,
+		forceScanInterval: forceScanInterval,
+		prices:            make(map[common.Address]*big.Int),
+		results:           make(map[common.Address]accountLiquidity),
+		cTokenABI:         cTokenABI,
+		comptrollerABI:    comptrollerABI,
+	}, nil
+}
+
+// liquidityScan is the liquidity of every borrower at a block.
+type liquidityScan struct {
+	// Nil for accounts whose liquidity cannot be fetched
+	results []accountLiquidity
+	// Every borrower was queried
+	full bool
+	// Borrowers queried
+	checked int
+}
+
+// accountLiquidities returns the liquidity of every borrower, querying
+// only the ones that may have changed when tracking deltas.
+func (l *Liquidatoor) accountLiquidities(ctx context.Context, block *big.Int, start *blockStart) (*liquidityScan, error) {
+	borrowers := start.borrowers
+	stale, full := l.staleBorrowers(ctx, block, start)
+
+	calls := l.liquidityCalls[:0]
+	if cap(calls) < len(borrowers) {
+		calls = make([]abis.MulticallCall, 0, len(borrowers))
+	}
+	for i, borrower := range borrowers {
+		if !stale[i] {
+			continue
+		}
+		calls = append(calls, abis.MulticallCall{
+			Target:   l.comptrollerAddress,
+			CallData: borrower.liquidityCallData,
+		})
+	}
+	l.liquidityCalls = calls
+	if l.delta != nil {
+		l.logger.Info(fmt.Sprintf("Checking %d of %d borrowers", len(calls), len(borrowers)),
+			F("pool", l.comptrollerAddress), F("block", block), F("checked", len(calls)), F("full", full))
+	}
+
+	resp, err := l.Batcher.Aggregate(&bind.CallOpts{Context: ctx}, calls)
+	if err != nil {
+		return nil, fmt.Errorf("failed batch request: %v", err)
+	}
+
+	results := make([]accountLiquidity, len(borrowers))
+	j := 0
+	for i, borrower := range borrowers {
+		if !stale[i] {
+			results[i] = l.delta.results[borrower.Address]
+			continue
+		}
+		if result := resp[j]; result.Success {
+			liquidity := accountLiquidity(result.ReturnData)
+			if err := liquidity.validate(); err != nil {
+				return nil, err
+			}
+			results[i] = liquidity
+		}
+		j++
+	}
+
+	if l.delta != nil && block != nil {
+		l.delta.update(block.Uint64(), full, borrowers, results, start.markets, start.prices)
+	}
+	return &liquidityScan{results: results, full: full, checked: len(calls)}, nil
+}
+
+// skipBlock reports whether the rest of the processing of a block can
+// be skipped, and why, as no account was checked again since nothing
+// affecting one changed. Blocks are skipped at most forceScanInterval
+// times in a row.
+func (d *deltaTracker) skipBlock(scan *liquidityScan) (bool, string) {
+	if d == nil || scan.full || scan.checked > 0 || d.skipped >= d.forceScanInterval {
+		d.resetSkipped()
+		return false, ""
+	}
+	d.skipped++
+	return true, fmt.Sprintf("no account activity or price changes affecting borrowers (%d of %d blocks in a row)", d.skipped, d.forceScanInterval)
+}
+
+func (d *deltaTracker) resetSkipped() {
+	if d != nil {
+		d.skipped = 0
+	}
+}
+
+// staleBorrowers reports which borrowers need to be checked at block,
+// and whether all of them do.
+func (l *Liquidatoor) staleBorrowers(ctx context.Context, block *big.Int, start *blockStart) ([]bool, bool) {
+	borrowers := start.borrowers
+	stale := make([]bool, len(borrowers))
+	full := l.delta == nil || block == nil
+	if !full {
+		d := l.delta
+		number := block.Uint64()
+		// Reorgs and skipped ranges are not tracked
+		full = !d.primed || number <= d.lastBlock || number-d.lastFullScan >= d.fullScanInterval
+		if number <= d.lastBlock {
+			// The events of the blocks replaced are unknown, so scans stay
+			// full until one succeeds
+			d.primed = false
+		}
+	}
+
+	var changed map[common.Address]bool
+	var active map[common.Address]bool
+	if l.delta != nil {
+		// Prices of an earlier block cannot tell what changed
+		if start.prices == nil {
+			full = true
+		} else {
+			changed = l.changedMarkets(start.markets, start.prices)
+		}
+	}
+	if !full {
+		var rescan bool
+		var err error
+		active, rescan, err = l.activeAccounts(ctx, l.delta.lastBlock+1, block.Uint64(), changed)
+		if err != nil {
+			l.logger.Warn(fmt.Sprintf("Failed to get account activity, checking every borrower: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		}
+		full = err != nil || rescan
+	}
+
+	for i, borrower := range borrowers {
+		if full || active[borrower.Address] || l.delta.results[borrower.Address] == nil {
+			stale[i] = true
+			continue
+		}
+		for _, asset := range borrower.Assets {
+			if changed[asset] {
+				stale[i] = true
+				break
+			}
+		}
+	}
+	return stale, full
+}
+
+// changedMarkets returns the markets whose price changed since the
+// last block processed. Prices are only remembered once the block is,
+// so changes survive blocks whose checks fail.
+func (l *Liquidatoor) changedMarkets(markets []common.Address, prices []*Price) map[common.Address]bool {
+	changed := make(map[common.Address]bool)
+	for i, market := range markets {
+		var price *big.Int
+		if prices[i] != nil {
+			price = prices[i].Mantissa
+		}
+		previous, ok := l.delta.prices[market]
+		if !ok || (price == nil) != (previous == nil) || (price != nil && !Equal(price, previous)) {
+			changed[market] = true
+		}
+	}
+	return changed
+}
+
+// activeAccounts returns the accounts named by pool events between the
+// provided blocks, adding markets whose collateral factor changed to
+// changed. It requests a rescan on events affecting every account.
+func (l *Liquidatoor) activeAccounts(ctx context.Context, from, to uint64, changed map[common.Address]bool) (map[common.Address]bool, bool, error) {
+	d := l.delta
+	addresses := []common.Address{l.comptrollerAddress}
+	for address := range l.LendMarkets {
+		addresses = append(addresses, common.HexToAddress(address))
+	}
+	var logs []types.Log
+	err := l.logs.filter(ctx, "pool events", ethereum.FilterQuery{Addresses: addresses}, from, to, func(chunk []types.Log, _ uint64) error {
+		logs = append(logs, chunk...)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	active := make(map[common.Address]bool)
+	for _, log := range logs {
+		if len(log.Topics) == 0 {
+			continue
+		}
+		if log.Address == l.comptrollerAddress {
+			event, err := d.comptrollerABI.EventByID(log.Topics[0])
+			if err != nil {
+				continue
+			}
+			for _, name := range comptrollerRescanEvents {
+				if event.Name == name {
+					return nil, true, nil
+				}
+			}
+			if event.Name == "NewCollateralFactor" {
+				market, err := eventAddress(event, log, "cToken")
+				if err != nil {
+					return nil, false, err
+				}
+				changed[market] = true
+				continue
+			}
+			if err := addEventAccounts(active, event, log, comptrollerAccountEvents[event.Name]); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+
+		event, err := d.cTokenABI.EventByID(log.Topics[0])
+		if err != nil {
+			continue
+		}
+		fields, ok := cTokenAccountEvents[event.Name]
+		if !ok {
+			continue
+		}
+		if event.Name == "Transfer" {
+			if len(log.Topics) < 3 {
+				return nil, false, fmt.Errorf("malformed Transfer event in tx %s", log.TxHash)
+			}
+			active[common.BytesToAddress(log.Topics[1].Bytes())] = true
+			active[common.BytesToAddress(log.Topics[2].Bytes())] = true
+			continue
+		}
+		if err := addEventAccounts(active, event, log, fields); err != nil {
+			return nil, false, err
+		}
+	}
+	return active, false, nil
+}
+
+func addEventAccounts(active map[common.Address]bool, event *abi.Event, log types.Log, fields []string) error {
+	for _, field := range fields {
+		account, err := eventAddress(event, log, field)
+		if err != nil {
+			return err
+		}
+		active[account] = true
+	}
+	return nil
+}
+
+// eventAddress returns a non-indexed address input of an event.
+func eventAddress(event *abi.Event, log types.Log, field string) (common.Address, error) {
+	values := make(map[string]interface{})
+	if err := event.Inputs.UnpackIntoMap(values, log.Data); err != nil {
+		return common.Address{}, fmt.Errorf("cannot unpack %s event in tx %s: %w", event.Name, log.TxHash, err)
+	}
+	address, ok := values[field].(common.Address)
+	if !ok {
+		return common.Address{}, fmt.Errorf("malformed %s event in tx %s", event.Name, log.TxHash)
+	}
+	return address, nil
+}
+
+// update remembers the results and the prices, if known, of a
+// processed block. Failed results are forgotten so the accounts are
+// checked again. Accounts that are no longer borrowers are dropped on
+// full scans.
+func (d *deltaTracker) update(block uint64, full bool, borrowers []Borrower, results []accountLiquidity, markets []common.Address, prices []*Price) {
+	if full {
+		d.results = make(map[common.Address]accountLiquidity, len(borrowers))
+		d.lastFullScan = block
+	}
+	for i, borrower := range borrowers {
+		if results[i] == nil {
+			delete(d.results, borrower.Address)
+			continue
+		}
+		d.results[borrower.Address] = results[i]
+	}
+	if prices != nil {
+		for i, market := range markets {
+			var price *big.Int
+			if prices[i] != nil {
+				price = prices[i].Mantissa
+			}
+			d.prices[market] = price
+		}
+	}
+	d.lastBlock = block
+	d.primed = true
+}
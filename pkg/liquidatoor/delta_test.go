@@ -0,0 +1,448 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/fakes"
+)
+
+// truthBatcher answers liquidity calls with the current liquidity of
+// each account, recording the accounts queried. Whole batches, or
+// single calls, fail when told to.
+type truthBatcher struct {
+	liquidity map[common.Address]*big.Int
+	queried   []common.Address
+	// Fails the next batch
+	fail bool
+	// Fails the calls of these accounts
+	failing map[common.Address]bool
+}
+
+func (b *truthBatcher) Aggregate(_ *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error) {
+	b.queried = b.queried[:0]
+	if b.fail {
+		b.fail = false
+		return nil, errors.New("batch failed")
+	}
+	results := make([]CallResult, len(calls))
+	for i, call := range calls {
+		account := common.BytesToAddress(call.CallData[4:36])
+		b.queried = append(b.queried, account)
+		if b.failing[account] {
+			continue
+		}
+		results[i] = CallResult{Success: true, ReturnData: liquidityOf(0, b.liquidity[account], new(big.Int))}
+	}
+	return results, nil
+}
+
+// deltaPool is a pool of borrowers in several markets, tracked across
+// blocks by a deltaTracker, along with the actual liquidity of every
+// borrower.
+type deltaPool struct {
+	t           *testing.T
+	r           *rand.Rand
+	l           *Liquidatoor
+	batcher     *truthBatcher
+	chain       *fakes.Logs
+	cTokenABI   *abi.ABI
+	markets     []common.Address
+	prices      []*Price
+	borrowers   []Borrower
+	logsInBlock map[uint64]uint
+}
+
+func newDeltaPool(t *testing.T, seed int64, fullScanInterval, forceScanInterval uint64) *deltaPool {
+	t.Helper()
+	comptrollerABI, err := abis.ComptrollerMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := newDeltaTracker(fullScanInterval, forceScanInterval, comptrollerABI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &deltaPool{
+		t:           t,
+		r:           rand.New(rand.NewSource(seed)),
+		batcher:     &truthBatcher{liquidity: make(map[common.Address]*big.Int), failing: make(map[common.Address]bool)},
+		chain:       fakes.NewLogs(0),
+		cTokenABI:   cTokenABI,
+		logsInBlock: make(map[uint64]uint),
+	}
+	logger := &stdLogger{logger: log.New(io.Discard, "", 0)}
+	p.l = &Liquidatoor{
+		logger:             logger,
+		comptrollerAddress: common.HexToAddress("0xc0"),
+		comptrollerABI:     comptrollerABI,
+		Batcher:            p.batcher,
+		LendMarkets:        make(map[string]CToken),
+		delta:              delta,
+		logs:               newLogBackfill(logger, logsBackend{logs: p.chain}, nil, 1000, 0),
+	}
+	for i := 0; i < 4; i++ {
+		market := common.BigToAddress(big.NewInt(int64(0xa0 + i)))
+		p.markets = append(p.markets, market)
+		p.prices = append(p.prices, &Price{Mantissa: big.NewInt(1e18)})
+		p.l.LendMarkets[market.String()] = nil
+	}
+	method := comptrollerABI.Methods["getAccountLiquidity"]
+	for i := 0; i < 20; i++ {
+		account := common.BigToAddress(big.NewInt(int64(0x1000 + i)))
+		packed, err := method.Inputs.Pack(account)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var assets []common.Address
+		for _, market := range p.markets {
+			if p.r.Intn(3) == 0 {
+				assets = append(assets, market)
+			}
+		}
+		p.borrowers = append(p.borrowers, Borrower{Address: account, Assets: assets, liquidityCallData: append(method.ID[:4:4], packed...)})
+		p.change(account)
+	}
+	return p
+}
+
+// change changes the liquidity of an account.
+func (p *deltaPool) change(account common.Address) {
+	p.batcher.liquidity[account] = new(big.Int).Rand(p.r, big.NewInt(1e18))
+}
+
+// changeMarket changes the liquidity of every account in market.
+func (p *deltaPool) changeMarket(market common.Address) {
+	for _, borrower := range p.borrowers {
+		for _, asset := range borrower.Assets {
+			if asset == market {
+				p.change(borrower.Address)
+			}
+		}
+	}
+}
+
+// emit emits event of contract at block with the addresses named in
+// inputs, and any other input set to one.
+func (p *deltaPool) emit(contract common.Address, contractABI *abi.ABI, name string, block uint64, inputs map[string]common.Address) {
+	p.t.Helper()
+	event := contractABI.Events[name]
+	log := types.Log{
+		Address:     contract,
+		Topics:      []common.Hash{event.ID},
+		BlockNumber: block,
+		BlockHash:   common.BigToHash(new(big.Int).SetUint64(block)),
+		Index:       p.logsInBlock[block],
+	}
+	p.logsInBlock[block]++
+	var values []interface{}
+	for _, input := range event.Inputs {
+		var value interface{} = big.NewInt(1)
+		if input.Type.T == abi.AddressTy {
+			value = inputs[input.Name]
+		}
+		if input.Indexed {
+			log.Topics = append(log.Topics, common.BytesToHash(value.(common.Address).Bytes()))
+		} else {
+			values = append(values, value)
+		}
+	}
+	data, err := event.Inputs.NonIndexed().Pack(values...)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	log.Data = data
+	p.chain.Emit(log)
+}
+
+// process checks the liquidity of the borrowers at block, returning
+// the scan unless the batch failed.
+func (p *deltaPool) process(block uint64) *liquidityScan {
+	p.t.Helper()
+	p.chain.Mine(block)
+	prices := make([]*Price, len(p.prices))
+	copy(prices, p.prices)
+	start := &blockStart{borrowers: p.borrowers, markets: p.markets, prices: prices}
+	scan, err := p.l.accountLiquidities(context.Background(), new(big.Int).SetUint64(block), start)
+	if err != nil {
+		return nil
+	}
+	return scan
+}
+
+// checkFresh checks every result of scan is the actual liquidity of
+// its borrower, or unknown if its call failed.
+func (p *deltaPool) checkFresh(block uint64, scan *liquidityScan) {
+	p.t.Helper()
+	for i, borrower := range p.borrowers {
+		result := scan.results[i]
+		if result == nil {
+			if !p.batcher.failing[borrower.Address] {
+				p.t.Fatalf("block %d: expected the liquidity of %s, got none", block, borrower.Address)
+			}
+			continue
+		}
+		if actual := p.batcher.liquidity[borrower.Address]; result.liquidity().Cmp(actual) != 0 {
+			p.t.Fatalf("block %d: account %s is stale, expected liquidity %v, got %v", block, borrower.Address, actual, result.liquidity())
+		}
+	}
+}
+
+// TestDeltaNeverStale changes prices, collateral factors and accounts
+// at random, through every event the tracker follows, and checks no
+// account is left with the liquidity of an earlier block. Batches and
+// calls fail, blocks are skipped and reprocessed along the way.
+func TestDeltaNeverStale(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		p := newDeltaPool(t, seed, 1000, 1000)
+		comptroller := p.l.comptrollerAddress
+		block := uint64(1)
+		for i := 0; i < 100; i++ {
+			// Skipped blocks have their changes caught on the next one
+			if p.r.Intn(10) == 0 {
+				block += uint64(1 + p.r.Intn(3))
+			}
+			for n := p.r.Intn(4); n > 0; n-- {
+				borrower := p.borrowers[p.r.Intn(len(p.borrowers))]
+				account, other := borrower.Address, p.borrowers[p.r.Intn(len(p.borrowers))].Address
+				market := p.markets[p.r.Intn(len(p.markets))]
+				switch p.r.Intn(11) {
+				case 0:
+					m := p.r.Intn(len(p.markets))
+					p.prices[m] = &Price{Mantissa: big.NewInt(p.r.Int63())}
+					p.changeMarket(p.markets[m])
+				case 1:
+					// Unpriced, then priced again
+					if p.prices[0] != nil {
+						p.prices[0] = nil
+						p.changeMarket(p.markets[0])
+					}
+				case 2:
+					p.emit(market, p.cTokenABI, "Borrow", block, map[string]common.Address{"borrower": account})
+					p.change(account)
+				case 3:
+					p.emit(market, p.cTokenABI, "RepayBorrow", block, map[string]common.Address{"payer": other, "borrower": account})
+					p.change(account)
+				case 4:
+					p.emit(market, p.cTokenABI, "LiquidateBorrow", block, map[string]common.Address{"liquidator": other, "borrower": account, "cTokenCollateral": market})
+					p.change(account)
+				case 5:
+					p.emit(market, p.cTokenABI, "Mint", block, map[string]common.Address{"minter": account})
+					p.change(account)
+				case 6:
+					p.emit(market, p.cTokenABI, "Redeem", block, map[string]common.Address{"redeemer": account})
+					p.change(account)
+				case 7:
+					p.emit(market, p.cTokenABI, "Transfer", block, map[string]common.Address{"from": account, "to": other})
+					p.change(account)
+					p.change(other)
+				case 8:
+					p.emit(comptroller, p.l.comptrollerABI, "MarketEntered", block, map[string]common.Address{"cToken": market, "account": account})
+					p.change(account)
+				case 9:
+					p.emit(comptroller, p.l.comptrollerABI, "NewCollateralFactor", block, map[string]common.Address{"cToken": market})
+					p.changeMarket(market)
+				case 10:
+					p.emit(comptroller, p.l.comptrollerABI, "NewPriceOracle", block, nil)
+					for _, borrower := range p.borrowers {
+						p.change(borrower.Address)
+					}
+				}
+			}
+			// Restore the price of an unpriced market every other block
+			if p.prices[0] == nil && p.r.Intn(2) == 0 {
+				p.prices[0] = &Price{Mantissa: big.NewInt(p.r.Int63())}
+				p.changeMarket(p.markets[0])
+			}
+
+			p.batcher.fail = p.r.Intn(15) == 0
+			for account := range p.batcher.failing {
+				delete(p.batcher.failing, account)
+			}
+			if p.r.Intn(5) == 0 {
+				p.batcher.failing[p.borrowers[p.r.Intn(len(p.borrowers))].Address] = true
+			}
+			if scan := p.process(block); scan != nil {
+				p.checkFresh(block, scan)
+			}
+			// Reorgs process a block again
+			if p.r.Intn(20) != 0 {
+				block++
+			}
+		}
+	}
+}
+
+// TestDeltaChecksOnlyAffected checks the accounts checked again are
+// exactly the ones a change affects.
+func TestDeltaChecksOnlyAffected(t *testing.T) {
+	p := newDeltaPool(t, 1, 1000, 1000)
+	p.borrowers[0].Assets = []common.Address{p.markets[0]}
+	p.borrowers[1].Assets = []common.Address{p.markets[1]}
+	p.borrowers[2].Assets = []common.Address{p.markets[0], p.markets[2]}
+	for i := 3; i < len(p.borrowers); i++ {
+		p.borrowers[i].Assets = []common.Address{p.markets[3]}
+	}
+	accounts := func(indexes ...int) []common.Address {
+		addresses := make([]common.Address, len(indexes))
+		for i, index := range indexes {
+			addresses[i] = p.borrowers[index].Address
+		}
+		return addresses
+	}
+	expectChecked := func(block uint64, expected []common.Address) {
+		t.Helper()
+		scan := p.process(block)
+		if scan == nil {
+			t.Fatalf("block %d: expected the batch to succeed", block)
+		}
+		p.checkFresh(block, scan)
+		checked := make(map[common.Address]bool)
+		for _, account := range p.batcher.queried {
+			checked[account] = true
+		}
+		if len(checked) != len(expected) || scan.checked != len(expected) {
+			t.Fatalf("block %d: expected %d accounts checked, got %v", block, len(expected), p.batcher.queried)
+		}
+		for _, account := range expected {
+			if !checked[account] {
+				t.Fatalf("block %d: expected %s checked, got %v", block, account, p.batcher.queried)
+			}
+		}
+	}
+
+	if scan := p.process(1); scan == nil || !scan.full {
+		t.Fatal("expected the first block scanned in full")
+	}
+	expectChecked(2, nil)
+
+	// A price change checks the accounts in the market
+	p.prices[0] = &Price{Mantissa: big.NewInt(2e18)}
+	p.changeMarket(p.markets[0])
+	expectChecked(3, accounts(0, 2))
+
+	// So does a collateral factor change
+	p.emit(p.l.comptrollerAddress, p.l.comptrollerABI, "NewCollateralFactor", 4, map[string]common.Address{"cToken": p.markets[2]})
+	p.changeMarket(p.markets[2])
+	expectChecked(4, accounts(2))
+
+	// Activity checks the accounts named, whatever the market
+	p.emit(p.markets[3], p.cTokenABI, "Transfer", 5, map[string]common.Address{"from": p.borrowers[1].Address, "to": p.borrowers[4].Address})
+	p.change(p.borrowers[1].Address)
+	p.change(p.borrowers[4].Address)
+	expectChecked(5, accounts(1, 4))
+
+	// A price change during a failed batch is still checked after
+	p.prices[1] = &Price{Mantissa: big.NewInt(3e18)}
+	p.changeMarket(p.markets[1])
+	p.batcher.fail = true
+	if scan := p.process(6); scan != nil {
+		t.Fatal("expected the batch to fail")
+	}
+	expectChecked(7, accounts(1))
+
+	// So is activity in a block that failed
+	p.emit(p.markets[0], p.cTokenABI, "Borrow", 8, map[string]common.Address{"borrower": p.borrowers[5].Address})
+	p.change(p.borrowers[5].Address)
+	p.batcher.fail = true
+	p.process(8)
+	expectChecked(9, accounts(5))
+
+	// Accounts whose call failed are checked again on the next block
+	p.emit(p.markets[0], p.cTokenABI, "Mint", 10, map[string]common.Address{"minter": p.borrowers[6].Address})
+	p.change(p.borrowers[6].Address)
+	p.batcher.failing[p.borrowers[6].Address] = true
+	expectChecked(10, accounts(6))
+	delete(p.batcher.failing, p.borrowers[6].Address)
+	expectChecked(11, accounts(6))
+}
+
+func TestDeltaScansInFull(t *testing.T) {
+	p := newDeltaPool(t, 1, 10, 1000)
+	p.process(1)
+	for block := uint64(2); block <= 10; block++ {
+		if scan := p.process(block); scan.full {
+			t.Fatalf("expected block %d checked incrementally", block)
+		}
+	}
+	// Every fullScanInterval blocks, for interest accrual
+	if scan := p.process(11); !scan.full {
+		t.Fatal("expected block 11 scanned in full")
+	}
+	// Reorgs and rescan events
+	if scan := p.process(11); !scan.full {
+		t.Fatal("expected a reorged block scanned in full")
+	}
+	// Until a scan after the reorg succeeds
+	p.batcher.fail = true
+	p.process(11)
+	if scan := p.process(12); !scan.full {
+		t.Fatal("expected the block after a failed reorged block scanned in full")
+	}
+	p.emit(p.l.comptrollerAddress, p.l.comptrollerABI, "MarketListed", 13, map[string]common.Address{"cToken": p.markets[0]})
+	if scan := p.process(13); !scan.full {
+		t.Fatal("expected a block listing a market scanned in full")
+	}
+	// Blocks without the prices of the block
+	scan, err := p.l.accountLiquidities(context.Background(), big.NewInt(14), &blockStart{borrowers: p.borrowers, markets: p.markets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scan.full {
+		t.Fatal("expected a block without prices scanned in full")
+	}
+}
+
+func TestSkipBlock(t *testing.T) {
+	d := &deltaTracker{forceScanInterval: 3}
+	quiet := &liquidityScan{}
+	for i := 1; i <= 3; i++ {
+		if skip, reason := d.skipBlock(quiet); !skip || reason == "" {
+			t.Fatalf("expected quiet block %d skipped with a reason", i)
+		}
+	}
+	// Never more than forceScanInterval in a row
+	if skip, _ := d.skipBlock(quiet); skip {
+		t.Fatal("expected the fourth quiet block in a row processed")
+	}
+	if skip, _ := d.skipBlock(quiet); !skip {
+		t.Fatal("expected the count of blocks skipped reset")
+	}
+
+	// Blocks checking any account, or every one, are processed and
+	// reset the count
+	for _, scan := range []*liquidityScan{{checked: 1}, {full: true}} {
+		d.skipped = 2
+		if skip, _ := d.skipBlock(scan); skip {
+			t.Fatalf("expected a block checking %d accounts processed", scan.checked)
+		}
+		if d.skipped != 0 {
+			t.Fatalf("expected the count reset, got %d", d.skipped)
+		}
+	}
+
+	// Without delta tracking, or a force scan interval, nothing is
+	// skipped
+	var untracked *deltaTracker
+	if skip, _ := untracked.skipBlock(quiet); skip {
+		t.Fatal("expected no block skipped without delta tracking")
+	}
+	if skip, _ := (&deltaTracker{}).skipBlock(quiet); skip {
+		t.Fatal("expected no block skipped without a force scan interval")
+	}
+}
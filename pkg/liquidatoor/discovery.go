@@ -0,0 +1,209 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+var expScale = big.NewInt(1e18)
+
+// PoolDiscovery periodically enumerates the pools registered in the
+// Fuse pool directory and starts or stops monitoring them through the
+// pool manager depending on whether they match the configured criteria.
+type PoolDiscovery struct {
+	conn    *Connection
+	manager *PoolManager
+
+	directory *abis.FusePoolDirectory
+	interval  time.Duration
+
+	// Minimum total borrows of a pool, denominated in the pool
+	// oracle's unit of account and scaled by 1e18
+	minTotalBorrows *big.Int
+	// Allow-listed pool admins; empty allows any admin
+	admins map[common.Address]bool
+
+	// Pools started by discovery; statically configured pools
+	// are never stopped by it
+	discovered map[common.Address]bool
+}
+
+func NewPoolDiscovery(conn *Connection, manager *PoolManager) (*PoolDiscovery, error) {
+	cfg := conn.config.Discovery
+	if cfg == nil {
+		return nil, fmt.Errorf("%w: POOL_DIRECTORY_ADDRESS cannot be empty", ErrInvalidConfig)
+	}
+
+	d := &PoolDiscovery{
+		conn:            conn,
+		manager:         manager,
+		interval:        cfg.Interval,
+		minTotalBorrows: cfg.MinTotalBorrows,
+		admins:          make(map[common.Address]bool),
+		discovered:      make(map[common.Address]bool),
+	}
+	for _, admin := range cfg.Admins {
+		d.admins[admin] = true
+	}
+
+	directory, err := abis.NewFusePoolDirectory(cfg.PoolDirectory, conn.client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate pool directory: %w", err)
+	}
+	d.directory = directory
+
+	return d, nil
+}
+
+// Init discovers pools periodically until ctx is cancelled.
+func (d *PoolDiscovery) Init(ctx context.Context) {
+	if err := d.run(ctx); err != nil {
+		d.conn.logger.Error(fmt.Sprintf("Failed to discover pools: %v", err), F("err", err))
+	}
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := d.run(ctx); err != nil {
+				d.conn.logger.Error(fmt.Sprintf("Failed to discover pools: %v", err), F("err", err))
+			}
+		}
+	}
+}
+
+func (d *PoolDiscovery) run(ctx context.Context) error {
+	d.conn.logger.Info("Initiating pool discovery...")
+
+	pools, err := d.directory.GetAllPools(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("cannot get all pools: %w", err)
+	}
+
+	registered := make(map[common.Address]bool)
+	for _, pool := range pools {
+		registered[pool.Comptroller] = true
+
+		eligible, err := d.eligible(ctx, pool.Comptroller)
+		if err != nil {
+			// Leave the pool as is until we can evaluate it again
+			d.conn.logger.Warn(fmt.Sprintf("Failed to evaluate pool %s (%s): %v", pool.Comptroller, pool.Name, err), F("pool", pool.Comptroller), F("err", err))
+			continue
+		}
+
+		switch {
+		case eligible && !d.manager.Has(pool.Comptroller):
+			if err := d.manager.Add(ctx, pool.Comptroller); err != nil {
+				d.conn.logger.Error(fmt.Sprintf("Failed to start monitoring pool %s (%s): %v", pool.Comptroller, pool.Name, err), F("pool", pool.Comptroller), F("err", err))
+				continue
+			}
+			d.discovered[pool.Comptroller] = true
+
+		case !eligible && d.discovered[pool.Comptroller]:
+			d.conn.logger.Info(fmt.Sprintf("Pool %s (%s) no longer matches discovery criteria", pool.Comptroller, pool.Name), F("pool", pool.Comptroller))
+			d.manager.Remove(pool.Comptroller)
+			delete(d.discovered, pool.Comptroller)
+		}
+	}
+
+	for comptroller := range d.discovered {
+		if !registered[comptroller] {
+			d.conn.logger.Info(fmt.Sprintf("Pool %s disappeared from the pool directory", comptroller), F("pool", comptroller))
+			d.manager.Remove(comptroller)
+			delete(d.discovered, comptroller)
+		}
+	}
+
+	d.conn.logger.Info(fmt.Sprintf("Pool discovery complete; monitoring %d pools.", len(d.manager.Pools())), F("pools", len(d.manager.Pools())))
+	return nil
+}
+
+func (d *PoolDiscovery) eligible(ctx context.Context, comptrollerAddress common.Address) (bool, error) {
+	comptroller, err := abis.NewComptroller(comptrollerAddress, d.conn.client)
+	if err != nil {
+		return false, fmt.Errorf("cannot instantiate comptroller: %w", err)
+	}
+
+	if len(d.admins) > 0 {
+		admin, err := comptroller.Admin(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return false, fmt.Errorf("cannot get admin: %w", err)
+		}
+		if !d.admins[admin] {
+			return false, nil
+		}
+	}
+
+	if d.minTotalBorrows == nil {
+		return true, nil
+	}
+	totalBorrows, err := d.totalBorrowsValue(ctx, comptroller)
+	if err != nil {
+		return false, err
+	}
+	return GTE(totalBorrows, d.minTotalBorrows), nil
+}
+
+// totalBorrowsValue returns the value of all borrows in the pool,
+// denominated in the pool oracle's unit of account.
+func (d *PoolDiscovery) totalBorrowsValue(ctx context.Context, comptroller Comptroller) (*big.Int, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	markets, err := comptroller.GetAllMarkets(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get markets: %w", err)
+	}
+	if len(markets) == 0 {
+		return new(big.Int), nil
+	}
+
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+
+	calls := []abis.MulticallCall{}
+	assets := make([]Asset, 0, len(markets))
+	totalBorrowsMethod := cTokenABI.Methods["totalBorrows"]
+	for _, market := range markets {
+		calls = append(calls, abis.MulticallCall{
+			Target:   market,
+			CallData: totalBorrowsMethod.ID,
+		})
+		// The oracle does not need the underlying
+		assets = append(assets, Asset{Market: market})
+	}
+
+	resp, err := d.conn.Batcher.Aggregate(opts, calls)
+	if err != nil {
+		return nil, fmt.Errorf("failed batch request: %v", err)
+	}
+	prices, err := pricesOf(ctx, d.conn.logger, NewOraclePriceSource(comptroller, d.conn.Batcher), assets, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	total := new(big.Int)
+	for i, result := range resp {
+		if !result.Success || prices[i] == nil {
+			return nil, fmt.Errorf("cannot get total borrows value of market %s", calls[i].Target)
+		}
+		var borrows *big.Int
+		if err := cTokenABI.UnpackIntoInterface(&borrows, totalBorrowsMethod.Name, result.ReturnData); err != nil {
+			return nil, fmt.Errorf("cannot unpack total borrows output: %v", err)
+		}
+		total.Add(total, prices[i].Value(borrows))
+	}
+
+	return total, nil
+}
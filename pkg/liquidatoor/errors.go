@@ -0,0 +1,192 @@
+package liquidatoor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"runtime/debug"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrInvalidConfig = errors.New("invalid config")
+	// Amounts of different decimals cannot be combined
+	ErrDecimalsMismatch = errors.New("decimals mismatch")
+	// The borrower cache has not been populated yet
+	ErrCacheNotPrimed = errors.New("borrower cache not primed")
+	// The estimated net profit of a liquidation is not positive
+	ErrUnprofitable = errors.New("unprofitable")
+	// Seizing collateral is paused in the pool or market
+	ErrMarketPaused = errors.New("market paused")
+	// The wallet cannot fund the repay amount
+	ErrInsufficientInventory = errors.New("insufficient inventory")
+	// The liquidation transaction reverts when simulated
+	ErrSimulationReverted = errors.New("simulation reverted")
+	// Prices are missing or cannot be trusted
+	ErrStaleData = errors.New("stale data")
+	// The pool enforces a supplier whitelist we are not in
+	ErrNotWhitelisted = errors.New("not whitelisted")
+	// The admin or implementation of the comptroller changed and the
+	// change was not acknowledged yet
+	ErrGovernanceChanged = errors.New("governance changed")
+	// Including the liquidation needs a gas price over the cap
+	ErrGasPriceCap = errors.New("gas price over cap")
+	// Swapping the seized collateral would exceed its slippage limit
+	ErrSlippage = errors.New("slippage over limit")
+	// A liquidation transaction was mined but reverted, usually as
+	// someone else liquidated the account first
+	ErrTxReverted = errors.New("transaction reverted")
+	// Executions of the account failed recently; see Cooldowns
+	ErrCoolingDown = errors.New("cooling down")
+	// Someone else's liquidation of the account is pending
+	ErrCompeting = errors.New("competing liquidation pending")
+	// Our own liquidation of the account in the market is pending
+	ErrAwaitingOwnTx = errors.New("awaiting own pending tx")
+	// A redundant instance holds the lock of the liquidation; see Lock
+	ErrHeldByPeer = errors.New("held by peer")
+	// The instance stands by for the primary; see RoleStandby
+	ErrStandby = errors.New("standing by")
+	// The consumer of the published candidates handles the liquidation;
+	// see PublishedAck
+	ErrHandledExternally = errors.New("handled externally")
+	// An oracle price deviates from its reference price, so the
+	// liquidation is held until the next block
+	ErrPriceDeviation = errors.New("price deviation")
+	// The account ranked below the per-block candidate limit and is
+	// evaluated again in the next block
+	ErrDeferred = errors.New("deferred")
+	// The account is annotated to never be liquidated, or only alerted
+	// on
+	ErrAnnotated = errors.New("annotated")
+	// Processing the candidate panicked, eg., on a malformed record
+	ErrPanic = errors.New("panic")
+	// No collateral market of the account has the cash to redeem any
+	// seize, and the inventory cannot fund holding it; see Redemption
+	ErrIlliquidCollateral = errors.New("illiquid collateral")
+	// The collateral of the account is worth no more than dust, so
+	// its shortfall is bad debt; see classifyCandidate
+	ErrBadDebt = errors.New("bad debt")
+	// Replaying the plan on a fork realized less than planned; see
+	// ForkValidation
+	ErrForkMismatch = errors.New("fork mismatch")
+	// The account is in a single market, so there is no pair of
+	// markets to repay and seize; see SelectLiquidationPair
+	ErrSingleAsset = errors.New("single asset")
+
+	// No plan could be made for an account
+	errNoPlan = errors.New("no liquidation plan")
+)
+
+// LiquidationError is why an account was not, or could not be,
+// liquidated. Unknown fields are zero.
+type LiquidationError struct {
+	Block    *big.Int
+	Pool     common.Address
+	Borrower common.Address
+	Market   common.Address
+	Err      error
+}
+
+func (e *LiquidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pool %s", e.Pool)
+	if e.Borrower != (common.Address{}) {
+		fmt.Fprintf(&b, " borrower %s", e.Borrower)
+	}
+	if e.Market != (common.Address{}) {
+		fmt.Fprintf(&b, " market %s", e.Market)
+	}
+	if e.Block != nil {
+		fmt.Fprintf(&b, " block %v", e.Block)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *LiquidationError) Unwrap() error {
+	return e.Err
+}
+
+// DropReason classifies why a candidate was dropped for metrics and
+// logs. It is empty for a nil error.
+func DropReason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrCacheNotPrimed):
+		return "cache_not_primed"
+	case errors.Is(err, ErrUnprofitable):
+		return "unprofitable"
+	case errors.Is(err, ErrMarketPaused):
+		return "market_paused"
+	case errors.Is(err, ErrInsufficientInventory):
+		return "insufficient_inventory"
+	case errors.Is(err, ErrSimulationReverted):
+		return "simulation_reverted"
+	case errors.Is(err, ErrStaleData):
+		return "stale_data"
+	case errors.Is(err, ErrNotWhitelisted):
+		return "not_whitelisted"
+	case errors.Is(err, ErrGovernanceChanged):
+		return "governance_changed"
+	case errors.Is(err, ErrGasPriceCap):
+		return "gas_price_cap"
+	case errors.Is(err, ErrSlippage):
+		return "slippage"
+	case errors.Is(err, ErrTxReverted):
+		return "tx_reverted"
+	case errors.Is(err, ErrCoolingDown):
+		return "cooldown"
+	case errors.Is(err, ErrCompeting):
+		return "competing_tx"
+	case errors.Is(err, ErrAwaitingOwnTx):
+		return "own_tx_pending"
+	case errors.Is(err, ErrHeldByPeer):
+		return "held_by_peer"
+	case errors.Is(err, ErrStandby):
+		return "standby"
+	case errors.Is(err, ErrHandledExternally):
+		return "external"
+	case errors.Is(err, ErrPriceDeviation):
+		return "price_deviation"
+	case errors.Is(err, ErrDeferred):
+		return "deferred"
+	case errors.Is(err, ErrAnnotated):
+		return "annotated"
+	case errors.Is(err, ErrPanic):
+		return "panic"
+	case errors.Is(err, ErrIlliquidCollateral):
+		return "illiquid_collateral"
+	case errors.Is(err, ErrBadDebt):
+		return "bad_debt"
+	case errors.Is(err, ErrForkMismatch):
+		return "fork_mismatch"
+	case errors.Is(err, ErrSingleAsset):
+		return "single_asset"
+	case errors.Is(err, errNoPlan):
+		return "no_plan"
+	default:
+		return "error"
+	}
+}
+
+// isRevert reports whether a node error is an execution revert rather
+// than a failure to reach the node.
+func isRevert(err error) bool {
+	return strings.Contains(err.Error(), "execution reverted")
+}
+
+// recovered runs fn, turning a panic into an ErrPanic error logged
+// with its stack, so one malformed record cannot take down the check,
+// or the execution, of every other candidate.
+func recovered(logger Logger, fn func() error, fields ...Field) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrPanic, r)
+			logger.Error(fmt.Sprintf("Recovered from %v", err), append(fields, F("err", err), F("stack", string(debug.Stack())))...)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,114 @@
+package liquidatoor
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// dropReasons is the reason of every sentinel, by its name in
+// errors.go.
+var dropReasons = map[string]struct {
+	err    error
+	reason string
+}{
+	// Configuration and amount errors do not drop candidates
+	"ErrInvalidConfig":         {ErrInvalidConfig, "error"},
+	"ErrDecimalsMismatch":      {ErrDecimalsMismatch, "error"},
+	"ErrCacheNotPrimed":        {ErrCacheNotPrimed, "cache_not_primed"},
+	"ErrUnprofitable":          {ErrUnprofitable, "unprofitable"},
+	"ErrMarketPaused":          {ErrMarketPaused, "market_paused"},
+	"ErrInsufficientInventory": {ErrInsufficientInventory, "insufficient_inventory"},
+	"ErrSimulationReverted":    {ErrSimulationReverted, "simulation_reverted"},
+	"ErrStaleData":             {ErrStaleData, "stale_data"},
+	"ErrNotWhitelisted":        {ErrNotWhitelisted, "not_whitelisted"},
+	"ErrGovernanceChanged":     {ErrGovernanceChanged, "governance_changed"},
+	"ErrGasPriceCap":           {ErrGasPriceCap, "gas_price_cap"},
+	"ErrSlippage":              {ErrSlippage, "slippage"},
+	"ErrTxReverted":            {ErrTxReverted, "tx_reverted"},
+	"ErrCoolingDown":           {ErrCoolingDown, "cooldown"},
+	"ErrCompeting":             {ErrCompeting, "competing_tx"},
+	"ErrAwaitingOwnTx":         {ErrAwaitingOwnTx, "own_tx_pending"},
+	"ErrHeldByPeer":            {ErrHeldByPeer, "held_by_peer"},
+	"ErrStandby":               {ErrStandby, "standby"},
+	"ErrHandledExternally":     {ErrHandledExternally, "external"},
+	"ErrPriceDeviation":        {ErrPriceDeviation, "price_deviation"},
+	"ErrDeferred":              {ErrDeferred, "deferred"},
+	"ErrAnnotated":             {ErrAnnotated, "annotated"},
+	"ErrPanic":                 {ErrPanic, "panic"},
+	"ErrIlliquidCollateral":    {ErrIlliquidCollateral, "illiquid_collateral"},
+	"ErrBadDebt":               {ErrBadDebt, "bad_debt"},
+	"ErrForkMismatch":          {ErrForkMismatch, "fork_mismatch"},
+	"ErrSingleAsset":           {ErrSingleAsset, "single_asset"},
+	"errNoPlan":                {errNoPlan, "no_plan"},
+}
+
+// TestDropReasonCoversSentinels checks every sentinel declared in
+// errors.go is classified, so a new one cannot fall through to "error"
+// unnoticed.
+func TestDropReasonCoversSentinels(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	declared := 0
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				if !strings.HasPrefix(strings.ToLower(name.Name), "err") {
+					continue
+				}
+				declared++
+				if _, ok := dropReasons[name.Name]; !ok {
+					t.Errorf("expected the drop reason of %s tested", name.Name)
+				}
+			}
+		}
+	}
+	if declared != len(dropReasons) {
+		t.Errorf("expected %d sentinels declared, found %d", len(dropReasons), declared)
+	}
+}
+
+func TestDropReason(t *testing.T) {
+	seen := make(map[string]string)
+	for name, tc := range dropReasons {
+		wrapped := fmt.Errorf("cannot liquidate account: %w", tc.err)
+		for _, err := range []error{
+			tc.err,
+			wrapped,
+			&LiquidationError{Block: big.NewInt(1), Pool: common.HexToAddress("0xc0"), Err: wrapped},
+		} {
+			if reason := DropReason(err); reason != tc.reason {
+				t.Errorf("%s: expected %q for %q, got %q", name, tc.reason, err, reason)
+			}
+		}
+		// Metrics tell the reasons apart
+		if other, ok := seen[tc.reason]; ok && tc.reason != "error" {
+			t.Errorf("expected %s and %s to have different drop reasons, both are %q", name, other, tc.reason)
+		}
+		seen[tc.reason] = name
+	}
+
+	if reason := DropReason(nil); reason != "" {
+		t.Errorf("expected no reason for a nil error, got %q", reason)
+	}
+	if reason := DropReason(errors.New("execution reverted")); reason != "error" {
+		t.Errorf("expected %q for an unknown error, got %q", "error", reason)
+	}
+	// Sentinels only formatted into the message do not count
+	if reason := DropReason(fmt.Errorf("%w: %v", ErrSimulationReverted, ErrUnprofitable)); reason != "simulation_reverted" {
+		t.Errorf("expected only wrapped sentinels classified, got %q", reason)
+	}
+}
@@ -0,0 +1,221 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Logs buffered per subscriber by default, over which they are dropped
+const eventBufferSize = 256
+
+// eventBus follows the logs of every subscriber of a connection over a
+// single subscription, for the union of their queries, which handles
+// reconnects, backfills and reorgs for all of them. Each subscriber is
+// handed its logs in order from a bounded buffer, so a slow subscriber
+// drops its own logs rather than holding up the rest.
+type eventBus struct {
+	logger Logger
+	client Backend
+	logs   *logBackfill
+	retry  time.Duration
+
+	lock        sync.Mutex
+	subscribers map[*eventSubscriber]bool
+	// Followed while there are subscribers
+	watch  *logWatch
+	cancel context.CancelFunc
+}
+
+// eventSubscriber is a subscriber of the bus.
+type eventSubscriber struct {
+	name  string
+	query ethereum.FilterQuery
+	fn    func(types.Log)
+	// Reads what the logs update instead, if set
+	resync func()
+
+	logs chan types.Log
+	// Signals logs were dropped or the subscription failed
+	lost chan struct{}
+
+	delivered uint64
+	dropped   uint64
+}
+
+// EventSubscriptionStats are the logs handed to a subscriber of the
+// event bus, and the ones dropped while its buffer was full.
+type EventSubscriptionStats struct {
+	Name      string
+	Delivered uint64
+	Dropped   uint64
+}
+
+func newEventBus(logger Logger, client Backend, logs *logBackfill, retry time.Duration) *eventBus {
+	return &eventBus{logger: logger, client: client, logs: logs, retry: retry, subscribers: make(map[*eventSubscriber]bool)}
+}
+
+// subscribe calls fn with every log of the addresses and first topics
+// of query, in order, until ctx is cancelled, and again with Removed
+// set if a reorg removes it. resync, if set, is called instead of the
+// logs that were dropped or lost while the subscription was down, to
+// read what they update.
+func (b *eventBus) subscribe(ctx context.Context, name string, query ethereum.FilterQuery, fn func(types.Log), resync func()) {
+	s := &eventSubscriber{
+		name:   name,
+		query:  query,
+		fn:     fn,
+		resync: resync,
+		logs:   make(chan types.Log, eventBufferSize),
+		lost:   make(chan struct{}, 1),
+	}
+	b.lock.Lock()
+	b.subscribers[s] = true
+	b.follow()
+	b.lock.Unlock()
+
+	go func() {
+		defer func() {
+			b.lock.Lock()
+			delete(b.subscribers, s)
+			b.follow()
+			b.lock.Unlock()
+		}()
+		s.run(ctx, b.logger)
+	}()
+}
+
+// follow follows the union of the queries of the subscribers, if any.
+// Callers hold the lock.
+func (b *eventBus) follow() {
+	if len(b.subscribers) == 0 {
+		if b.cancel != nil {
+			b.cancel()
+			b.watch, b.cancel = nil, nil
+		}
+		return
+	}
+
+	// Subscribers of any address or topic widen the query to all
+	addresses, anyAddress := make(map[common.Address]bool), false
+	topics, anyTopic := make(map[common.Hash]bool), false
+	query := ethereum.FilterQuery{Topics: [][]common.Hash{{}}}
+	for s := range b.subscribers {
+		anyAddress = anyAddress || len(s.query.Addresses) == 0
+		for _, address := range s.query.Addresses {
+			if !addresses[address] {
+				addresses[address] = true
+				query.Addresses = append(query.Addresses, address)
+			}
+		}
+		if len(s.query.Topics) == 0 || len(s.query.Topics[0]) == 0 {
+			anyTopic = true
+			continue
+		}
+		for _, topic := range s.query.Topics[0] {
+			if !topics[topic] {
+				topics[topic] = true
+				query.Topics[0] = append(query.Topics[0], topic)
+			}
+		}
+	}
+	if anyAddress {
+		query.Addresses = nil
+	}
+	if anyTopic {
+		query.Topics = nil
+	}
+
+	if b.watch != nil {
+		b.watch.setQuery(query)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.watch, b.cancel = newLogWatch(b.logger, b.client, b.logs, "pool events", query, b.retry), cancel
+	go b.watch.run(ctx, b.dispatch, b.failed)
+}
+
+// dispatch hands log to every subscriber of it, dropping it for the
+// ones whose buffer is full.
+func (b *eventBus) dispatch(log types.Log) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for s := range b.subscribers {
+		if !queryMatches(s.query, log) {
+			continue
+		}
+		select {
+		case s.logs <- log:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+			s.signal()
+		}
+	}
+}
+
+func (b *eventBus) failed(error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for s := range b.subscribers {
+		s.signal()
+	}
+}
+
+// stats returns the stats of every subscriber.
+func (b *eventBus) stats() []EventSubscriptionStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	stats := make([]EventSubscriptionStats, 0, len(b.subscribers))
+	for s := range b.subscribers {
+		stats = append(stats, EventSubscriptionStats{
+			Name:      s.name,
+			Delivered: atomic.LoadUint64(&s.delivered),
+			Dropped:   atomic.LoadUint64(&s.dropped),
+		})
+	}
+	return stats
+}
+
+func (s *eventSubscriber) signal() {
+	select {
+	case s.lost <- struct{}{}:
+	default:
+	}
+}
+
+func (s *eventSubscriber) run(ctx context.Context, logger Logger) {
+	var dropped uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case log := <-s.logs:
+			atomic.AddUint64(&s.delivered, 1)
+			s.fn(log)
+		case <-s.lost:
+			// The logs buffered predate what is read
+			for drained := false; !drained; {
+				select {
+				case log := <-s.logs:
+					atomic.AddUint64(&s.delivered, 1)
+					s.fn(log)
+				default:
+					drained = true
+				}
+			}
+			if total := atomic.LoadUint64(&s.dropped); total > dropped {
+				logger.Warn(fmt.Sprintf("Dropped %d %s while busy", total-dropped, s.name), F("logs", s.name), F("dropped", total-dropped))
+				dropped = total
+			}
+			if s.resync != nil {
+				s.resync()
+			}
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	defaultExecutionWorkers   = 1
+	defaultExecutionQueueSize = 64
+	// Executions running at shutdown are cancelled after, so their
+	// receipts are tracked without holding up shutdown indefinitely
+	defaultExecutionDrainTimeout = 2 * time.Minute
+)
+
+// Executor carries out the liquidation of a candidate, eg., simulates
+// it, submits it and waits for its receipt. The outcome is nil unless
+// a transaction was mined, even if it failed.
+type Executor interface {
+	Execute(ctx context.Context, c Candidate) (*Outcome, error)
+}
+
+// ExecutorFunc adapts a function to an Executor.
+type ExecutorFunc func(ctx context.Context, c Candidate) (*Outcome, error)
+
+func (f ExecutorFunc) Execute(ctx context.Context, c Candidate) (*Outcome, error) {
+	return f(ctx, c)
+}
+
+// Job is a candidate queued for execution.
+type Job struct {
+	Candidate Candidate
+	Executor  Executor
+	// Higher ranked jobs are executed first and dropped last
+	Rank *big.Int
+	// Called once a liquidation of the job succeeds, with the account
+	// no longer queued or executing, eg., to queue its next round
+	followUp func(context.Context, Job, *Outcome)
+}
+
+type jobKey struct {
+	pool    common.Address
+	account common.Address
+}
+
+func (j Job) key() jobKey {
+	return jobKey{pool: j.Candidate.Pool, account: j.Candidate.Account}
+}
+
+// ExecutionQueue decouples detection from execution: block processing
+// pushes jobs and workers execute them, so a slow transaction does not
+// delay scanning the next block. An account is never queued or
+// executed twice at the same time. When the queue is full the lowest
+// ranked job is dropped. Outcomes are recorded in the ledger, if any,
+// and no job is executed while its kill switch is engaged. Failures
+// cool their accounts down, if cooldowns are set. With a coordinator,
+// jobs whose lock a redundant instance holds are skipped, and no job
+// is executed while the instance stands by.
+type ExecutionQueue struct {
+	logger       Logger
+	workers      int
+	size         int
+	drainTimeout time.Duration
+	ledger       *Ledger
+	cooldowns    *Cooldowns
+	alerts       *Alerts
+	journal      *Journal
+	coordinator  *coordinator
+	roles        *roles
+	publisher    *publisher
+	budget       *Budget
+	// Chain the budget is shared by pools of
+	chain string
+
+	lock    sync.Mutex
+	cond    *sync.Cond
+	pending []Job
+	// Queued or executing
+	active  map[jobKey]bool
+	running map[jobKey]bool
+	closed  bool
+}
+
+func NewExecutionQueue(logger Logger, workers, size int) *ExecutionQueue {
+	if workers <= 0 {
+		workers = defaultExecutionWorkers
+	}
+	if size <= 0 {
+		size = defaultExecutionQueueSize
+	}
+	q := &ExecutionQueue{
+		logger:       logger,
+		workers:      workers,
+		size:         size,
+		drainTimeout: defaultExecutionDrainTimeout,
+		active:       make(map[jobKey]bool),
+		running:      make(map[jobKey]bool),
+	}
+	q.cond = sync.NewCond(&q.lock)
+	return q
+}
+
+// Push queues a job and reports whether it was queued. A job replaces
+// the queued job of the same account, as it is based on a later block,
+// continuing its campaign, and is dropped while the account is
+// executing.
+func (q *ExecutionQueue) Push(job Job) bool {
+	if job.Rank == nil {
+		job.Rank = new(big.Int)
+	}
+	key := job.key()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed || q.running[key] {
+		return false
+	}
+	if q.halted(job) {
+		return false
+	}
+	if q.active[key] {
+		for i := range q.pending {
+			if q.pending[i].key() == key {
+				if job.Candidate.Campaign == nil {
+					job.Candidate.Campaign = q.pending[i].Candidate.Campaign
+				}
+				q.pending[i] = job
+				return true
+			}
+		}
+	}
+
+	if len(q.pending) >= q.size {
+		lowest := 0
+		for i := range q.pending {
+			if GT(q.pending[lowest].Rank, q.pending[i].Rank) {
+				lowest = i
+			}
+		}
+		if !GT(job.Rank, q.pending[lowest].Rank) {
+			q.logger.Warn(fmt.Sprintf("Execution queue full; dropping account %s", job.Candidate.Account),
+				F("pool", job.Candidate.Pool), F("account", job.Candidate.Account))
+			return false
+		}
+		evicted := q.pending[lowest]
+		q.logger.Warn(fmt.Sprintf("Execution queue full; dropping account %s", evicted.Candidate.Account),
+			F("pool", evicted.Candidate.Pool), F("account", evicted.Candidate.Account))
+		delete(q.active, evicted.key())
+		q.pending = append(q.pending[:lowest], q.pending[lowest+1:]...)
+	}
+
+	q.pending = append(q.pending, job)
+	q.active[key] = true
+	q.cond.Signal()
+	return true
+}
+
+// Invalidate drops the queued jobs of a pool, eg., as they were
+// planned under parameters that changed, and returns how many.
+func (q *ExecutionQueue) Invalidate(pool common.Address) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	kept := q.pending[:0]
+	for _, job := range q.pending {
+		if job.Candidate.Pool == pool {
+			delete(q.active, job.key())
+			continue
+		}
+		kept = append(kept, job)
+	}
+	dropped := len(q.pending) - len(kept)
+	q.pending = kept
+	return dropped
+}
+
+// Len returns the number of queued jobs.
+func (q *ExecutionQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.pending)
+}
+
+// Run executes jobs until ctx is cancelled. Queued jobs are then
+// dropped and Run returns once executing jobs complete. Executing jobs
+// are not cancelled with ctx, so their transactions are followed to
+// their receipts, but once the drain timeout passes.
+func (q *ExecutionQueue) Run(ctx context.Context) {
+	drainCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(drainCtx)
+		}()
+	}
+
+	<-ctx.Done()
+	q.lock.Lock()
+	q.closed = true
+	if len(q.pending) > 0 {
+		q.logger.Info(fmt.Sprintf("Dropping %d queued executions", len(q.pending)), F("dropped", len(q.pending)))
+	}
+	q.pending = nil
+	running := len(q.running)
+	q.cond.Broadcast()
+	q.lock.Unlock()
+
+	if running > 0 {
+		q.logger.Info(fmt.Sprintf("Waiting up to %v for %d running executions", q.drainTimeout, running), F("running", running))
+	}
+	timeout := time.AfterFunc(q.drainTimeout, func() {
+		q.logger.Warn(fmt.Sprintf("Cancelling running executions after %v", q.drainTimeout))
+		cancel()
+	})
+	defer timeout.Stop()
+	wg.Wait()
+	q.logger.Info("Execution queue drained")
+}
+
+// drain executes the queued jobs one at a time, the highest ranked
+// first, without workers, and calls fn with the outcome of every job.
+// Jobs left once ctx is cancelled are not executed. It is used instead
+// of Run.
+func (q *ExecutionQueue) drain(ctx context.Context, fn func(Job, *Outcome, error)) {
+	for {
+		q.lock.Lock()
+		if len(q.pending) == 0 {
+			q.lock.Unlock()
+			return
+		}
+		job := q.pop()
+		q.lock.Unlock()
+
+		var outcome *Outcome
+		var err error
+		switch {
+		case ctx.Err() != nil:
+			err = ctx.Err()
+		case q.halted(job):
+			_, reason := q.ledger.Halted()
+			err = fmt.Errorf("kill switch engaged: %s", reason)
+		default:
+			outcome, err = q.execute(ctx, job)
+		}
+		fn(job, outcome, err)
+
+		q.lock.Lock()
+		delete(q.active, job.key())
+		q.lock.Unlock()
+		q.followUp(ctx, job, outcome, err)
+	}
+}
+
+// executeNow executes job before returning, unless its account is
+// queued or executing, and returns its outcome, if any, and why it
+// failed or was skipped. It is used instead of Push.
+func (q *ExecutionQueue) executeNow(ctx context.Context, job Job) (*Outcome, error) {
+	key := job.key()
+	q.lock.Lock()
+	if q.closed || q.active[key] || q.running[key] {
+		q.lock.Unlock()
+		return nil, fmt.Errorf("account %s is already queued or executing", job.Candidate.Account.Hex())
+	}
+	q.active[key], q.running[key] = true, true
+	q.lock.Unlock()
+
+	var outcome *Outcome
+	var err error
+	if q.halted(job) {
+		_, reason := q.ledger.Halted()
+		err = fmt.Errorf("kill switch engaged: %s", reason)
+	} else {
+		outcome, err = q.execute(ctx, job)
+	}
+
+	q.lock.Lock()
+	delete(q.running, key)
+	delete(q.active, key)
+	q.lock.Unlock()
+	q.followUp(ctx, job, outcome, err)
+	return outcome, err
+}
+
+// jobs returns the queued jobs.
+func (q *ExecutionQueue) jobs() []Job {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return append([]Job(nil), q.pending...)
+}
+
+func (q *ExecutionQueue) work(ctx context.Context) {
+	for {
+		q.lock.Lock()
+		for len(q.pending) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.lock.Unlock()
+			return
+		}
+		job := q.pop()
+		key := job.key()
+		q.running[key] = true
+		q.lock.Unlock()
+
+		var outcome *Outcome
+		var err error
+		if !q.halted(job) {
+			outcome, err = q.execute(ctx, job)
+		}
+
+		q.lock.Lock()
+		delete(q.running, key)
+		delete(q.active, key)
+		q.lock.Unlock()
+		q.followUp(ctx, job, outcome, err)
+	}
+}
+
+// followUp calls the follow-up of job if its liquidation succeeded.
+func (q *ExecutionQueue) followUp(ctx context.Context, job Job, outcome *Outcome, err error) {
+	if job.followUp == nil || err != nil || outcome == nil || outcome.Liquidation == nil {
+		return
+	}
+	recovered(q.logger, func() error {
+		job.followUp(ctx, job, outcome)
+		return nil
+	}, F("pool", job.Candidate.Pool), F("account", job.Candidate.Account))
+}
+
+// pop removes the highest ranked job, the oldest among equals.
+func (q *ExecutionQueue) pop() Job {
+	highest := 0
+	for i := range q.pending {
+		if GT(q.pending[i].Rank, q.pending[highest].Rank) {
+			highest = i
+		}
+	}
+	job := q.pending[highest]
+	q.pending = append(q.pending[:highest], q.pending[highest+1:]...)
+	return job
+}
+
+// admit fails with ErrStandby while the instance stands by, with
+// ErrHandledExternally if the consumer of the published candidates
+// handles job, and with ErrHeldByPeer if a redundant instance executes
+// it. Once admitted, unlock lets the lock of the execution go.
+func (q *ExecutionQueue) admit(ctx context.Context, job Job) (unlock func(sent bool), err error) {
+	if q.roles.standby() {
+		return nil, ErrStandby
+	}
+	if q.publisher.awaitHandled(ctx, job.Candidate) {
+		return nil, ErrHandledExternally
+	}
+	return q.coordinator.acquire(ctx, job.Candidate)
+}
+
+// execute executes job, and returns its outcome, if any, and why it
+// failed or was skipped.
+func (q *ExecutionQueue) execute(ctx context.Context, job Job) (*Outcome, error) {
+	unlock, err := q.admit(ctx, job)
+	if err != nil {
+		q.logger.Info(fmt.Sprintf("Skipping account %s (%s): %v", job.Candidate.Account, DropReason(err), err),
+			F("pool", job.Candidate.Pool), F("account", job.Candidate.Account), F("reason", DropReason(err)), F("err", err))
+		if q.cooldowns != nil {
+			q.cooldowns.record(job.Candidate.Pool, job.Candidate.Account, err, time.Now())
+		}
+		decision := JournalEntry{Kind: JournalDecision, Pool: job.Candidate.Pool, Block: job.Candidate.Block, Account: job.Candidate.Account,
+			Decision: "drop", Reason: DropReason(err), Err: err.Error()}
+		q.journal.Record(decision)
+		q.publisher.publish(job.Candidate, decision)
+		return nil, err
+	}
+	var outcome *Outcome
+	// Executions cancelled while waiting for their receipt may have
+	// sent a transaction
+	defer func() { unlock(outcome != nil && outcome.Tx != (common.Hash{}) || ctx.Err() != nil) }()
+	release, err := q.budget.execution(ctx, budgetKey{chain: q.chain, pool: job.Candidate.Pool})
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	err = recovered(q.logger, func() (err error) {
+		outcome, err = job.Executor.Execute(ctx, job.Candidate)
+		return err
+	}, F("pool", job.Candidate.Pool), F("account", job.Candidate.Account))
+	if err != nil {
+		q.logger.Error(fmt.Sprintf("Failed to execute liquidation of account %s: %v", job.Candidate.Account, err),
+			F("pool", job.Candidate.Pool), F("account", job.Candidate.Account), F("err", err))
+	}
+	if q.cooldowns != nil {
+		q.cooldowns.record(job.Candidate.Pool, job.Candidate.Account, err, time.Now())
+	}
+	if err == nil {
+		q.alerts.liquidated(job.Candidate.Pool, job.Candidate.Account)
+	}
+	if outcome == nil {
+		return nil, err
+	}
+	if outcome.Pool == (common.Address{}) {
+		outcome.Pool, outcome.Account = job.Candidate.Pool, job.Candidate.Account
+	}
+	if outcome.Tx != (common.Hash{}) && outcome.Campaign == nil {
+		outcome.Campaign = &Campaign{ID: outcome.Tx, Round: 1}
+		if job.Candidate.Campaign != nil {
+			outcome.Campaign = job.Candidate.Campaign
+		}
+	}
+	entry := JournalEntry{Kind: JournalReceipt, Pool: outcome.Pool, Account: outcome.Account, Tx: &outcome.Tx,
+		Data: JournalReceiptData{PnL: outcome.PnL, Swaps: outcome.Swaps, Liquidation: outcome.Liquidation, Campaign: outcome.Campaign}}
+	if err != nil {
+		entry.Err = err.Error()
+	}
+	q.journal.Record(entry)
+	q.publisher.publish(job.Candidate, entry)
+	if q.ledger == nil {
+		return outcome, err
+	}
+	if err := q.ledger.Record(*outcome); err != nil {
+		q.logger.Error(fmt.Sprintf("Failed to record execution of account %s: %v", job.Candidate.Account, err),
+			F("pool", job.Candidate.Pool), F("account", job.Candidate.Account), F("err", err))
+	}
+	return outcome, err
+}
+
+// halted reports whether the kill switch is engaged, logging the job
+// that is dropped.
+func (q *ExecutionQueue) halted(job Job) bool {
+	if q.ledger == nil {
+		return false
+	}
+	halted, reason := q.ledger.Halted()
+	if halted {
+		q.logger.Warn(fmt.Sprintf("Kill switch engaged (%s); dropping account %s", reason, job.Candidate.Account),
+			F("pool", job.Candidate.Pool), F("account", job.Candidate.Account), F("reason", reason))
+	}
+	return halted
+}
@@ -0,0 +1,211 @@
+package liquidatoor
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// slowExecutor executes candidates once released, or once its context
+// is done, and records what it executed.
+type slowExecutor struct {
+	started  chan common.Address
+	release  chan struct{}
+	lock     sync.Mutex
+	running  map[common.Address]int
+	overlaps int
+	executed []common.Address
+	// Errors of the contexts of the executions, once done
+	errs []error
+}
+
+func newSlowExecutor() *slowExecutor {
+	return &slowExecutor{started: make(chan common.Address, 16), release: make(chan struct{}), running: make(map[common.Address]int)}
+}
+
+func (e *slowExecutor) Execute(ctx context.Context, c Candidate) (*Outcome, error) {
+	e.lock.Lock()
+	e.running[c.Account]++
+	if e.running[c.Account] > 1 {
+		e.overlaps++
+	}
+	e.lock.Unlock()
+	e.started <- c.Account
+
+	select {
+	case <-e.release:
+	case <-ctx.Done():
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.running[c.Account]--
+	e.executed = append(e.executed, c.Account)
+	e.errs = append(e.errs, ctx.Err())
+	return nil, ctx.Err()
+}
+
+// wait waits for n executions to start and returns their accounts.
+func (e *slowExecutor) wait(t *testing.T, n int) []common.Address {
+	t.Helper()
+	var accounts []common.Address
+	for len(accounts) < n {
+		select {
+		case account := <-e.started:
+			accounts = append(accounts, account)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d executions, %d started", n, len(accounts))
+		}
+	}
+	return accounts
+}
+
+func queueJob(account int64, rank int64, executor Executor) Job {
+	return Job{
+		Candidate: Candidate{Pool: common.HexToAddress("0xc0"), Account: common.BigToAddress(big.NewInt(account))},
+		Executor:  executor,
+		Rank:      big.NewInt(rank),
+	}
+}
+
+func TestExecutionQueuePush(t *testing.T) {
+	q := NewExecutionQueue(quietLogger(), 1, 3)
+	for _, job := range []Job{queueJob(1, 1, nil), queueJob(2, 3, nil), queueJob(3, 2, nil)} {
+		if !q.Push(job) {
+			t.Fatalf("expected account %s queued", job.Candidate.Account)
+		}
+	}
+	ranks := func() []int64 {
+		var ranks []int64
+		for _, job := range q.jobs() {
+			ranks = append(ranks, job.Rank.Int64())
+		}
+		return ranks
+	}
+
+	// Full, the lowest ranked job is dropped for a higher one
+	if !q.Push(queueJob(4, 5, nil)) {
+		t.Fatal("expected the higher ranked job queued")
+	}
+	if got := ranks(); len(got) != 3 || got[0] != 3 || got[1] != 2 || got[2] != 5 {
+		t.Fatalf("expected the job ranked 1 evicted, got ranks %v", got)
+	}
+	// and jobs ranked no higher than the lowest are dropped
+	if q.Push(queueJob(5, 2, nil)) {
+		t.Fatal("expected a job ranked as the lowest dropped")
+	}
+	// A job of a queued account replaces it rather than evicting
+	if !q.Push(queueJob(3, 1, nil)) {
+		t.Fatal("expected the job of a queued account to replace it")
+	}
+	if got := ranks(); len(got) != 3 || got[1] != 1 {
+		t.Fatalf("expected the job of account 3 replaced, got ranks %v", got)
+	}
+	// Popped by rank
+	q.lock.Lock()
+	first, second := q.pop(), q.pop()
+	q.lock.Unlock()
+	if first.Rank.Int64() != 5 || second.Rank.Int64() != 3 {
+		t.Fatalf("expected the highest ranked jobs first, got %v and %v", first.Rank, second.Rank)
+	}
+}
+
+func TestExecutionQueueDedupsAccounts(t *testing.T) {
+	q := NewExecutionQueue(quietLogger(), 4, 8)
+	executor := newSlowExecutor()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx)
+	}()
+
+	if !q.Push(queueJob(1, 1, executor)) {
+		t.Fatal("expected account 1 queued")
+	}
+	executor.wait(t, 1)
+	// Not queued again while executing
+	if q.Push(queueJob(1, 2, executor)) {
+		t.Fatal("expected account 1 dropped while executing")
+	}
+	// Other accounts execute alongside
+	for account := int64(2); account <= 3; account++ {
+		if !q.Push(queueJob(account, 1, executor)) {
+			t.Fatalf("expected account %d queued", account)
+		}
+	}
+	executor.wait(t, 2)
+
+	close(executor.release)
+	cancel()
+	<-done
+	executor.lock.Lock()
+	defer executor.lock.Unlock()
+	if executor.overlaps != 0 || len(executor.executed) != 3 {
+		t.Fatalf("expected 3 executions without overlaps, got %d with %d overlaps", len(executor.executed), executor.overlaps)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("expected nothing queued, got %d", q.Len())
+	}
+}
+
+func TestExecutionQueueRunDrains(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		drainTimeout time.Duration
+		// Whether the execution completes before the timeout
+		released bool
+	}{
+		{name: "completes", drainTimeout: time.Minute, released: true},
+		{name: "times out", drainTimeout: 50 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q := NewExecutionQueue(quietLogger(), 1, 8)
+			q.drainTimeout = tc.drainTimeout
+			executor := newSlowExecutor()
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				q.Run(ctx)
+			}()
+			q.Push(queueJob(1, 1, executor))
+			executor.wait(t, 1)
+			// Queued behind the running execution
+			q.Push(queueJob(2, 1, executor))
+
+			cancel()
+			select {
+			case <-done:
+				if tc.released {
+					t.Fatal("expected Run to wait for the running execution")
+				}
+			case <-time.After(200 * time.Millisecond):
+				if !tc.released {
+					t.Fatal("expected Run to return once the drain timeout passed")
+				}
+			}
+			if tc.released {
+				close(executor.release)
+				<-done
+			}
+
+			executor.lock.Lock()
+			defer executor.lock.Unlock()
+			if len(executor.executed) != 1 || executor.executed[0] != common.BigToAddress(big.NewInt(1)) {
+				t.Fatalf("expected only the running execution completed, got %v", executor.executed)
+			}
+			// Cancelled by the drain timeout only
+			if cancelled := executor.errs[0] != nil; cancelled == tc.released {
+				t.Fatalf("expected the execution cancelled %v, got %v", !tc.released, executor.errs[0])
+			}
+			if q.Push(queueJob(3, 1, executor)) {
+				t.Fatal("expected nothing queued once closed")
+			}
+		})
+	}
+}
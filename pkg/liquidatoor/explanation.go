@@ -0,0 +1,120 @@
+package liquidatoor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Explanation is why a candidate was ranked and decided as it was.
+// Guards are recorded as they are evaluated and the rest when deciding,
+// from the values the decision used, so it cannot disagree with the
+// decision. A nil explanation records nothing.
+type Explanation struct {
+	// Inputs: what the account owes and the plan of the primary
+	// strategy, if any; values in the oracle's unit of account scaled by
+	// 1e18
+	Shortfall        *big.Int       `json:"shortfall,omitempty"`
+	Strategy         string         `json:"strategy,omitempty"`
+	BorrowMarket     common.Address `json:"borrowMarket"`
+	CollateralMarket common.Address `json:"collateralMarket"`
+	RepayValue       *big.Int       `json:"repayValue,omitempty"`
+	SeizeValue       *big.Int       `json:"seizeValue,omitempty"`
+	// Profit breakdown of the plan, if estimated
+	Profit *ProfitEstimate `json:"profit,omitempty"`
+	// In the order evaluated, up to the first failed
+	Guards []GuardResult `json:"guards"`
+	// Liquidate or drop, and the DropReason of dropped candidates
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+	// Rank of the execution queued, higher first, if queued
+	Rank *big.Int `json:"rank,omitempty"`
+}
+
+// GuardResult is the outcome of a check a candidate must pass to be
+// liquidated.
+type GuardResult struct {
+	Guard  string `json:"guard"`
+	Passed bool   `json:"passed"`
+}
+
+// failed records whether guard failed and returns it.
+func (e *Explanation) failed(guard string, failed bool) bool {
+	if e != nil {
+		e.Guards = append(e.Guards, GuardResult{Guard: guard, Passed: !failed})
+	}
+	return failed
+}
+
+// decide records the inputs of c and its decision, queued at rank if
+// liquidated and ranked.
+func (e *Explanation) decide(c Candidate, strategy string, rank *big.Int) {
+	if e == nil {
+		return
+	}
+	e.Shortfall, e.Profit = c.Shortfall, c.Estimate
+	if c.Plan != nil {
+		e.Strategy = strategy
+		e.BorrowMarket, e.CollateralMarket = c.Plan.BorrowMarket, c.Plan.CollateralMarket
+		e.RepayValue, e.SeizeValue = c.Plan.RepayValue, c.Plan.SeizeValue
+	}
+	e.Decision, e.Reason, e.Rank = "liquidate", "", rank
+	if c.Err != nil {
+		e.Decision, e.Reason, e.Rank = "drop", DropReason(c.Err), nil
+	}
+}
+
+// String formats the decision and the guards evaluated, eg., drop
+// (unprofitable): plan pass, profitable fail.
+func (e Explanation) String() string {
+	guards := make([]string, 0, len(e.Guards))
+	for _, guard := range e.Guards {
+		outcome := "fail"
+		if guard.Passed {
+			outcome = "pass"
+		}
+		guards = append(guards, guard.Guard+" "+outcome)
+	}
+	decision := e.Decision
+	if e.Reason != "" {
+		decision += " (" + e.Reason + ")"
+	}
+	if e.Profit != nil {
+		decision += ", " + e.Profit.String()
+	}
+	if len(guards) == 0 {
+		return decision + ": no guards evaluated"
+	}
+	return fmt.Sprintf("%s: %s", decision, strings.Join(guards, ", "))
+}
+
+// MarshalJSON encodes the values of the explanation as decimal strings.
+func (e Explanation) MarshalJSON() ([]byte, error) {
+	type explanation Explanation
+	return json.Marshal(struct {
+		explanation
+		Shortfall  *decimalInt `json:"shortfall,omitempty"`
+		RepayValue *decimalInt `json:"repayValue,omitempty"`
+		SeizeValue *decimalInt `json:"seizeValue,omitempty"`
+		Rank       *decimalInt `json:"rank,omitempty"`
+	}{explanation(e), decimal(e.Shortfall), decimal(e.RepayValue), decimal(e.SeizeValue), decimal(e.Rank)})
+}
+
+func (e *Explanation) UnmarshalJSON(data []byte) error {
+	type explanation Explanation
+	v := struct {
+		*explanation
+		Shortfall  *decimalInt `json:"shortfall,omitempty"`
+		RepayValue *decimalInt `json:"repayValue,omitempty"`
+		SeizeValue *decimalInt `json:"seizeValue,omitempty"`
+		Rank       *decimalInt `json:"rank,omitempty"`
+	}{explanation: (*explanation)(e)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	e.Shortfall, e.RepayValue, e.SeizeValue, e.Rank = v.Shortfall.int(), v.RepayValue.int(), v.SeizeValue.int(), v.Rank.int()
+	return nil
+}
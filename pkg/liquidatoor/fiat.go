@@ -0,0 +1,359 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	defaultFiatPriceURL = "https://api.coingecko.com/api/v3/simple/price"
+	defaultFiatPriceTTL = 5 * time.Minute
+	// Requests per second to the price API
+	defaultFiatPriceRate = 0.1
+	fiatPriceTimeout     = 10 * time.Second
+	// Bytes of the largest response read
+	fiatPriceMaxSize = 1 << 20
+)
+
+// FiatPricer prices tokens in USD by their identifiers at an external
+// price API, eg., CoinGecko ids. Its prices are only reported, never
+// used to decide liquidations.
+type FiatPricer interface {
+	// Prices returns the USD price of one whole token of the ids it
+	// can price; the others are left out.
+	Prices(ctx context.Context, ids []string) (map[string]float64, error)
+}
+
+// httpFiatPricer fetches prices from an API answering
+// ?ids=a,b&vs_currencies=usd with {"a":{"usd":1.5}}, as the simple
+// price API of CoinGecko. Prices are cached for a TTL, requests are
+// rate limited, and stale prices are returned while the API fails.
+type httpFiatPricer struct {
+	url     string
+	ttl     time.Duration
+	client  *http.Client
+	limiter *rateLimiter
+
+	lock  sync.Mutex
+	cache map[string]fiatPrice
+}
+
+type fiatPrice struct {
+	usd float64
+	at  time.Time
+}
+
+// NewHTTPFiatPricer returns a FiatPricer of the price API at url, the
+// simple price API of CoinGecko if empty, caching prices for ttl and
+// requesting it up to rate times per second.
+func NewHTTPFiatPricer(url string, ttl time.Duration, rate float64) FiatPricer {
+	if url == "" {
+		url = defaultFiatPriceURL
+	}
+	if ttl <= 0 {
+		ttl = defaultFiatPriceTTL
+	}
+	if rate <= 0 {
+		rate = defaultFiatPriceRate
+	}
+	return &httpFiatPricer{
+		url:     url,
+		ttl:     ttl,
+		client:  &http.Client{Timeout: fiatPriceTimeout},
+		limiter: newRateLimiter(rate),
+		cache:   make(map[string]fiatPrice),
+	}
+}
+
+func (p *httpFiatPricer) Prices(ctx context.Context, ids []string) (map[string]float64, error) {
+	now := time.Now()
+	prices := make(map[string]float64, len(ids))
+	expired := make([]string, 0)
+	p.lock.Lock()
+	for _, id := range ids {
+		if cached, ok := p.cache[id]; ok && now.Sub(cached.at) < p.ttl {
+			prices[id] = cached.usd
+		} else {
+			expired = append(expired, id)
+		}
+	}
+	p.lock.Unlock()
+	if len(expired) == 0 {
+		return prices, nil
+	}
+
+	fetched, err := p.fetch(ctx, expired)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for id, usd := range fetched {
+		p.cache[id] = fiatPrice{usd: usd, at: now}
+	}
+	for _, id := range expired {
+		if cached, ok := p.cache[id]; ok {
+			prices[id] = cached.usd
+		}
+	}
+	return prices, err
+}
+
+// fetch requests the prices of ids.
+func (p *httpFiatPricer) fetch(ctx context.Context, ids []string) (map[string]float64, error) {
+	if err := p.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, fiatPriceTimeout)
+	defer cancel()
+	query := url.Values{"ids": {strings.Join(ids, ",")}, "vs_currencies": {"usd"}}
+	separator := "?"
+	if strings.Contains(p.url, "?") {
+		separator = "&"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+separator+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot request fiat prices: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch fiat prices: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch fiat prices: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, fiatPriceMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read fiat prices: %w", err)
+	}
+	var body map[string]map[string]float64
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("cannot decode fiat prices: %w", err)
+	}
+	prices := make(map[string]float64, len(body))
+	for id, quotes := range body {
+		if usd, ok := quotes["usd"]; ok && usd > 0 && !math.IsInf(usd, 0) {
+			prices[id] = usd
+		}
+	}
+	return prices, nil
+}
+
+// loadFiatIDs reads the JSON object of the identifiers of underlyings
+// at path, with the native token keyed by the zero address.
+func loadFiatIDs(path string) (map[common.Address]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read fiat ids: %w", err)
+	}
+	var file map[string]string
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cannot decode fiat ids %s: %w", path, err)
+	}
+	ids := make(map[common.Address]string, len(file))
+	for address, id := range file {
+		if !common.IsHexAddress(address) || id == "" {
+			return nil, fmt.Errorf("%w: invalid fiat id %q of %q", ErrInvalidConfig, id, address)
+		}
+		ids[common.HexToAddress(address)] = id
+	}
+	return ids, nil
+}
+
+// fiatReporter renders values in USD for reporting from the prices of
+// a FiatPricer, refreshed every interval off the checks, so reporting
+// never waits for the price API. Values of the oracle of a pool are
+// priced through the markets of its last snapshot whose underlying is
+// mapped. Anything it cannot price is nil, and reported in the units it
+// has. A nil fiatReporter prices nothing.
+type fiatReporter struct {
+	logger   Logger
+	pricer   FiatPricer
+	ids      map[common.Address]string
+	interval time.Duration
+
+	lock sync.RWMutex
+	// USD per whole token by id, and per unit of account of the oracle
+	// of every pool
+	prices map[string]float64
+	units  map[common.Address]float64
+	// Why the last refresh failed, if it did
+	failure string
+}
+
+func newFiatReporter(logger Logger, pricer FiatPricer, ids map[common.Address]string, interval time.Duration) *fiatReporter {
+	if interval <= 0 {
+		interval = defaultFiatPriceTTL
+	}
+	return &fiatReporter{
+		logger:   logger,
+		pricer:   pricer,
+		ids:      ids,
+		interval: interval,
+		prices:   make(map[string]float64),
+		units:    make(map[common.Address]float64),
+	}
+}
+
+// run refreshes the prices every interval until ctx is cancelled.
+func (f *fiatReporter) run(ctx context.Context) {
+	if f == nil {
+		return
+	}
+	f.refresh(ctx)
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		f.refresh(ctx)
+	}
+}
+
+// refresh prices every id, keeping the previous prices of the ones the
+// pricer cannot price. Repeated failures are logged once.
+func (f *fiatReporter) refresh(ctx context.Context) {
+	ids := make([]string, 0, len(f.ids))
+	seen := make(map[string]bool, len(f.ids))
+	for _, id := range f.ids {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	var prices map[string]float64
+	err := recovered(f.logger, func() (err error) {
+		prices, err = f.pricer.Prices(ctx, ids)
+		return err
+	})
+
+	failure := ""
+	if err != nil {
+		failure = err.Error()
+	}
+	f.lock.Lock()
+	previous := f.failure
+	f.failure = failure
+	for id, usd := range prices {
+		f.prices[id] = usd
+	}
+	f.lock.Unlock()
+	switch {
+	case err != nil && failure != previous:
+		f.logger.Warn(fmt.Sprintf("Failed to update fiat prices; reporting %d of %d in USD: %v", len(prices), len(ids), err), F("err", err))
+	case err == nil && previous != "":
+		f.logger.Info(fmt.Sprintf("Updated fiat prices again, %d of %d", len(prices), len(ids)))
+	}
+}
+
+// price returns the USD price of one whole underlying, the native
+// token if zero.
+func (f *fiatReporter) price(underlying common.Address) (float64, bool) {
+	id, ok := f.ids[underlying]
+	if !ok {
+		return 0, false
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	usd, ok := f.prices[id]
+	return usd, ok
+}
+
+// amountUSD returns amount of underlying, of decimals, in USD, or nil
+// if it is not priced.
+func (f *fiatReporter) amountUSD(underlying common.Address, amount *big.Int, decimals uint8) *float64 {
+	if f == nil || amount == nil {
+		return nil
+	}
+	usd, ok := f.price(underlying)
+	if !ok {
+		return nil
+	}
+	return fiat(amount, decimals, usd)
+}
+
+// nativeUSD returns wei of the native token in USD, or nil if it is not
+// priced.
+func (f *fiatReporter) nativeUSD(wei *big.Int) *float64 {
+	return f.amountUSD(common.Address{}, wei, 18)
+}
+
+// observe prices the unit of account of the oracle of the pool of s by
+// its first market in address order whose underlying is priced, as the
+// USD price of the underlying over its oracle price.
+func (f *fiatReporter) observe(s *Snapshot) {
+	if f == nil || s == nil {
+		return
+	}
+	markets := make([]MarketSnapshot, 0, len(s.Markets))
+	for _, market := range s.Markets {
+		markets = append(markets, market)
+	}
+	sort.Slice(markets, func(i, j int) bool {
+		return bytes.Compare(markets[i].Address[:], markets[j].Address[:]) == -1
+	})
+	for _, market := range markets {
+		usd, ok := f.price(market.Underlying)
+		if !ok || !IsPositive(market.Price) {
+			continue
+		}
+		// Value of one whole underlying in the unit of account
+		value := market.Value(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(market.Decimals)), nil))
+		units := fiat(value, valueDecimals, 1)
+		if *units == 0 {
+			continue
+		}
+		f.lock.Lock()
+		f.units[s.Pool] = usd / *units
+		f.lock.Unlock()
+		return
+	}
+}
+
+// valueUSD returns value, in the unit of account of the oracle of pool
+// scaled by 1e18, in USD, or nil if the unit of account is not priced.
+func (f *fiatReporter) valueUSD(pool common.Address, value *big.Int) *float64 {
+	if f == nil || value == nil {
+		return nil
+	}
+	f.lock.RLock()
+	usd, ok := f.units[pool]
+	f.lock.RUnlock()
+	if !ok {
+		return nil
+	}
+	return fiat(value, valueDecimals, usd)
+}
+
+// fiat returns amount of decimals at a USD price per whole unit.
+func fiat(amount *big.Int, decimals uint8, usd float64) *float64 {
+	whole, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))).Float64()
+	v := whole * usd
+	return &v
+}
+
+// formatUSD formats a USD figure to append to the raw figure it was
+// priced from, empty if unpriced.
+func formatUSD(usd *float64) string {
+	if usd == nil {
+		return ""
+	}
+	return fmt.Sprintf(" (%s USD)", strconv.FormatFloat(*usd, 'f', 2, 64))
+}
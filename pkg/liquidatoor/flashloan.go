@@ -0,0 +1,211 @@
+package liquidatoor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+const (
+	BalancerFlashLiquidity = "balancer"
+	AaveFlashLiquidity     = "aave"
+)
+
+// The Balancer vault is deployed at the same address on every chain.
+var balancerVaultAddress = common.HexToAddress("0xBA12222222228d8Ba445958a75a0704d566BF2C8")
+
+// Aave flash loan premiums are expressed in basis points.
+var bpsScale = big.NewInt(10000)
+
+// FlashLiquiditySource is a venue able to lend tokens for the duration
+// of a transaction, used to fund liquidations without inventory.
+type FlashLiquiditySource interface {
+	Name() string
+	// Quote reports whether amount of token can be flash borrowed and
+	// what it would cost.
+	Quote(opts *bind.CallOpts, token common.Address, amount *big.Int) (*FlashQuote, error)
+	// LoanParams builds the parameters the executor contract needs to
+	// take the flash loan.
+	LoanParams(token common.Address, amount *big.Int) ([]byte, error)
+}
+
+type FlashQuote struct {
+	Available bool
+	Fee       *big.Int
+}
+
+func newFlashLiquiditySource(name string, client Backend, aavePool common.Address) (FlashLiquiditySource, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case BalancerFlashLiquidity:
+		return newBalancerFlashLiquidity(client, balancerVaultAddress)
+	case AaveFlashLiquidity:
+		if aavePool == (common.Address{}) {
+			return nil, errors.New("AAVE_POOL_ADDRESS cannot be empty")
+		}
+		return newAaveFlashLiquidity(client, aavePool)
+	default:
+		return nil, fmt.Errorf("unknown flash liquidity source %q", name)
+	}
+}
+
+// balancerFlashLiquidity lends whatever the Balancer vault holds,
+// without fees.
+type balancerFlashLiquidity struct {
+	client Backend
+	vault  common.Address
+	params abi.Arguments
+}
+
+func newBalancerFlashLiquidity(client Backend, vault common.Address) (*balancerFlashLiquidity, error) {
+	addressType, _ := abi.NewType("address", "", nil)
+	addressesType, _ := abi.NewType("address[]", "", nil)
+	amountsType, _ := abi.NewType("uint256[]", "", nil)
+
+	return &balancerFlashLiquidity{
+		client: client,
+		vault:  vault,
+		params: abi.Arguments{
+			{Name: "vault", Type: addressType},
+			{Name: "tokens", Type: addressesType},
+			{Name: "amounts", Type: amountsType},
+		},
+	}, nil
+}
+
+func (b *balancerFlashLiquidity) Name() string {
+	return BalancerFlashLiquidity
+}
+
+func (b *balancerFlashLiquidity) Quote(opts *bind.CallOpts, token common.Address, amount *big.Int) (*FlashQuote, error) {
+	erc20, err := abis.NewCToken(token, b.client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get interface for token %s: %w", token, err)
+	}
+	balance, err := erc20.BalanceOf(opts, b.vault)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get vault balance of %s: %w", token, err)
+	}
+	return &FlashQuote{Available: GTE(balance, amount), Fee: new(big.Int)}, nil
+}
+
+func (b *balancerFlashLiquidity) LoanParams(token common.Address, amount *big.Int) ([]byte, error) {
+	return b.params.Pack(b.vault, []common.Address{token}, []*big.Int{amount})
+}
+
+// aaveFlashLiquidity lends the available liquidity of an Aave v3
+// reserve for the pool's flash loan premium.
+type aaveFlashLiquidity struct {
+	client  Backend
+	address common.Address
+	pool    *abis.AaveV3Pool
+	params  abi.Arguments
+}
+
+func newAaveFlashLiquidity(client Backend, address common.Address) (*aaveFlashLiquidity, error) {
+	pool, err := abis.NewAaveV3Pool(address, client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate aave pool: %w", err)
+	}
+
+	addressType, _ := abi.NewType("address", "", nil)
+	amountType, _ := abi.NewType("uint256", "", nil)
+
+	return &aaveFlashLiquidity{
+		client:  client,
+		address: address,
+		pool:    pool,
+		params: abi.Arguments{
+			{Name: "pool", Type: addressType},
+			{Name: "asset", Type: addressType},
+			{Name: "amount", Type: amountType},
+		},
+	}, nil
+}
+
+func (a *aaveFlashLiquidity) Name() string {
+	return AaveFlashLiquidity
+}
+
+func (a *aaveFlashLiquidity) Quote(opts *bind.CallOpts, token common.Address, amount *big.Int) (*FlashQuote, error) {
+	reserve, err := a.pool.GetReserveData(opts, token)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get reserve data of %s: %w", token, err)
+	}
+	if reserve.ATokenAddress == (common.Address{}) {
+		// Not listed in the pool
+		return &FlashQuote{Available: false, Fee: new(big.Int)}, nil
+	}
+
+	erc20, err := abis.NewCToken(token, a.client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get interface for token %s: %w", token, err)
+	}
+	liquidity, err := erc20.BalanceOf(opts, reserve.ATokenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get available liquidity of %s: %w", token, err)
+	}
+
+	premium, err := a.pool.FLASHLOANPREMIUMTOTAL(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get flash loan premium: %w", err)
+	}
+	return &FlashQuote{Available: GTE(liquidity, amount), Fee: feeOf(amount, premium)}, nil
+}
+
+func (a *aaveFlashLiquidity) LoanParams(token common.Address, amount *big.Int) ([]byte, error) {
+	return a.params.Pack(a.address, token, amount)
+}
+
+// Funding describes where the repay amount of a liquidation comes from.
+type Funding struct {
+	// Nil when repaying from our wallet inventory
+	FlashLoan *FlashLoan
+}
+
+type FlashLoan struct {
+	Source string
+	Token  common.Address
+	Amount *big.Int
+	Fee    *big.Int
+	// Parameters for the executor contract
+	Params []byte
+}
+
+// planFunding prefers a flash loan when a source is configured and can
+// serve the amount, falling back to inventory otherwise.
+func (l *Liquidatoor) planFunding(opts *bind.CallOpts, token common.Address, amount *big.Int) *Funding {
+	if l.flashLiquidity == nil {
+		return &Funding{}
+	}
+
+	quote, err := l.flashLiquidity.Quote(opts, token, amount)
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Failed to quote %s flash loan of %v %s, using inventory: %v", l.flashLiquidity.Name(), amount, token, err), F("token", token), F("err", err))
+		return &Funding{}
+	}
+	if !quote.Available {
+		l.logger.Info(fmt.Sprintf("Not enough %s flash liquidity for %v %s, using inventory", l.flashLiquidity.Name(), amount, token), F("token", token))
+		return &Funding{}
+	}
+
+	params, err := l.flashLiquidity.LoanParams(token, amount)
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Failed to build %s flash loan parameters, using inventory: %v", l.flashLiquidity.Name(), err), F("token", token), F("err", err))
+		return &Funding{}
+	}
+	return &Funding{FlashLoan: &FlashLoan{
+		Source: l.flashLiquidity.Name(),
+		Token:  token,
+		Amount: amount,
+		Fee:    quote.Fee,
+		Params: params,
+	}}
+}
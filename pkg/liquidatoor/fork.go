@@ -0,0 +1,377 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+const (
+	defaultForkTimeout = 30 * time.Second
+	// Blocks the fork may mine past the block it was reset to while
+	// replaying a plan before it is a runaway fork
+	defaultForkMaxDrift = 16
+	// Interval receipts of the fork are polled at
+	forkReceiptInterval = 100 * time.Millisecond
+)
+
+// Share of the gross profit of a plan its replay on a fork may fall
+// short of
+var defaultForkTolerance = NewRatio(big.NewInt(5), big.NewInt(100))
+
+// ForkValidation is the replay of a plan on a fork of the block it is
+// about to be executed in.
+type ForkValidation struct {
+	// Fork was reset to, and its head once replayed
+	Block     uint64
+	ForkBlock uint64
+	// Transactions replayed, in order
+	Steps []ForkStep
+	// Net balance changes of the wallet by token, the native token keyed
+	// by the zero address, less the funding of the repay the fork
+	// needed
+	Deltas map[common.Address]*big.Int
+	// Values of the deltas and of the plan, in the oracle's unit of
+	// account scaled by 1e18
+	Realized *big.Int
+	Expected *big.Int
+}
+
+// ForkStep is a transaction of a replay.
+type ForkStep struct {
+	Name    string
+	Tx      common.Hash
+	GasUsed uint64
+}
+
+func (v ForkValidation) String() string {
+	steps := make([]string, 0, len(v.Steps))
+	for _, step := range v.Steps {
+		steps = append(steps, fmt.Sprintf("%s %d gas", step.Name, step.GasUsed))
+	}
+	return fmt.Sprintf("realized %s of %s expected at block %d (%s)", formatValue(v.Realized), formatValue(v.Expected), v.Block, strings.Join(steps, ", "))
+}
+
+// forkValidator replays plans against a fork endpoint, eg., anvil or a
+// hardhat node, forked from the node of the connection. The fork is
+// reset to the latest block before every replay, so replays run one at
+// a time. The wallet is impersonated and pays no gas, and the repay is
+// funded from the cash of the borrow market if the wallet lacks it, as
+// a flash loan would. Swaps of the redeemed collateral are left to the
+// executors, so they are not replayed. A nil forkValidator validates
+// every plan.
+type forkValidator struct {
+	logger Logger
+	// Fork, and the node forked from
+	url      string
+	upstream string
+	timeout  time.Duration
+	// Share of the gross profit the replay may fall short of
+	tolerance Ratio
+	maxDrift  uint64
+
+	cTokenABI *abi.ABI
+
+	lock   sync.Mutex
+	client *rpc.Client
+}
+
+func newForkValidator(logger Logger, url, upstream string, timeout time.Duration, tolerance *Ratio, maxDrift uint64) (*forkValidator, error) {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = defaultForkTimeout
+	}
+	if maxDrift == 0 {
+		maxDrift = defaultForkMaxDrift
+	}
+	f := &forkValidator{
+		logger:    logger,
+		url:       url,
+		upstream:  upstream,
+		timeout:   timeout,
+		tolerance: defaultForkTolerance,
+		maxDrift:  maxDrift,
+		cTokenABI: cTokenABI,
+	}
+	if tolerance != nil {
+		f.tolerance = *tolerance
+	}
+	return f, nil
+}
+
+// validateOnFork replays the plan of c on the fork and returns an
+// error wrapping ErrForkMismatch if it realizes less than the gross
+// profit of the plan, beyond the tolerance, or if the replay, net of
+// the estimated gas and slippage, is unprofitable, and one wrapping
+// ErrSimulationReverted if a transaction reverts. Failing to replay it
+// holds the execution as well.
+func (l *Liquidatoor) validateOnFork(ctx context.Context, c Candidate) error {
+	f := l.fork
+	if f == nil || c.Plan == nil {
+		return nil
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	v, err := f.replay(ctx, l, *c.Plan)
+	if err != nil {
+		return fmt.Errorf("cannot validate on fork: %w", err)
+	}
+	fields := []Field{F("pool", l.comptrollerAddress), F("account", c.Account), F("block", v.Block), F("realized", v.Realized), F("expected", v.Expected)}
+	// realized >= expected * (1 - tolerance)
+	short := new(big.Int).Sub(v.Expected, v.Realized)
+	if IsPositive(short) && IsPositive(v.Expected) && NewRatio(short, v.Expected).Exceeds(f.tolerance) {
+		return fmt.Errorf("%w: %s, short by more than %s", ErrForkMismatch, v, f.tolerance.Percent())
+	}
+	if c.Estimate != nil && c.Estimate.Currency == OracleUnitOfAccount {
+		net := new(big.Int).Sub(v.Realized, orZero(c.Estimate.Gas))
+		if net.Sub(net, orZero(c.Estimate.Slippage)); !IsPositive(net) {
+			return fmt.Errorf("%w: %s, %s net of the estimated gas and slippage", ErrForkMismatch, v, formatValue(net))
+		}
+	}
+	l.logger.Info(fmt.Sprintf("Validated liquidation of account %s on fork: %s", c.Account, v), fields...)
+	return nil
+}
+
+// replay resets the fork to the latest block of the connection and
+// executes plan on it.
+func (f *forkValidator) replay(ctx context.Context, l *Liquidatoor, plan LiquidationPlan) (*ForkValidation, error) {
+	if f.client == nil {
+		client, err := rpc.DialContext(ctx, f.url)
+		if err != nil {
+			return nil, fmt.Errorf("cannot dial fork: %w", err)
+		}
+		f.client = client
+	}
+	fork := ethclient.NewClient(f.client)
+
+	head, err := l.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get latest block: %w", err)
+	}
+	v := &ForkValidation{Block: head.Number.Uint64(), Deltas: make(map[common.Address]*big.Int)}
+	forking := map[string]interface{}{"jsonRpcUrl": f.upstream, "blockNumber": v.Block}
+	if err := f.client.CallContext(ctx, nil, "hardhat_reset", map[string]interface{}{"forking": forking}); err != nil {
+		return nil, fmt.Errorf("cannot reset fork to block %d: %w", v.Block, err)
+	}
+	number, err := fork.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get block of fork: %w", err)
+	}
+	if number < v.Block || number > v.Block+f.maxDrift {
+		return nil, fmt.Errorf("fork is at block %d after a reset to %d", number, v.Block)
+	}
+
+	wallet := l.TxOpts.From
+	borrow, collateral := l.underlyingInfo[plan.BorrowMarket.String()], l.underlyingInfo[plan.CollateralMarket.String()]
+	tokens := []UnderlyingInfo{borrow, collateral}
+	before := make(map[common.Address]*big.Int, len(tokens))
+	for _, token := range tokens {
+		if before[token.address], err = f.balance(ctx, fork, token, wallet); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.impersonate(ctx, wallet); err != nil {
+		return nil, err
+	}
+
+	// Fund the repay the wallet lacks
+	funded := new(big.Int)
+	if lacking := new(big.Int).Sub(plan.RepayAmount, before[borrow.address]); IsPositive(lacking) {
+		funded.Set(lacking)
+		if borrow.native {
+			balance := new(big.Int).Add(before[borrow.address], lacking)
+			if err := f.client.CallContext(ctx, nil, "hardhat_setBalance", wallet, hexutil.EncodeBig(balance)); err != nil {
+				return nil, fmt.Errorf("cannot fund wallet on fork: %w", err)
+			}
+		} else {
+			if err := f.impersonate(ctx, plan.BorrowMarket); err != nil {
+				return nil, err
+			}
+			if _, err := f.send(ctx, fork, v, "fund", plan.BorrowMarket, borrow.address, nil, "transfer", wallet, lacking); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !borrow.native {
+		if _, err := f.send(ctx, fork, v, "approve", wallet, borrow.address, nil, "approve", plan.BorrowMarket, plan.RepayAmount); err != nil {
+			return nil, err
+		}
+	}
+	cTokens, err := f.balance(ctx, fork, UnderlyingInfo{address: plan.CollateralMarket}, wallet)
+	if err != nil {
+		return nil, err
+	}
+	call, err := l.adapter.RepayCall(RepayParams{
+		Borrower:         plan.Borrower,
+		CTokenBorrowed:   plan.BorrowMarket,
+		CTokenCollateral: plan.CollateralMarket,
+		RepayAmount:      plan.RepayAmount,
+		Native:           borrow.native,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot build repay call: %w", err)
+	}
+	if _, err := f.sendCall(ctx, fork, v, "liquidate", wallet, call); err != nil {
+		return nil, err
+	}
+	seized, err := f.balance(ctx, fork, UnderlyingInfo{address: plan.CollateralMarket}, wallet)
+	if err != nil {
+		return nil, err
+	}
+	if seized.Sub(seized, cTokens); !IsPositive(seized) {
+		return nil, fmt.Errorf("%w: no collateral seized on fork", ErrForkMismatch)
+	}
+	if _, err := f.send(ctx, fork, v, "redeem", wallet, plan.CollateralMarket, nil, "redeem", seized); err != nil {
+		return nil, err
+	}
+
+	if v.ForkBlock, err = fork.BlockNumber(ctx); err != nil {
+		return nil, fmt.Errorf("cannot get block of fork: %w", err)
+	}
+	if v.ForkBlock > v.Block+f.maxDrift {
+		return nil, fmt.Errorf("runaway fork: mined %d blocks past %d replaying the plan", v.ForkBlock-v.Block, v.Block)
+	}
+
+	// Deltas, valued at the oracle prices of the block
+	v.Realized = new(big.Int)
+	for _, token := range tokens {
+		if _, ok := v.Deltas[token.address]; ok {
+			continue
+		}
+		after, err := f.balance(ctx, fork, token, wallet)
+		if err != nil {
+			return nil, err
+		}
+		delta := new(big.Int).Sub(after, before[token.address])
+		if token.address == borrow.address {
+			delta.Sub(delta, funded)
+		}
+		v.Deltas[token.address] = delta
+	}
+	markets := []common.Address{plan.BorrowMarket, plan.CollateralMarket}
+	if borrow.address == collateral.address {
+		markets = markets[:1]
+	}
+	for _, asset := range l.assets(markets) {
+		price, err := l.priceSource.PriceOf(ctx, asset, head.Number)
+		if err != nil {
+			return nil, fmt.Errorf("cannot price %s at block %v: %w", asset.Underlying, head.Number, err)
+		}
+		delta := v.Deltas[asset.Underlying]
+		value := USDValue(new(big.Int).Abs(delta), price.Mantissa, asset.Decimals)
+		if IsNegative(delta) {
+			value.Neg(value)
+		}
+		v.Realized.Add(v.Realized, value)
+	}
+	v.Expected = new(big.Int).Sub(plan.SeizeValue, plan.RepayValue)
+	return v, nil
+}
+
+// impersonate lets the fork send transactions from account.
+func (f *forkValidator) impersonate(ctx context.Context, account common.Address) error {
+	if err := f.client.CallContext(ctx, nil, "hardhat_impersonateAccount", account); err != nil {
+		return fmt.Errorf("cannot impersonate %s on fork: %w", account, err)
+	}
+	return nil
+}
+
+// balance returns the balance of account in token, of the native token
+// if native.
+func (f *forkValidator) balance(ctx context.Context, fork *ethclient.Client, token UnderlyingInfo, account common.Address) (*big.Int, error) {
+	if token.native {
+		balance, err := fork.BalanceAt(ctx, account, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get balance of %s on fork: %w", account, err)
+		}
+		return balance, nil
+	}
+	method := f.cTokenABI.Methods["balanceOf"]
+	inputs, err := method.Inputs.Pack(account)
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack account: %w", err)
+	}
+	out, err := fork.CallContract(ctx, ethereum.CallMsg{To: &token.address, Data: append(method.ID[:len(method.ID):len(method.ID)], inputs...)}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get balance of %s in %s on fork: %w", account, token.address, err)
+	}
+	values, err := method.Outputs.Unpack(out)
+	if err != nil || len(values) == 0 {
+		return nil, fmt.Errorf("cannot unpack balance of %s in %s on fork: %v", account, token.address, err)
+	}
+	balance, ok := values[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("cannot unpack balance of %s in %s on fork", account, token.address)
+	}
+	return balance, nil
+}
+
+// send calls method of the ctoken ABI, whose ERC20 methods any token
+// shares, on to from from.
+func (f *forkValidator) send(ctx context.Context, fork *ethclient.Client, v *ForkValidation, name string, from, to common.Address, value *big.Int, method string, args ...interface{}) (*types.Receipt, error) {
+	data, err := f.cTokenABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack %s: %w", method, err)
+	}
+	return f.sendCall(ctx, fork, v, name, from, &RepayCall{To: to, Value: value, Data: data})
+}
+
+// sendCall sends call from from without paying gas and waits for it to
+// be mined, recording it as a step of v.
+func (f *forkValidator) sendCall(ctx context.Context, fork *ethclient.Client, v *ForkValidation, name string, from common.Address, call *RepayCall) (*types.Receipt, error) {
+	if err := f.client.CallContext(ctx, nil, "hardhat_setNextBlockBaseFeePerGas", hexutil.EncodeBig(new(big.Int))); err != nil {
+		return nil, fmt.Errorf("cannot clear base fee of fork: %w", err)
+	}
+	tx := map[string]interface{}{"from": from, "to": call.To, "data": hexutil.Bytes(call.Data), "gasPrice": hexutil.EncodeBig(new(big.Int))}
+	if call.Value != nil {
+		tx["value"] = hexutil.EncodeBig(call.Value)
+	}
+	var hash common.Hash
+	if err := f.client.CallContext(ctx, &hash, "eth_sendTransaction", tx); err != nil {
+		if isRevert(err) {
+			return nil, fmt.Errorf("%s on fork: %w: %v", name, ErrSimulationReverted, err)
+		}
+		return nil, fmt.Errorf("cannot send %s on fork: %w", name, err)
+	}
+	ticker := time.NewTicker(forkReceiptInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := fork.TransactionReceipt(ctx, hash)
+		switch {
+		case err == nil && receipt != nil:
+			v.Steps = append(v.Steps, ForkStep{Name: name, Tx: hash, GasUsed: receipt.GasUsed})
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return nil, fmt.Errorf("%s on fork: %w: tx %s reverted", name, ErrSimulationReverted, hash)
+			}
+			return receipt, nil
+		case err != nil && !errors.Is(err, ethereum.NotFound):
+			return nil, fmt.Errorf("cannot get receipt of %s on fork: %w", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("cannot get receipt of %s on fork: %w", name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// L1FeeModel identifies how a rollup charges for posting
+// transaction data to L1.
+type L1FeeModel string
+
+const (
+	// L1 chains and sidechains have no L1 data fee
+	L1FeeNone     L1FeeModel = ""
+	L1FeeOPStack  L1FeeModel = "opstack"
+	L1FeeArbitrum L1FeeModel = "arbitrum"
+)
+
+var (
+	// OP-stack GasPriceOracle predeploy
+	opStackGasPriceOracleAddress = common.HexToAddress("0x420000000000000000000000000000000000000F")
+	// Arbitrum NodeInterface virtual contract
+	arbitrumNodeInterfaceAddress = common.HexToAddress("0x00000000000000000000000000000000000000C8")
+)
+
+// GasCost is the estimated cost of sending a transaction, in wei
+// of the native token.
+type GasCost struct {
+	Gas uint64
+	// Estimated without the access list, if the transaction has one
+	GasWithoutAccessList uint64
+	AccessList           types.AccessList
+	GasPrice             *big.Int
+	// Execution cost of the transaction
+	L2Fee *big.Int
+	// Cost of posting the transaction data to L1
+	L1Fee *big.Int
+	Total *big.Int
+}
+
+// L1Fee is the L1 data fee of a transaction.
+type L1Fee struct {
+	Fee *big.Int
+	// Part of the gas estimate of the node already accounting
+	// for the L1 fee, if any
+	IncludedGas uint64
+}
+
+// L1FeeEstimator estimates the L1 data fee of a transaction.
+type L1FeeEstimator interface {
+	L1Fee(ctx context.Context, tx *types.Transaction) (*L1Fee, error)
+}
+
+func newL1FeeEstimator(model L1FeeModel, client Backend) (L1FeeEstimator, error) {
+	switch model {
+	case L1FeeNone:
+		return noL1FeeEstimator{}, nil
+	case L1FeeOPStack:
+		oracle, err := abis.NewGasPriceOracle(opStackGasPriceOracleAddress, client)
+		if err != nil {
+			return nil, fmt.Errorf("cannot instantiate gas price oracle: %w", err)
+		}
+		return &opStackL1FeeEstimator{oracle: oracle}, nil
+	case L1FeeArbitrum:
+		nodeInterface, err := abis.NewNodeInterfaceCaller(arbitrumNodeInterfaceAddress, client)
+		if err != nil {
+			return nil, fmt.Errorf("cannot instantiate node interface: %w", err)
+		}
+		return &arbitrumL1FeeEstimator{nodeInterface: &abis.NodeInterfaceCallerRaw{Contract: nodeInterface}}, nil
+	default:
+		return nil, fmt.Errorf("unknown L1 fee model %q", model)
+	}
+}
+
+type noL1FeeEstimator struct{}
+
+func (noL1FeeEstimator) L1Fee(context.Context, *types.Transaction) (*L1Fee, error) {
+	return &L1Fee{Fee: new(big.Int)}, nil
+}
+
+// opStackL1FeeEstimator asks the GasPriceOracle predeploy for the
+// L1 fee of the serialized transaction.
+type opStackL1FeeEstimator struct {
+	oracle *abis.GasPriceOracle
+}
+
+func (e *opStackL1FeeEstimator) L1Fee(ctx context.Context, tx *types.Transaction) (*L1Fee, error) {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("cannot serialize transaction: %w", err)
+	}
+	fee, err := e.oracle.GetL1Fee(&bind.CallOpts{Context: ctx}, data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get L1 fee: %w", err)
+	}
+	return &L1Fee{Fee: fee}, nil
+}
+
+// arbitrumL1FeeEstimator uses the L1 component of the node's gas
+// estimate. Arbitrum gas estimates already include that component.
+type arbitrumL1FeeEstimator struct {
+	nodeInterface *abis.NodeInterfaceCallerRaw
+}
+
+func (e *arbitrumL1FeeEstimator) L1Fee(ctx context.Context, tx *types.Transaction) (*L1Fee, error) {
+	var to common.Address
+	if tx.To() != nil {
+		to = *tx.To()
+	}
+
+	var out []interface{}
+	if err := e.nodeInterface.Call(&bind.CallOpts{Context: ctx}, &out, "gasEstimateL1Component", to, tx.To() == nil, tx.Data()); err != nil {
+		return nil, fmt.Errorf("cannot get L1 gas estimate: %w", err)
+	}
+	gasForL1 := *abi.ConvertType(out[0], new(uint64)).(*uint64)
+	baseFee := *abi.ConvertType(out[1], new(*big.Int)).(**big.Int)
+
+	return &L1Fee{
+		Fee:         new(big.Int).Mul(new(big.Int).SetUint64(gasForL1), baseFee),
+		IncludedGas: gasForL1,
+	}, nil
+}
+
+// estimateGasCost estimates the total cost of sending the provided
+// call from our wallet, including any L1 data fee.
+func (l *Liquidatoor) estimateGasCost(ctx context.Context, call *RepayCall) (*GasCost, error) {
+	from := l.TxOpts.From
+	msg := ethereum.CallMsg{
+		From:  from,
+		To:    &call.To,
+		Value: call.Value,
+		Data:  call.Data,
+	}
+	gas, err := l.client.EstimateGas(ctx, msg)
+	if err != nil {
+		if isRevert(err) {
+			return nil, fmt.Errorf("cannot estimate gas: %w: %v", ErrSimulationReverted, err)
+		}
+		return nil, fmt.Errorf("cannot estimate gas: %w", err)
+	}
+	withoutList := gas
+	list, gas := l.accessLists.saving(ctx, l.client, msg, gas)
+
+	gasPrice, err := l.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get gas price: %w", err)
+	}
+	if gasPrice, err = l.gasCap.price(ctx, l.client, gasPrice); err != nil {
+		return nil, err
+	}
+
+	nonce, err := l.client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get nonce: %w", err)
+	}
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:    l.chainID,
+		Nonce:      nonce,
+		GasTipCap:  gasPrice,
+		GasFeeCap:  gasPrice,
+		Gas:        gas,
+		To:         &call.To,
+		Value:      call.Value,
+		Data:       call.Data,
+		AccessList: list,
+	})
+	l1Fee, err := l.l1FeeEstimator.L1Fee(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var l2Gas uint64
+	if l1Fee.IncludedGas < gas {
+		l2Gas = gas - l1Fee.IncludedGas
+	}
+	l2Fee := new(big.Int).Mul(new(big.Int).SetUint64(l2Gas), gasPrice)
+
+	cost := &GasCost{
+		Gas:      gas,
+		GasPrice: gasPrice,
+		L2Fee:    l2Fee,
+		L1Fee:    l1Fee.Fee,
+		Total:    new(big.Int).Add(l2Fee, l1Fee.Fee),
+	}
+	if list != nil {
+		cost.GasWithoutAccessList, cost.AccessList = withoutList, list
+	}
+	return cost, nil
+}
+
+// gasPaid returns the gas fee paid by a mined transaction, in wei of
+// the native token. L1 data fees are not included.
+func gasPaid(ctx context.Context, client Backend, tx *types.Transaction, receipt *types.Receipt) (*big.Int, error) {
+	gasPrice := tx.GasPrice()
+	if tx.Type() == types.DynamicFeeTxType {
+		head, err := client.HeaderByNumber(ctx, receipt.BlockNumber)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get header of block %v: %w", receipt.BlockNumber, err)
+		}
+		if head.BaseFee != nil {
+			gasPrice = new(big.Int).Add(head.BaseFee, tx.GasTipCap())
+			if GT(gasPrice, tx.GasFeeCap()) {
+				gasPrice = tx.GasFeeCap()
+			}
+		}
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), gasPrice), nil
+}
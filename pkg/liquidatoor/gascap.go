@@ -0,0 +1,124 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// gasCap is the hard maximum gas price transactions are sent with,
+// whatever fees the node suggests. Transactions that cannot be
+// included under the cap are rejected rather than sent underpriced.
+type gasCap struct {
+	logger Logger
+	// In wei; nil is unlimited. Legacy transactions are capped by
+	// maxGasPrice and EIP-1559 ones by maxFeePerGas, each falling back
+	// to the other.
+	maxGasPrice  *big.Int
+	maxFeePerGas *big.Int
+
+	// Transactions and estimates rejected since startup
+	rejected uint64
+}
+
+func newGasCap(logger Logger, maxGasPrice, maxFeePerGas *big.Int) *gasCap {
+	return &gasCap{logger: logger, maxGasPrice: maxGasPrice, maxFeePerGas: maxFeePerGas}
+}
+
+// limit returns the cap of transactions on top of head, if any.
+func (g *gasCap) limit(head *types.Header) *big.Int {
+	if (head.BaseFee != nil && g.maxFeePerGas != nil) || g.maxGasPrice == nil {
+		return g.maxFeePerGas
+	}
+	return g.maxGasPrice
+}
+
+// price caps the gas price of an estimate. It returns ErrGasPriceCap
+// if the price needed for inclusion is over the cap, so candidates
+// are dropped before they are sent.
+func (g *gasCap) price(ctx context.Context, client bind.ContractTransactor, gasPrice *big.Int) (*big.Int, error) {
+	if g.maxGasPrice == nil && g.maxFeePerGas == nil {
+		return gasPrice, nil
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get latest header: %w", err)
+	}
+	limit := g.limit(head)
+	needed := gasPrice
+	if head.BaseFee != nil {
+		needed = head.BaseFee
+	}
+	if err := g.check(needed, limit); err != nil {
+		return nil, err
+	}
+	if GT(gasPrice, limit) {
+		return limit, nil
+	}
+	return gasPrice, nil
+}
+
+// transactOpts returns a copy of txOpts bound to ctx with the fees
+// bind would pick, capped.
+func (g *gasCap) transactOpts(ctx context.Context, client bind.ContractTransactor, txOpts *bind.TransactOpts) (*bind.TransactOpts, error) {
+	opts := withContext(ctx, txOpts)
+	if g.maxGasPrice == nil && g.maxFeePerGas == nil {
+		return opts, nil
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get latest header: %w", err)
+	}
+	limit := g.limit(head)
+
+	if head.BaseFee == nil || opts.GasPrice != nil {
+		gasPrice := opts.GasPrice
+		if gasPrice == nil {
+			if gasPrice, err = client.SuggestGasPrice(ctx); err != nil {
+				return nil, fmt.Errorf("cannot get gas price: %w", err)
+			}
+		}
+		if err := g.check(gasPrice, limit); err != nil {
+			return nil, err
+		}
+		opts.GasPrice = gasPrice
+		return opts, nil
+	}
+
+	if err := g.check(head.BaseFee, limit); err != nil {
+		return nil, err
+	}
+	tip := opts.GasTipCap
+	if tip == nil {
+		if tip, err = client.SuggestGasTipCap(ctx); err != nil {
+			return nil, fmt.Errorf("cannot get gas tip: %w", err)
+		}
+	}
+	feeCap := opts.GasFeeCap
+	if feeCap == nil {
+		// The default of bind
+		feeCap = new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+	if GT(feeCap, limit) {
+		feeCap = new(big.Int).Set(limit)
+	}
+	if GT(tip, feeCap) {
+		tip = new(big.Int).Set(feeCap)
+	}
+	opts.GasTipCap, opts.GasFeeCap = tip, feeCap
+	return opts, nil
+}
+
+func (g *gasCap) check(needed, limit *big.Int) error {
+	if !GT(needed, limit) {
+		return nil
+	}
+	rejected := atomic.AddUint64(&g.rejected, 1)
+	g.logger.Warn(fmt.Sprintf("Gas price %v is over the cap of %v wei", needed, limit),
+		F("gasPrice", needed), F("cap", limit), F("rejected", rejected))
+	return fmt.Errorf("gas price %v over the cap of %v: %w", needed, limit, ErrGasPriceCap)
+}
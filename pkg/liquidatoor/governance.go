@@ -0,0 +1,120 @@
+package liquidatoor
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Comptroller getters whose changes precede parameter changes
+var governanceMethods = []string{"admin", "pendingAdmin", "comptrollerImplementation", "pendingComptrollerImplementation"}
+
+// governanceWatch alerts on changes of the admin and implementation of
+// a comptroller, read along the market state of every interval blocks.
+// Optionally, liquidations are held from a change until an operator
+// acknowledges it.
+type governanceWatch struct {
+	logger      Logger
+	comptroller common.Address
+	abi         *abi.ABI
+	interval    uint64
+	pause       bool
+
+	lastRead uint64
+	// Nil until first read
+	last map[string]common.Address
+
+	lock sync.Mutex
+	// Unacknowledged changes, held if pausing
+	changes []string
+}
+
+func newGovernanceWatch(logger Logger, comptroller common.Address, comptrollerABI *abi.ABI, interval uint64, pause bool) *governanceWatch {
+	if interval == 0 {
+		interval = 1
+	}
+	return &governanceWatch{logger: logger, comptroller: comptroller, abi: comptrollerABI, interval: interval, pause: pause}
+}
+
+// calls returns the getters to read at block, if due. A nil block is
+// always due.
+func (g *governanceWatch) calls(block *big.Int) []abis.MulticallCall {
+	if g.last != nil && block != nil && block.Uint64() < g.lastRead+g.interval {
+		return nil
+	}
+	if block != nil {
+		g.lastRead = block.Uint64()
+	}
+	calls := make([]abis.MulticallCall, 0, len(governanceMethods))
+	for _, name := range governanceMethods {
+		calls = append(calls, abis.MulticallCall{Target: g.comptroller, CallData: g.abi.Methods[name].ID})
+	}
+	return calls
+}
+
+// observe diffs the results of calls against the last seen values and
+// alerts on any change. Getters a comptroller lacks are ignored.
+func (g *governanceWatch) observe(block *big.Int, results []CallResult) {
+	if len(results) == 0 {
+		return
+	}
+	values := make(map[string]common.Address, len(governanceMethods))
+	for i, name := range governanceMethods {
+		if !results[i].Success || len(results[i].ReturnData) < 32 {
+			continue
+		}
+		values[name] = common.BytesToAddress(results[i].ReturnData[:32])
+	}
+
+	if g.last != nil {
+		for _, name := range governanceMethods {
+			old, seen := g.last[name]
+			value, ok := values[name]
+			if seen != ok || old != value {
+				g.changed(block, name, old, value)
+			}
+		}
+	}
+	g.last = values
+}
+
+func (g *governanceWatch) changed(block *big.Int, name string, old, value common.Address) {
+	change := fmt.Sprintf("%s changed from %s to %s", name, old, value)
+	g.logger.Error(fmt.Sprintf("Comptroller %s %s at block %v", g.comptroller, change, block),
+		F("pool", g.comptroller), F("block", block), F("field", name), F("old", old), F("new", value))
+	if !g.pause {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if len(g.changes) == 0 {
+		g.logger.Error(fmt.Sprintf("Holding liquidations in pool %s until the change is acknowledged", g.comptroller), F("pool", g.comptroller))
+	}
+	g.changes = append(g.changes, change)
+}
+
+// held returns the unacknowledged changes liquidations are held for,
+// if any.
+func (g *governanceWatch) held() []string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return append([]string(nil), g.changes...)
+}
+
+// acknowledge resumes liquidations and returns how many changes were
+// acknowledged.
+func (g *governanceWatch) acknowledge() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	acknowledged := len(g.changes)
+	if acknowledged > 0 {
+		g.logger.Info(fmt.Sprintf("Acknowledged %d changes, resuming liquidations in pool %s", acknowledged, g.comptroller), F("pool", g.comptroller))
+	}
+	g.changes = nil
+	return acknowledged
+}
@@ -0,0 +1,606 @@
+package liquidatoor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Blocks filtered at once by default
+const defaultHistoryBlockRange = 10000
+
+// Tables of the history store. Amounts are decimal strings, times Unix
+// seconds and addresses and hashes hex strings.
+var historySchema = []string{
+	`CREATE TABLE IF NOT EXISTS liquidations (
+		pool TEXT NOT NULL,
+		block INTEGER NOT NULL,
+		log_index INTEGER NOT NULL,
+		time INTEGER NOT NULL,
+		tx TEXT NOT NULL,
+		liquidator TEXT NOT NULL,
+		borrower TEXT NOT NULL,
+		borrow_market TEXT NOT NULL,
+		collateral_market TEXT NOT NULL,
+		repay_amount TEXT NOT NULL,
+		seize_tokens TEXT NOT NULL,
+		gas_used INTEGER NOT NULL,
+		gas_paid TEXT,
+		gas_tip TEXT,
+		PRIMARY KEY (pool, block, log_index)
+	)`,
+	// Liquidations are pruned by age
+	`CREATE INDEX IF NOT EXISTS liquidations_time ON liquidations (time)`,
+	`CREATE TABLE IF NOT EXISTS cursors (
+		pool TEXT PRIMARY KEY,
+		next_block INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS rollups (
+		day INTEGER NOT NULL,
+		liquidator TEXT NOT NULL,
+		borrow_market TEXT NOT NULL,
+		liquidations INTEGER NOT NULL,
+		borrowers INTEGER NOT NULL,
+		repay_amount TEXT NOT NULL,
+		gas_paid TEXT NOT NULL,
+		PRIMARY KEY (day, liquidator, borrow_market)
+	)`,
+}
+
+const historyLiquidationColumns = `pool, block, log_index, time, tx, liquidator, borrower, borrow_market, collateral_market,
+	repay_amount, seize_tokens, gas_used, gas_paid, gas_tip`
+
+// HistoricalLiquidation is a past LiquidateBorrow event of a pool.
+type HistoricalLiquidation struct {
+	Pool             common.Address
+	Block            uint64
+	Time             time.Time
+	Tx               common.Hash
+	LogIndex         uint
+	Liquidator       common.Address
+	Borrower         common.Address
+	BorrowMarket     common.Address
+	CollateralMarket common.Address
+	// In the underlying of the borrow market
+	RepayAmount *big.Int
+	// Collateral cTokens
+	SeizeTokens *big.Int
+	// Of the whole transaction
+	GasUsed uint64
+	// In wei of the native token; nil if the backend cannot fetch
+	// transactions
+	GasPaid *big.Int `json:",omitempty"`
+	// Effective priority fee per gas of the transaction over the base
+	// fee of its block, in wei; nil if the backend cannot fetch
+	// transactions
+	GasTip *big.Int `json:",omitempty"`
+}
+
+// HistoryGrouping is what liquidations are summarized by.
+type HistoryGrouping string
+
+const (
+	ByLiquidator HistoryGrouping = "liquidator"
+	ByMarket     HistoryGrouping = "market"
+	ByWeek       HistoryGrouping = "week"
+)
+
+// HistorySummary sums the liquidations of a group.
+type HistorySummary struct {
+	// Liquidator or borrow market address, or ISO week, eg., 2022-W07
+	Key          string
+	Liquidations int
+	Borrowers    int
+	// Only by market, in its underlying
+	RepayAmount *big.Int `json:",omitempty"`
+	// Of distinct transactions
+	GasPaid *big.Int
+}
+
+// HistoryRollup sums the liquidations of a day by a liquidator in a
+// borrow market, once pruned from the history store.
+type HistoryRollup struct {
+	// Midnight UTC
+	Day          time.Time
+	Liquidator   common.Address
+	BorrowMarket common.Address
+	Liquidations int
+	// Distinct within the day
+	Borrowers   int
+	RepayAmount *big.Int
+	GasPaid     *big.Int
+}
+
+// History stores the past liquidations of the monitored pools, indexed
+// from their LiquidateBorrow events, in a SQLite database, so it can
+// also be queried directly. Each pool is indexed up to a cursor so
+// indexing resumes where it stopped. Pruned liquidations are kept as
+// daily rollups, so summaries cover them.
+type History struct {
+	logger Logger
+	db     *sql.DB
+}
+
+// OpenHistory opens, or creates, the history store at path.
+func OpenHistory(logger Logger, path string) (*History, error) {
+	db, err := openSQLite(path, historySchema)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open history: %w", err)
+	}
+	return &History{logger: logger, db: db}, nil
+}
+
+func (h *History) Close() error {
+	return h.db.Close()
+}
+
+// cursor returns the next block to index of a pool, if any was.
+func (h *History) cursor(ctx context.Context, pool common.Address) (uint64, bool, error) {
+	var next uint64
+	err := h.db.QueryRowContext(ctx, `SELECT next_block FROM cursors WHERE pool = ?`, pool.Hex()).Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot read history cursor of pool %s: %w", pool, err)
+	}
+	return next, true, nil
+}
+
+// add stores liquidations of a pool and its cursor in a single
+// transaction, so an interrupted chunk is indexed again.
+func (h *History) add(ctx context.Context, pool common.Address, liquidations []HistoricalLiquidation, next uint64) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot write history: %w", err)
+	}
+	defer tx.Rollback()
+	for _, l := range liquidations {
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO liquidations (`+historyLiquidationColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			l.Pool.Hex(), l.Block, l.LogIndex, l.Time.Unix(), l.Tx.Hex(), l.Liquidator.Hex(), l.Borrower.Hex(), l.BorrowMarket.Hex(), l.CollateralMarket.Hex(),
+			sqlInt(l.RepayAmount), sqlInt(l.SeizeTokens), l.GasUsed, sqlInt(l.GasPaid), sqlInt(l.GasTip))
+		if err != nil {
+			return fmt.Errorf("cannot store liquidation: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO cursors (pool, next_block) VALUES (?, ?)`, pool.Hex(), next); err != nil {
+		return fmt.Errorf("cannot store history cursor: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot write history: %w", err)
+	}
+	return nil
+}
+
+// Liquidations calls fn with every stored liquidation, by pool and
+// block, without loading them all at once.
+func (h *History) Liquidations(ctx context.Context, fn func(HistoricalLiquidation) error) error {
+	return eachLiquidation(ctx, h.db, "", nil, fn)
+}
+
+// eachLiquidation calls fn with the liquidations matching where, if
+// set, by pool and block.
+func eachLiquidation(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}, where string, args []interface{}, fn func(HistoricalLiquidation) error) error {
+	query := `SELECT ` + historyLiquidationColumns + ` FROM liquidations`
+	if where != "" {
+		query += ` WHERE ` + where
+	}
+	rows, err := q.QueryContext(ctx, query+` ORDER BY pool, block, log_index`, args...)
+	if err != nil {
+		return fmt.Errorf("cannot read history: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			l                                                  HistoricalLiquidation
+			pool, tx, liquidator, borrower, market, collateral string
+			seconds                                            int64
+			repayAmount, seizeTokens, gasPaid, gasTip          sql.NullString
+		)
+		err := rows.Scan(&pool, &l.Block, &l.LogIndex, &seconds, &tx, &liquidator, &borrower, &market, &collateral,
+			&repayAmount, &seizeTokens, &l.GasUsed, &gasPaid, &gasTip)
+		if err != nil {
+			return fmt.Errorf("cannot read history: %w", err)
+		}
+		l.Pool, l.Tx, l.Time = common.HexToAddress(pool), common.HexToHash(tx), time.Unix(seconds, 0).UTC()
+		l.Liquidator, l.Borrower = common.HexToAddress(liquidator), common.HexToAddress(borrower)
+		l.BorrowMarket, l.CollateralMarket = common.HexToAddress(market), common.HexToAddress(collateral)
+		for _, amount := range []struct {
+			value *sql.NullString
+			x     **big.Int
+		}{{&repayAmount, &l.RepayAmount}, {&seizeTokens, &l.SeizeTokens}, {&gasPaid, &l.GasPaid}, {&gasTip, &l.GasTip}} {
+			if *amount.x, err = parseSQLInt(*amount.value); err != nil {
+				return fmt.Errorf("cannot decode liquidation %s/%d: %w", l.Tx, l.LogIndex, err)
+			}
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cannot read history: %w", err)
+	}
+	return nil
+}
+
+// Rollups calls fn with every daily rollup of pruned liquidations, by
+// day.
+func (h *History) Rollups(ctx context.Context, fn func(HistoryRollup) error) error {
+	rows, err := h.db.QueryContext(ctx, `SELECT day, liquidator, borrow_market, liquidations, borrowers, repay_amount, gas_paid
+		FROM rollups ORDER BY day, liquidator, borrow_market`)
+	if err != nil {
+		return fmt.Errorf("cannot read history: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanRollup(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cannot read history: %w", err)
+	}
+	return nil
+}
+
+func scanRollup(row interface{ Scan(...interface{}) error }) (HistoryRollup, error) {
+	var (
+		r                    HistoryRollup
+		day                  int64
+		liquidator, market   string
+		repayAmount, gasPaid sql.NullString
+	)
+	if err := row.Scan(&day, &liquidator, &market, &r.Liquidations, &r.Borrowers, &repayAmount, &gasPaid); err != nil {
+		return r, err
+	}
+	r.Day, r.Liquidator, r.BorrowMarket = time.Unix(day, 0).UTC(), common.HexToAddress(liquidator), common.HexToAddress(market)
+	var err error
+	if r.RepayAmount, err = parseSQLInt(repayAmount); err != nil {
+		return r, fmt.Errorf("cannot decode rollup of %s: %w", r.Day.Format("2006-01-02"), err)
+	}
+	if r.GasPaid, err = parseSQLInt(gasPaid); err != nil {
+		return r, fmt.Errorf("cannot decode rollup of %s: %w", r.Day.Format("2006-01-02"), err)
+	}
+	return r, nil
+}
+
+// prune rolls up the liquidations before a time into daily rollups
+// and deletes them, in a single transaction, then vacuums the store to
+// return the space freed. It returns the number of liquidations
+// deleted.
+func (h *History) prune(ctx context.Context, before time.Time) (int, error) {
+	type rollupKey struct {
+		day                time.Time
+		liquidator, market common.Address
+	}
+	rollups := make(map[rollupKey]*HistoryRollup)
+	borrowers := make(map[rollupKey]map[common.Address]bool)
+	txs := make(map[rollupKey]map[common.Hash]bool)
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("cannot prune history: %w", err)
+	}
+	defer tx.Rollback()
+	pruned := 0
+	err = eachLiquidation(ctx, tx, `time < ?`, []interface{}{before.Unix()}, func(l HistoricalLiquidation) error {
+		day := l.Time.UTC().Truncate(24 * time.Hour)
+		k := rollupKey{day: day, liquidator: l.Liquidator, market: l.BorrowMarket}
+		rollup, ok := rollups[k]
+		if !ok {
+			rollup = &HistoryRollup{Day: day, Liquidator: l.Liquidator, BorrowMarket: l.BorrowMarket, RepayAmount: new(big.Int), GasPaid: new(big.Int)}
+			rollups[k] = rollup
+			borrowers[k] = make(map[common.Address]bool)
+			txs[k] = make(map[common.Hash]bool)
+		}
+		rollup.Liquidations++
+		if !borrowers[k][l.Borrower] {
+			borrowers[k][l.Borrower] = true
+			rollup.Borrowers++
+		}
+		rollup.RepayAmount.Add(rollup.RepayAmount, l.RepayAmount)
+		if l.GasPaid != nil && !txs[k][l.Tx] {
+			txs[k][l.Tx] = true
+			rollup.GasPaid.Add(rollup.GasPaid, l.GasPaid)
+		}
+		pruned++
+		return nil
+	})
+	if err != nil || pruned == 0 {
+		return 0, err
+	}
+
+	for _, rollup := range rollups {
+		// Days pruned across passes add up
+		existing, err := scanRollup(tx.QueryRowContext(ctx, `SELECT day, liquidator, borrow_market, liquidations, borrowers, repay_amount, gas_paid
+			FROM rollups WHERE day = ? AND liquidator = ? AND borrow_market = ?`, rollup.Day.Unix(), rollup.Liquidator.Hex(), rollup.BorrowMarket.Hex()))
+		switch {
+		case err == nil:
+			rollup.Liquidations += existing.Liquidations
+			rollup.Borrowers += existing.Borrowers
+			rollup.RepayAmount.Add(rollup.RepayAmount, existing.RepayAmount)
+			rollup.GasPaid.Add(rollup.GasPaid, existing.GasPaid)
+		case !errors.Is(err, sql.ErrNoRows):
+			return 0, fmt.Errorf("cannot read rollup: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO rollups (day, liquidator, borrow_market, liquidations, borrowers, repay_amount, gas_paid)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, rollup.Day.Unix(), rollup.Liquidator.Hex(), rollup.BorrowMarket.Hex(), rollup.Liquidations, rollup.Borrowers,
+			sqlInt(rollup.RepayAmount), sqlInt(rollup.GasPaid))
+		if err != nil {
+			return 0, fmt.Errorf("cannot store rollup: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM liquidations WHERE time < ?`, before.Unix()); err != nil {
+		return 0, fmt.Errorf("cannot prune history: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("cannot prune history: %w", err)
+	}
+	if _, err := h.db.ExecContext(ctx, `VACUUM`); err != nil {
+		return pruned, fmt.Errorf("cannot vacuum history: %w", err)
+	}
+	return pruned, nil
+}
+
+// Summary sums the stored liquidations, and the rollups of the pruned
+// ones, by the provided grouping, with the most liquidations first;
+// weeks are in chronological order. The borrowers of rollups are only
+// distinct within their day.
+func (h *History) Summary(ctx context.Context, by HistoryGrouping) ([]HistorySummary, error) {
+	var key func(liquidator, market common.Address, t time.Time) string
+	switch by {
+	case ByLiquidator:
+		key = func(liquidator, _ common.Address, _ time.Time) string { return liquidator.String() }
+	case ByMarket:
+		key = func(_, market common.Address, _ time.Time) string { return market.String() }
+	case ByWeek:
+		key = func(_, _ common.Address, t time.Time) string {
+			year, week := t.UTC().ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown history grouping %q", ErrInvalidConfig, by)
+	}
+
+	groups := make(map[string]*HistorySummary)
+	borrowers := make(map[string]map[common.Address]bool)
+	txs := make(map[string]map[common.Hash]bool)
+	groupOf := func(k string) *HistorySummary {
+		group, ok := groups[k]
+		if !ok {
+			group = &HistorySummary{Key: k, GasPaid: new(big.Int)}
+			if by == ByMarket {
+				group.RepayAmount = new(big.Int)
+			}
+			groups[k] = group
+			borrowers[k] = make(map[common.Address]bool)
+			txs[k] = make(map[common.Hash]bool)
+		}
+		return group
+	}
+	err := h.Rollups(ctx, func(r HistoryRollup) error {
+		group := groupOf(key(r.Liquidator, r.BorrowMarket, r.Day))
+		group.Liquidations += r.Liquidations
+		group.Borrowers += r.Borrowers
+		if group.RepayAmount != nil {
+			group.RepayAmount.Add(group.RepayAmount, r.RepayAmount)
+		}
+		group.GasPaid.Add(group.GasPaid, r.GasPaid)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = h.Liquidations(ctx, func(l HistoricalLiquidation) error {
+		k := key(l.Liquidator, l.BorrowMarket, l.Time)
+		group := groupOf(k)
+		group.Liquidations++
+		if !borrowers[k][l.Borrower] {
+			borrowers[k][l.Borrower] = true
+			group.Borrowers++
+		}
+		if group.RepayAmount != nil {
+			group.RepayAmount.Add(group.RepayAmount, l.RepayAmount)
+		}
+		if l.GasPaid != nil && !txs[k][l.Tx] {
+			txs[k][l.Tx] = true
+			group.GasPaid.Add(group.GasPaid, l.GasPaid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]HistorySummary, 0, len(groups))
+	for _, group := range groups {
+		summaries = append(summaries, *group)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if by != ByWeek && summaries[i].Liquidations != summaries[j].Liquidations {
+			return summaries[i].Liquidations > summaries[j].Liquidations
+		}
+		return summaries[i].Key < summaries[j].Key
+	})
+	return summaries, nil
+}
+
+// IndexHistory indexes the liquidations of every configured pool into
+// the history store, from where the previous indexing stopped, or the
+// configured start block, up to the latest block.
+func (c *Connection) IndexHistory(ctx context.Context, h *History) error {
+	indexer, err := newHistoryIndexer(c.logger, c.client, c.logLimiter, h, c.config.HistoryBlockRange)
+	if err != nil {
+		return err
+	}
+	for _, pool := range c.config.Comptrollers {
+		comptroller, err := abis.NewComptroller(pool, c.client)
+		if err != nil {
+			return fmt.Errorf("cannot instantiate comptroller: %w", err)
+		}
+		markets, err := comptroller.GetAllMarkets(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return fmt.Errorf("cannot get markets of pool %s: %w", pool, err)
+		}
+		if err := indexer.index(ctx, pool, markets, c.config.HistoryStartBlock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// historyIndexer stores the LiquidateBorrow events of a pool.
+type historyIndexer struct {
+	logger  Logger
+	client  Backend
+	history *History
+	logs    *logBackfill
+
+	filterer *abis.CTokenFilterer
+	topic    common.Hash
+}
+
+func newHistoryIndexer(logger Logger, client Backend, limiter *logLimiter, h *History, blockRange uint64) (*historyIndexer, error) {
+	if blockRange == 0 {
+		blockRange = defaultHistoryBlockRange
+	}
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	filterer, err := abis.NewCTokenFilterer(common.Address{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate ctoken filterer: %w", err)
+	}
+	return &historyIndexer{
+		logger:   logger,
+		client:   client,
+		history:  h,
+		logs:     newLogBackfill(logger, client, limiter, blockRange, logRetries),
+		filterer: filterer,
+		topic:    cTokenABI.Events["LiquidateBorrow"].ID,
+	}, nil
+}
+
+func (x *historyIndexer) index(ctx context.Context, pool common.Address, markets []common.Address, startBlock uint64) error {
+	from, ok, err := x.history.cursor(ctx, pool)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		from = startBlock
+	}
+	header, err := x.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot get latest block: %w", err)
+	}
+	head := header.Number.Uint64()
+
+	indexed := 0
+	query := ethereum.FilterQuery{Addresses: markets, Topics: [][]common.Hash{{x.topic}}}
+	err = x.logs.filter(ctx, "liquidations of pool "+pool.String(), query, from, head, func(logs []types.Log, last uint64) error {
+		n, err := x.store(ctx, pool, logs, last+1)
+		indexed += n
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	x.logger.Info(fmt.Sprintf("Indexed %d liquidations of pool %s up to block %d", indexed, pool, head), F("pool", pool), F("block", head), F("liquidations", indexed))
+	return nil
+}
+
+// store writes the liquidations of logs and the cursor of the pool in
+// a single transaction, once every header and receipt is fetched, so
+// an interrupted chunk is indexed again.
+func (x *historyIndexer) store(ctx context.Context, pool common.Address, logs []types.Log, next uint64) (int, error) {
+	headers := make(map[uint64]*types.Header)
+	gas := make(map[common.Hash]*types.Receipt)
+	paid := make(map[common.Hash]*big.Int)
+	tips := make(map[common.Hash]*big.Int)
+	var liquidations []HistoricalLiquidation
+	for _, log := range logs {
+		if log.Removed {
+			continue
+		}
+		event, err := x.filterer.ParseLiquidateBorrow(log)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse LiquidateBorrow event: %w", err)
+		}
+		header, ok := headers[log.BlockNumber]
+		if !ok {
+			if header, err = x.client.HeaderByNumber(ctx, new(big.Int).SetUint64(log.BlockNumber)); err != nil {
+				return 0, fmt.Errorf("cannot get header of block %d: %w", log.BlockNumber, err)
+			}
+			headers[log.BlockNumber] = header
+		}
+		if _, ok := gas[log.TxHash]; !ok {
+			if gas[log.TxHash], paid[log.TxHash], tips[log.TxHash], err = x.gas(ctx, log.TxHash, header.BaseFee); err != nil {
+				return 0, err
+			}
+		}
+		liquidations = append(liquidations, HistoricalLiquidation{
+			Pool:             pool,
+			Block:            log.BlockNumber,
+			Time:             time.Unix(int64(header.Time), 0).UTC(),
+			Tx:               log.TxHash,
+			LogIndex:         log.Index,
+			Liquidator:       event.Liquidator,
+			Borrower:         event.Borrower,
+			BorrowMarket:     log.Address,
+			CollateralMarket: event.CTokenCollateral,
+			RepayAmount:      event.RepayAmount,
+			SeizeTokens:      event.SeizeTokens,
+			GasUsed:          gas[log.TxHash].GasUsed,
+			GasPaid:          paid[log.TxHash],
+			GasTip:           tips[log.TxHash],
+		})
+	}
+	if err := x.history.add(ctx, pool, liquidations, next); err != nil {
+		return 0, err
+	}
+	return len(liquidations), nil
+}
+
+// gas returns the receipt of a transaction and, if the backend can
+// fetch transactions, the gas it paid and its tip over baseFee.
+func (x *historyIndexer) gas(ctx context.Context, hash common.Hash, baseFee *big.Int) (*types.Receipt, *big.Int, *big.Int, error) {
+	receipt, err := x.client.TransactionReceipt(ctx, hash)
+	if err == nil && receipt == nil {
+		err = ethereum.NotFound
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("cannot get receipt of %s: %w", hash, err)
+	}
+	reader, ok := x.client.(interface {
+		TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	})
+	if !ok {
+		return receipt, nil, nil, nil
+	}
+	tx, _, err := reader.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("cannot get transaction %s: %w", hash, err)
+	}
+	paid, err := gasPaid(ctx, x.client, tx, receipt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return receipt, paid, tx.EffectiveGasTipValue(baseFee), nil
+}
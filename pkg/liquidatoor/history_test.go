@@ -0,0 +1,257 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	historyPool       = common.HexToAddress("0xc0")
+	historyLiquidator = common.HexToAddress("0x1")
+	historyRival      = common.HexToAddress("0x2")
+	historyMarket     = common.HexToAddress("0xa")
+	historyOther      = common.HexToAddress("0xb")
+)
+
+func openTestHistory(t *testing.T) *History {
+	t.Helper()
+	h, err := OpenHistory(quietLogger(), filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// historyLiquidation is a liquidation by liquidator of borrower in the
+// market, repaying repay and paying gas in transaction tx, days after
+// Monday 14 February 2022.
+func historyLiquidation(block uint64, tx byte, liquidator, borrower, market common.Address, repay, gas int64, days int) HistoricalLiquidation {
+	return HistoricalLiquidation{
+		Pool:             historyPool,
+		Block:            block,
+		Time:             time.Date(2022, 2, 14+days, 12, 0, 0, 0, time.UTC),
+		Tx:               common.Hash{tx},
+		LogIndex:         uint(block % 3),
+		Liquidator:       liquidator,
+		Borrower:         borrower,
+		BorrowMarket:     market,
+		CollateralMarket: historyOther,
+		RepayAmount:      big.NewInt(repay),
+		SeizeTokens:      new(big.Int).Lsh(big.NewInt(repay), 70),
+		GasUsed:          uint64(gas),
+		GasPaid:          big.NewInt(gas),
+		GasTip:           big.NewInt(1),
+	}
+}
+
+func liquidationsOf(t *testing.T, h *History) []HistoricalLiquidation {
+	t.Helper()
+	var liquidations []HistoricalLiquidation
+	if err := h.Liquidations(context.Background(), func(l HistoricalLiquidation) error {
+		liquidations = append(liquidations, l)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return liquidations
+}
+
+func TestHistory(t *testing.T) {
+	h := openTestHistory(t)
+	if _, ok, err := h.cursor(context.Background(), historyPool); err != nil || ok {
+		t.Fatalf("expected no cursor, got %v, %v", ok, err)
+	}
+
+	borrower := common.HexToAddress("0x1000")
+	first := historyLiquidation(20, 1, historyLiquidator, borrower, historyMarket, 100, 10, 0)
+	second := historyLiquidation(10, 2, historyRival, borrower, historyMarket, 200, 20, 0)
+	// Backends that cannot fetch transactions leave the gas paid unset
+	second.GasPaid, second.GasTip = nil, nil
+	if err := h.add(context.Background(), historyPool, []HistoricalLiquidation{first, second}, 21); err != nil {
+		t.Fatal(err)
+	}
+	// Chunks indexed again replace their liquidations
+	if err := h.add(context.Background(), historyPool, []HistoricalLiquidation{first}, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	if next, ok, err := h.cursor(context.Background(), historyPool); err != nil || !ok || next != 30 {
+		t.Fatalf("expected the cursor at block 30, got %d, %v, %v", next, ok, err)
+	}
+	if got := liquidationsOf(t, h); !reflect.DeepEqual(got, []HistoricalLiquidation{second, first}) {
+		t.Fatalf("expected the liquidations by block, got %+v", got)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := h.Liquidations(context.Background(), func(HistoricalLiquidation) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected iteration to stop at the first error, got %v after %d", err, calls)
+	}
+
+	// Reads stop with their context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.Summary(ctx, ByLiquidator); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the summary to stop once cancelled, got %v", err)
+	}
+}
+
+func TestHistorySummary(t *testing.T) {
+	h := openTestHistory(t)
+	alice, bob := common.HexToAddress("0x1000"), common.HexToAddress("0x2000")
+	liquidations := []HistoricalLiquidation{
+		historyLiquidation(1, 1, historyLiquidator, alice, historyMarket, 100, 10, 0),
+		// Liquidating alice twice in the transaction of the first
+		historyLiquidation(2, 1, historyLiquidator, alice, historyOther, 50, 10, 0),
+		historyLiquidation(3, 3, historyLiquidator, bob, historyMarket, 300, 30, 7),
+		historyLiquidation(4, 4, historyRival, bob, historyMarket, 400, 40, 7),
+	}
+	if err := h.add(context.Background(), historyPool, liquidations, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		by       HistoryGrouping
+		expected []HistorySummary
+	}{
+		{by: ByLiquidator, expected: []HistorySummary{
+			{Key: historyLiquidator.String(), Liquidations: 3, Borrowers: 2, GasPaid: big.NewInt(40)},
+			{Key: historyRival.String(), Liquidations: 1, Borrowers: 1, GasPaid: big.NewInt(40)},
+		}},
+		{by: ByMarket, expected: []HistorySummary{
+			{Key: historyMarket.String(), Liquidations: 3, Borrowers: 2, RepayAmount: big.NewInt(800), GasPaid: big.NewInt(80)},
+			{Key: historyOther.String(), Liquidations: 1, Borrowers: 1, RepayAmount: big.NewInt(50), GasPaid: big.NewInt(10)},
+		}},
+		// In chronological order
+		{by: ByWeek, expected: []HistorySummary{
+			{Key: "2022-W07", Liquidations: 2, Borrowers: 1, GasPaid: big.NewInt(10)},
+			{Key: "2022-W08", Liquidations: 2, Borrowers: 1, GasPaid: big.NewInt(70)},
+		}},
+	} {
+		t.Run(string(tc.by), func(t *testing.T) {
+			summaries, err := h.Summary(context.Background(), tc.by)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(summaries, tc.expected) {
+				t.Fatalf("expected %+v, got %+v", tc.expected, summaries)
+			}
+		})
+	}
+
+	if _, err := h.Summary(context.Background(), "day"); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestHistoryPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h, err := OpenHistory(quietLogger(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	alice, bob := common.HexToAddress("0x1000"), common.HexToAddress("0x2000")
+	liquidations := []HistoricalLiquidation{
+		historyLiquidation(1, 1, historyLiquidator, alice, historyMarket, 100, 10, 0),
+		historyLiquidation(2, 2, historyLiquidator, bob, historyMarket, 200, 20, 0),
+		historyLiquidation(3, 3, historyLiquidator, alice, historyMarket, 300, 30, 1),
+		historyLiquidation(4, 4, historyRival, bob, historyMarket, 400, 40, 7),
+	}
+	// Enough liquidations for pruning them to free pages
+	for block := uint64(10); block < 2000; block++ {
+		liquidations = append(liquidations, historyLiquidation(block, byte(block), historyRival, alice, historyOther, 1, 0, 30))
+	}
+	if err := h.add(context.Background(), historyPool, liquidations, 2000); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := h.Summary(context.Background(), ByMarket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The janitor prunes the history while it is open elsewhere
+	janitor := &janitor{logger: quietLogger(), historyPath: path, maxAge: 24 * time.Hour}
+	report, err := janitor.prune(context.Background(), liquidations[2].Time.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Liquidations != 2 {
+		t.Fatalf("expected the liquidations of the first day pruned, got %+v", report)
+	}
+	// Days pruned across passes add up
+	late := historyLiquidation(0, 5, historyLiquidator, bob, historyMarket, 500, 50, 0)
+	if err := h.add(context.Background(), historyPool, []HistoricalLiquidation{late}, 2000); err != nil {
+		t.Fatal(err)
+	}
+	if pruned, err := h.prune(context.Background(), liquidations[2].Time); err != nil || pruned != 1 {
+		t.Fatalf("expected the late liquidation of the first day pruned, got %d, %v", pruned, err)
+	}
+	if pruned, err := h.prune(context.Background(), liquidations[3].Time.Add(time.Second)); err != nil || pruned != 2 {
+		t.Fatalf("expected the liquidations of the second and eighth days pruned, got %d, %v", pruned, err)
+	}
+	if got := liquidationsOf(t, h); len(got) != len(liquidations)-4 {
+		t.Fatalf("expected %d liquidations left, got %d", len(liquidations)-4, len(got))
+	}
+
+	var rollups []HistoryRollup
+	if err := h.Rollups(context.Background(), func(r HistoryRollup) error {
+		rollups = append(rollups, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	day := func(days int) time.Time { return time.Date(2022, 2, 14+days, 0, 0, 0, 0, time.UTC) }
+	expectedRollups := []HistoryRollup{
+		// Borrowers are distinct within a pass only
+		{Day: day(0), Liquidator: historyLiquidator, BorrowMarket: historyMarket, Liquidations: 3, Borrowers: 3, RepayAmount: big.NewInt(800), GasPaid: big.NewInt(80)},
+		{Day: day(1), Liquidator: historyLiquidator, BorrowMarket: historyMarket, Liquidations: 1, Borrowers: 1, RepayAmount: big.NewInt(300), GasPaid: big.NewInt(30)},
+		{Day: day(7), Liquidator: historyRival, BorrowMarket: historyMarket, Liquidations: 1, Borrowers: 1, RepayAmount: big.NewInt(400), GasPaid: big.NewInt(40)},
+	}
+	if !reflect.DeepEqual(rollups, expectedRollups) {
+		t.Fatalf("expected rollups %+v, got %+v", expectedRollups, rollups)
+	}
+
+	// Summaries cover the rollups, and the late liquidation; borrowers
+	// of rollups are only distinct within their day
+	summaries, err := h.Summary(context.Background(), ByMarket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	market := &expected[1]
+	market.Liquidations++
+	market.Borrowers += 3
+	market.RepayAmount.Add(market.RepayAmount, late.RepayAmount)
+	market.GasPaid.Add(market.GasPaid, late.GasPaid)
+	if !reflect.DeepEqual(summaries, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, summaries)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned, err := h.prune(context.Background(), liquidations[4].Time.Add(time.Second)); err != nil || pruned != len(liquidations)-4 {
+		t.Fatalf("expected every liquidation pruned, got %d, %v", pruned, err)
+	}
+	vacuumed, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vacuumed.Size() >= info.Size() {
+		t.Fatalf("expected the history vacuumed below %d bytes, got %d", info.Size(), vacuumed.Size())
+	}
+}
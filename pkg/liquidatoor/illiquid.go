@@ -0,0 +1,143 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// defaultIlliquidCollateralAlert is the share of the cash of a
+// collateral market an account may owe, or a plan may seize, before it
+// is illiquid.
+var defaultIlliquidCollateralAlert = NewRatio(big.NewInt(1), big.NewInt(1))
+
+// IlliquidCollateral is a candidate whose shortfall or planned seize
+// exceeds the bound of the cash of its collateral market: seizing it
+// likely fails or cannot be redeemed, and what remains is bad debt.
+// Values are in the unit of account of the oracle scaled by 1e18.
+type IlliquidCollateral struct {
+	Market common.Address `json:"market"`
+	// Of the market, in value
+	Cash        *big.Int `json:"cash"`
+	TotalSupply *big.Int `json:"totalSupply,omitempty"`
+	// Of the account and of the plan before it was bounded by the cash
+	Shortfall  *big.Int `json:"shortfall,omitempty"`
+	SeizeValue *big.Int `json:"seizeValue"`
+	// Largest of the shortfall and the seize value over the cash, and
+	// the seize value over the total supply
+	CashRatio   Ratio `json:"cashRatio"`
+	SupplyShare Ratio `json:"supplyShare"`
+}
+
+func (i IlliquidCollateral) String() string {
+	return fmt.Sprintf("owing or seizing %s of the cash of market %s (%s of its supply)", i.CashRatio.Percent(), i.Market, i.SupplyShare.Percent())
+}
+
+// MarshalJSON encodes the values as decimal strings.
+func (i IlliquidCollateral) MarshalJSON() ([]byte, error) {
+	type illiquid IlliquidCollateral
+	return json.Marshal(struct {
+		illiquid
+		Cash        *decimalInt `json:"cash"`
+		TotalSupply *decimalInt `json:"totalSupply,omitempty"`
+		Shortfall   *decimalInt `json:"shortfall,omitempty"`
+		SeizeValue  *decimalInt `json:"seizeValue"`
+	}{illiquid(i), decimal(i.Cash), decimal(i.TotalSupply), decimal(i.Shortfall), decimal(i.SeizeValue)})
+}
+
+// illiquidCollateral returns how plan of account compares to the cash
+// of its collateral market, if it exceeds the bound. Markets whose cash
+// is unknown are never illiquid.
+func illiquidCollateral(s *Snapshot, account AccountPositions, plan LiquidationPlan, bound Ratio) *IlliquidCollateral {
+	market, ok := s.Markets[plan.CollateralMarket]
+	if !ok || market.Cash == nil || market.Price == nil {
+		return nil
+	}
+	cash := market.Value(market.Cash)
+	owed := orZero(plan.SeizeValue)
+	if GT(account.Shortfall, owed) {
+		owed = account.Shortfall
+	}
+	i := &IlliquidCollateral{
+		Market:     plan.CollateralMarket,
+		Cash:       cash,
+		Shortfall:  account.Shortfall,
+		SeizeValue: plan.SeizeValue,
+		CashRatio:  NewRatio(owed, cash),
+	}
+	if !i.CashRatio.Exceeds(bound) {
+		return nil
+	}
+	if market.TotalSupply != nil {
+		i.TotalSupply = market.Value(market.TotalSupply)
+		i.SupplyShare = NewRatio(orZero(plan.SeizeValue), i.TotalSupply)
+	}
+	return i
+}
+
+// realizable checks the plans of the primary strategy against the cash
+// of their collateral markets. Illiquid candidates are tagged, alerted
+// once until they are no longer illiquid, and planned again with their
+// positions marked Illiquid, so the strategy seizes what the market can
+// pay out. The plans of illiquid accounts are replaced by the new ones,
+// if any; positions stay marked for the shadow strategies.
+func (l *Liquidatoor) realizable(ctx context.Context, strategy Strategy, input *StrategyInput, plans []LiquidationPlan, candidates map[common.Address]Candidate) []LiquidationPlan {
+	indexes := make(map[common.Address]int, len(input.Candidates))
+	for i, account := range input.Candidates {
+		indexes[account.Account] = i
+	}
+	var illiquid []AccountPositions
+	for _, plan := range plans {
+		i, ok := indexes[plan.Borrower]
+		if !ok {
+			continue
+		}
+		account := input.Candidates[i]
+		found := illiquidCollateral(input.Snapshot, account, plan, l.illiquidCollateralAlert)
+		if found == nil {
+			delete(l.illiquidAccounts, account.Account)
+			continue
+		}
+		if c, ok := candidates[account.Account]; ok {
+			c.Illiquid = found
+			candidates[account.Account] = c
+		}
+		if !l.illiquidAccounts[account.Account] {
+			l.illiquidAccounts[account.Account] = true
+			l.logger.Error(fmt.Sprintf("Account %s is underwater beyond the liquidity of its collateral: %s", account.Account, found),
+				F("pool", l.comptrollerAddress), F("account", account.Account), F("market", found.Market),
+				F("shortfall", found.Shortfall), F("seizeValue", found.SeizeValue), F("cash", found.Cash), F("cashRatio", found.CashRatio))
+		}
+		input.Candidates[i].Illiquid = true
+		illiquid = append(illiquid, input.Candidates[i])
+	}
+	if len(illiquid) == 0 {
+		return plans
+	}
+
+	var replanned []LiquidationPlan
+	err := recovered(l.logger, func() (err error) {
+		replanned, err = strategy.Plan(ctx, &StrategyInput{Snapshot: input.Snapshot, Candidates: illiquid, Inventory: input.Inventory})
+		return err
+	}, F("pool", l.comptrollerAddress), F("strategy", strategy.Name()))
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Failed to plan illiquid accounts with strategy %s, keeping their plans: %v", strategy.Name(), err),
+			F("pool", l.comptrollerAddress), F("strategy", strategy.Name()), F("err", err))
+		return plans
+	}
+	// Accounts without a plan within the cash keep theirs
+	replace := make(map[common.Address]bool, len(replanned))
+	for _, plan := range replanned {
+		replace[plan.Borrower] = true
+	}
+	kept := make([]LiquidationPlan, 0, len(plans))
+	for _, plan := range plans {
+		if !replace[plan.Borrower] {
+			kept = append(kept, plan)
+		}
+	}
+	return append(kept, replanned...)
+}
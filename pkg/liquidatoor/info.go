@@ -1,6 +1,12 @@
 package liquidatoor
 
+import "github.com/ethereum/go-ethereum/common"
+
 type UnderlyingInfo struct {
+	address  common.Address
 	name     string
 	decimals uint8
+	// Set for markets of the native token, eg., cETH, which
+	// do not have an underlying ERC20
+	native bool
 }
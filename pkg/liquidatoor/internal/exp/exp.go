@@ -0,0 +1,84 @@
+// Package exp combines 1e18-scaled mantissas, such as close factors,
+// liquidation incentives, collateral factors and exchange rates, the
+// way Compound's Exponential library does, so values computed off-chain
+// match the protocol's to the unit. Multiplications happen before
+// divisions, and results are truncated towards zero as in Solidity
+// unless stated otherwise. Ceil variants round up instead, for amounts
+// that must not be underestimated.
+//
+// Functions set z to their result and return it, as math/big does. z
+// may alias the operands unless stated otherwise.
+package exp
+
+import "math/big"
+
+var (
+	// Scale of mantissas, 1e18
+	Scale = big.NewInt(1e18)
+	// Half of Scale, for rounding
+	halfScale = big.NewInt(5e17)
+)
+
+// MulScalarTruncate sets z to the mantissa a times scalar, truncated
+// to an integer: a*scalar/1e18.
+func MulScalarTruncate(z, a, scalar *big.Int) *big.Int {
+	z.Mul(a, scalar)
+	return z.Quo(z, Scale)
+}
+
+// MulScalarCeil is MulScalarTruncate rounding up.
+func MulScalarCeil(z, a, scalar *big.Int) *big.Int {
+	z.Mul(a, scalar)
+	return QuoCeil(z, z, Scale)
+}
+
+// DivScalar sets z to the mantissa a divided by scalar, a mantissa:
+// a/scalar.
+func DivScalar(z, a, scalar *big.Int) *big.Int {
+	return z.Quo(a, scalar)
+}
+
+// DivScalarByExpTruncate sets z to scalar divided by the mantissa
+// divisor, truncated to an integer: scalar*1e18/divisor. z may not
+// alias divisor.
+func DivScalarByExpTruncate(z, scalar, divisor *big.Int) *big.Int {
+	z.Mul(scalar, Scale)
+	return z.Quo(z, divisor)
+}
+
+// DivScalarByExpCeil is DivScalarByExpTruncate rounding up.
+func DivScalarByExpCeil(z, scalar, divisor *big.Int) *big.Int {
+	z.Mul(scalar, Scale)
+	return QuoCeil(z, z, divisor)
+}
+
+// QuoCeil sets z to x/y rounded up. z may not alias y.
+func QuoCeil(z, x, y *big.Int) *big.Int {
+	var r big.Int
+	z.QuoRem(x, y, &r)
+	// Truncating only rounds down positive quotients
+	if r.Sign() != 0 && r.Sign() == y.Sign() {
+		z.Add(z, big.NewInt(1))
+	}
+	return z
+}
+
+// MulExp sets z to the product of the mantissas a and b, a mantissa:
+// (a*b+0.5e18)/1e18. Unlike the other operations it rounds half up,
+// as Compound does.
+func MulExp(z, a, b *big.Int) *big.Int {
+	z.Mul(a, b)
+	if z.Sign() == -1 {
+		z.Sub(z, halfScale)
+	} else {
+		z.Add(z, halfScale)
+	}
+	return z.Quo(z, Scale)
+}
+
+// DivExp sets z to the mantissa a divided by the mantissa b, a
+// mantissa: a*1e18/b. z may not alias b.
+func DivExp(z, a, b *big.Int) *big.Int {
+	z.Mul(a, Scale)
+	return z.Quo(z, b)
+}
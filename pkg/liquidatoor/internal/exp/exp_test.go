@@ -0,0 +1,203 @@
+package exp
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+)
+
+// Mainnet Compound v2 parameters.
+var (
+	// Initial exchange rate of cDAI and cETH: 0.02 underlying per cToken,
+	// scaled by 1e18 and by 1e18/1e8 for the decimals of the underlying
+	// and the cToken
+	initialRate18 = mustInt("200000000000000000000000000")
+	// Initial exchange rate of cUSDC, with 6 decimals underlying
+	initialRate6 = big.NewInt(2e14)
+	// Comptroller close factor and liquidation incentive
+	closeFactor          = big.NewInt(5e17)
+	liquidationIncentive = big.NewInt(108e16)
+)
+
+func mustInt(s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid integer " + s)
+	}
+	return i
+}
+
+type mantissaCase struct {
+	name     string
+	a, b     *big.Int
+	expected *big.Int
+}
+
+func checkMantissa(t *testing.T, fn func(z, a, b *big.Int) *big.Int, cases []mantissaCase) {
+	t.Helper()
+	for _, tc := range cases {
+		if result := fn(new(big.Int), tc.a, tc.b); result.Cmp(tc.expected) != 0 {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, result)
+		}
+	}
+}
+
+func TestMulScalarTruncate(t *testing.T) {
+	checkMantissa(t, MulScalarTruncate, []mantissaCase{
+		// balanceOfUnderlying of the 50 cDAI minted for 1 DAI
+		{"cDAI balance at the initial rate", initialRate18, big.NewInt(5e9), big.NewInt(1e18)},
+		// 1.23456789 cUSDC at an accrued rate of 0.0226739504568262,
+		// 27992.531... units of USDC
+		{"cUSDC balance truncated", big.NewInt(226739504568262), big.NewInt(123456789), big.NewInt(27992)},
+		// The most a single liquidation repays of a 1001 wei borrow
+		{"close factor truncated", closeFactor, big.NewInt(1001), big.NewInt(500)},
+		// The seized cTokens of liquidateCalculateSeizeTokens below
+		{"seize tokens", big.NewInt(27e17), big.NewInt(1e9), big.NewInt(27e8)},
+		{"under a unit", big.NewInt(999_999_999_999_999_999), big.NewInt(1), big.NewInt(0)},
+	})
+}
+
+func TestMulScalarCeil(t *testing.T) {
+	checkMantissa(t, MulScalarCeil, []mantissaCase{
+		{"cDAI balance at the initial rate", initialRate18, big.NewInt(5e9), big.NewInt(1e18)},
+		{"cUSDC balance rounded up", big.NewInt(226739504568262), big.NewInt(123456789), big.NewInt(27993)},
+		{"under a unit", big.NewInt(1), big.NewInt(1), big.NewInt(1)},
+		{"zero", closeFactor, big.NewInt(0), big.NewInt(0)},
+	})
+}
+
+func TestDivScalarByExpTruncate(t *testing.T) {
+	checkMantissa(t, DivScalarByExpTruncate, []mantissaCase{
+		// Minting for 1 DAI or 1 USDC at the initial rates
+		{"cDAI minted", big.NewInt(1e18), initialRate18, big.NewInt(5e9)},
+		{"cUSDC minted", big.NewInt(1e6), initialRate6, big.NewInt(5e9)},
+		// redeemUnderlying of 27.99253 USDC at the accrued rate above
+		{"cUSDC redeemed truncated", big.NewInt(27992530), big.NewInt(226739504568262), big.NewInt(123456783824)},
+		// A 1000 USD repay is at most 1080 USD of collateral
+		{"incentive", big.NewInt(1080), liquidationIncentive, big.NewInt(1000)},
+	})
+}
+
+func TestDivScalarByExpCeil(t *testing.T) {
+	checkMantissa(t, DivScalarByExpCeil, []mantissaCase{
+		{"cDAI minted", big.NewInt(1e18), initialRate18, big.NewInt(5e9)},
+		{"cUSDC redeemed rounded up", big.NewInt(27992530), big.NewInt(226739504568262), big.NewInt(123456783825)},
+	})
+}
+
+func TestDivScalar(t *testing.T) {
+	checkMantissa(t, DivScalar, []mantissaCase{
+		{"exact", liquidationIncentive, big.NewInt(4), big.NewInt(27e16)},
+		{"truncated", mustInt("1000000000000000000000000000000"), big.NewInt(7), mustInt("142857142857142857142857142857")},
+		{"under a unit", big.NewInt(6), big.NewInt(7), big.NewInt(0)},
+	})
+}
+
+func TestMulExp(t *testing.T) {
+	checkMantissa(t, MulExp, []mantissaCase{
+		{"exact", closeFactor, liquidationIncentive, big.NewInt(54e16)},
+		// 1.5 wei rounds half up, where truncating gives 1
+		{"half up", big.NewInt(3), closeFactor, big.NewInt(2)},
+		{"under half", big.NewInt(3), big.NewInt(1e17), big.NewInt(0)},
+		{"negative half up", big.NewInt(-3), closeFactor, big.NewInt(-2)},
+	})
+}
+
+func TestDivExp(t *testing.T) {
+	checkMantissa(t, DivExp, []mantissaCase{
+		// The numerator and denominator of liquidateCalculateSeizeTokens
+		// below
+		{"seize ratio", mustInt("1080000000000000000000000000000"), mustInt("400000000000000000000000000000"), big.NewInt(27e17)},
+		{"truncated", big.NewInt(1), big.NewInt(3), big.NewInt(333333333333333333)},
+	})
+}
+
+// TestSeizeTokens repeats the comptroller's liquidateCalculateSeizeTokens
+// for a 1000 USDC repay seizing cETH at the initial rate with ETH at
+// 2000 USD, prices scaled to 36 decimals less those of the asset:
+//
+//	numerator = mul_(Exp(incentive), Exp(priceBorrowed))
+//	denominator = mul_(Exp(priceCollateral), Exp(exchangeRate))
+//	seizeTokens = mul_ScalarTruncate(div_(numerator, denominator), repay)
+//
+// which is 27 cETH, worth the 1080 USD of an 8% incentive.
+func TestSeizeTokens(t *testing.T) {
+	priceBorrowed := mustInt("1000000000000000000000000000000")
+	priceCollateral := mustInt("2000000000000000000000")
+	repay := big.NewInt(1000e6)
+
+	numerator := MulScalarTruncate(new(big.Int), liquidationIncentive, priceBorrowed)
+	denominator := MulScalarTruncate(new(big.Int), priceCollateral, initialRate18)
+	ratio := DivExp(new(big.Int), numerator, denominator)
+	seized := MulScalarTruncate(new(big.Int), ratio, repay)
+	if seized.Cmp(big.NewInt(27e8)) != 0 {
+		t.Fatalf("expected 27 cETH seized, got %v", seized)
+	}
+	if underlying := MulScalarTruncate(new(big.Int), initialRate18, seized); underlying.Cmp(big.NewInt(54e16)) != 0 {
+		t.Fatalf("expected 0.54 ETH seized, got %v", underlying)
+	}
+}
+
+// evmProgram returns code computing body over the two words of its call
+// data and returning the result, as the protocol computes on-chain.
+func evmProgram(body ...byte) []byte {
+	code := []byte{
+		byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD),
+		byte(vm.PUSH1), 32, byte(vm.CALLDATALOAD),
+	}
+	code = append(code, body...)
+	return append(code,
+		byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	)
+}
+
+// pushScale pushes 1e18.
+var pushScale = []byte{byte(vm.PUSH8), 0x0d, 0xe0, 0xb6, 0xb3, 0xa7, 0x64, 0x00, 0x00}
+
+var evmPrograms = []struct {
+	name string
+	fn   func(z, a, b *big.Int) *big.Int
+	code []byte
+}{
+	// a*b/1e18
+	{"MulScalarTruncate", MulScalarTruncate, evmProgram(append(append([]byte{byte(vm.MUL)}, pushScale...), byte(vm.SWAP1), byte(vm.DIV))...)},
+	// a*1e18/b
+	{"DivScalarByExpTruncate", DivScalarByExpTruncate, evmProgram(append(append([]byte{byte(vm.SWAP1)}, pushScale...), byte(vm.MUL), byte(vm.DIV))...)},
+	// (a*b+0.5e18)/1e18
+	{"MulExp", MulExp, evmProgram(append(append([]byte{byte(vm.MUL), byte(vm.PUSH8), 0x06, 0xf0, 0x5b, 0x59, 0xd3, 0xb2, 0x00, 0x00, byte(vm.ADD)}, pushScale...), byte(vm.SWAP1), byte(vm.DIV))...)},
+	// a/b
+	{"DivScalar", DivScalar, evmProgram(byte(vm.SWAP1), byte(vm.DIV))},
+}
+
+// TestMatchesEVM checks the helpers against the same arithmetic run by
+// the EVM, for operands small enough not to overflow, as the protocol's
+// checked arithmetic reverts on overflow.
+func TestMatchesEVM(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	max := new(big.Int).Lsh(big.NewInt(1), 120)
+	for _, program := range evmPrograms {
+		for i := 0; i < 200; i++ {
+			a := new(big.Int).Rand(r, max)
+			b := new(big.Int).Rand(r, max)
+			// Mantissas of the magnitudes the protocol uses, and nonzero
+			// divisors
+			if i%2 == 0 {
+				b.Rsh(b, uint(r.Intn(120)))
+			}
+			b.Add(b, big.NewInt(1))
+			input := append(math.U256Bytes(new(big.Int).Set(a)), math.U256Bytes(new(big.Int).Set(b))...)
+			output, _, err := runtime.Execute(program.code, input, nil)
+			if err != nil {
+				t.Fatalf("%s: %v", program.name, err)
+			}
+			if expected, result := new(big.Int).SetBytes(output), program.fn(new(big.Int), a, b); result.Cmp(expected) != 0 {
+				t.Fatalf("%s(%v, %v): expected %v as on-chain, got %v", program.name, a, b, expected, result)
+			}
+		}
+	}
+}
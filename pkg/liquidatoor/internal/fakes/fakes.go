@@ -0,0 +1,158 @@
+// Package fakes provides hand-written in-memory implementations of the
+// contract interfaces used by the liquidatoor.
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Comptroller returns the configured values. Err, when set, is
+// returned by every method.
+type Comptroller struct {
+	Borrowers            []common.Address
+	Markets              []common.Address
+	OracleAddress        common.Address
+	CloseFactor          *big.Int
+	LiquidationIncentive *big.Int
+	SeizePaused          bool
+	// Seized by every liquidation, unless Seizes has the market it
+	// repays
+	SeizeTokens *big.Int
+	Seizes      map[common.Address]*big.Int
+	Err         error
+}
+
+func (c *Comptroller) GetAllBorrowers(*bind.CallOpts) ([]common.Address, error) {
+	return c.Borrowers, c.Err
+}
+
+func (c *Comptroller) GetAllMarkets(*bind.CallOpts) ([]common.Address, error) {
+	return c.Markets, c.Err
+}
+
+func (c *Comptroller) Oracle(*bind.CallOpts) (common.Address, error) {
+	return c.OracleAddress, c.Err
+}
+
+func (c *Comptroller) CloseFactorMantissa(*bind.CallOpts) (*big.Int, error) {
+	return c.CloseFactor, c.Err
+}
+
+func (c *Comptroller) LiquidationIncentiveMantissa(*bind.CallOpts) (*big.Int, error) {
+	return c.LiquidationIncentive, c.Err
+}
+
+func (c *Comptroller) SeizeGuardianPaused(*bind.CallOpts) (bool, error) {
+	return c.SeizePaused, c.Err
+}
+
+func (c *Comptroller) LiquidateCalculateSeizeTokens(_ *bind.CallOpts, borrowed, _ common.Address, _ *big.Int) (*big.Int, *big.Int, error) {
+	if seizeTokens, ok := c.Seizes[borrowed]; ok {
+		return new(big.Int), seizeTokens, c.Err
+	}
+	return new(big.Int), c.SeizeTokens, c.Err
+}
+
+// PriceOracle returns prices keyed by cToken.
+type PriceOracle struct {
+	Prices map[common.Address]*big.Int
+}
+
+func (o *PriceOracle) GetUnderlyingPrice(_ *bind.CallOpts, cToken common.Address) (*big.Int, error) {
+	price, ok := o.Prices[cToken]
+	if !ok {
+		return nil, fmt.Errorf("no price for %s", cToken)
+	}
+	return price, nil
+}
+
+// CToken returns balances keyed by account. A zero UnderlyingAddress
+// makes Underlying fail like native token markets do.
+type CToken struct {
+	Borrows           *big.Int
+	UnderlyingAddress common.Address
+	Balances          map[common.Address]*big.Int
+	BorrowBalances    map[common.Address]*big.Int
+	// cToken balances, and the exchange rate to the underlying scaled
+	// by 1e18
+	Tokens       map[common.Address]*big.Int
+	ExchangeRate *big.Int
+}
+
+func (c *CToken) TotalBorrows(*bind.CallOpts) (*big.Int, error) {
+	return c.Borrows, nil
+}
+
+func (c *CToken) Underlying(*bind.CallOpts) (common.Address, error) {
+	if c.UnderlyingAddress == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("execution reverted")
+	}
+	return c.UnderlyingAddress, nil
+}
+
+func (c *CToken) BalanceOfUnderlying(_ *bind.CallOpts, owner common.Address) (*big.Int, error) {
+	return balanceOf(c.Balances, owner), nil
+}
+
+func (c *CToken) BorrowBalanceStored(_ *bind.CallOpts, account common.Address) (*big.Int, error) {
+	return balanceOf(c.BorrowBalances, account), nil
+}
+
+func (c *CToken) BalanceOf(_ *bind.CallOpts, owner common.Address) (*big.Int, error) {
+	return balanceOf(c.Tokens, owner), nil
+}
+
+func (c *CToken) ExchangeRateStored(*bind.CallOpts) (*big.Int, error) {
+	return c.ExchangeRate, nil
+}
+
+func balanceOf(balances map[common.Address]*big.Int, account common.Address) *big.Int {
+	if balance, ok := balances[account]; ok {
+		return balance
+	}
+	return new(big.Int)
+}
+
+// Multicall answers every call through Handler and fails the whole
+// batch on the first error, like the legacy multicall does.
+type Multicall struct {
+	Block   *big.Int
+	Handler func(call abis.MulticallCall) ([]byte, error)
+}
+
+func (m *Multicall) Aggregate(_ *bind.CallOpts, calls []abis.MulticallCall) (struct {
+	BlockNumber *big.Int
+	ReturnData  [][]byte
+}, error) {
+	resp := struct {
+		BlockNumber *big.Int
+		ReturnData  [][]byte
+	}{BlockNumber: m.Block}
+
+	for _, call := range calls {
+		data, err := m.Handler(call)
+		if err != nil {
+			return resp, err
+		}
+		resp.ReturnData = append(resp.ReturnData, data)
+	}
+	return resp, nil
+}
+
+// Head is a fixed chain head.
+type Head uint64
+
+func (h Head) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		number = new(big.Int).SetUint64(uint64(h))
+	}
+	return &types.Header{Number: number}, nil
+}
@@ -0,0 +1,228 @@
+package fakes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrSubscriptionKilled fails the subscriptions killed by Logs.Kill.
+var ErrSubscriptionKilled = errors.New("subscription killed")
+
+// Logs is a chain of logs, served by FilterLogs and delivered to live
+// subscriptions as they are emitted. Subscriptions can be killed, and
+// refused until restored, like those of a node whose websocket drops.
+type Logs struct {
+	// Queries of more blocks, or returning more logs, fail with the
+	// errors nodes limit log queries with, if set
+	MaxRange   uint64
+	MaxResults int
+
+	lock sync.Mutex
+	head uint64
+	// In the order emitted, without the removed ones
+	logs []types.Log
+	subs map[*Subscription]bool
+	down bool
+	// Queries filtered, including failed ones
+	queries []ethereum.FilterQuery
+	// Signals a new subscription
+	subscribed chan struct{}
+}
+
+// NewLogs returns a chain at head without logs.
+func NewLogs(head uint64) *Logs {
+	return &Logs{head: head, subs: make(map[*Subscription]bool), subscribed: make(chan struct{}, 16)}
+}
+
+// Emit adds logs to the chain, moving the head to the block of the
+// last, and delivers them to the live subscriptions matching them.
+func (l *Logs) Emit(logs ...types.Log) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, log := range logs {
+		if log.BlockNumber > l.head {
+			l.head = log.BlockNumber
+		}
+		l.logs = append(l.logs, log)
+		l.deliver(log)
+	}
+}
+
+// Remove removes log from the chain, as a reorg does, and delivers it
+// with Removed set to the live subscriptions matching it.
+func (l *Logs) Remove(log types.Log) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for i := range l.logs {
+		if l.logs[i].BlockHash == log.BlockHash && l.logs[i].Index == log.Index {
+			l.logs = append(l.logs[:i], l.logs[i+1:]...)
+			break
+		}
+	}
+	log.Removed = true
+	l.deliver(log)
+}
+
+// Mine moves the head to block without logs.
+func (l *Logs) Mine(block uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if block > l.head {
+		l.head = block
+	}
+}
+
+func (l *Logs) deliver(log types.Log) {
+	for sub := range l.subs {
+		if matches(sub.query, log) {
+			select {
+			case sub.logs <- log:
+			case <-sub.done:
+			}
+		}
+	}
+}
+
+// Kill fails every live subscription with ErrSubscriptionKilled and
+// refuses new ones until Restore.
+func (l *Logs) Kill() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.down = true
+	for sub := range l.subs {
+		delete(l.subs, sub)
+		sub.err <- ErrSubscriptionKilled
+	}
+}
+
+// Restore accepts subscriptions again.
+func (l *Logs) Restore() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.down = false
+}
+
+// Subscribed returns a channel signalled on every new subscription.
+func (l *Logs) Subscribed() <-chan struct{} {
+	return l.subscribed
+}
+
+// Queries returns the queries filtered so far.
+func (l *Logs) Queries() []ethereum.FilterQuery {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]ethereum.FilterQuery(nil), l.queries...)
+}
+
+func (l *Logs) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		l.lock.Lock()
+		number = new(big.Int).SetUint64(l.head)
+		l.lock.Unlock()
+	}
+	return &types.Header{Number: number}, nil
+}
+
+// FilterLogs returns the logs of the blocks and addresses of query
+// whose first topic matches, in order. Only single blocks are queried
+// by hash, and only the first topics are matched.
+func (l *Logs) FilterLogs(_ context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.queries = append(l.queries, query)
+	from, to := uint64(0), l.head
+	if query.FromBlock != nil {
+		from = query.FromBlock.Uint64()
+	}
+	if query.ToBlock != nil {
+		to = query.ToBlock.Uint64()
+	}
+	if l.MaxRange > 0 && to-from+1 > l.MaxRange {
+		return nil, fmt.Errorf("query exceeds max block range %d", l.MaxRange)
+	}
+	logs := make([]types.Log, 0)
+	for _, log := range l.logs {
+		if log.BlockNumber >= from && log.BlockNumber <= to && matches(query, log) {
+			logs = append(logs, log)
+		}
+	}
+	if l.MaxResults > 0 && len(logs) > l.MaxResults {
+		return nil, fmt.Errorf("query returned more than %d results", l.MaxResults)
+	}
+	return logs, nil
+}
+
+func (l *Logs) SubscribeFilterLogs(_ context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.down {
+		return nil, ErrSubscriptionKilled
+	}
+	sub := &Subscription{logs: ch, query: query, err: make(chan error, 1), done: make(chan struct{}), parent: l}
+	l.subs[sub] = true
+	select {
+	case l.subscribed <- struct{}{}:
+	default:
+	}
+	return sub, nil
+}
+
+// Subscription is a live subscription to the logs of a query.
+type Subscription struct {
+	logs   chan<- types.Log
+	query  ethereum.FilterQuery
+	err    chan error
+	done   chan struct{}
+	once   sync.Once
+	parent *Logs
+}
+
+func (s *Subscription) Err() <-chan error {
+	return s.err
+}
+
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		// Deliveries blocked on the subscription give up first
+		close(s.done)
+		s.parent.lock.Lock()
+		delete(s.parent.subs, s)
+		s.parent.lock.Unlock()
+	})
+}
+
+// matches reports whether log is of the addresses and first topics of
+// query.
+func matches(query ethereum.FilterQuery, log types.Log) bool {
+	if len(query.Addresses) > 0 && !contains(query.Addresses, log.Address) {
+		return false
+	}
+	if len(query.Topics) == 0 || len(query.Topics[0]) == 0 {
+		return true
+	}
+	if len(log.Topics) == 0 {
+		return false
+	}
+	for _, topic := range query.Topics[0] {
+		if topic == log.Topics[0] {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+// Package store persists state files atomically, versioned and
+// checksummed, so a crash mid-write never leaves a file that cannot be
+// told apart from a valid one.
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// The file does not match its checksum or cannot be decoded
+	ErrCorrupt = errors.New("corrupt state file")
+	// The file was written by a later schema version
+	ErrFutureVersion = errors.New("state file from a future version")
+)
+
+// envelope wraps the data of every file. Files written before
+// envelopes are version 0.
+type envelope struct {
+	Version  int             `json:"version"`
+	Checksum string          `json:"checksum"`
+	Data     json.RawMessage `json:"data"`
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Write atomically replaces the file at path with v encoded as JSON
+// under schema version.
+func Write(path string, version int, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cannot encode %s: %w", path, err)
+	}
+	data, err = json.Marshal(envelope{Version: version, Checksum: checksum(data), Data: data})
+	if err != nil {
+		return fmt.Errorf("cannot encode %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot persist %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot persist %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot persist %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot persist %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("cannot persist %s: %w", path, err)
+	}
+	// The rename survives a crash once the directory is synced
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// Read decodes the file at path, written by Write with a version up to
+// the provided one, into v and returns its version. It returns false
+// if there is no file, and ErrCorrupt or ErrFutureVersion if it cannot
+// be trusted.
+func Read(path string, version int, v interface{}) (int, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	read, err := Decode(data, version, v)
+	if err != nil {
+		return 0, true, fmt.Errorf("%s: %w", path, err)
+	}
+	return read, true, nil
+}
+
+// Decode decodes the contents of a file written by Write with a
+// version up to the provided one into v, which may be nil to only
+// validate them, and returns their version.
+func Decode(data []byte, version int, v interface{}) (int, error) {
+	// Files written before envelopes are anything but an envelope
+	var e envelope
+	trimmed := bytes.TrimSpace(data)
+	legacy := len(trimmed) > 0 && trimmed[0] != '{'
+	if !legacy {
+		if err := json.Unmarshal(data, &e); err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		legacy = e.Version == 0 && e.Checksum == "" && e.Data == nil
+	}
+	if legacy {
+		e = envelope{Data: data}
+	} else if checksum(compact(e.Data)) != e.Checksum {
+		return 0, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	if e.Version > version {
+		return 0, fmt.Errorf("%w: version %d, expected up to %d", ErrFutureVersion, e.Version, version)
+	}
+	if v == nil {
+		return e.Version, nil
+	}
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return e.Version, nil
+}
+
+// compact undoes any reformatting of the data since it was written.
+func compact(data []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// Quarantine moves a file that cannot be trusted aside, so starting
+// over with empty state does not overwrite it, and returns its new
+// path.
+func Quarantine(path string) (string, error) {
+	moved := fmt.Sprintf("%s.corrupt-%d", path, time.Now().UnixNano())
+	if err := os.Rename(path, moved); err != nil {
+		return "", fmt.Errorf("cannot move %s aside: %w", path, err)
+	}
+	return moved, nil
+}
@@ -0,0 +1,441 @@
+package liquidatoor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// JournalSchemaVersion is the version of JournalEntry, bumped on
+// incompatible changes. Version 2 entries are JSON objects, one per
+// line, with:
+//
+//	v        schema version
+//	time     RFC 3339 time the event was recorded
+//	kind     one of the Journal* kinds below
+//	pool     comptroller or Comet address
+//	block    block number, if known
+//	account  borrower, for every kind but block
+//	tx       transaction hash, for submission and receipt
+//	decision for decision: liquidate or drop
+//	reason   for decision: the DropReason of dropped candidates
+//	err      error, if any
+//	data     kind-specific: JournalBlockData for block,
+//	         JournalCandidateData for candidate, the Explanation of
+//	         the candidate for decision, if any, and
+//	         JournalReceiptData for receipt
+//
+// Integers, such as blocks, amounts and values, are decimal strings, as
+// JSON numbers lose precision in decoders reading them as float64.
+// Version 1 entries encoded them as numbers.
+const JournalSchemaVersion = 2
+
+const (
+	JournalBlock      = "block"
+	JournalCandidate  = "candidate"
+	JournalDecision   = "decision"
+	JournalSubmission = "submission"
+	JournalReceipt    = "receipt"
+)
+
+const (
+	// Entries buffered before dropping
+	journalBuffer = 1024
+	// Size the journal is rotated at by default
+	defaultJournalMaxSize = 100 << 20
+	// Longest entry read back
+	journalMaxLine = 1 << 20
+)
+
+// JournalEntry is an event of the journal; see JournalSchemaVersion.
+type JournalEntry struct {
+	Version  int            `json:"v"`
+	Time     time.Time      `json:"time"`
+	Kind     string         `json:"kind"`
+	Pool     common.Address `json:"pool"`
+	Block    *big.Int       `json:"block,omitempty"`
+	Account  common.Address `json:"account"`
+	Tx       *common.Hash   `json:"tx,omitempty"`
+	Decision string         `json:"decision,omitempty"`
+	Reason   string         `json:"reason,omitempty"`
+	Err      string         `json:"err,omitempty"`
+	Data     interface{}    `json:"data,omitempty"`
+}
+
+// MarshalJSON encodes the block of the entry as decimal strings.
+func (e JournalEntry) MarshalJSON() ([]byte, error) {
+	type entry JournalEntry
+	return json.Marshal(struct {
+		entry
+		Block *decimalInt `json:"block,omitempty"`
+	}{entry(e), decimal(e.Block)})
+}
+
+func (e *JournalEntry) UnmarshalJSON(data []byte) error {
+	type entry JournalEntry
+	v := struct {
+		*entry
+		Block *decimalInt `json:"block,omitempty"`
+	}{entry: (*entry)(e)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	e.Block = v.Block.int()
+	return nil
+}
+
+// JournalBlockData summarizes the processing of a block.
+type JournalBlockData struct {
+	Borrowers    int            `json:"borrowers"`
+	Underwater   int            `json:"underwater"`
+	Planned      int            `json:"planned"`
+	Profitable   int            `json:"profitable"`
+	Liquidatable int            `json:"liquidatable"`
+	Dropped      map[string]int `json:"dropped,omitempty"`
+}
+
+// JournalCandidateData is what was known of a candidate when deciding.
+type JournalCandidateData struct {
+	Shortfall    *big.Int         `json:"shortfall,omitempty"`
+	HealthFactor *Ratio           `json:"healthFactor,omitempty"`
+	Plan         *LiquidationPlan `json:"plan,omitempty"`
+	Estimate     *ProfitEstimate  `json:"estimate,omitempty"`
+	Locked       bool             `json:"collateralLocked,omitempty"`
+	// What we know about the account, if annotated
+	Annotation *Annotation `json:"annotation,omitempty"`
+	// How the plan was adjusted to the cash of its collateral market
+	Redemption *Redemption `json:"redemption,omitempty"`
+}
+
+// MarshalJSON encodes the shortfall of the candidate as decimal strings.
+func (d JournalCandidateData) MarshalJSON() ([]byte, error) {
+	type candidateData JournalCandidateData
+	return json.Marshal(struct {
+		candidateData
+		Shortfall *decimalInt `json:"shortfall,omitempty"`
+	}{candidateData(d), decimal(d.Shortfall)})
+}
+
+func (d *JournalCandidateData) UnmarshalJSON(data []byte) error {
+	type candidateData JournalCandidateData
+	v := struct {
+		*candidateData
+		Shortfall *decimalInt `json:"shortfall,omitempty"`
+	}{candidateData: (*candidateData)(d)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	d.Shortfall = v.Shortfall.int()
+	return nil
+}
+
+// JournalReceiptData is the realized outcome of a mined transaction.
+type JournalReceiptData struct {
+	PnL         *big.Int             `json:"pnl"`
+	Swaps       []SwapRecord         `json:"swaps,omitempty"`
+	Liquidation *RealizedLiquidation `json:"liquidation,omitempty"`
+	Campaign    *Campaign            `json:"campaign,omitempty"`
+}
+
+// MarshalJSON encodes the profit of the receipt as decimal strings.
+func (d JournalReceiptData) MarshalJSON() ([]byte, error) {
+	type receiptData JournalReceiptData
+	return json.Marshal(struct {
+		receiptData
+		PnL *decimalInt `json:"pnl"`
+	}{receiptData(d), decimal(d.PnL)})
+}
+
+func (d *JournalReceiptData) UnmarshalJSON(data []byte) error {
+	type receiptData JournalReceiptData
+	v := struct {
+		*receiptData
+		PnL *decimalInt `json:"pnl"`
+	}{receiptData: (*receiptData)(d)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	d.PnL = v.PnL.int()
+	return nil
+}
+
+// Journal appends every decision and action to a JSONL file for
+// forensics, independently of logging. Recording never blocks: entries
+// are buffered and dropped, and counted, when the writer falls behind.
+// Submissions and receipts are synced to disk. The file is rotated to
+// a timestamped sibling once over its maximum size. A nil journal
+// records nothing.
+type Journal struct {
+	logger  Logger
+	path    string
+	maxSize int64
+
+	// Guards closing entries
+	lock    sync.RWMutex
+	closed  bool
+	entries chan JournalEntry
+	dropped uint64
+	done    chan struct{}
+
+	file *os.File
+	size int64
+}
+
+// OpenJournal opens the journal at path for appending.
+func OpenJournal(logger Logger, path string, maxSize int64) (*Journal, error) {
+	if maxSize <= 0 {
+		maxSize = defaultJournalMaxSize
+	}
+	j := &Journal{
+		logger:  logger,
+		path:    path,
+		maxSize: maxSize,
+		entries: make(chan JournalEntry, journalBuffer),
+		done:    make(chan struct{}),
+	}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	go j.run()
+	return j, nil
+}
+
+func (j *Journal) open() error {
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot open journal: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("cannot stat journal: %w", err)
+	}
+	j.file, j.size = file, info.Size()
+	// A crash mid-append leaves a torn last entry the next one must not
+	// be appended to
+	if complete, err := completeSize(j.path, j.size); err != nil || complete != j.size {
+		if err == nil {
+			err = file.Truncate(complete)
+		}
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("cannot truncate torn journal entry: %w", err)
+		}
+		j.logger.Warn(fmt.Sprintf("Truncated a torn entry of %d bytes off the journal", j.size-complete), F("path", j.path))
+		j.size = complete
+	}
+	return nil
+}
+
+// completeSize returns the size of the complete lines of the file at
+// path, of size.
+func completeSize(path string, size int64) (int64, error) {
+	if size == 0 {
+		return 0, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	offset := size - journalMaxLine
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, size-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil {
+		return 0, err
+	}
+	if tail[len(tail)-1] == '\n' {
+		return size, nil
+	}
+	return offset + int64(bytes.LastIndexByte(tail, '\n')+1), nil
+}
+
+// Record queues an entry, filling in its version and time.
+func (j *Journal) Record(entry JournalEntry) {
+	if j == nil {
+		return
+	}
+	entry.Version = JournalSchemaVersion
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	if j.closed {
+		atomic.AddUint64(&j.dropped, 1)
+		return
+	}
+	select {
+	case j.entries <- entry:
+	default:
+		atomic.AddUint64(&j.dropped, 1)
+	}
+}
+
+// Dropped returns the number of entries dropped so far.
+func (j *Journal) Dropped() uint64 {
+	if j == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&j.dropped)
+}
+
+// Close writes the buffered entries and closes the journal.
+func (j *Journal) Close() {
+	if j == nil {
+		return
+	}
+	j.lock.Lock()
+	if j.closed {
+		j.lock.Unlock()
+		return
+	}
+	j.closed = true
+	close(j.entries)
+	j.lock.Unlock()
+
+	<-j.done
+	if dropped := j.Dropped(); dropped > 0 {
+		j.logger.Warn(fmt.Sprintf("Dropped %d journal entries", dropped), F("dropped", dropped))
+	}
+}
+
+func (j *Journal) run() {
+	defer close(j.done)
+	defer j.file.Close()
+	for entry := range j.entries {
+		if err := j.write(entry); err != nil {
+			atomic.AddUint64(&j.dropped, 1)
+			j.logger.Error(fmt.Sprintf("Failed to write journal entry: %v", err), F("kind", entry.Kind), F("err", err))
+		}
+	}
+}
+
+func (j *Journal) write(entry JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("cannot encode journal entry: %w", err)
+	}
+	line = append(line, '\n')
+	if j.size > 0 && j.size+int64(len(line)) > j.maxSize {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("cannot write journal: %w", err)
+	}
+	if entry.Kind == JournalSubmission || entry.Kind == JournalReceipt {
+		if err := j.file.Sync(); err != nil {
+			return fmt.Errorf("cannot sync journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// rotate renames the journal after the current time, which sorts
+// rotated journals from oldest, and starts a new one.
+func (j *Journal) rotate() error {
+	if err := j.file.Close(); err != nil {
+		return fmt.Errorf("cannot close journal: %w", err)
+	}
+	rotated := fmt.Sprintf("%s.%d", j.path, time.Now().UnixNano())
+	if err := os.Rename(j.path, rotated); err != nil {
+		return fmt.Errorf("cannot rotate journal: %w", err)
+	}
+	j.logger.Info("Rotated journal to "+rotated, F("path", rotated))
+	return j.open()
+}
+
+// JournalTimeline reads the journal at path, including its rotated
+// journals, and returns the entries of account in the order they were
+// recorded, with the block entries of its pools in between.
+func JournalTimeline(path string, account common.Address) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	pools := make(map[common.Address]bool)
+	err := scanJournals(path, func(entry JournalEntry) error {
+		switch {
+		case entry.Account == account:
+			pools[entry.Pool] = true
+			entries = append(entries, entry)
+		case entry.Kind == JournalBlock:
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Only blocks of pools the account was seen in
+	timeline := entries[:0]
+	for _, entry := range entries {
+		if entry.Kind != JournalBlock || pools[entry.Pool] {
+			timeline = append(timeline, entry)
+		}
+	}
+	return timeline, nil
+}
+
+// scanJournals calls fn with every entry of the journal at path and
+// its rotated journals, in the order they were recorded.
+func scanJournals(path string, fn func(JournalEntry) error) error {
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return fmt.Errorf("cannot list rotated journals: %w", err)
+	}
+	sort.Strings(rotated)
+
+	for _, name := range append(rotated, path) {
+		if err := scanJournal(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanJournal(name string, fn func(JournalEntry) error) error {
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot open journal: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, journalMaxLine)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			return fmt.Errorf("cannot decode %s line %d: %w", name, line, err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("cannot read %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package liquidatoor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/store"
+)
+
+// Window realized losses are limited over
+const lossWindow = 24 * time.Hour
+
+// Outcome is the realized result of an execution.
+type Outcome struct {
+	Time    time.Time
+	Pool    common.Address
+	Account common.Address
+	Tx      common.Hash
+	// Profit net of gas, including the gas of failed transactions, in
+	// wei of the native token; negative for losses
+	PnL *big.Int
+	// PnL in USD when recorded, if the native token is priced; only
+	// reported
+	PnLUSD *float64 `json:",omitempty"`
+	// Swaps of the seized collateral, if any
+	Swaps []SwapRecord `json:",omitempty"`
+
+	// Block the candidate was planned in and its estimate, if known
+	Block     *big.Int        `json:",omitempty"`
+	Predicted *ProfitEstimate `json:",omitempty"`
+	// Parsed from the receipt of Compound liquidations
+	Liquidation *RealizedLiquidation `json:",omitempty"`
+	// Campaign and round of the transaction, if mined
+	Campaign *Campaign `json:",omitempty"`
+}
+
+// MarshalJSON encodes the profit and block of the outcome as decimal
+// strings.
+func (o Outcome) MarshalJSON() ([]byte, error) {
+	type outcome Outcome
+	return json.Marshal(struct {
+		outcome
+		PnL   *decimalInt
+		Block *decimalInt `json:",omitempty"`
+	}{outcome(o), decimal(o.PnL), decimal(o.Block)})
+}
+
+func (o *Outcome) UnmarshalJSON(data []byte) error {
+	type outcome Outcome
+	v := struct {
+		*outcome
+		PnL   *decimalInt
+		Block *decimalInt `json:",omitempty"`
+	}{outcome: (*outcome)(o)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	o.PnL, o.Block = v.PnL.int(), v.Block.int()
+	return nil
+}
+
+// Ledger records the outcomes of executions over the last day and
+// engages the kill switch once realized losses exceed the daily limit.
+// The kill switch stays engaged, across restarts if the ledger is
+// persisted, until it is reset. It is safe for concurrent use.
+type Ledger struct {
+	logger Logger
+	// Empty keeps the ledger in memory
+	path string
+	// In wei of the native token; nil is unlimited
+	limit *big.Int
+	// Renders outcomes in USD, if set
+	fiat *fiatReporter
+
+	lock  sync.Mutex
+	state ledgerState
+}
+
+type ledgerState struct {
+	// Oldest first
+	Outcomes []Outcome `json:"outcomes"`
+	Halted   bool      `json:"halted"`
+	HaltedAt time.Time `json:"haltedAt,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	// Losses before the last reset do not count towards the limit
+	ResetAt time.Time `json:"resetAt,omitempty"`
+}
+
+// OpenLedger loads the ledger persisted at path, if any.
+func OpenLedger(logger Logger, path string, limit *big.Int) (*Ledger, error) {
+	l := &Ledger{logger: logger, path: path, limit: limit}
+	if path == "" {
+		return l, nil
+	}
+	var state ledgerState
+	ok, err := loadState(logger, "ledger", path, ledgerVersion, &state)
+	if err != nil || !ok {
+		return l, err
+	}
+	l.state = state
+	if l.state.Halted {
+		l.logger.Error(fmt.Sprintf("Kill switch engaged since %v: %s", l.state.HaltedAt, l.state.Reason), F("reason", l.state.Reason))
+	}
+	return l, nil
+}
+
+// Record adds an outcome and engages the kill switch if the losses of
+// the last day exceed the limit.
+func (l *Ledger) Record(o Outcome) error {
+	if o.Time.IsZero() {
+		o.Time = time.Now()
+	}
+	if o.PnL == nil {
+		o.PnL = new(big.Int)
+	}
+	if o.PnLUSD == nil {
+		o.PnLUSD = l.fiat.nativeUSD(o.PnL)
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.state.Outcomes = append(l.state.Outcomes, o)
+	for _, swap := range o.Swaps {
+		l.logger.Info(fmt.Sprintf("Swapped %v of %s for %v of %s with %s slippage %s", swap.AmountIn, swap.TokenIn, swap.AmountOut, swap.TokenOut, swap.Class, mantissaRatio(swap.Slippage).Percent()),
+			F("pool", o.Pool), F("account", o.Account), F("tx", o.Tx), F("class", swap.Class), F("slippage", swap.Slippage))
+	}
+	pnl, losses := l.window(o.Time)
+	l.logger.Info(fmt.Sprintf("Execution for account %s realized %s%s; %s%s over the last day with %s%s of losses", o.Account,
+		formatValue(o.PnL), formatUSD(o.PnLUSD), formatValue(pnl), formatUSD(l.fiat.nativeUSD(pnl)), formatValue(losses), formatUSD(l.fiat.nativeUSD(losses))),
+		F("pool", o.Pool), F("account", o.Account), F("tx", o.Tx), F("pnl", o.PnL), F("pnlUsd", o.PnLUSD), F("dailyPnl", pnl), F("dailyLosses", losses))
+
+	if l.limit != nil && !l.state.Halted && GT(losses, l.limit) {
+		l.state.Halted = true
+		l.state.HaltedAt = o.Time
+		l.state.Reason = fmt.Sprintf("losses of %s over the last day exceed the limit of %s", formatValue(losses), formatValue(l.limit))
+		l.logger.Error(fmt.Sprintf("KILL SWITCH ENGAGED: %s; no liquidation is executed until it is reset", l.state.Reason),
+			F("dailyLosses", losses), F("limit", l.limit))
+	}
+	return l.persist()
+}
+
+// window forgets outcomes older than a day from now and returns the
+// net result and the losses of the rest.
+func (l *Ledger) window(now time.Time) (*big.Int, *big.Int) {
+	i := 0
+	for i < len(l.state.Outcomes) && now.Sub(l.state.Outcomes[i].Time) > lossWindow {
+		i++
+	}
+	l.state.Outcomes = l.state.Outcomes[i:]
+
+	pnl, losses := new(big.Int), new(big.Int)
+	for _, o := range l.state.Outcomes {
+		pnl.Add(pnl, o.PnL)
+		if IsNegative(o.PnL) && o.Time.After(l.state.ResetAt) {
+			losses.Sub(losses, o.PnL)
+		}
+	}
+	return pnl, losses
+}
+
+// Halted reports whether the kill switch is engaged, and why.
+func (l *Ledger) Halted() (bool, string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.state.Halted, l.state.Reason
+}
+
+// Reset disengages the kill switch. Losses already recorded no longer
+// count towards the limit.
+func (l *Ledger) Reset() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !l.state.Halted {
+		return nil
+	}
+	l.state.Halted, l.state.HaltedAt, l.state.Reason = false, time.Time{}, ""
+	l.state.ResetAt = time.Now()
+	l.logger.Info("Kill switch reset")
+	return l.persist()
+}
+
+// persist atomically replaces the persisted ledger.
+func (l *Ledger) persist() error {
+	if l.path == "" {
+		return nil
+	}
+	if err := store.Write(l.path, ledgerVersion, l.state); err != nil {
+		return fmt.Errorf("cannot persist ledger: %w", err)
+	}
+	return nil
+}
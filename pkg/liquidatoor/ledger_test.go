@@ -0,0 +1,171 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// lossStreak executes liquidations of an account one after the other,
+// each realizing the next of pnls, in wei.
+type lossStreak struct {
+	pnls     []int64
+	executed int
+}
+
+func (s *lossStreak) Execute(context.Context, Candidate) (*Outcome, error) {
+	pnl := s.pnls[s.executed]
+	s.executed++
+	outcome := &Outcome{Tx: common.BigToHash(big.NewInt(int64(s.executed))), PnL: big.NewInt(pnl)}
+	// Reverted transactions still cost their gas
+	if pnl < 0 && s.executed%2 == 0 {
+		return outcome, errors.New("execution reverted")
+	}
+	return outcome, nil
+}
+
+func quietLogger() Logger {
+	return &stdLogger{logger: log.New(io.Discard, "", 0)}
+}
+
+func TestLedgerLossStreakEngagesKillSwitch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	// 0.1 of the native token
+	ledger, err := OpenLedger(quietLogger(), path, big.NewInt(1e17))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	// Profits do not offset losses: 0.12 lost in four, over the limit on
+	// the last
+	for i, pnl := range []int64{-3e16, 5e17, -3e16, -3e16, -3e16} {
+		if err := ledger.Record(Outcome{Time: start.Add(time.Duration(i) * time.Minute), PnL: big.NewInt(pnl)}); err != nil {
+			t.Fatal(err)
+		}
+		halted, _ := ledger.Halted()
+		if expected := i == 4; halted != expected {
+			t.Fatalf("expected the kill switch engaged %v after outcome %d, got %v", expected, i, halted)
+		}
+	}
+	_, reason := ledger.Halted()
+	if !strings.Contains(reason, "0.12") || !strings.Contains(reason, "0.1") {
+		t.Fatalf("expected the losses and the limit in the reason, got %q", reason)
+	}
+
+	// Engaged across restarts until reset
+	ledger, err = OpenLedger(quietLogger(), path, big.NewInt(1e17))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if halted, _ := ledger.Halted(); !halted {
+		t.Fatal("expected the kill switch still engaged after reopening the ledger")
+	}
+	if err := ledger.Record(Outcome{PnL: big.NewInt(1e18)}); err != nil {
+		t.Fatal(err)
+	}
+	if halted, _ := ledger.Halted(); !halted {
+		t.Fatal("expected profits not to disengage the kill switch")
+	}
+	if err := ledger.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Losses before the reset no longer count, across restarts too
+	ledger, err = OpenLedger(quietLogger(), path, big.NewInt(1e17))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if halted, _ := ledger.Halted(); halted {
+		t.Fatal("expected the kill switch reset after reopening the ledger")
+	}
+	if err := ledger.Record(Outcome{PnL: big.NewInt(-9e16)}); err != nil {
+		t.Fatal(err)
+	}
+	if halted, _ := ledger.Halted(); halted {
+		t.Fatal("expected losses before the reset not to count")
+	}
+	if err := ledger.Record(Outcome{PnL: big.NewInt(-2e16)}); err != nil {
+		t.Fatal(err)
+	}
+	if halted, _ := ledger.Halted(); !halted {
+		t.Fatal("expected losses after the reset to engage the kill switch")
+	}
+}
+
+func TestLedgerForgetsLossesAfterADay(t *testing.T) {
+	ledger, err := OpenLedger(quietLogger(), "", big.NewInt(1e17))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0.04 lost every 13 hours never exceeds 0.1 within a day
+	start := time.Now().Add(-130 * time.Hour)
+	for i := 0; i < 10; i++ {
+		if err := ledger.Record(Outcome{Time: start.Add(time.Duration(i) * 13 * time.Hour), PnL: big.NewInt(-4e16)}); err != nil {
+			t.Fatal(err)
+		}
+		if halted, reason := ledger.Halted(); halted {
+			t.Fatalf("expected losses older than a day forgotten, halted after outcome %d: %s", i, reason)
+		}
+	}
+	// Three within a day exceed it
+	if err := ledger.Record(Outcome{Time: start.Add(122 * time.Hour), PnL: big.NewInt(-4e16)}); err != nil {
+		t.Fatal(err)
+	}
+	if halted, _ := ledger.Halted(); !halted {
+		t.Fatal("expected 0.12 lost within a day to engage the kill switch")
+	}
+}
+
+// TestExecutionStandsDownOnLossLimit executes a streak of small losses,
+// some reverted, through the queue, and checks execution stops once
+// they cross the limit.
+func TestExecutionStandsDownOnLossLimit(t *testing.T) {
+	logger := quietLogger()
+	ledger, err := OpenLedger(logger, "", big.NewInt(1e17))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewExecutionQueue(logger, 1, 10)
+	q.ledger = ledger
+	streak := &lossStreak{pnls: []int64{-2e16, -2e16, 1e16, -2e16, -3e16, -2e16, -1e16, -1e16}}
+	job := Job{Candidate: Candidate{Pool: common.HexToAddress("0xc0"), Account: common.HexToAddress("0x1000")}, Executor: streak}
+
+	// 0.11 lost on the sixth execution, including the gas of the
+	// reverted ones
+	for i := 0; i < 6; i++ {
+		outcome, _ := q.executeNow(context.Background(), job)
+		if outcome == nil {
+			t.Fatalf("expected execution %d to realize an outcome", i)
+		}
+	}
+	if halted, _ := ledger.Halted(); !halted {
+		t.Fatal("expected the kill switch engaged after losing 0.11")
+	}
+
+	// Nothing else is executed or queued
+	if _, err := q.executeNow(context.Background(), job); err == nil || !strings.Contains(err.Error(), "kill switch engaged") {
+		t.Fatalf("expected execution refused by the kill switch, got %v", err)
+	}
+	if q.Push(job) {
+		t.Fatal("expected the job not queued with the kill switch engaged")
+	}
+	if streak.executed != 6 {
+		t.Fatalf("expected 6 executions, got %d", streak.executed)
+	}
+
+	// Until reset
+	if err := ledger.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.executeNow(context.Background(), job); err != nil || streak.executed != 7 {
+		t.Fatalf("expected execution to resume after the reset, got %v", err)
+	}
+}
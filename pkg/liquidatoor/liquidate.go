@@ -0,0 +1,339 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/exp"
+)
+
+// LiquidateAccount liquidates an underwater borrower now, outside the
+// checks of new blocks. The liquidation is planned and checked as in
+// a check of the latest block, using the market state read by the
+// last check, and sent from the wallet. Borrowers in several markets
+// are liquidated in the pair SelectLiquidationPair selects, if any,
+// rather than as the strategy plans. It returns the hash of the
+// transaction without waiting for it to be mined; the transaction is
+// watched until the liquidatoor's context is done. If the borrower is
+// not liquidated, the error says why, eg., a LiquidationError with
+// ErrInsufficientInventory, including when the plan needs more than
+// the wallet holds and a flash liquidity source would fund the rest.
+func (l *Liquidatoor) LiquidateAccount(ctx context.Context, borrower Borrower) (common.Hash, error) {
+	if l.simulation != nil {
+		return common.Hash{}, fmt.Errorf("%w: ROLE %s cannot execute liquidations", ErrInvalidConfig, RoleSimulation)
+	}
+	l.checkLock.Lock()
+	defer l.checkLock.Unlock()
+	last := l.lastStart
+	if last == nil {
+		return common.Hash{}, ErrCacheNotPrimed
+	}
+	header, err := l.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("cannot get latest block: %w", err)
+	}
+	prices, err := pricesOf(ctx, l.logger, l.priceSource, l.assets(last.markets), nil)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("cannot get prices: %w", err)
+	}
+	start := &blockStart{
+		borrowers:     []Borrower{borrower},
+		markets:       last.markets,
+		prices:        prices,
+		averagePrices: last.averagePrices,
+		snapshot:      l.snapshot(header.Number, last.markets, prices, last),
+		inventory:     last.inventory,
+	}
+	if len(borrower.Assets) >= 2 {
+		pair, err := l.selectPair(ctx, start.snapshot, borrower.Address, borrower.Assets)
+		if err != nil {
+			l.logger.Info(fmt.Sprintf("Planning liquidation of account %s with strategy %s: %v", borrower.Address, l.strategy.Name(), err), F("pool", l.comptrollerAddress), F("account", borrower.Address), F("err", err))
+		} else {
+			start.pairs = map[common.Address]LiquidationPlan{borrower.Address: pair.plan(borrower.Address)}
+		}
+	}
+	f, err := l.evaluate(ctx, header.Number, start, []Borrower{borrower}, ExecuteNone)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	c := f.candidates[0]
+	if c.Err != nil {
+		return common.Hash{}, c.Err
+	}
+	tx, err := l.sendLiquidation(ctx, c)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	go l.pending.watch(l.lifetime, l.client, tx.Hash(), l.blockTime)
+	return tx.Hash(), nil
+}
+
+// Liquidate liquidates account in the pool of comptroller once, with
+// LiquidateAccount, and waits for the transaction to be mined or for
+// ctx to be cancelled, in which case it stays pending for the next
+// instance. It reads the start of the latest block first, as checks
+// do, and fails if account is not underwater. It is used instead of
+// Run, eg., to liquidate an account the borrower cache misses.
+func (c *Connection) Liquidate(ctx context.Context, comptroller, account common.Address) (*types.Receipt, error) {
+	l, err := c.NewLiquidatoor(ctx, comptroller)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate pool %s: %w", comptroller, err)
+	}
+	header, err := c.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get latest block: %w", err)
+	}
+	l.checkLock.Lock()
+	_, err = l.startBlock(ctx, header.Number)
+	l.checkLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	borrower, err := l.underwater(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	if borrower == nil {
+		return nil, fmt.Errorf("account %s is not underwater in pool %s", account, comptroller)
+	}
+	hash, err := l.LiquidateAccount(ctx, *borrower)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := c.pending.watch(ctx, c.client, hash, c.blockTime)
+	if err != nil {
+		return nil, fmt.Errorf("cannot wait for liquidation transaction %s: %w", hash, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return receipt, fmt.Errorf("liquidation transaction %s: %w", hash, ErrTxReverted)
+	}
+	return receipt, nil
+}
+
+// liquidate is the executor of ExecuteLiquidations: it sends the
+// liquidation of the candidate and waits for it to be mined.
+func (l *Liquidatoor) liquidate(ctx context.Context, c Candidate) (*Outcome, error) {
+	tx, err := l.sendLiquidation(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := bind.WaitMined(ctx, l.client, tx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot wait for liquidation transaction: %w", err)
+	}
+	outcome := &Outcome{Tx: tx.Hash()}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		paid, err := gasPaid(ctx, l.client, tx, receipt)
+		if err != nil {
+			return nil, err
+		}
+		outcome.PnL = paid.Neg(paid)
+		return outcome, fmt.Errorf("liquidation transaction %s: %w", tx.Hash(), ErrTxReverted)
+	}
+	return outcome, nil
+}
+
+// sendLiquidation sends liquidateBorrow for the plan of the candidate
+// from the wallet, repaying at most RepayAmount if the liquidation is
+// still profitable, once the wallet has approved the borrowed
+// underlying. Nothing is sent with
+// RoleSimulation.
+func (l *Liquidatoor) sendLiquidation(ctx context.Context, c Candidate) (*types.Transaction, error) {
+	if l.simulation != nil {
+		return nil, fmt.Errorf("%w: ROLE %s cannot execute liquidations", ErrInvalidConfig, RoleSimulation)
+	}
+	plan := c.Plan
+	if plan == nil {
+		return nil, fmt.Errorf("cannot liquidate account %s: %w", c.Account, errNoPlan)
+	}
+	borrow := l.underlyingInfo[plan.BorrowMarket.String()]
+	fields := []Field{F("pool", l.comptrollerAddress), F("account", c.Account), F("market", plan.BorrowMarket)}
+	repayAmount, err := l.RepayAmount(ctx, plan.Borrower, plan.BorrowMarket)
+	if err != nil {
+		return nil, err
+	}
+	if !IsPositive(repayAmount) {
+		l.logger.Info(fmt.Sprintf("Nothing of the %s loan of account %s can be repaid from the wallet; skipping liquidation", borrow.name, c.Account), fields...)
+		return nil, l.sendError(c, ErrInsufficientInventory)
+	}
+	// With flash liquidity, plans are not checked against the inventory,
+	// and the wallet cannot take the flash loan funding the rest
+	if GT(plan.RepayAmount, repayAmount) && l.flashLiquidity != nil {
+		l.logger.Info(fmt.Sprintf("Only %v of the %v %s planned for account %s can be repaid from the wallet, which takes no flash loans; skipping liquidation", repayAmount, plan.RepayAmount, borrow.name, c.Account), fields...)
+		return nil, l.sendError(c, ErrInsufficientInventory)
+	}
+	if GT(plan.RepayAmount, repayAmount) {
+		l.logger.Info(fmt.Sprintf("Repaying %v %s of account %s rather than the %v planned, under the close factor and the wallet balance", repayAmount, borrow.name, c.Account, plan.RepayAmount), append(fields, F("planned", plan.RepayAmount), F("repay", repayAmount))...)
+		if err := l.checkCapped(ctx, c, repayAmount); err != nil {
+			return nil, err
+		}
+	} else {
+		repayAmount = plan.RepayAmount
+	}
+	call, err := l.adapter.RepayCall(RepayParams{
+		Borrower:         plan.Borrower,
+		CTokenBorrowed:   plan.BorrowMarket,
+		CTokenCollateral: plan.CollateralMarket,
+		RepayAmount:      repayAmount,
+		Native:           borrow.native,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot build repay call: %w", err)
+	}
+	if !borrow.native {
+		if err := l.approveUnderlying(ctx, borrow, call.To, repayAmount); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := sendCall(ctx, l.client, l.TxOpts, l.gasCap, l.pending, l.accessLists, call)
+	if err != nil {
+		return nil, fmt.Errorf("cannot send liquidation transaction: %w", err)
+	}
+	hash := tx.Hash()
+	l.logger.Info(fmt.Sprintf("Liquidation transaction for account %s: %s", c.Account, l.presenter.TxURL(hash)), append(fields, F("tx", hash))...)
+	submission := JournalEntry{Kind: JournalSubmission, Pool: l.comptrollerAddress, Account: c.Account, Tx: &hash}
+	l.journal.Record(submission)
+	l.publisher.publish(c, submission)
+	l.pending.sent(tx, l.comptrollerAddress, c.Account, plan.BorrowMarket)
+	return tx, nil
+}
+
+// checkCapped estimates the plan of the candidate again repaying only
+// repayAmount, and fails unless it is still profitable. Candidates
+// without the snapshot of their plan, eg., decoded ones, cannot be
+// estimated again, so they fail too.
+func (l *Liquidatoor) checkCapped(ctx context.Context, c Candidate, repayAmount *big.Int) error {
+	s := c.snapshot
+	if s == nil {
+		l.logger.Info(fmt.Sprintf("Cannot estimate the smaller liquidation of account %s; skipping liquidation", c.Account), F("pool", l.comptrollerAddress), F("account", c.Account))
+		return l.sendError(c, ErrInsufficientInventory)
+	}
+	borrow := s.Markets[c.Plan.BorrowMarket]
+	capped := *c.Plan
+	capped.RepayAmount = repayAmount
+	capped.RepayValue = costOf(borrow, repayAmount)
+	capped.SeizeValue = seizeValueOf(s, borrow, repayAmount)
+	estimate, err := l.profitEstimator.Estimate(ctx, capped, s)
+	if err != nil {
+		return fmt.Errorf("cannot estimate profit of liquidation of account %s: %w", c.Account, err)
+	}
+	if !estimate.Profitable() {
+		l.logger.Info(fmt.Sprintf("Smaller liquidation of account %s is unprofitable: %s; skipping liquidation", c.Account, estimate), F("pool", l.comptrollerAddress), F("account", c.Account))
+		return l.sendError(c, ErrUnprofitable)
+	}
+	return nil
+}
+
+// RepayAmount returns the most of the loan of borrower in market that
+// can be repaid from the wallet: the close factor of the pool of the
+// borrow balance, up to the wallet balance of the underlying. A
+// liquidation repaying more reverts, so none is sent when it is zero.
+func (l *Liquidatoor) RepayAmount(ctx context.Context, borrower, market common.Address) (*big.Int, error) {
+	cToken, ok := l.LendMarkets[market.String()]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a market of pool %s", market, l.comptrollerAddress)
+	}
+	borrowBalance, err := cToken.BorrowBalanceStored(&bind.CallOpts{Context: ctx}, borrower)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get borrow balance of account %s: %w", borrower, err)
+	}
+	balance, err := l.walletBalance(ctx, l.underlyingInfo[market.String()])
+	if err != nil {
+		return nil, err
+	}
+	return maxRepayAmount(l.liquidationParams().closeFactor, borrowBalance, balance), nil
+}
+
+// maxRepayAmount returns closeFactor, scaled by 1e18, of borrowBalance,
+// up to balance.
+func maxRepayAmount(closeFactor, borrowBalance, balance *big.Int) *big.Int {
+	amount := exp.MulScalarTruncate(new(big.Int), closeFactor, borrowBalance)
+	if GT(amount, balance) {
+		amount.Set(balance)
+	}
+	return amount
+}
+
+// walletBalance returns the balance of the wallet in the underlying.
+func (l *Liquidatoor) walletBalance(ctx context.Context, info UnderlyingInfo) (*big.Int, error) {
+	if info.native {
+		balance, err := l.client.BalanceAt(ctx, l.TxOpts.From, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get native balance: %w", err)
+		}
+		return balance, nil
+	}
+	underlying, err := abis.NewCToken(info.address, l.client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get interface for %s: %w", info.name, err)
+	}
+	balance, err := underlying.BalanceOf(&bind.CallOpts{Context: ctx}, l.TxOpts.From)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get %s balance: %w", info.name, err)
+	}
+	return balance, nil
+}
+
+func (l *Liquidatoor) sendError(c Candidate, err error) error {
+	return &LiquidationError{Block: c.Block, Pool: l.comptrollerAddress, Borrower: c.Account, Market: c.Plan.BorrowMarket, Err: err}
+}
+
+// approveUnderlying approves spender for amount of the underlying, unless
+// it already is, and waits for the approval to be mined. A lower
+// allowance left over, eg., by a liquidation that reverted, is reset to
+// zero first, as tokens like USDT refuse to change an allowance that is
+// not zero.
+func (l *Liquidatoor) approveUnderlying(ctx context.Context, info UnderlyingInfo, spender common.Address, amount *big.Int) error {
+	underlying, err := abis.NewCToken(info.address, l.client)
+	if err != nil {
+		return fmt.Errorf("cannot get interface for %s: %w", info.name, err)
+	}
+	allowance, err := underlying.Allowance(&bind.CallOpts{Context: ctx}, l.TxOpts.From, spender)
+	if err != nil {
+		return fmt.Errorf("cannot get %s allowance: %w", info.name, err)
+	}
+	if GTE(allowance, amount) {
+		return nil
+	}
+
+	if IsPositive(allowance) {
+		l.logger.Info(fmt.Sprintf("Resetting %s allowance of %s from %v", info.name, spender, allowance),
+			F("pool", l.comptrollerAddress), F("spender", spender), F("allowance", allowance))
+		if err := l.approve(ctx, underlying, info, spender, new(big.Int)); err != nil {
+			return err
+		}
+	}
+	return l.approve(ctx, underlying, info, spender, amount)
+}
+
+// approve sets the allowance of spender to amount of the underlying and
+// waits for the approval to be mined.
+func (l *Liquidatoor) approve(ctx context.Context, underlying *abis.CToken, info UnderlyingInfo, spender common.Address, amount *big.Int) error {
+	txOpts, err := l.gasCap.transactOpts(ctx, l.client, l.TxOpts)
+	if err != nil {
+		return err
+	}
+	if err := l.pending.assign(ctx, l.client, txOpts); err != nil {
+		return err
+	}
+	tx, err := underlying.Approve(txOpts, spender, amount)
+	l.pending.release(txOpts, err)
+	if err != nil {
+		return fmt.Errorf("cannot approve %s: %w", info.name, err)
+	}
+	receipt, err := bind.WaitMined(ctx, l.client, tx)
+	if err != nil {
+		return fmt.Errorf("cannot wait for approval: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("approval %s: %w", tx.Hash(), ErrTxReverted)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/fakes"
+)
+
+func TestMaxRepayAmount(t *testing.T) {
+	borrow := big.NewInt(1000)
+	for _, tc := range []struct {
+		name        string
+		closeFactor *big.Int
+		balance     *big.Int
+		expected    *big.Int
+	}{
+		{"zero close factor", big.NewInt(0), big.NewInt(10000), big.NewInt(0)},
+		{"full close factor", big.NewInt(1e18), big.NewInt(10000), big.NewInt(1000)},
+		{"half close factor", big.NewInt(5e17), big.NewInt(10000), big.NewInt(500)},
+		{"balance below close factor amount", big.NewInt(5e17), big.NewInt(300), big.NewInt(300)},
+		{"balance at close factor amount", big.NewInt(5e17), big.NewInt(500), big.NewInt(500)},
+		{"balance above close factor amount", big.NewInt(5e17), big.NewInt(501), big.NewInt(500)},
+		{"zero balance", big.NewInt(5e17), big.NewInt(0), big.NewInt(0)},
+		{"rounds down", big.NewInt(333333333333333333), big.NewInt(10000), big.NewInt(333)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if amount := maxRepayAmount(tc.closeFactor, borrow, tc.balance); amount.Cmp(tc.expected) != 0 {
+				t.Errorf("expected %v, got %v", tc.expected, amount)
+			}
+		})
+	}
+}
+
+// walletBackend reports a native balance and records the transactions
+// sent; every other call panics.
+type walletBackend struct {
+	Backend
+	balance *big.Int
+	sent    []*types.Transaction
+}
+
+func (b *walletBackend) BalanceAt(context.Context, common.Address, *big.Int) (*big.Int, error) {
+	return b.balance, nil
+}
+
+func (b *walletBackend) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+// walletLiquidatoor is a pool with a native market, borrowed by
+// borrower, and a collateral market, liquidated from a wallet holding
+// balance.
+func walletLiquidatoor(t *testing.T, borrower common.Address, borrowBalance, balance *big.Int) (*Liquidatoor, *walletBackend) {
+	t.Helper()
+	client := &walletBackend{balance: balance}
+	adapter, err := newCompoundProtocolAdapter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Liquidatoor{
+		client:             client,
+		logger:             NewStdLogger(),
+		TxOpts:             &bind.TransactOpts{From: common.HexToAddress("0xfeed")},
+		comptrollerAddress: common.HexToAddress("0xc0"),
+		adapter:            adapter,
+		LendMarkets: map[string]CToken{
+			common.HexToAddress("0xa").String(): &fakes.CToken{BorrowBalances: map[common.Address]*big.Int{borrower: borrowBalance}},
+			common.HexToAddress("0xb").String(): &fakes.CToken{},
+		},
+		underlyingInfo: map[string]UnderlyingInfo{
+			common.HexToAddress("0xa").String(): {name: "ETH", decimals: 18, native: true},
+		},
+	}
+	l.params.Store(&liquidationParams{closeFactor: big.NewInt(5e17), incentive: big.NewInt(108e16)})
+	return l, client
+}
+
+func TestSendLiquidationSkipsZeroRepayAmount(t *testing.T) {
+	borrower := common.HexToAddress("0x1000")
+	plan := &LiquidationPlan{Borrower: borrower, BorrowMarket: common.HexToAddress("0xa"), CollateralMarket: common.HexToAddress("0xb"), RepayAmount: big.NewInt(500)}
+	for _, tc := range []struct {
+		name                   string
+		borrowBalance, balance *big.Int
+	}{
+		{"empty wallet", big.NewInt(1000), big.NewInt(0)},
+		{"nothing borrowed", big.NewInt(0), big.NewInt(1e18)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l, client := walletLiquidatoor(t, borrower, tc.borrowBalance, tc.balance)
+			amount, err := l.RepayAmount(context.Background(), borrower, plan.BorrowMarket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if amount.Sign() != 0 {
+				t.Fatalf("expected a zero repay amount, got %v", amount)
+			}
+			tx, err := l.sendLiquidation(context.Background(), Candidate{Account: borrower, Plan: plan})
+			if !errors.Is(err, ErrInsufficientInventory) {
+				t.Fatalf("expected ErrInsufficientInventory, got %v", err)
+			}
+			if tx != nil || len(client.sent) != 0 {
+				t.Fatalf("expected nothing sent, got %d transactions", len(client.sent))
+			}
+		})
+	}
+}
+
+func TestSendLiquidationRefusesFlashFundedPlans(t *testing.T) {
+	borrower := common.HexToAddress("0x1000")
+	plan := &LiquidationPlan{Borrower: borrower, BorrowMarket: common.HexToAddress("0xa"), CollateralMarket: common.HexToAddress("0xb"), RepayAmount: big.NewInt(500)}
+	// The plan was not checked against the 300 in the wallet
+	l, client := walletLiquidatoor(t, borrower, big.NewInt(1000), big.NewInt(300))
+	l.flashLiquidity = flashLender{}
+	tx, err := l.sendLiquidation(context.Background(), Candidate{Account: borrower, Plan: plan})
+	if !errors.Is(err, ErrInsufficientInventory) {
+		t.Fatalf("expected ErrInsufficientInventory, got %v", err)
+	}
+	if tx != nil || len(client.sent) != 0 {
+		t.Fatalf("expected nothing sent, got %d transactions", len(client.sent))
+	}
+}
+
+func TestSendLiquidationEstimatesCappedPlans(t *testing.T) {
+	borrower := common.HexToAddress("0x1000")
+	plan := &LiquidationPlan{Borrower: borrower, BorrowMarket: common.HexToAddress("0xa"), CollateralMarket: common.HexToAddress("0xb"), RepayAmount: big.NewInt(500)}
+	s := &Snapshot{
+		Markets:              map[common.Address]MarketSnapshot{plan.BorrowMarket: {Price: big.NewInt(1e18)}},
+		LiquidationIncentive: big.NewInt(108e16),
+	}
+	for _, tc := range []struct {
+		name      string
+		snapshot  *Snapshot
+		estimated int
+		expected  error
+	}{
+		// The gas of the smaller liquidation exceeds its incentive
+		{"unprofitable", s, 1, ErrUnprofitable},
+		{"no snapshot", nil, 0, ErrInsufficientInventory},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The wallet repays 300 of the 500 planned
+			l, client := walletLiquidatoor(t, borrower, big.NewInt(1000), big.NewInt(300))
+			estimator := &incentiveEstimator{net: big.NewInt(-1)}
+			l.profitEstimator = estimator
+			tx, err := l.sendLiquidation(context.Background(), Candidate{Account: borrower, Plan: plan, snapshot: tc.snapshot})
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, err)
+			}
+			if estimator.estimated != tc.estimated {
+				t.Fatalf("expected %d estimates, got %d", tc.estimated, estimator.estimated)
+			}
+			if tx != nil || len(client.sent) != 0 {
+				t.Fatalf("expected nothing sent, got %d transactions", len(client.sent))
+			}
+		})
+	}
+}
+
+// allowanceBackend is a token that, like USDT, reverts approvals that
+// change an allowance that is not zero, and mines every transaction
+// right away.
+type allowanceBackend struct {
+	Backend
+	t         *testing.T
+	allowance *big.Int
+	approvals []*big.Int
+	receipts  map[common.Hash]*types.Receipt
+}
+
+func (b *allowanceBackend) CallContract(context.Context, ethereum.CallMsg, *big.Int) ([]byte, error) {
+	return common.LeftPadBytes(b.allowance.Bytes(), 32), nil
+}
+
+func (b *allowanceBackend) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return uint64(len(b.approvals)), nil
+}
+
+func (b *allowanceBackend) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	args, err := cTokenABI.Methods["approve"].Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	amount := args[1].(*big.Int)
+	b.approvals = append(b.approvals, amount)
+	receipt := &types.Receipt{TxHash: tx.Hash(), Status: types.ReceiptStatusFailed}
+	if IsZero(b.allowance) || IsZero(amount) {
+		b.allowance = amount
+		receipt.Status = types.ReceiptStatusSuccessful
+	}
+	b.receipts[tx.Hash()] = receipt
+	return nil
+}
+
+func (b *allowanceBackend) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	return b.receipts[hash], nil
+}
+
+func TestApproveUnderlyingResetsAllowance(t *testing.T) {
+	spender, amount := common.HexToAddress("0xa"), big.NewInt(500)
+	for _, tc := range []struct {
+		name      string
+		allowance *big.Int
+		expected  []*big.Int
+	}{
+		{"enough allowance", big.NewInt(500), nil},
+		{"no allowance", big.NewInt(0), []*big.Int{amount}},
+		{"lower allowance", big.NewInt(100), []*big.Int{big.NewInt(0), amount}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &allowanceBackend{t: t, allowance: tc.allowance, receipts: make(map[common.Hash]*types.Receipt)}
+			l := &Liquidatoor{
+				client: client,
+				logger: quietLogger(),
+				gasCap: &gasCap{},
+				TxOpts: &bind.TransactOpts{
+					From:     common.HexToAddress("0xfeed"),
+					GasPrice: big.NewInt(1),
+					GasLimit: 100000,
+					Signer:   func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) { return tx, nil },
+				},
+			}
+			info := UnderlyingInfo{address: common.HexToAddress("0xdac17"), name: "USDT", decimals: 6}
+			if err := l.approveUnderlying(context.Background(), info, spender, amount); err != nil {
+				t.Fatal(err)
+			}
+			if len(client.approvals) != len(tc.expected) {
+				t.Fatalf("expected approvals %v, got %v", tc.expected, client.approvals)
+			}
+			for i, approval := range client.approvals {
+				if !Equal(approval, tc.expected[i]) {
+					t.Fatalf("expected approvals %v, got %v", tc.expected, client.approvals)
+				}
+			}
+			if !GTE(client.allowance, amount) {
+				t.Fatalf("expected an allowance of %v, got %v", amount, client.allowance)
+			}
+		})
+	}
+}
@@ -2,110 +2,223 @@ package liquidatoor
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"errors"
 	"fmt"
-	"log"
 	"math/big"
-	"os"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/kargakis/liquidatoor/pkg/abis"
 )
 
 type Liquidatoor struct {
 	// Node connection
-	client *ethclient.Client
-	// Blockchain explorer URL
-	explorerURL string
+	client  Backend
+	chainID *big.Int
+	// Renders addresses and links to the blockchain explorer
+	presenter *Presenter
+	// Average time between blocks
+	blockTime time.Duration
+	// Symbol of the native token
+	nativeSymbol string
 	// TODO: Figure out whether it is faster to always
 	// instantiate this vs deep-copying to avoid mutations
 	// or whether we don't care about mutations as these
 	// will always be in specific fields, ie., gas stuff
 	TxOpts *bind.TransactOpts
+	logger Logger
 
 	// Contracts
-	Multicall          *abis.Multicall
-	Comptroller        *abis.Comptroller
-	Oracle             *abis.PriceOracle
-	BorrowMarkets      map[string]*abis.CToken
-	LendMarkets        map[string]*abis.CToken
+	Batcher            CallBatcher
+	l1FeeEstimator     L1FeeEstimator
+	gasCap             *gasCap
+	accessLists        *accessLists
+	flashLiquidity     FlashLiquiditySource
+	Comptroller        Comptroller
+	priceSource        PriceSource
+	priceGuard         *priceGuard
+	BorrowMarkets      map[string]CToken
+	LendMarkets        map[string]CToken
 	comptrollerAddress common.Address
 	comptrollerABI     *abi.ABI
+	capabilities       Capabilities
+	adapter            ProtocolAdapter
+	strategy           Strategy
+	shadowStrategies   []Strategy
+	profitEstimator    ProfitEstimator
+	// Shared with the connection; see SetMaxCandidates
+	maxCandidates *int64
+	executor      Executor
+	queue         *ExecutionQueue
+	// Our liquidations in flight, shared with the connection
+	pending *pendingTxs
+	// The context the liquidatoor was created with, which outlives the
+	// calls sending transactions that are watched until mined
+	lifetime context.Context
+	// While transfers are paused
+	transferPausedPolicy string
+	// Limits collateral swaps of flash loan liquidations
+	slippage   *slippagePolicy
+	swapQuoter SwapQuoter
+	// Realized over predicted values warned about, scaled by 1e18
+	outcomeDriftTolerance *big.Int
+	// Decisions and actions, if journaled
+	journal *Journal
+	// Candidates and their outcomes, if published
+	publisher *publisher
+	// Shared by every pool of the connection
+	readiness *readiness
+	// Pending liquidations by others, if monitored
+	mempool    *mempoolWatch
+	simulation *simulation
+	// Last block the simulation observed liquidations up to
+	observedBlock          uint64
+	standDownOnCompetition bool
+	annotations            *Annotations
+
+	// *liquidationParams, replaced on events
+	params       atomic.Value
+	paramsReadAt uint64
+	// Replaced on ActionPaused events
+	pauses *pauseState
+	// map[common.Address]marketTotals, replaced with the market state
+	totals atomic.Value
+	// Summarizes the risk of the pool every check
+	risk *riskMonitor
+	// Prices the unit of account of the oracle every check, for reports
+	fiat *fiatReporter
+	// Replays plans before they are executed, if set
+	fork *forkValidator
+	// Accounts alerted illiquid, until they are not, under checkLock
+	illiquidCollateralAlert Ratio
+	illiquidAccounts        map[common.Address]bool
+	// Collateral value underwater accounts are bad debt at or under
+	badDebtDust *big.Int
+	// Campaign of the next round of accounts being evaluated again
+	// after a liquidation, under checkLock
+	campaigns map[common.Address]Campaign
+	// Recheck the accounts of their markets on emission
+	priceUpdateEvents []PriceUpdateEvent
 
 	borrowerCacheInterval time.Duration
 	borrowerCache         *BorrowerCache
+	// Updates the borrower cache between refreshes
+	borrowerEvents *borrowerEvents
+	// Filters the logs of the pool while processing blocks
+	logs *logBackfill
+	// Follows the events of the pool
+	bus *eventBus
 
 	underlyingInfo map[string]UnderlyingInfo
-}
-
-var (
-	noOpts = new(bind.CallOpts)
-	zero   = big.NewInt(0)
-)
 
-func New() (*Liquidatoor, error) {
-	// Instantiate liquidatoor
-	l := &Liquidatoor{
-		BorrowMarkets:  make(map[string]*abis.CToken),
-		LendMarkets:    make(map[string]*abis.CToken),
-		underlyingInfo: make(map[string]UnderlyingInfo),
-	}
+	// Buffers reused by shortfall checks across blocks
+	checkLock      sync.Mutex
+	liquidityCalls []abis.MulticallCall
+	// Fallback for legs of the next block start that fail
+	lastStart *blockStart
+	// What checks do with their liquidatable candidates
+	execution BlockExecution
+	// Nil unless only changed accounts are checked between full scans
+	delta *deltaTracker
+	// Borrowers ordered by how close they are to liquidation
+	watchlist *watchlist
+	// Admin and implementation of the comptroller
+	governance *governanceWatch
+	whitelist  *whitelistCheck
+}
 
-	// Run validations
-	if err := l.validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
-	}
+var zero = big.NewInt(0)
 
-	// Connect to node
-	// TODO: Make timeout configurable
-	client, err := ethclient.Dial(os.Getenv("NODE_API_URL"))
+// New connects using the environment and instantiates a liquidatoor
+// for the first configured comptroller.
+func New(ctx context.Context) (*Liquidatoor, error) {
+	cfg, err := ConfigFromEnv()
 	if err != nil {
-		return nil, fmt.Errorf("cannot connect to node: %w", err)
+		return nil, err
 	}
-	l.client = client
-
-	chainID, err := client.NetworkID(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("cannot get chain id: %w", err)
+	if len(cfg.Comptrollers) == 0 {
+		return nil, fmt.Errorf("%w: COMPTROLLER_ADDRESS cannot be empty", ErrInvalidConfig)
 	}
-	fmt.Println("Chain ID:", chainID)
 
-	// Load private key
-	privateKey, err := crypto.HexToECDSA(os.Getenv("PRIVATE_KEY"))
+	conn, err := Connect(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("cannot load private key: %w", err)
+		return nil, err
 	}
 
-	// Extract address
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("cannot cast public key to ECDSA")
-	}
-	address := crypto.PubkeyToAddress(*publicKeyECDSA)
-	fmt.Printf("Liquidatoor address: %s/address/%s\n", l.explorerURL, address)
+	return conn.NewLiquidatoor(ctx, cfg.Comptrollers[0])
+}
 
-	txOpts, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
-	if err != nil {
-		return nil, fmt.Errorf("cannot create authorized transactor: %w", err)
-	}
-	l.TxOpts = txOpts
+// NewLiquidatoor instantiates a liquidatoor for the pool governed
+// by the provided comptroller, reusing the connection.
+func (c *Connection) NewLiquidatoor(ctx context.Context, comptrollerAddress common.Address) (*Liquidatoor, error) {
+	start := time.Now()
+	opts := &bind.CallOpts{Context: ctx}
 
-	// Instantiate multicall contract
-	multicall, err := abis.NewMulticall(common.HexToAddress(os.Getenv("MULTICALL_ADDRESS")), client)
-	if err != nil {
-		return nil, fmt.Errorf("cannot instantiate multicall: %w", err)
+	// Instantiate liquidatoor
+	l := &Liquidatoor{
+		client:                 c.client,
+		chainID:                c.chainID,
+		l1FeeEstimator:         c.l1FeeEstimator,
+		gasCap:                 c.gasCap,
+		accessLists:            c.accessLists,
+		flashLiquidity:         c.flashLiquidity,
+		presenter:              c.presenter,
+		blockTime:              c.blockTime,
+		nativeSymbol:           c.nativeSymbol,
+		TxOpts:                 c.TxOpts,
+		logger:                 c.logger,
+		Batcher:                c.budget.batcher(budgetKey{chain: c.config.Chain, pool: comptrollerAddress}, c.readEndpoint, c.client, c.Batcher, c.batchSize),
+		BorrowMarkets:          make(map[string]CToken),
+		LendMarkets:            make(map[string]CToken),
+		comptrollerAddress:     comptrollerAddress,
+		borrowerCacheInterval:  c.borrowerCacheInterval,
+		strategy:               c.strategy,
+		shadowStrategies:       c.config.ShadowStrategies,
+		profitEstimator:        c.config.ProfitEstimator,
+		maxCandidates:          c.maxCandidates,
+		executor:               c.config.Executor,
+		queue:                  c.queue,
+		pending:                c.pending,
+		lifetime:               ctx,
+		slippage:               newSlippagePolicy(c.config.SlippageLimits, c.config.TokenClasses),
+		swapQuoter:             c.config.SwapQuoter,
+		journal:                c.journal,
+		publisher:              c.publisher,
+		readiness:              c.readiness,
+		outcomeDriftTolerance:  c.config.OutcomeDriftTolerance,
+		mempool:                c.mempool,
+		simulation:             c.simulation,
+		standDownOnCompetition: c.config.StandDownOnCompetition,
+		annotations:            c.annotations,
+		watchlist:              newWatchlist(),
+		pauses:                 newPauseState(),
+		risk:                   newRiskMonitor(c.logger, comptrollerAddress, c.config.RiskShortfallAlert, c.config.RiskConcentrationAlert),
+		fiat:                   c.fiat,
+		fork:                   c.fork,
+		illiquidAccounts:       make(map[common.Address]bool),
+		campaigns:              make(map[common.Address]Campaign),
+		priceUpdateEvents:      c.config.PriceUpdateEvents,
+		bus:                    c.bus,
 	}
-	l.Multicall = multicall
+	if l.executor == nil && c.config.ExecuteLiquidations {
+		l.executor = ExecutorFunc(l.liquidate)
+		// The wallet repays from its inventory, so plans are checked
+		// against it rather than funded by flash loans
+		if l.flashLiquidity != nil {
+			l.logger.Warn(fmt.Sprintf("Not using %s flash liquidity, as liquidations are sent from the wallet without an executor", l.flashLiquidity.Name()), F("pool", l.comptrollerAddress), F("source", l.flashLiquidity.Name()))
+			l.flashLiquidity = nil
+		}
+	}
+	client := c.client
+	l.logs = newLogBackfill(l.logger, client, c.logLimiter, c.borrowerScanBlockRange, blockLogRetries)
 
 	// Instantiate comptroller
 	comptroller, err := abis.NewComptroller(l.comptrollerAddress, client)
@@ -114,317 +227,835 @@ func New() (*Liquidatoor, error) {
 	}
 	l.Comptroller = comptroller
 
-	oracle, err := comptroller.Oracle(noOpts)
-	if err != nil {
+	if _, err := comptroller.Oracle(opts); err != nil {
 		return nil, fmt.Errorf("cannot fetch price oracle: %w", err)
 	}
-	l.Oracle, err = abis.NewPriceOracle(oracle, client)
-	if err != nil {
-		return nil, fmt.Errorf("cannot instantiate price oracle: %w", err)
-	}
+	l.priceSource = c.priceSource(comptroller)
+	l.priceGuard = c.priceGuard()
 
 	abi, err := abis.ComptrollerMetaData.GetAbi()
 	if err != nil {
 		return nil, fmt.Errorf("cannot get comptroller ABI: %w", err)
 	}
 	l.comptrollerABI = abi
+	if l.outcomeDriftTolerance == nil {
+		l.outcomeDriftTolerance = defaultOutcomeDriftTolerance
+	}
+	l.badDebtDust = c.config.BadDebtDust
+	if l.badDebtDust == nil {
+		l.badDebtDust = defaultBadDebtDust
+	}
+	l.illiquidCollateralAlert = defaultIlliquidCollateralAlert
+	if c.config.IlliquidCollateralAlert != nil {
+		l.illiquidCollateralAlert = *c.config.IlliquidCollateralAlert
+	}
+	l.transferPausedPolicy = c.config.TransferPausedPolicies[l.comptrollerAddress]
+	if l.transferPausedPolicy == "" {
+		l.transferPausedPolicy = TransferPausedDeprioritize
+	}
+	l.governance = newGovernanceWatch(l.logger, l.comptrollerAddress, abi, c.config.GovernanceCheckInterval, c.config.PauseOnGovernanceChange)
+	ignoreWhitelist := false
+	for _, pool := range c.config.IgnoreWhitelistPools {
+		ignoreWhitelist = ignoreWhitelist || pool == l.comptrollerAddress
+	}
+	if l.whitelist, err = newWhitelistCheck(l.logger, l.comptrollerAddress, l.TxOpts.From, abi, ignoreWhitelist); err != nil {
+		return nil, err
+	}
+	resp, err := l.Batcher.Aggregate(opts, l.whitelist.calls())
+	if err != nil {
+		return nil, fmt.Errorf("cannot check whitelist: %w", err)
+	}
+	l.whitelist.observe(resp)
+	if c.config.FullScanInterval > 0 {
+		if l.delta, err = newDeltaTracker(c.config.FullScanInterval, c.config.ForceScanInterval, abi); err != nil {
+			return nil, err
+		}
+	}
+
+	l.capabilities = probeCapabilities(ctx, l.logger, client, l.comptrollerAddress, abi, capabilityOverrides(c.config, l.comptrollerAddress))
+	l.logger.Info(fmt.Sprintf("Comptroller %s capabilities: %s", l.comptrollerAddress, l.capabilities), F("pool", l.comptrollerAddress))
+	l.risk.capabilities = &l.capabilities
+
+	adapter, err := newProtocolAdapter(c.adapterName, c, comptroller)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate protocol adapter: %w", err)
+	}
+	l.adapter = adapter
+	if l.profitEstimator == nil {
+		l.profitEstimator = newOracleProfitEstimator(l)
+	}
+
+	if !l.capabilities.CloseFactorMantissa || !l.capabilities.LiquidationIncentiveMantissa {
+		return nil, fmt.Errorf("comptroller %s lacks the liquidation parameter getters: %s", l.comptrollerAddress, l.capabilities)
+	}
+	closeFactor, liquidationIncentive, err := adapter.LiquidationParams(opts)
+	if err != nil {
+		return nil, err
+	}
+	l.setLiquidationParams(&liquidationParams{closeFactor: closeFactor, incentive: liquidationIncentive}, "startup")
 
 	// Instantiate markets
-	markets, err := comptroller.GetAllMarkets(noOpts)
+	markets, err := readMarkets(opts, client, l.comptrollerAddress, abi, comptroller, l.capabilities)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get markets: %w", err)
 	}
 
-	for _, market := range markets {
-		cToken, err := abis.NewCToken(market, client)
-		if err != nil {
-			return nil, fmt.Errorf("cannot get CToken for market %s: %w", market, err)
-		}
-		borrows, err := cToken.TotalBorrows(noOpts)
+	l.borrowerCache = NewBorrowerCache(l.logger, l.borrowerCacheInterval, l.Batcher, l.comptrollerAddress, comptroller, abi)
+	l.borrowerCache.store = c.borrowerStore
+	l.borrowerCache.shard = c.shard
+	l.borrowerCache.accounts = c.accountList
+	if !l.capabilities.GetAllBorrowers && c.accountList == nil {
+		scanner, err := newBorrowerScanner(l.logger, client, c.logLimiter, markets, c.borrowerScanStartBlock, c.borrowerScanBlockRange)
 		if err != nil {
-			return nil, fmt.Errorf("cannot read total borrows for CToken %s: %w", market, err)
-		}
-		if borrows.Cmp(zero) == 1 {
-			l.BorrowMarkets[market.String()] = cToken
+			return nil, err
 		}
-		l.LendMarkets[market.String()] = cToken
+		scanner.shard = c.shard
+		l.borrowerCache.scanner = scanner
 	}
-	if err := l.getUnderlyingInfo(); err != nil {
+	if l.borrowerEvents, err = newBorrowerEvents(abi); err != nil {
 		return nil, err
 	}
 
-	l.prettyPrintMarkets()
+	// Load market and underlying metadata while priming the borrower
+	// cache. Errors are reported in stage order to be deterministic.
+	var underlyingInfo map[string]UnderlyingInfo
+	errs := make([]error, 2)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		errs[0] = l.loadMarkets(gctx, markets)
+		return errs[0]
+	})
+	g.Go(func() error {
+		underlyingInfo, errs[1] = l.getUnderlyingInfo(gctx, markets)
+		return errs[1]
+	})
+	g.Go(func() error {
+		// Failing to prime the cache is not fatal as it is retried
+		l.borrowerCache.Prime(gctx)
+		return nil
+	})
+	g.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.underlyingInfo = underlyingInfo
+	l.presenter.labelMarkets(underlyingInfo)
+	l.mempool.watch(markets)
+	if err := l.reconcilePauses(ctx, nil, "startup"); err != nil {
+		return nil, fmt.Errorf("cannot read pauses: %w", err)
+	}
 
-	// Start borrower cache in a separate thread
-	l.borrowerCache = NewBorrowerCache(l.borrowerCacheInterval, multicall, comptroller, abi)
-	go l.borrowerCache.Init()
+	l.prettyPrintMarkets(ctx)
 
+	l.logger.Info(fmt.Sprintf("Loaded %d markets of comptroller %s in %v", len(markets), l.comptrollerAddress, time.Since(start)),
+		F("pool", l.comptrollerAddress), F("markets", len(markets)), F("duration", time.Since(start)))
 	return l, nil
 }
 
-func (l *Liquidatoor) validate() error {
-	explorerURL := os.Getenv("BLOCKCHAIN_EXPLORER_URL")
-	if explorerURL == "" {
-		return errors.New("BLOCKCHAIN_EXPLORER_URL cannot be empty")
+// AcknowledgeGovernanceChange resumes liquidations held since a change
+// of the admin or implementation of the comptroller, and returns how
+// many changes were acknowledged.
+func (l *Liquidatoor) AcknowledgeGovernanceChange() int {
+	return l.governance.acknowledge()
+}
+
+// loadMarkets sorts markets into lend and borrow markets.
+func (l *Liquidatoor) loadMarkets(ctx context.Context, markets []common.Address) error {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return fmt.Errorf("cannot get ctoken ABI: %w", err)
 	}
-	l.explorerURL = explorerURL
+	totalBorrowsMethod := cTokenABI.Methods["totalBorrows"]
 
-	if os.Getenv("BORROWER_CACHE_INTERVAL") == "" {
-		return errors.New("BORROWER_CACHE_INTERVAL cannot be empty")
+	calls := make([]abis.MulticallCall, 0, len(markets))
+	for _, market := range markets {
+		calls = append(calls, abis.MulticallCall{Target: market, CallData: totalBorrowsMethod.ID})
 	}
-	borrowerCacheInterval, err := time.ParseDuration(os.Getenv("BORROWER_CACHE_INTERVAL"))
+	resp, err := l.Batcher.Aggregate(&bind.CallOpts{Context: ctx}, calls)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed batch request: %v", err)
 	}
-	l.borrowerCacheInterval = borrowerCacheInterval
 
-	comptrollerAddress := os.Getenv("COMPTROLLER_ADDRESS")
-	if comptrollerAddress == "" {
-		return errors.New("COMPTROLLER_ADDRESS cannot be empty")
+	for i, market := range markets {
+		cToken, err := abis.NewCToken(market, l.client)
+		if err != nil {
+			return fmt.Errorf("cannot get CToken for market %s: %w", market, err)
+		}
+		if !resp[i].Success {
+			return fmt.Errorf("cannot read total borrows for CToken %s: call reverted", market)
+		}
+		var borrows *big.Int
+		if err := cTokenABI.UnpackIntoInterface(&borrows, totalBorrowsMethod.Name, resp[i].ReturnData); err != nil {
+			return fmt.Errorf("cannot read total borrows for CToken %s: %w", market, err)
+		}
+		if IsPositive(borrows) {
+			l.BorrowMarkets[market.String()] = cToken
+		}
+		l.LendMarkets[market.String()] = cToken
 	}
-	l.comptrollerAddress = common.HexToAddress(comptrollerAddress)
+	return nil
+}
 
-	if os.Getenv("PRIVATE_KEY") == "" {
-		return errors.New("PRIVATE_KEY cannot be empty")
+// getUnderlyingInfo returns the underlying of every market, by market.
+func (l *Liquidatoor) getUnderlyingInfo(ctx context.Context, markets []common.Address) (map[string]UnderlyingInfo, error) {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
 	}
+	underlyingMethod := cTokenABI.Methods["underlying"]
+	nameMethod := cTokenABI.Methods["name"]
+	decimalsMethod := cTokenABI.Methods["decimals"]
+	opts := &bind.CallOpts{Context: ctx}
 
-	if os.Getenv("MULTICALL_ADDRESS") == "" {
-		return errors.New("MULTICALL_ADDRESS cannot be empty")
+	calls := make([]abis.MulticallCall, 0, len(markets))
+	for _, market := range markets {
+		calls = append(calls, abis.MulticallCall{Target: market, CallData: underlyingMethod.ID})
 	}
-
-	if os.Getenv("NODE_API_URL") == "" {
-		return errors.New("NODE_API_URL cannot be empty")
+	resp, err := l.Batcher.Aggregate(opts, calls)
+	if err != nil {
+		return nil, fmt.Errorf("failed batch request: %v", err)
 	}
 
-	return nil
-}
+	info := make(map[string]UnderlyingInfo, len(markets))
+	underlyings := make([]common.Address, 0, len(markets))
+	erc20Markets := make([]common.Address, 0, len(markets))
+	for i, market := range markets {
+		var underlying common.Address
+		if !resp[i].Success || cTokenABI.UnpackIntoInterface(&underlying, underlyingMethod.Name, resp[i].ReturnData) != nil {
+			// Native token markets, eg., cETH, have no underlying
+			l.logger.Warn(fmt.Sprintf("Cannot get underlying for market %s, assuming a native token market", market), F("pool", l.comptrollerAddress), F("market", market))
+			info[market.String()] = UnderlyingInfo{name: l.nativeSymbol, decimals: 18, native: true}
+			continue
+		}
+		underlyings = append(underlyings, underlying)
+		erc20Markets = append(erc20Markets, market)
+	}
 
-func (l *Liquidatoor) getAccountLiquidityMethod() abi.Method {
-	return l.comptrollerABI.Methods["getAccountLiquidity"]
-}
+	calls = make([]abis.MulticallCall, 0, 2*len(underlyings))
+	for _, underlying := range underlyings {
+		calls = append(calls,
+			abis.MulticallCall{Target: underlying, CallData: nameMethod.ID},
+			abis.MulticallCall{Target: underlying, CallData: decimalsMethod.ID},
+		)
+	}
+	resp, err = l.Batcher.Aggregate(opts, calls)
+	if err != nil {
+		return nil, fmt.Errorf("failed batch request: %v", err)
+	}
 
-func (l *Liquidatoor) getUnderlyingInfo() error {
-	for address, market := range l.LendMarkets {
-		underlying, err := market.Underlying(noOpts)
-		if err != nil {
-			return fmt.Errorf("cannot get underlying: %w", err)
+	for i, underlying := range underlyings {
+		nameResult, decimalsResult := resp[2*i], resp[2*i+1]
+		var name string
+		if !nameResult.Success {
+			return nil, fmt.Errorf("cannot get name for underlying %s of market %s: call reverted", underlying, erc20Markets[i])
 		}
-
-		erc20, err := abis.NewCToken(underlying, l.client)
-		if err != nil {
-			return fmt.Errorf("cannot get interface for underlying %s: %w", underlying, err)
+		if err := cTokenABI.UnpackIntoInterface(&name, nameMethod.Name, nameResult.ReturnData); err != nil {
+			return nil, fmt.Errorf("cannot get name for underlying %s of market %s: %w", underlying, erc20Markets[i], err)
 		}
-
-		name, err := erc20.Name(noOpts)
-		if err != nil {
-			return fmt.Errorf("cannot get name for underlying %s: %w", underlying, err)
+		var decimals uint8
+		if !decimalsResult.Success {
+			return nil, fmt.Errorf("cannot get decimals for underlying %s of market %s: call reverted", underlying, erc20Markets[i])
 		}
-		decimals, err := erc20.Decimals(noOpts)
-		if err != nil {
-			return fmt.Errorf("cannot get decimals for underlying %s: %w", underlying, err)
+		if err := cTokenABI.UnpackIntoInterface(&decimals, decimalsMethod.Name, decimalsResult.ReturnData); err != nil {
+			return nil, fmt.Errorf("cannot get decimals for underlying %s of market %s: %w", underlying, erc20Markets[i], err)
 		}
-		l.underlyingInfo[address] = UnderlyingInfo{name: name, decimals: decimals}
+		info[erc20Markets[i].String()] = UnderlyingInfo{address: underlying, name: name, decimals: decimals}
 	}
-	return nil
+	return info, nil
 }
 
-func (l *Liquidatoor) prettyPrintMarkets() {
+func (l *Liquidatoor) prettyPrintMarkets(ctx context.Context) {
 	if len(l.LendMarkets) == 0 {
 		return
 	}
 
 	cTokenABI, err := abis.CTokenMetaData.GetAbi()
 	if err != nil {
-		log.Printf("Failed to get ctoken ABI: %v", err)
-		return
-	}
-
-	priceOracleABI, err := abis.PriceOracleMetaData.GetAbi()
-	if err != nil {
-		log.Printf("Failed to get price oracle ABI: %v", err)
+		l.logger.Error(fmt.Sprintf("Failed to get ctoken ABI: %v", err), F("err", err))
 		return
 	}
 
 	calls := []abis.MulticallCall{}
 	symbolMethod := cTokenABI.Methods["symbol"]
-	getPriceMethod := priceOracleABI.Methods["getUnderlyingPrice"]
-
-	oracle, _ := l.Comptroller.Oracle(noOpts)
-
+	markets := make([]common.Address, 0, len(l.LendMarkets))
 	for address := range l.LendMarkets {
+		markets = append(markets, common.HexToAddress(address))
 		calls = append(calls, abis.MulticallCall{
 			Target:   common.HexToAddress(address),
 			CallData: symbolMethod.ID,
 		})
-		inputs, err := getPriceMethod.Inputs.Pack(common.HexToAddress(address))
-		if err != nil {
-			log.Printf("cannot pack cToken: %v", err)
-			return
-		}
-		calls = append(calls, abis.MulticallCall{
-			Target:   oracle,
-			CallData: append(getPriceMethod.ID[:], inputs[:]...),
-		})
 	}
 
-	resp, err := l.Multicall.Aggregate(noOpts, calls)
+	resp, err := l.Batcher.Aggregate(&bind.CallOpts{Context: ctx}, calls)
+	if err != nil {
+		l.logger.Error(fmt.Sprintf("Failed multicall request to get symbols: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		return
+	}
+	prices, err := pricesOf(ctx, l.logger, l.priceSource, l.assets(markets), nil)
 	if err != nil {
-		log.Printf("Failed multicall request to get symbols: %v", err)
+		l.logger.Error(fmt.Sprintf("Failed to get prices: %v", err), F("pool", l.comptrollerAddress), F("err", err))
 		return
 	}
 
-	fmt.Println()
-	fmt.Println("MARKETS")
-	for i, data := range resp.ReturnData {
-		if i%2 == 0 {
-			out, err := symbolMethod.Outputs.Unpack(data)
-			if err != nil {
-				log.Printf("Failed to unpack symbol output: %v", err)
-				return
-			}
-			symbol := *abi.ConvertType(out[0], new(string)).(*string)
-			fmt.Printf("- %s/address/%s (%s)\n", l.explorerURL, calls[i].Target, symbol)
-		} else {
-			out, err := getPriceMethod.Outputs.Unpack(data)
+	l.logger.Info("MARKETS", F("pool", l.comptrollerAddress))
+	for i, result := range resp {
+		symbol := "unknown"
+		if result.Success {
+			out, err := symbolMethod.Outputs.Unpack(result.ReturnData)
 			if err != nil {
-				log.Printf("Failed to unpack price output: %v", err)
+				l.logger.Error(fmt.Sprintf("Failed to unpack symbol output: %v", err), F("err", err))
 				return
 			}
-			price := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
-			fmt.Printf("  Price: %v\n", price)
+			symbol = *abi.ConvertType(out[0], new(string)).(*string)
 		}
+		price := "unknown"
+		if prices[i] != nil {
+			// Oracle prices of tokens of different decimals are scaled
+			// differently; report the price of a whole unit
+			price = formatValue(UnitPrice(prices[i].Mantissa, l.underlyingInfo[markets[i].String()].decimals))
+		}
+		l.logger.Info(fmt.Sprintf("- %s\n  Price: %s", l.presenter.Link(calls[i].Target), price),
+			F("pool", l.comptrollerAddress), F("market", calls[i].Target), F("symbol", symbol), F("price", price))
 	}
-	fmt.Println()
 }
 
-func (l *Liquidatoor) SubscribeToBlocks() {
-	headers := make(chan *types.Header)
-	sub, err := l.client.SubscribeNewHead(context.Background(), headers)
+// SubscribeToBlocks processes every new block and keeps the borrower
+// cache up to date until ctx is cancelled.
+func (l *Liquidatoor) SubscribeToBlocks(ctx context.Context) error {
+	l.Start(ctx)
+	return subscribeToBlocks(ctx, l.logger, l.client, l.blockTime, func(ctx context.Context, header *types.Header) {
+		l.ProcessBlock(ctx, header)
+	})
+}
+
+// ShortfallCheck checks every cached borrower at the latest block.
+// It fails with ErrCacheNotPrimed until the borrower cache is primed.
+func (l *Liquidatoor) ShortfallCheck(ctx context.Context) error {
+	return l.shortfallCheck(ctx, nil, &BlockResult{Pool: l.comptrollerAddress})
+}
+
+// shortfallCheck checks every cached borrower while processing the
+// provided block, if known, recording what it found and did in result.
+func (l *Liquidatoor) shortfallCheck(ctx context.Context, block *big.Int, result *BlockResult) error {
+	l.logger.Info("Starting shortfall checks...", F("pool", l.comptrollerAddress))
+
+	l.checkLock.Lock()
+	defer l.checkLock.Unlock()
+
+	l.followBorrowers(ctx, block)
+	start, err := l.startBlock(ctx, block)
 	if err != nil {
-		log.Fatalf("Failed to subscribe to headers: %v", err)
+		return err
 	}
+	borrowers := start.borrowers
+	result.Borrowers, result.Errors, result.Timings = len(borrowers), start.errs, start.timings
+	l.logger.Info(fmt.Sprintf("Number of borrowers: %d", len(borrowers)), F("pool", l.comptrollerAddress), F("borrowers", len(borrowers)))
 
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Printf("Got subscription error: %v", err)
+	if len(borrowers) == 0 {
+		if !l.borrowerCache.Primed() {
+			return &LiquidationError{Block: block, Pool: l.comptrollerAddress, Err: ErrCacheNotPrimed}
+		}
+		// Pools, or shards, without borrowers are checked in full
+		l.logger.Info("No borrowers to check; shortfall check complete.", F("pool", l.comptrollerAddress), F("block", block))
+		return nil
+	}
 
-		case header := <-headers:
-			log.Printf("Processing block %d", header.Number.Uint64())
+	// Fetch all borrowers liquidity, reusing the calls of the previous
+	// block and the calldata packed by the borrower cache
+	began := time.Now()
+	scan, err := l.accountLiquidities(ctx, block, start)
+	result.Timings.Liquidities = time.Since(began)
+	if err != nil {
+		return err
+	}
+	if skip, reason := l.delta.skipBlock(scan); skip {
+		l.logger.Info(fmt.Sprintf("No-op block %v: %s", block, reason), F("pool", l.comptrollerAddress), F("block", block), F("reason", reason))
+		result.SkipReason = reason
+		return nil
+	}
+	liquidities := scan.results
 
-			// TODO: Avoid processing when in-flight check is in progress
-			if err := l.ShortfallCheck(); err != nil {
-				log.Printf("Failed shortfall check: %v", err)
-			}
+	for i, liquidity := range liquidities {
+		if liquidity == nil {
+			l.logger.Warn(fmt.Sprintf("Failed to get account %s liquidity", borrowers[i].Address), F("pool", l.comptrollerAddress), F("account", borrowers[i].Address))
+			result.Errors = append(result.Errors, fmt.Errorf("cannot get liquidity of account %s", borrowers[i].Address.Hex()))
+			continue
+		}
+		if liquidity.failed() {
+			failure := liquidity.failure()
+			l.logger.Warn(fmt.Sprintf("contract error while getting account %s liquidity: %s", borrowers[i], failure), F("pool", l.comptrollerAddress), F("account", borrowers[i].Address), F("code", failure.Error), F("failure", failure.String()))
+			result.Errors = append(result.Errors, fmt.Errorf("cannot get liquidity of account %s: %s", borrowers[i].Address.Hex(), failure))
 		}
 	}
-}
 
-func (l *Liquidatoor) ShortfallCheck() error {
-	log.Println("Starting shortfall checks...")
+	// Underwater accounts by decreasing shortfall
+	l.watchlist.sync(borrowers, liquidities, scan.full)
+	underwaterAccounts := l.watchlist.underwater()
+	l.fiat.observe(start.snapshot)
+	l.queue.alerts.observe(l.comptrollerAddress, underwaterAccounts, l.health(borrowers))
 
-	borrowers := l.borrowerCache.Read()
-	log.Printf("Number of borrowers: %d", len(borrowers))
+	result.Underwater = len(underwaterAccounts)
+	began = time.Now()
+	f, err := l.evaluate(ctx, block, start, underwaterAccounts, l.execution)
+	result.Timings.Evaluation = time.Since(began)
+	if err != nil {
+		return err
+	}
+	result.Candidates, result.Executions = f.candidates, f.executions
+	l.risk.observe(start.snapshot, borrowers, liquidities, l.closestSensitivities(ctx, start.snapshot))
+	l.logger.Info(fmt.Sprintf("Funnel: %d borrowers, %d underwater, %d planned, %d profitable, %d liquidatable; dropped %s",
+		len(borrowers), len(underwaterAccounts), f.planned, f.profitable, f.liquidatable, formatDropped(f.dropped)),
+		F("pool", l.comptrollerAddress), F("block", block), F("borrowers", len(borrowers)), F("underwater", len(underwaterAccounts)),
+		F("planned", f.planned), F("profitable", f.profitable), F("liquidatable", f.liquidatable), F("dropped", f.dropped))
+	l.journal.Record(JournalEntry{Kind: JournalBlock, Pool: l.comptrollerAddress, Block: block, Data: JournalBlockData{
+		Borrowers: len(borrowers), Underwater: len(underwaterAccounts), Planned: f.planned, Profitable: f.profitable, Liquidatable: f.liquidatable, Dropped: f.dropped,
+	}})
 
-	if len(borrowers) == 0 {
-		// Ignore if the cache is not primed yet
-		log.Println("Empty borrower cache; aborting shortfall check")
-		return nil
+	l.logger.Info("Shortfall check complete.", F("pool", l.comptrollerAddress))
+
+	return nil
+}
+
+// funnel counts the underwater accounts of a check by how far they
+// got.
+type funnel struct {
+	planned, profitable, liquidatable int
+	dropped                           map[string]int
+	// In the order evaluated
+	candidates []Candidate
+	executions []BlockExecutionResult
+}
+
+// evaluate plans the liquidations of the underwater accounts of a
+// check, reports them and executes the liquidatable ones as execution
+// says.
+func (l *Liquidatoor) evaluate(ctx context.Context, block *big.Int, start *blockStart, underwaterAccounts []Borrower, execution BlockExecution) (*funnel, error) {
+	candidates := make(map[common.Address]Candidate, len(underwaterAccounts))
+	for _, acc := range underwaterAccounts {
+		candidates[acc.Address] = Candidate{
+			Pool:        l.comptrollerAddress,
+			Protocol:    l.adapter.Name(),
+			Account:     acc.Address,
+			Shortfall:   acc.Shortfall,
+			Block:       block,
+			Annotation:  l.annotations.annotation(acc.Address),
+			Explanation: &Explanation{},
+		}
+	}
+	if len(underwaterAccounts) > 0 {
+		if err := l.plan(ctx, start, underwaterAccounts, candidates); err != nil {
+			return nil, err
+		}
 	}
 
-	// Fetch all borrowers liquidity
-	calls := []abis.MulticallCall{}
-	id := l.getAccountLiquidityMethod().ID
+	f := &funnel{dropped: make(map[string]int)}
+	for _, acc := range underwaterAccounts {
+		c := candidates[acc.Address]
+		c.CollateralLocked = start.snapshot.TransferPaused
+		c.snapshot = start.snapshot
+		if campaign, ok := l.campaigns[acc.Address]; ok {
+			c.Campaign = &campaign
+		}
+		var rank *big.Int
+		if c.Err == nil && l.simulation == nil && l.executor != nil {
+			rank = l.executionRank(c)
+		}
+		c.Explanation.decide(c, l.strategy.Name(), rank)
+		reportCandidate(l.logger, c)
+		journalCandidate(l.journal, block, c)
+		l.publisher.publishCandidate(block, c)
+		f.candidates = append(f.candidates, c)
+		if c.Plan != nil {
+			f.planned++
+		}
+		if c.Estimate != nil && c.Estimate.Profitable() {
+			f.profitable++
+		}
+		if reason := DropReason(c.Err); reason != "" {
+			f.dropped[reason]++
+			continue
+		}
+		f.liquidatable++
+		switch {
+		case l.simulation != nil:
+			l.simulation.wouldSubmit(c, true)
+		case l.executor != nil && execution == ExecuteQueued:
+			job := Job{Candidate: c, Executor: ExecutorFunc(l.execute), Rank: rank, followUp: l.nextRound}
+			f.executions = append(f.executions, BlockExecutionResult{Account: c.Account, Rank: rank, Queued: l.queue.Push(job)})
+		case l.executor != nil && execution == ExecuteInline:
+			job := Job{Candidate: c, Executor: ExecutorFunc(l.execute), Rank: rank, followUp: l.nextRound}
+			outcome, err := l.queue.executeNow(ctx, job)
+			f.executions = append(f.executions, BlockExecutionResult{Account: c.Account, Rank: rank, Outcome: outcome, Err: err})
+		}
+	}
+	return f, nil
+}
 
-	for _, borrower := range borrowers {
-		inputs, err := l.getAccountLiquidityMethod().Inputs.Pack(borrower.Address)
-		if err != nil {
-			return fmt.Errorf("cannot pack borrower: %w", err)
+// health returns what the last check found of accounts that are not
+// underwater. Accounts are only watched while their liquidity is
+// known, so the borrowers tell failed checks apart from repaid loans.
+func (l *Liquidatoor) health(borrowers []Borrower) func(common.Address) (accountHealth, *big.Int) {
+	var borrowing map[common.Address]bool
+	return func(account common.Address) (accountHealth, *big.Int) {
+		if liquidity, ok := l.watchlist.liquidity(account); ok {
+			return healthy, liquidity
 		}
-		calls = append(calls, abis.MulticallCall{
-			Target:   l.comptrollerAddress,
-			CallData: append(id[:], inputs[:]...),
-		})
+		if borrowing == nil {
+			borrowing = make(map[common.Address]bool, len(borrowers))
+			for _, borrower := range borrowers {
+				borrowing[borrower.Address] = true
+			}
+		}
+		if borrowing[account] {
+			return healthUnknown, nil
+		}
+		return notBorrowing, nil
 	}
+}
 
-	resp, err := l.Multicall.Aggregate(noOpts, calls)
+// plan runs the strategy over the underwater accounts and records
+// the plans and their estimated profit on the candidates. Plans are
+// only logged, as are the plans of the shadow strategies, so
+// strategies can be compared.
+func (l *Liquidatoor) plan(ctx context.Context, start *blockStart, underwaterAccounts []Borrower, candidates map[common.Address]Candidate) error {
+	positions, err := l.positions(ctx, nil, underwaterAccounts)
 	if err != nil {
-		return fmt.Errorf("failed multicall request: %v", err)
+		return fmt.Errorf("cannot get positions: %w", err)
 	}
+	l.planPositions(ctx, start, underwaterAccounts, positions, candidates)
+	return nil
+}
 
-	// Filter underwater accounts
-	underwaterAccounts := make([]Borrower, 0)
-	for i, data := range resp.ReturnData {
-		out, err := l.getAccountLiquidityMethod().Outputs.Unpack(data)
-		if err != nil {
-			return fmt.Errorf("cannot unpack output: %v", err)
+// planPositions plans the underwater accounts given their positions,
+// in the same order.
+func (l *Liquidatoor) planPositions(ctx context.Context, start *blockStart, underwaterAccounts []Borrower, positions []AccountPositions, candidates map[common.Address]Candidate) {
+	snapshot, inventory := start.snapshot, start.inventory
+	actionable := make([]AccountPositions, 0, len(positions))
+	for i, account := range positions {
+		l.printPositions(account)
+		c := candidates[account.Account]
+		// Positions that could not be read leave the account actionable
+		c.Class = AccountCandidate
+		if len(account.Positions) >= len(underwaterAccounts[i].Assets) {
+			c.Class = classifyCandidate(snapshot, account, l.badDebtDust)
 		}
-		cErr := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
-		liquidity := *abi.ConvertType(out[1], new(*big.Int)).(**big.Int)
-		shortfall := *abi.ConvertType(out[2], new(*big.Int)).(**big.Int)
-		if cErr.Cmp(zero) != 0 {
-			log.Printf("contract error while getting account %s liquidity: %v\n", borrowers[i], cErr)
+		if old := l.risk.classified(account.Account, c.Class); old != c.Class {
+			reason := "left out of execution"
+			if c.Class == AccountCandidate {
+				reason = "executed again"
+			}
+			l.logger.Info(fmt.Sprintf("Account %s is now %s, was %s; %s", account.Account, c.Class, old, reason),
+				F("pool", l.comptrollerAddress), F("block", snapshot.Block), F("account", account.Account), F("class", c.Class), F("previous", old))
+		}
+		if c.Explanation.failed("bad-debt", c.Class == AccountBadDebt) {
+			c.Err = l.liquidationError(snapshot, account.Account, common.Address{}, ErrBadDebt)
+		} else {
+			actionable = append(actionable, account)
+		}
+		candidates[account.Account] = c
+	}
+
+	input := &StrategyInput{Snapshot: snapshot, Candidates: actionable, Inventory: inventory}
+	for j, strategy := range append([]Strategy{l.strategy}, l.shadowStrategies...) {
+		var plans []LiquidationPlan
+		err := recovered(l.logger, func() (err error) {
+			plans, err = strategy.Plan(ctx, input)
+			return err
+		}, F("pool", l.comptrollerAddress), F("strategy", strategy.Name()))
+		if err != nil {
+			l.logger.Error(fmt.Sprintf("Failed to plan liquidations with strategy %s: %v", strategy.Name(), err), F("pool", l.comptrollerAddress), F("strategy", strategy.Name()), F("err", err))
 			continue
 		}
-		res := liquidity.Cmp(shortfall)
-		if res == -1 {
-			underwaterAccounts = append(underwaterAccounts, Borrower{
-				Address:   borrowers[i].Address,
-				Assets:    borrowers[i].Assets,
-				Shortfall: shortfall,
-			})
+		if j == 0 {
+			plans = l.realizable(ctx, strategy, input, plans, candidates)
+			plans = pinPairs(input, plans, start.pairs)
+		}
+		plans, deferred := l.topPlans(plans)
+		if len(deferred) > 0 {
+			l.logger.Info(fmt.Sprintf("Deferring %d of %d plans of strategy %s to the next block", len(deferred), len(plans)+len(deferred), strategy.Name()),
+				F("pool", l.comptrollerAddress), F("strategy", strategy.Name()), F("deferred", len(deferred)))
+		}
+		for _, plan := range deferred {
+			if c, ok := candidates[plan.Borrower]; ok && j == 0 && c.Plan == nil {
+				plan := plan
+				c.Plan = &plan
+				c.Explanation.failed("candidate-limit", true)
+				c.Err = l.liquidationError(snapshot, plan.Borrower, plan.BorrowMarket, ErrDeferred)
+				candidates[plan.Borrower] = c
+			}
+		}
+		for i, plan := range plans {
+			var estimate *ProfitEstimate
+			err := recovered(l.logger, func() (err error) {
+				estimate, err = l.profitEstimator.Estimate(ctx, plan, snapshot)
+				return err
+			}, F("pool", l.comptrollerAddress), F("strategy", strategy.Name()), F("account", plan.Borrower))
+			if err != nil {
+				l.logger.Warn(fmt.Sprintf("Failed to estimate profit of strategy %s plan %d: %v", strategy.Name(), i, err), F("pool", l.comptrollerAddress), F("strategy", strategy.Name()), F("account", plan.Borrower), F("err", err))
+			} else {
+				l.logger.Info(fmt.Sprintf("Strategy %s plan %d: %s; %s", strategy.Name(), i, plan, estimate), F("pool", l.comptrollerAddress), F("strategy", strategy.Name()), F("account", plan.Borrower))
+			}
+			// Only the primary strategy is recorded
+			if j > 0 {
+				continue
+			}
+			if c, ok := candidates[plan.Borrower]; ok && c.Plan == nil {
+				plan := plan
+				c.Plan, c.Estimate = &plan, estimate
+				c.Explanation.failed("candidate-limit", false)
+				if c.Explanation.failed("estimate", err != nil) {
+					c.Err = l.liquidationError(snapshot, plan.Borrower, plan.BorrowMarket, err)
+				}
+				candidates[plan.Borrower] = c
+			}
 		}
 	}
-	sort.Sort(ByShortfall(underwaterAccounts))
 
-	for _, acc := range underwaterAccounts {
-		fmt.Printf("Account %s is underwater by %v\n", acc.Address, acc.Shortfall)
-		// TODO: Check whether it is worth to execute liquidation
-		// liquidateCalculateSeizeTokens
-		l.getAssets(acc.Address, acc.Assets)
+	for _, account := range positions {
+		c := candidates[account.Account]
+		if err := recovered(l.logger, func() error {
+			c.HealthFactor = healthFactor(snapshot, account)
+			if c.Class != AccountBadDebt {
+				l.checkCandidate(ctx, start, account, &c)
+			}
+			return nil
+		}, F("pool", l.comptrollerAddress), F("account", account.Account)); err != nil {
+			c.Err = l.liquidationError(snapshot, account.Account, common.Address{}, err)
+		}
+		candidates[account.Account] = c
+	}
+}
+
+// pinPairs replaces the plans of the accounts of input that have a
+// plan in pairs, planning them if the strategy did not.
+func pinPairs(input *StrategyInput, plans []LiquidationPlan, pairs map[common.Address]LiquidationPlan) []LiquidationPlan {
+	if len(pairs) == 0 {
+		return plans
+	}
+	planned := make(map[common.Address]bool, len(plans))
+	for i, plan := range plans {
+		if pair, ok := pairs[plan.Borrower]; ok {
+			plans[i] = pair
+		}
+		planned[plan.Borrower] = true
 	}
+	for _, account := range input.Candidates {
+		if pair, ok := pairs[account.Account]; ok && !planned[account.Account] {
+			plans = append(plans, pair)
+		}
+	}
+	return plans
+}
 
-	log.Println("Shortfall check complete.")
+// healthFactor returns the health factor of an underwater account, the
+// value of its collateral weighted by the collateral factors over the
+// value of its borrows, or nil if unknown. The weighted collateral is
+// the borrows less the shortfall.
+func healthFactor(s *Snapshot, account AccountPositions) *Ratio {
+	if account.Shortfall == nil {
+		return nil
+	}
+	supplied, borrowed := new(big.Int), new(big.Int)
+	for _, position := range account.Positions {
+		market, ok := s.Markets[position.Market]
+		if !ok || market.Price == nil {
+			return nil
+		}
+		supplied.Add(supplied, market.Value(orZero(position.Supplied)))
+		borrowed.Add(borrowed, market.Value(orZero(position.Borrowed)))
+	}
+	health := NewRatio(new(big.Int).Sub(borrowed, account.Shortfall), borrowed)
+	if IsZero(borrowed) {
+		// Infinite with collateral, undefined without
+		health = NewRatio(supplied, borrowed)
+	}
+	return &health
+}
 
-	return nil
+// checkCandidate records on a planned candidate why it cannot be
+// liquidated, if any.
+func (l *Liquidatoor) checkCandidate(ctx context.Context, start *blockStart, account AccountPositions, c *Candidate) {
+	snapshot, inventory := start.snapshot, start.inventory
+	e := c.Explanation
+	if c.Err == nil {
+		c.Err = l.dropReason(snapshot, inventory, account, *c)
+	}
+	if c.Err == nil {
+		if err := l.annotations.check(l.comptrollerAddress, account.Account); e.failed("annotation", err != nil) {
+			c.Err = l.liquidationError(snapshot, account.Account, c.Plan.BorrowMarket, err)
+		}
+	}
+	if c.Err == nil {
+		if err := l.queue.cooldowns.check(l.comptrollerAddress, account.Account); e.failed("cooldown", err != nil) {
+			c.Err = l.liquidationError(snapshot, account.Account, c.Plan.BorrowMarket, err)
+		}
+	}
+	if c.Err == nil {
+		if hash, ok := l.pending.intent(l.comptrollerAddress, account.Account, c.Plan.BorrowMarket); e.failed("own-tx", ok) {
+			c.Err = l.liquidationError(snapshot, account.Account, c.Plan.BorrowMarket, fmt.Errorf("%w %s", ErrAwaitingOwnTx, hash.Hex()))
+		}
+	}
+	if c.Err == nil {
+		if market, err := l.priceGuard.check(ctx, snapshot, c.Plan, start.averagePrices); e.failed("price-deviation", err != nil) {
+			c.Err = l.liquidationError(snapshot, account.Account, market, err)
+			l.logger.Warn(fmt.Sprintf("Holding liquidation of account %s until the next block: %v", account.Account, err),
+				F("pool", l.comptrollerAddress), F("account", account.Account), F("market", market),
+				F("triggers", atomic.LoadUint64(&l.priceGuard.triggers)), F("err", err))
+		}
+	}
+	if c.Err == nil {
+		if err := l.checkRedemption(ctx, snapshot, inventory, account, c); e.failed("redemption", err != nil) {
+			c.Err = l.liquidationError(snapshot, account.Account, c.Plan.CollateralMarket, err)
+		}
+	}
+	if c.Err == nil {
+		if err := l.checkSlippage(ctx, snapshot, c); e.failed("slippage", err != nil) {
+			c.Err = l.liquidationError(snapshot, account.Account, c.Plan.CollateralMarket, err)
+		}
+	}
+	if c.Err == nil {
+		if c.Competitor = l.mempool.competitor(account.Account); e.failed("competition", c.Competitor != nil && l.standDownOnCompetition) {
+			c.Err = l.liquidationError(snapshot, account.Account, c.Competitor.Market,
+				fmt.Errorf("%w: tx %s from %s", ErrCompeting, c.Competitor.Tx, c.Competitor.From))
+		}
+	}
 }
 
-func (l *Liquidatoor) getAssets(account common.Address, assets []common.Address) {
-	lentAssets := make([]*abis.CToken, 0)
-	borrowedAssets := make([]*abis.CToken, 0)
+// topPlans ranks plans by gross profit and splits off the ones beyond
+// the candidate limit. Plans are ranked again in every block.
+func (l *Liquidatoor) topPlans(plans []LiquidationPlan) ([]LiquidationPlan, []LiquidationPlan) {
+	k := int(atomic.LoadInt64(l.maxCandidates))
+	if k == 0 || len(plans) <= k {
+		return plans, nil
+	}
+	type rankedPlan struct {
+		plan  LiquidationPlan
+		gross *big.Int
+	}
+	ranked := make([]rankedPlan, len(plans))
+	for i, plan := range plans {
+		ranked[i] = rankedPlan{plan: plan, gross: new(big.Int).Sub(orZero(plan.SeizeValue), orZero(plan.RepayValue))}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return GT(ranked[i].gross, ranked[j].gross)
+	})
+	top := make([]LiquidationPlan, 0, k)
+	deferred := make([]LiquidationPlan, 0, len(plans)-k)
+	for i, r := range ranked {
+		if i < k {
+			top = append(top, r.plan)
+		} else {
+			deferred = append(deferred, r.plan)
+		}
+	}
+	return top, deferred
+}
 
-	for _, asset := range assets {
-		address := asset.String()
+// executionRank ranks the execution of a liquidatable candidate by its
+// estimated profit. Candidates with locked collateral rank last under
+// the deprioritize policy when realizing their profit needs exiting
+// the collateral, ie., to repay a flash loan. Otherwise accounts
+// annotated as priority rank first.
+func (l *Liquidatoor) executionRank(c Candidate) *big.Int {
+	if !c.CollateralLocked || l.transferPausedPolicy != TransferPausedDeprioritize || l.flashLiquidity == nil {
+		return l.annotations.rank(c.Account, c.Estimate.Net)
+	}
+	l.logger.Info(fmt.Sprintf("Transfers are paused in pool %s; deprioritizing account %s", l.comptrollerAddress, c.Account),
+		F("pool", l.comptrollerAddress), F("account", c.Account))
+	return new(big.Int)
+}
 
-		underlyingInfo := l.underlyingInfo[address]
-		cToken, ok := l.BorrowMarkets[address]
-		if !ok {
-			cToken = l.LendMarkets[address]
-			lentAssets = append(lentAssets, cToken)
+// assets returns the price source assets of markets.
+func (l *Liquidatoor) assets(markets []common.Address) []Asset {
+	assets := make([]Asset, 0, len(markets))
+	for _, market := range markets {
+		info := l.underlyingInfo[market.String()]
+		assets = append(assets, Asset{Market: market, Underlying: info.address, Decimals: info.decimals})
+	}
+	return assets
+}
 
-			balance, err := cToken.BalanceOfUnderlying(noOpts, account)
-			if err != nil {
-				log.Printf("Failed to get underlying balance for account %s: %v", account, err)
-				return
+// dropReason returns why a planned candidate cannot be liquidated, if
+// any.
+func (l *Liquidatoor) dropReason(s *Snapshot, inventory Inventory, account AccountPositions, c Candidate) error {
+	e := c.Explanation
+	if e.failed("pool-seize-paused", s.SeizePaused) {
+		return l.liquidationError(s, account.Account, common.Address{}, ErrMarketPaused)
+	}
+	if e.failed("whitelist", l.whitelist.blocked()) {
+		return l.liquidationError(s, account.Account, common.Address{}, ErrNotWhitelisted)
+	}
+	if changes := l.governance.held(); e.failed("governance", len(changes) > 0) {
+		return l.liquidationError(s, account.Account, common.Address{}, fmt.Errorf("%w: %s", ErrGovernanceChanged, strings.Join(changes, "; ")))
+	}
+	if e.failed("plan", c.Plan == nil) {
+		for _, position := range account.Positions {
+			if market := s.Markets[position.Market]; market.Price == nil {
+				return l.liquidationError(s, account.Account, position.Market, ErrStaleData)
 			}
-			sBalance := Balance{value: balance, decimals: underlyingInfo.decimals}
-			fmt.Printf("Account %s has balance %s in %s\n", account, sBalance, underlyingInfo.name)
-		} else {
-			borrowedAssets = append(borrowedAssets, cToken)
-
-			borrowed, err := cToken.BorrowBalanceStored(noOpts, account)
-			if err != nil {
-				log.Printf("Failed to get underlying balance for account %s: %v", account, err)
-				return
+		}
+		// Seizing every collateral of the account may be paused
+		paused := common.Address{}
+		for _, position := range account.Positions {
+			if !IsPositive(position.Supplied) {
+				continue
 			}
-			// If borrowed balance is zero here than this is an asset
-			// the user has lent instead of borrowed, sooo...
-			if borrowed.Cmp(zero) != 0 {
-				sBalance := Balance{value: borrowed, decimals: underlyingInfo.decimals}
-				fmt.Printf("Account %s has borrowed balance %s in %s\n", account, sBalance, underlyingInfo.name)
-				// Should be getting BalanceOfUnderlying
+			if !s.Markets[position.Market].SeizePaused {
+				paused = common.Address{}
+				break
 			}
+			paused = position.Market
+		}
+		if paused != (common.Address{}) {
+			return l.liquidationError(s, account.Account, paused, ErrMarketPaused)
 		}
+		return l.liquidationError(s, account.Account, common.Address{}, errNoPlan)
 	}
+	if e.failed("collateral-seize-paused", s.Markets[c.Plan.CollateralMarket].SeizePaused) {
+		return l.liquidationError(s, account.Account, c.Plan.CollateralMarket, ErrMarketPaused)
+	}
+	if e.failed("profitable", !c.Estimate.Profitable()) {
+		return l.liquidationError(s, account.Account, c.Plan.BorrowMarket, ErrUnprofitable)
+	}
+	// Flash loans are only quoted when executing
+	if l.flashLiquidity == nil {
+		balance := inventory[s.Markets[c.Plan.BorrowMarket].Underlying]
+		if e.failed("inventory", balance == nil || GT(c.Plan.RepayAmount, balance)) {
+			return l.liquidationError(s, account.Account, c.Plan.BorrowMarket, ErrInsufficientInventory)
+		}
+	}
+	return nil
+}
+
+func (l *Liquidatoor) liquidationError(s *Snapshot, borrower, market common.Address, err error) error {
+	return &LiquidationError{Block: s.Block, Pool: l.comptrollerAddress, Borrower: borrower, Market: market, Err: err}
+}
 
+// formatDropped formats drop counts by reason in a stable order.
+func formatDropped(dropped map[string]int) string {
+	if len(dropped) == 0 {
+		return "none"
+	}
+	reasons := make([]string, 0, len(dropped))
+	for reason, count := range dropped {
+		reasons = append(reasons, fmt.Sprintf("%s=%d", reason, count))
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, " ")
+}
+
+func (l *Liquidatoor) printPositions(account AccountPositions) {
+	for _, position := range account.Positions {
+		underlyingInfo := l.underlyingInfo[position.Market.String()]
+		if !IsZero(position.Supplied) {
+			sBalance := NewAmount(position.Supplied, underlyingInfo.decimals)
+			l.logger.Info(fmt.Sprintf("Account %s has balance %s in %s", account.Account, sBalance, underlyingInfo.name), F("pool", l.comptrollerAddress), F("account", account.Account), F("market", position.Market))
+		}
+		if !IsZero(position.Borrowed) {
+			sBalance := NewAmount(position.Borrowed, underlyingInfo.decimals)
+			l.logger.Info(fmt.Sprintf("Account %s has borrowed balance %s in %s", account.Account, sBalance, underlyingInfo.name), F("pool", l.comptrollerAddress), F("account", account.Account), F("market", position.Market))
+		}
+	}
 }
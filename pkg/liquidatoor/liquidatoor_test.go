@@ -0,0 +1,372 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// maybeInt returns a random integer, or nil one time in four.
+func maybeInt(r *rand.Rand, digits int) *big.Int {
+	if r.Intn(4) == 0 {
+		return nil
+	}
+	return randomInt(r, digits)
+}
+
+// partialInput returns a snapshot, and the positions of the accounts
+// found underwater in it, with any amount, price or parameter missing
+// and positions in markets the snapshot lacks.
+func partialInput(r *rand.Rand) (*blockStart, []Borrower, []AccountPositions) {
+	s := &Snapshot{
+		Block:                maybeInt(r, 8),
+		SeizePaused:          r.Intn(20) == 0,
+		CloseFactor:          maybeInt(r, 18),
+		LiquidationIncentive: maybeInt(r, 19),
+		Markets:              make(map[common.Address]MarketSnapshot),
+	}
+	inventory := make(Inventory)
+	averages := make(map[common.Address]*big.Int)
+	addresses := make([]common.Address, 1+r.Intn(5))
+	for i := range addresses {
+		addresses[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+		// Positions in markets missing from the snapshot
+		if r.Intn(6) == 0 {
+			continue
+		}
+		m := MarketSnapshot{
+			Address:          addresses[i],
+			Underlying:       common.BigToAddress(big.NewInt(int64(100 + i))),
+			Decimals:         uint8(r.Intn(19)),
+			Price:            maybeInt(r, 30),
+			SeizePaused:      r.Intn(10) == 0,
+			TotalSupply:      maybeInt(r, 30),
+			TotalBorrows:     maybeInt(r, 30),
+			Cash:             maybeInt(r, 30),
+			CollateralFactor: maybeInt(r, 18),
+		}
+		s.Markets[m.Address] = m
+		// Averages within 1% of the oracle price, or anything
+		switch r.Intn(3) {
+		case 0:
+			if m.Price != nil {
+				average := new(big.Int).Mul(m.Price, big.NewInt(int64(99+r.Intn(3))))
+				averages[m.Address] = average.Quo(average, big.NewInt(100))
+			}
+		case 1:
+			averages[m.Address] = maybeInt(r, 30)
+		}
+		if r.Intn(2) == 0 {
+			inventory[m.Underlying] = maybeInt(r, 30)
+		}
+	}
+	borrowers := make([]Borrower, r.Intn(8))
+	positions := make([]AccountPositions, len(borrowers))
+	for i := range borrowers {
+		account := common.BigToAddress(big.NewInt(int64(1000 + i)))
+		p := AccountPositions{Account: account, Shortfall: maybeInt(r, 24), Illiquid: r.Intn(5) == 0}
+		for _, address := range addresses {
+			if r.Intn(2) == 0 {
+				p.Positions = append(p.Positions, Position{Market: address, Supplied: maybeInt(r, 30), Borrowed: maybeInt(r, 30)})
+			}
+		}
+		positions[i] = p
+		// Positions may have been read for fewer assets than known
+		borrowers[i] = Borrower{Address: account, Assets: addresses[:r.Intn(len(addresses)+1)], Shortfall: p.Shortfall}
+	}
+	return &blockStart{snapshot: s, inventory: inventory, averagePrices: averages}, borrowers, positions
+}
+
+// partialPlans is a strategy planning random accounts, known or not,
+// with any amount or value missing or negative.
+type partialPlans struct {
+	r *rand.Rand
+}
+
+func (partialPlans) Name() string { return "partial" }
+
+func (s partialPlans) Plan(_ context.Context, input *StrategyInput) ([]LiquidationPlan, error) {
+	if s.r.Intn(10) == 0 {
+		return nil, errors.New("cannot plan")
+	}
+	plans := make([]LiquidationPlan, s.r.Intn(len(input.Candidates)+2))
+	for i := range plans {
+		p := LiquidationPlan{
+			Borrower:         common.BigToAddress(big.NewInt(int64(1000 + s.r.Intn(10)))),
+			BorrowMarket:     common.BigToAddress(big.NewInt(int64(s.r.Intn(7)))),
+			CollateralMarket: common.BigToAddress(big.NewInt(int64(s.r.Intn(7)))),
+			RepayAmount:      maybeInt(s.r, 30),
+			RepayValue:       maybeInt(s.r, 30),
+			SeizeValue:       maybeInt(s.r, 30),
+		}
+		if p.RepayValue != nil && s.r.Intn(10) == 0 {
+			p.RepayValue.Neg(p.RepayValue)
+		}
+		plans[i] = p
+	}
+	return plans, nil
+}
+
+// partialEstimates estimates partially populated profits, none, or
+// fails.
+type partialEstimates struct {
+	r *rand.Rand
+}
+
+func (partialEstimates) Name() string { return "partial" }
+
+func (e partialEstimates) Estimate(context.Context, LiquidationPlan, *Snapshot) (*ProfitEstimate, error) {
+	switch e.r.Intn(5) {
+	case 0:
+		return nil, errors.New("cannot estimate")
+	case 1:
+		return nil, nil
+	}
+	return &ProfitEstimate{Gross: maybeInt(e.r, 20), Gas: maybeInt(e.r, 20), Slippage: maybeInt(e.r, 20), Net: maybeInt(e.r, 20)}, nil
+}
+
+// recoveryLogger records the panics recovered.
+type recoveryLogger struct {
+	stdLogger
+	lock      sync.Mutex
+	recovered []string
+}
+
+func (l *recoveryLogger) Error(msg string, fields ...Field) {
+	if strings.HasPrefix(msg, "Recovered from") {
+		for _, field := range fields {
+			if field.Key == "stack" {
+				msg += "\n" + field.Value.(string)
+			}
+		}
+		l.lock.Lock()
+		l.recovered = append(l.recovered, msg)
+		l.lock.Unlock()
+	}
+}
+
+// FuzzPlanPartialCandidates plans partially populated accounts with
+// partially populated plans and estimates, and checks no candidate is
+// dropped for panicking, so nothing relies on the recovery.
+func FuzzPlanPartialCandidates(f *testing.F) {
+	comptrollerABI, err := abis.ComptrollerMetaData.GetAbi()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range []int64{0, 1, 2, 42, 1 << 40} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+		logger := &recoveryLogger{stdLogger: stdLogger{logger: log.New(io.Discard, "", 0)}}
+		maxCandidates := int64(r.Intn(3))
+		pool := common.HexToAddress("0xc0")
+		whitelist, err := newWhitelistCheck(logger, pool, common.Address{}, comptrollerABI, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := &Liquidatoor{
+			logger:                  logger,
+			comptrollerAddress:      pool,
+			strategy:                defaultStrategy{},
+			shadowStrategies:        []Strategy{partialPlans{r}},
+			profitEstimator:         partialEstimates{r},
+			maxCandidates:           &maxCandidates,
+			queue:                   NewExecutionQueue(logger, 1, 1),
+			risk:                    newRiskMonitor(logger, pool, nil, nil),
+			whitelist:               whitelist,
+			governance:              newGovernanceWatch(logger, pool, comptrollerABI, 0, true),
+			priceGuard:              newPriceGuard(nil, nil, nil),
+			slippage:                newSlippagePolicy(nil, nil),
+			illiquidAccounts:        make(map[common.Address]bool),
+			illiquidCollateralAlert: defaultIlliquidCollateralAlert,
+			badDebtDust:             defaultBadDebtDust,
+		}
+		if r.Intn(2) == 0 {
+			l.strategy, l.shadowStrategies = partialPlans{r}, []Strategy{defaultStrategy{}}
+		}
+
+		for round := 0; round < 20; round++ {
+			start, borrowers, positions := partialInput(r)
+			candidates := make(map[common.Address]Candidate, len(borrowers))
+			for _, borrower := range borrowers {
+				c := Candidate{Account: borrower.Address, Shortfall: borrower.Shortfall, Block: start.snapshot.Block}
+				if r.Intn(2) == 0 {
+					c.Explanation = &Explanation{}
+				}
+				candidates[borrower.Address] = c
+			}
+			l.planPositions(context.Background(), start, borrowers, positions, candidates)
+
+			if len(logger.recovered) > 0 {
+				t.Fatalf("expected no panics, recovered %d: %s", len(logger.recovered), logger.recovered[0])
+			}
+			for account, c := range candidates {
+				if errors.Is(c.Err, ErrPanic) {
+					t.Fatalf("expected account %s not dropped for panicking: %v", account, c.Err)
+				}
+				if c.Err == nil && (c.Plan == nil || !c.Estimate.Profitable()) {
+					t.Fatalf("expected account %s without a profitable plan dropped", account)
+				}
+				// Every candidate is encoded
+				if _, err := c.MarshalJSON(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+// limitInput is a block where accounts 0x1000 and on borrow 1000 times
+// their size of one market against twice that of another, every token
+// worth one, so the larger ones are the more profitable.
+func limitInput(sizes ...int64) (*blockStart, []Borrower, []AccountPositions) {
+	borrowed, supplied := common.HexToAddress("0xa"), common.HexToAddress("0xb")
+	s := &Snapshot{
+		Block:                big.NewInt(100),
+		CloseFactor:          big.NewInt(5e17),
+		LiquidationIncentive: big.NewInt(108e16),
+		Markets: map[common.Address]MarketSnapshot{
+			borrowed: {Address: borrowed, Decimals: 18, Price: big.NewInt(1e18)},
+			supplied: {Address: supplied, Decimals: 18, Price: big.NewInt(1e18)},
+		},
+	}
+	borrowers := make([]Borrower, len(sizes))
+	positions := make([]AccountPositions, len(sizes))
+	for i, size := range sizes {
+		account := common.BigToAddress(big.NewInt(int64(0x1000 + i)))
+		borrowers[i] = Borrower{Address: account, Assets: []common.Address{borrowed, supplied}, Shortfall: big.NewInt(size)}
+		positions[i] = AccountPositions{Account: account, Shortfall: big.NewInt(size), Positions: []Position{
+			{Market: borrowed, Borrowed: new(big.Int).Mul(big.NewInt(1000*size), big.NewInt(1e18))},
+			{Market: supplied, Supplied: new(big.Int).Mul(big.NewInt(2000*size), big.NewInt(1e18))},
+		}}
+	}
+	return &blockStart{snapshot: s, inventory: Inventory{}}, borrowers, positions
+}
+
+// planningLiquidatoor plans with the default strategy, estimating
+// plans with estimator, up to maxCandidates of them.
+func planningLiquidatoor(t *testing.T, estimator ProfitEstimator, maxCandidates *int64) *Liquidatoor {
+	t.Helper()
+	comptrollerABI, err := abis.ComptrollerMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := common.HexToAddress("0xc0")
+	whitelist, err := newWhitelistCheck(quietLogger(), pool, common.Address{}, comptrollerABI, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Liquidatoor{
+		logger:                  quietLogger(),
+		comptrollerAddress:      pool,
+		strategy:                defaultStrategy{},
+		profitEstimator:         estimator,
+		maxCandidates:           maxCandidates,
+		queue:                   NewExecutionQueue(quietLogger(), 1, 1),
+		risk:                    newRiskMonitor(quietLogger(), pool, nil, nil),
+		whitelist:               whitelist,
+		governance:              newGovernanceWatch(quietLogger(), pool, comptrollerABI, 0, true),
+		priceGuard:              newPriceGuard(nil, nil, nil),
+		slippage:                newSlippagePolicy(nil, nil),
+		illiquidAccounts:        make(map[common.Address]bool),
+		illiquidCollateralAlert: defaultIlliquidCollateralAlert,
+		badDebtDust:             defaultBadDebtDust,
+	}
+}
+
+func TestPlanPositionsCandidateLimit(t *testing.T) {
+	maxCandidates := int64(2)
+	estimator := &incentiveEstimator{}
+	l := planningLiquidatoor(t, estimator, &maxCandidates)
+	conn := &Connection{logger: quietLogger(), maxCandidates: &maxCandidates}
+
+	// plan plans a block of accounts of sizes, and returns the indexes
+	// of the accounts estimated and of the ones deferred
+	plan := func(sizes ...int64) (estimated, deferred []int) {
+		t.Helper()
+		start, borrowers, positions := limitInput(sizes...)
+		candidates := make(map[common.Address]Candidate, len(borrowers))
+		for _, borrower := range borrowers {
+			candidates[borrower.Address] = Candidate{Account: borrower.Address, Shortfall: borrower.Shortfall, Block: start.snapshot.Block}
+		}
+		estimator.estimated = 0
+		l.planPositions(context.Background(), start, borrowers, positions, candidates)
+		for i, borrower := range borrowers {
+			c := candidates[borrower.Address]
+			switch {
+			case errors.Is(c.Err, ErrDeferred):
+				if c.Plan == nil || c.Estimate != nil {
+					t.Fatalf("expected account %d deferred with its plan and no estimate, got %+v", i, c)
+				}
+				deferred = append(deferred, i)
+			case c.Estimate != nil:
+				estimated = append(estimated, i)
+			default:
+				t.Fatalf("expected account %d estimated or deferred, got %v", i, c.Err)
+			}
+		}
+		if estimator.estimated != len(estimated) {
+			t.Fatalf("expected %d estimates, got %d", len(estimated), estimator.estimated)
+		}
+		return estimated, deferred
+	}
+	check := func(name string, estimated, deferred, expectedEstimated, expectedDeferred []int) {
+		t.Helper()
+		if fmt.Sprint(estimated) != fmt.Sprint(expectedEstimated) || fmt.Sprint(deferred) != fmt.Sprint(expectedDeferred) {
+			t.Fatalf("%s: expected %v estimated and %v deferred, got %v and %v", name, expectedEstimated, expectedDeferred, estimated, deferred)
+		}
+	}
+
+	// Only the most profitable plans are estimated
+	estimated, deferred := plan(1, 4, 2, 3)
+	check("top 2", estimated, deferred, []int{1, 3}, []int{0, 2})
+
+	// Deferred accounts are ranked again in the next block, against
+	// their new positions
+	estimated, deferred = plan(5, 4, 2, 3)
+	check("ranked again", estimated, deferred, []int{0, 1}, []int{2, 3})
+
+	// The limit changes from the next block on
+	conn.SetMaxCandidates(3)
+	estimated, deferred = plan(1, 4, 2, 3)
+	check("raised", estimated, deferred, []int{1, 2, 3}, []int{0})
+	conn.SetMaxCandidates(0)
+	estimated, deferred = plan(1, 4, 2, 3)
+	check("unlimited", estimated, deferred, []int{0, 1, 2, 3}, nil)
+}
+
+func TestPlanPositionsPairs(t *testing.T) {
+	var maxCandidates int64
+	l := planningLiquidatoor(t, &incentiveEstimator{}, &maxCandidates)
+	start, borrowers, positions := limitInput(1, 2)
+	// The pair selected for the first account, rather than the plan of
+	// the strategy
+	pinned := LiquidationPlan{Borrower: borrowers[0].Address, BorrowMarket: common.HexToAddress("0xa"), CollateralMarket: common.HexToAddress("0xb"),
+		RepayAmount: big.NewInt(100), RepayValue: big.NewInt(100), SeizeValue: big.NewInt(120)}
+	start.pairs = map[common.Address]LiquidationPlan{pinned.Borrower: pinned}
+	candidates := make(map[common.Address]Candidate, len(borrowers))
+	for _, borrower := range borrowers {
+		candidates[borrower.Address] = Candidate{Account: borrower.Address, Shortfall: borrower.Shortfall, Block: start.snapshot.Block, Explanation: &Explanation{}}
+	}
+	l.planPositions(context.Background(), start, borrowers, positions, candidates)
+
+	if c := candidates[pinned.Borrower]; c.Plan == nil || !reflect.DeepEqual(*c.Plan, pinned) || c.Estimate == nil || c.Estimate.Net.Int64() != 20 {
+		t.Fatalf("expected the pinned plan estimated, got %+v, %+v", c.Plan, c.Estimate)
+	}
+	if c := candidates[borrowers[1].Address]; c.Plan == nil || c.Plan.RepayAmount.Cmp(new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))) != 0 {
+		t.Fatalf("expected the plan of the strategy for the other account, got %+v", c.Plan)
+	}
+}
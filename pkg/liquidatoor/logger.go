@@ -0,0 +1,94 @@
+package liquidatoor
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Logger receives everything the package logs. Messages are complete
+// sentences; fields repeat their identifiers for structured loggers.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// stdLogger prints messages with the standard library logger the way
+// they have always been printed. Fields are already part of messages
+// so they are not printed, and debug messages are dropped.
+type stdLogger struct {
+	logger *log.Logger
+}
+
+func NewStdLogger() Logger {
+	return &stdLogger{logger: log.New(os.Stderr, "", log.LstdFlags)}
+}
+
+func (l *stdLogger) Debug(string, ...Field) {}
+
+func (l *stdLogger) Info(msg string, _ ...Field) {
+	l.logger.Print(msg)
+}
+
+func (l *stdLogger) Warn(msg string, _ ...Field) {
+	l.logger.Print(msg)
+}
+
+func (l *stdLogger) Error(msg string, _ ...Field) {
+	l.logger.Print(msg)
+}
+
+// labelLogger labels every message with a field, eg., the shard or the
+// chain, so the lines and stats of every instance, or of every chain of
+// one, can be told apart.
+type labelLogger struct {
+	logger Logger
+	key    string
+	value  string
+}
+
+func (l *labelLogger) label(msg string, fields []Field) (string, []Field) {
+	return fmt.Sprintf("%s [%s %s]", msg, l.key, l.value), append(fields[:len(fields):len(fields)], F(l.key, l.value))
+}
+
+func (l *labelLogger) Debug(msg string, fields ...Field) {
+	msg, fields = l.label(msg, fields)
+	l.logger.Debug(msg, fields...)
+}
+
+func (l *labelLogger) Info(msg string, fields ...Field) {
+	msg, fields = l.label(msg, fields)
+	l.logger.Info(msg, fields...)
+}
+
+func (l *labelLogger) Warn(msg string, fields ...Field) {
+	msg, fields = l.label(msg, fields)
+	l.logger.Warn(msg, fields...)
+}
+
+func (l *labelLogger) Error(msg string, fields ...Field) {
+	msg, fields = l.label(msg, fields)
+	l.logger.Error(msg, fields...)
+}
+
+// Option customizes a connection and everything built on top of it.
+type Option func(*Connection)
+
+// WithLogger makes the connection, and every pool monitor it creates,
+// log to logger.
+func WithLogger(logger Logger) Option {
+	return func(c *Connection) {
+		c.logger = logger
+	}
+}
@@ -0,0 +1,351 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// Retries of a failed chunk by default, backing off from
+	// logRetryDelay
+	logRetries    = 5
+	logRetryDelay = time.Second
+	// Retries of a failed chunk filtered while processing a block,
+	// which is retried on the next one anyway
+	blockLogRetries = 1
+	// Interval between progress reports of long backfills
+	logProgressInterval = 30 * time.Second
+	// Chunks filtered within limits narrowed for their result size
+	// before widening them again
+	logWidenAfter = 8
+)
+
+// logLimiter holds the log limits of a node endpoint, shared by every
+// backfill over it so limits shrunk by one apply to the rest for the
+// session.
+type logLimiter struct {
+	// Host of the endpoint, and domain of its provider if known
+	host     string
+	provider string
+
+	lock   sync.Mutex
+	limits LogLimits
+	// Rate of the endpoint, if bounded
+	budget *Budget
+
+	requests uint64
+	failures uint64
+	shrinks  uint64
+}
+
+// LogLimitStats are the log limits of a node endpoint currently in
+// use and the queries sent to it.
+type LogLimitStats struct {
+	Endpoint string
+	Provider string
+	LogLimits
+	Requests uint64
+	Failures uint64
+	// Times the limits were shrunk on limit errors
+	Shrinks uint64
+}
+
+func newLogLimiter(host string, configured map[string]LogLimits) *logLimiter {
+	limits, provider := logLimitsFor(host, configured)
+	return &logLimiter{host: host, provider: provider, limits: limits}
+}
+
+func (r *logLimiter) current() LogLimits {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.limits
+}
+
+// shrink narrows the limits of queries for the session, unless
+// another query already narrowed them below limits. It returns the
+// new limits, and false if they cannot be narrowed further.
+func (r *logLimiter) shrink(limits LogLimits, addresses int) (LogLimits, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.limits.BlockRange < limits.BlockRange || (r.limits.AddressBatch > 0 && (limits.AddressBatch == 0 || r.limits.AddressBatch < limits.AddressBatch)) {
+		return r.limits, true
+	}
+	narrowed, ok := narrow(limits, addresses)
+	if !ok {
+		return r.limits, false
+	}
+	r.limits.BlockRange, r.limits.AddressBatch = narrowed.BlockRange, narrowed.AddressBatch
+	atomic.AddUint64(&r.shrinks, 1)
+	return r.limits, true
+}
+
+// narrow halves the block range of limits, down to a single block,
+// then the address batch of queries of addresses, and returns false if
+// neither can be halved.
+func narrow(limits LogLimits, addresses int) (LogLimits, bool) {
+	switch batch := limits.AddressBatch; {
+	case limits.BlockRange > 1:
+		limits.BlockRange /= 2
+	case addresses > 1 && (batch == 0 || batch > addresses):
+		limits.AddressBatch = addresses / 2
+	case batch > 1:
+		limits.AddressBatch = batch / 2
+	default:
+		return limits, false
+	}
+	return limits, true
+}
+
+func (r *logLimiter) stats() LogLimitStats {
+	limits := r.current()
+	return LogLimitStats{
+		Endpoint:  r.host,
+		Provider:  r.provider,
+		LogLimits: limits,
+		Requests:  atomic.LoadUint64(&r.requests),
+		Failures:  atomic.LoadUint64(&r.failures),
+		Shrinks:   atomic.LoadUint64(&r.shrinks),
+	}
+}
+
+// filterLogs filters the logs of query in batches of addresses, at
+// most limits.Concurrency at once, and returns them in order.
+func (r *logLimiter) filterLogs(ctx context.Context, client ethereum.LogFilterer, query ethereum.FilterQuery, limits LogLimits) ([]types.Log, error) {
+	batches := [][]common.Address{query.Addresses}
+	if size := limits.AddressBatch; size > 0 && len(query.Addresses) > size {
+		batches = batches[:0]
+		for start := 0; start < len(query.Addresses); start += size {
+			end := start + size
+			if end > len(query.Addresses) {
+				end = len(query.Addresses)
+			}
+			batches = append(batches, query.Addresses[start:end])
+		}
+	}
+
+	results := make([][]types.Log, len(batches))
+	errs := make([]error, len(batches))
+	if limits.Concurrency < 1 {
+		limits.Concurrency = 1
+	}
+	concurrency := make(chan struct{}, limits.Concurrency)
+	var wg sync.WaitGroup
+	for i, addresses := range batches {
+		wg.Add(1)
+		concurrency <- struct{}{}
+		go func(i int, query ethereum.FilterQuery) {
+			defer wg.Done()
+			defer func() { <-concurrency }()
+			if errs[i] = r.budget.wait(ctx, r.host); errs[i] != nil {
+				return
+			}
+			atomic.AddUint64(&r.requests, 1)
+			results[i], errs[i] = client.FilterLogs(ctx, query)
+			if errs[i] != nil {
+				atomic.AddUint64(&r.failures, 1)
+			}
+		}(i, ethereum.FilterQuery{BlockHash: query.BlockHash, FromBlock: query.FromBlock, ToBlock: query.ToBlock, Addresses: addresses, Topics: query.Topics})
+	}
+	wg.Wait()
+
+	// Limit errors first, so the limits are narrowed
+	for _, err := range errs {
+		if err != nil && (isLogRangeLimit(err) || isLogResultLimit(err)) {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	logs := make([]types.Log, 0)
+	for _, result := range results {
+		logs = append(logs, result...)
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+	return logs, nil
+}
+
+// logBackfill filters logs over block ranges in chunks of at most
+// blockRange blocks, within the limits of the endpoint. The limits are
+// narrowed for the session when the node rejects a query for its
+// range, and only until the logs thin out when it rejects one for its
+// result size, which depends on the blocks. Other failures are retried
+// with backoff.
+type logBackfill struct {
+	logger     Logger
+	client     ethereum.LogFilterer
+	limiter    *logLimiter
+	blockRange uint64
+	retries    int
+}
+
+func newLogBackfill(logger Logger, client ethereum.LogFilterer, limiter *logLimiter, blockRange uint64, retries int) *logBackfill {
+	if blockRange == 0 {
+		blockRange = defaultBorrowerScanBlockRange
+	}
+	if limiter == nil {
+		limiter = newLogLimiter("", nil)
+	}
+	return &logBackfill{logger: logger, client: client, limiter: limiter, blockRange: blockRange, retries: retries}
+}
+
+// limits returns the limits of the next query.
+func (b *logBackfill) limits() LogLimits {
+	limits := b.limiter.current()
+	if limits.BlockRange > b.blockRange {
+		limits.BlockRange = b.blockRange
+	}
+	return limits
+}
+
+// filter calls fn with the logs of query in every chunk of the blocks
+// from-to, in order, and the last block of the chunk. Chunks failing
+// to filter or in fn are retried; what describes the logs in reports.
+func (b *logBackfill) filter(ctx context.Context, what string, query ethereum.FilterQuery, from, to uint64, fn func(logs []types.Log, last uint64) error) error {
+	start, reported, retries := from, time.Now(), 0
+	// Limits narrowed for the logs of the blocks, if any, widened again
+	// after as many chunks as filtered since
+	var narrowed *LogLimits
+	filtered := 0
+	for from <= to {
+		limits := b.limits()
+		if narrowed != nil {
+			if filtered >= logWidenAfter {
+				narrowed, filtered = widen(*narrowed, limits, len(query.Addresses)), 0
+			}
+			if narrowed != nil {
+				limits = *narrowed
+			}
+		}
+		last := from + limits.BlockRange - 1
+		if last > to || last < from {
+			last = to
+		}
+		query.FromBlock, query.ToBlock = new(big.Int).SetUint64(from), new(big.Int).SetUint64(last)
+		logs, err := b.limiter.filterLogs(ctx, b.client, query, limits)
+		// Narrow the range actually queried, which may be shorter than
+		// the limit near the end
+		limits.BlockRange = last - from + 1
+		if err != nil && isLogRangeLimit(err) {
+			if shrunk, ok := b.limiter.shrink(limits, len(query.Addresses)); ok {
+				b.logger.Info(fmt.Sprintf("Node limits log queries; filtering %s in %d blocks and %s at once", what, shrunk.BlockRange, formatAddressBatch(shrunk.AddressBatch)),
+					F("logs", what), F("endpoint", b.limiter.host), F("range", shrunk.BlockRange), F("addresses", shrunk.AddressBatch), F("err", err))
+				narrowed = nil
+				continue
+			}
+		}
+		if err != nil && isLogResultLimit(err) {
+			if limits, ok := narrow(limits, len(query.Addresses)); ok {
+				b.logger.Debug(fmt.Sprintf("Too many %s in blocks %d-%d; filtering %d blocks and %s at once", what, from, last, limits.BlockRange, formatAddressBatch(limits.AddressBatch)),
+					F("logs", what), F("endpoint", b.limiter.host), F("range", limits.BlockRange), F("addresses", limits.AddressBatch), F("err", err))
+				narrowed, filtered = &limits, 0
+				continue
+			}
+		}
+		if err == nil {
+			err = fn(logs, last)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if retries >= b.retries {
+				return fmt.Errorf("cannot filter %s in blocks %d-%d: %w", what, from, last, err)
+			}
+			delay := logRetryDelay << retries
+			retries++
+			b.logger.Warn(fmt.Sprintf("Failed to filter %s in blocks %d-%d, retrying in %v: %v", what, from, last, delay, err),
+				F("logs", what), F("from", from), F("to", last), F("err", err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			continue
+		}
+		retries = 0
+		filtered++
+		from = last + 1
+
+		if from <= to && time.Since(reported) >= logProgressInterval {
+			reported = time.Now()
+			done := NewRatio(new(big.Int).SetUint64(from-start), new(big.Int).SetUint64(to-start+1))
+			b.logger.Info(fmt.Sprintf("Filtered %s in blocks %d-%d of %d-%d (%s)", what, start, last, start, to, done.Percent()),
+				F("logs", what), F("from", start), F("to", last), F("target", to))
+		}
+	}
+	return nil
+}
+
+// isLogRangeLimit reports whether a node rejected a log query for its
+// block range. Providers word these differently.
+func isLogRangeLimit(err error) bool {
+	return containsAny(err, "block range", "range too large", "range is too large", "range is too wide", "limited to a")
+}
+
+// isLogResultLimit reports whether a node rejected a log query for the
+// size of its result.
+func isLogResultLimit(err error) bool {
+	return containsAny(err, "query returned more than", "more than 10000 results", "limit exceeded", "too many", "response size", "query timeout")
+}
+
+func containsAny(err error, substrings ...string) bool {
+	msg := strings.ToLower(err.Error())
+	for _, substring := range substrings {
+		if strings.Contains(msg, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// widen doubles the address batch of narrowed queries of addresses,
+// up to limits, then their block range, and returns nil once they are
+// back to limits.
+func widen(narrowed, limits LogLimits, addresses int) *LogLimits {
+	if narrowed.AddressBatch != limits.AddressBatch {
+		narrowed.AddressBatch *= 2
+		switch {
+		case limits.AddressBatch == 0 && narrowed.AddressBatch >= addresses:
+			narrowed.AddressBatch = 0
+		case limits.AddressBatch > 0 && narrowed.AddressBatch > limits.AddressBatch:
+			narrowed.AddressBatch = limits.AddressBatch
+		}
+	} else {
+		narrowed.BlockRange *= 2
+	}
+	if narrowed.BlockRange >= limits.BlockRange {
+		if narrowed.AddressBatch == limits.AddressBatch {
+			return nil
+		}
+		narrowed.BlockRange = limits.BlockRange
+	}
+	return &narrowed
+}
+
+func formatAddressBatch(batch int) string {
+	if batch == 0 {
+		return "every address"
+	}
+	return fmt.Sprintf("%d addresses", batch)
+}
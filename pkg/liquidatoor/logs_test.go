@@ -0,0 +1,154 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/fakes"
+)
+
+// denseChain returns a chain of blocks 1-to with perBlock(block) logs
+// of watchedAddress in each, and its logs.
+func denseChain(to uint64, perBlock func(block uint64) int) (*fakes.Logs, []types.Log) {
+	chain := fakes.NewLogs(0)
+	var logs []types.Log
+	for block := uint64(1); block <= to; block++ {
+		for i := 0; i < perBlock(block); i++ {
+			logs = append(logs, testLog(block, uint(i)))
+		}
+	}
+	chain.Emit(logs...)
+	return chain, logs
+}
+
+// backfillAll filters every log of watchedAddress in blocks from-to,
+// checking the chunks are in order and end at to.
+func backfillAll(t *testing.T, b *logBackfill, from, to uint64) []types.Log {
+	t.Helper()
+	var logs []types.Log
+	previous := from - 1
+	err := b.filter(context.Background(), "test logs", ethereum.FilterQuery{Addresses: []common.Address{watchedAddress}}, from, to, func(chunk []types.Log, last uint64) error {
+		if last <= previous {
+			t.Fatalf("expected chunks in order, got one ending at %d after one ending at %d", last, previous)
+		}
+		for _, log := range chunk {
+			if log.BlockNumber <= previous || log.BlockNumber > last {
+				t.Fatalf("expected the logs of blocks %d-%d, got one of block %d", previous+1, last, log.BlockNumber)
+			}
+		}
+		previous = last
+		logs = append(logs, chunk...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != to {
+		t.Fatalf("expected chunks up to block %d, got up to %d", to, previous)
+	}
+	return logs
+}
+
+// checkLogsOnce checks logs are the expected logs in blocks from-to,
+// each once and in order.
+func checkLogsOnce(t *testing.T, expected, logs []types.Log, from, to uint64) {
+	t.Helper()
+	seen := make(map[logKey]bool, len(logs))
+	for _, log := range logs {
+		key := logKey{block: log.BlockHash, index: log.Index}
+		if seen[key] {
+			t.Fatalf("log %d of block %d filtered twice", log.Index, log.BlockNumber)
+		}
+		seen[key] = true
+	}
+	count := 0
+	for _, log := range expected {
+		if log.BlockNumber < from || log.BlockNumber > to {
+			continue
+		}
+		if count >= len(logs) || logs[count].BlockHash != log.BlockHash || logs[count].Index != log.Index {
+			t.Fatalf("expected log %d of block %d at position %d", log.Index, log.BlockNumber, count)
+		}
+		count++
+	}
+	if count != len(logs) {
+		t.Fatalf("expected %d logs, got %d", count, len(logs))
+	}
+}
+
+func TestLogBackfillHalvesOnRangeLimit(t *testing.T) {
+	chain, all := denseChain(1000, func(block uint64) int { return int(block % 3) })
+	chain.MaxRange = 100
+	limiter := newLogLimiter("", nil)
+	b := newLogBackfill(NewStdLogger(), chain, limiter, 1000, 0)
+
+	logs := backfillAll(t, b, 1, 1000)
+	checkLogsOnce(t, all, logs, 1, 1000)
+	// 1000 blocks halve to 500, 250, 125 and 62
+	if limits := limiter.current(); limits.BlockRange != 62 {
+		t.Fatalf("expected the session limited to 62 blocks, got %d", limits.BlockRange)
+	}
+	if stats := limiter.stats(); stats.Shrinks != 4 || stats.Failures != 4 {
+		t.Fatalf("expected 4 failed queries shrinking the limits, got %d failures and %d shrinks", stats.Failures, stats.Shrinks)
+	}
+
+	// Later backfills start from the limits shrunk
+	before := len(chain.Queries())
+	logs = backfillAll(t, b, 500, 700)
+	checkLogsOnce(t, all, logs, 500, 700)
+	if failures := limiter.stats().Failures; failures != 4 {
+		t.Fatalf("expected no further failures, got %d", failures-4)
+	}
+	if queries := len(chain.Queries()) - before; queries != 4 {
+		t.Fatalf("expected 201 blocks in 4 queries, got %d", queries)
+	}
+}
+
+func TestLogBackfillNarrowsOnResultLimit(t *testing.T) {
+	// Blocks 400-449 are crowded
+	chain, all := denseChain(2000, func(block uint64) int {
+		if block >= 400 && block < 450 {
+			return 5
+		}
+		return 1
+	})
+	chain.MaxResults = 100
+	limiter := newLogLimiter("", nil)
+	b := newLogBackfill(NewStdLogger(), chain, limiter, 100, 0)
+
+	logs := backfillAll(t, b, 1, 2000)
+	checkLogsOnce(t, all, logs, 1, 2000)
+	// Crowded blocks only narrow the queries of their chunks
+	if limits := limiter.current(); limits.BlockRange < 100 {
+		t.Fatalf("expected the session limits kept, got %d blocks", limits.BlockRange)
+	}
+	// Widened again by doubling every logWidenAfter chunks
+	widened := false
+	for _, query := range chain.Queries() {
+		from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+		widened = widened || (from >= 450 && to-from+1 == 100)
+	}
+	if !widened {
+		t.Fatal("expected queries widened back to 100 blocks past the crowded blocks")
+	}
+}
+
+func TestLogBackfillFailsOnSingleCrowdedBlock(t *testing.T) {
+	chain, _ := denseChain(10, func(block uint64) int {
+		if block == 5 {
+			return 3
+		}
+		return 1
+	})
+	chain.MaxResults = 2
+	b := newLogBackfill(NewStdLogger(), chain, nil, 10, 0)
+	err := b.filter(context.Background(), "test logs", ethereum.FilterQuery{Addresses: []common.Address{watchedAddress}}, 1, 10, func([]types.Log, uint64) error { return nil })
+	if err == nil || !isLogResultLimit(errors.Unwrap(err)) {
+		t.Fatalf("expected a result limit error for block 5, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package liquidatoor
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/exp"
+)
+
+// Scratch values for valuation loops, which would otherwise allocate
+// for every position of every candidate in every block. Values taken
+// from the pool must not escape; exported results are always fresh.
+var scratchPool = sync.Pool{
+	New: func() interface{} { return new(big.Int) },
+}
+
+func getScratch() *big.Int {
+	return scratchPool.Get().(*big.Int)
+}
+
+func putScratch(x *big.Int) {
+	scratchPool.Put(x)
+}
+
+// orZero returns x, or zero if x is nil, for fields that are nil until
+// known. The result must not be modified.
+func orZero(x *big.Int) *big.Int {
+	if x == nil {
+		return zero
+	}
+	return x
+}
+
+// Comparisons of integers, to read as intent rather than as the sign
+// conventions of Cmp. Nil integers are zero.
+
+// IsPositive reports whether x > 0.
+func IsPositive(x *big.Int) bool {
+	return orZero(x).Sign() == 1
+}
+
+// IsNegative reports whether x < 0.
+func IsNegative(x *big.Int) bool {
+	return orZero(x).Sign() == -1
+}
+
+// IsZero reports whether x == 0.
+func IsZero(x *big.Int) bool {
+	return orZero(x).Sign() == 0
+}
+
+// Equal reports whether a == b.
+func Equal(a, b *big.Int) bool {
+	return orZero(a).Cmp(orZero(b)) == 0
+}
+
+// GT reports whether a > b.
+func GT(a, b *big.Int) bool {
+	return orZero(a).Cmp(orZero(b)) == 1
+}
+
+// GTE reports whether a >= b.
+func GTE(a, b *big.Int) bool {
+	return orZero(a).Cmp(orZero(b)) >= 0
+}
+
+// Min returns the smaller of a and b, not a copy.
+func Min(a, b *big.Int) *big.Int {
+	if GT(a, b) {
+		return b
+	}
+	return a
+}
+
+// Max returns the larger of a and b, not a copy.
+func Max(a, b *big.Int) *big.Int {
+	if GT(b, a) {
+		return b
+	}
+	return a
+}
+
+// valueInto sets z to the value of amount at price, as USDValue does,
+// and returns z.
+func valueInto(z, price, amount *big.Int) *big.Int {
+	return exp.MulScalarTruncate(z, price, amount)
+}
+
+// Amounts and values round so liquidations never revert over a unit
+// and estimates err against us:
+//
+//	- repay amounts round down, so they never exceed the close factor
+//	- values we pay, of repay amounts, gas and fees, round up
+//	- values we receive, of seized collateral, round down
+//	- minimum swap outputs round down after slippage
+//
+// The functions below implement the policy; amounts and values of
+// liquidations are not rounded elsewhere.
+
+// repayAmountOf returns the amount of the underlying of m worth value,
+// rounded down.
+func repayAmountOf(m MarketSnapshot, value *big.Int) *big.Int {
+	return m.Amount(value)
+}
+
+// costOf returns the value of an amount of the underlying of m we pay,
+// rounded up.
+func costOf(m MarketSnapshot, amount *big.Int) *big.Int {
+	return exp.MulScalarCeil(new(big.Int), m.Price, amount)
+}
+
+// seizeValueOf returns the value of the collateral seized by repaying
+// amount of the underlying of m, rounded down.
+func seizeValueOf(s *Snapshot, m MarketSnapshot, amount *big.Int) *big.Int {
+	value := m.Value(amount)
+	return exp.MulScalarTruncate(value, s.LiquidationIncentive, value)
+}
+
+// minAmountOutOf returns the minimum output of a swap expected to
+// return expected, within limit scaled by 1e18, rounded down.
+func minAmountOutOf(expected, limit *big.Int) *big.Int {
+	min := new(big.Int).Sub(expScale, limit)
+	return exp.MulScalarTruncate(min, min, expected)
+}
+
+// feeOf returns the fee of amount at a rate in basis points, rounded
+// up.
+func feeOf(amount, bps *big.Int) *big.Int {
+	fee := new(big.Int).Mul(amount, bps)
+	return exp.QuoCeil(fee, fee, bpsScale)
+}
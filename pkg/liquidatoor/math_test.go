@@ -0,0 +1,168 @@
+package liquidatoor
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// roundingCase is an operation at an exact multiple, or one wei off.
+type roundingCase struct {
+	name     string
+	input    int64
+	expected int64
+}
+
+func checkRounding(t *testing.T, fn func(*big.Int) *big.Int, cases []roundingCase) {
+	t.Helper()
+	for _, tc := range cases {
+		if result := fn(big.NewInt(tc.input)); result.Cmp(big.NewInt(tc.expected)) != 0 {
+			t.Errorf("%s: expected %d for %d, got %v", tc.name, tc.expected, tc.input, result)
+		}
+	}
+}
+
+func TestRepayAmountRoundsDown(t *testing.T) {
+	// 3 per unit, so 3k is worth k
+	m := MarketSnapshot{Decimals: 18, Price: big.NewInt(3e18)}
+	checkRounding(t, func(value *big.Int) *big.Int { return repayAmountOf(m, value) }, []roundingCase{
+		{"exact", 3000, 1000},
+		{"one wei over", 3001, 1000},
+		{"one wei under", 2999, 999},
+		{"under a unit", 2, 0},
+	})
+}
+
+func TestCostRoundsUp(t *testing.T) {
+	// 0.3 per unit, so 10k cost 3k
+	m := MarketSnapshot{Decimals: 18, Price: big.NewInt(3e17)}
+	checkRounding(t, func(amount *big.Int) *big.Int { return costOf(m, amount) }, []roundingCase{
+		{"exact", 1000, 300},
+		{"one wei over", 1001, 301},
+		{"one wei under", 999, 300},
+		{"zero", 0, 0},
+	})
+}
+
+func TestSeizeValueRoundsDown(t *testing.T) {
+	// An 8% incentive at 1 per unit, so 25k seize 27k
+	s := &Snapshot{LiquidationIncentive: big.NewInt(108e16)}
+	m := MarketSnapshot{Decimals: 18, Price: big.NewInt(1e18)}
+	checkRounding(t, func(amount *big.Int) *big.Int { return seizeValueOf(s, m, amount) }, []roundingCase{
+		{"exact", 2500, 2700},
+		{"one wei over", 2501, 2701},
+		{"one wei under", 2499, 2698},
+		{"under a unit", 12, 12},
+	})
+}
+
+func TestMinAmountOutRoundsDown(t *testing.T) {
+	// 1% slippage, so 100k return at least 99k
+	limit := big.NewInt(1e16)
+	checkRounding(t, func(expected *big.Int) *big.Int { return minAmountOutOf(expected, limit) }, []roundingCase{
+		{"exact", 10000, 9900},
+		{"one wei over", 10001, 9900},
+		{"one wei under", 9999, 9899},
+	})
+}
+
+func TestFeeRoundsUp(t *testing.T) {
+	// 30 bps, so 10000k pay 30k
+	bps := big.NewInt(30)
+	checkRounding(t, func(amount *big.Int) *big.Int { return feeOf(amount, bps) }, []roundingCase{
+		{"exact", 10000, 30},
+		{"one wei over", 10001, 31},
+		{"one wei under", 9999, 30},
+		{"zero", 0, 0},
+	})
+}
+
+// TestRepayNeverExceedsValue checks a repay amount rounded down never
+// costs more than the value it was sized for, even rounded up, at
+// prices that do not divide it.
+func TestRepayNeverExceedsValue(t *testing.T) {
+	for _, price := range []int64{3e18, 7e17, 1e18 + 1, 999999999999999999} {
+		m := MarketSnapshot{Decimals: 18, Price: big.NewInt(price)}
+		for _, value := range []int64{1e18 - 1, 1e18, 1e18 + 1} {
+			amount := repayAmountOf(m, big.NewInt(value))
+			if cost := costOf(m, amount); cost.Cmp(big.NewInt(value)) > 0 {
+				t.Errorf("repaying %v at price %d for value %d costs %v", amount, price, value, cost)
+			}
+		}
+	}
+}
+
+// comparands are integers of every sign, nil and large ones, nil
+// comparing as zero.
+var comparands = []*big.Int{nil, big.NewInt(0), big.NewInt(1), big.NewInt(-1), big.NewInt(1e18), mustInt("-1000000000000000000000000000000"), mustInt("1000000000000000000000000000001")}
+
+// sign returns the sign of x, nil being zero, regardless of orZero.
+func sign(x *big.Int) int {
+	if x == nil {
+		return 0
+	}
+	return x.Sign()
+}
+
+func TestSignPredicates(t *testing.T) {
+	for _, x := range comparands {
+		if positive := IsPositive(x); positive != (sign(x) == 1) {
+			t.Errorf("expected IsPositive(%v) to be %v", x, !positive)
+		}
+		if negative := IsNegative(x); negative != (sign(x) == -1) {
+			t.Errorf("expected IsNegative(%v) to be %v", x, !negative)
+		}
+		if isZero := IsZero(x); isZero != (sign(x) == 0) {
+			t.Errorf("expected IsZero(%v) to be %v", x, !isZero)
+		}
+	}
+}
+
+func TestComparisons(t *testing.T) {
+	for _, a := range comparands {
+		for _, b := range comparands {
+			if a != nil && b != nil {
+				cmp := a.Cmp(b)
+				if GT(a, b) != (cmp == 1) || GTE(a, b) != (cmp >= 0) || Equal(a, b) != (cmp == 0) {
+					t.Errorf("expected GT(%v, %v) %v, GTE %v and Equal %v", a, b, cmp == 1, cmp >= 0, cmp == 0)
+				}
+			}
+			// Exactly one of a > b, b > a and a == b holds
+			equal := Equal(a, b)
+			if n := boolCount(GT(a, b), GT(b, a), equal); n != 1 {
+				t.Errorf("expected a strict order of %v and %v, %d relations hold", a, b, n)
+			}
+
+			// Either input is returned, as is, unmodified
+			before := [2]string{fmt.Sprint(a), fmt.Sprint(b)}
+			min, max := Min(a, b), Max(a, b)
+			if (min != a && min != b) || (max != a && max != b) {
+				t.Errorf("expected Min and Max of %v and %v to return either", a, b)
+			}
+			if GT(min, a) || GT(min, b) || GT(a, max) || GT(b, max) {
+				t.Errorf("expected Min(%v, %v) = %v and Max = %v ordered", a, b, min, max)
+			}
+			// Ties return the first
+			if equal && (min != a || max != a) {
+				t.Errorf("expected Min and Max of equal %v and %v to return the first", a, b)
+			}
+			if after := [2]string{fmt.Sprint(a), fmt.Sprint(b)}; after != before {
+				t.Errorf("expected %v unmodified, got %v", before, after)
+			}
+		}
+	}
+	// Nil is zero
+	if !GTE(nil, big.NewInt(0)) || GT(nil, big.NewInt(0)) || !GT(big.NewInt(1), nil) || !GT(nil, big.NewInt(-1)) || !Equal(nil, big.NewInt(0)) {
+		t.Error("expected nil to compare as zero")
+	}
+}
+
+func boolCount(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
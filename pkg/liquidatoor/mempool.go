@@ -0,0 +1,245 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Blocks a pending liquidation is assumed to compete with ours for
+const pendingLiquidationBlocks = 5
+
+// PendingLiquidation is a liquidation of an account by someone else
+// seen in the mempool.
+type PendingLiquidation struct {
+	Tx       common.Hash
+	From     common.Address
+	Borrower common.Address
+	// cToken repaid
+	Market    common.Address
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+	Seen      time.Time
+}
+
+// MarshalJSON encodes the fees of the liquidation as decimal strings.
+func (p PendingLiquidation) MarshalJSON() ([]byte, error) {
+	type pending PendingLiquidation
+	return json.Marshal(struct {
+		pending
+		GasTipCap *decimalInt
+		GasFeeCap *decimalInt
+	}{pending(p), decimal(p.GasTipCap), decimal(p.GasFeeCap)})
+}
+
+func (p *PendingLiquidation) UnmarshalJSON(data []byte) error {
+	type pending PendingLiquidation
+	v := struct {
+		*pending
+		GasTipCap *decimalInt
+		GasFeeCap *decimalInt
+	}{pending: (*pending)(p)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	p.GasTipCap, p.GasFeeCap = v.GasTipCap.int(), v.GasFeeCap.int()
+	return nil
+}
+
+// Outbid raises the fees of opts above the ones of the pending
+// liquidation by the minimum replacement bump of 10%, plus 1 wei, so
+// ours is mined first. Fees are still capped when sent.
+func (p *PendingLiquidation) Outbid(opts *bind.TransactOpts) {
+	bump := func(fee *big.Int) *big.Int {
+		bumped := new(big.Int).Mul(fee, big.NewInt(110))
+		return bumped.Div(bumped, big.NewInt(100)).Add(bumped, common.Big1)
+	}
+	if opts.GasTipCap == nil || !GT(opts.GasTipCap, p.GasTipCap) {
+		opts.GasTipCap = bump(p.GasTipCap)
+	}
+	if opts.GasFeeCap == nil || !GT(opts.GasFeeCap, p.GasFeeCap) {
+		opts.GasFeeCap = bump(p.GasFeeCap)
+	}
+	if GT(opts.GasTipCap, opts.GasFeeCap) {
+		opts.GasFeeCap = new(big.Int).Set(opts.GasTipCap)
+	}
+}
+
+// mempoolWatch follows pending transactions for liquidations of the
+// accounts in the monitored markets by others. Providers without
+// pending transaction subscriptions disable it.
+type mempoolWatch struct {
+	logger Logger
+	rpc    *rpc.Client
+	signer types.Signer
+	// Our wallet
+	self common.Address
+	ttl  time.Duration
+	// liquidateBorrow of cTokens and cEther, by selector
+	methods map[string]*abi.Method
+
+	lock    sync.Mutex
+	markets map[common.Address]bool
+	// By borrower
+	pending map[common.Address]*PendingLiquidation
+}
+
+func newMempoolWatch(logger Logger, rpcClient *rpc.Client, chainID *big.Int, self common.Address, blockTime time.Duration) (*mempoolWatch, error) {
+	w := &mempoolWatch{
+		logger:  logger,
+		rpc:     rpcClient,
+		signer:  types.LatestSignerForChainID(chainID),
+		self:    self,
+		ttl:     pendingLiquidationBlocks * blockTime,
+		methods: make(map[string]*abi.Method),
+		markets: make(map[common.Address]bool),
+		pending: make(map[common.Address]*PendingLiquidation),
+	}
+	for _, metadata := range []*bind.MetaData{abis.CTokenMetaData, abis.CEtherMetaData} {
+		contractABI, err := metadata.GetAbi()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get ABI: %w", err)
+		}
+		method := contractABI.Methods["liquidateBorrow"]
+		w.methods[string(method.ID)] = &method
+	}
+	return w, nil
+}
+
+// watch adds markets to the monitored ones.
+func (w *mempoolWatch) watch(markets []common.Address) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, market := range markets {
+		w.markets[market] = true
+	}
+}
+
+// competitor returns the pending liquidation of borrower by someone
+// else, if any.
+func (w *mempoolWatch) competitor(borrower common.Address) *PendingLiquidation {
+	if w == nil {
+		return nil
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	p, ok := w.pending[borrower]
+	if !ok {
+		return nil
+	}
+	if time.Since(p.Seen) > w.ttl {
+		delete(w.pending, borrower)
+		return nil
+	}
+	return p
+}
+
+// run follows pending transactions until ctx is cancelled, with full
+// transactions if the provider can send them and by hash otherwise.
+func (w *mempoolWatch) run(ctx context.Context) {
+	if w.rpc == nil {
+		w.logger.Info("No RPC client; mempool monitoring disabled")
+		return
+	}
+
+	txs := make(chan *types.Transaction)
+	sub, err := w.rpc.EthSubscribe(ctx, txs, "newPendingTransactions", true)
+	if err == nil {
+		w.logger.Info("Monitoring the mempool for competing liquidations")
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-sub.Err():
+				w.logger.Warn(fmt.Sprintf("Pending transaction subscription failed; mempool monitoring disabled: %v", err), F("err", err))
+				return
+			case tx := <-txs:
+				w.observe(tx)
+			}
+		}
+	}
+
+	hashes := make(chan common.Hash)
+	sub, err = w.rpc.EthSubscribe(ctx, hashes, "newPendingTransactions")
+	if err != nil {
+		w.logger.Info(fmt.Sprintf("Provider cannot subscribe to pending transactions; mempool monitoring disabled: %v", err), F("err", err))
+		return
+	}
+	w.logger.Info("Monitoring the mempool for competing liquidations by transaction hash")
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-sub.Err():
+			w.logger.Warn(fmt.Sprintf("Pending transaction subscription failed; mempool monitoring disabled: %v", err), F("err", err))
+			return
+		case hash := <-hashes:
+			var tx *types.Transaction
+			if err := w.rpc.CallContext(ctx, &tx, "eth_getTransactionByHash", hash); err != nil || tx == nil {
+				continue
+			}
+			w.observe(tx)
+		}
+	}
+}
+
+// observe records tx if it liquidates an account of a monitored
+// market.
+func (w *mempoolWatch) observe(tx *types.Transaction) {
+	if tx.To() == nil || len(tx.Data()) < 4 {
+		return
+	}
+	method, ok := w.methods[string(tx.Data()[:4])]
+	if !ok {
+		return
+	}
+	w.lock.Lock()
+	monitored := w.markets[*tx.To()]
+	w.lock.Unlock()
+	if !monitored {
+		return
+	}
+	from, err := types.Sender(w.signer, tx)
+	if err != nil || from == w.self {
+		return
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil || len(args) == 0 {
+		return
+	}
+	borrower, ok := args[0].(common.Address)
+	if !ok {
+		return
+	}
+
+	p := &PendingLiquidation{
+		Tx:        tx.Hash(),
+		From:      from,
+		Borrower:  borrower,
+		Market:    *tx.To(),
+		GasTipCap: tx.GasTipCap(),
+		GasFeeCap: tx.GasFeeCap(),
+		Seen:      time.Now(),
+	}
+	w.lock.Lock()
+	w.pending[borrower] = p
+	w.lock.Unlock()
+	w.logger.Info(fmt.Sprintf("Pending liquidation of account %s by %s in market %s: tx %s", borrower, from, p.Market, p.Tx),
+		F("account", borrower), F("from", from), F("market", p.Market), F("tx", p.Tx), F("tip", p.GasTipCap))
+}
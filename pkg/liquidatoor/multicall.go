@@ -0,0 +1,125 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Multicall3 is deployed at the same address on nearly every chain.
+var multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+// CallResult is the outcome of a single call in a batch.
+type CallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// Multicaller batches read calls through whichever multicall variant
+// is deployed at the configured address. Multicall3 tolerates failures
+// of individual calls whereas the legacy multicall fails the whole
+// batch if any call reverts.
+type Multicaller struct {
+	client    headerReader
+	address   common.Address
+	batchSize int
+
+	// One per read connection, used round-robin
+	callers []multicallCaller
+	next    uint32
+	// Isolates the calls reverting legacy batches
+	bisector *bisector
+}
+
+// multicallCaller has the variant deployed set.
+type multicallCaller struct {
+	multicall3 *abis.Multicall3CallerRaw
+	multicall  Multicall
+}
+
+func newMulticaller(ctx context.Context, logger Logger, client Backend, readers []bind.ContractCaller, address common.Address, batchSize int, bisector *bisector) (*Multicaller, error) {
+	m := &Multicaller{client: client, address: address, batchSize: batchSize, bisector: bisector}
+
+	multicall3, err := abis.NewMulticall3Caller(address, client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate multicall3: %w", err)
+	}
+	raw := &abis.Multicall3CallerRaw{Contract: multicall3}
+
+	// Only Multicall3 implements aggregate3
+	var out []interface{}
+	isMulticall3 := raw.Call(&bind.CallOpts{Context: ctx}, &out, "aggregate3", []abis.Multicall3Call3{}) == nil
+	if isMulticall3 {
+		logger.Info(fmt.Sprintf("Using Multicall3 at %s", address), F("multicall", address))
+	} else {
+		logger.Info(fmt.Sprintf("Using legacy multicall at %s", address), F("multicall", address))
+	}
+
+	for _, reader := range readers {
+		if isMulticall3 {
+			multicall3, err := abis.NewMulticall3Caller(address, reader)
+			if err != nil {
+				return nil, fmt.Errorf("cannot instantiate multicall3: %w", err)
+			}
+			m.callers = append(m.callers, multicallCaller{multicall3: &abis.Multicall3CallerRaw{Contract: multicall3}})
+			continue
+		}
+		multicall, err := abis.NewMulticallCaller(address, reader)
+		if err != nil {
+			return nil, fmt.Errorf("cannot instantiate multicall: %w", err)
+		}
+		m.callers = append(m.callers, multicallCaller{multicall: multicall})
+	}
+	return m, nil
+}
+
+func (m *Multicaller) Aggregate(opts *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error) {
+	return aggregateChunked(m.client, opts, calls, m.batchSize, len(m.callers), m.aggregate)
+}
+
+func (m *Multicaller) aggregate(opts *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error) {
+	caller := m.callers[atomic.AddUint32(&m.next, 1)%uint32(len(m.callers))]
+	if caller.multicall3 != nil {
+		return aggregate3(caller.multicall3, opts, calls)
+	}
+
+	return m.bisector.aggregate(opts, calls, func(opts *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error) {
+		resp, err := caller.multicall.Aggregate(opts, calls)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]CallResult, len(resp.ReturnData))
+		for i, data := range resp.ReturnData {
+			results[i] = CallResult{Success: true, ReturnData: data}
+		}
+		return results, nil
+	})
+}
+
+func aggregate3(multicall3 *abis.Multicall3CallerRaw, opts *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error) {
+	calls3 := make([]abis.Multicall3Call3, len(calls))
+	for i, call := range calls {
+		calls3[i] = abis.Multicall3Call3{
+			Target:       call.Target,
+			AllowFailure: true,
+			CallData:     call.CallData,
+		}
+	}
+
+	var resp []abis.Multicall3Result
+	out := []interface{}{&resp}
+	if err := multicall3.Call(opts, &out, "aggregate3", calls3); err != nil {
+		return nil, err
+	}
+
+	results := make([]CallResult, len(resp))
+	for i, r := range resp {
+		results[i] = CallResult{Success: r.Success, ReturnData: r.ReturnData}
+	}
+	return results, nil
+}
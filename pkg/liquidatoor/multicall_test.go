@@ -0,0 +1,291 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+const multicallHead = 100
+
+// multicallReturn is what the target of call returns: its address
+// and calldata, or a revert if the calldata starts with 0xff.
+func multicallReturn(target common.Address, callData []byte) ([]byte, error) {
+	if len(callData) > 0 && callData[0] == 0xff {
+		return nil, errors.New("execution reverted")
+	}
+	return append(target.Bytes(), callData...), nil
+}
+
+// multicallChain is a node with Multicall3, or the legacy multicall if
+// legacy, deployed at multicall3Address, or nothing if empty. It
+// records the multicall methods called and the blocks they are called
+// at; every other call panics.
+type multicallChain struct {
+	Backend
+	empty bool
+	abi   *abi.ABI
+
+	lock    sync.Mutex
+	methods []string
+	blocks  []*big.Int
+}
+
+func newMulticallChain(t *testing.T, legacy bool) *multicallChain {
+	t.Helper()
+	metadata := abis.Multicall3MetaData
+	if legacy {
+		metadata = abis.MulticallMetaData
+	}
+	contractABI, err := metadata.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &multicallChain{abi: contractABI}
+}
+
+func (c *multicallChain) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(multicallHead)}, nil
+}
+
+func (c *multicallChain) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	if c.empty {
+		return nil, nil
+	}
+	return []byte{0x60}, nil
+}
+
+func (c *multicallChain) CallContract(_ context.Context, msg ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	method, err := c.abi.MethodById(msg.Data)
+	if err != nil {
+		// Not implemented by the variant deployed
+		return nil, errors.New("execution reverted")
+	}
+	c.lock.Lock()
+	c.methods = append(c.methods, method.Name)
+	c.blocks = append(c.blocks, block)
+	c.lock.Unlock()
+	inputs, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	switch method.Name {
+	case "aggregate3":
+		calls := *abi.ConvertType(inputs[0], new([]abis.Multicall3Call3)).(*[]abis.Multicall3Call3)
+		results := make([]abis.Multicall3Result, len(calls))
+		for i, call := range calls {
+			data, err := multicallReturn(call.Target, call.CallData)
+			results[i] = abis.Multicall3Result{Success: err == nil, ReturnData: data}
+		}
+		return method.Outputs.Pack(results)
+	case "aggregate":
+		// The legacy multicall reverts as a whole
+		calls := *abi.ConvertType(inputs[0], new([]abis.MulticallCall)).(*[]abis.MulticallCall)
+		returnData := make([][]byte, len(calls))
+		for i, call := range calls {
+			data, err := multicallReturn(call.Target, call.CallData)
+			if err != nil {
+				return nil, err
+			}
+			returnData[i] = data
+		}
+		return method.Outputs.Pack(big.NewInt(multicallHead), returnData)
+	}
+	return nil, errors.New("execution reverted")
+}
+
+func (c *multicallChain) called() ([]string, []*big.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.methods, c.blocks
+}
+
+// multicallCalls returns n calls of distinct targets, the ones at
+// reverting indices reverting.
+func multicallCalls(n int, reverting ...int) []abis.MulticallCall {
+	calls := make([]abis.MulticallCall, n)
+	for i := range calls {
+		calls[i] = abis.MulticallCall{Target: common.BigToAddress(big.NewInt(int64(i + 1))), CallData: []byte{byte(i), 0x01}}
+	}
+	for _, i := range reverting {
+		calls[i].CallData[0] = 0xff
+	}
+	return calls
+}
+
+// checkResults checks the calls at failed indices failed and every
+// other one returned what its target does.
+func checkResults(t *testing.T, calls []abis.MulticallCall, results []CallResult, failed ...int) {
+	t.Helper()
+	if len(results) != len(calls) {
+		t.Fatalf("expected %d results, got %d", len(calls), len(results))
+	}
+	reverted := make(map[int]bool)
+	for _, i := range failed {
+		reverted[i] = true
+	}
+	for i, call := range calls {
+		if reverted[i] {
+			if results[i].Success {
+				t.Fatalf("expected call %d to fail", i)
+			}
+			continue
+		}
+		expected, _ := multicallReturn(call.Target, call.CallData)
+		if !results[i].Success || !bytes.Equal(results[i].ReturnData, expected) {
+			t.Fatalf("expected call %d to return %x, got %v, %x", i, expected, results[i].Success, results[i].ReturnData)
+		}
+	}
+}
+
+func TestMulticallerAggregate3(t *testing.T) {
+	chain := newMulticallChain(t, false)
+	batcher, err := newCallBatcher(context.Background(), quietLogger(), chain, nil, nil, multicall3Address, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := batcher.(*Multicaller); !ok || m.callers[0].multicall3 == nil {
+		t.Fatalf("expected Multicall3 detected, got %T", batcher)
+	}
+
+	// Failing calls fail alone, in every chunk
+	calls := multicallCalls(5, 1, 4)
+	results, err := batcher.Aggregate(&bind.CallOpts{}, calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, calls, results, 1, 4)
+	methods, blocks := chain.called()
+	// The detection, then the three chunks pinned at the head
+	if len(methods) != 4 {
+		t.Fatalf("expected 4 calls, got %v", methods)
+	}
+	for i, method := range methods[1:] {
+		if method != "aggregate3" || blocks[i+1] == nil || blocks[i+1].Int64() != multicallHead {
+			t.Fatalf("expected aggregate3 at block %d, got %s at %v", multicallHead, method, blocks[i+1])
+		}
+	}
+
+	if results, err := batcher.Aggregate(&bind.CallOpts{}, nil); err != nil || len(results) != 0 {
+		t.Fatalf("expected no results of no calls, got %v, %v", results, err)
+	}
+}
+
+func TestMulticallerLegacyFallback(t *testing.T) {
+	chain := newMulticallChain(t, true)
+	batcher, err := newCallBatcher(context.Background(), quietLogger(), chain, nil, nil, multicall3Address, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := batcher.(*Multicaller); !ok || m.callers[0].multicall == nil {
+		t.Fatalf("expected the legacy multicall detected, got %T", batcher)
+	}
+	calls := multicallCalls(5)
+	results, err := batcher.Aggregate(&bind.CallOpts{BlockNumber: big.NewInt(42)}, calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, calls, results)
+	methods, blocks := chain.called()
+	for i, method := range methods {
+		if method != "aggregate" || blocks[i].Int64() != 42 {
+			t.Fatalf("expected aggregate at the block of the options, got %s at %v", method, blocks[i])
+		}
+	}
+
+	// A reverting call fails its whole batch, unless bisected
+	calls = multicallCalls(5, 3)
+	if _, err := batcher.Aggregate(&bind.CallOpts{}, calls); err == nil || !isRevert(err) {
+		t.Fatalf("expected the batch reverted, got %v", err)
+	}
+	bisector := newBisector(quietLogger(), func(context.Context) error { return nil }, time.Minute)
+	batcher, err = newCallBatcher(context.Background(), quietLogger(), chain, nil, nil, multicall3Address, 2, bisector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = batcher.Aggregate(&bind.CallOpts{}, calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, calls, results, 3)
+	if stats := bisector.Stats(); stats.Isolated != 1 || stats.Poisoned != 1 {
+		t.Fatalf("expected the reverting call isolated, got %+v", stats)
+	}
+}
+
+// ethService answers eth_call as the targets of multicallReturn do.
+type ethService struct {
+	lock   sync.Mutex
+	blocks []string
+}
+
+func (s *ethService) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	s.lock.Lock()
+	s.blocks = append(s.blocks, block)
+	s.lock.Unlock()
+	data, err := hexutil.Decode(args["data"].(string))
+	if err != nil {
+		return nil, err
+	}
+	return multicallReturn(common.HexToAddress(args["to"].(string)), data)
+}
+
+func TestCallBatcherRPCFallback(t *testing.T) {
+	chain := newMulticallChain(t, false)
+	chain.empty = true
+	if _, err := newCallBatcher(context.Background(), quietLogger(), chain, nil, nil, multicall3Address, 2, nil); err == nil {
+		t.Fatal("expected an error without a multicall or an RPC client")
+	}
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	eth := &ethService{}
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+	batcher, err := newCallBatcher(context.Background(), quietLogger(), chain, client, nil, multicall3Address, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := batcher.(*rpcBatcher); !ok {
+		t.Fatalf("expected JSON-RPC batches, got %T", batcher)
+	}
+
+	// Reverting calls fail alone, as with aggregate3
+	calls := multicallCalls(5, 0, 2)
+	results, err := batcher.Aggregate(&bind.CallOpts{}, calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, calls, results, 0, 2)
+	if methods, _ := chain.called(); len(methods) != 0 {
+		t.Fatalf("expected no multicall, got %v", methods)
+	}
+	eth.lock.Lock()
+	defer eth.lock.Unlock()
+	if len(eth.blocks) != len(calls) {
+		t.Fatalf("expected %d eth_calls, got %d", len(calls), len(eth.blocks))
+	}
+	for _, block := range eth.blocks {
+		if block != hexutil.EncodeBig(big.NewInt(multicallHead)) {
+			t.Fatalf("expected every call at block %d, got %s", multicallHead, block)
+		}
+	}
+}
@@ -0,0 +1,503 @@
+package liquidatoor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// natsMessage is a message published to the fake NATS server.
+type natsMessage struct {
+	subject string
+	payload []byte
+}
+
+// natsClient is a connection to the fake NATS server.
+type natsClient struct {
+	conn net.Conn
+	lock sync.Mutex
+	// Subscription ids by subject
+	sids map[string]string
+}
+
+func (c *natsClient) write(format string, args ...interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	fmt.Fprintf(c.conn, format, args...)
+}
+
+// fakeNATS is a NATS server speaking enough of the core protocol for
+// natsConn: it greets with INFO, checks the credentials of CONNECT if
+// set, answers PING, and routes PUB to the SUB of the subject. Every
+// message published is also sent to published.
+type fakeNATS struct {
+	t        *testing.T
+	listener net.Listener
+	// Accepted credentials, if any
+	user, pass string
+
+	lock     sync.Mutex
+	clients  []*natsClient
+	connects []map[string]interface{}
+	pongs    int
+	// Closed and replaced on every subscription
+	subscribed chan struct{}
+
+	published chan natsMessage
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATS{t: t, listener: listener, subscribed: make(chan struct{}), published: make(chan natsMessage, 1000)}
+	t.Cleanup(func() {
+		listener.Close()
+		s.kill()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATS) url() string {
+	return "nats://" + s.listener.Addr().String()
+}
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	c := &natsClient{conn: conn, sids: make(map[string]string)}
+	s.lock.Lock()
+	s.clients = append(s.clients, c)
+	s.lock.Unlock()
+	defer conn.Close()
+
+	c.write("INFO {\"server_id\":\"fake\",\"version\":\"2.10.0\",\"max_payload\":1048576}\r\n")
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			var options map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &options); err != nil {
+				c.write("-ERR 'Invalid Connect'\r\n")
+				return
+			}
+			s.lock.Lock()
+			s.connects = append(s.connects, options)
+			user, pass := s.user, s.pass
+			s.lock.Unlock()
+			if user != "" && (options["user"] != user || options["pass"] != pass) {
+				c.write("-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PING":
+			c.write("PONG\r\n")
+		case "PONG":
+			s.lock.Lock()
+			s.pongs++
+			s.lock.Unlock()
+		case "SUB":
+			c.lock.Lock()
+			c.sids[fields[1]] = fields[2]
+			c.lock.Unlock()
+			s.lock.Lock()
+			close(s.subscribed)
+			s.subscribed = make(chan struct{})
+			s.lock.Unlock()
+		case "PUB":
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			s.published <- natsMessage{subject: fields[1], payload: payload[:size]}
+			s.deliver(fields[1], payload[:size])
+		}
+	}
+}
+
+// deliver sends payload to the subscribers of subject.
+func (s *fakeNATS) deliver(subject string, payload []byte) {
+	s.lock.Lock()
+	clients := append([]*natsClient(nil), s.clients...)
+	s.lock.Unlock()
+	for _, c := range clients {
+		c.lock.Lock()
+		sid, ok := c.sids[subject]
+		c.lock.Unlock()
+		if ok {
+			c.write("MSG %s %s %d\r\n%s\r\n", subject, sid, len(payload), payload)
+		}
+	}
+}
+
+// ping pings every client.
+func (s *fakeNATS) ping() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, c := range s.clients {
+		c.write("PING\r\n")
+	}
+}
+
+// kill drops every connection.
+func (s *fakeNATS) kill() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, c := range s.clients {
+		c.conn.Close()
+	}
+	s.clients = nil
+}
+
+// waitSubscribed waits for a client to subscribe to subject.
+func (s *fakeNATS) waitSubscribed(subject string) {
+	s.t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		s.lock.Lock()
+		subscribed := s.subscribed
+		for _, c := range s.clients {
+			c.lock.Lock()
+			_, ok := c.sids[subject]
+			c.lock.Unlock()
+			if ok {
+				s.lock.Unlock()
+				return
+			}
+		}
+		s.lock.Unlock()
+		select {
+		case <-subscribed:
+		case <-deadline:
+			s.t.Fatalf("expected a subscription to %s", subject)
+		}
+	}
+}
+
+// next returns the next message published to subject, skipping the
+// ones to other subjects.
+func (s *fakeNATS) next(subject string) natsMessage {
+	s.t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-s.published:
+			if m.subject == subject {
+				return m
+			}
+		case <-deadline:
+			s.t.Fatalf("expected a message published to %s", subject)
+		}
+	}
+}
+
+// quiet checks nothing is published to subject for d.
+func (s *fakeNATS) quiet(subject string, d time.Duration) {
+	s.t.Helper()
+	deadline := time.After(d)
+	for {
+		select {
+		case m := <-s.published:
+			if m.subject == subject {
+				s.t.Fatalf("expected nothing published to %s, got %s", subject, m.payload)
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func TestDialNATS(t *testing.T) {
+	s := newFakeNATS(t)
+	s.lock.Lock()
+	s.user, s.pass = "desk", "secret"
+	s.lock.Unlock()
+	address := s.listener.Addr().String()
+
+	conn, err := dialNATS(context.Background(), "nats://desk:secret@"+address, "liquidatoor test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.close()
+	s.lock.Lock()
+	options := s.connects[0]
+	s.lock.Unlock()
+	if options["name"] != "liquidatoor test" || options["verbose"] != false || options["user"] != "desk" || options["pass"] != "secret" {
+		t.Fatalf("expected the name and credentials in CONNECT, got %v", options)
+	}
+
+	// Authorization errors arrive instead of the pong
+	if _, err := dialNATS(context.Background(), "nats://desk:wrong@"+address, "liquidatoor test"); err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Fatalf("expected the authorization error, got %v", err)
+	}
+	// Tokens are sent as such
+	s.lock.Lock()
+	s.user = ""
+	s.lock.Unlock()
+	conn, err = dialNATS(context.Background(), "nats://token@"+address, "liquidatoor test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.close()
+	s.lock.Lock()
+	options = s.connects[len(s.connects)-1]
+	s.lock.Unlock()
+	if options["auth_token"] != "token" || options["user"] != nil {
+		t.Fatalf("expected the token in CONNECT, got %v", options)
+	}
+}
+
+func TestDialNATSUnexpectedGreeting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "+OK\r\n")
+	}()
+	if _, err := dialNATS(context.Background(), "nats://"+listener.Addr().String(), "liquidatoor test"); err == nil || !strings.Contains(err.Error(), "greeting") {
+		t.Fatalf("expected the greeting refused, got %v", err)
+	}
+}
+
+func TestNATSConnRun(t *testing.T) {
+	s := newFakeNATS(t)
+	conn, err := dialNATS(context.Background(), s.url(), "liquidatoor test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 10)
+	if err := conn.subscribe("acks", func(payload []byte) { received <- string(payload) }); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- conn.run(ctx) }()
+	s.waitSubscribed("acks")
+
+	// Messages are dispatched to their subscription, with or without a
+	// reply subject
+	s.deliver("acks", []byte("first"))
+	s.lock.Lock()
+	s.clients[0].write("MSG acks 1 inbox.1 6\r\nsecond\r\n")
+	s.lock.Unlock()
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case payload := <-received:
+			if payload != expected {
+				t.Fatalf("expected %q, got %q", expected, payload)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %q delivered", expected)
+		}
+	}
+
+	// Pings of the server are answered
+	s.ping()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.lock.Lock()
+		pongs := s.pongs
+		s.lock.Unlock()
+		if pongs == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the ping answered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Publishing goes out while running
+	if err := conn.publish("candidates", []byte("event")); err != nil {
+		t.Fatal(err)
+	}
+	if m := s.next("candidates"); string(m.payload) != "event" {
+		t.Fatalf("expected the event published, got %q", m.payload)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error once cancelled, got %v", err)
+	}
+
+	// A dropped connection fails run
+	conn, err = dialNATS(context.Background(), s.url(), "liquidatoor test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { done <- conn.run(context.Background()) }()
+	s.kill()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected the dropped connection to fail run")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected run to return once the connection dropped")
+	}
+}
+
+// testPublisher returns a publisher to s, redelivering after
+// redelivery, buffering buffer events.
+func testPublisher(t *testing.T, s *fakeNATS, buffer int, redelivery time.Duration) *publisher {
+	t.Helper()
+	p, err := newPublisher(quietLogger(), s.url(), "", "", buffer, redelivery, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// publishedCandidate publishes a candidate event of account.
+func publishedCandidate(p *publisher, account int64) Candidate {
+	c := Candidate{Pool: common.HexToAddress("0xc0"), Account: common.BigToAddress(big.NewInt(account))}
+	p.publish(c, JournalEntry{Kind: JournalCandidate, Pool: c.Pool, Account: c.Account})
+	return c
+}
+
+func decodeEvent(t *testing.T, m natsMessage) PublishedEvent {
+	t.Helper()
+	var event PublishedEvent
+	if err := json.Unmarshal(m.payload, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestPublisherRedeliversUntilAcked(t *testing.T) {
+	s := newFakeNATS(t)
+	p := testPublisher(t, s, 10, 100*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.run(ctx)
+	s.waitSubscribed(p.ackSubject)
+
+	c := publishedCandidate(p, 1)
+	first := decodeEvent(t, s.next(p.subject))
+	if first.Version != PublishedSchemaVersion || first.Candidate != candidateID(c) || first.Entry.Account != c.Account {
+		t.Fatalf("unexpected event %+v", first)
+	}
+	// Unacknowledged, it is published again with the same id
+	for i := 0; i < 2; i++ {
+		if again := decodeEvent(t, s.next(p.subject)); again.ID != first.ID {
+			t.Fatalf("expected event %s redelivered, got %s", first.ID, again.ID)
+		}
+	}
+
+	// Until acknowledged, marking the candidate handled
+	ack, _ := json.Marshal(PublishedAck{ID: first.ID, Handled: true})
+	s.deliver(p.ackSubject, ack)
+	if !p.awaitHandled(ctx, c) {
+		t.Fatal("expected the candidate marked handled")
+	}
+	// A redelivery may have been in flight
+	s.quiet(p.subject, 200*time.Millisecond)
+	s.quiet(p.subject, 300*time.Millisecond)
+	stats := p.stats()
+	if stats.Published != 1 || stats.Redelivered < 2 || stats.Acked != 1 || stats.Handled != 1 || stats.Buffered != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	// Other candidates are not
+	if p.awaitHandled(ctx, Candidate{Pool: c.Pool, Account: common.HexToAddress("0x2")}) {
+		t.Fatal("expected a candidate not acknowledged as handled unhandled")
+	}
+}
+
+func TestPublisherReconnects(t *testing.T) {
+	s := newFakeNATS(t)
+	p := testPublisher(t, s, 10, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.run(ctx)
+	s.waitSubscribed(p.ackSubject)
+
+	publishedCandidate(p, 1)
+	first := decodeEvent(t, s.next(p.subject))
+	s.kill()
+	// Published while disconnected
+	publishedCandidate(p, 2)
+
+	// Unacknowledged events are published again over the new
+	// connection, in order, these ones before the redelivery is due
+	s.waitSubscribed(p.ackSubject)
+	if again := decodeEvent(t, s.next(p.subject)); again.ID != first.ID {
+		t.Fatalf("expected event %s published again, got %s", first.ID, again.ID)
+	}
+	second := decodeEvent(t, s.next(p.subject))
+	if second.ID == first.ID || second.Entry.Account != common.HexToAddress("0x2") {
+		t.Fatalf("expected the event published while disconnected, got %+v", second)
+	}
+	s.lock.Lock()
+	connects := len(s.connects)
+	s.lock.Unlock()
+	if connects != 2 {
+		t.Fatalf("expected 2 connections, got %d", connects)
+	}
+}
+
+func TestPublisherBufferOverflow(t *testing.T) {
+	s := newFakeNATS(t)
+	p := testPublisher(t, s, 3, time.Hour)
+	// Buffered before connecting, dropping the oldest
+	for account := int64(1); account <= 5; account++ {
+		publishedCandidate(p, account)
+	}
+	if stats := p.stats(); stats.Dropped != 2 || stats.Buffered != 3 {
+		t.Fatalf("expected 2 events dropped and 3 buffered, got %+v", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.run(ctx)
+	for account := int64(3); account <= 5; account++ {
+		if event := decodeEvent(t, s.next(p.subject)); event.Entry.Account != common.BigToAddress(big.NewInt(account)) {
+			t.Fatalf("expected the event of account %d, got %s", account, event.Entry.Account)
+		}
+	}
+	s.quiet(p.subject, 200*time.Millisecond)
+
+	// Acknowledgments of dropped or unknown events are ignored
+	s.deliver(p.ackSubject, []byte(`{"id":"unknown"}`))
+	s.deliver(p.ackSubject, []byte(`not json`))
+	if stats := p.stats(); stats.Acked != 0 || stats.Buffered != 3 {
+		t.Fatalf("expected nothing acknowledged, got %+v", stats)
+	}
+}
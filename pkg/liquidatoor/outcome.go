@@ -0,0 +1,313 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/exp"
+)
+
+// Deviation of realized from predicted values, scaled by 1e18, over
+// which a warning is raised by default
+var defaultOutcomeDriftTolerance = big.NewInt(5e16)
+
+// RealizedLiquidation is what a mined liquidation did, parsed from the
+// LiquidateBorrow, Transfer and Redeem events of its receipt.
+type RealizedLiquidation struct {
+	Block            uint64
+	Liquidator       common.Address
+	BorrowMarket     common.Address
+	CollateralMarket common.Address
+	RepayAmount      *big.Int
+	// Collateral cTokens seized from the borrower and received by the
+	// liquidator, less any protocol share
+	SeizeTokens    *big.Int
+	ReceivedTokens *big.Int
+	// Underlying of the received cTokens at the exchange rate of the
+	// block
+	SeizedUnderlying *big.Int
+	// Underlying redeemed from the collateral market, if any
+	Redeemed *big.Int
+	// At the prices of the block, in the oracle's unit of account
+	// scaled by 1e18
+	RepayValue *big.Int
+	SeizeValue *big.Int
+	GasUsed    uint64
+}
+
+// MarshalJSON encodes the amounts and values of the liquidation as
+// decimal strings.
+func (r RealizedLiquidation) MarshalJSON() ([]byte, error) {
+	type liquidation RealizedLiquidation
+	return json.Marshal(struct {
+		liquidation
+		RepayAmount      *decimalInt
+		SeizeTokens      *decimalInt
+		ReceivedTokens   *decimalInt
+		SeizedUnderlying *decimalInt
+		Redeemed         *decimalInt
+		RepayValue       *decimalInt
+		SeizeValue       *decimalInt
+	}{liquidation(r), decimal(r.RepayAmount), decimal(r.SeizeTokens), decimal(r.ReceivedTokens), decimal(r.SeizedUnderlying), decimal(r.Redeemed), decimal(r.RepayValue), decimal(r.SeizeValue)})
+}
+
+func (r *RealizedLiquidation) UnmarshalJSON(data []byte) error {
+	type liquidation RealizedLiquidation
+	v := struct {
+		*liquidation
+		RepayAmount      *decimalInt
+		SeizeTokens      *decimalInt
+		ReceivedTokens   *decimalInt
+		SeizedUnderlying *decimalInt
+		Redeemed         *decimalInt
+		RepayValue       *decimalInt
+		SeizeValue       *decimalInt
+	}{liquidation: (*liquidation)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.RepayAmount, r.SeizeTokens, r.ReceivedTokens, r.SeizedUnderlying, r.Redeemed, r.RepayValue, r.SeizeValue = v.RepayAmount.int(), v.SeizeTokens.int(), v.ReceivedTokens.int(), v.SeizedUnderlying.int(), v.Redeemed.int(), v.RepayValue.int(), v.SeizeValue.int()
+	return nil
+}
+
+// execute executes a candidate with the configured executor, once its
+// plan is validated on the fork, if any, and completes its outcome with
+// the liquidation parsed from the receipt.
+func (l *Liquidatoor) execute(ctx context.Context, c Candidate) (*Outcome, error) {
+	if err := l.validateOnFork(ctx, c); err != nil {
+		return nil, err
+	}
+	outcome, err := l.executor.Execute(ctx, c)
+	if outcome == nil || outcome.Tx == (common.Hash{}) {
+		return outcome, err
+	}
+	// Mined
+	l.pending.done(outcome.Tx)
+	outcome.Block, outcome.Predicted = c.Block, c.Estimate
+	if realizeErr := l.realize(ctx, c, outcome); realizeErr != nil {
+		l.logger.Warn(fmt.Sprintf("Failed to parse the receipt of liquidation %s of account %s: %v", outcome.Tx, c.Account, realizeErr),
+			F("pool", l.comptrollerAddress), F("account", c.Account), F("tx", outcome.Tx), F("err", realizeErr))
+	}
+	return outcome, err
+}
+
+// realize parses the receipt of the outcome transaction and fills in
+// the realized liquidation, and the PnL if the executor did not.
+func (l *Liquidatoor) realize(ctx context.Context, c Candidate, outcome *Outcome) error {
+	receipt, err := l.client.TransactionReceipt(ctx, outcome.Tx)
+	if err != nil {
+		return fmt.Errorf("cannot get receipt: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil
+	}
+	realized, err := l.parseLiquidation(c.Account, receipt)
+	if err != nil {
+		return err
+	}
+	if realized == nil {
+		if failures, err := l.parseFailures(receipt); err == nil && len(failures) > 0 {
+			return fmt.Errorf("liquidation of account %s failed: %s", c.Account, formatFailures(failures))
+		}
+		return fmt.Errorf("no LiquidateBorrow event of account %s", c.Account)
+	}
+
+	block := receipt.BlockNumber
+	borrow, collateral := l.underlyingInfo[realized.BorrowMarket.String()], l.underlyingInfo[realized.CollateralMarket.String()]
+	borrowPrice, err := l.priceSource.PriceOf(ctx, Asset{Market: realized.BorrowMarket, Underlying: borrow.address, Decimals: borrow.decimals}, block)
+	if err != nil {
+		return fmt.Errorf("cannot price %s at block %v: %w", borrow.name, block, err)
+	}
+	collateralPrice, err := l.priceSource.PriceOf(ctx, Asset{Market: realized.CollateralMarket, Underlying: collateral.address, Decimals: collateral.decimals}, block)
+	if err != nil {
+		return fmt.Errorf("cannot price %s at block %v: %w", collateral.name, block, err)
+	}
+	cToken, err := abis.NewCToken(realized.CollateralMarket, l.client)
+	if err != nil {
+		return fmt.Errorf("cannot get interface for market %s: %w", realized.CollateralMarket, err)
+	}
+	exchangeRate, err := cToken.ExchangeRateStored(&bind.CallOpts{Context: ctx, BlockNumber: block})
+	if err != nil {
+		return fmt.Errorf("cannot get exchange rate of %s at block %v: %w", realized.CollateralMarket, block, err)
+	}
+	realized.SeizedUnderlying = exp.MulScalarTruncate(new(big.Int), exchangeRate, realized.ReceivedTokens)
+	realized.RepayValue = borrowPrice.Value(realized.RepayAmount)
+	realized.SeizeValue = collateralPrice.Value(realized.SeizedUnderlying)
+	outcome.Liquidation = realized
+
+	if outcome.PnL == nil {
+		if outcome.PnL, err = l.realizedPnL(ctx, outcome.Tx, receipt, realized); err != nil {
+			return err
+		}
+	}
+	l.checkDrift(c, realized)
+	return nil
+}
+
+// parseLiquidation returns the liquidation of account in receipt, if
+// any.
+func (l *Liquidatoor) parseLiquidation(account common.Address, receipt *types.Receipt) (*RealizedLiquidation, error) {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	filterer, err := abis.NewCTokenFilterer(common.Address{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate ctoken filterer: %w", err)
+	}
+
+	var realized *RealizedLiquidation
+	for _, log := range receipt.Logs {
+		if len(log.Topics) == 0 || log.Topics[0] != cTokenABI.Events["LiquidateBorrow"].ID {
+			continue
+		}
+		event, err := filterer.ParseLiquidateBorrow(*log)
+		if err != nil || event.Borrower != account {
+			continue
+		}
+		realized = &RealizedLiquidation{
+			Block:            receipt.BlockNumber.Uint64(),
+			Liquidator:       event.Liquidator,
+			BorrowMarket:     log.Address,
+			CollateralMarket: event.CTokenCollateral,
+			RepayAmount:      event.RepayAmount,
+			SeizeTokens:      event.SeizeTokens,
+			ReceivedTokens:   new(big.Int),
+			Redeemed:         new(big.Int),
+			GasUsed:          receipt.GasUsed,
+		}
+		break
+	}
+	if realized == nil {
+		return nil, nil
+	}
+
+	for _, log := range receipt.Logs {
+		if log.Address != realized.CollateralMarket || len(log.Topics) == 0 {
+			continue
+		}
+		switch log.Topics[0] {
+		case cTokenABI.Events["Transfer"].ID:
+			event, err := filterer.ParseTransfer(*log)
+			if err == nil && event.From == account && event.To == realized.Liquidator {
+				realized.ReceivedTokens.Add(realized.ReceivedTokens, event.Amount)
+			}
+		case cTokenABI.Events["Redeem"].ID:
+			event, err := filterer.ParseRedeem(*log)
+			if err == nil && event.Redeemer == realized.Liquidator {
+				realized.Redeemed.Add(realized.Redeemed, event.RedeemAmount)
+			}
+		}
+	}
+	// Versions without a protocol share may not emit the transfer
+	if IsZero(realized.ReceivedTokens) {
+		realized.ReceivedTokens.Set(realized.SeizeTokens)
+	}
+	return realized, nil
+}
+
+// parseFailures returns the failures emitted in receipt: versions of
+// the markets and comptrollers that return error codes rather than
+// revert emit a Failure event and succeed.
+func (l *Liquidatoor) parseFailures(receipt *types.Receipt) ([]abis.Failure, error) {
+	filterer, err := abis.NewCTokenFilterer(common.Address{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate ctoken filterer: %w", err)
+	}
+	var failures []abis.Failure
+	for _, log := range receipt.Logs {
+		if len(log.Topics) == 0 || log.Topics[0] != l.comptrollerABI.Events["Failure"].ID {
+			continue
+		}
+		event, err := filterer.ParseFailure(*log)
+		if err != nil {
+			continue
+		}
+		reporter := abis.TokenReporter
+		if log.Address == l.comptrollerAddress {
+			reporter = abis.ComptrollerReporter
+		}
+		failures = append(failures, reporter.Decode(event.Error, event.Info, event.Detail))
+	}
+	return failures, nil
+}
+
+func formatFailures(failures []abis.Failure) string {
+	s := make([]string, len(failures))
+	for i, failure := range failures {
+		s[i] = failure.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// realizedPnL is the realized gross profit in wei of the native token,
+// less the gas paid when the transaction can be fetched.
+func (l *Liquidatoor) realizedPnL(ctx context.Context, hash common.Hash, receipt *types.Receipt, realized *RealizedLiquidation) (*big.Int, error) {
+	gross := new(big.Int).Sub(realized.SeizeValue, realized.RepayValue)
+	pnl := gross
+	for market, info := range l.underlyingInfo {
+		if !info.native {
+			continue
+		}
+		price, err := l.priceSource.PriceOf(ctx, Asset{Market: common.HexToAddress(market), Decimals: info.decimals}, receipt.BlockNumber)
+		if err != nil {
+			return nil, fmt.Errorf("cannot price %s at block %v: %w", info.name, receipt.BlockNumber, err)
+		}
+		pnl = MarketSnapshot{Price: price.Mantissa}.Amount(gross)
+		break
+	}
+
+	reader, ok := l.client.(interface {
+		TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	})
+	if !ok {
+		return pnl, nil
+	}
+	tx, _, err := reader.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get transaction %s: %w", hash, err)
+	}
+	paid, err := gasPaid(ctx, l.client, tx, receipt)
+	if err != nil {
+		return nil, err
+	}
+	return pnl.Sub(pnl, paid), nil
+}
+
+// checkDrift warns when the realized liquidation deviates from the
+// plan of the candidate beyond the tolerance.
+func (l *Liquidatoor) checkDrift(c Candidate, realized *RealizedLiquidation) {
+	if c.Plan == nil {
+		return
+	}
+	predictedGross := new(big.Int).Sub(c.Plan.SeizeValue, c.Plan.RepayValue)
+	realizedGross := new(big.Int).Sub(realized.SeizeValue, realized.RepayValue)
+	for _, value := range []struct {
+		name                string
+		predicted, realized *big.Int
+	}{
+		{"repay amount", c.Plan.RepayAmount, realized.RepayAmount},
+		{"seize value", c.Plan.SeizeValue, realized.SeizeValue},
+		{"gross profit", predictedGross, realizedGross},
+	} {
+		if IsZero(value.predicted) {
+			continue
+		}
+		diff := new(big.Int).Sub(value.realized, value.predicted)
+		drift := NewRatio(diff.Abs(diff), new(big.Int).Abs(value.predicted))
+		if !drift.Exceeds(mantissaRatio(l.outcomeDriftTolerance)) {
+			continue
+		}
+		l.logger.Warn(fmt.Sprintf("Realized %s of liquidating account %s is %v, predicted %v; off by %s", value.name, c.Account, value.realized, value.predicted, drift.Percent()),
+			F("pool", l.comptrollerAddress), F("account", c.Account), F("field", value.name),
+			F("predicted", value.predicted), F("realized", value.realized), F("drift", drift.String()))
+	}
+}
@@ -0,0 +1,154 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/exp"
+)
+
+// LiquidationPair is the pair of markets to liquidate an account in,
+// as SelectLiquidationPair finds it.
+type LiquidationPair struct {
+	BorrowMarket     common.Address
+	CollateralMarket common.Address
+	// Up to the close factor of the borrow balance, in the underlying
+	// of the borrow market
+	RepayAmount *big.Int
+	// Collateral cTokens seized for the repay amount
+	SeizeTokens *big.Int
+	// Value repaid, and value of the underlying of the cTokens seized,
+	// at the oracle prices, in the oracle's unit of account scaled by
+	// 1e18
+	RepayValue *big.Int
+	SeizeValue *big.Int
+	// Value seized less value repaid; before gas
+	Profit *big.Int
+}
+
+// plan returns the liquidation of account in the pair.
+func (p *LiquidationPair) plan(account common.Address) LiquidationPlan {
+	return LiquidationPlan{
+		Borrower:         account,
+		BorrowMarket:     p.BorrowMarket,
+		CollateralMarket: p.CollateralMarket,
+		RepayAmount:      p.RepayAmount,
+		RepayValue:       p.RepayValue,
+		SeizeValue:       p.SeizeValue,
+	}
+}
+
+// SelectLiquidationPair returns the pair of the markets in assets
+// account borrows in and supplies to that is the most profitable to
+// liquidate at the latest block. Every borrow is planned against every
+// collateral as the default strategy plans a pair, and valued at what
+// the comptroller's liquidateCalculateSeizeTokens seizes; collateral
+// markets holding fewer cTokens of the account are skipped, as seizing
+// would revert. Accounts in a single market fail with ErrSingleAsset.
+func (l *Liquidatoor) SelectLiquidationPair(ctx context.Context, account common.Address, assets []common.Address) (*LiquidationPair, error) {
+	if len(assets) < 2 {
+		return nil, fmt.Errorf("cannot select liquidation pair of account %s: %w", account, ErrSingleAsset)
+	}
+	prices, err := pricesOf(ctx, l.logger, l.priceSource, l.assets(assets), nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get prices: %w", err)
+	}
+	return l.selectPair(ctx, l.snapshot(nil, assets, prices, nil), account, assets)
+}
+
+// selectPair selects the liquidation pair of account at the prices and
+// liquidation params of s, as SelectLiquidationPair does.
+func (l *Liquidatoor) selectPair(ctx context.Context, s *Snapshot, account common.Address, assets []common.Address) (*LiquidationPair, error) {
+	if len(assets) < 2 {
+		return nil, fmt.Errorf("cannot select liquidation pair of account %s: %w", account, ErrSingleAsset)
+	}
+	if s.CloseFactor == nil || s.LiquidationIncentive == nil || !IsPositive(s.LiquidationIncentive) {
+		return nil, fmt.Errorf("cannot select liquidation pair without liquidation params: %w", ErrStaleData)
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	var borrows, collaterals []*positionValue
+	for _, address := range assets {
+		cToken, ok := l.LendMarkets[address.String()]
+		if !ok {
+			return nil, fmt.Errorf("%s is not a market of pool %s", address, l.comptrollerAddress)
+		}
+		market, ok := s.Markets[address]
+		if !ok || !IsPositive(market.Price) {
+			continue
+		}
+		info := l.underlyingInfo[address.String()]
+		borrowed, err := cToken.BorrowBalanceStored(opts, account)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get borrow balance of account %s in %s: %w", account, info.name, err)
+		}
+		if IsPositive(borrowed) {
+			borrows = append(borrows, &positionValue{market: market, amount: borrowed, value: market.Value(borrowed)})
+		}
+		// Collateral that cannot be seized is skipped
+		if market.SeizePaused {
+			continue
+		}
+		supplied, err := cToken.BalanceOfUnderlying(opts, account)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get supply of account %s in %s: %w", account, info.name, err)
+		}
+		if IsPositive(supplied) {
+			collaterals = append(collaterals, &positionValue{market: market, amount: supplied, value: market.Value(supplied)})
+		}
+	}
+
+	// cTokens held and exchange rates, by collateral market
+	tokens := make(map[common.Address]*big.Int, len(collaterals))
+	exchangeRates := make(map[common.Address]*big.Int, len(collaterals))
+	var best *LiquidationPair
+	for _, borrow := range borrows {
+		for _, collateral := range collaterals {
+			plan := planLiquidation(s, account, borrow, collateral)
+			if !IsPositive(plan.RepayAmount) {
+				continue
+			}
+			code, seizeTokens, err := l.Comptroller.LiquidateCalculateSeizeTokens(opts, plan.BorrowMarket, plan.CollateralMarket, plan.RepayAmount)
+			if err != nil {
+				return nil, fmt.Errorf("cannot calculate seize tokens of %s for %s: %w", plan.CollateralMarket, plan.BorrowMarket, err)
+			}
+			cToken := l.LendMarkets[plan.CollateralMarket.String()]
+			if _, ok := tokens[plan.CollateralMarket]; !ok {
+				if tokens[plan.CollateralMarket], err = cToken.BalanceOf(opts, account); err != nil {
+					return nil, fmt.Errorf("cannot get balance of account %s in %s: %w", account, plan.CollateralMarket, err)
+				}
+			}
+			if IsPositive(code) || GT(seizeTokens, tokens[plan.CollateralMarket]) {
+				l.logger.Debug(fmt.Sprintf("Skipping collateral %s of account %s for %s: seizes %v of %v cTokens held, error %v", plan.CollateralMarket, account, plan.BorrowMarket, seizeTokens, tokens[plan.CollateralMarket], code),
+					F("pool", l.comptrollerAddress), F("account", account), F("market", plan.CollateralMarket))
+				continue
+			}
+			if _, ok := exchangeRates[plan.CollateralMarket]; !ok {
+				if exchangeRates[plan.CollateralMarket], err = cToken.ExchangeRateStored(opts); err != nil {
+					return nil, fmt.Errorf("cannot get exchange rate of %s: %w", plan.CollateralMarket, err)
+				}
+			}
+			seized := exp.MulScalarTruncate(new(big.Int), orZero(exchangeRates[plan.CollateralMarket]), seizeTokens)
+			seizeValue := collateral.market.Value(seized)
+			profit := new(big.Int).Sub(seizeValue, plan.RepayValue)
+			if best == nil || GT(profit, best.Profit) {
+				best = &LiquidationPair{
+					BorrowMarket:     plan.BorrowMarket,
+					CollateralMarket: plan.CollateralMarket,
+					RepayAmount:      plan.RepayAmount,
+					SeizeTokens:      seizeTokens,
+					RepayValue:       plan.RepayValue,
+					SeizeValue:       seizeValue,
+					Profit:           profit,
+				}
+			}
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("cannot select liquidation pair of account %s: %w", account, errNoPlan)
+	}
+	return best, nil
+}
@@ -0,0 +1,145 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/fakes"
+)
+
+var (
+	pairBorrower = common.HexToAddress("0x1000")
+	pairBorrowed = common.HexToAddress("0xa")
+	// The larger collateral, and the smaller one
+	pairLarge = common.HexToAddress("0xb")
+	pairSmall = common.HexToAddress("0xc")
+)
+
+// pairLiquidatoor is a pool where pairBorrower borrows 10000 of
+// pairBorrowed and supplies 8000 of pairLarge and 6000 of pairSmall,
+// every unit worth one, holding tokens of each, every cToken worth 20
+// units. Every liquidation seizes seizeTokens.
+func pairLiquidatoor(t *testing.T, seizeTokens int64, tokens map[common.Address]int64) *Liquidatoor {
+	t.Helper()
+	adapter, err := newCompoundProtocolAdapter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	market := func(supplied, borrowed, tokens int64) *fakes.CToken {
+		return &fakes.CToken{
+			Balances:       map[common.Address]*big.Int{pairBorrower: big.NewInt(supplied)},
+			BorrowBalances: map[common.Address]*big.Int{pairBorrower: big.NewInt(borrowed)},
+			Tokens:         map[common.Address]*big.Int{pairBorrower: big.NewInt(tokens)},
+			ExchangeRate:   new(big.Int).Mul(big.NewInt(20), big.NewInt(1e18)),
+		}
+	}
+	l := &Liquidatoor{
+		logger:             quietLogger(),
+		comptrollerAddress: common.HexToAddress("0xc0"),
+		adapter:            adapter,
+		pauses:             newPauseState(),
+		Comptroller:        &fakes.Comptroller{SeizeTokens: big.NewInt(seizeTokens)},
+		LendMarkets: map[string]CToken{
+			pairBorrowed.String(): market(0, 10000, tokens[pairBorrowed]),
+			pairLarge.String():    market(8000, 0, tokens[pairLarge]),
+			pairSmall.String():    market(6000, 0, tokens[pairSmall]),
+		},
+		underlyingInfo: map[string]UnderlyingInfo{
+			pairBorrowed.String(): {name: "A", decimals: 18},
+			pairLarge.String():    {name: "B", decimals: 18},
+			pairSmall.String():    {name: "C", decimals: 18},
+		},
+		priceSource: NewStaticPriceSource(map[common.Address]*Price{
+			pairBorrowed: {Mantissa: big.NewInt(1e18)},
+			pairLarge:    {Mantissa: big.NewInt(1e18)},
+			pairSmall:    {Mantissa: big.NewInt(1e18)},
+		}),
+	}
+	l.params.Store(&liquidationParams{closeFactor: big.NewInt(5e17), incentive: big.NewInt(108e16)})
+	return l
+}
+
+func TestSelectLiquidationPair(t *testing.T) {
+	assets := []common.Address{pairBorrowed, pairLarge, pairSmall}
+	for _, tc := range []struct {
+		name        string
+		seizeTokens int64
+		// Units a cToken is worth, by market, if not 20
+		exchangeRates map[common.Address]int64
+		tokens        map[common.Address]int64
+		collateral    common.Address
+		repay         int64
+		err           error
+	}{
+		// The close factor of the borrow, against either collateral,
+		// seizes the repay value times the incentive; the first is
+		// kept
+		{name: "largest collateral", seizeTokens: 270, tokens: map[common.Address]int64{pairLarge: 400, pairSmall: 400},
+			collateral: pairLarge, repay: 5000},
+		// What is seized decides, rather than the incentive
+		{name: "collateral seizing more", seizeTokens: 270, exchangeRates: map[common.Address]int64{pairSmall: 21}, tokens: map[common.Address]int64{pairLarge: 400, pairSmall: 400},
+			collateral: pairSmall, repay: 5000},
+		{name: "exactly the tokens seized", seizeTokens: 270, tokens: map[common.Address]int64{pairLarge: 270},
+			collateral: pairLarge, repay: 5000},
+		// Seizing more cTokens than held reverts
+		{name: "largest collateral holds too few tokens", seizeTokens: 270, tokens: map[common.Address]int64{pairLarge: 269, pairSmall: 400},
+			collateral: pairSmall, repay: 5000},
+		{name: "every collateral holds too few tokens", seizeTokens: 270, tokens: map[common.Address]int64{pairLarge: 269, pairSmall: 100},
+			err: errNoPlan},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := pairLiquidatoor(t, tc.seizeTokens, tc.tokens)
+			exchangeRate := int64(20)
+			for market, rate := range tc.exchangeRates {
+				l.LendMarkets[market.String()].(*fakes.CToken).ExchangeRate = new(big.Int).Mul(big.NewInt(rate), big.NewInt(1e18))
+				if market == tc.collateral {
+					exchangeRate = rate
+				}
+			}
+			pair, err := l.SelectLiquidationPair(context.Background(), pairBorrower, assets)
+			if !errors.Is(err, tc.err) || (err != nil && tc.err == nil) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+			if tc.err != nil {
+				return
+			}
+			// Valued at the underlying of the cTokens seized
+			if pair.BorrowMarket != pairBorrowed || pair.CollateralMarket != tc.collateral || pair.RepayAmount.Cmp(big.NewInt(tc.repay)) != 0 ||
+				pair.SeizeTokens.Int64() != tc.seizeTokens || pair.Profit.Cmp(big.NewInt(tc.seizeTokens*exchangeRate-tc.repay)) != 0 {
+				t.Fatalf("expected a repay of %d seizing %s, got %+v", tc.repay, tc.collateral, pair)
+			}
+		})
+	}
+}
+
+func TestSelectLiquidationPairSingleAsset(t *testing.T) {
+	l := pairLiquidatoor(t, 270, nil)
+	for _, assets := range [][]common.Address{nil, {pairBorrowed}} {
+		if _, err := l.SelectLiquidationPair(context.Background(), pairBorrower, assets); !errors.Is(err, ErrSingleAsset) {
+			t.Fatalf("expected ErrSingleAsset for %d assets, got %v", len(assets), err)
+		}
+	}
+	if _, err := l.SelectLiquidationPair(context.Background(), pairBorrower, []common.Address{pairBorrowed, common.HexToAddress("0xd")}); err == nil {
+		t.Fatal("expected an error for a market outside the pool")
+	}
+}
+
+func TestSelectLiquidationPairBorrows(t *testing.T) {
+	// The borrower also borrows 20000 of pairSmall, and repaying it
+	// seizes the most, of pairLarge only
+	l := pairLiquidatoor(t, 270, map[common.Address]int64{pairLarge: 400, pairSmall: 300})
+	l.LendMarkets[pairSmall.String()].(*fakes.CToken).BorrowBalances[pairBorrower] = big.NewInt(20000)
+	l.Comptroller.(*fakes.Comptroller).Seizes = map[common.Address]*big.Int{pairSmall: big.NewInt(400)}
+	pair, err := l.SelectLiquidationPair(context.Background(), pairBorrower, []common.Address{pairBorrowed, pairLarge, pairSmall})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Up to the value of the collateral over the incentive
+	if pair.BorrowMarket != pairSmall || pair.CollateralMarket != pairLarge || pair.RepayAmount.Int64() != 7407 || pair.Profit.Int64() != 400*20-7407 {
+		t.Fatalf("expected to repay pairSmall against pairLarge, got %+v", pair)
+	}
+}
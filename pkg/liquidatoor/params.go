@@ -0,0 +1,114 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Blocks between reads of the liquidation params, in case events are
+// missed
+const paramsReconcileInterval = 100
+
+// liquidationParams are the close factor and liquidation incentive
+// mantissas of a pool, which every plan depends on.
+type liquidationParams struct {
+	closeFactor *big.Int
+	incentive   *big.Int
+}
+
+func (l *Liquidatoor) liquidationParams() *liquidationParams {
+	return l.params.Load().(*liquidationParams)
+}
+
+// setLiquidationParams replaces the params of the pool and, if they
+// changed, alerts and drops the queued executions planned under the
+// old ones.
+func (l *Liquidatoor) setLiquidationParams(params *liquidationParams, source string) {
+	old, _ := l.params.Load().(*liquidationParams)
+	l.params.Store(params)
+	if old == nil || (Equal(old.closeFactor, params.closeFactor) && Equal(old.incentive, params.incentive)) {
+		return
+	}
+
+	l.logger.Warn(fmt.Sprintf("Liquidation params of pool %s changed (%s): close factor %v -> %v, liquidation incentive %v -> %v",
+		l.comptrollerAddress, source, old.closeFactor, params.closeFactor, old.incentive, params.incentive),
+		F("pool", l.comptrollerAddress), F("source", source),
+		F("oldCloseFactor", old.closeFactor), F("closeFactor", params.closeFactor),
+		F("oldIncentive", old.incentive), F("incentive", params.incentive))
+	if dropped := l.queue.Invalidate(l.comptrollerAddress); dropped > 0 {
+		l.logger.Info(fmt.Sprintf("Dropped %d executions planned under the old liquidation params", dropped),
+			F("pool", l.comptrollerAddress), F("dropped", dropped))
+	}
+}
+
+// reconcileLiquidationParams reads the params every
+// paramsReconcileInterval blocks. A nil block always reads them.
+func (l *Liquidatoor) reconcileLiquidationParams(ctx context.Context, block *big.Int) error {
+	if block != nil {
+		number := block.Uint64()
+		if number < l.paramsReadAt+paramsReconcileInterval {
+			return nil
+		}
+		l.paramsReadAt = number
+	}
+	closeFactor, incentive, err := l.adapter.LiquidationParams(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return err
+	}
+	l.setLiquidationParams(&liquidationParams{closeFactor: closeFactor, incentive: incentive}, "read")
+	return nil
+}
+
+// watchLiquidationParams applies NewCloseFactor and
+// NewLiquidationIncentive events until ctx is cancelled, reading the
+// params whenever events are lost or a reorg removes one.
+func (l *Liquidatoor) watchLiquidationParams(ctx context.Context) {
+	filterer, err := abis.NewComptrollerFilterer(l.comptrollerAddress, l.client)
+	if err != nil {
+		l.logger.Error(fmt.Sprintf("Cannot watch liquidation params: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		return
+	}
+	closeFactor, incentive := l.comptrollerABI.Events["NewCloseFactor"], l.comptrollerABI.Events["NewLiquidationIncentive"]
+	query := ethereum.FilterQuery{Addresses: []common.Address{l.comptrollerAddress}, Topics: [][]common.Hash{{closeFactor.ID, incentive.ID}}}
+
+	l.bus.subscribe(ctx, "liquidation param events", query, func(log types.Log) {
+		if log.Removed {
+			// The params before cannot be told from the event
+			if err := l.reconcileLiquidationParams(ctx, nil); err != nil {
+				l.logger.Warn(fmt.Sprintf("Failed to read liquidation params after a reorg: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+			}
+			return
+		}
+		params := *l.liquidationParams()
+		switch log.Topics[0] {
+		case closeFactor.ID:
+			event, err := filterer.ParseNewCloseFactor(log)
+			if err != nil {
+				l.logger.Warn(fmt.Sprintf("Skipping NewCloseFactor event: %v", err), F("pool", l.comptrollerAddress), F("tx", log.TxHash), F("err", err))
+				return
+			}
+			params.closeFactor = event.NewCloseFactorMantissa
+			l.setLiquidationParams(&params, fmt.Sprintf("NewCloseFactor in tx %s", log.TxHash))
+		case incentive.ID:
+			event, err := filterer.ParseNewLiquidationIncentive(log)
+			if err != nil {
+				l.logger.Warn(fmt.Sprintf("Skipping NewLiquidationIncentive event: %v", err), F("pool", l.comptrollerAddress), F("tx", log.TxHash), F("err", err))
+				return
+			}
+			params.incentive = event.NewLiquidationIncentiveMantissa
+			l.setLiquidationParams(&params, fmt.Sprintf("NewLiquidationIncentive in tx %s", log.TxHash))
+		}
+	}, func() {
+		if err := l.reconcileLiquidationParams(ctx, nil); err != nil {
+			l.logger.Warn(fmt.Sprintf("Failed to read liquidation params: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Actions of ActionPaused events
+const (
+	actionMint     = "Mint"
+	actionBorrow   = "Borrow"
+	actionSeize    = "Seize"
+	actionTransfer = "Transfer"
+)
+
+// pauseState is the pause state of the actions of a pool, pool-wide
+// and by market, as read at startup and replaced on ActionPaused
+// events.
+type pauseState struct {
+	mu      sync.RWMutex
+	pool    map[string]bool
+	markets map[common.Address]map[string]bool
+	readAt  uint64
+}
+
+func newPauseState() *pauseState {
+	return &pauseState{pool: make(map[string]bool), markets: make(map[common.Address]map[string]bool)}
+}
+
+// pauses are the pauses affecting liquidations in a block.
+type pauses struct {
+	seize    bool
+	transfer bool
+	// Markets paused as collateral
+	seizeMarkets map[common.Address]bool
+}
+
+func (p *pauseState) pauses() pauses {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	paused := pauses{seize: p.pool[actionSeize], transfer: p.pool[actionTransfer], seizeMarkets: make(map[common.Address]bool)}
+	for market, actions := range p.markets {
+		if actions[actionSeize] {
+			paused.seizeMarkets[market] = true
+		}
+	}
+	return paused
+}
+
+// set records the pause state of action, pool-wide for the zero
+// market, and returns whether it changed.
+func (p *pauseState) set(market common.Address, action string, paused bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	actions := p.pool
+	if market != (common.Address{}) {
+		if actions = p.markets[market]; actions == nil {
+			actions = make(map[string]bool)
+			p.markets[market] = actions
+		}
+	}
+	if actions[action] == paused {
+		return false
+	}
+	actions[action] = paused
+	return true
+}
+
+// setPause records the pause state of action and, on a transition,
+// warns and, once seizing pauses, drops the queued executions that
+// would revert.
+func (l *Liquidatoor) setPause(market common.Address, action string, paused bool, source string) {
+	if !l.pauses.set(market, action, paused) {
+		return
+	}
+	state, scope := "resumed", "pool "+l.comptrollerAddress.String()
+	if paused {
+		state = "paused"
+	}
+	if market != (common.Address{}) {
+		scope = fmt.Sprintf("market %s of pool %s", market, l.comptrollerAddress)
+	}
+	l.logger.Warn(fmt.Sprintf("%s %s in %s (%s)", action, state, scope, source),
+		F("pool", l.comptrollerAddress), F("market", market), F("action", action), F("paused", paused), F("source", source))
+	if paused && action == actionSeize {
+		if dropped := l.queue.Invalidate(l.comptrollerAddress); dropped > 0 {
+			l.logger.Info(fmt.Sprintf("Dropped %d executions planned before seizing paused", dropped),
+				F("pool", l.comptrollerAddress), F("dropped", dropped))
+		}
+	}
+}
+
+// reconcilePauses reads the pause state every paramsReconcileInterval
+// blocks. A nil block always reads it.
+func (l *Liquidatoor) reconcilePauses(ctx context.Context, block *big.Int, source string) error {
+	if block != nil {
+		number := block.Uint64()
+		if number < l.pauses.readAt+paramsReconcileInterval {
+			return nil
+		}
+		l.pauses.readAt = number
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	// Pauses the comptroller has no getter of are left to the events
+	if l.capabilities.SeizeGuardianPaused {
+		seizePaused, err := l.Comptroller.SeizeGuardianPaused(opts)
+		if err != nil {
+			l.logger.Debug(fmt.Sprintf("Cannot get seize pause state: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		}
+		l.setPause(common.Address{}, actionSeize, seizePaused, source)
+	}
+
+	markets := make([]common.Address, 0, len(l.LendMarkets))
+	for address := range l.LendMarkets {
+		markets = append(markets, common.HexToAddress(address))
+	}
+	sort.Slice(markets, func(i, j int) bool { return markets[i].String() < markets[j].String() })
+	methods := l.comptrollerABI.Methods
+	type read struct {
+		market common.Address
+		action string
+	}
+	var reads []read
+	var calls []abis.MulticallCall
+	if l.capabilities.TransferGuardianPaused {
+		reads = append(reads, read{action: actionTransfer})
+		calls = append(calls, abis.MulticallCall{Target: l.comptrollerAddress, CallData: methods["transferGuardianPaused"].ID})
+	}
+	getters := []struct {
+		method string
+		action string
+		ok     bool
+	}{
+		{"mintGuardianPaused", actionMint, l.capabilities.MintGuardianPaused},
+		{"borrowGuardianPaused", actionBorrow, l.capabilities.BorrowGuardianPaused},
+	}
+	for _, market := range markets {
+		for _, getter := range getters {
+			if !getter.ok {
+				continue
+			}
+			inputs, err := methods[getter.method].Inputs.Pack(market)
+			if err != nil {
+				return fmt.Errorf("cannot pack %s: %w", getter.method, err)
+			}
+			reads = append(reads, read{market: market, action: getter.action})
+			calls = append(calls, abis.MulticallCall{Target: l.comptrollerAddress, CallData: append(methods[getter.method].ID[:], inputs...)})
+		}
+	}
+	if len(calls) == 0 {
+		return nil
+	}
+	resp, err := l.Batcher.Aggregate(opts, calls)
+	if err != nil {
+		return fmt.Errorf("failed batch request: %v", err)
+	}
+	paused := func(result CallResult) bool {
+		return result.Success && len(result.ReturnData) >= 32 && result.ReturnData[31] == 1
+	}
+
+	for i, r := range reads {
+		l.setPause(r.market, r.action, paused(resp[i]), source)
+	}
+	return nil
+}
+
+// watchPauses applies both ActionPaused events until ctx is
+// cancelled, reading the pauses whenever events are lost or a reorg
+// removes one.
+func (l *Liquidatoor) watchPauses(ctx context.Context) {
+	filterer, err := abis.NewComptrollerFilterer(l.comptrollerAddress, l.client)
+	if err != nil {
+		l.logger.Error(fmt.Sprintf("Cannot watch pauses: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		return
+	}
+	// The pool-wide and per-market variants share a name
+	var poolPause, marketPause common.Hash
+	for _, event := range l.comptrollerABI.Events {
+		if event.RawName != "ActionPaused" {
+			continue
+		}
+		if len(event.Inputs) == 2 {
+			poolPause = event.ID
+		} else {
+			marketPause = event.ID
+		}
+	}
+	query := ethereum.FilterQuery{Addresses: []common.Address{l.comptrollerAddress}, Topics: [][]common.Hash{{poolPause, marketPause}}}
+
+	l.bus.subscribe(ctx, "pause events", query, func(log types.Log) {
+		market, action, paused := common.Address{}, "", false
+		if log.Topics[0] == poolPause {
+			event, err := filterer.ParseActionPaused(log)
+			if err != nil {
+				l.logger.Warn(fmt.Sprintf("Skipping ActionPaused event: %v", err), F("pool", l.comptrollerAddress), F("tx", log.TxHash), F("err", err))
+				return
+			}
+			action, paused = event.Action, event.PauseState
+		} else {
+			event, err := filterer.ParseActionPaused0(log)
+			if err != nil {
+				l.logger.Warn(fmt.Sprintf("Skipping ActionPaused event: %v", err), F("pool", l.comptrollerAddress), F("tx", log.TxHash), F("err", err))
+				return
+			}
+			market, action, paused = event.CToken, event.Action, event.PauseState
+		}
+		if !log.Removed {
+			l.setPause(market, action, paused, fmt.Sprintf("ActionPaused in tx %s", log.TxHash))
+			return
+		}
+		// Undo the event, then read the pauses that can be read over
+		// the undone ones
+		source := fmt.Sprintf("reorg of ActionPaused in tx %s", log.TxHash)
+		l.setPause(market, action, !paused, source)
+		if err := l.reconcilePauses(ctx, nil, source); err != nil {
+			l.logger.Warn(fmt.Sprintf("Failed to read pauses: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		}
+	}, func() {
+		if err := l.reconcilePauses(ctx, nil, "read"); err != nil {
+			l.logger.Warn(fmt.Sprintf("Failed to read pauses: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		}
+	})
+}
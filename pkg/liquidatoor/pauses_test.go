@@ -0,0 +1,101 @@
+package liquidatoor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/fakes"
+)
+
+// pauseLiquidatoor watches the pause events of a pool on chain until
+// the test ends.
+func pauseLiquidatoor(t *testing.T, chain *fakes.Logs) *Liquidatoor {
+	t.Helper()
+	comptrollerABI, err := abis.ComptrollerMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := logsBackend{logs: chain}
+	logger := NewStdLogger()
+	l := &Liquidatoor{
+		client:             client,
+		logger:             logger,
+		comptrollerAddress: watchedAddress,
+		comptrollerABI:     comptrollerABI,
+		pauses:             newPauseState(),
+		queue:              NewExecutionQueue(logger, 1, 1),
+		bus:                newEventBus(logger, client, newLogBackfill(logger, client, nil, 10, 0), 10*time.Millisecond),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	l.watchPauses(ctx)
+	waitSubscribed(t, chain)
+	return l
+}
+
+// pauseLog is the index-th log of block pausing or resuming seizing
+// collateral in market.
+func pauseLog(t *testing.T, l *Liquidatoor, block uint64, index uint, market common.Address, paused bool) types.Log {
+	t.Helper()
+	var event *abi.Event
+	for _, e := range l.comptrollerABI.Events {
+		if e.RawName == "ActionPaused" && len(e.Inputs) == 3 {
+			e := e
+			event = &e
+		}
+	}
+	data, err := event.Inputs.NonIndexed().Pack(market, actionSeize, paused)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := testLog(block, index)
+	log.Topics, log.Data = []common.Hash{event.ID}, data
+	return log
+}
+
+// waitSeizePaused waits until seizing collateral in market is paused
+// as expected.
+func waitSeizePaused(t *testing.T, l *Liquidatoor, market common.Address, paused bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if l.pauses.pauses().seizeMarkets[market] == paused {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected seizing paused %v in market %s", paused, market)
+}
+
+func TestRemovedPauseIsUndone(t *testing.T) {
+	chain := fakes.NewLogs(100)
+	l := pauseLiquidatoor(t, chain)
+	market := common.HexToAddress("0xb")
+
+	pause := pauseLog(t, l, 101, 0, market, true)
+	chain.Emit(pause)
+	waitSeizePaused(t, l, market, true)
+	chain.Remove(pause)
+	waitSeizePaused(t, l, market, false)
+}
+
+func TestRemovedResumeIsUndone(t *testing.T) {
+	chain := fakes.NewLogs(100)
+	l := pauseLiquidatoor(t, chain)
+	market := common.HexToAddress("0xb")
+
+	chain.Emit(pauseLog(t, l, 101, 0, market, true))
+	waitSeizePaused(t, l, market, true)
+	resume := pauseLog(t, l, 102, 0, market, false)
+	chain.Emit(resume)
+	waitSeizePaused(t, l, market, false)
+	// The pause before the resume holds again
+	chain.Remove(resume)
+	waitSeizePaused(t, l, market, true)
+}
@@ -0,0 +1,309 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/store"
+)
+
+// Age after which a transaction still pending on startup is abandoned
+// by default
+const defaultPendingTxTTL = 30 * time.Minute
+
+// PendingTx is a liquidation transaction sent but not known to be
+// mined.
+type PendingTx struct {
+	Hash    common.Hash
+	Nonce   uint64
+	Pool    common.Address
+	Account common.Address
+	// Repay market, the Comet for absorbs; zero for transactions
+	// persisted before markets were, which hold every market
+	Market common.Address
+	// Legacy transactions only set GasPrice
+	GasPrice  *big.Int `json:",omitempty"`
+	GasTipCap *big.Int `json:",omitempty"`
+	GasFeeCap *big.Int `json:",omitempty"`
+	SentAt    time.Time
+}
+
+// pendingTxs assigns the nonces of our transactions and persists the
+// liquidation transactions in flight, so an instance started before
+// they were mined neither reuses their nonces nor loses their outcome.
+// A nil pendingTxs leaves nonces to bind.
+type pendingTxs struct {
+	logger Logger
+	// Empty keeps pending transactions in memory
+	path string
+	ttl  time.Duration
+
+	lock sync.Mutex
+	txs  map[common.Hash]*PendingTx
+	// Next nonce to assign, unless the node reports a later one
+	next uint64
+}
+
+func openPendingTxs(logger Logger, path string, ttl time.Duration) (*pendingTxs, error) {
+	if ttl == 0 {
+		ttl = defaultPendingTxTTL
+	}
+	p := &pendingTxs{logger: logger, path: path, ttl: ttl, txs: make(map[common.Hash]*PendingTx)}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// load replaces the transactions in memory with the persisted ones, eg.,
+// the ones another instance sent before this one took over.
+func (p *pendingTxs) load() error {
+	if p.path == "" {
+		return nil
+	}
+	var txs []*PendingTx
+	ok, err := loadState(p.logger, "pending transactions", p.path, pendingTxsVersion, &txs)
+	if err != nil || !ok {
+		return err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.txs = make(map[common.Hash]*PendingTx, len(txs))
+	for _, tx := range txs {
+		p.txs[tx.Hash] = tx
+	}
+	return nil
+}
+
+// assign sets the nonce of opts to the next one, which is never below
+// the pending nonce of the node nor reused from a transaction in
+// flight.
+func (p *pendingTxs) assign(ctx context.Context, client bind.ContractTransactor, opts *bind.TransactOpts) error {
+	if p == nil {
+		return nil
+	}
+	nonce, err := client.PendingNonceAt(ctx, opts.From)
+	if err != nil {
+		return fmt.Errorf("cannot get pending nonce: %w", err)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.next > nonce {
+		nonce = p.next
+	}
+	p.next = nonce + 1
+	opts.Nonce = new(big.Int).SetUint64(nonce)
+	return nil
+}
+
+// release frees the nonce of opts if sending failed, so it is assigned
+// again.
+func (p *pendingTxs) release(opts *bind.TransactOpts, err error) {
+	if p == nil || err == nil || opts.Nonce == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.next == opts.Nonce.Uint64()+1 {
+		p.next--
+	}
+}
+
+// sent persists a liquidation transaction until it is done.
+func (p *pendingTxs) sent(tx *types.Transaction, pool, account, market common.Address) {
+	if p == nil {
+		return
+	}
+	pending := &PendingTx{Hash: tx.Hash(), Nonce: tx.Nonce(), Pool: pool, Account: account, Market: market, SentAt: time.Now()}
+	if tx.Type() == types.LegacyTxType {
+		pending.GasPrice = tx.GasPrice()
+	} else {
+		pending.GasTipCap, pending.GasFeeCap = tx.GasTipCap(), tx.GasFeeCap()
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.txs[pending.Hash] = pending
+	p.persistLocked()
+}
+
+// done forgets a mined transaction.
+func (p *pendingTxs) done(hash common.Hash) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.txs[hash]; !ok {
+		return
+	}
+	delete(p.txs, hash)
+	p.persistLocked()
+}
+
+// intent returns our transaction liquidating account in market of pool
+// that is in flight, if any: neither mined nor older than the TTL,
+// after which it is abandoned on startup.
+func (p *pendingTxs) intent(pool, account, market common.Address) (common.Hash, bool) {
+	if p == nil {
+		return common.Hash{}, false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for hash, tx := range p.txs {
+		if tx.Pool != pool || tx.Account != account || time.Since(tx.SentAt) > p.ttl {
+			continue
+		}
+		if tx.Market == market || tx.Market == (common.Address{}) {
+			return hash, true
+		}
+	}
+	return common.Hash{}, false
+}
+
+// hashes returns the hashes of the transactions still pending
+// reconciliation. Abandoned ones are forgotten, so the journals only
+// they reference can be pruned.
+func (p *pendingTxs) hashes() map[common.Hash]bool {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	hashes := make(map[common.Hash]bool, len(p.txs))
+	for hash := range p.txs {
+		hashes[hash] = true
+	}
+	return hashes
+}
+
+// watch waits for a transaction to be mined and forgets it. It is left
+// for the next instance if ctx is cancelled first.
+func (p *pendingTxs) watch(ctx context.Context, client Backend, hash common.Hash, interval time.Duration) (*types.Receipt, error) {
+	if p == nil {
+		return nil, nil
+	}
+	for {
+		receipt, err := client.TransactionReceipt(ctx, hash)
+		if err == nil && receipt != nil {
+			p.done(hash)
+			return receipt, nil
+		}
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			p.logger.Warn(fmt.Sprintf("Failed to get receipt of transaction %s: %v", hash, err), F("tx", hash), F("err", err))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// reconcile settles the transactions left pending by a previous
+// instance: mined ones are recorded in the ledger, ones past the TTL
+// are reported and forgotten and nonces resume after the rest, which
+// are returned to be waited for.
+func (p *pendingTxs) reconcile(ctx context.Context, client Backend, ledger *Ledger) ([]PendingTx, error) {
+	p.lock.Lock()
+	txs := make([]PendingTx, 0, len(p.txs))
+	for _, tx := range p.txs {
+		txs = append(txs, *tx)
+	}
+	p.lock.Unlock()
+
+	var waiting []PendingTx
+	for _, tx := range txs {
+		receipt, err := client.TransactionReceipt(ctx, tx.Hash)
+		switch {
+		case err == nil && receipt != nil:
+			p.logger.Info(fmt.Sprintf("Transaction %s of account %s left pending was mined in block %v", tx.Hash, tx.Account, receipt.BlockNumber),
+				F("pool", tx.Pool), F("account", tx.Account), F("tx", tx.Hash))
+			if err := p.settle(ctx, client, ledger, tx, receipt); err != nil {
+				return nil, err
+			}
+		case err != nil && !errors.Is(err, ethereum.NotFound):
+			return nil, fmt.Errorf("cannot get receipt of transaction %s: %w", tx.Hash, err)
+		case time.Since(tx.SentAt) > p.ttl:
+			p.logger.Error(fmt.Sprintf("Abandoning transaction %s of account %s with nonce %d, pending since %v", tx.Hash, tx.Account, tx.Nonce, tx.SentAt),
+				F("pool", tx.Pool), F("account", tx.Account), F("tx", tx.Hash), F("nonce", tx.Nonce))
+			p.done(tx.Hash)
+		default:
+			p.logger.Info(fmt.Sprintf("Resuming wait for transaction %s of account %s with nonce %d", tx.Hash, tx.Account, tx.Nonce),
+				F("pool", tx.Pool), F("account", tx.Account), F("tx", tx.Hash), F("nonce", tx.Nonce))
+			waiting = append(waiting, tx)
+			p.lock.Lock()
+			if tx.Nonce+1 > p.next {
+				p.next = tx.Nonce + 1
+			}
+			p.lock.Unlock()
+		}
+	}
+	return waiting, nil
+}
+
+// resume waits for the transactions returned by reconcile and records
+// them in the ledger once mined.
+func (p *pendingTxs) resume(ctx context.Context, client Backend, ledger *Ledger, txs []PendingTx, interval time.Duration) {
+	for _, tx := range txs {
+		go func(tx PendingTx) {
+			receipt, err := p.watch(ctx, client, tx.Hash, interval)
+			if err != nil {
+				return
+			}
+			if err := p.settle(ctx, client, ledger, tx, receipt); err != nil {
+				p.logger.Error(fmt.Sprintf("Failed to record transaction %s of account %s: %v", tx.Hash, tx.Account, err),
+					F("pool", tx.Pool), F("account", tx.Account), F("tx", tx.Hash), F("err", err))
+			}
+		}(tx)
+	}
+}
+
+// settle records the gas paid by a mined transaction, whose executor
+// is gone, as its outcome and forgets it.
+func (p *pendingTxs) settle(ctx context.Context, client Backend, ledger *Ledger, tx PendingTx, receipt *types.Receipt) error {
+	outcome := Outcome{Pool: tx.Pool, Account: tx.Account, Tx: tx.Hash, PnL: new(big.Int)}
+	if reader, ok := client.(interface {
+		TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	}); ok {
+		sent, _, err := reader.TransactionByHash(ctx, tx.Hash)
+		if err != nil {
+			return fmt.Errorf("cannot get transaction %s: %w", tx.Hash, err)
+		}
+		paid, err := gasPaid(ctx, client, sent, receipt)
+		if err != nil {
+			return err
+		}
+		outcome.PnL.Neg(paid)
+	}
+	if ledger != nil {
+		if err := ledger.Record(outcome); err != nil {
+			return err
+		}
+	}
+	p.done(tx.Hash)
+	return nil
+}
+
+// persistLocked atomically replaces the persisted transactions; errors
+// are logged as the transactions are already sent.
+func (p *pendingTxs) persistLocked() {
+	if p.path == "" {
+		return
+	}
+	txs := make([]*PendingTx, 0, len(p.txs))
+	for _, tx := range p.txs {
+		txs = append(txs, tx)
+	}
+	if err := store.Write(p.path, pendingTxsVersion, txs); err != nil {
+		p.logger.Error(fmt.Sprintf("Failed to persist pending transactions: %v", err), F("err", err))
+	}
+}
@@ -0,0 +1,62 @@
+package liquidatoor
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// receiptsBackend answers the receipts of mined transactions only.
+type receiptsBackend struct {
+	Backend
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (b *receiptsBackend) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	if receipt, ok := b.receipts[hash]; ok {
+		return receipt, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func TestPendingTxsReconcile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending")
+	p, err := openPendingTxs(quietLogger(), path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, account := common.HexToAddress("0xc0"), common.HexToAddress("0xa")
+	mined, stale, fresh := common.Hash{1}, common.Hash{2}, common.Hash{3}
+	p.txs = map[common.Hash]*PendingTx{
+		mined: {Hash: mined, Nonce: 1, Pool: pool, Account: account, SentAt: time.Now().Add(-time.Hour)},
+		stale: {Hash: stale, Nonce: 2, Pool: pool, Account: account, SentAt: time.Now().Add(-time.Hour)},
+		fresh: {Hash: fresh, Nonce: 3, Pool: pool, Account: account, SentAt: time.Now()},
+	}
+	client := &receiptsBackend{receipts: map[common.Hash]*types.Receipt{mined: {BlockNumber: big.NewInt(1)}}}
+
+	waiting, err := p.reconcile(context.Background(), client, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(waiting) != 1 || waiting[0].Hash != fresh || p.next != 4 {
+		t.Fatalf("expected to wait for the fresh transaction only, from nonce 4, got %+v from %d", waiting, p.next)
+	}
+	// Abandoned transactions no longer keep their journals from being
+	// pruned, also once persisted
+	if hashes := p.hashes(); len(hashes) != 1 || !hashes[fresh] {
+		t.Fatalf("expected the fresh transaction pending only, got %v", hashes)
+	}
+	loaded, err := openPendingTxs(quietLogger(), path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashes := loaded.hashes(); len(hashes) != 1 || !hashes[fresh] {
+		t.Fatalf("expected the fresh transaction persisted only, got %v", hashes)
+	}
+}
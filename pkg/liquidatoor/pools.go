@@ -0,0 +1,139 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// monitor processes the blocks of a single pool.
+type monitor interface {
+	SubscribeToBlocks(ctx context.Context) error
+}
+
+// PoolManager runs one monitor per pool on top of a shared connection.
+type PoolManager struct {
+	conn *Connection
+
+	lock *sync.Mutex
+	// Stops monitoring each pool
+	pools map[common.Address]context.CancelFunc
+	// Monitor of each pool
+	monitors map[common.Address]monitor
+}
+
+func NewPoolManager(conn *Connection) *PoolManager {
+	return &PoolManager{
+		conn:     conn,
+		lock:     &sync.Mutex{},
+		pools:    make(map[common.Address]context.CancelFunc),
+		monitors: make(map[common.Address]monitor),
+	}
+}
+
+// Add starts monitoring the pool governed by the provided comptroller
+// until ctx is cancelled or the pool is removed. Adding an already
+// monitored pool is a no-op.
+func (m *PoolManager) Add(ctx context.Context, comptroller common.Address) error {
+	if m.Has(comptroller) {
+		return nil
+	}
+
+	l, err := m.conn.NewLiquidatoor(ctx, comptroller)
+	if err != nil {
+		return fmt.Errorf("cannot instantiate liquidatoor for pool %s: %w", comptroller, err)
+	}
+	m.start(ctx, comptroller, l)
+	return nil
+}
+
+// AddComet starts monitoring the Comet market at the provided address.
+func (m *PoolManager) AddComet(ctx context.Context, comet common.Address) error {
+	if m.Has(comet) {
+		return nil
+	}
+
+	c, err := m.conn.NewCometMonitor(ctx, comet)
+	if err != nil {
+		return fmt.Errorf("cannot instantiate monitor for comet %s: %w", comet, err)
+	}
+	m.start(ctx, comet, c)
+	return nil
+}
+
+func (m *PoolManager) start(ctx context.Context, address common.Address, pool monitor) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	m.lock.Lock()
+	m.pools[address] = cancel
+	m.monitors[address] = pool
+	m.lock.Unlock()
+	m.conn.readiness.add(address)
+
+	m.conn.logger.Info(fmt.Sprintf("Started monitoring pool %s", address), F("pool", address))
+
+	go func() {
+		if err := pool.SubscribeToBlocks(ctx); err != nil {
+			m.conn.logger.Error(fmt.Sprintf("Stopped monitoring pool %s: %v", address, err), F("pool", address), F("err", err))
+			m.Remove(address)
+		}
+	}()
+}
+
+// Remove stops monitoring the pool governed by the provided comptroller.
+func (m *PoolManager) Remove(comptroller common.Address) {
+	m.lock.Lock()
+	cancel, ok := m.pools[comptroller]
+	delete(m.pools, comptroller)
+	delete(m.monitors, comptroller)
+	m.lock.Unlock()
+
+	if !ok {
+		return
+	}
+	cancel()
+	m.conn.readiness.remove(comptroller)
+	m.conn.logger.Info(fmt.Sprintf("Stopped monitoring pool %s", comptroller), F("pool", comptroller))
+}
+
+func (m *PoolManager) Has(comptroller common.Address) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, ok := m.pools[comptroller]
+	return ok
+}
+
+// Pools returns the comptroller addresses of all monitored pools.
+func (m *PoolManager) Pools() []common.Address {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	pools := make([]common.Address, 0, len(m.pools))
+	for comptroller := range m.pools {
+		pools = append(pools, comptroller)
+	}
+	return pools
+}
+
+// liquidatoors returns the monitors of the Compound pools, sorted by
+// comptroller address.
+func (m *PoolManager) liquidatoors() []*Liquidatoor {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	liquidatoors := make([]*Liquidatoor, 0, len(m.monitors))
+	for _, pool := range m.monitors {
+		if l, ok := pool.(*Liquidatoor); ok {
+			liquidatoors = append(liquidatoors, l)
+		}
+	}
+	sort.Slice(liquidatoors, func(i, j int) bool {
+		return bytes.Compare(liquidatoors[i].comptrollerAddress[:], liquidatoors[j].comptrollerAddress[:]) == -1
+	})
+	return liquidatoors
+}
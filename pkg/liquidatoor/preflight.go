@@ -0,0 +1,112 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// PreflightError lists everything wrong with the contracts configured,
+// as found on the chain at startup. It is an ErrInvalidConfig.
+type PreflightError struct {
+	Problems []string
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("%d preflight checks failed: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+func (e *PreflightError) Unwrap() error {
+	return ErrInvalidConfig
+}
+
+// Preflight dials the node of cfg and checks the contracts of cfg on
+// its chain, as Connect does, without connecting.
+func Preflight(ctx context.Context, cfg *Config) error {
+	if cfg.NodeAPIURL == "" {
+		return fmt.Errorf("%w: NODE_API_URL cannot be empty", ErrInvalidConfig)
+	}
+	client, err := ethclient.DialContext(ctx, cfg.NodeAPIURL)
+	if err != nil {
+		return fmt.Errorf("cannot connect to node: %w", err)
+	}
+	defer client.Close()
+	chainID, err := client.NetworkID(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot get chain id: %w", err)
+	}
+	return preflight(ctx, client, chainID, cfg, cfg.MulticallAddress)
+}
+
+// preflight checks that client is on the expected chain, if any, that
+// the comptrollers and Comets of cfg, the oracles of the comptrollers
+// and multicall, unless nil, are deployed, and that the comptrollers
+// are comptrollers. Every problem found is returned in one
+// PreflightError, so that a typo surfaces at startup rather than as the
+// errors it causes later.
+func preflight(ctx context.Context, client Backend, chainID *big.Int, cfg *Config, multicall *common.Address) error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	// deployed reports whether there is code at address, described by
+	// what, reporting a problem if not
+	deployed := func(address common.Address, what string) bool {
+		code, err := client.CodeAt(ctx, address, nil)
+		if err != nil {
+			problem("cannot get code of %s: %v", what, err)
+			return false
+		}
+		if len(code) == 0 {
+			problem("no contract deployed at %s", what)
+			return false
+		}
+		return true
+	}
+
+	if cfg.ExpectedChainID != nil && !Equal(cfg.ExpectedChainID, chainID) {
+		problem("connected to chain %v but EXPECTED_CHAIN_ID is %v", chainID, cfg.ExpectedChainID)
+	}
+	if multicall != nil {
+		deployed(*multicall, "multicall address "+multicall.Hex())
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	for _, address := range cfg.Comptrollers {
+		if !deployed(address, "comptroller "+address.Hex()) {
+			continue
+		}
+		comptroller, err := abis.NewComptroller(address, client)
+		if err != nil {
+			return fmt.Errorf("cannot instantiate comptroller: %w", err)
+		}
+		if ok, err := comptroller.IsComptroller(opts); err != nil {
+			problem("comptroller %s does not answer isComptroller: %v", address.Hex(), err)
+			continue
+		} else if !ok {
+			problem("comptroller %s is not a comptroller", address.Hex())
+			continue
+		}
+		oracle, err := comptroller.Oracle(opts)
+		switch {
+		case err != nil:
+			problem("cannot fetch price oracle of comptroller %s: %v", address.Hex(), err)
+		case oracle == (common.Address{}):
+			problem("comptroller %s has no price oracle", address.Hex())
+		default:
+			deployed(oracle, fmt.Sprintf("price oracle %s of comptroller %s", oracle.Hex(), address.Hex()))
+		}
+	}
+	for _, address := range cfg.Comets {
+		deployed(address, "Comet "+address.Hex())
+	}
+	if len(problems) > 0 {
+		return &PreflightError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Label of the address liquidations are executed from
+const ownLabel = "liquidatoor"
+
+const (
+	// Age of the resolved names, and interval between attempts to
+	// resolve an address that failed
+	ensNameTTL        = 24 * time.Hour
+	ensRetryInterval  = 5 * time.Minute
+	ensResolveTimeout = 10 * time.Second
+	// Addresses waiting to be resolved; further ones are resolved once
+	// mentioned again
+	ensQueueSize = 256
+)
+
+// Methods of the ENS registry and resolvers used for reverse records
+const ensABI = `[
+	{"name":"resolver","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"name":"name","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"string"}]},
+	{"name":"addr","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]}
+]`
+
+// Presenter renders addresses, transactions and blocks the same way on
+// every surface: links point to the block explorer, and addresses are
+// followed by what we know of them, in order, their annotation, our own
+// address or the market they are, or their name if resolved. A nil
+// Presenter renders addresses as they are, without links. It is safe
+// for concurrent use.
+type Presenter struct {
+	explorerURL string
+	annotations *Annotations
+	// Resolves the names of the addresses without a label, if set
+	names *nameResolver
+
+	lock   sync.RWMutex
+	labels map[common.Address]string
+}
+
+func newPresenter(explorerURL string, annotations *Annotations) *Presenter {
+	return &Presenter{
+		explorerURL: strings.TrimSuffix(explorerURL, "/"),
+		annotations: annotations,
+		labels:      make(map[common.Address]string),
+	}
+}
+
+// NewPresenter returns a Presenter of the explorer and the annotations
+// of cfg, for the commands rendering what they read without connecting.
+func NewPresenter(logger Logger, cfg *Config) (*Presenter, error) {
+	annotations := newAnnotations(logger)
+	if cfg.AnnotationsPath != "" {
+		if err := annotations.load(cfg.AnnotationsPath); err != nil {
+			return nil, err
+		}
+	}
+	return newPresenter(cfg.ExplorerURL, annotations), nil
+}
+
+// label labels address, unless annotated.
+func (p *Presenter) label(address common.Address, label string) {
+	if p == nil || label == "" {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.labels[address] = label
+}
+
+// labelMarkets labels the markets of a pool, and their underlyings, by
+// the symbols of their underlyings.
+func (p *Presenter) labelMarkets(underlyingInfo map[string]UnderlyingInfo) {
+	for market, info := range underlyingInfo {
+		p.label(common.HexToAddress(market), info.name)
+		if !info.native {
+			p.label(info.address, info.name)
+		}
+	}
+}
+
+// Label returns what we know of address, empty if nothing.
+func (p *Presenter) Label(address common.Address) string {
+	if p == nil {
+		return ""
+	}
+	if annotation, ok := p.annotations.Get(address); ok {
+		return annotation.Label
+	}
+	p.lock.RLock()
+	label, ok := p.labels[address]
+	p.lock.RUnlock()
+	if ok {
+		return label
+	}
+	return p.names.name(address)
+}
+
+// Address returns address followed by its label, if any.
+func (p *Presenter) Address(address common.Address) string {
+	return withLabel(address.Hex(), p.Label(address))
+}
+
+// Link returns the explorer link of address followed by its label, if
+// any.
+func (p *Presenter) Link(address common.Address) string {
+	return withLabel(p.AddressURL(address), p.Label(address))
+}
+
+// AddressURL returns the explorer link of address, or the address if
+// there is no explorer.
+func (p *Presenter) AddressURL(address common.Address) string {
+	return p.url("address", address.Hex())
+}
+
+// TxURL returns the explorer link of a transaction, or its hash if
+// there is no explorer.
+func (p *Presenter) TxURL(hash common.Hash) string {
+	return p.url("tx", hash.Hex())
+}
+
+// BlockURL returns the explorer link of a block, or its number if there
+// is no explorer.
+func (p *Presenter) BlockURL(number *big.Int) string {
+	return p.url("block", number.String())
+}
+
+func (p *Presenter) url(kind, id string) string {
+	if p == nil || p.explorerURL == "" {
+		return id
+	}
+	return fmt.Sprintf("%s/%s/%s", p.explorerURL, kind, id)
+}
+
+// run resolves names until ctx is cancelled, if resolving them.
+func (p *Presenter) run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	p.names.run(ctx)
+}
+
+func withLabel(s, label string) string {
+	if label == "" {
+		return s
+	}
+	return fmt.Sprintf("%s (%s)", s, label)
+}
+
+// nameResolver resolves the ENS names of addresses, or the names of a
+// registry of the same interface on other chains, off the callers: a
+// name that is not cached is queued and resolved in the background, and
+// empty until then. Only names whose forward record points back to the
+// address are kept, since anyone can set a reverse record. A nil
+// nameResolver resolves nothing.
+type nameResolver struct {
+	logger   Logger
+	client   Backend
+	registry common.Address
+	abi      abi.ABI
+	requests chan common.Address
+
+	lock  sync.Mutex
+	names map[common.Address]cachedName
+}
+
+type cachedName struct {
+	name string
+	// Resolved or queued at
+	at time.Time
+}
+
+func newNameResolver(logger Logger, client Backend, registry common.Address) (*nameResolver, error) {
+	parsed, err := abi.JSON(strings.NewReader(ensABI))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse ENS ABI: %w", err)
+	}
+	return &nameResolver{
+		logger:   logger,
+		client:   client,
+		registry: registry,
+		abi:      parsed,
+		requests: make(chan common.Address, ensQueueSize),
+		names:    make(map[common.Address]cachedName),
+	}, nil
+}
+
+// name returns the cached name of address, queueing it to be resolved
+// if it is not cached or expired.
+func (r *nameResolver) name(address common.Address) string {
+	if r == nil {
+		return ""
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	cached, ok := r.names[address]
+	if ok && time.Since(cached.at) < ensNameTTL {
+		return cached.name
+	}
+	select {
+	case r.requests <- address:
+		r.names[address] = cachedName{name: cached.name, at: time.Now()}
+	default:
+	}
+	return cached.name
+}
+
+// run resolves the queued addresses until ctx is cancelled.
+func (r *nameResolver) run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	for {
+		var address common.Address
+		select {
+		case <-ctx.Done():
+			return
+		case address = <-r.requests:
+		}
+		rctx, cancel := context.WithTimeout(ctx, ensResolveTimeout)
+		name, err := r.resolve(rctx, address)
+		cancel()
+		r.lock.Lock()
+		if err != nil {
+			// Kept until retried
+			r.names[address] = cachedName{name: r.names[address].name, at: time.Now().Add(ensRetryInterval - ensNameTTL)}
+		} else {
+			r.names[address] = cachedName{name: name, at: time.Now()}
+		}
+		r.lock.Unlock()
+		if err != nil {
+			r.logger.Debug(fmt.Sprintf("Cannot resolve name of %s: %v", address.Hex(), err), F("err", err))
+		}
+	}
+}
+
+// resolve returns the verified name of address, empty if it has none.
+func (r *nameResolver) resolve(ctx context.Context, address common.Address) (string, error) {
+	node := namehash(strings.ToLower(address.Hex()[2:]) + ".addr.reverse")
+	var resolver common.Address
+	if err := r.call(ctx, r.registry, "resolver", node, &resolver); err != nil || resolver == (common.Address{}) {
+		return "", err
+	}
+	var name string
+	if err := r.call(ctx, resolver, "name", node, &name); err != nil || name == "" {
+		return "", err
+	}
+
+	node = namehash(name)
+	if err := r.call(ctx, r.registry, "resolver", node, &resolver); err != nil || resolver == (common.Address{}) {
+		return "", err
+	}
+	var resolved common.Address
+	if err := r.call(ctx, resolver, "addr", node, &resolved); err != nil || resolved != address {
+		return "", err
+	}
+	return name, nil
+}
+
+// call calls method of target with node. Targets without the method
+// answer nothing, and leave out unchanged.
+func (r *nameResolver) call(ctx context.Context, target common.Address, method string, node common.Hash, out interface{}) error {
+	data, err := r.abi.Pack(method, node)
+	if err != nil {
+		return fmt.Errorf("cannot pack %s: %w", method, err)
+	}
+	result, err := r.client.CallContract(ctx, ethereum.CallMsg{To: &target, Data: data}, nil)
+	if err != nil {
+		return fmt.Errorf("cannot call %s of %s: %w", method, target.Hex(), err)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	if err := r.abi.UnpackIntoInterface(out, method, result); err != nil {
+		return fmt.Errorf("cannot unpack %s of %s: %w", method, target.Hex(), err)
+	}
+	return nil
+}
+
+// namehash returns the ENS node of name.
+func namehash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node[:], crypto.Keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// Addresses, and the prefixes of longer hex strings, eg., hashes, which
+// are left as they are
+var hexPattern = regexp.MustCompile(`0x[0-9a-fA-F]{40,}`)
+
+// presentingLogger follows the addresses every message mentions by their
+// labels, where first mentioned, and appends the labels of the accounts
+// in its fields it does not mention. Every label is also added as the
+// label field.
+type presentingLogger struct {
+	logger    Logger
+	presenter *Presenter
+}
+
+func (l *presentingLogger) present(msg string, fields []Field) (string, []Field) {
+	var labels []string
+	mentioned := make(map[common.Address]bool)
+	var b strings.Builder
+	last := 0
+	for _, match := range hexPattern.FindAllStringIndex(msg, -1) {
+		if match[1]-match[0] != 2*common.AddressLength+2 {
+			continue
+		}
+		address := common.HexToAddress(msg[match[0]:match[1]])
+		if mentioned[address] {
+			continue
+		}
+		mentioned[address] = true
+		label := l.presenter.Label(address)
+		if label == "" {
+			continue
+		}
+		labels = append(labels, label)
+		b.WriteString(msg[last:match[1]])
+		last = match[1]
+		if suffix := withLabel("", label); !strings.HasPrefix(msg[last:], suffix) {
+			b.WriteString(suffix)
+		}
+	}
+	b.WriteString(msg[last:])
+	msg = b.String()
+
+	var unmentioned []string
+	for _, field := range fields {
+		address, ok := field.Value.(common.Address)
+		if !ok || mentioned[address] || (field.Key != "account" && field.Key != "borrower") {
+			continue
+		}
+		mentioned[address] = true
+		if label := l.presenter.Label(address); label != "" {
+			labels = append(labels, label)
+			unmentioned = append(unmentioned, label)
+		}
+	}
+	if len(labels) == 0 {
+		return msg, fields
+	}
+	if len(unmentioned) > 0 {
+		msg = fmt.Sprintf("%s [%s]", msg, strings.Join(unmentioned, ", "))
+	}
+	return msg, append(fields[:len(fields):len(fields)], F("label", strings.Join(labels, ", ")))
+}
+
+func (l *presentingLogger) Debug(msg string, fields ...Field) {
+	msg, fields = l.present(msg, fields)
+	l.logger.Debug(msg, fields...)
+}
+
+func (l *presentingLogger) Info(msg string, fields ...Field) {
+	msg, fields = l.present(msg, fields)
+	l.logger.Info(msg, fields...)
+}
+
+func (l *presentingLogger) Warn(msg string, fields ...Field) {
+	msg, fields = l.present(msg, fields)
+	l.logger.Warn(msg, fields...)
+}
+
+func (l *presentingLogger) Error(msg string, fields ...Field) {
+	msg, fields = l.present(msg, fields)
+	l.logger.Error(msg, fields...)
+}
@@ -0,0 +1,209 @@
+package liquidatoor
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChainPreset holds the chain-specific defaults. Explicit config
+// always takes precedence over the preset.
+type ChainPreset struct {
+	Name string
+	// Average time between blocks
+	BlockTime time.Duration
+	// Address of the multicall contract
+	MulticallAddress common.Address
+	// Symbol of the native token, used for native token markets
+	NativeSymbol string
+	// Whether the chain supports EIP-1559 dynamic fee transactions
+	DynamicFees bool
+	// How the chain charges for posting data to L1, if at all
+	L1Fee L1FeeModel
+	// Protocol adapter used when not configured explicitly
+	ProtocolAdapter string
+	// Flash loan venue, if any
+	FlashLiquiditySource string
+	AavePoolAddress      common.Address
+}
+
+var defaultChainPreset = ChainPreset{
+	Name:             "unknown",
+	BlockTime:        12 * time.Second,
+	MulticallAddress: multicall3Address,
+	NativeSymbol:     "ETH",
+	DynamicFees:      true,
+	ProtocolAdapter:  CompoundAdapter,
+}
+
+// chainPresets is keyed by chain ID. Supporting a new chain only
+// requires adding an entry here.
+var chainPresets = map[uint64]ChainPreset{
+	1: {
+		Name:                 "ethereum",
+		BlockTime:            12 * time.Second,
+		MulticallAddress:     multicall3Address,
+		NativeSymbol:         "ETH",
+		DynamicFees:          true,
+		ProtocolAdapter:      CompoundAdapter,
+		FlashLiquiditySource: BalancerFlashLiquidity,
+		AavePoolAddress:      common.HexToAddress("0x87870Bca3F3fD6335C3F4ce8392D69350B4fA4E2"),
+	},
+	10: {
+		Name:                 "optimism",
+		BlockTime:            2 * time.Second,
+		MulticallAddress:     multicall3Address,
+		NativeSymbol:         "ETH",
+		DynamicFees:          true,
+		L1Fee:                L1FeeOPStack,
+		ProtocolAdapter:      CompoundAdapter,
+		FlashLiquiditySource: BalancerFlashLiquidity,
+		AavePoolAddress:      common.HexToAddress("0x794a61358D6845594F94dc1DB02A252b5b4814aD"),
+	},
+	56: {
+		Name:                 "bsc",
+		BlockTime:            3 * time.Second,
+		MulticallAddress:     multicall3Address,
+		NativeSymbol:         "BNB",
+		DynamicFees:          false,
+		ProtocolAdapter:      VenusAdapter,
+		FlashLiquiditySource: AaveFlashLiquidity,
+		AavePoolAddress:      common.HexToAddress("0x6807dc923806fE8Fd134338EABCA509979a7e0cB"),
+	},
+	137: {
+		Name:                 "polygon",
+		BlockTime:            2 * time.Second,
+		MulticallAddress:     multicall3Address,
+		NativeSymbol:         "MATIC",
+		DynamicFees:          true,
+		ProtocolAdapter:      CompoundAdapter,
+		FlashLiquiditySource: BalancerFlashLiquidity,
+		AavePoolAddress:      common.HexToAddress("0x794a61358D6845594F94dc1DB02A252b5b4814aD"),
+	},
+	324: {
+		Name:             "zksync",
+		BlockTime:        1 * time.Second,
+		MulticallAddress: common.HexToAddress("0xF9cda624FBC7e059355ce98a31693d299FACd963"),
+		NativeSymbol:     "ETH",
+		DynamicFees:      true,
+		ProtocolAdapter:  CompoundAdapter,
+	},
+	8453: {
+		Name:                 "base",
+		BlockTime:            2 * time.Second,
+		MulticallAddress:     multicall3Address,
+		NativeSymbol:         "ETH",
+		DynamicFees:          true,
+		L1Fee:                L1FeeOPStack,
+		ProtocolAdapter:      CompoundAdapter,
+		FlashLiquiditySource: BalancerFlashLiquidity,
+		AavePoolAddress:      common.HexToAddress("0xA238Dd80C259a72e81d7e4664a9801593F98d1c5"),
+	},
+	42161: {
+		Name:                 "arbitrum",
+		BlockTime:            250 * time.Millisecond,
+		MulticallAddress:     multicall3Address,
+		NativeSymbol:         "ETH",
+		DynamicFees:          true,
+		L1Fee:                L1FeeArbitrum,
+		ProtocolAdapter:      CompoundAdapter,
+		FlashLiquiditySource: BalancerFlashLiquidity,
+		AavePoolAddress:      common.HexToAddress("0x794a61358D6845594F94dc1DB02A252b5b4814aD"),
+	},
+	43114: {
+		Name:                 "avalanche",
+		BlockTime:            2 * time.Second,
+		MulticallAddress:     multicall3Address,
+		NativeSymbol:         "AVAX",
+		DynamicFees:          true,
+		ProtocolAdapter:      CompoundAdapter,
+		FlashLiquiditySource: BalancerFlashLiquidity,
+		AavePoolAddress:      common.HexToAddress("0x794a61358D6845594F94dc1DB02A252b5b4814aD"),
+	},
+}
+
+// presetFor returns the preset for the provided chain ID, falling
+// back to the default preset for unknown chains.
+func presetFor(chainID uint64) (ChainPreset, bool) {
+	preset, ok := chainPresets[chainID]
+	if !ok {
+		return defaultChainPreset, false
+	}
+	return preset, true
+}
+
+// LogLimits are the limits of log queries of a node endpoint. Zero
+// values are unset and filled in from the preset of the provider.
+type LogLimits struct {
+	// Blocks filtered by a query at most
+	BlockRange uint64
+	// Addresses filtered by a query at most; the rest are split into
+	// further queries
+	AddressBatch int
+	// Queries of a block range in flight at once
+	Concurrency int
+}
+
+// merge fills in the unset limits of l from other.
+func (l LogLimits) merge(other LogLimits) LogLimits {
+	if l.BlockRange == 0 {
+		l.BlockRange = other.BlockRange
+	}
+	if l.AddressBatch == 0 {
+		l.AddressBatch = other.AddressBatch
+	}
+	if l.Concurrency == 0 {
+		l.Concurrency = other.Concurrency
+	}
+	return l
+}
+
+var defaultLogLimits = LogLimits{
+	BlockRange:   defaultBorrowerScanBlockRange,
+	AddressBatch: 0,
+	Concurrency:  1,
+}
+
+// logProviderPresets are keyed by the domain of the provider. Limits
+// found to be too large at runtime are shrunk anyway.
+var logProviderPresets = map[string]LogLimits{
+	"alchemy.com":  {BlockRange: 2000, Concurrency: 4},
+	"infura.io":    {BlockRange: 10000, Concurrency: 2},
+	"quiknode.pro": {BlockRange: 10000, Concurrency: 2},
+}
+
+// logLimitsFor returns the log limits of the endpoint host, from the
+// limits configured for it or its domain, then the ones configured
+// under "*" for any host, then the preset of its provider, then the
+// defaults, and the domain of the provider if known.
+func logLimitsFor(host string, configured map[string]LogLimits) (LogLimits, string) {
+	var limits LogLimits
+	// Most specific domain first
+	domains := make([]string, 0, len(configured))
+	for domain := range configured {
+		if domain != "*" && inDomain(host, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Slice(domains, func(i, j int) bool { return len(domains[i]) > len(domains[j]) })
+	for _, domain := range domains {
+		limits = limits.merge(configured[domain])
+	}
+	limits = limits.merge(configured["*"])
+
+	provider := ""
+	for domain, preset := range logProviderPresets {
+		if inDomain(host, domain) {
+			limits, provider = limits.merge(preset), domain
+			break
+		}
+	}
+	return limits.merge(defaultLogLimits), provider
+}
+
+// inDomain reports whether host is domain or one of its subdomains.
+func inDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,130 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestChainPresets(t *testing.T) {
+	expected := map[uint64]struct {
+		name, native, adapter, flash string
+		blockTime                    time.Duration
+		l1Fee                        L1FeeModel
+		dynamicFees                  bool
+	}{
+		1:     {"ethereum", "ETH", CompoundAdapter, BalancerFlashLiquidity, 12 * time.Second, L1FeeNone, true},
+		10:    {"optimism", "ETH", CompoundAdapter, BalancerFlashLiquidity, 2 * time.Second, L1FeeOPStack, true},
+		56:    {"bsc", "BNB", VenusAdapter, AaveFlashLiquidity, 3 * time.Second, L1FeeNone, false},
+		137:   {"polygon", "MATIC", CompoundAdapter, BalancerFlashLiquidity, 2 * time.Second, L1FeeNone, true},
+		324:   {"zksync", "ETH", CompoundAdapter, "", time.Second, L1FeeNone, true},
+		8453:  {"base", "ETH", CompoundAdapter, BalancerFlashLiquidity, 2 * time.Second, L1FeeOPStack, true},
+		42161: {"arbitrum", "ETH", CompoundAdapter, BalancerFlashLiquidity, 250 * time.Millisecond, L1FeeArbitrum, true},
+		43114: {"avalanche", "AVAX", CompoundAdapter, BalancerFlashLiquidity, 2 * time.Second, L1FeeNone, true},
+	}
+	// A new chain is tested along with its preset
+	if len(expected) != len(chainPresets) {
+		t.Fatalf("expected %d presets tested, got %d", len(chainPresets), len(expected))
+	}
+	for chainID, tc := range expected {
+		preset, ok := presetFor(chainID)
+		if !ok {
+			t.Errorf("expected a preset for chain %d", chainID)
+			continue
+		}
+		if preset.Name != tc.name || preset.NativeSymbol != tc.native || preset.ProtocolAdapter != tc.adapter || preset.FlashLiquiditySource != tc.flash ||
+			preset.BlockTime != tc.blockTime || preset.L1Fee != tc.l1Fee || preset.DynamicFees != tc.dynamicFees {
+			t.Errorf("chain %d: unexpected preset %+v", chainID, preset)
+		}
+	}
+}
+
+// TestChainPresetsUsable checks every preset, and the default one,
+// sets up: a block cadence, a multicall, an adapter, an L1 fee model
+// and a flash loan venue that can be instantiated.
+func TestChainPresetsUsable(t *testing.T) {
+	presets := map[uint64]ChainPreset{0: defaultChainPreset}
+	for chainID, preset := range chainPresets {
+		presets[chainID] = preset
+	}
+	names := make(map[string]uint64)
+	for chainID, preset := range presets {
+		if other, ok := names[preset.Name]; ok {
+			t.Errorf("expected chains %d and %d named differently, both are %s", chainID, other, preset.Name)
+		}
+		names[preset.Name] = chainID
+		if preset.BlockTime <= 0 {
+			t.Errorf("%s: expected a block time, got %v", preset.Name, preset.BlockTime)
+		}
+		if preset.MulticallAddress == (common.Address{}) {
+			t.Errorf("%s: expected a multicall address", preset.Name)
+		}
+		if preset.NativeSymbol == "" || strings.ToUpper(preset.NativeSymbol) != preset.NativeSymbol {
+			t.Errorf("%s: expected an upper case native symbol, got %q", preset.Name, preset.NativeSymbol)
+		}
+		if _, err := newProtocolAdapter(preset.ProtocolAdapter, &Connection{venusLiquidatorAddress: venusLiquidator}, nil); err != nil {
+			t.Errorf("%s: %v", preset.Name, err)
+		}
+		if _, err := newL1FeeEstimator(preset.L1Fee, nil); err != nil {
+			t.Errorf("%s: %v", preset.Name, err)
+		}
+		if _, err := newFlashLiquiditySource(preset.FlashLiquiditySource, nil, preset.AavePoolAddress); err != nil {
+			t.Errorf("%s: %v", preset.Name, err)
+		}
+	}
+
+	if preset, ok := presetFor(31337); ok || preset.Name != defaultChainPreset.Name {
+		t.Fatalf("expected the default preset for an unknown chain, got %s", preset.Name)
+	}
+}
+
+func TestApplyPreset(t *testing.T) {
+	// Unset settings are filled in from the preset
+	c := &Connection{logger: quietLogger(), chainID: big.NewInt(42161)}
+	c.applyPreset()
+	if c.preset.Name != "arbitrum" || c.blockTime != 250*time.Millisecond || *c.multicallAddress != multicall3Address || c.nativeSymbol != "ETH" ||
+		c.adapterName != CompoundAdapter || c.flashLiquidityName != BalancerFlashLiquidity || *c.aavePoolAddress != chainPresets[42161].AavePoolAddress {
+		t.Fatalf("expected the arbitrum preset applied, got %+v", c)
+	}
+
+	// Explicit settings take precedence
+	multicall, aavePool := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	c = &Connection{
+		logger:             quietLogger(),
+		chainID:            big.NewInt(56),
+		blockTime:          time.Second,
+		multicallAddress:   &multicall,
+		nativeSymbol:       "WBNB",
+		adapterName:        CompoundAdapter,
+		flashLiquidityName: "none",
+		aavePoolAddress:    &aavePool,
+	}
+	c.applyPreset()
+	if c.preset.Name != "bsc" || c.blockTime != time.Second || *c.multicallAddress != multicall || c.nativeSymbol != "WBNB" ||
+		c.adapterName != CompoundAdapter || c.flashLiquidityName != "" || *c.aavePoolAddress != aavePool {
+		t.Fatalf("expected the configured settings kept, got %+v", c)
+	}
+
+	c = &Connection{logger: quietLogger(), chainID: big.NewInt(31337)}
+	c.applyPreset()
+	if c.preset.Name != defaultChainPreset.Name || c.blockTime != defaultChainPreset.BlockTime || c.nativeSymbol != "ETH" {
+		t.Fatalf("expected the defaults applied on an unknown chain, got %+v", c)
+	}
+}
+
+func TestPreflightExpectedChainID(t *testing.T) {
+	cfg := &Config{ExpectedChainID: big.NewInt(1)}
+	if err := preflight(context.Background(), nil, big.NewInt(1), cfg, nil); err != nil {
+		t.Fatalf("expected the expected chain accepted, got %v", err)
+	}
+	err := preflight(context.Background(), nil, big.NewInt(137), cfg, nil)
+	var preflightErr *PreflightError
+	if !errors.As(err, &preflightErr) || !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "EXPECTED_CHAIN_ID is 1") {
+		t.Fatalf("expected a contradicting chain refused, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Blocks averaged into the reference price of assets without a feed
+const priceGuardWindow = 10
+
+// priceGuard holds liquidations whose repay or collateral oracle price
+// deviates from a reference price, as a manipulated or glitched oracle
+// print would make them unprofitable. Held accounts are evaluated
+// again in the next block. The reference is the secondary source, if
+// it can price the asset, or the average oracle price over the last
+// blocks.
+type priceGuard struct {
+	// Nil only uses the average price
+	reference PriceSource
+	// Scaled by 1e18, by underlying with the native token keyed by the
+	// zero address
+	limits       map[common.Address]*big.Int
+	defaultLimit *big.Int
+	// Overrides the limits, if any
+	remote *RemoteConfig
+
+	// Oracle prices of the last blocks, oldest first, by market
+	history map[common.Address][]*big.Int
+	// Liquidations held since startup
+	triggers uint64
+}
+
+func newPriceGuard(reference PriceSource, limits map[common.Address]*big.Int, defaultLimit *big.Int) *priceGuard {
+	if defaultLimit == nil {
+		defaultLimit = defaultMaxPriceDeviation
+	}
+	return &priceGuard{
+		reference:    reference,
+		limits:       limits,
+		defaultLimit: defaultLimit,
+		history:      make(map[common.Address][]*big.Int),
+	}
+}
+
+// observe returns the average price of every market over the previous
+// blocks and remembers the prices of the current one, if known.
+func (g *priceGuard) observe(markets []common.Address, prices []*Price) map[common.Address]*big.Int {
+	averages := make(map[common.Address]*big.Int, len(markets))
+	for i, market := range markets {
+		history := g.history[market]
+		if len(history) > 0 {
+			sum := new(big.Int)
+			for _, price := range history {
+				sum.Add(sum, price)
+			}
+			averages[market] = sum.Div(sum, big.NewInt(int64(len(history))))
+		}
+		if prices == nil || prices[i] == nil {
+			continue
+		}
+		if len(history) == priceGuardWindow {
+			history = append(history[:0], history[1:]...)
+		}
+		g.history[market] = append(history, prices[i].Mantissa)
+	}
+	return averages
+}
+
+// check returns an error if the price of either market of the plan
+// deviates from its reference by more than the limit of its
+// underlying. Prices without a reference are unchecked.
+func (g *priceGuard) check(ctx context.Context, s *Snapshot, plan *LiquidationPlan, averages map[common.Address]*big.Int) (common.Address, error) {
+	for _, market := range []common.Address{plan.BorrowMarket, plan.CollateralMarket} {
+		m := s.Markets[market]
+		if m.Price == nil {
+			continue
+		}
+		source, reference := "average", averages[market]
+		if g.reference != nil {
+			price, err := g.reference.PriceOf(ctx, Asset{Market: market, Underlying: m.Underlying, Decimals: m.Decimals}, nil)
+			if err == nil {
+				source, reference = g.reference.Name(), price.Mantissa
+			}
+		}
+		if IsZero(reference) {
+			continue
+		}
+
+		limit, ok := g.remote.deviationLimit(m.Underlying)
+		if !ok {
+			limit, ok = g.limits[m.Underlying]
+		}
+		if !ok {
+			limit = g.defaultLimit
+		}
+		if deviation := priceDeviation(&Price{Mantissa: m.Price}, &Price{Mantissa: reference}); deviation.Exceeds(mantissaRatio(limit)) {
+			atomic.AddUint64(&g.triggers, 1)
+			return market, fmt.Errorf("oracle price %v of %s deviates by %s from %s price %v, over %s: %w",
+				m.Price, m.Symbol, deviation.Percent(), source, reference, mantissaRatio(limit).Percent(), ErrPriceDeviation)
+		}
+	}
+	return common.Address{}, nil
+}
@@ -0,0 +1,353 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+var errNoPrice = errors.New("no price")
+
+// Asset is a market's underlying as seen by a price source.
+type Asset struct {
+	Market common.Address
+	// Zero for the native token
+	Underlying common.Address
+	Decimals   uint8
+}
+
+// Price is the price of the smallest unit of an asset in the unit of
+// account scaled by 1e36, ie., the price of a whole unit scaled by
+// 1e(36-decimals), which is how Compound oracles report prices.
+type Price struct {
+	Mantissa *big.Int
+}
+
+// Value returns the value of amount of the asset in the unit of
+// account scaled by 1e18, as USDValue does.
+func (p Price) Value(amount *big.Int) *big.Int {
+	return valueInto(new(big.Int), p.Mantissa, amount)
+}
+
+// USDValue returns the value of amount smallest units of a token of
+// decimals at price, scaled by 1e(36-decimals) as Compound oracles
+// report it, in the unit of account, USD for most pools, scaled by
+// 1e18. The decimals of the token cancel out in the price scaling, so
+// amounts must only be valued at the price of their own token.
+func USDValue(amount, price *big.Int, decimals uint8) *big.Int {
+	return NewAmount(amount, decimals).MulPrice(price).Value
+}
+
+// UnitPrice returns the price of a whole unit of a token of decimals in
+// the unit of account scaled by 1e18. Unlike oracle prices, unit prices
+// of tokens of different decimals compare.
+func UnitPrice(price *big.Int, decimals uint8) *big.Int {
+	return USDValue(pow10(decimals), price, decimals)
+}
+
+// PriceSource prices assets at a block; a nil block is the latest
+// one. Implementations normalize prices to the Price scaling.
+type PriceSource interface {
+	Name() string
+	PriceOf(ctx context.Context, asset Asset, block *big.Int) (*Price, error)
+}
+
+// batchPriceSource prices several assets at once. Assets that cannot
+// be priced have a nil price and an error; the last error is only set
+// when no asset could be priced at all.
+type batchPriceSource interface {
+	PricesOf(ctx context.Context, assets []Asset, block *big.Int) ([]*Price, []error, error)
+}
+
+// pricesOf prices assets and logs the ones that cannot be priced,
+// whose prices are nil.
+func pricesOf(ctx context.Context, logger Logger, source PriceSource, assets []Asset, block *big.Int) ([]*Price, error) {
+	prices, errs, err := priceAll(ctx, source, assets, block)
+	if err != nil {
+		return nil, err
+	}
+	for i, err := range errs {
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Failed to get %s price of market %s: %v", source.Name(), assets[i].Market, err),
+				F("market", assets[i].Market), F("source", source.Name()), F("err", err))
+		}
+	}
+	return prices, nil
+}
+
+// priceAll prices assets in a batch if the source supports it.
+func priceAll(ctx context.Context, source PriceSource, assets []Asset, block *big.Int) ([]*Price, []error, error) {
+	if batch, ok := source.(batchPriceSource); ok {
+		return batch.PricesOf(ctx, assets, block)
+	}
+	prices := make([]*Price, len(assets))
+	errs := make([]error, len(assets))
+	for i, asset := range assets {
+		prices[i], errs[i] = source.PriceOf(ctx, asset, block)
+	}
+	return prices, errs, nil
+}
+
+// oraclePriceSource reads the price oracle of a comptroller.
+type oraclePriceSource struct {
+	comptroller Comptroller
+	batcher     CallBatcher
+}
+
+func NewOraclePriceSource(comptroller Comptroller, batcher CallBatcher) PriceSource {
+	return &oraclePriceSource{comptroller: comptroller, batcher: batcher}
+}
+
+func (s *oraclePriceSource) Name() string {
+	return "oracle"
+}
+
+func (s *oraclePriceSource) PriceOf(ctx context.Context, asset Asset, block *big.Int) (*Price, error) {
+	return priceOne(ctx, s, asset, block)
+}
+
+func (s *oraclePriceSource) PricesOf(ctx context.Context, assets []Asset, block *big.Int) ([]*Price, []error, error) {
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: block}
+	oracle, err := s.comptroller.Oracle(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot fetch price oracle: %w", err)
+	}
+	priceOracleABI, err := abis.PriceOracleMetaData.GetAbi()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get price oracle ABI: %w", err)
+	}
+	getPriceMethod := priceOracleABI.Methods["getUnderlyingPrice"]
+
+	calls := make([]abis.MulticallCall, 0, len(assets))
+	for _, asset := range assets {
+		inputs, err := getPriceMethod.Inputs.Pack(asset.Market)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot pack cToken: %w", err)
+		}
+		calls = append(calls, abis.MulticallCall{
+			Target:   oracle,
+			CallData: append(getPriceMethod.ID[:], inputs[:]...),
+		})
+	}
+
+	resp, err := s.batcher.Aggregate(opts, calls)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed batch request: %v", err)
+	}
+
+	prices := make([]*Price, len(assets))
+	errs := make([]error, len(assets))
+	for i, result := range resp {
+		if !result.Success {
+			errs[i] = fmt.Errorf("oracle %s reverted: %w", oracle, errNoPrice)
+			continue
+		}
+		price := &Price{}
+		if err := priceOracleABI.UnpackIntoInterface(&price.Mantissa, getPriceMethod.Name, result.ReturnData); err != nil {
+			errs[i] = fmt.Errorf("cannot unpack price output: %v", err)
+			continue
+		}
+		prices[i] = price
+	}
+	return prices, errs, nil
+}
+
+// chainlinkPriceSource reads Chainlink feeds keyed by underlying, with
+// the native token keyed by the zero address.
+type chainlinkPriceSource struct {
+	caller bind.ContractCaller
+	feeds  map[common.Address]common.Address
+
+	mu sync.Mutex
+	// Feed decimals never change
+	decimals map[common.Address]uint8
+}
+
+func NewChainlinkPriceSource(caller bind.ContractCaller, feeds map[common.Address]common.Address) PriceSource {
+	return &chainlinkPriceSource{
+		caller:   caller,
+		feeds:    feeds,
+		decimals: make(map[common.Address]uint8),
+	}
+}
+
+func (s *chainlinkPriceSource) Name() string {
+	return "chainlink"
+}
+
+func (s *chainlinkPriceSource) PriceOf(ctx context.Context, asset Asset, block *big.Int) (*Price, error) {
+	feed, ok := s.feeds[asset.Underlying]
+	if !ok {
+		return nil, fmt.Errorf("no feed for %s: %w", asset.Underlying, errNoPrice)
+	}
+	aggregator, err := abis.NewChainlinkAggregatorCaller(feed, s.caller)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate feed %s: %w", feed, err)
+	}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: block}
+
+	feedDecimals, err := s.feedDecimals(opts, feed, aggregator)
+	if err != nil {
+		return nil, err
+	}
+	round, err := aggregator.LatestRoundData(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get latest round of feed %s: %w", feed, err)
+	}
+	if !IsPositive(round.Answer) {
+		return nil, fmt.Errorf("invalid answer %v of feed %s: %w", round.Answer, feed, ErrStaleData)
+	}
+
+	// Answers are the price of a whole unit scaled by 1e(feed decimals)
+	return &Price{Mantissa: rescale(round.Answer, int(feedDecimals), 36-int(asset.Decimals))}, nil
+}
+
+func (s *chainlinkPriceSource) feedDecimals(opts *bind.CallOpts, feed common.Address, aggregator *abis.ChainlinkAggregatorCaller) (uint8, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if decimals, ok := s.decimals[feed]; ok {
+		return decimals, nil
+	}
+	decimals, err := aggregator.Decimals(opts)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get decimals of feed %s: %w", feed, err)
+	}
+	s.decimals[feed] = decimals
+	return decimals, nil
+}
+
+// staticPriceSource returns fixed prices keyed by market or
+// underlying, eg., for tests and backtests.
+type staticPriceSource struct {
+	prices map[common.Address]*Price
+}
+
+func NewStaticPriceSource(prices map[common.Address]*Price) PriceSource {
+	return &staticPriceSource{prices: prices}
+}
+
+func (s *staticPriceSource) Name() string {
+	return "static"
+}
+
+func (s *staticPriceSource) PriceOf(_ context.Context, asset Asset, _ *big.Int) (*Price, error) {
+	if price, ok := s.prices[asset.Market]; ok {
+		return price, nil
+	}
+	if price, ok := s.prices[asset.Underlying]; ok {
+		return price, nil
+	}
+	return nil, fmt.Errorf("cannot get price of market %s: %w", asset.Market, errNoPrice)
+}
+
+// checkedPriceSource returns the prices of the primary source unless
+// they deviate from the ones of the secondary by more than the
+// maximum deviation. Assets the secondary cannot price are unchecked.
+type checkedPriceSource struct {
+	primary   PriceSource
+	secondary PriceSource
+	// Scaled by 1e18, eg., 5e16 for 5%
+	maxDeviation *big.Int
+}
+
+func NewCheckedPriceSource(primary, secondary PriceSource, maxDeviation *big.Int) PriceSource {
+	return &checkedPriceSource{primary: primary, secondary: secondary, maxDeviation: maxDeviation}
+}
+
+func (s *checkedPriceSource) Name() string {
+	return fmt.Sprintf("%s/%s", s.primary.Name(), s.secondary.Name())
+}
+
+func (s *checkedPriceSource) PriceOf(ctx context.Context, asset Asset, block *big.Int) (*Price, error) {
+	return priceOne(ctx, s, asset, block)
+}
+
+func (s *checkedPriceSource) PricesOf(ctx context.Context, assets []Asset, block *big.Int) ([]*Price, []error, error) {
+	prices, errs, err := priceAll(ctx, s.primary, assets, block)
+	if err != nil {
+		return nil, nil, err
+	}
+	checks, _, err := priceAll(ctx, s.secondary, assets, block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get %s prices: %w", s.secondary.Name(), err)
+	}
+
+	for i, price := range prices {
+		if price == nil || checks[i] == nil || IsZero(checks[i].Mantissa) {
+			continue
+		}
+		if deviation := priceDeviation(price, checks[i]); deviation.Exceeds(mantissaRatio(s.maxDeviation)) {
+			errs[i] = fmt.Errorf("%s price %v deviates by %s from %s price %v: %w",
+				s.primary.Name(), price.Mantissa, deviation.Percent(), s.secondary.Name(), checks[i].Mantissa, ErrStaleData)
+			prices[i] = nil
+		}
+	}
+	return prices, errs, nil
+}
+
+// priceOne prices a single asset with a batch source.
+func priceOne(ctx context.Context, source batchPriceSource, asset Asset, block *big.Int) (*Price, error) {
+	prices, errs, err := source.PricesOf(ctx, []Asset{asset}, block)
+	if err != nil {
+		return nil, err
+	}
+	return prices[0], errs[0]
+}
+
+// priceDeviation returns |price - check| / check.
+func priceDeviation(price, check *Price) Ratio {
+	diff := new(big.Int).Sub(price.Mantissa, check.Mantissa)
+	return NewRatio(diff.Abs(diff), check.Mantissa)
+}
+
+// defaultMaxPriceDeviation is 5%
+var defaultMaxPriceDeviation = big.NewInt(5e16)
+
+// priceSource returns the configured price source of a pool, checked
+// against Chainlink feeds if any are configured.
+func (c *Connection) priceSource(comptroller Comptroller) PriceSource {
+	source := c.config.PriceSource
+	if source == nil {
+		source = NewOraclePriceSource(comptroller, c.Batcher)
+	}
+	if len(c.config.ChainlinkFeeds) == 0 {
+		return source
+	}
+	maxDeviation := c.config.MaxPriceDeviation
+	if maxDeviation == nil {
+		maxDeviation = defaultMaxPriceDeviation
+	}
+	return NewCheckedPriceSource(source, NewChainlinkPriceSource(c.client, c.config.ChainlinkFeeds), maxDeviation)
+}
+
+// priceGuard returns the guard of liquidations against oracle prices,
+// referencing Chainlink feeds if any are configured.
+func (c *Connection) priceGuard() *priceGuard {
+	var reference PriceSource
+	if len(c.config.ChainlinkFeeds) > 0 {
+		reference = NewChainlinkPriceSource(c.client, c.config.ChainlinkFeeds)
+	}
+	guard := newPriceGuard(reference, c.config.PriceDeviationLimits, c.config.MaxPriceDeviation)
+	guard.remote = c.remoteConfig
+	return guard
+}
+
+// rescale converts value from a scale of 10^from to one of 10^to.
+func rescale(value *big.Int, from, to int) *big.Int {
+	if from == to {
+		return new(big.Int).Set(value)
+	}
+	if from < to {
+		factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(to-from)), nil)
+		return factor.Mul(factor, value)
+	}
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(from-to)), nil)
+	return factor.Div(value, factor)
+}
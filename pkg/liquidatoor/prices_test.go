@@ -0,0 +1,69 @@
+package liquidatoor
+
+import (
+	"math/big"
+	"testing"
+)
+
+// Oracle prices of a whole unit scaled by 1e(36-decimals)
+func TestUSDValue(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		decimals uint8
+		amount   string
+		price    string
+		expected string
+	}{
+		// 1234.56789 USDC at $1
+		{"USDC", 6, "1234567890", "1000000000000000000000000000000", "1234567890000000000000"},
+		// One micro USDC at $0.999999999999999999999999999999
+		{"USDC truncated", 6, "1", "999999999999999999999999999999", "999999999999"},
+		// 0.5 WBTC at $30000
+		{"WBTC", 8, "50000000", "300000000000000000000000000000000", "15000000000000000000000"},
+		// 0.00000001 WBTC at $30000.5
+		{"WBTC satoshi", 8, "1", "300005000000000000000000000000000", "300005000000000"},
+		// 1.5 ETH at $2000
+		{"ETH", 18, "1500000000000000000", "2000000000000000000000", "3000000000000000000000"},
+		// One wei at $1999.999999999999999999, truncated
+		{"ETH wei", 18, "1", "1999999999999999999999", "1999"},
+		// 100 million ETH at $5000
+		{"ETH large", 18, "100000000000000000000000000", "5000000000000000000000", "500000000000000000000000000000"},
+		{"zero", 18, "0", "2000000000000000000000", "0"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			value := USDValue(mustInt(tc.amount), mustInt(tc.price), tc.decimals)
+			if value.Cmp(mustInt(tc.expected)) != 0 {
+				t.Fatalf("expected %s, got %v", tc.expected, value)
+			}
+			// Price.Value and MarketSnapshot.Value agree
+			if value := (Price{Mantissa: mustInt(tc.price)}).Value(mustInt(tc.amount)); value.Cmp(mustInt(tc.expected)) != 0 {
+				t.Fatalf("expected Price.Value %s, got %v", tc.expected, value)
+			}
+			m := MarketSnapshot{Decimals: tc.decimals, Price: mustInt(tc.price)}
+			if value := m.Value(mustInt(tc.amount)); value.Cmp(mustInt(tc.expected)) != 0 {
+				t.Fatalf("expected MarketSnapshot.Value %s, got %v", tc.expected, value)
+			}
+		})
+	}
+}
+
+func TestUnitPrice(t *testing.T) {
+	for _, tc := range []struct {
+		decimals uint8
+		price    string
+		expected string
+	}{
+		{6, "1000000000000000000000000000000", "1000000000000000000"},
+		{8, "300000000000000000000000000000000", "30000000000000000000000"},
+		{18, "2000000000000000000000", "2000000000000000000000"},
+	} {
+		if unit := UnitPrice(mustInt(tc.price), tc.decimals); unit.Cmp(mustInt(tc.expected)) != 0 {
+			t.Errorf("expected the unit price of %d decimals at %s to be %s, got %v", tc.decimals, tc.price, tc.expected, unit)
+		}
+	}
+	// Unit prices of a dollar compare across decimals
+	usdc, dai := UnitPrice(mustInt("1000000000000000000000000000000"), 6), UnitPrice(big.NewInt(1e18), 18)
+	if usdc.Cmp(dai) != 0 {
+		t.Fatalf("expected USDC and DAI unit prices to be equal, got %v and %v", usdc, dai)
+	}
+}
@@ -0,0 +1,122 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// BlockExecution is what processing a block does with its liquidatable
+// candidates.
+type BlockExecution int
+
+const (
+	// Queued for the workers of the execution queue, as SubscribeToBlocks
+	// does
+	ExecuteQueued BlockExecution = iota
+	// Executed one at a time before the block is done, so that their
+	// transactions are reported; the next block waits for them
+	ExecuteInline
+	// Only reported
+	ExecuteNone
+)
+
+// BlockResult is what processing a block of a pool found and did.
+type BlockResult struct {
+	Pool  common.Address
+	Block *big.Int
+	// Why the block was not checked, if it was not, eg.,
+	// ErrCacheNotPrimed
+	Err error
+	// Set if nothing changed since the last block checked, so the
+	// underwater accounts were not looked at again
+	SkipReason string
+
+	Borrowers  int
+	Underwater int
+	// Underwater accounts, in the order looked at, by decreasing
+	// shortfall, with their plans, estimates and decisions; the Err of
+	// a candidate is why it was dropped
+	Candidates []Candidate
+	// Of the liquidatable candidates
+	Executions []BlockExecutionResult
+	// Encountered without failing the block, eg., the accounts whose
+	// liquidity could not be read
+	Errors  []error
+	Timings BlockTimings
+}
+
+// BlockExecutionResult is the execution of a liquidatable candidate of
+// a block.
+type BlockExecutionResult struct {
+	Account common.Address
+	Rank    *big.Int
+	// Whether the candidate was queued, or, if executed inline, its
+	// outcome, with the transaction sent, if any, and why it failed
+	Queued  bool
+	Outcome *Outcome
+	Err     error
+}
+
+// BlockTimings are how long the stages of processing a block took.
+type BlockTimings struct {
+	// Reading the start of the block, concurrently
+	Prices    time.Duration
+	State     time.Duration
+	Borrowers time.Duration
+	// Reading the liquidity of every borrower
+	Liquidities time.Duration
+	// Planning, ranking and executing the underwater accounts
+	Evaluation time.Duration
+	Total      time.Duration
+}
+
+// SetExecution sets what processing a block does with its liquidatable
+// candidates; ExecuteQueued by default. Candidates are never executed
+// without an executor, or while simulating.
+func (l *Liquidatoor) SetExecution(execution BlockExecution) {
+	l.checkLock.Lock()
+	defer l.checkLock.Unlock()
+	l.execution = execution
+}
+
+// Start keeps the borrower cache, the liquidation parameters, the
+// pauses and the prices of the pool up to date until ctx is cancelled,
+// for callers feeding blocks to ProcessBlock themselves.
+func (l *Liquidatoor) Start(ctx context.Context) {
+	go l.borrowerCache.Init(ctx)
+	l.watchLiquidationParams(ctx)
+	l.watchPauses(ctx)
+	l.watchPriceUpdates(ctx)
+}
+
+// ProcessBlock checks every cached borrower at header, plans, ranks and
+// executes the liquidations of the underwater ones, and returns what it
+// found and did. It fails, as does the Err of the result, if the block
+// could not be checked, with ErrCacheNotPrimed until the borrower cache
+// is primed.
+func (l *Liquidatoor) ProcessBlock(ctx context.Context, header *types.Header) (*BlockResult, error) {
+	result := &BlockResult{Pool: l.comptrollerAddress, Block: header.Number}
+	began := time.Now()
+	err := l.shortfallCheck(ctx, header.Number, result)
+	result.Timings.Total = time.Since(began)
+	result.Err = err
+	switch {
+	case err == nil:
+		l.readiness.primed(l.comptrollerAddress, header.Number)
+		l.observeLiquidations(ctx, header.Number)
+	case errors.Is(err, ErrCacheNotPrimed):
+		// Ignore if the cache is not primed yet
+		l.logger.Info("Empty borrower cache; aborting shortfall check", F("pool", l.comptrollerAddress), F("block", header.Number))
+		l.readiness.skip(l.comptrollerAddress)
+	default:
+		l.logger.Error(fmt.Sprintf("Failed shortfall check: %v", err), F("pool", l.comptrollerAddress), F("block", header.Number), F("err", err))
+		l.readiness.skip(l.comptrollerAddress)
+	}
+	return result, err
+}
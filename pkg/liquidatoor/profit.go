@@ -0,0 +1,167 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Unit of account of estimates priced by the pool oracle
+const OracleUnitOfAccount = "oracle"
+
+// Gas assumed for a liquidation whose simulation reverts, eg., because
+// the wallet does not hold the repay amount yet.
+const fallbackLiquidationGas = 600000
+
+// ProfitEstimator predicts the profit of executing a liquidation plan.
+type ProfitEstimator interface {
+	Name() string
+	Estimate(ctx context.Context, plan LiquidationPlan, snapshot *Snapshot) (*ProfitEstimate, error)
+}
+
+// ProfitEstimate values are denominated in Currency scaled by 1e18.
+type ProfitEstimate struct {
+	Estimator string
+	Currency  string
+	// Value of the seized collateral minus the repaid value
+	Gross    *big.Int
+	Gas      *big.Int
+	Slippage *big.Int
+	Net      *big.Int
+	// Gas limit of the repay transaction when estimated, and, if it
+	// saves gas, the access list executors attach to it and the estimate
+	// without it; see Config.AccessLists
+	GasLimit             uint64           `json:",omitempty"`
+	GasWithoutAccessList uint64           `json:",omitempty"`
+	AccessList           types.AccessList `json:",omitempty"`
+}
+
+// Profitable reports whether the estimate is of a positive net profit;
+// a nil estimate is not.
+func (e *ProfitEstimate) Profitable() bool {
+	return e != nil && IsPositive(e.Net)
+}
+
+// MarshalJSON encodes the values of the estimate as decimal strings.
+func (e ProfitEstimate) MarshalJSON() ([]byte, error) {
+	type estimate ProfitEstimate
+	return json.Marshal(struct {
+		estimate
+		Gross    *decimalInt
+		Gas      *decimalInt
+		Slippage *decimalInt
+		Net      *decimalInt
+	}{estimate(e), decimal(e.Gross), decimal(e.Gas), decimal(e.Slippage), decimal(e.Net)})
+}
+
+func (e *ProfitEstimate) UnmarshalJSON(data []byte) error {
+	type estimate ProfitEstimate
+	v := struct {
+		*estimate
+		Gross    *decimalInt
+		Gas      *decimalInt
+		Slippage *decimalInt
+		Net      *decimalInt
+	}{estimate: (*estimate)(e)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	e.Gross, e.Gas, e.Slippage, e.Net = v.Gross.int(), v.Gas.int(), v.Slippage.int(), v.Net.int()
+	return nil
+}
+
+func (e ProfitEstimate) String() string {
+	return fmt.Sprintf("net %s %s (gross %s, gas %s, slippage %s)", formatValue(e.Net), e.Currency, formatValue(e.Gross), formatValue(e.Gas), formatValue(e.Slippage))
+}
+
+// oracleProfitEstimator prices plans with the pool oracle and the gas
+// of the repay transaction. Seized collateral is kept as cTokens so
+// there is no slippage.
+type oracleProfitEstimator struct {
+	l *Liquidatoor
+}
+
+func newOracleProfitEstimator(l *Liquidatoor) ProfitEstimator {
+	return &oracleProfitEstimator{l: l}
+}
+
+func (e *oracleProfitEstimator) Name() string {
+	return "oracle"
+}
+
+func (e *oracleProfitEstimator) Estimate(ctx context.Context, plan LiquidationPlan, s *Snapshot) (*ProfitEstimate, error) {
+	gross := new(big.Int).Sub(plan.SeizeValue, plan.RepayValue)
+
+	cost, err := e.gasCost(ctx, plan, s)
+	if err != nil {
+		return nil, err
+	}
+	gas := nativeValue(s, cost.Total)
+
+	slippage := new(big.Int)
+	net := new(big.Int).Sub(gross, gas)
+	net.Sub(net, slippage)
+
+	return &ProfitEstimate{
+		Estimator: e.Name(),
+		Currency:  OracleUnitOfAccount,
+		Gross:     gross,
+		Gas:       gas,
+		Slippage:  slippage,
+		Net:       net,
+
+		GasLimit:             cost.Gas,
+		GasWithoutAccessList: cost.GasWithoutAccessList,
+		AccessList:           cost.AccessList,
+	}, nil
+}
+
+// gasCost returns the cost of the repay transaction, in the native
+// token.
+func (e *oracleProfitEstimator) gasCost(ctx context.Context, plan LiquidationPlan, s *Snapshot) (*GasCost, error) {
+	call, err := e.l.adapter.RepayCall(RepayParams{
+		Borrower:         plan.Borrower,
+		CTokenBorrowed:   plan.BorrowMarket,
+		CTokenCollateral: plan.CollateralMarket,
+		RepayAmount:      plan.RepayAmount,
+		Native:           s.Markets[plan.BorrowMarket].Native,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot build repay call: %w", err)
+	}
+
+	cost, err := e.l.estimateGasCost(ctx, call)
+	if err == nil {
+		return cost, nil
+	}
+	if !errors.Is(err, ErrSimulationReverted) {
+		return nil, err
+	}
+	e.l.logger.Warn(fmt.Sprintf("Failed to estimate gas of liquidating %s, assuming %d gas: %v", plan.Borrower, fallbackLiquidationGas, err),
+		F("pool", e.l.comptrollerAddress), F("account", plan.Borrower), F("err", err))
+
+	gasPrice, err := e.l.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get gas price: %w", err)
+	}
+	if gasPrice, err = e.l.gasCap.price(ctx, e.l.client, gasPrice); err != nil {
+		return nil, err
+	}
+	return &GasCost{Gas: fallbackLiquidationGas, GasPrice: gasPrice, Total: new(big.Int).Mul(big.NewInt(fallbackLiquidationGas), gasPrice)}, nil
+}
+
+// nativeValue converts a cost in the native token to the oracle's unit
+// of account, rounded up. Pools without a priced native market are assumed
+// to be priced in the native token, as Fuse pools are.
+func nativeValue(s *Snapshot, amount *big.Int) *big.Int {
+	for _, market := range s.Markets {
+		if market.Native && IsPositive(market.Price) {
+			return costOf(market, amount)
+		}
+	}
+	return new(big.Int).Set(amount)
+}
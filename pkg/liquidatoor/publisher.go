@@ -0,0 +1,377 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// PublishedSchemaVersion is the version of PublishedEvent, bumped on
+// incompatible changes. Version 1 events are JSON objects with:
+//
+//	v         schema version
+//	id        unique id of the event, to acknowledge it with
+//	candidate id of the candidate, to mark it handled with: pool,
+//	          account and block, if known, separated by colons
+//	entry     the event, a JournalEntry of schema JournalSchemaVersion
+//	          of kind candidate, decision, submission or receipt
+//
+// Events are delivered at least once: they are published again until
+// acknowledged, so consumers need to skip the ids they already handled.
+// Acknowledgments are PublishedAck objects published on the ack
+// subject.
+const PublishedSchemaVersion = 1
+
+const (
+	defaultPublishSubject = "liquidatoor.candidates"
+	// Unacknowledged events buffered before dropping the oldest
+	defaultPublishBuffer = 1024
+	// Wait for an acknowledgment before publishing again
+	defaultPublishRedelivery = 30 * time.Second
+	// Candidates marked handled are remembered for
+	handledTTL = 10 * time.Minute
+	// Waits before connecting again, doubling up to the maximum
+	publishMinRetry = time.Second
+	publishMaxRetry = 30 * time.Second
+)
+
+// PublishedEvent is an event of the publisher; see
+// PublishedSchemaVersion.
+type PublishedEvent struct {
+	Version   int          `json:"v"`
+	ID        string       `json:"id"`
+	Candidate string       `json:"candidate"`
+	Entry     JournalEntry `json:"entry"`
+}
+
+// PublishedAck acknowledges the event of ID. Handled marks its
+// candidate as handled by the consumer, so a candidate not yet
+// executed is not executed locally.
+type PublishedAck struct {
+	ID      string `json:"id"`
+	Handled bool   `json:"handled,omitempty"`
+}
+
+// PublisherStats counts the events of the publisher.
+type PublisherStats struct {
+	Published   uint64
+	Redelivered uint64
+	Acked       uint64
+	Handled     uint64
+	// Dropped when the buffer was full
+	Dropped  uint64
+	Buffered int
+}
+
+type publishedMessage struct {
+	id        string
+	candidate string
+	payload   []byte
+	published bool
+	// Zero until published on the current connection
+	sentAt time.Time
+}
+
+// publisher publishes candidates and their outcomes to NATS for an
+// external execution pipeline, buffering the unacknowledged ones,
+// bounded, across reconnects. A nil publisher publishes nothing.
+type publisher struct {
+	logger     Logger
+	url        string
+	subject    string
+	ackSubject string
+	buffer     int
+	redelivery time.Duration
+	// Wait for a candidate to be marked handled before executing it
+	handoff  time.Duration
+	instance string
+
+	lock     sync.Mutex
+	seq      uint64
+	messages []*publishedMessage
+	byID     map[string]*publishedMessage
+	// Candidates marked handled, until they expire
+	handled map[string]time.Time
+	// Closed and replaced on every candidate marked handled
+	marked chan struct{}
+	counts PublisherStats
+	// Signals new messages
+	queued chan struct{}
+}
+
+func newPublisher(logger Logger, url, subject, ackSubject string, buffer int, redelivery, handoff time.Duration) (*publisher, error) {
+	instance, err := instanceID()
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		subject = defaultPublishSubject
+	}
+	if ackSubject == "" {
+		ackSubject = subject + ".ack"
+	}
+	if buffer == 0 {
+		buffer = defaultPublishBuffer
+	}
+	if redelivery == 0 {
+		redelivery = defaultPublishRedelivery
+	}
+	if redelivery < 0 || handoff < 0 {
+		return nil, fmt.Errorf("%w: NATS_REDELIVERY and NATS_HANDOFF cannot be negative", ErrInvalidConfig)
+	}
+	return &publisher{
+		logger:     logger,
+		url:        url,
+		subject:    subject,
+		ackSubject: ackSubject,
+		buffer:     buffer,
+		redelivery: redelivery,
+		handoff:    handoff,
+		instance:   instance,
+		byID:       make(map[string]*publishedMessage),
+		handled:    make(map[string]time.Time),
+		marked:     make(chan struct{}),
+		queued:     make(chan struct{}, 1),
+	}, nil
+}
+
+// candidateID identifies the candidate of account in pool found in
+// block, if known, across events.
+func candidateID(c Candidate) string {
+	if c.Block == nil {
+		return fmt.Sprintf("%s:%s", c.Pool, c.Account)
+	}
+	return fmt.Sprintf("%s:%s:%s", c.Pool, c.Account, c.Block)
+}
+
+// publish buffers entry, an event of candidate c, to be published,
+// dropping the oldest event if the buffer is full.
+func (p *publisher) publish(c Candidate, entry JournalEntry) {
+	if p == nil {
+		return
+	}
+	entry.Version = JournalSchemaVersion
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	p.lock.Lock()
+	p.seq++
+	event := PublishedEvent{Version: PublishedSchemaVersion, ID: fmt.Sprintf("%s-%d", p.instance, p.seq), Candidate: candidateID(c), Entry: entry}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		p.lock.Unlock()
+		p.logger.Error(fmt.Sprintf("Cannot encode event of account %s: %v", c.Account, err), F("pool", c.Pool), F("account", c.Account), F("err", err))
+		return
+	}
+	if len(p.messages) >= p.buffer {
+		oldest := p.messages[0]
+		p.messages = p.messages[1:]
+		delete(p.byID, oldest.id)
+		p.counts.Dropped++
+		if p.counts.Dropped == 1 || p.counts.Dropped%uint64(p.buffer) == 0 {
+			p.logger.Warn(fmt.Sprintf("Publisher buffer of %d events is full, dropped %d so far", p.buffer, p.counts.Dropped), F("dropped", p.counts.Dropped))
+		}
+	}
+	message := &publishedMessage{id: event.ID, candidate: event.Candidate, payload: payload}
+	p.messages = append(p.messages, message)
+	p.byID[message.id] = message
+	p.lock.Unlock()
+
+	select {
+	case p.queued <- struct{}{}:
+	default:
+	}
+}
+
+// publishCandidate publishes what was known of a candidate in block and
+// whether it is liquidated, like journalCandidate.
+func (p *publisher) publishCandidate(block *big.Int, c Candidate) {
+	if p == nil {
+		return
+	}
+	for _, entry := range candidateEntries(block, c) {
+		p.publish(c, entry)
+	}
+}
+
+// awaitHandled reports whether the consumer marked c handled, waiting
+// for it up to the handoff configured.
+func (p *publisher) awaitHandled(ctx context.Context, c Candidate) bool {
+	if p == nil {
+		return false
+	}
+	id := candidateID(c)
+	timeout := time.NewTimer(p.handoff)
+	defer timeout.Stop()
+	for {
+		p.lock.Lock()
+		until, ok := p.handled[id]
+		marked := p.marked
+		p.lock.Unlock()
+		if ok && time.Now().Before(until) {
+			return true
+		}
+		select {
+		case <-marked:
+		case <-timeout.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// ack handles an acknowledgment.
+func (p *publisher) ack(data []byte) {
+	var ack PublishedAck
+	if err := json.Unmarshal(data, &ack); err != nil || ack.ID == "" {
+		p.logger.Warn(fmt.Sprintf("Skipping invalid acknowledgment %q", data), F("subject", p.ackSubject))
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	message, ok := p.byID[ack.ID]
+	if !ok {
+		// Acknowledged already, or dropped
+		return
+	}
+	delete(p.byID, ack.ID)
+	for i := range p.messages {
+		if p.messages[i] == message {
+			p.messages = append(p.messages[:i], p.messages[i+1:]...)
+			break
+		}
+	}
+	p.counts.Acked++
+	if !ack.Handled {
+		return
+	}
+	now := time.Now()
+	for id, until := range p.handled {
+		if now.After(until) {
+			delete(p.handled, id)
+		}
+	}
+	p.handled[message.candidate] = now.Add(handledTTL)
+	p.counts.Handled++
+	close(p.marked)
+	p.marked = make(chan struct{})
+}
+
+// run publishes the buffered events until ctx is cancelled, connecting
+// again whenever the connection fails.
+func (p *publisher) run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	retry := publishMinRetry
+	for {
+		connected, err := p.connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			retry = publishMinRetry
+		}
+		p.logger.Warn(fmt.Sprintf("Publisher disconnected, connecting again in %v: %v", retry, err), F("subject", p.subject), F("err", err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		if retry *= 2; retry > publishMaxRetry {
+			retry = publishMaxRetry
+		}
+	}
+}
+
+// connect publishes over one connection until it fails, and reports
+// whether it connected.
+func (p *publisher) connect(ctx context.Context) (bool, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, natsPingInterval)
+	conn, err := dialNATS(dialCtx, p.url, "liquidatoor "+p.instance)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	defer conn.close()
+	if err := conn.subscribe(p.ackSubject, p.ack); err != nil {
+		return true, err
+	}
+	p.lock.Lock()
+	// Every event is published again over a new connection
+	for _, message := range p.messages {
+		message.sentAt = time.Time{}
+	}
+	buffered := len(p.messages)
+	p.lock.Unlock()
+	p.logger.Info(fmt.Sprintf("Publishing to %s, %d events buffered", p.subject, buffered), F("subject", p.subject), F("buffered", buffered))
+
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	failed := make(chan error, 1)
+	go func() {
+		failed <- conn.run(ctx)
+	}()
+	tick := p.redelivery / 4
+	if tick == 0 {
+		tick = p.redelivery
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		if err := p.flush(conn); err != nil {
+			return true, err
+		}
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case err := <-failed:
+			return true, err
+		case <-p.queued:
+		case <-ticker.C:
+		}
+	}
+}
+
+// flush publishes the events not published yet, and the ones not
+// acknowledged within the redelivery configured.
+func (p *publisher) flush(conn *natsConn) error {
+	now := time.Now()
+	p.lock.Lock()
+	due := make([]*publishedMessage, 0)
+	for _, message := range p.messages {
+		if message.sentAt.IsZero() || now.Sub(message.sentAt) >= p.redelivery {
+			due = append(due, message)
+		}
+	}
+	p.lock.Unlock()
+	for _, message := range due {
+		if err := conn.publish(p.subject, message.payload); err != nil {
+			return err
+		}
+		p.lock.Lock()
+		if message.published {
+			p.counts.Redelivered++
+		} else {
+			p.counts.Published++
+		}
+		message.published, message.sentAt = true, now
+		p.lock.Unlock()
+	}
+	return nil
+}
+
+func (p *publisher) stats() PublisherStats {
+	if p == nil {
+		return PublisherStats{}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	stats := p.counts
+	stats.Buffered = len(p.messages)
+	return stats
+}
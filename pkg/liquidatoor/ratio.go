@@ -0,0 +1,170 @@
+package liquidatoor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// Decimals of formatted ratios
+const ratioDecimals = 4
+
+// Ratio is a ratio of integers, eg., a health factor or a deviation. A
+// zero denominator makes it infinite, as the health factor of an
+// account without borrows, or undefined if the numerator is zero too,
+// as the health factor of an account without collateral or borrows.
+// Nil integers are zero.
+type Ratio struct {
+	Num   *big.Int
+	Denom *big.Int
+}
+
+func NewRatio(num, denom *big.Int) Ratio {
+	return Ratio{Num: num, Denom: denom}
+}
+
+// mantissaRatio returns the ratio of a mantissa scaled by 1e18.
+func mantissaRatio(mantissa *big.Int) Ratio {
+	return Ratio{Num: mantissa, Denom: expScale}
+}
+
+// ParseRatio parses a decimal ratio, eg., 1.10, with up to 18 decimals,
+// or inf.
+func ParseRatio(s string) (Ratio, error) {
+	switch s {
+	case "inf":
+		return Ratio{Num: big.NewInt(1), Denom: new(big.Int)}, nil
+	case "-inf":
+		return Ratio{Num: big.NewInt(-1), Denom: new(big.Int)}, nil
+	}
+	amount, err := ParseAmount(s, 18)
+	if err != nil {
+		return Ratio{}, fmt.Errorf("invalid ratio %q", s)
+	}
+	return mantissaRatio(amount.Value), nil
+}
+
+func (r Ratio) Infinite() bool {
+	return orZero(r.Denom).Sign() == 0 && orZero(r.Num).Sign() != 0
+}
+
+func (r Ratio) Undefined() bool {
+	return orZero(r.Denom).Sign() == 0 && orZero(r.Num).Sign() == 0
+}
+
+// sign returns the sign of the ratio and its absolute numerator and
+// denominator.
+func (r Ratio) sign() (int, *big.Int, *big.Int) {
+	num, denom := orZero(r.Num), orZero(r.Denom)
+	sign := num.Sign()
+	if denom.Sign() == -1 {
+		sign = -sign
+	}
+	return sign, new(big.Int).Abs(num), new(big.Int).Abs(denom)
+}
+
+// cmp compares defined ratios. Infinite ratios are larger than finite
+// ones, or smaller if negative.
+func (r Ratio) cmp(o Ratio) int {
+	rSign, rNum, rDenom := r.sign()
+	oSign, oNum, oDenom := o.sign()
+	if rSign != oSign {
+		if rSign < oSign {
+			return -1
+		}
+		return 1
+	}
+	var c int
+	switch {
+	case r.Infinite() && o.Infinite():
+		return 0
+	case r.Infinite():
+		c = 1
+	case o.Infinite():
+		c = -1
+	default:
+		c = new(big.Int).Mul(rNum, oDenom).Cmp(new(big.Int).Mul(oNum, rDenom))
+	}
+	return c * rSign
+}
+
+// Exceeds reports whether the ratio is over threshold. Undefined ratios
+// exceed nothing.
+func (r Ratio) Exceeds(threshold Ratio) bool {
+	return !r.Undefined() && !threshold.Undefined() && r.cmp(threshold) == 1
+}
+
+// Below reports whether the ratio is under threshold. Undefined ratios
+// are below nothing.
+func (r Ratio) Below(threshold Ratio) bool {
+	return !r.Undefined() && !threshold.Undefined() && r.cmp(threshold) == -1
+}
+
+// Mantissa returns the ratio scaled by 1e18, truncated, or nil if it is
+// infinite or undefined.
+func (r Ratio) Mantissa() *big.Int {
+	return r.scaled(18)
+}
+
+func (r Ratio) scaled(decimals uint8) *big.Int {
+	if orZero(r.Denom).Sign() == 0 {
+		return nil
+	}
+	sign, num, denom := r.sign()
+	scaled := num.Mul(num, pow10(decimals))
+	scaled.Quo(scaled, denom)
+	if sign == -1 {
+		scaled.Neg(scaled)
+	}
+	return scaled
+}
+
+// String formats the ratio with up to 4 decimals, truncated, eg., 1.043,
+// or as inf or undefined.
+func (r Ratio) String() string {
+	return r.format(ratioDecimals, "")
+}
+
+// Percent formats the ratio as a percentage with up to 2 decimals, eg.,
+// 5.23%.
+func (r Ratio) Percent() string {
+	return r.format(2, "%")
+}
+
+func (r Ratio) format(decimals uint8, unit string) string {
+	switch {
+	case r.Undefined():
+		return "undefined"
+	case r.Infinite() && r.Num.Sign() == -1:
+		return "-inf"
+	case r.Infinite():
+		return "inf"
+	}
+	// Percentages of 2 decimals are ratios of 4
+	return NewAmount(r.scaled(ratioDecimals), decimals).String() + unit
+}
+
+// MarshalJSON encodes the ratio as its decimal string.
+func (r Ratio) MarshalJSON() ([]byte, error) {
+	if r.Undefined() || r.Infinite() {
+		return json.Marshal(r.String())
+	}
+	return json.Marshal(NewAmount(r.Mantissa(), 18).String())
+}
+
+func (r *Ratio) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "undefined" {
+		*r = Ratio{Num: new(big.Int), Denom: new(big.Int)}
+		return nil
+	}
+	parsed, err := ParseRatio(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
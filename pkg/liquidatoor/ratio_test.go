@@ -0,0 +1,55 @@
+package liquidatoor
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestRatioJSONRoundTrip(t *testing.T) {
+	for _, num := range largeInts {
+		// Ratios of 18 decimals round trip exactly
+		r := mantissaRatio(num)
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("expected ratio %v encoded as a string, got %s", num, data)
+		}
+		var decoded Ratio
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Mantissa().Cmp(num) != 0 {
+			t.Fatalf("expected mantissa %v, got %v", num, decoded.Mantissa())
+		}
+	}
+}
+
+func TestRatioJSONSpecialValues(t *testing.T) {
+	for _, tc := range []struct {
+		ratio    Ratio
+		expected string
+	}{
+		{NewRatio(big.NewInt(1), new(big.Int)), `"inf"`},
+		{NewRatio(big.NewInt(-1), new(big.Int)), `"-inf"`},
+		{NewRatio(new(big.Int), new(big.Int)), `"undefined"`},
+	} {
+		data, err := json.Marshal(tc.ratio)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.expected {
+			t.Fatalf("expected %s, got %s", tc.expected, data)
+		}
+		var decoded Ratio
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Infinite() != tc.ratio.Infinite() || decoded.Undefined() != tc.ratio.Undefined() || decoded.String() != tc.ratio.String() {
+			t.Fatalf("expected %v, got %v", tc.ratio, decoded)
+		}
+	}
+}
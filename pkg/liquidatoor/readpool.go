@@ -0,0 +1,108 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ReadPool is a set of HTTP connections that read batches are spread
+// over round-robin, so that chunks executed concurrently do not queue
+// behind each other on a single connection. Subscriptions and
+// transactions stay on the main connection.
+type ReadPool struct {
+	clients []*readClient
+	next    uint32
+}
+
+// ReadClientStats are the requests sent over a pooled connection.
+type ReadClientStats struct {
+	Requests uint64
+	Failures uint64
+}
+
+type readClient struct {
+	rpc *rpc.Client
+	eth *ethclient.Client
+
+	requests uint64
+	failures uint64
+}
+
+func dialReadPool(ctx context.Context, rawURL string, size int) (*ReadPool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid READ_NODE_API_URL: %v", ErrInvalidConfig, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: READ_NODE_API_URL needs to be an HTTP URL, got %q", ErrInvalidConfig, u.Scheme)
+	}
+
+	p := &ReadPool{}
+	for i := 0; i < size; i++ {
+		rpcClient, err := rpc.DialContext(ctx, rawURL)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("cannot connect to read node: %w", err)
+		}
+		p.clients = append(p.clients, &readClient{rpc: rpcClient, eth: ethclient.NewClient(rpcClient)})
+	}
+	return p, nil
+}
+
+func (p *ReadPool) Size() int {
+	return len(p.clients)
+}
+
+// pick returns the next connection.
+func (p *ReadPool) pick() *readClient {
+	return p.clients[atomic.AddUint32(&p.next, 1)%uint32(len(p.clients))]
+}
+
+// Stats returns the stats of every connection, in dial order.
+func (p *ReadPool) Stats() []ReadClientStats {
+	stats := make([]ReadClientStats, len(p.clients))
+	for i, client := range p.clients {
+		stats[i] = ReadClientStats{
+			Requests: atomic.LoadUint64(&client.requests),
+			Failures: atomic.LoadUint64(&client.failures),
+		}
+	}
+	return stats
+}
+
+// Close closes every connection once in-flight requests complete.
+func (p *ReadPool) Close() {
+	for _, client := range p.clients {
+		client.rpc.Close()
+	}
+}
+
+func (c *readClient) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return c.eth.CodeAt(ctx, contract, blockNumber)
+}
+
+func (c *readClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	atomic.AddUint64(&c.requests, 1)
+	out, err := c.eth.CallContract(ctx, call, blockNumber)
+	if err != nil {
+		atomic.AddUint64(&c.failures, 1)
+	}
+	return out, err
+}
+
+func (c *readClient) BatchCallContext(ctx context.Context, elems []rpc.BatchElem) error {
+	atomic.AddUint64(&c.requests, 1)
+	err := c.rpc.BatchCallContext(ctx, elems)
+	if err != nil {
+		atomic.AddUint64(&c.failures, 1)
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package liquidatoor
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// readiness tracks whether every pool monitored completed a check: its
+// borrowers cached, its markets read and their prices fetched. Until
+// then blocks are skipped. Once ready the instance stays ready, so
+// pools found later do not flap it.
+type readiness struct {
+	logger Logger
+
+	lock sync.Mutex
+	// Whether every pool completed a check
+	pools map[common.Address]bool
+	ready bool
+	// Last block a pool completed a check of until ready
+	block   *big.Int
+	skipped uint64
+}
+
+func newReadiness(logger Logger) *readiness {
+	return &readiness{logger: logger, pools: make(map[common.Address]bool)}
+}
+
+// add waits for pool to complete a check before being ready.
+func (r *readiness) add(pool common.Address) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.pools[pool]; !ok {
+		r.pools[pool] = false
+	}
+}
+
+// remove stops waiting for pool.
+func (r *readiness) remove(pool common.Address) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.pools, pool)
+	r.update()
+}
+
+// primed records that pool completed a check of block.
+func (r *readiness) primed(pool common.Address, block *big.Int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.pools[pool] {
+		return
+	}
+	r.pools[pool] = true
+	if r.ready {
+		r.logger.Info(fmt.Sprintf("Pool %s ready at block %v", pool, block), F("pool", pool), F("block", block))
+		return
+	}
+	r.block = block
+	r.update()
+}
+
+// skip records that pool skipped a block before being ready.
+func (r *readiness) skip(pool common.Address) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.pools[pool] {
+		r.skipped++
+	}
+}
+
+// update becomes ready once every pool is. Callers hold the lock.
+func (r *readiness) update() {
+	if r.ready || len(r.pools) == 0 {
+		return
+	}
+	for _, primed := range r.pools {
+		if !primed {
+			return
+		}
+	}
+	r.ready = true
+	r.logger.Info(fmt.Sprintf("liquidatoor ready at block %v, %d blocks skipped until then", r.block, r.skipped), F("block", r.block), F("skipped", r.skipped))
+}
+
+func (r *readiness) status() (bool, *big.Int, uint64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.ready, r.block, r.skipped
+}
+
+// handle answers readiness probes: 200 once ready and 503 until then.
+func (r *readiness) handle(w http.ResponseWriter, _ *http.Request) {
+	ready, block, skipped := r.status()
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "not ready, %d blocks skipped\n", skipped)
+		return
+	}
+	fmt.Fprintf(w, "ready at block %v\n", block)
+}
@@ -0,0 +1,155 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// watchPriceUpdates rechecks the accounts of the markets of the
+// configured price update events as they are emitted, until ctx is
+// cancelled. Events received during a recheck are coalesced into the
+// next one.
+func (l *Liquidatoor) watchPriceUpdates(ctx context.Context) {
+	events := make([]PriceUpdateEvent, 0, len(l.priceUpdateEvents))
+	query := ethereum.FilterQuery{Topics: [][]common.Hash{{}}}
+	for _, event := range l.priceUpdateEvents {
+		if _, ok := l.LendMarkets[event.Market.String()]; ok || event.Market == (common.Address{}) {
+			events = append(events, event)
+			query.Addresses = append(query.Addresses, event.Emitter)
+			query.Topics[0] = append(query.Topics[0], event.Topic)
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	var lock sync.Mutex
+	// Nil is every market
+	markets := make(map[common.Address]bool)
+	var source string
+	updated := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-updated:
+			}
+			lock.Lock()
+			rechecked, from := markets, source
+			markets = make(map[common.Address]bool)
+			lock.Unlock()
+			if err := l.recheck(ctx, rechecked, from); err != nil {
+				l.logger.Error(fmt.Sprintf("Failed recheck: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+			}
+		}
+	}()
+
+	l.bus.subscribe(ctx, "price update events", query, func(log types.Log) {
+		// Prices are read again on the next block anyway
+		if log.Removed {
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		for _, event := range events {
+			if log.Address != event.Emitter || log.Topics[0] != event.Topic {
+				continue
+			}
+			source = fmt.Sprintf("price update in tx %s", log.TxHash)
+			if event.Market == (common.Address{}) {
+				markets = nil
+			} else if markets != nil {
+				markets[event.Market] = true
+			}
+		}
+		select {
+		case updated <- struct{}{}:
+		default:
+		}
+	}, nil)
+}
+
+// recheck checks the borrowers of markets again with the current
+// prices, against the rest of the start of the last block, and queues
+// the liquidatable ones. A nil markets checks every borrower. It holds
+// the lock of block checks so it never overlaps one, and leaves the
+// watchlist to the next of them.
+func (l *Liquidatoor) recheck(ctx context.Context, markets map[common.Address]bool, source string) error {
+	l.checkLock.Lock()
+	defer l.checkLock.Unlock()
+
+	last := l.lastStart
+	if last == nil {
+		return nil
+	}
+	borrowers := make([]Borrower, 0)
+	for _, borrower := range l.borrowerCache.Read() {
+		for _, asset := range borrower.Assets {
+			if markets == nil || markets[asset] {
+				borrowers = append(borrowers, borrower)
+				break
+			}
+		}
+	}
+	if len(borrowers) == 0 {
+		return nil
+	}
+
+	prices, err := pricesOf(ctx, l.logger, l.priceSource, l.assets(last.markets), nil)
+	if err != nil {
+		return fmt.Errorf("cannot get prices: %w", err)
+	}
+	block := last.snapshot.Block
+	start := &blockStart{
+		borrowers:     borrowers,
+		markets:       last.markets,
+		prices:        prices,
+		averagePrices: last.averagePrices,
+		snapshot:      l.snapshot(block, last.markets, prices, last),
+		inventory:     last.inventory,
+	}
+
+	calls := make([]abis.MulticallCall, 0, len(borrowers))
+	for _, borrower := range borrowers {
+		calls = append(calls, abis.MulticallCall{Target: l.comptrollerAddress, CallData: borrower.liquidityCallData})
+	}
+	resp, err := l.Batcher.Aggregate(&bind.CallOpts{Context: ctx}, calls)
+	if err != nil {
+		return fmt.Errorf("failed batch request: %v", err)
+	}
+	underwaterAccounts := make([]Borrower, 0)
+	for i, result := range resp {
+		liquidity := accountLiquidity(result.ReturnData)
+		if !result.Success || liquidity.validate() != nil || liquidity.failed() || !liquidity.underwater() {
+			continue
+		}
+		underwaterAccounts = append(underwaterAccounts, Borrower{
+			Address:   borrowers[i].Address,
+			Assets:    borrowers[i].Assets,
+			Shortfall: liquidity.shortfall(),
+		})
+	}
+	sort.SliceStable(underwaterAccounts, func(i, j int) bool {
+		return GT(underwaterAccounts[i].Shortfall, underwaterAccounts[j].Shortfall)
+	})
+
+	f, err := l.evaluate(ctx, block, start, underwaterAccounts, ExecuteQueued)
+	if err != nil {
+		return err
+	}
+	l.logger.Info(fmt.Sprintf("Recheck on %s: %d exposed borrowers, %d underwater, %d planned, %d profitable, %d liquidatable; dropped %s",
+		source, len(borrowers), len(underwaterAccounts), f.planned, f.profitable, f.liquidatable, formatDropped(f.dropped)),
+		F("pool", l.comptrollerAddress), F("block", block), F("source", source), F("borrowers", len(borrowers)), F("underwater", len(underwaterAccounts)),
+		F("planned", f.planned), F("profitable", f.profitable), F("liquidatable", f.liquidatable), F("dropped", f.dropped))
+	return nil
+}
@@ -0,0 +1,143 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Adjustments of a plan whose seized collateral cannot be redeemed
+const (
+	// Seize another collateral market of the account with the cash
+	RedemptionSwitchCollateral = "switch-collateral"
+	// Repay from inventory and hold the seized cTokens
+	RedemptionHold = "hold"
+	// Repay less, seizing up to the cash of the collateral market
+	RedemptionDownsize = "downsize"
+)
+
+// Redemption is how the plan of a candidate was adjusted because the
+// cash of its collateral market cannot redeem the collateral seized,
+// as realizing the profit of a flash loan funded liquidation needs.
+type Redemption struct {
+	// Collateral market of the original plan, its cash and the
+	// underlying the plan would seize from it
+	Market     common.Address `json:"market"`
+	Cash       *big.Int       `json:"cash"`
+	Redeemable *big.Int       `json:"redeemable"`
+	// One of RedemptionSwitchCollateral, RedemptionHold or
+	// RedemptionDownsize
+	Adjustment string `json:"adjustment"`
+}
+
+func (r Redemption) String() string {
+	return fmt.Sprintf("%s: market %s has %v cash to redeem %v", r.Adjustment, r.Market, r.Cash, r.Redeemable)
+}
+
+// MarshalJSON encodes the amounts as decimal strings.
+func (r Redemption) MarshalJSON() ([]byte, error) {
+	type redemption Redemption
+	return json.Marshal(struct {
+		redemption
+		Cash       *decimalInt `json:"cash"`
+		Redeemable *decimalInt `json:"redeemable"`
+	}{redemption(r), decimal(r.Cash), decimal(r.Redeemable)})
+}
+
+func (r *Redemption) UnmarshalJSON(data []byte) error {
+	type redemption Redemption
+	v := struct {
+		*redemption
+		Cash       *decimalInt `json:"cash"`
+		Redeemable *decimalInt `json:"redeemable"`
+	}{redemption: (*redemption)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.Cash, r.Redeemable = v.Cash.int(), v.Redeemable.int()
+	return nil
+}
+
+// checkRedemption adjusts the plan of a candidate funded by a flash
+// loan whose collateral market lacks the cash to redeem the seize. It
+// prefers, in order, seizing another collateral of the account with the
+// cash for the full repay, holding the seized cTokens if the inventory
+// covers the repay, and the larger of the repays that seize within the
+// cash of the original or another collateral market. Markets whose
+// cash is unknown are assumed liquid. Adjusted plans are estimated
+// again.
+func (l *Liquidatoor) checkRedemption(ctx context.Context, s *Snapshot, inventory Inventory, account AccountPositions, c *Candidate) error {
+	collateral, borrow := s.Markets[c.Plan.CollateralMarket], s.Markets[c.Plan.BorrowMarket]
+	if l.flashLiquidity == nil || collateral.Cash == nil || !IsPositive(collateral.Price) {
+		return nil
+	}
+	redeemable := collateral.Amount(c.Plan.SeizeValue)
+	if GTE(collateral.Cash, redeemable) {
+		return nil
+	}
+	r := &Redemption{Market: collateral.Address, Cash: collateral.Cash, Redeemable: redeemable}
+
+	var borrowed *positionValue
+	var switched *LiquidationPlan
+	for _, position := range account.Positions {
+		if position.Market == borrow.Address {
+			borrowed = &positionValue{market: borrow, amount: position.Borrowed, value: borrow.Value(orZero(position.Borrowed))}
+		}
+	}
+	if borrowed == nil || !IsPositive(borrowed.value) {
+		return ErrStaleData
+	}
+	for _, position := range account.Positions {
+		market := s.Markets[position.Market]
+		if !IsPositive(position.Supplied) || market.SeizePaused || !IsPositive(market.Price) {
+			continue
+		}
+		// Seizing up to the cash of the market, known or not
+		supplied := &positionValue{market: market, amount: position.Supplied, value: market.Value(position.Supplied)}
+		if market.Cash != nil {
+			if cash := market.Value(market.Cash); GT(supplied.value, cash) {
+				supplied.value = cash
+			}
+		}
+		if IsZero(supplied.value) {
+			continue
+		}
+		plan := planLiquidation(s, account.Account, borrowed, supplied)
+		if IsPositive(plan.RepayAmount) && (switched == nil || GT(plan.RepayAmount, switched.RepayAmount)) {
+			switched = &plan
+		}
+	}
+
+	plan := c.Plan
+	switch {
+	case switched != nil && switched.CollateralMarket != collateral.Address && GTE(switched.RepayAmount, c.Plan.RepayAmount):
+		r.Adjustment, plan = RedemptionSwitchCollateral, switched
+	case GTE(inventory[borrow.Underlying], c.Plan.RepayAmount):
+		r.Adjustment = RedemptionHold
+	case switched != nil && switched.CollateralMarket != collateral.Address:
+		r.Adjustment, plan = RedemptionSwitchCollateral, switched
+	case switched != nil:
+		r.Adjustment, plan = RedemptionDownsize, switched
+	default:
+		return fmt.Errorf("%w: market %s has %v cash to redeem %v", ErrIlliquidCollateral, collateral.Address, collateral.Cash, redeemable)
+	}
+	c.Redemption = r
+	l.logger.Info(fmt.Sprintf("Adjusted liquidation of account %s to %s", account.Account, r),
+		F("pool", l.comptrollerAddress), F("account", account.Account), F("market", collateral.Address), F("adjustment", r.Adjustment),
+		F("cash", r.Cash), F("redeemable", r.Redeemable), F("repay", plan.RepayAmount))
+	if plan == c.Plan {
+		return nil
+	}
+	estimate, err := l.profitEstimator.Estimate(ctx, *plan, s)
+	if err != nil {
+		return err
+	}
+	c.Plan, c.Estimate = plan, estimate
+	if !estimate.Profitable() {
+		return ErrUnprofitable
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// flashLender lends anything for free.
+type flashLender struct{}
+
+func (flashLender) Name() string { return "free" }
+
+func (flashLender) Quote(*bind.CallOpts, common.Address, *big.Int) (*FlashQuote, error) {
+	return &FlashQuote{Available: true, Fee: new(big.Int)}, nil
+}
+
+func (flashLender) LoanParams(common.Address, *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+// incentiveEstimator estimates the incentive as the net profit, or
+// net if set, and counts the plans estimated.
+type incentiveEstimator struct {
+	net       *big.Int
+	estimated int
+}
+
+func (*incentiveEstimator) Name() string { return "incentive" }
+
+func (e *incentiveEstimator) Estimate(_ context.Context, plan LiquidationPlan, _ *Snapshot) (*ProfitEstimate, error) {
+	e.estimated++
+	net := e.net
+	if net == nil {
+		net = new(big.Int).Sub(plan.SeizeValue, plan.RepayValue)
+	}
+	return &ProfitEstimate{Net: net}, nil
+}
+
+var (
+	redeemBorrowed   = common.HexToAddress("0xa")
+	redeemCollateral = common.HexToAddress("0xb")
+	redeemOther      = common.HexToAddress("0xc")
+	redeemUnderlying = common.HexToAddress("0xaa")
+)
+
+// redemptionInput is an account borrowing 10000 and supplying 8000 of
+// the collateral market with cash, and otherSupplied of another with
+// otherCash, every unit worth one. Its plan repays 5000, at the close
+// factor, seizing 5400 of the collateral.
+func redemptionInput(cash, otherSupplied, otherCash *big.Int) (*Snapshot, AccountPositions, *Candidate) {
+	market := func(address, underlying common.Address, cash *big.Int) MarketSnapshot {
+		return MarketSnapshot{Address: address, Underlying: underlying, Decimals: 18, Price: big.NewInt(1e18), Cash: cash}
+	}
+	s := &Snapshot{
+		CloseFactor:          big.NewInt(5e17),
+		LiquidationIncentive: big.NewInt(108e16),
+		Markets: map[common.Address]MarketSnapshot{
+			redeemBorrowed:   market(redeemBorrowed, redeemUnderlying, nil),
+			redeemCollateral: market(redeemCollateral, common.HexToAddress("0xbb"), cash),
+			redeemOther:      market(redeemOther, common.HexToAddress("0xcc"), otherCash),
+		},
+	}
+	account := AccountPositions{
+		Account: common.HexToAddress("0x1000"),
+		Positions: []Position{
+			{Market: redeemBorrowed, Borrowed: big.NewInt(10000)},
+			{Market: redeemCollateral, Supplied: big.NewInt(8000)},
+			{Market: redeemOther, Supplied: otherSupplied},
+		},
+	}
+	borrowed := &positionValue{market: s.Markets[redeemBorrowed], amount: big.NewInt(10000), value: big.NewInt(10000)}
+	supplied := &positionValue{market: s.Markets[redeemCollateral], amount: big.NewInt(8000), value: big.NewInt(8000)}
+	plan := planLiquidation(s, account.Account, borrowed, supplied)
+	return s, account, &Candidate{Account: account.Account, Plan: &plan, Estimate: &ProfitEstimate{Net: big.NewInt(400)}}
+}
+
+func TestCheckRedemption(t *testing.T) {
+	for _, tc := range []struct {
+		name                           string
+		cash, otherSupplied, otherCash *big.Int
+		inventory                      *big.Int
+		unprofitable                   bool
+		adjustment                     string
+		market                         common.Address
+		repay                          int64
+		err                            error
+	}{
+		{name: "enough cash", cash: big.NewInt(5400), market: redeemCollateral, repay: 5000},
+		{name: "unknown cash", market: redeemCollateral, repay: 5000},
+
+		// Zero cash
+		{name: "zero cash, another collateral covers the repay", cash: big.NewInt(0), otherSupplied: big.NewInt(6000), otherCash: big.NewInt(1e6),
+			adjustment: RedemptionSwitchCollateral, market: redeemOther, repay: 5000},
+		{name: "zero cash, another collateral of unknown cash", cash: big.NewInt(0), otherSupplied: big.NewInt(6000),
+			adjustment: RedemptionSwitchCollateral, market: redeemOther, repay: 5000},
+		{name: "zero cash, inventory covers the repay", cash: big.NewInt(0), otherSupplied: big.NewInt(1080), otherCash: big.NewInt(1e6), inventory: big.NewInt(5000),
+			adjustment: RedemptionHold, market: redeemCollateral, repay: 5000},
+		{name: "zero cash, inventory short of the repay", cash: big.NewInt(0), otherSupplied: big.NewInt(1080), otherCash: big.NewInt(1e6), inventory: big.NewInt(4999),
+			adjustment: RedemptionSwitchCollateral, market: redeemOther, repay: 1000},
+		{name: "zero cash, another collateral of partial cash", cash: big.NewInt(0), otherSupplied: big.NewInt(6000), otherCash: big.NewInt(540),
+			adjustment: RedemptionSwitchCollateral, market: redeemOther, repay: 500},
+		{name: "zero cash everywhere", cash: big.NewInt(0), otherSupplied: big.NewInt(6000), otherCash: big.NewInt(0),
+			market: redeemCollateral, repay: 5000, err: ErrIlliquidCollateral},
+		{name: "zero cash, no other collateral", cash: big.NewInt(0),
+			market: redeemCollateral, repay: 5000, err: ErrIlliquidCollateral},
+
+		// Partial cash
+		{name: "partial cash", cash: big.NewInt(2700),
+			adjustment: RedemptionDownsize, market: redeemCollateral, repay: 2500},
+		{name: "partial cash, one wei short", cash: big.NewInt(5399),
+			adjustment: RedemptionDownsize, market: redeemCollateral, repay: 4999},
+		{name: "partial cash, a smaller other collateral", cash: big.NewInt(2700), otherSupplied: big.NewInt(1080), otherCash: big.NewInt(1e6),
+			adjustment: RedemptionDownsize, market: redeemCollateral, repay: 2500},
+		{name: "partial cash, a larger other collateral", cash: big.NewInt(1080), otherSupplied: big.NewInt(2700), otherCash: big.NewInt(1e6),
+			adjustment: RedemptionSwitchCollateral, market: redeemOther, repay: 2500},
+		{name: "partial cash, inventory covers the repay", cash: big.NewInt(2700), inventory: big.NewInt(1e6),
+			adjustment: RedemptionHold, market: redeemCollateral, repay: 5000},
+		{name: "partial cash, downsized unprofitably", cash: big.NewInt(2700), unprofitable: true,
+			adjustment: RedemptionDownsize, market: redeemCollateral, repay: 2500, err: ErrUnprofitable},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, account, c := redemptionInput(tc.cash, tc.otherSupplied, tc.otherCash)
+			original := c.Plan
+			estimator := &incentiveEstimator{}
+			if tc.unprofitable {
+				estimator.net = big.NewInt(-1)
+			}
+			l := &Liquidatoor{logger: quietLogger(), flashLiquidity: flashLender{}, profitEstimator: estimator}
+			inventory := Inventory{redeemUnderlying: tc.inventory}
+
+			err := l.checkRedemption(context.Background(), s, inventory, account, c)
+			if !errors.Is(err, tc.err) || (err != nil && tc.err == nil) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+			if c.Plan.CollateralMarket != tc.market || c.Plan.RepayAmount.Cmp(big.NewInt(tc.repay)) != 0 {
+				t.Fatalf("expected a repay of %d seizing %s, got %v seizing %s", tc.repay, tc.market, c.Plan.RepayAmount, c.Plan.CollateralMarket)
+			}
+
+			if tc.adjustment == "" {
+				if c.Redemption != nil {
+					t.Fatalf("expected no adjustment, got %s", c.Redemption)
+				}
+				return
+			}
+			if c.Redemption == nil || c.Redemption.Adjustment != tc.adjustment {
+				t.Fatalf("expected the %s adjustment, got %v", tc.adjustment, c.Redemption)
+			}
+			if c.Redemption.Market != redeemCollateral || c.Redemption.Cash.Cmp(tc.cash) != 0 || c.Redemption.Redeemable.Cmp(big.NewInt(5400)) != 0 {
+				t.Fatalf("expected the cash and the seize of the original plan, got %s", c.Redemption)
+			}
+			// Adjusted plans are estimated again and seize within the
+			// cash of their market
+			if changed := c.Plan != original; changed != (estimator.estimated == 1) {
+				t.Fatalf("expected plans estimated again only when changed, estimated %d", estimator.estimated)
+			}
+			if tc.adjustment == RedemptionHold {
+				return
+			}
+			if cash := s.Markets[c.Plan.CollateralMarket].Cash; cash != nil && GT(c.Plan.SeizeValue, cash) {
+				t.Fatalf("expected at most the cash of %v seized, got %v", cash, c.Plan.SeizeValue)
+			}
+			if c.Estimate.Profitable() == tc.unprofitable {
+				t.Fatalf("expected the estimate of the adjusted plan, got %+v", c.Estimate)
+			}
+		})
+	}
+}
+
+func TestCheckRedemptionWithoutFlashLoans(t *testing.T) {
+	// Inventory funded liquidations hold the seized cTokens anyway
+	s, account, c := redemptionInput(big.NewInt(0), nil, nil)
+	original := *c.Plan
+	l := &Liquidatoor{logger: quietLogger(), profitEstimator: &incentiveEstimator{}}
+	if err := l.checkRedemption(context.Background(), s, Inventory{}, account, c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Redemption != nil || c.Plan.CollateralMarket != original.CollateralMarket || c.Plan.RepayAmount.Cmp(original.RepayAmount) != 0 {
+		t.Fatalf("expected the plan unadjusted, got %s", c.Redemption)
+	}
+}
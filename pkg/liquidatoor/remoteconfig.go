@@ -0,0 +1,366 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/store"
+)
+
+const (
+	defaultRemoteConfigInterval = 30 * time.Second
+	remoteConfigTimeout         = 10 * time.Second
+	// Bytes of the largest document fetched
+	remoteConfigMaxSize = 1 << 20
+	remoteConfigVersion = 1
+)
+
+// RemoteDocument is the subset of settings a remote config can change
+// at runtime. Documents carrying any other setting are rejected.
+type RemoteDocument struct {
+	// Increases with every change; older documents are rejected
+	Version uint64 `json:"version"`
+	// Accounts never liquidated, as annotations of handling never
+	Deny []RemoteDenial `json:"deny,omitempty"`
+	// Price deviation limits by underlying, overriding
+	// PRICE_DEVIATION_LIMITS, scaled by 1e18 as decimal strings
+	PriceDeviationLimits map[common.Address]*decimalInt `json:"priceDeviationLimits,omitempty"`
+}
+
+// RemoteDenial is an account of the deny-list of a remote config.
+type RemoteDenial struct {
+	Address common.Address `json:"address"`
+	Label   string         `json:"label,omitempty"`
+}
+
+// remoteEnvelope is a document as served, signed by the signer of the
+// remote config: the signature is an Ethereum signed message of the
+// document, which is a string so its bytes survive re-encoding.
+type remoteEnvelope struct {
+	Document  string        `json:"document"`
+	Signature hexutil.Bytes `json:"signature"`
+}
+
+// RemoteConfig polls a URL for a signed RemoteDocument and applies it:
+// its deny-list through the annotations, as if from their file, and
+// its price deviation limits to the price guards. Documents that are
+// not signed by the signer, are invalid or older than the applied one
+// are rejected. The last good document persists to a file, if set, and
+// is applied while the URL cannot be fetched, including at startup. A
+// nil RemoteConfig applies nothing.
+type RemoteConfig struct {
+	logger      Logger
+	url         string
+	signer      common.Address
+	interval    time.Duration
+	path        string
+	client      *http.Client
+	annotations *Annotations
+
+	lock     sync.RWMutex
+	document *RemoteDocument
+	// Why the last poll failed, if it did
+	failure string
+}
+
+func newRemoteConfig(logger Logger, url string, signer common.Address, interval time.Duration, path string, annotations *Annotations) (*RemoteConfig, error) {
+	if signer == (common.Address{}) {
+		return nil, fmt.Errorf("%w: REMOTE_CONFIG_URL needs REMOTE_CONFIG_SIGNER", ErrInvalidConfig)
+	}
+	if interval == 0 {
+		interval = defaultRemoteConfigInterval
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("%w: REMOTE_CONFIG_INTERVAL needs to be positive, got %v", ErrInvalidConfig, interval)
+	}
+	return &RemoteConfig{
+		logger:      logger,
+		url:         url,
+		signer:      signer,
+		interval:    interval,
+		path:        path,
+		client:      &http.Client{Timeout: remoteConfigTimeout},
+		annotations: annotations,
+	}, nil
+}
+
+// load applies the last good document, if persisted, then fetches the
+// current one. Failing to fetch it is logged, as the last good
+// document, or the local settings, apply meanwhile.
+func (r *RemoteConfig) load(ctx context.Context) error {
+	if r.path != "" {
+		var envelope remoteEnvelope
+		ok, err := loadState(r.logger, "remote config", r.path, remoteConfigVersion, &envelope)
+		if err != nil {
+			return err
+		}
+		if ok {
+			document, err := r.verify(envelope)
+			if err != nil {
+				r.logger.Error(fmt.Sprintf("Ignoring the persisted remote config: %v", err), F("path", r.path), F("err", err))
+			} else {
+				r.logger.Info(fmt.Sprintf("Applying the last good remote config, version %d", document.Version), F("version", document.Version))
+				r.apply(document)
+			}
+		}
+	}
+	r.poll(ctx)
+	return nil
+}
+
+// run polls the remote config every interval until ctx is cancelled.
+func (r *RemoteConfig) run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.poll(ctx)
+	}
+}
+
+// poll fetches the document and applies it if it is newer than the
+// applied one. Repeated failures are logged once.
+func (r *RemoteConfig) poll(ctx context.Context) {
+	envelope, err := r.fetch(ctx)
+	var document *RemoteDocument
+	if err == nil {
+		document, err = r.verify(envelope)
+	}
+	if err == nil {
+		err = r.check(document)
+	}
+
+	failure := ""
+	if err != nil {
+		failure = err.Error()
+	}
+	r.lock.Lock()
+	previous := r.failure
+	r.failure = failure
+	current := r.document
+	r.lock.Unlock()
+	version := uint64(0)
+	if current != nil {
+		version = current.Version
+	}
+	if err != nil {
+		if failure != previous {
+			r.logger.Error(fmt.Sprintf("Failed to update the remote config; keeping version %d: %v", version, err), F("url", r.url), F("version", version), F("err", err))
+		}
+		return
+	}
+	if previous != "" {
+		r.logger.Info(fmt.Sprintf("Fetched the remote config again, version %d", document.Version), F("url", r.url), F("version", document.Version))
+	}
+	if document.Version == version {
+		return
+	}
+	r.apply(document)
+	if r.path == "" {
+		return
+	}
+	if err := store.Write(r.path, remoteConfigVersion, envelope); err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to persist remote config version %d: %v", document.Version, err), F("path", r.path), F("version", document.Version), F("err", err))
+	}
+}
+
+// fetch fetches the signed document.
+func (r *RemoteConfig) fetch(ctx context.Context) (remoteEnvelope, error) {
+	var envelope remoteEnvelope
+	ctx, cancel := context.WithTimeout(ctx, remoteConfigTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return envelope, fmt.Errorf("cannot request remote config: %w", err)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return envelope, fmt.Errorf("cannot fetch remote config: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return envelope, fmt.Errorf("cannot fetch remote config: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remoteConfigMaxSize+1))
+	if err != nil {
+		return envelope, fmt.Errorf("cannot fetch remote config: %w", err)
+	}
+	if len(data) > remoteConfigMaxSize {
+		return envelope, fmt.Errorf("remote config is larger than %d bytes", remoteConfigMaxSize)
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return envelope, fmt.Errorf("cannot decode remote config: %w", err)
+	}
+	return envelope, nil
+}
+
+// verify checks the signature of the document of envelope and decodes
+// it, rejecting unknown settings and invalid values.
+func (r *RemoteConfig) verify(envelope remoteEnvelope) (*RemoteDocument, error) {
+	if len(envelope.Signature) != crypto.SignatureLength {
+		return nil, errors.New("remote config is not signed")
+	}
+	signature := append([]byte(nil), envelope.Signature...)
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	key, err := crypto.SigToPub(accounts.TextHash([]byte(envelope.Document)), signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature of remote config: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*key); signer != r.signer {
+		return nil, fmt.Errorf("remote config is signed by %s, not by %s", signer, r.signer)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(envelope.Document)))
+	decoder.DisallowUnknownFields()
+	var document RemoteDocument
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid remote config: %w", err)
+	}
+	if document.Version == 0 {
+		return nil, errors.New("invalid remote config: version needs to be positive")
+	}
+	for _, denial := range document.Deny {
+		if denial.Address == (common.Address{}) {
+			return nil, fmt.Errorf("invalid remote config version %d: deny-list entry without address", document.Version)
+		}
+	}
+	for underlying, limit := range document.PriceDeviationLimits {
+		if limit == nil || IsNegative(limit.int()) {
+			return nil, fmt.Errorf("invalid remote config version %d: invalid price deviation limit of %s", document.Version, underlying)
+		}
+	}
+	return &document, nil
+}
+
+// check rejects documents older than the applied one, which a stale
+// cache or a replay would serve.
+func (r *RemoteConfig) check(document *RemoteDocument) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.document != nil && document.Version < r.document.Version {
+		return fmt.Errorf("remote config version %d is older than the applied version %d", document.Version, r.document.Version)
+	}
+	return nil
+}
+
+// apply applies document, logging every change it makes.
+func (r *RemoteConfig) apply(document *RemoteDocument) {
+	r.lock.Lock()
+	previous := r.document
+	r.document = document
+	r.lock.Unlock()
+	if previous == nil {
+		previous = &RemoteDocument{}
+	}
+	fields := func(fields ...Field) []Field {
+		return append(fields, F("version", document.Version))
+	}
+
+	denied := make(map[common.Address]bool, len(previous.Deny))
+	for _, denial := range previous.Deny {
+		denied[denial.Address] = true
+	}
+	annotations := make([]Annotation, 0, len(document.Deny))
+	for _, denial := range document.Deny {
+		label := denial.Label
+		if label == "" {
+			label = "remote deny-list"
+		}
+		annotations = append(annotations, Annotation{Address: denial.Address, Label: label, Handling: HandlingNever})
+		if denied[denial.Address] {
+			delete(denied, denial.Address)
+			continue
+		}
+		r.logger.Info(fmt.Sprintf("Remote config version %d denies account %s", document.Version, denial.Address), fields(F("account", denial.Address))...)
+	}
+	for _, account := range sortedAddresses(denied) {
+		r.logger.Info(fmt.Sprintf("Remote config version %d no longer denies account %s", document.Version, account), fields(F("account", account))...)
+	}
+	r.annotations.applyRemote(annotations)
+
+	underlyings := make(map[common.Address]bool)
+	for underlying := range previous.PriceDeviationLimits {
+		underlyings[underlying] = true
+	}
+	for underlying := range document.PriceDeviationLimits {
+		underlyings[underlying] = true
+	}
+	for _, underlying := range sortedAddresses(underlyings) {
+		before, after := previous.PriceDeviationLimits[underlying], document.PriceDeviationLimits[underlying]
+		switch {
+		case after == nil:
+			r.logger.Info(fmt.Sprintf("Remote config version %d removes the price deviation limit of %s", document.Version, underlying), fields(F("underlying", underlying))...)
+		case before == nil || !Equal(before.int(), after.int()):
+			r.logger.Info(fmt.Sprintf("Remote config version %d limits the price deviation of %s to %s", document.Version, underlying, mantissaRatio(after.int()).Percent()),
+				fields(F("underlying", underlying), F("limit", after.int()))...)
+		}
+	}
+	r.logger.Info(fmt.Sprintf("Applied remote config version %d: %d denied accounts, %d price deviation limits", document.Version, len(document.Deny), len(document.PriceDeviationLimits)),
+		fields(F("denied", len(document.Deny)), F("limits", len(document.PriceDeviationLimits)))...)
+}
+
+// Version returns the version of the applied document, or zero.
+func (r *RemoteConfig) Version() uint64 {
+	if r == nil {
+		return 0
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.document == nil {
+		return 0
+	}
+	return r.document.Version
+}
+
+// deviationLimit returns the price deviation limit of underlying set
+// by the applied document, if any.
+func (r *RemoteConfig) deviationLimit(underlying common.Address) (*big.Int, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.document == nil {
+		return nil, false
+	}
+	limit, ok := r.document.PriceDeviationLimits[underlying]
+	if !ok {
+		return nil, false
+	}
+	return limit.int(), true
+}
+
+// sortedAddresses returns the addresses of set, in order.
+func sortedAddresses(set map[common.Address]bool) []common.Address {
+	list := make([]common.Address, 0, len(set))
+	for address := range set {
+		list = append(list, address)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i][:], list[j][:]) == -1
+	})
+	return list
+}
@@ -0,0 +1,368 @@
+package liquidatoor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/store"
+)
+
+// Interval between pruning passes by default
+const defaultRetentionInterval = time.Hour
+
+// JournalRollup sums the journal entries of a day of a pool, once its
+// journal is pruned.
+type JournalRollup struct {
+	// Midnight UTC
+	Day  time.Time      `json:"day"`
+	Pool common.Address `json:"pool"`
+	// Block entries, and their counts summed
+	Blocks       int            `json:"blocks"`
+	Underwater   int            `json:"underwater"`
+	Liquidatable int            `json:"liquidatable"`
+	Dropped      map[string]int `json:"dropped,omitempty"`
+	// Receipt entries
+	Executions int      `json:"executions"`
+	Failures   int      `json:"failures"`
+	PnL        *big.Int `json:"pnl"`
+}
+
+// MarshalJSON encodes the profit of the rollup as decimal strings.
+func (r JournalRollup) MarshalJSON() ([]byte, error) {
+	type rollup JournalRollup
+	return json.Marshal(struct {
+		rollup
+		PnL *decimalInt `json:"pnl"`
+	}{rollup(r), decimal(r.PnL)})
+}
+
+func (r *JournalRollup) UnmarshalJSON(data []byte) error {
+	type rollup JournalRollup
+	v := struct {
+		*rollup
+		PnL *decimalInt `json:"pnl"`
+	}{rollup: (*rollup)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.PnL = v.PnL.int()
+	return nil
+}
+
+// journalRollups are the rollups of pruned journals, and the names of
+// the journals rolled up, so a journal is not rolled up twice if
+// pruning stops before deleting it.
+type journalRollups struct {
+	Rollups  []JournalRollup `json:"rollups"`
+	Journals []string        `json:"journals"`
+}
+
+// PruneReport is what a pruning pass removed.
+type PruneReport struct {
+	JournalFiles int
+	JournalBytes int64
+	// Rotated journals kept as they record pending transactions
+	JournalsKept int
+	Liquidations int
+}
+
+func (r PruneReport) empty() bool {
+	return r.JournalFiles == 0 && r.JournalsKept == 0 && r.Liquidations == 0
+}
+
+// janitor enforces the retention policies: rotated journals older than
+// the maximum age, or the oldest beyond the maximum size of all
+// rotated journals, are rolled up into daily rollups and deleted, as
+// are liquidations of the history store older than the maximum age.
+// Journals recording the submission of a transaction still pending
+// reconciliation are kept. The ledger only keeps the last day already.
+type janitor struct {
+	logger      Logger
+	journalPath string
+	historyPath string
+	maxAge      time.Duration
+	maxSize     int64
+	pending     *pendingTxs
+}
+
+func (c *Connection) newJanitor() *janitor {
+	return &janitor{
+		logger:      c.logger,
+		journalPath: c.config.JournalPath,
+		historyPath: c.config.HistoryPath,
+		maxAge:      c.config.RetentionMaxAge,
+		maxSize:     c.config.RetentionMaxSize,
+		pending:     c.pending,
+	}
+}
+
+// run prunes every interval until ctx is cancelled.
+func (j *janitor) run(ctx context.Context, interval time.Duration) {
+	if j.maxAge <= 0 && j.maxSize <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := j.prune(ctx, time.Now()); err != nil {
+			j.logger.Error(fmt.Sprintf("Failed to prune: %v", err), F("err", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune runs a pruning pass and reports what it removed.
+func (j *janitor) prune(ctx context.Context, now time.Time) (PruneReport, error) {
+	var report PruneReport
+	if j.journalPath != "" {
+		if err := j.pruneJournals(now, &report); err != nil {
+			return report, err
+		}
+	}
+	if j.historyPath != "" && j.maxAge > 0 {
+		if err := j.pruneHistory(ctx, now, &report); err != nil {
+			return report, err
+		}
+	}
+	if !report.empty() {
+		j.logger.Info(fmt.Sprintf("Pruned %d journals of %d bytes, keeping %d recording pending transactions, and %d liquidations of the history",
+			report.JournalFiles, report.JournalBytes, report.JournalsKept, report.Liquidations),
+			F("journalFiles", report.JournalFiles), F("journalBytes", report.JournalBytes), F("journalsKept", report.JournalsKept), F("liquidations", report.Liquidations))
+	}
+	return report, nil
+}
+
+func (j *janitor) pruneHistory(ctx context.Context, now time.Time, report *PruneReport) error {
+	if _, err := os.Stat(j.historyPath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	h, err := OpenHistory(j.logger, j.historyPath)
+	if err != nil {
+		// Eg., locked by an indexing run for longer than the busy
+		// timeout; pruned on the next pass
+		j.logger.Warn(fmt.Sprintf("Skipping pruning of the history: %v", err), F("err", err))
+		return nil
+	}
+	defer h.Close()
+	report.Liquidations, err = h.prune(ctx, now.Add(-j.maxAge))
+	return err
+}
+
+// journalRollupsPath is where rollups of pruned journals are kept,
+// out of the way of rotated journals.
+func journalRollupsPath(journalPath string) string {
+	return journalPath + "-rollups.json"
+}
+
+func (j *janitor) pruneJournals(now time.Time, report *PruneReport) error {
+	rotated, err := filepath.Glob(j.journalPath + ".*")
+	if err != nil {
+		return fmt.Errorf("cannot list rotated journals: %w", err)
+	}
+	type journalFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []journalFile
+	var total int64
+	for _, path := range rotated {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("cannot stat journal: %w", err)
+		}
+		files = append(files, journalFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	// Rotated journals are named after their rotation time
+	sort.Slice(files, func(a, b int) bool { return files[a].path < files[b].path })
+
+	var rollups journalRollups
+	rollupsPath := journalRollupsPath(j.journalPath)
+	if _, err := loadState(j.logger, "journal rollups", rollupsPath, journalRollupsVersion, &rollups); err != nil {
+		return err
+	}
+	rolledUp := make(map[string]bool, len(rollups.Journals))
+	for _, name := range rollups.Journals {
+		rolledUp[name] = true
+	}
+	pending := j.pending.hashes()
+	for _, file := range files {
+		expired := j.maxAge > 0 && now.Sub(file.modTime) > j.maxAge
+		oversized := j.maxSize > 0 && total > j.maxSize
+		if !expired && !oversized {
+			continue
+		}
+		if name := filepath.Base(file.path); !rolledUp[name] {
+			fileRollups, referenced, err := rollUpJournal(file.path, pending)
+			if err != nil {
+				return err
+			}
+			if referenced {
+				report.JournalsKept++
+				continue
+			}
+			rollups.Rollups = mergeJournalRollups(rollups.Rollups, fileRollups)
+			rollups.Journals = append(rollups.Journals, name)
+			// The rollups are persisted before the journal goes
+			if err := store.Write(rollupsPath, journalRollupsVersion, rollups); err != nil {
+				return fmt.Errorf("cannot persist journal rollups: %w", err)
+			}
+		}
+		if err := os.Remove(file.path); err != nil {
+			return fmt.Errorf("cannot prune journal: %w", err)
+		}
+		total -= file.size
+		report.JournalFiles++
+		report.JournalBytes += file.size
+	}
+	return nil
+}
+
+// rollUpJournal sums the entries of a journal by day and pool, and
+// reports whether it records the submission of a pending transaction.
+func rollUpJournal(path string, pending map[common.Hash]bool) ([]JournalRollup, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot open journal: %w", err)
+	}
+	defer file.Close()
+
+	type rollupKey struct {
+		day  time.Time
+		pool common.Address
+	}
+	rollups := make(map[rollupKey]*JournalRollup)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, journalMaxLine)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			return nil, false, fmt.Errorf("cannot decode %s line %d: %w", path, line, err)
+		}
+		var raw struct {
+			Data json.RawMessage `json:"data,omitempty"`
+		}
+		if err := json.Unmarshal([]byte(text), &raw); err != nil {
+			return nil, false, fmt.Errorf("cannot decode %s line %d: %w", path, line, err)
+		}
+		if entry.Kind == JournalSubmission && entry.Tx != nil && pending[*entry.Tx] {
+			return nil, true, nil
+		}
+		if entry.Kind != JournalBlock && entry.Kind != JournalReceipt {
+			continue
+		}
+
+		day := entry.Time.UTC().Truncate(24 * time.Hour)
+		k := rollupKey{day: day, pool: entry.Pool}
+		rollup, ok := rollups[k]
+		if !ok {
+			rollup = &JournalRollup{Day: day, Pool: entry.Pool, PnL: new(big.Int)}
+			rollups[k] = rollup
+		}
+		switch entry.Kind {
+		case JournalBlock:
+			var data JournalBlockData
+			if err := json.Unmarshal(raw.Data, &data); err != nil {
+				return nil, false, fmt.Errorf("cannot decode %s line %d: %w", path, line, err)
+			}
+			rollup.Blocks++
+			rollup.Underwater += data.Underwater
+			rollup.Liquidatable += data.Liquidatable
+			for reason, n := range data.Dropped {
+				if rollup.Dropped == nil {
+					rollup.Dropped = make(map[string]int)
+				}
+				rollup.Dropped[reason] += n
+			}
+		case JournalReceipt:
+			var data JournalReceiptData
+			if err := json.Unmarshal(raw.Data, &data); err != nil {
+				return nil, false, fmt.Errorf("cannot decode %s line %d: %w", path, line, err)
+			}
+			rollup.Executions++
+			if entry.Err != "" {
+				rollup.Failures++
+			}
+			if data.PnL != nil {
+				rollup.PnL.Add(rollup.PnL, data.PnL)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+
+	list := make([]JournalRollup, 0, len(rollups))
+	for _, rollup := range rollups {
+		list = append(list, *rollup)
+	}
+	return list, false, nil
+}
+
+// mergeJournalRollups adds rollups to existing ones, by day and pool.
+func mergeJournalRollups(existing, rollups []JournalRollup) []JournalRollup {
+	for _, rollup := range rollups {
+		i := sort.Search(len(existing), func(i int) bool {
+			return !journalRollupLess(existing[i], rollup)
+		})
+		if i == len(existing) || !existing[i].Day.Equal(rollup.Day) || existing[i].Pool != rollup.Pool {
+			existing = append(existing, JournalRollup{})
+			copy(existing[i+1:], existing[i:])
+			existing[i] = JournalRollup{Day: rollup.Day, Pool: rollup.Pool, PnL: new(big.Int)}
+		}
+		merged := &existing[i]
+		merged.Blocks += rollup.Blocks
+		merged.Underwater += rollup.Underwater
+		merged.Liquidatable += rollup.Liquidatable
+		for reason, n := range rollup.Dropped {
+			if merged.Dropped == nil {
+				merged.Dropped = make(map[string]int)
+			}
+			merged.Dropped[reason] += n
+		}
+		merged.Executions += rollup.Executions
+		merged.Failures += rollup.Failures
+		merged.PnL = new(big.Int).Add(merged.PnL, rollup.PnL)
+	}
+	return existing
+}
+
+func journalRollupLess(a, b JournalRollup) bool {
+	if !a.Day.Equal(b.Day) {
+		return a.Day.Before(b.Day)
+	}
+	return strings.Compare(a.Pool.Hex(), b.Pool.Hex()) == -1
+}
+
+// JournalRollups returns the daily rollups of the pruned journals at
+// path, by day and pool.
+func JournalRollups(path string) ([]JournalRollup, error) {
+	var rollups journalRollups
+	if _, _, err := store.Read(journalRollupsPath(path), journalRollupsVersion, &rollups); err != nil {
+		return nil, fmt.Errorf("cannot read journal rollups: %w", err)
+	}
+	return rollups.Rollups, nil
+}
@@ -0,0 +1,360 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// Accounts whose liquidity is within this percentage of their
+	// borrows, a health factor under 1.05, are near liquidation
+	riskNearMargin = 5
+	// Largest borrowers the concentration of the borrows of a pool is of
+	riskTopBorrowers = 5
+)
+
+// PoolRisk summarizes the risk of a pool at a block. Values are in the
+// unit of account of the oracle scaled by 1e18.
+type PoolRisk struct {
+	Pool  common.Address `json:"pool"`
+	Block *big.Int       `json:"block"`
+	// Totals of every market; the pool totals add up the priced ones
+	Markets  []MarketRisk `json:"markets"`
+	Supplied *big.Int     `json:"supplied"`
+	Borrowed *big.Int     `json:"borrowed"`
+
+	Borrowers  int `json:"borrowers"`
+	Underwater int `json:"underwater"`
+	// Of every underwater account
+	Shortfall *big.Int `json:"shortfall"`
+	// Borrowers whose borrows are known, from the positions read by the
+	// checks, and how many of them are near liquidation
+	Known           int `json:"known"`
+	NearLiquidation int `json:"nearLiquidation"`
+	// Borrows of the largest known borrowers over the borrows of the
+	// pool
+	TopBorrowers  []common.Address `json:"topBorrowers"`
+	Concentration Ratio            `json:"concentration"`
+	// Price moves that liquidate the healthy accounts closest to
+	// liquidation, if read
+	Closest []AccountSensitivity `json:"closest"`
+	// Borrows of the underwater accounts without collateral worth
+	// liquidating, by market, and their value; see classifyCandidate
+	BadDebtAccounts int             `json:"badDebtAccounts"`
+	BadDebt         *big.Int        `json:"badDebt"`
+	BadDebtMarkets  []MarketBadDebt `json:"badDebtMarkets"`
+	// Of the comptroller, as probed at startup
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+}
+
+// MarketRisk are the totals of a market, in underlying; nil if unknown,
+// as are the values of markets the oracle cannot price.
+type MarketRisk struct {
+	Market        common.Address `json:"market"`
+	Symbol        string         `json:"symbol"`
+	TotalSupply   *big.Int       `json:"totalSupply"`
+	TotalBorrows  *big.Int       `json:"totalBorrows"`
+	SuppliedValue *big.Int       `json:"suppliedValue"`
+	BorrowedValue *big.Int       `json:"borrowedValue"`
+}
+
+func (r PoolRisk) String() string {
+	return fmt.Sprintf("%s supplied, %s borrowed, %d of %d borrowers underwater by %s, %d of %d known near liquidation, top %d borrowers hold %s of borrows, %s of bad debt across %d accounts",
+		formatValue(r.Supplied), formatValue(r.Borrowed), r.Underwater, r.Borrowers, formatValue(r.Shortfall),
+		r.NearLiquidation, r.Known, len(r.TopBorrowers), r.Concentration.Percent(), formatValue(r.BadDebt), r.BadDebtAccounts)
+}
+
+// MarshalJSON encodes the integers of the summary as decimal strings.
+func (r PoolRisk) MarshalJSON() ([]byte, error) {
+	type risk PoolRisk
+	return json.Marshal(struct {
+		risk
+		Block     *decimalInt `json:"block"`
+		Supplied  *decimalInt `json:"supplied"`
+		Borrowed  *decimalInt `json:"borrowed"`
+		Shortfall *decimalInt `json:"shortfall"`
+		BadDebt   *decimalInt `json:"badDebt"`
+	}{risk(r), decimal(r.Block), decimal(r.Supplied), decimal(r.Borrowed), decimal(r.Shortfall), decimal(r.BadDebt)})
+}
+
+// MarshalJSON encodes the totals and values of the market as decimal
+// strings.
+func (m MarketRisk) MarshalJSON() ([]byte, error) {
+	type market MarketRisk
+	return json.Marshal(struct {
+		market
+		TotalSupply   *decimalInt `json:"totalSupply"`
+		TotalBorrows  *decimalInt `json:"totalBorrows"`
+		SuppliedValue *decimalInt `json:"suppliedValue"`
+		BorrowedValue *decimalInt `json:"borrowedValue"`
+	}{market(m), decimal(m.TotalSupply), decimal(m.TotalBorrows), decimal(m.SuppliedValue), decimal(m.BorrowedValue)})
+}
+
+// riskMonitor summarizes the risk of a pool every check from what the
+// check read: the liquidity of every borrower, the market totals of the
+// snapshot and the borrows of the accounts whose positions were read,
+// kept until read again, and the bad debt of the accounts classified as
+// such until they are not. It alerts when the aggregate shortfall or the
+// concentration of the borrows exceeds its threshold, once until they
+// are under it again. It is safe for concurrent use.
+type riskMonitor struct {
+	logger Logger
+	pool   common.Address
+	// Nil disables the alert
+	shortfallAlert     *big.Int
+	concentrationAlert *Ratio
+	// Set before the first check
+	capabilities *Capabilities
+
+	lock sync.Mutex
+	// Borrow balances of every account whose positions were read
+	borrows map[common.Address][]Position
+	// Accounts classified bad debt, and the bad debt of the last report
+	badDebt         map[common.Address]bool
+	badDebtReported *big.Int
+	last            *PoolRisk
+	// Whether the thresholds are exceeded
+	shortfallHigh, concentrationHigh bool
+}
+
+func newRiskMonitor(logger Logger, pool common.Address, shortfallAlert *big.Int, concentrationAlert *Ratio) *riskMonitor {
+	return &riskMonitor{
+		logger:             logger,
+		pool:               pool,
+		shortfallAlert:     shortfallAlert,
+		concentrationAlert: concentrationAlert,
+		borrows:            make(map[common.Address][]Position),
+		badDebt:            make(map[common.Address]bool),
+	}
+}
+
+// record keeps the borrows of accounts whose positions were read.
+func (r *riskMonitor) record(accounts []AccountPositions) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, account := range accounts {
+		borrows := make([]Position, 0, 1)
+		for _, position := range account.Positions {
+			if IsPositive(position.Borrowed) {
+				borrows = append(borrows, Position{Market: position.Market, Borrowed: position.Borrowed})
+			}
+		}
+		r.borrows[account.Account] = borrows
+	}
+}
+
+// observe summarizes the risk of the pool at the block of s, in time
+// proportional to the number of borrowers, given the liquidity of
+// every borrower and the sensitivities of the closest to liquidation,
+// and alerts if it exceeds a threshold.
+func (r *riskMonitor) observe(s *Snapshot, borrowers []Borrower, liquidities []accountLiquidity, closest []AccountSensitivity) *PoolRisk {
+	if r == nil {
+		return nil
+	}
+	risk := &PoolRisk{
+		Pool:      s.Pool,
+		Block:     s.Block,
+		Markets:   make([]MarketRisk, 0, len(s.Markets)),
+		Supplied:  new(big.Int),
+		Borrowed:  new(big.Int),
+		Borrowers: len(borrowers),
+		Shortfall: new(big.Int),
+		Closest:   closest,
+
+		Capabilities: r.capabilities,
+	}
+	for _, market := range s.Markets {
+		m := MarketRisk{Market: market.Address, Symbol: market.Symbol, TotalSupply: market.TotalSupply, TotalBorrows: market.TotalBorrows}
+		if market.Price != nil && market.TotalSupply != nil && market.TotalBorrows != nil {
+			m.SuppliedValue, m.BorrowedValue = market.Value(market.TotalSupply), market.Value(market.TotalBorrows)
+			risk.Supplied.Add(risk.Supplied, m.SuppliedValue)
+			risk.Borrowed.Add(risk.Borrowed, m.BorrowedValue)
+		}
+		risk.Markets = append(risk.Markets, m)
+	}
+	sort.Slice(risk.Markets, func(i, j int) bool {
+		return bytes.Compare(risk.Markets[i].Market[:], risk.Markets[j].Market[:]) == -1
+	})
+
+	type borrower struct {
+		account common.Address
+		value   *big.Int
+	}
+	// Largest first
+	top := make([]borrower, 0, riskTopBorrowers+1)
+	near := new(big.Int)
+
+	r.lock.Lock()
+	for i, b := range borrowers {
+		liquidity := liquidities[i]
+		if liquidity == nil || liquidity.failed() {
+			continue
+		}
+		underwater := liquidity.underwater()
+		if underwater {
+			risk.Underwater++
+			risk.Shortfall.Add(risk.Shortfall, liquidity.shortfall())
+		} else {
+			delete(r.badDebt, b.Address)
+		}
+		borrows, ok := r.borrows[b.Address]
+		if !ok {
+			continue
+		}
+		value := new(big.Int)
+		for _, position := range borrows {
+			market, ok := s.Markets[position.Market]
+			if !ok || market.Price == nil {
+				value = nil
+				break
+			}
+			value.Add(value, market.Value(position.Borrowed))
+		}
+		if value == nil {
+			continue
+		}
+		risk.Known++
+		// liquidity <= value * riskNearMargin%
+		near.Mul(value, big.NewInt(riskNearMargin))
+		if !underwater && IsPositive(value) && GTE(near, new(big.Int).Mul(liquidity.liquidity(), big.NewInt(100))) {
+			risk.NearLiquidation++
+		}
+		if len(top) < riskTopBorrowers || GT(value, top[len(top)-1].value) {
+			j := sort.Search(len(top), func(j int) bool { return GT(value, top[j].value) })
+			top = append(top, borrower{})
+			copy(top[j+1:], top[j:])
+			top[j] = borrower{account: b.Address, value: value}
+			if len(top) > riskTopBorrowers {
+				top = top[:riskTopBorrowers]
+			}
+		}
+	}
+	// Forget accounts that are no longer borrowers, but for the bad
+	// debt parked out of the checks
+	if len(r.borrows) > len(borrowers) {
+		current := make(map[common.Address]bool, len(borrowers))
+		for _, b := range borrowers {
+			current[b.Address] = true
+		}
+		for account := range r.borrows {
+			if !current[account] && !r.badDebt[account] {
+				delete(r.borrows, account)
+			}
+		}
+	}
+	risk.BadDebtMarkets, risk.BadDebt, risk.BadDebtAccounts = r.badDebtOf(s)
+	r.lock.Unlock()
+
+	largest := new(big.Int)
+	risk.TopBorrowers = make([]common.Address, 0, len(top))
+	for _, b := range top {
+		risk.TopBorrowers = append(risk.TopBorrowers, b.account)
+		largest.Add(largest, b.value)
+	}
+	// Undefined without the totals of the pool
+	if IsPositive(risk.Borrowed) {
+		risk.Concentration = NewRatio(largest, risk.Borrowed)
+	}
+
+	r.alert(risk)
+	r.lock.Lock()
+	r.last = risk
+	r.lock.Unlock()
+	return risk
+}
+
+// alert warns when the shortfall or the concentration of risk exceed
+// their thresholds, and logs when they are under them again.
+func (r *riskMonitor) alert(risk *PoolRisk) {
+	fields := []Field{F("pool", r.pool), F("block", risk.Block), F("shortfall", risk.Shortfall), F("concentration", risk.Concentration.String())}
+	if r.shortfallAlert != nil {
+		high := GT(risk.Shortfall, r.shortfallAlert)
+		switch {
+		case high && !r.shortfallHigh:
+			r.logger.Error(fmt.Sprintf("Aggregate shortfall of pool %s is %s, over %s, across %d underwater accounts", r.pool, formatValue(risk.Shortfall), formatValue(r.shortfallAlert), risk.Underwater),
+				append(fields, F("threshold", r.shortfallAlert), F("underwater", risk.Underwater))...)
+		case !high && r.shortfallHigh:
+			r.logger.Info(fmt.Sprintf("Aggregate shortfall of pool %s is %s, back under %s", r.pool, formatValue(risk.Shortfall), formatValue(r.shortfallAlert)),
+				append(fields, F("threshold", r.shortfallAlert))...)
+		}
+		r.shortfallHigh = high
+	}
+	if r.concentrationAlert != nil {
+		high := risk.Concentration.Exceeds(*r.concentrationAlert)
+		switch {
+		case high && !r.concentrationHigh:
+			r.logger.Error(fmt.Sprintf("Top %d borrowers of pool %s hold %s of its borrows, over %s: %v", len(risk.TopBorrowers), r.pool, risk.Concentration.Percent(), r.concentrationAlert.Percent(), risk.TopBorrowers),
+				append(fields, F("threshold", r.concentrationAlert.String()), F("borrowers", risk.TopBorrowers))...)
+		case !high && r.concentrationHigh:
+			r.logger.Info(fmt.Sprintf("Top %d borrowers of pool %s hold %s of its borrows, back under %s", len(risk.TopBorrowers), r.pool, risk.Concentration.Percent(), r.concentrationAlert.Percent()),
+				append(fields, F("threshold", r.concentrationAlert.String()))...)
+		}
+		r.concentrationHigh = high
+	}
+}
+
+// latest returns the summary of the last check, or nil if none
+// completed.
+func (r *riskMonitor) latest() *PoolRisk {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.last
+}
+
+// reportRisk logs the risk of every Compound pool of manager every
+// interval, daily by default as the competitor report, until ctx is
+// cancelled.
+func (c *Connection) reportRisk(ctx context.Context, manager *PoolManager, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultCompetitorStatsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, l := range manager.liquidatoors() {
+			risk := l.risk.latest()
+			if risk == nil {
+				continue
+			}
+			shortfallUSD, badDebtUSD := c.fiat.valueUSD(risk.Pool, risk.Shortfall), c.fiat.valueUSD(risk.Pool, risk.BadDebt)
+			c.logger.Info(fmt.Sprintf("Risk of pool %s at block %v: %s%s", risk.Pool, risk.Block, risk, formatRiskUSD(shortfallUSD, badDebtUSD)),
+				F("pool", risk.Pool), F("block", risk.Block), F("supplied", risk.Supplied), F("borrowed", risk.Borrowed),
+				F("underwater", risk.Underwater), F("shortfall", risk.Shortfall), F("nearLiquidation", risk.NearLiquidation),
+				F("known", risk.Known), F("concentration", risk.Concentration.String()),
+				F("badDebt", risk.BadDebt), F("badDebtAccounts", risk.BadDebtAccounts), F("shortfallUsd", shortfallUSD), F("badDebtUsd", badDebtUSD))
+			if last, grown := l.risk.badDebtGrown(risk); grown {
+				c.logger.Warn(fmt.Sprintf("Bad debt of pool %s grew from %s to %s%s across %d accounts", risk.Pool, formatValue(last), formatValue(risk.BadDebt), formatUSD(badDebtUSD), risk.BadDebtAccounts),
+					F("pool", risk.Pool), F("block", risk.Block), F("badDebt", risk.BadDebt), F("badDebtUsd", badDebtUSD), F("previous", last), F("badDebtAccounts", risk.BadDebtAccounts))
+			}
+		}
+	}
+}
+
+// formatRiskUSD formats the shortfall and the bad debt of a risk report
+// in USD, empty if the unit of account of the pool is not priced.
+func formatRiskUSD(shortfall, badDebt *float64) string {
+	if shortfall == nil || badDebt == nil {
+		return ""
+	}
+	return fmt.Sprintf("; shortfall %s USD, bad debt %s USD", strconv.FormatFloat(*shortfall, 'f', 2, 64), strconv.FormatFloat(*badDebt, 'f', 2, 64))
+}
@@ -0,0 +1,75 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+)
+
+// Run connects to the configured node and monitors every configured
+// pool until ctx is cancelled.
+func Run(ctx context.Context, cfg *Config, opts ...Option) error {
+	conn, err := Connect(ctx, cfg, opts...)
+	if err != nil {
+		return fmt.Errorf("cannot connect: %w", err)
+	}
+	defer conn.Close()
+	return conn.Run(ctx)
+}
+
+// Run monitors every configured pool, and the pools found by
+// discovery if enabled, until ctx is cancelled.
+func (c *Connection) Run(ctx context.Context) error {
+	manager := NewPoolManager(c)
+
+	// Executions in flight complete before returning
+	ctx, cancel := context.WithCancel(ctx)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		c.queue.Run(ctx)
+	}()
+	defer func() { <-drained }()
+	defer cancel()
+	if c.mempool != nil {
+		go c.mempool.run(ctx)
+	}
+	go c.annotations.run(ctx)
+	go c.accountList.run(ctx)
+	go c.presenter.run(ctx)
+	go c.remoteConfig.run(ctx)
+	go c.fiat.run(ctx)
+	go c.alerts.run(ctx)
+	go c.claimShard(ctx)
+	go c.roles.run(ctx)
+	go c.publisher.run(ctx)
+	go c.serve(ctx, c.config.ReadinessAddress, manager)
+	go c.newJanitor().run(ctx, c.config.RetentionInterval)
+	go c.newCompetitorReport().run(ctx, c.config.CompetitorStatsInterval)
+	go c.simulation.run(ctx, c.config.CompetitorStatsInterval)
+	go c.reportRisk(ctx, manager, c.config.CompetitorStatsInterval)
+	c.pending.resume(ctx, c.client, c.ledger, c.pendingResumed, c.blockTime)
+
+	for _, comptroller := range c.config.Comptrollers {
+		if err := manager.Add(ctx, comptroller); err != nil {
+			return fmt.Errorf("cannot instantiate liquidatoor: %w", err)
+		}
+	}
+	for _, comet := range c.config.Comets {
+		if err := manager.AddComet(ctx, comet); err != nil {
+			return fmt.Errorf("cannot instantiate comet monitor: %w", err)
+		}
+	}
+
+	if c.config.Discovery != nil {
+		discovery, err := NewPoolDiscovery(c, manager)
+		if err != nil {
+			return fmt.Errorf("cannot instantiate pool discovery: %w", err)
+		}
+		go discovery.Init(ctx)
+	} else if len(manager.Pools()) == 0 {
+		return fmt.Errorf("%w: one of COMPTROLLER_ADDRESS, COMET_ADDRESS or POOL_DIRECTORY_ADDRESS needs to be set", ErrInvalidConfig)
+	}
+
+	<-ctx.Done()
+	return nil
+}
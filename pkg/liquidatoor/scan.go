@@ -0,0 +1,134 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ScanSummary is what a single scan found and executed; see
+// Connection.Scan.
+type ScanSummary struct {
+	Block *decimalInt  `json:"block"`
+	Pools []ScanResult `json:"pools"`
+	// Liquidatable candidates of every pool
+	Candidates int `json:"candidates"`
+	// Candidates whose liquidation succeeded or failed, if executing
+	Executed int `json:"executed"`
+	Failed   int `json:"failed"`
+	// Pools that could not be scanned
+	Unscanned int `json:"unscanned"`
+}
+
+// ScanResult is what a single scan found in a pool.
+type ScanResult struct {
+	Pool       common.Address  `json:"pool"`
+	Candidates []ScanCandidate `json:"candidates"`
+	// Why the pool could not be scanned, if it could not
+	Err string `json:"error,omitempty"`
+}
+
+// ScanCandidate is a liquidatable candidate a single scan found.
+type ScanCandidate struct {
+	Account common.Address `json:"account"`
+	// Of the account, see Presenter
+	Label    string       `json:"label,omitempty"`
+	URL      string       `json:"url"`
+	Executed bool         `json:"executed"`
+	Tx       *common.Hash `json:"tx,omitempty"`
+	TxURL    string       `json:"txUrl,omitempty"`
+	PnL      *decimalInt  `json:"pnl,omitempty"`
+	Err      string       `json:"error,omitempty"`
+}
+
+// Scan checks every configured pool once at the latest block, priming
+// their borrower caches first, and executes the liquidatable
+// candidates found if execute is set, one at a time. Pools found by
+// discovery are not scanned. Scan returns once done or once ctx is
+// cancelled, so a deadline on ctx bounds it; it is used instead of
+// Run.
+func (c *Connection) Scan(ctx context.Context, execute bool) (*ScanSummary, error) {
+	pools := append(append([]common.Address(nil), c.config.Comptrollers...), c.config.Comets...)
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("%w: one of COMPTROLLER_ADDRESS or COMET_ADDRESS needs to be set", ErrInvalidConfig)
+	}
+	header, err := c.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get latest block: %w", err)
+	}
+	summary := &ScanSummary{Block: decimal(header.Number), Pools: make([]ScanResult, len(pools))}
+	results := make(map[common.Address]*ScanResult, len(pools))
+	for i, pool := range pools {
+		summary.Pools[i] = ScanResult{Pool: pool, Candidates: []ScanCandidate{}}
+		results[pool] = &summary.Pools[i]
+	}
+	c.logger.Info(fmt.Sprintf("Scanning %d pools at block %v", len(pools), header.Number), F("block", header.Number))
+
+	for i, pool := range pools {
+		if i < len(c.config.Comptrollers) {
+			err = c.scanPool(ctx, pool, header.Number)
+		} else {
+			err = c.scanComet(ctx, pool)
+		}
+		if err != nil {
+			c.logger.Error(fmt.Sprintf("Failed to scan pool %s: %v", pool, err), F("pool", pool), F("err", err))
+			results[pool].Err = err.Error()
+			summary.Unscanned++
+		}
+	}
+
+	record := func(job Job, candidate ScanCandidate) {
+		candidate.Label, candidate.URL = c.presenter.Label(candidate.Account), c.presenter.AddressURL(candidate.Account)
+		if candidate.Tx != nil {
+			candidate.TxURL = c.presenter.TxURL(*candidate.Tx)
+		}
+		if result, ok := results[job.Candidate.Pool]; ok {
+			result.Candidates = append(result.Candidates, candidate)
+		}
+		summary.Candidates++
+	}
+	if execute {
+		c.queue.drain(ctx, func(job Job, outcome *Outcome, err error) {
+			candidate := ScanCandidate{Account: job.Candidate.Account, Executed: err == nil}
+			if outcome != nil {
+				candidate.Tx, candidate.PnL = &outcome.Tx, decimal(outcome.PnL)
+			}
+			if err != nil {
+				candidate.Err = err.Error()
+				summary.Failed++
+			} else {
+				summary.Executed++
+			}
+			record(job, candidate)
+		})
+	} else {
+		for _, job := range c.queue.jobs() {
+			record(job, ScanCandidate{Account: job.Candidate.Account})
+		}
+	}
+	c.logger.Info(fmt.Sprintf("Scan complete: %d candidates, %d executed, %d failed, %d pools not scanned", summary.Candidates, summary.Executed, summary.Failed, summary.Unscanned),
+		F("block", header.Number), F("candidates", summary.Candidates), F("executed", summary.Executed), F("failed", summary.Failed), F("unscanned", summary.Unscanned))
+	return summary, ctx.Err()
+}
+
+// scanPool checks the pool of comptroller at block, queueing its
+// candidates. NewLiquidatoor primes its borrower cache.
+func (c *Connection) scanPool(ctx context.Context, comptroller common.Address, block *big.Int) error {
+	l, err := c.NewLiquidatoor(ctx, comptroller)
+	if err != nil {
+		return err
+	}
+	return l.shortfallCheck(ctx, block, &BlockResult{Pool: comptroller, Block: block})
+}
+
+// scanComet checks the Comet market at address, queueing its
+// candidates.
+func (c *Connection) scanComet(ctx context.Context, address common.Address) error {
+	m, err := c.NewCometMonitor(ctx, address)
+	if err != nil {
+		return err
+	}
+	return m.LiquidatableCheck(ctx)
+}
@@ -0,0 +1,134 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/exp"
+)
+
+// Healthy accounts closest to liquidation whose sensitivities are
+// refreshed every check
+const sensitivityWatched = 10
+
+const (
+	ShockDrop = "drop"
+	ShockRise = "rise"
+)
+
+// PriceSensitivity is the move of the price of the underlying of one
+// market that brings the liquidity of an account to zero, every other
+// price unchanged. Shocks are one at a time: the moves of several
+// markets do not add up.
+type PriceSensitivity struct {
+	Market common.Address `json:"market"`
+	Symbol string         `json:"symbol"`
+	// ShockDrop for markets the account is net supplied in, weighted by
+	// the collateral factor, ShockRise for the ones it is net borrowed
+	// from
+	Shock string `json:"shock"`
+	// Share of the price; zero if the account is liquidatable already
+	Move Ratio `json:"move"`
+	// Whether the price can move that far; drops cannot exceed 100%
+	Reachable bool `json:"reachable"`
+}
+
+func (p PriceSensitivity) String() string {
+	if !p.Reachable {
+		return fmt.Sprintf("not liquidatable by a %s %s alone", p.Symbol, p.Shock)
+	}
+	verb := "drops"
+	if p.Shock == ShockRise {
+		verb = "rises"
+	}
+	return fmt.Sprintf("liquidatable if %s %s %s", p.Symbol, verb, p.Move.Percent())
+}
+
+// AccountSensitivity are the sensitivities of a watched account given
+// its liquidity, in the unit of account of the oracle scaled by 1e18.
+type AccountSensitivity struct {
+	Account       common.Address     `json:"account"`
+	Liquidity     *big.Int           `json:"liquidity"`
+	Sensitivities []PriceSensitivity `json:"sensitivities"`
+}
+
+// MarshalJSON encodes the liquidity as a decimal string.
+func (a AccountSensitivity) MarshalJSON() ([]byte, error) {
+	type sensitivity AccountSensitivity
+	return json.Marshal(struct {
+		sensitivity
+		Liquidity *decimalInt `json:"liquidity"`
+	}{sensitivity(a), decimal(a.Liquidity)})
+}
+
+// sensitivities returns the price move of every market account is
+// exposed to that brings liquidity, negative if underwater, to zero,
+// smallest first. A move of a fraction of the price of a market changes
+// the liquidity by that fraction of the supply weighted by the
+// collateral factor less the borrows of the market. Markets that cannot
+// be priced, or whose supply cannot be weighted, are left out.
+func sensitivities(s *Snapshot, account AccountPositions, liquidity *big.Int) []PriceSensitivity {
+	result := make([]PriceSensitivity, 0, len(account.Positions))
+	for _, position := range account.Positions {
+		market, ok := s.Markets[position.Market]
+		if !ok || market.Price == nil || (market.CollateralFactor == nil && IsPositive(position.Supplied)) {
+			continue
+		}
+		exposure := new(big.Int).Neg(market.Value(orZero(position.Borrowed)))
+		if IsPositive(position.Supplied) {
+			exposure.Add(exposure, exp.MulScalarTruncate(new(big.Int), market.CollateralFactor, market.Value(position.Supplied)))
+		}
+		if IsZero(exposure) {
+			continue
+		}
+		p := PriceSensitivity{Market: market.Address, Symbol: market.Symbol, Shock: ShockDrop, Reachable: true}
+		if IsNegative(exposure) {
+			p.Shock = ShockRise
+			exposure.Neg(exposure)
+		}
+		p.Move = NewRatio(new(big.Int), exposure)
+		if IsPositive(liquidity) {
+			p.Move = NewRatio(liquidity, exposure)
+		}
+		if p.Shock == ShockDrop && p.Move.Exceeds(NewRatio(big.NewInt(1), big.NewInt(1))) {
+			p.Reachable = false
+		}
+		result = append(result, p)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Reachable != result[j].Reachable {
+			return result[i].Reachable
+		}
+		return result[i].Move.Below(result[j].Move)
+	})
+	return result
+}
+
+// closestSensitivities returns the sensitivities of the healthy
+// watched accounts closest to liquidation, from their positions at the
+// latest block, or nil if they cannot be read.
+func (l *Liquidatoor) closestSensitivities(ctx context.Context, s *Snapshot) []AccountSensitivity {
+	borrowers, liquidities := l.watchlist.closest(sensitivityWatched)
+	if len(borrowers) == 0 {
+		return nil
+	}
+	positions, err := l.positions(ctx, nil, borrowers)
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Cannot get positions of the accounts closest to liquidation: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		return nil
+	}
+	closest := make([]AccountSensitivity, 0, len(positions))
+	for i, account := range positions {
+		a := AccountSensitivity{Account: account.Account, Liquidity: liquidities[i], Sensitivities: sensitivities(s, account, liquidities[i])}
+		if len(a.Sensitivities) > 0 {
+			l.logger.Debug(fmt.Sprintf("Account %s is %s", a.Account, a.Sensitivities[0]), F("pool", l.comptrollerAddress), F("account", a.Account))
+		}
+		closest = append(closest, a)
+	}
+	return closest
+}
@@ -0,0 +1,90 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Wait between claims of the shard with the coordination backend
+const shardClaimInterval = time.Minute
+
+// Shard is the part of the borrowers of every pool an instance owns,
+// by the hash of their addresses, so instances can split pools too
+// large for one of them to check within the block time. The zero
+// Shard owns every borrower.
+type Shard struct {
+	Index int
+	Count int
+}
+
+func (s Shard) sharded() bool {
+	return s.Count > 1
+}
+
+func (s Shard) validate() error {
+	if s.Count < 0 || s.Index < 0 || (s.Count > 0 && s.Index >= s.Count) || (s.Count == 0 && s.Index != 0) {
+		return fmt.Errorf("%w: SHARD_INDEX %d needs to be below SHARD_COUNT %d", ErrInvalidConfig, s.Index, s.Count)
+	}
+	return nil
+}
+
+// Owns reports whether account falls in the shard.
+func (s Shard) Owns(account common.Address) bool {
+	if !s.sharded() {
+		return true
+	}
+	hash := crypto.Keccak256(account.Bytes())
+	return binary.BigEndian.Uint64(hash[:8])%uint64(s.Count) == uint64(s.Index)
+}
+
+// filter returns the accounts the shard owns, in order.
+func (s Shard) filter(accounts []common.Address) []common.Address {
+	if !s.sharded() {
+		return accounts
+	}
+	owned := make([]common.Address, 0, len(accounts)/s.Count+1)
+	for _, account := range accounts {
+		if s.Owns(account) {
+			owned = append(owned, account)
+		}
+	}
+	return owned
+}
+
+func (s Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+// claimShard claims the shard every shardClaimInterval until ctx is
+// cancelled, if sharded and coordinated, and alerts when another
+// instance claimed it first: both then check the same borrowers, and
+// no instance checks the borrowers of the shard it should own. Every
+// instance claims the shard of the current interval, so the second one
+// fails every interval.
+func (c *Connection) claimShard(ctx context.Context) {
+	if !c.shard.sharded() || c.queue.coordinator == nil {
+		return
+	}
+	for {
+		now := time.Now()
+		interval := now.UnixNano() / int64(shardClaimInterval)
+		claimed, err := c.queue.coordinator.claim(ctx, fmt.Sprintf("shard:%s:%d", c.shard, interval), 2*shardClaimInterval)
+		switch {
+		case err != nil:
+			c.logger.Warn(fmt.Sprintf("Cannot claim shard %s: %v", c.shard, err), F("err", err))
+		case !claimed:
+			c.logger.Error(fmt.Sprintf("Shard %s is claimed by another instance, check SHARD_INDEX and SHARD_COUNT", c.shard))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Unix(0, (interval+1)*int64(shardClaimInterval)).Sub(now)):
+		}
+	}
+}
@@ -1,7 +1,65 @@
 package liquidatoor
 
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
 type ByShortfall []Borrower
 
-func (a ByShortfall) Len() int           { return len(a) }
-func (a ByShortfall) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByShortfall) Less(i, j int) bool { return a[i].Shortfall.Cmp(a[j].Shortfall) == 1 }
+func (a ByShortfall) Len() int      { return len(a) }
+func (a ByShortfall) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a ByShortfall) Less(i, j int) bool {
+	return GT(a[i].Shortfall, a[j].Shortfall)
+}
+
+// accountLiquidity is the output of getAccountLiquidity, an error
+// code, the liquidity and the shortfall as 32-byte words. Its outputs
+// are unnamed so they cannot be unpacked into a struct, and most
+// accounts are healthy, so words are compared in place and only the
+// shortfall of underwater accounts is decoded.
+type accountLiquidity []byte
+
+func (a accountLiquidity) validate() error {
+	if len(a) != 3*32 {
+		return fmt.Errorf("cannot unpack account liquidity of %d bytes", len(a))
+	}
+	return nil
+}
+
+func (a accountLiquidity) errCode() *big.Int {
+	return new(big.Int).SetBytes(a[:32])
+}
+
+// failure returns the error code of the comptroller by name.
+func (a accountLiquidity) failure() abis.Failure {
+	return abis.ComptrollerReporter.Decode(a.errCode(), nil, nil)
+}
+
+func (a accountLiquidity) failed() bool {
+	return !isZeroWord(a[:32])
+}
+
+func (a accountLiquidity) underwater() bool {
+	return bytes.Compare(a[32:64], a[64:96]) == -1
+}
+
+func (a accountLiquidity) liquidity() *big.Int {
+	return new(big.Int).SetBytes(a[32:64])
+}
+
+func (a accountLiquidity) shortfall() *big.Int {
+	return new(big.Int).SetBytes(a[64:96])
+}
+
+func isZeroWord(word []byte) bool {
+	for _, b := range word {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
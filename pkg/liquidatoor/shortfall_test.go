@@ -0,0 +1,70 @@
+package liquidatoor
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// liquidityBatcher answers every call with the same account liquidity.
+type liquidityBatcher struct {
+	data []byte
+}
+
+func (b liquidityBatcher) Aggregate(_ *bind.CallOpts, calls []abis.MulticallCall) ([]CallResult, error) {
+	results := make([]CallResult, len(calls))
+	for i := range results {
+		results[i] = CallResult{Success: true, ReturnData: b.data}
+	}
+	return results, nil
+}
+
+// BenchmarkAccountLiquidities checks the liquidity of the 15k healthy
+// borrowers of a pool once per block, as shortfall checks do.
+func BenchmarkAccountLiquidities(b *testing.B) {
+	comptrollerABI, err := abis.ComptrollerMetaData.GetAbi()
+	if err != nil {
+		b.Fatal(err)
+	}
+	method := comptrollerABI.Methods["getAccountLiquidity"]
+	data, err := method.Outputs.Pack(big.NewInt(0), big.NewInt(1e18), big.NewInt(0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	borrowers := make([]Borrower, 15000)
+	for i := range borrowers {
+		address := common.BigToAddress(big.NewInt(int64(i + 1)))
+		packed, err := method.Inputs.Pack(address)
+		if err != nil {
+			b.Fatal(err)
+		}
+		borrowers[i] = Borrower{Address: address, liquidityCallData: append(method.ID[:len(method.ID):len(method.ID)], packed...)}
+	}
+	l := &Liquidatoor{
+		logger:         &stdLogger{logger: log.New(io.Discard, "", 0)},
+		Batcher:        liquidityBatcher{data: data},
+		comptrollerABI: comptrollerABI,
+	}
+	start := &blockStart{borrowers: borrowers}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scan, err := l.accountLiquidities(context.Background(), big.NewInt(1), start)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, liquidity := range scan.results {
+			if liquidity.failed() || liquidity.underwater() {
+				b.Fatal("expected healthy borrowers")
+			}
+		}
+	}
+}
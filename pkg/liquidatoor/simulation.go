@@ -0,0 +1,288 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+const (
+	// Blocks an opportunity stays open after it was last liquidatable,
+	// for its liquidation by a competitor to count as lost
+	simulationWindow = 20
+	// Expected profits the median is computed over, the latest
+	simulationSamples = 4096
+)
+
+// SimulationStats is what a simulation found since it started.
+type SimulationStats struct {
+	Since time.Time
+	// Opportunities the simulation would have submitted, and their rate
+	Opportunities int
+	PerDay        float64
+	// Lower median of the net expected profit of the opportunities, in
+	// Currency; nil if none was estimated
+	MedianExpectedProfit *big.Int
+	Currency             string
+	// Opportunities of Compound pools liquidated by a competitor while
+	// open, out of the ones closed
+	Lost     int
+	Closed   int
+	LossRate Ratio
+}
+
+func (s SimulationStats) String() string {
+	profit := "unknown"
+	if s.MedianExpectedProfit != nil {
+		profit = formatValue(s.MedianExpectedProfit) + " " + s.Currency
+	}
+	return fmt.Sprintf("%d opportunities (%.1f a day), median expected profit %s, %d of %d lost to competitors (%s)",
+		s.Opportunities, s.PerDay, profit, s.Lost, s.Closed, s.LossRate.Percent())
+}
+
+// simulation records what a keyless instance would submit: every
+// liquidatable candidate opens an opportunity, which is lost if a
+// competitor liquidates the account while it is open and closes once
+// the account was not liquidatable for simulationWindow blocks. It is
+// safe for concurrent use; a nil simulation records nothing.
+type simulation struct {
+	logger  Logger
+	journal *Journal
+	since   time.Time
+
+	lock          sync.Mutex
+	open          map[jobKey]*opportunity
+	opportunities int
+	profits       []*big.Int
+	currency      string
+	lost, closed  int
+}
+
+// opportunity is a liquidation the simulation would have submitted.
+type opportunity struct {
+	// Blocks it was first and last liquidatable at
+	opened, seen uint64
+	// It cannot be lost, as its liquidations are not observed
+	unobserved bool
+}
+
+func newSimulation(logger Logger, journal *Journal) *simulation {
+	return &simulation{logger: logger, journal: journal, since: time.Now(), open: make(map[jobKey]*opportunity)}
+}
+
+// keylessTransactOpts returns the transact options of a keyless
+// connection, from address, which panic when signing so no path can
+// submit a transaction.
+func keylessTransactOpts(address common.Address) *bind.TransactOpts {
+	return &bind.TransactOpts{
+		From: address,
+		Signer: func(common.Address, *types.Transaction) (*types.Transaction, error) {
+			panic(fmt.Sprintf("cannot sign transactions of %s: ROLE is %s and holds no key", address, RoleSimulation))
+		},
+	}
+}
+
+// wouldSubmit records the decision to submit the liquidation of c,
+// opening its opportunity unless open. Liquidations of unobserved
+// opportunities are not tracked, so they are not counted as lost.
+func (s *simulation) wouldSubmit(c Candidate, observed bool) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	key := jobKey{pool: c.Pool, account: c.Account}
+	var block uint64
+	if c.Block != nil {
+		block = c.Block.Uint64()
+	}
+	o, ok := s.open[key]
+	if ok {
+		o.seen = block
+	} else {
+		s.opportunities++
+		s.open[key] = &opportunity{opened: block, seen: block, unobserved: !observed}
+		if c.Estimate != nil && c.Estimate.Net != nil {
+			if len(s.profits) == simulationSamples {
+				s.profits = append(s.profits[:0], s.profits[1:]...)
+			}
+			s.profits = append(s.profits, c.Estimate.Net)
+			s.currency = c.Estimate.Currency
+		}
+	}
+	s.lock.Unlock()
+
+	fields := []Field{F("pool", c.Pool), F("account", c.Account), F("block", c.Block)}
+	if c.Estimate != nil {
+		s.logger.Info(fmt.Sprintf("Would submit liquidation of account %s, expected %s", c.Account, c.Estimate), append(fields, F("net", c.Estimate.Net))...)
+	} else {
+		s.logger.Info(fmt.Sprintf("Would submit liquidation of account %s", c.Account), fields...)
+	}
+	s.journal.Record(JournalEntry{Kind: JournalDecision, Pool: c.Pool, Block: c.Block, Account: c.Account, Decision: "would-submit"})
+}
+
+// liquidated records that liquidator liquidated account in pool at
+// block, losing its open opportunity, if any.
+func (s *simulation) liquidated(pool, account, liquidator common.Address, block uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := jobKey{pool: pool, account: account}
+	o, ok := s.open[key]
+	if !ok || o.unobserved || block <= o.opened {
+		return
+	}
+	delete(s.open, key)
+	s.lost++
+	s.closed++
+	s.logger.Info(fmt.Sprintf("Lost the opportunity of account %s to %s at block %d, %d blocks after detecting it", account, liquidator, block, block-o.opened),
+		F("pool", pool), F("account", account), F("liquidator", liquidator), F("block", block))
+}
+
+// expire closes the opportunities of pool not liquidatable since
+// simulationWindow blocks before block.
+func (s *simulation) expire(pool common.Address, block uint64) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, o := range s.open {
+		if key.pool != pool || o.seen+simulationWindow >= block {
+			continue
+		}
+		delete(s.open, key)
+		if !o.unobserved {
+			s.closed++
+		}
+	}
+}
+
+// watched reports whether pool has opportunities whose liquidation is
+// observed.
+func (s *simulation) watched(pool common.Address) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, o := range s.open {
+		if key.pool == pool && !o.unobserved {
+			return true
+		}
+	}
+	return false
+}
+
+// stats returns what the simulation found since it started.
+func (s *simulation) stats() SimulationStats {
+	if s == nil {
+		return SimulationStats{}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stats := SimulationStats{
+		Since:         s.since,
+		Opportunities: s.opportunities,
+		Currency:      s.currency,
+		Lost:          s.lost,
+		Closed:        s.closed,
+		LossRate:      NewRatio(big.NewInt(int64(s.lost)), big.NewInt(int64(s.closed))),
+	}
+	if days := time.Since(s.since).Hours() / 24; days > 0 {
+		stats.PerDay = float64(s.opportunities) / days
+	}
+	if len(s.profits) > 0 {
+		profits := append([]*big.Int(nil), s.profits...)
+		sort.Slice(profits, func(i, j int) bool {
+			return GT(profits[j], profits[i])
+		})
+		stats.MedianExpectedProfit = profits[(len(profits)-1)/2]
+	}
+	return stats
+}
+
+// run logs the statistics every interval, daily by default as the
+// competitor report, until ctx is cancelled.
+func (s *simulation) run(ctx context.Context, interval time.Duration) {
+	if s == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultCompetitorStatsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.report()
+	}
+}
+
+// report logs the statistics.
+func (s *simulation) report() {
+	if s == nil {
+		return
+	}
+	stats := s.stats()
+	s.logger.Info("Simulation: "+stats.String(), F("opportunities", stats.Opportunities), F("perDay", stats.PerDay),
+		F("medianProfit", stats.MedianExpectedProfit), F("lost", stats.Lost), F("closed", stats.Closed))
+}
+
+// observeLiquidations records the liquidations of the pool since the
+// last block observed, up to block, against the open opportunities of
+// the simulation, if any.
+func (l *Liquidatoor) observeLiquidations(ctx context.Context, block *big.Int) {
+	if l.simulation == nil || block == nil {
+		return
+	}
+	to := block.Uint64()
+	from := l.observedBlock + 1
+	if l.observedBlock == 0 || from > to || !l.simulation.watched(l.comptrollerAddress) {
+		l.observedBlock = to
+		l.simulation.expire(l.comptrollerAddress, to)
+		return
+	}
+
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		l.logger.Error(fmt.Sprintf("Cannot get ctoken ABI: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		return
+	}
+	event := cTokenABI.Events["LiquidateBorrow"]
+	addresses := make([]common.Address, 0, len(l.LendMarkets))
+	for address := range l.LendMarkets {
+		addresses = append(addresses, common.HexToAddress(address))
+	}
+	query := ethereum.FilterQuery{Addresses: addresses, Topics: [][]common.Hash{{event.ID}}}
+	err = l.logs.filter(ctx, "liquidations", query, from, to, func(logs []types.Log, _ uint64) error {
+		for _, log := range logs {
+			borrower, err := eventAddress(&event, log, "borrower")
+			if err != nil {
+				return err
+			}
+			liquidator, err := eventAddress(&event, log, "liquidator")
+			if err != nil {
+				return err
+			}
+			l.simulation.liquidated(l.comptrollerAddress, borrower, liquidator, log.BlockNumber)
+		}
+		return nil
+	})
+	if err != nil {
+		// Retried with the next block
+		l.logger.Warn(fmt.Sprintf("Failed to get liquidations of blocks %d-%d: %v", from, to, err), F("pool", l.comptrollerAddress), F("err", err))
+		return
+	}
+	l.observedBlock = to
+	l.simulation.expire(l.comptrollerAddress, to)
+}
@@ -0,0 +1,74 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSimulationRefusesToSend(t *testing.T) {
+	borrower := common.HexToAddress("0x1000")
+	l, client := walletLiquidatoor(t, borrower, big.NewInt(1000), big.NewInt(1e18))
+	l.TxOpts = keylessTransactOpts(common.HexToAddress("0xfeed"))
+	l.simulation = newSimulation(l.logger, nil)
+
+	if _, err := l.LiquidateAccount(context.Background(), Borrower{Address: borrower}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected LiquidateAccount to refuse with ErrInvalidConfig, got %v", err)
+	}
+	// The executor of ExecuteLiquidations, in case one is set
+	plan := &LiquidationPlan{Borrower: borrower, BorrowMarket: common.HexToAddress("0xa"), CollateralMarket: common.HexToAddress("0xb"), RepayAmount: big.NewInt(500)}
+	if _, err := l.liquidate(context.Background(), Candidate{Account: borrower, Plan: plan}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected the executor to refuse with ErrInvalidConfig, got %v", err)
+	}
+	if len(client.sent) != 0 {
+		t.Fatalf("expected nothing sent, got %d transactions", len(client.sent))
+	}
+}
+
+func TestKeylessTransactOptsCannotSign(t *testing.T) {
+	client := &walletBackend{}
+	opts := keylessTransactOpts(common.HexToAddress("0xfeed"))
+	// Preset so nothing is queried before signing
+	opts.Nonce, opts.GasPrice, opts.GasLimit = big.NewInt(0), big.NewInt(1), 21000
+	contract := bind.NewBoundContract(common.HexToAddress("0xc0"), abi.ABI{}, client, client, client)
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "holds no key") {
+			t.Fatalf("expected signing to panic, got %v", r)
+		}
+		if len(client.sent) != 0 {
+			t.Fatalf("expected nothing sent, got %d transactions", len(client.sent))
+		}
+	}()
+	_, _ = contract.RawTransact(opts, nil)
+}
+
+func TestSimulationRejectsExecutors(t *testing.T) {
+	simulated := common.HexToAddress("0xfeed")
+	for _, tc := range []struct {
+		name   string
+		config Config
+	}{
+		{"private key", Config{PrivateKey: "01", SimulationAddress: simulated}},
+		{"no simulated wallet", Config{}},
+		{"executor", Config{SimulationAddress: simulated, Executor: ExecutorFunc(func(context.Context, Candidate) (*Outcome, error) { return nil, nil })}},
+		{"executing liquidations", Config{SimulationAddress: simulated, ExecuteLiquidations: true}},
+		{"NATS handoff", Config{SimulationAddress: simulated, NATSURL: "nats://localhost:4222"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.Role = RoleSimulation
+			c := &Connection{config: &tc.config, logger: quietLogger()}
+			if err := c.transactor(big.NewInt(1)); !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("expected ErrInvalidConfig, got %v", err)
+			}
+			if c.TxOpts != nil {
+				t.Fatal("expected no transact options")
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TokenClass groups tokens by the slippage their swaps are expected to
+// have.
+type TokenClass string
+
+const (
+	TokenClassStable   TokenClass = "stable"
+	TokenClassMajor    TokenClass = "major"
+	TokenClassLongTail TokenClass = "long-tail"
+)
+
+// Times a plan is halved when a swap quote exceeds the slippage limit
+// before dropping it
+const maxSwapDownsizes = 3
+
+// Scaled by 1e18
+var defaultSlippageLimits = map[TokenClass]*big.Int{
+	TokenClassStable:   big.NewInt(3e15),
+	TokenClassMajor:    big.NewInt(1e16),
+	TokenClassLongTail: big.NewInt(3e16),
+}
+
+// Classes of well-known symbols; other tokens are long-tail unless
+// configured
+var symbolClasses = map[string]TokenClass{
+	"USDC": TokenClassStable, "USDT": TokenClassStable, "DAI": TokenClassStable, "BUSD": TokenClassStable,
+	"FRAX": TokenClassStable, "LUSD": TokenClassStable, "TUSD": TokenClassStable, "USDP": TokenClassStable,
+	"ETH": TokenClassMajor, "WETH": TokenClassMajor, "STETH": TokenClassMajor, "WSTETH": TokenClassMajor,
+	"WBTC": TokenClassMajor, "BTCB": TokenClassMajor, "BNB": TokenClassMajor, "WBNB": TokenClassMajor,
+	"MATIC": TokenClassMajor, "WMATIC": TokenClassMajor, "AVAX": TokenClassMajor, "WAVAX": TokenClassMajor,
+}
+
+// SwapQuoter quotes swaps of seized collateral, eg., on the DEX the
+// executor swaps on.
+type SwapQuoter interface {
+	QuoteSwap(ctx context.Context, tokenIn, tokenOut common.Address, amountIn *big.Int) (*big.Int, error)
+}
+
+// SwapLimit bounds the swap of the seized collateral of a plan into
+// the repaid token, as needed to repay a flash loan.
+type SwapLimit struct {
+	// The zero address is the native token
+	TokenIn  common.Address
+	TokenOut common.Address
+	// Class of the larger limit of the two tokens
+	Class    TokenClass
+	AmountIn *big.Int
+	// At the oracle price
+	Expected *big.Int
+	// Maximum slippage from the oracle price, scaled by 1e18
+	Limit *big.Int
+	// Use as amountOutMin
+	MinAmountOut *big.Int
+}
+
+// MarshalJSON encodes the amounts of the limit as decimal strings.
+func (s SwapLimit) MarshalJSON() ([]byte, error) {
+	type limit SwapLimit
+	return json.Marshal(struct {
+		limit
+		AmountIn     *decimalInt
+		Expected     *decimalInt
+		Limit        *decimalInt
+		MinAmountOut *decimalInt
+	}{limit(s), decimal(s.AmountIn), decimal(s.Expected), decimal(s.Limit), decimal(s.MinAmountOut)})
+}
+
+func (s *SwapLimit) UnmarshalJSON(data []byte) error {
+	type limit SwapLimit
+	v := struct {
+		*limit
+		AmountIn     *decimalInt
+		Expected     *decimalInt
+		Limit        *decimalInt
+		MinAmountOut *decimalInt
+	}{limit: (*limit)(s)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	s.AmountIn, s.Expected, s.Limit, s.MinAmountOut = v.AmountIn.int(), v.Expected.int(), v.Limit.int(), v.MinAmountOut.int()
+	return nil
+}
+
+// Check returns ErrSlippage if amountOut is below the minimum.
+func (s *SwapLimit) Check(amountOut *big.Int) error {
+	if GTE(amountOut, s.MinAmountOut) {
+		return nil
+	}
+	return fmt.Errorf("%w: %v of %s for %v of %s is %s below the oracle price, over the %s limit of %s",
+		ErrSlippage, amountOut, s.TokenOut, s.AmountIn, s.TokenIn, s.Slippage(amountOut).Percent(), s.Class, mantissaRatio(s.Limit).Percent())
+}
+
+// Slippage returns how far below the oracle price amountOut is;
+// negative if above.
+func (s *SwapLimit) Slippage(amountOut *big.Int) Ratio {
+	return NewRatio(new(big.Int).Sub(s.Expected, amountOut), s.Expected)
+}
+
+// Record returns the ledger record of the completed swap.
+func (s *SwapLimit) Record(amountOut *big.Int) SwapRecord {
+	return SwapRecord{
+		TokenIn:   s.TokenIn,
+		TokenOut:  s.TokenOut,
+		Class:     s.Class,
+		AmountIn:  s.AmountIn,
+		AmountOut: amountOut,
+		Expected:  s.Expected,
+		Slippage:  s.Slippage(amountOut).Mantissa(),
+	}
+}
+
+// SwapRecord is a completed swap of seized collateral, recorded in the
+// ledger to tune slippage limits from.
+type SwapRecord struct {
+	TokenIn   common.Address
+	TokenOut  common.Address
+	Class     TokenClass
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	Expected  *big.Int
+	// Realized, scaled by 1e18
+	Slippage *big.Int
+}
+
+// MarshalJSON encodes the amounts of the swap as decimal strings.
+func (r SwapRecord) MarshalJSON() ([]byte, error) {
+	type record SwapRecord
+	return json.Marshal(struct {
+		record
+		AmountIn  *decimalInt
+		AmountOut *decimalInt
+		Expected  *decimalInt
+		Slippage  *decimalInt
+	}{record(r), decimal(r.AmountIn), decimal(r.AmountOut), decimal(r.Expected), decimal(r.Slippage)})
+}
+
+func (r *SwapRecord) UnmarshalJSON(data []byte) error {
+	type record SwapRecord
+	v := struct {
+		*record
+		AmountIn  *decimalInt
+		AmountOut *decimalInt
+		Expected  *decimalInt
+		Slippage  *decimalInt
+	}{record: (*record)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.AmountIn, r.AmountOut, r.Expected, r.Slippage = v.AmountIn.int(), v.AmountOut.int(), v.Expected.int(), v.Slippage.int()
+	return nil
+}
+
+// slippagePolicy holds the slippage limits of every token class.
+type slippagePolicy struct {
+	limits map[TokenClass]*big.Int
+	// Overrides by underlying
+	classes map[common.Address]TokenClass
+}
+
+func newSlippagePolicy(limits map[TokenClass]*big.Int, classes map[common.Address]TokenClass) *slippagePolicy {
+	p := &slippagePolicy{limits: make(map[TokenClass]*big.Int), classes: classes}
+	for class, limit := range defaultSlippageLimits {
+		p.limits[class] = limit
+	}
+	for class, limit := range limits {
+		p.limits[class] = limit
+	}
+	return p
+}
+
+func (p *slippagePolicy) class(m MarketSnapshot) TokenClass {
+	if class, ok := p.classes[m.Underlying]; ok {
+		return class
+	}
+	if class, ok := symbolClasses[strings.ToUpper(m.Symbol)]; ok {
+		return class
+	}
+	if m.Native {
+		return TokenClassMajor
+	}
+	return TokenClassLongTail
+}
+
+// swapLimit bounds the swap of the collateral seized by plan into its
+// repaid token.
+func (p *slippagePolicy) swapLimit(s *Snapshot, plan *LiquidationPlan) *SwapLimit {
+	collateral, borrow := s.Markets[plan.CollateralMarket], s.Markets[plan.BorrowMarket]
+	class := p.class(collateral)
+	if other := p.class(borrow); GT(p.limits[other], p.limits[class]) {
+		class = other
+	}
+	limit := p.limits[class]
+	expected := borrow.Amount(plan.SeizeValue)
+	return &SwapLimit{
+		TokenIn:      collateral.Underlying,
+		TokenOut:     borrow.Underlying,
+		Class:        class,
+		AmountIn:     collateral.Amount(plan.SeizeValue),
+		Expected:     expected,
+		Limit:        limit,
+		MinAmountOut: minAmountOutOf(expected, limit),
+	}
+}
+
+// checkSlippage bounds the collateral swap of a candidate funded by a
+// flash loan and, with a quoter, halves its plan while the quote
+// exceeds the limit. The estimate then accounts for the quoted
+// slippage.
+func (l *Liquidatoor) checkSlippage(ctx context.Context, s *Snapshot, c *Candidate) error {
+	collateral, borrow := s.Markets[c.Plan.CollateralMarket], s.Markets[c.Plan.BorrowMarket]
+	if l.flashLiquidity == nil || collateral.Underlying == borrow.Underlying && collateral.Native == borrow.Native {
+		return nil
+	}
+	// Held collateral is not swapped
+	if c.Redemption != nil && c.Redemption.Adjustment == RedemptionHold {
+		return nil
+	}
+	if !IsPositive(collateral.Price) || !IsPositive(borrow.Price) {
+		return ErrStaleData
+	}
+	plan := *c.Plan
+	for i := 0; ; i++ {
+		limit := l.slippage.swapLimit(s, &plan)
+		if l.swapQuoter == nil {
+			c.Swap = limit
+			return nil
+		}
+		quote, err := l.swapQuoter.QuoteSwap(ctx, limit.TokenIn, limit.TokenOut, limit.AmountIn)
+		if err != nil {
+			return fmt.Errorf("cannot quote swap: %w", err)
+		}
+		err = limit.Check(quote)
+		if err != nil && i < maxSwapDownsizes {
+			plan.RepayAmount = new(big.Int).Quo(plan.RepayAmount, big.NewInt(2))
+			plan.RepayValue = costOf(borrow, plan.RepayAmount)
+			plan.SeizeValue = seizeValueOf(s, borrow, plan.RepayAmount)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			l.logger.Info(fmt.Sprintf("Downsized liquidation of account %s to repay %v for a swap within the %s slippage limit", plan.Borrower, plan.RepayAmount, limit.Class),
+				F("pool", l.comptrollerAddress), F("account", plan.Borrower), F("repay", plan.RepayAmount), F("class", limit.Class))
+			estimate, err := l.profitEstimator.Estimate(ctx, plan, s)
+			if err != nil {
+				return err
+			}
+			c.Plan, c.Estimate = &plan, estimate
+		}
+		c.Swap = limit
+		slippage := s.Markets[plan.BorrowMarket].Value(new(big.Int).Sub(limit.Expected, quote))
+		if IsPositive(slippage) && c.Estimate.Currency == OracleUnitOfAccount {
+			estimate := *c.Estimate
+			estimate.Slippage = new(big.Int).Add(estimate.Slippage, slippage)
+			estimate.Net = new(big.Int).Sub(estimate.Net, slippage)
+			c.Estimate = &estimate
+			if !estimate.Profitable() {
+				return ErrUnprofitable
+			}
+		}
+		return nil
+	}
+}
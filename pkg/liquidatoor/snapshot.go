@@ -0,0 +1,419 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/exp"
+)
+
+// Snapshot is the state of a pool's markets when processing a block.
+type Snapshot struct {
+	Pool     common.Address
+	Protocol string
+	// Block being processed; nil if unknown
+	Block *big.Int
+
+	// Whether seizing collateral is paused pool-wide
+	SeizePaused bool
+	// Whether cToken transfers are paused pool-wide, locking seized
+	// collateral in the pool
+	TransferPaused bool
+
+	CloseFactor          *big.Int
+	LiquidationIncentive *big.Int
+
+	Markets map[common.Address]MarketSnapshot
+}
+
+type MarketSnapshot struct {
+	Address common.Address
+	// Zero for native token markets
+	Underlying common.Address
+	Symbol     string
+	Decimals   uint8
+	Native     bool
+	// Oracle price of the underlying scaled by 1e(36-decimals); nil
+	// if the oracle could not price it
+	Price *big.Int
+	// Whether seizing the market as collateral is paused
+	SeizePaused bool
+	// Underlying supplied to, borrowed from and held by the market as
+	// of the last market state read; nil if unknown. Seized collateral
+	// can only be redeemed up to the cash.
+	TotalSupply  *big.Int
+	TotalBorrows *big.Int
+	Cash         *big.Int
+	// Share of the supply counted towards liquidity, scaled by 1e18;
+	// nil if unknown
+	CollateralFactor *big.Int
+}
+
+// Value returns the value of amount of the market's underlying in the
+// oracle's unit of account scaled by 1e18.
+func (m MarketSnapshot) Value(amount *big.Int) *big.Int {
+	return USDValue(amount, m.Price, m.Decimals)
+}
+
+// Amount is the inverse of Value.
+func (m MarketSnapshot) Amount(value *big.Int) *big.Int {
+	return exp.DivScalarByExpTruncate(new(big.Int), value, m.Price)
+}
+
+// AccountPositions are the balances of an account in every market it
+// entered.
+type AccountPositions struct {
+	Account   common.Address
+	Shortfall *big.Int
+	Positions []Position
+	// The shortfall or the seize planned exceeds the liquidity of the
+	// collateral market, so plans should seize within its cash; see
+	// IlliquidCollateral
+	Illiquid bool
+}
+
+// Position balances are denominated in the market's underlying.
+type Position struct {
+	Market   common.Address
+	Supplied *big.Int
+	Borrowed *big.Int
+}
+
+// Inventory is the wallet balance of every underlying. The native
+// token is keyed by the zero address.
+type Inventory map[common.Address]*big.Int
+
+// blockStart is what processing a block reads up front: the borrowers,
+// the market prices and the market state. The legs are independent so
+// they are read concurrently.
+type blockStart struct {
+	borrowers []Borrower
+	markets   []common.Address
+	// Nil if prices could not be read, in which case the snapshot has
+	// the prices of an earlier block
+	prices []*Price
+	// Oracle prices averaged over the previous blocks, by market
+	averagePrices map[common.Address]*big.Int
+	snapshot      *Snapshot
+	inventory     Inventory
+	// Plans of the pairs SelectLiquidationPair chose, by account,
+	// replacing those of the primary strategy, eg., in LiquidateAccount
+	pairs map[common.Address]LiquidationPlan
+	// Of reading the start, and the legs that fell back to an earlier
+	// block
+	timings BlockTimings
+	errs    []error
+}
+
+// startBlock reads the start of a block. Legs failing fall back to
+// their result in an earlier block, with a warning.
+func (l *Liquidatoor) startBlock(ctx context.Context, block *big.Int) (*blockStart, error) {
+	markets := make([]common.Address, 0, len(l.LendMarkets))
+	for address := range l.LendMarkets {
+		markets = append(markets, common.HexToAddress(address))
+	}
+	start := &blockStart{markets: markets}
+
+	var wg sync.WaitGroup
+	var pricesErr, stateErr error
+	var pricesTook, stateTook, cacheTook time.Duration
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		began := time.Now()
+		start.prices, pricesErr = pricesOf(ctx, l.logger, l.priceSource, l.assets(markets), nil)
+		pricesTook = time.Since(began)
+	}()
+	go func() {
+		defer wg.Done()
+		began := time.Now()
+		if err := l.reconcileLiquidationParams(ctx, block); err != nil {
+			l.logger.Warn(fmt.Sprintf("Failed to read liquidation params: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		}
+		if err := l.reconcilePauses(ctx, block, "read"); err != nil {
+			l.logger.Warn(fmt.Sprintf("Failed to read pauses: %v", err), F("pool", l.comptrollerAddress), F("err", err))
+		}
+		start.inventory, stateErr = l.marketState(ctx, block)
+		stateTook = time.Since(began)
+	}()
+	go func() {
+		defer wg.Done()
+		began := time.Now()
+		start.borrowers = l.borrowerCache.Read()
+		cacheTook = time.Since(began)
+	}()
+	wg.Wait()
+	l.logger.Info(fmt.Sprintf("Read block %v start: prices in %v, market state in %v, borrowers in %v", block, pricesTook, stateTook, cacheTook),
+		F("pool", l.comptrollerAddress), F("block", block), F("prices", pricesTook), F("state", stateTook), F("cache", cacheTook))
+	start.timings = BlockTimings{Prices: pricesTook, State: stateTook, Borrowers: cacheTook}
+
+	last := l.lastStart
+	if pricesErr != nil {
+		if last == nil {
+			return nil, fmt.Errorf("cannot get prices: %w", pricesErr)
+		}
+		l.logger.Warn(fmt.Sprintf("Failed to get prices, using the ones of block %v: %v", last.snapshot.Block, pricesErr),
+			F("pool", l.comptrollerAddress), F("block", block), F("err", pricesErr))
+		start.prices = nil
+		start.errs = append(start.errs, fmt.Errorf("cannot get prices: %w", pricesErr))
+	}
+	if stateErr != nil {
+		if last == nil {
+			return nil, stateErr
+		}
+		l.logger.Warn(fmt.Sprintf("Failed to get market state, using the one of block %v: %v", last.snapshot.Block, stateErr),
+			F("pool", l.comptrollerAddress), F("block", block), F("err", stateErr))
+		start.inventory = last.inventory
+		start.errs = append(start.errs, stateErr)
+	}
+
+	start.snapshot = l.snapshot(block, markets, start.prices, last)
+	start.averagePrices = l.priceGuard.observe(markets, start.prices)
+
+	l.lastStart = start
+	return start, nil
+}
+
+// snapshot returns the snapshot of markets in block with prices, or
+// the prices of last if nil.
+func (l *Liquidatoor) snapshot(block *big.Int, markets []common.Address, prices []*Price, last *blockStart) *Snapshot {
+	paused := l.pauses.pauses()
+	totals, _ := l.totals.Load().(map[common.Address]marketTotals)
+
+	params := l.liquidationParams()
+	s := &Snapshot{
+		Pool:                 l.comptrollerAddress,
+		Protocol:             l.adapter.Name(),
+		Block:                block,
+		CloseFactor:          params.closeFactor,
+		LiquidationIncentive: params.incentive,
+		SeizePaused:          paused.seize,
+		TransferPaused:       paused.transfer,
+		Markets:              make(map[common.Address]MarketSnapshot, len(markets)),
+	}
+	for i, market := range markets {
+		info := l.underlyingInfo[market.String()]
+		m := MarketSnapshot{
+			Address:          market,
+			Underlying:       info.address,
+			Symbol:           info.name,
+			Decimals:         info.decimals,
+			Native:           info.native,
+			SeizePaused:      paused.seizeMarkets[market],
+			TotalSupply:      totals[market].supply,
+			TotalBorrows:     totals[market].borrows,
+			Cash:             totals[market].cash,
+			CollateralFactor: totals[market].collateralFactor,
+		}
+		switch {
+		case prices != nil && prices[i] != nil:
+			m.Price = prices[i].Mantissa
+		case prices == nil:
+			m.Price = last.snapshot.Markets[market].Price
+		}
+		s.Markets[market] = m
+	}
+	return s
+}
+
+// marketTotals are the underlying supplied to, borrowed from and held
+// by a market, and its collateral factor.
+type marketTotals struct {
+	supply           *big.Int
+	borrows          *big.Int
+	cash             *big.Int
+	collateralFactor *big.Int
+}
+
+// marketState reads the wallet inventory, whether the pool whitelists
+// us, the comptroller governance when due and the totals of every
+// market.
+func (l *Liquidatoor) marketState(ctx context.Context, block *big.Int) (Inventory, error) {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	balanceOfMethod := cTokenABI.Methods["balanceOf"]
+	inputs, err := balanceOfMethod.Inputs.Pack(l.TxOpts.From)
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack owner: %w", err)
+	}
+
+	// Wallet balances of ERC20 markets
+	calls := []abis.MulticallCall{}
+	for _, info := range l.underlyingInfo {
+		if info.native {
+			continue
+		}
+		calls = append(calls, abis.MulticallCall{
+			Target:   info.address,
+			CallData: append(balanceOfMethod.ID[:], inputs[:]...),
+		})
+	}
+
+	balances := len(calls)
+	calls = append(calls, l.whitelist.calls()...)
+	whitelist := len(calls)
+	calls = append(calls, l.governance.calls(block)...)
+	governance := len(calls)
+	totalSupplyMethod := cTokenABI.Methods["totalSupply"]
+	exchangeRateMethod := cTokenABI.Methods["exchangeRateStored"]
+	totalBorrowsMethod := cTokenABI.Methods["totalBorrows"]
+	cashMethod := cTokenABI.Methods["getCash"]
+	marketsMethod := l.comptrollerABI.Methods["markets"]
+	for market := range l.LendMarkets {
+		target := common.HexToAddress(market)
+		inputs, err := marketsMethod.Inputs.Pack(target)
+		if err != nil {
+			return nil, fmt.Errorf("cannot pack market: %w", err)
+		}
+		calls = append(calls,
+			abis.MulticallCall{Target: target, CallData: totalSupplyMethod.ID},
+			abis.MulticallCall{Target: target, CallData: exchangeRateMethod.ID},
+			abis.MulticallCall{Target: target, CallData: totalBorrowsMethod.ID},
+			abis.MulticallCall{Target: target, CallData: cashMethod.ID},
+			abis.MulticallCall{Target: l.comptrollerAddress, CallData: append(marketsMethod.ID[:len(marketsMethod.ID):len(marketsMethod.ID)], inputs...)},
+		)
+	}
+
+	resp, err := l.Batcher.Aggregate(&bind.CallOpts{Context: ctx}, calls)
+	if err != nil {
+		return nil, fmt.Errorf("failed batch request: %v", err)
+	}
+	l.whitelist.observe(resp[balances:whitelist])
+	l.governance.observe(block, resp[whitelist:governance])
+	l.observeTotals(cTokenABI, calls[governance:], resp[governance:])
+
+	inventory := make(Inventory)
+	for i, result := range resp[:balances] {
+		underlying := calls[i].Target
+		if !result.Success {
+			l.logger.Warn(fmt.Sprintf("Failed to get inventory of %s", underlying), F("pool", l.comptrollerAddress), F("token", underlying))
+			continue
+		}
+		var balance *big.Int
+		if err := cTokenABI.UnpackIntoInterface(&balance, balanceOfMethod.Name, result.ReturnData); err != nil {
+			return nil, fmt.Errorf("cannot unpack balance output: %v", err)
+		}
+		inventory[underlying] = balance
+	}
+
+	native, err := l.client.BalanceAt(ctx, l.TxOpts.From, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get native balance: %w", err)
+	}
+	inventory[common.Address{}] = native
+
+	return inventory, nil
+}
+
+// observeTotals replaces the market totals with the ones of resp, the
+// totalSupply, exchangeRateStored, totalBorrows and getCash of every
+// market and its markets entry in the comptroller. Totals that cannot
+// be read keep their previous value.
+func (l *Liquidatoor) observeTotals(cTokenABI *abi.ABI, calls []abis.MulticallCall, resp []CallResult) {
+	old, _ := l.totals.Load().(map[common.Address]marketTotals)
+	unpack := func(method string, result CallResult) *big.Int {
+		var value *big.Int
+		if !result.Success || cTokenABI.UnpackIntoInterface(&value, method, result.ReturnData) != nil {
+			return nil
+		}
+		return value
+	}
+	totals := make(map[common.Address]marketTotals, len(resp)/5)
+	for i := 0; i+4 < len(resp); i += 5 {
+		market := calls[i].Target
+		t := old[market]
+		if supply, rate := unpack("totalSupply", resp[i]), unpack("exchangeRateStored", resp[i+1]); supply != nil && rate != nil {
+			// The exchange rate is scaled by 1e18
+			t.supply = exp.MulScalarTruncate(new(big.Int), supply, rate)
+		}
+		if borrows := unpack("totalBorrows", resp[i+2]); borrows != nil {
+			t.borrows = borrows
+		}
+		if cash := unpack("getCash", resp[i+3]); cash != nil {
+			t.cash = cash
+		}
+		if resp[i+4].Success {
+			if out, err := l.comptrollerABI.Unpack("markets", resp[i+4].ReturnData); err == nil && len(out) > 1 {
+				if factor, ok := out[1].(*big.Int); ok {
+					t.collateralFactor = factor
+				}
+			}
+		}
+		totals[market] = t
+	}
+	l.totals.Store(totals)
+}
+
+// positions reads the balances of the provided accounts in every
+// market they entered at block, the latest if nil.
+func (l *Liquidatoor) positions(ctx context.Context, block *big.Int, borrowers []Borrower) ([]AccountPositions, error) {
+	cTokenABI, err := abis.CTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	suppliedMethod := cTokenABI.Methods["balanceOfUnderlying"]
+	borrowedMethod := cTokenABI.Methods["borrowBalanceStored"]
+
+	size := 0
+	for _, borrower := range borrowers {
+		size += 2 * len(borrower.Assets)
+	}
+	calls := make([]abis.MulticallCall, 0, size)
+	for _, borrower := range borrowers {
+		inputs, err := suppliedMethod.Inputs.Pack(borrower.Address)
+		if err != nil {
+			return nil, fmt.Errorf("cannot pack borrower: %w", err)
+		}
+		for _, asset := range borrower.Assets {
+			calls = append(calls,
+				abis.MulticallCall{Target: asset, CallData: append(suppliedMethod.ID[:], inputs[:]...)},
+				abis.MulticallCall{Target: asset, CallData: append(borrowedMethod.ID[:], inputs[:]...)},
+			)
+		}
+	}
+
+	resp, err := l.Batcher.Aggregate(&bind.CallOpts{Context: ctx, BlockNumber: block}, calls)
+	if err != nil {
+		return nil, fmt.Errorf("failed batch request: %v", err)
+	}
+
+	accounts := make([]AccountPositions, 0, len(borrowers))
+	i := 0
+	for _, borrower := range borrowers {
+		account := AccountPositions{
+			Account:   borrower.Address,
+			Shortfall: borrower.Shortfall,
+			Positions: make([]Position, 0, len(borrower.Assets)),
+		}
+		for _, asset := range borrower.Assets {
+			supplied, borrowed := resp[i], resp[i+1]
+			i += 2
+			if !supplied.Success || !borrowed.Success {
+				l.logger.Warn(fmt.Sprintf("Failed to get position of account %s in market %s", borrower.Address, asset), F("pool", l.comptrollerAddress), F("account", borrower.Address), F("market", asset))
+				continue
+			}
+
+			position := Position{Market: asset}
+			if err := cTokenABI.UnpackIntoInterface(&position.Supplied, suppliedMethod.Name, supplied.ReturnData); err != nil {
+				return nil, fmt.Errorf("cannot unpack supplied output: %v", err)
+			}
+			if err := cTokenABI.UnpackIntoInterface(&position.Borrowed, borrowedMethod.Name, borrowed.ReturnData); err != nil {
+				return nil, fmt.Errorf("cannot unpack borrowed output: %v", err)
+			}
+			account.Positions = append(account.Positions, position)
+		}
+		accounts = append(accounts, account)
+	}
+	l.risk.record(accounts)
+	return accounts, nil
+}
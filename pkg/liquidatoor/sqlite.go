@@ -0,0 +1,80 @@
+package liquidatoor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+
+	// SQLite driver of the databases
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Milliseconds a statement waits for another process, eg., an indexing
+// run, to release a database
+const sqliteBusyTimeout = 5000
+
+// openSQLite opens, or creates, the SQLite database at path and creates
+// the tables of schema that do not exist. Transactions lock the database
+// for writing when they begin, so concurrent writers wait for each other
+// rather than fail on commit.
+func openSQLite(path string, schema []string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=%d&_txlock=immediate", path, sqliteBusyTimeout))
+	if err != nil {
+		return nil, err
+	}
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// lockSQLite locks the SQLite database at path against writes, once
+// pending writes complete, until the returned function is called.
+// Readers are not locked out.
+func lockSQLite(path string) (func() error, error) {
+	db, err := openSQLite(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		conn.Close()
+		db.Close()
+		return nil, err
+	}
+	return func() error {
+		conn.ExecContext(ctx, `ROLLBACK`)
+		conn.Close()
+		return db.Close()
+	}, nil
+}
+
+// sqlInt encodes x as a decimal string, as SQLite integers are 64 bits,
+// or NULL if x is nil.
+func sqlInt(x *big.Int) interface{} {
+	if x == nil {
+		return nil
+	}
+	return x.String()
+}
+
+// parseSQLInt decodes an integer encoded by sqlInt.
+func parseSQLInt(s sql.NullString) (*big.Int, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	x, ok := new(big.Int).SetString(s.String, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", s.String)
+	}
+	return x, nil
+}
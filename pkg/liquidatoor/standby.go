@@ -0,0 +1,372 @@
+package liquidatoor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/store"
+)
+
+// Role is whether an instance submits liquidations.
+type Role string
+
+const (
+	// Submits liquidations, and beats the heartbeat if any
+	RolePrimary Role = "primary"
+	// Detects liquidations but never submits them, until the heartbeat
+	// of the primary goes stale
+	RoleStandby Role = "standby"
+	// Holds no key and only records what it would submit, for the
+	// statistics of a chain before funding a wallet on it
+	RoleSimulation Role = "simulation"
+)
+
+const (
+	defaultHeartbeatInterval  = 10 * time.Second
+	defaultHeartbeatStaleness = time.Minute
+	heartbeatVersion          = 1
+	roleVersion               = 1
+)
+
+// Heartbeat is where the primary instance signals it is alive, for a
+// standby instance to take over once it stops.
+type Heartbeat interface {
+	// Beat records that instance is alive at the provided time.
+	Beat(ctx context.Context, instance string, at time.Time) error
+	// Last returns the instance and time of the last beat, or the zero
+	// time if there is none.
+	Last(ctx context.Context) (string, time.Time, error)
+}
+
+// NewHeartbeat returns the Heartbeat of rawURL: a file on a shared
+// volume, as a path or file:// URL, a Redis key under key, or an HTTP
+// endpoint of the primary, which only answers while it is alive.
+func NewHeartbeat(rawURL, key string) (Heartbeat, error) {
+	switch {
+	case strings.HasPrefix(rawURL, "redis://"), strings.HasPrefix(rawURL, "rediss://"):
+		client, err := newRedisClient(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		return &redisHeartbeat{client: client, key: key}, nil
+	case strings.HasPrefix(rawURL, "http://"), strings.HasPrefix(rawURL, "https://"):
+		return &httpHeartbeat{url: rawURL, client: &http.Client{Timeout: lockTimeout}}, nil
+	default:
+		return &fileHeartbeat{path: strings.TrimPrefix(rawURL, "file://")}, nil
+	}
+}
+
+// beat is the last beat of a heartbeat.
+type beat struct {
+	Instance string    `json:"instance"`
+	At       time.Time `json:"at"`
+}
+
+type fileHeartbeat struct {
+	path string
+}
+
+func (h *fileHeartbeat) Beat(_ context.Context, instance string, at time.Time) error {
+	return store.Write(h.path, heartbeatVersion, beat{Instance: instance, At: at})
+}
+
+func (h *fileHeartbeat) Last(context.Context) (string, time.Time, error) {
+	var last beat
+	if _, _, err := store.Read(h.path, heartbeatVersion, &last); err != nil {
+		return "", time.Time{}, err
+	}
+	return last.Instance, last.At, nil
+}
+
+type redisHeartbeat struct {
+	client *redisClient
+	key    string
+}
+
+func (h *redisHeartbeat) Beat(ctx context.Context, instance string, at time.Time) error {
+	_, err := h.client.command(ctx, "SET", h.key, fmt.Sprintf("%d %s", at.UnixNano(), instance))
+	return err
+}
+
+func (h *redisHeartbeat) Last(ctx context.Context) (string, time.Time, error) {
+	reply, err := h.client.command(ctx, "GET", h.key)
+	if err != nil || reply == "" {
+		return "", time.Time{}, err
+	}
+	fields := strings.SplitN(reply, " ", 2)
+	at, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || len(fields) != 2 {
+		return "", time.Time{}, fmt.Errorf("invalid heartbeat %q", reply)
+	}
+	return fields[1], time.Unix(0, at), nil
+}
+
+// httpHeartbeat checks the primary instead: it beats whenever its
+// endpoint answers with a success status.
+type httpHeartbeat struct {
+	url    string
+	client *http.Client
+
+	lock sync.Mutex
+	last time.Time
+}
+
+func (h *httpHeartbeat) Beat(context.Context, string, time.Time) error {
+	return nil
+}
+
+func (h *httpHeartbeat) Last(ctx context.Context) (string, time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("cannot check peer: %w", err)
+	}
+	resp, err := h.client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err == nil && resp.StatusCode < 300 {
+		h.last = time.Now()
+	}
+	// The peer not answering is the heartbeat stopping
+	return h.url, h.last, nil
+}
+
+// roleState is the role file, where an operator demotes a running
+// instance; see Demote.
+type roleState struct {
+	Role   Role      `json:"role"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// Demote makes the running instance of cfg stand by within a heartbeat
+// interval, and returns the role it persisted last.
+func Demote(cfg *Config) (Role, error) {
+	if cfg.RolePath == "" {
+		return "", fmt.Errorf("%w: one of ROLE_PATH or DATA_DIR needs to be set", ErrInvalidConfig)
+	}
+	var state roleState
+	if _, _, err := store.Read(cfg.RolePath, roleVersion, &state); err != nil {
+		return "", err
+	}
+	if err := store.Write(cfg.RolePath, roleVersion, roleState{Role: RoleStandby, Reason: "demoted by operator", At: time.Now()}); err != nil {
+		return "", err
+	}
+	return state.Role, nil
+}
+
+// roles holds the role of an instance, beating the heartbeat while
+// primary and watching it while standing by. A nil roles is always
+// primary.
+type roles struct {
+	logger    Logger
+	heartbeat Heartbeat
+	instance  string
+	interval  time.Duration
+	staleness time.Duration
+	path      string
+	// Reports whether the instance is ready to take over, as it would
+	// skip blocks until then
+	ready func() bool
+	// Called before promoting, eg., to reconcile the transactions the
+	// primary left pending; failing it is retried on the next beat
+	promote func(context.Context) error
+
+	standing int32
+
+	// Owned by run
+	since   time.Time
+	modTime time.Time
+	// Set on demotion until another instance beats, so the instance
+	// does not take over right back
+	held bool
+	// Set while the heartbeat cannot be read
+	failing bool
+	// Set while the heartbeat is stale but the instance is not ready
+	waiting bool
+}
+
+func newRoles(logger Logger, heartbeat Heartbeat, role Role, interval, staleness time.Duration, path string, ready func() bool, promote func(context.Context) error) (*roles, error) {
+	instance, err := instanceID()
+	if err != nil {
+		return nil, err
+	}
+	if interval == 0 {
+		interval = defaultHeartbeatInterval
+	}
+	if staleness == 0 {
+		staleness = defaultHeartbeatStaleness
+	}
+	if staleness < 2*interval {
+		return nil, fmt.Errorf("%w: HEARTBEAT_STALENESS of %v needs to cover two HEARTBEAT_INTERVAL of %v", ErrInvalidConfig, staleness, interval)
+	}
+	r := &roles{
+		logger:    logger,
+		heartbeat: heartbeat,
+		instance:  instance,
+		interval:  interval,
+		staleness: staleness,
+		path:      path,
+		ready:     ready,
+		promote:   promote,
+		since:     time.Now(),
+	}
+	if role == RoleStandby {
+		r.standing = 1
+	}
+	if err := r.persist(role, "configured"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// standby reports whether the instance stands by.
+func (r *roles) standby() bool {
+	return r != nil && atomic.LoadInt32(&r.standing) == 1
+}
+
+func (r *roles) role() Role {
+	if r.standby() {
+		return RoleStandby
+	}
+	return RolePrimary
+}
+
+// run beats or watches the heartbeat every interval until ctx is
+// cancelled.
+func (r *roles) run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if r.standby() {
+			r.watch(ctx)
+		} else {
+			r.beat(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// beat beats the heartbeat, unless an operator demoted the instance.
+func (r *roles) beat(ctx context.Context) {
+	if state, ok := r.demoted(); ok {
+		atomic.StoreInt32(&r.standing, 1)
+		r.since, r.held = time.Now(), true
+		r.logger.Error(fmt.Sprintf("Standing by: %s; no liquidation is submitted until the heartbeat of another primary goes stale", state.Reason),
+			F("role", RoleStandby), F("reason", state.Reason))
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+	if err := r.heartbeat.Beat(ctx, r.instance, time.Now()); err != nil {
+		r.logger.Warn(fmt.Sprintf("Cannot beat heartbeat: %v", err), F("err", err))
+	}
+}
+
+// watch promotes the instance once the heartbeat of the primary is
+// stale for the staleness configured.
+func (r *roles) watch(ctx context.Context) {
+	readCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+	instance, last, err := r.heartbeat.Last(readCtx)
+	cancel()
+	if err != nil {
+		// Taking over while the primary may be alive would submit
+		// everything twice
+		if !r.failing {
+			r.logger.Error(fmt.Sprintf("Cannot read heartbeat, standing by until it can be read: %v", err), F("err", err))
+		}
+		r.failing = true
+		return
+	}
+	if r.failing {
+		r.logger.Info("Heartbeat can be read again")
+		r.failing = false
+	}
+	// Beats of this instance before it was demoted do not count
+	if instance == r.instance {
+		last = time.Time{}
+	}
+	if last.After(r.since) {
+		r.held = false
+	}
+	if last.Before(r.since) {
+		last = r.since
+	}
+	stale := time.Since(last)
+	if r.held || stale < r.staleness {
+		return
+	}
+	if r.ready != nil && !r.ready() {
+		if !r.waiting {
+			r.logger.Error(fmt.Sprintf("Heartbeat of the primary is stale for %v, taking over once ready", stale.Round(time.Second)), F("stale", stale))
+		}
+		r.waiting = true
+		return
+	}
+	r.waiting = false
+
+	if r.promote != nil {
+		if err := r.promote(ctx); err != nil {
+			r.logger.Error(fmt.Sprintf("Cannot take over from the primary, whose heartbeat is stale for %v: %v", stale.Round(time.Second), err),
+				F("stale", stale), F("err", err))
+			return
+		}
+	}
+	atomic.StoreInt32(&r.standing, 0)
+	r.since = time.Now()
+	reason := fmt.Sprintf("heartbeat of the primary stale for %v", stale.Round(time.Second))
+	if err := r.persist(RolePrimary, reason); err != nil {
+		r.logger.Warn(fmt.Sprintf("Cannot persist role: %v", err), F("err", err))
+	}
+	r.logger.Error(fmt.Sprintf("PROMOTED TO PRIMARY: %s; submitting liquidations", reason), F("role", RolePrimary), F("reason", reason), F("stale", stale))
+	r.beat(ctx)
+}
+
+// demoted returns the demotion an operator persisted since the
+// instance became primary, if any.
+func (r *roles) demoted() (roleState, bool) {
+	if r.path == "" {
+		return roleState{}, false
+	}
+	info, err := os.Stat(r.path)
+	if err != nil || info.ModTime().Equal(r.modTime) {
+		return roleState{}, false
+	}
+	r.modTime = info.ModTime()
+	var state roleState
+	if _, _, err := store.Read(r.path, roleVersion, &state); err != nil {
+		r.logger.Warn(fmt.Sprintf("Cannot read role: %v", err), F("path", r.path), F("err", err))
+		return roleState{}, false
+	}
+	return state, state.Role == RoleStandby && state.At.After(r.since)
+}
+
+// persist persists the role, for operators to tell and change it.
+func (r *roles) persist(role Role, reason string) error {
+	if r.path == "" {
+		return nil
+	}
+	if err := store.Write(r.path, roleVersion, roleState{Role: role, Reason: reason, At: time.Now()}); err != nil {
+		return fmt.Errorf("cannot persist role: %w", err)
+	}
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return nil
+}
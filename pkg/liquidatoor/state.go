@@ -0,0 +1,76 @@
+package liquidatoor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/store"
+)
+
+// Schema versions of the state files. Versions 2 encode integers as
+// decimal strings, and read the numbers of versions 1.
+const (
+	ledgerVersion     = 2
+	pendingTxsVersion = 1
+	alertsVersion     = 1
+	// Of the rollups of pruned journals
+	journalRollupsVersion = 2
+)
+
+// Files and databases under the data directory
+const (
+	dataLedger     = "ledger.json"
+	dataPendingTxs = "pending.json"
+	dataJournal    = "journal.jsonl"
+	dataBorrowers  = "borrowers"
+	dataHistory    = "history"
+	dataAlerts     = "alerts.json"
+	dataRole       = "role.json"
+	dataRemote     = "remote.json"
+)
+
+// applyDataDir places every state file that is not explicitly
+// configured under the data directory, if set.
+func (cfg *Config) applyDataDir() {
+	if cfg.DataDir == "" {
+		return
+	}
+	for _, path := range []struct {
+		value *string
+		name  string
+	}{
+		{&cfg.LedgerPath, dataLedger},
+		{&cfg.PendingTxPath, dataPendingTxs},
+		{&cfg.JournalPath, dataJournal},
+		{&cfg.BorrowerCachePath, dataBorrowers},
+		{&cfg.HistoryPath, dataHistory},
+		{&cfg.AlertsPath, dataAlerts},
+		{&cfg.RolePath, dataRole},
+		{&cfg.RemoteConfigPath, dataRemote},
+	} {
+		if *path.value == "" {
+			*path.value = filepath.Join(cfg.DataDir, path.name)
+		}
+	}
+}
+
+// loadState decodes the state file at path into v and reports whether
+// it did. A file that cannot be trusted, as it is corrupt or from a
+// later version, is moved aside with an alert so starting over with
+// empty state does not overwrite it.
+func loadState(logger Logger, what, path string, version int, v interface{}) (bool, error) {
+	_, ok, err := store.Read(path, version, v)
+	if errors.Is(err, store.ErrCorrupt) || errors.Is(err, store.ErrFutureVersion) {
+		moved, moveErr := store.Quarantine(path)
+		if moveErr != nil {
+			return false, fmt.Errorf("cannot load %s: %v; %w", what, err, moveErr)
+		}
+		logger.Error(fmt.Sprintf("Starting with an empty %s: %v; moved it to %s", what, err, moved), F("path", moved), F("err", err))
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot read %s: %w", what, err)
+	}
+	return ok, nil
+}
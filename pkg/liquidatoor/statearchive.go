@@ -0,0 +1,289 @@
+package liquidatoor
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/store"
+)
+
+// Version of the snapshot archive layout
+const snapshotVersion = 1
+
+// Entry of the archive describing it, ahead of the state
+const snapshotManifest = "MANIFEST.json"
+
+// Versions of the state files validated on snapshot and restore
+var stateVersions = map[string]int{
+	dataLedger:     ledgerVersion,
+	dataPendingTxs: pendingTxsVersion,
+	dataAlerts:     alertsVersion,
+
+	journalRollupsPath(dataJournal): journalRollupsVersion,
+}
+
+// Databases under the data directory, locked against writes while
+// archived
+var stateDatabases = []string{dataBorrowers, dataHistory}
+
+// StateManifest describes the state in a state snapshot.
+type StateManifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"createdAt"`
+	States    map[string]int `json:"states"`
+	Files     []string       `json:"files"`
+}
+
+// SnapshotState archives the contents of the data directory to w as a
+// gzipped tarball. Its databases are locked against writes for the
+// duration so their files do not change while archived; writes of a
+// running bot wait for the lock, and fail if the snapshot outlasts the
+// busy timeout.
+func SnapshotState(cfg *Config, w io.Writer) (*StateManifest, error) {
+	if cfg.DataDir == "" {
+		return nil, fmt.Errorf("%w: DATA_DIR cannot be empty", ErrInvalidConfig)
+	}
+	release, err := lockDatabases(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	manifest := &StateManifest{Version: snapshotVersion, CreatedAt: time.Now().UTC(), States: make(map[string]int)}
+	err = filepath.Walk(cfg.DataDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(cfg.DataDir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if version, ok := stateVersions[name]; ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if manifest.States[name], err = store.Decode(data, version, nil); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		manifest.Files = append(manifest.Files, name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read data directory: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode snapshot manifest: %w", err)
+	}
+	if err := writeTarEntry(tw, snapshotManifest, int64(len(data)), strings.NewReader(string(data))); err != nil {
+		return nil, err
+	}
+	for _, name := range manifest.Files {
+		if err := archiveFile(tw, cfg.DataDir, name); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("cannot write snapshot: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("cannot write snapshot: %w", err)
+	}
+	return manifest, nil
+}
+
+func archiveFile(tw *tar.Writer, dir, name string) error {
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", name, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", name, err)
+	}
+	return writeTarEntry(tw, name, info.Size(), file)
+}
+
+func writeTarEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	header := &tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: time.Now(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("cannot write snapshot: %w", err)
+	}
+	if _, err := io.CopyN(tw, r, size); err != nil {
+		return fmt.Errorf("cannot write snapshot of %s: %w", name, err)
+	}
+	return nil
+}
+
+// lockDatabases locks the databases under dir, if any, against writes
+// until the returned function is called.
+func lockDatabases(dir string) (func(), error) {
+	var closers []func() error
+	release := func() {
+		for _, closeDB := range closers {
+			closeDB()
+		}
+	}
+	for _, name := range stateDatabases {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		closeDB, err := lockSQLite(path)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("cannot lock %s: %w", name, err)
+		}
+		closers = append(closers, closeDB)
+	}
+	return release, nil
+}
+
+// RestoreState unpacks a snapshot from r into the data directory,
+// refusing to replace a non-empty one unless forced, in which case it
+// is moved aside. The snapshot is unpacked and validated next to the
+// data directory first, so a failed restore leaves it untouched. The
+// restored state is reconciled once connected, as on any start.
+func RestoreState(cfg *Config, r io.Reader, force bool) (*StateManifest, error) {
+	if cfg.DataDir == "" {
+		return nil, fmt.Errorf("%w: DATA_DIR cannot be empty", ErrInvalidConfig)
+	}
+	dir := filepath.Clean(cfg.DataDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("cannot read data directory: %w", err)
+	}
+	if len(entries) > 0 && !force {
+		return nil, fmt.Errorf("data directory %s is not empty; force to replace it", dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create data directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("cannot unpack snapshot: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	manifest, err := unpackSnapshot(r, tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) > 0 {
+		if err := os.Rename(dir, fmt.Sprintf("%s.replaced-%d", dir, time.Now().UnixNano())); err != nil {
+			return nil, fmt.Errorf("cannot move data directory aside: %w", err)
+		}
+	} else if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("cannot replace data directory: %w", err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, fmt.Errorf("cannot replace data directory: %w", err)
+	}
+	return manifest, nil
+}
+
+// unpackSnapshot unpacks a snapshot into dir and validates it against
+// its manifest.
+func unpackSnapshot(r io.Reader, dir string) (*StateManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	var manifest *StateManifest
+	unpacked := make(map[string]bool)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read snapshot: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected snapshot entry %s", header.Name)
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("snapshot entry %s escapes the data directory", header.Name)
+		}
+
+		if manifest == nil {
+			if header.Name != snapshotManifest {
+				return nil, errors.New("snapshot does not start with a manifest")
+			}
+			manifest = new(StateManifest)
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("cannot decode snapshot manifest: %w", err)
+			}
+			if manifest.Version > snapshotVersion {
+				return nil, fmt.Errorf("snapshot version %d, expected up to %d", manifest.Version, snapshotVersion)
+			}
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("cannot unpack %s: %w", header.Name, err)
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unpack %s: %w", header.Name, err)
+		}
+		_, err = io.Copy(file, tr)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot unpack %s: %w", header.Name, err)
+		}
+		unpacked[filepath.ToSlash(name)] = true
+	}
+	if manifest == nil {
+		return nil, errors.New("snapshot is empty")
+	}
+
+	for _, name := range manifest.Files {
+		if !unpacked[name] {
+			return nil, fmt.Errorf("snapshot is missing %s", name)
+		}
+	}
+	for name, version := range stateVersions {
+		if !unpacked[name] {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", name, err)
+		}
+		read, err := store.Decode(data, version, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if read != manifest.States[name] {
+			return nil, fmt.Errorf("%s is version %d, the manifest lists %d", name, read, manifest.States[name])
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,176 @@
+package liquidatoor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/exp"
+)
+
+const DefaultStrategy = "default"
+
+// Strategy turns the liquidation candidates of a block into an ordered
+// list of liquidation plans.
+type Strategy interface {
+	Name() string
+	Plan(ctx context.Context, input *StrategyInput) ([]LiquidationPlan, error)
+}
+
+type StrategyInput struct {
+	Snapshot   *Snapshot
+	Candidates []AccountPositions
+	Inventory  Inventory
+}
+
+// LiquidationPlan repays part of a borrow in exchange for collateral.
+type LiquidationPlan struct {
+	Borrower         common.Address
+	BorrowMarket     common.Address
+	CollateralMarket common.Address
+	// Denominated in the underlying of the borrow market
+	RepayAmount *big.Int
+	// Values in the oracle's unit of account scaled by 1e18
+	RepayValue *big.Int
+	SeizeValue *big.Int
+}
+
+// MarshalJSON encodes the amounts and values of the plan as decimal
+// strings.
+func (p LiquidationPlan) MarshalJSON() ([]byte, error) {
+	type plan LiquidationPlan
+	return json.Marshal(struct {
+		plan
+		RepayAmount *decimalInt
+		RepayValue  *decimalInt
+		SeizeValue  *decimalInt
+	}{plan(p), decimal(p.RepayAmount), decimal(p.RepayValue), decimal(p.SeizeValue)})
+}
+
+func (p *LiquidationPlan) UnmarshalJSON(data []byte) error {
+	type plan LiquidationPlan
+	v := struct {
+		*plan
+		RepayAmount *decimalInt
+		RepayValue  *decimalInt
+		SeizeValue  *decimalInt
+	}{plan: (*plan)(p)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	p.RepayAmount, p.RepayValue, p.SeizeValue = v.RepayAmount.int(), v.RepayValue.int(), v.SeizeValue.int()
+	return nil
+}
+
+func (p LiquidationPlan) String() string {
+	return fmt.Sprintf("liquidate %s repaying %v in market %s for collateral in market %s (repay value %v, seize value %v)",
+		p.Borrower, p.RepayAmount, p.BorrowMarket, p.CollateralMarket, p.RepayValue, p.SeizeValue)
+}
+
+// defaultStrategy takes candidates by decreasing shortfall and repays
+// as much as possible of the largest borrow against the largest
+// collateral of each.
+type defaultStrategy struct{}
+
+func NewDefaultStrategy() Strategy {
+	return defaultStrategy{}
+}
+
+func (defaultStrategy) Name() string {
+	return DefaultStrategy
+}
+
+func (defaultStrategy) Plan(_ context.Context, input *StrategyInput) ([]LiquidationPlan, error) {
+	if s := input.Snapshot; s.CloseFactor == nil || s.LiquidationIncentive == nil || !IsPositive(s.LiquidationIncentive) {
+		return nil, fmt.Errorf("cannot plan without liquidation params: %w", ErrStaleData)
+	}
+	candidates := make([]AccountPositions, len(input.Candidates))
+	copy(candidates, input.Candidates)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return GT(candidates[i].Shortfall, candidates[j].Shortfall)
+	})
+
+	plans := make([]LiquidationPlan, 0, len(candidates))
+	// Values are only copied out of the scratch value when they become
+	// the largest borrow or collateral of a candidate
+	scratch := getScratch()
+	defer putScratch(scratch)
+	for _, candidate := range candidates {
+		borrow, collateral := choosePair(input.Snapshot, candidate, nil, scratch)
+		if borrow == nil || collateral == nil || IsZero(borrow.value) || IsZero(collateral.value) {
+			continue
+		}
+		// Seize no more than the market can pay out
+		if m := collateral.market; candidate.Illiquid && m.Cash != nil {
+			if cash := m.Value(m.Cash); GT(collateral.value, cash) {
+				collateral.value = cash
+			}
+		}
+
+		plan := planLiquidation(input.Snapshot, candidate.Account, borrow, collateral)
+		if IsZero(plan.RepayAmount) {
+			continue
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+type positionValue struct {
+	market MarketSnapshot
+	amount *big.Int
+	value  *big.Int
+}
+
+// choosePair returns the largest borrow of account and its largest
+// collateral that can be seized, outside the markets in skip, at the
+// prices of s. Repaying the largest borrow against the largest
+// collateral repays, and so earns as incentive, the most. Values are
+// only copied out of scratch when they become the largest.
+func choosePair(s *Snapshot, account AccountPositions, skip map[common.Address]bool, scratch *big.Int) (borrow, collateral *positionValue) {
+	for _, position := range account.Positions {
+		market, ok := s.Markets[position.Market]
+		if !ok || IsZero(market.Price) {
+			continue
+		}
+		if borrowed := valueInto(scratch, market.Price, orZero(position.Borrowed)); borrow == nil || GT(borrowed, borrow.value) {
+			borrow = &positionValue{market: market, amount: position.Borrowed, value: new(big.Int).Set(borrowed)}
+		}
+		// Collateral that cannot be seized is skipped
+		if market.SeizePaused || skip[market.Address] {
+			continue
+		}
+		if supplied := valueInto(scratch, market.Price, orZero(position.Supplied)); collateral == nil || GT(supplied, collateral.value) {
+			collateral = &positionValue{market: market, amount: position.Supplied, value: new(big.Int).Set(supplied)}
+		}
+	}
+	return borrow, collateral
+}
+
+// planLiquidation repays up to the close factor of the borrow, bounded
+// by the collateral available to seize. Amounts and values round as
+// repayAmountOf documents.
+func planLiquidation(s *Snapshot, account common.Address, borrow, collateral *positionValue) LiquidationPlan {
+	repayValue := exp.MulScalarTruncate(new(big.Int), s.CloseFactor, borrow.value)
+
+	// Seizing is worth the repay value times the incentive
+	maxRepayValue := exp.DivScalarByExpTruncate(getScratch(), collateral.value, s.LiquidationIncentive)
+	if GT(repayValue, maxRepayValue) {
+		repayValue.Set(maxRepayValue)
+	}
+	putScratch(maxRepayValue)
+
+	repayAmount := repayAmountOf(borrow.market, repayValue)
+	return LiquidationPlan{
+		Borrower:         account,
+		BorrowMarket:     borrow.market.Address,
+		CollateralMarket: collateral.market.Address,
+		RepayAmount:      repayAmount,
+		RepayValue:       costOf(borrow.market, repayAmount),
+		SeizeValue:       seizeValueOf(s, borrow.market, repayAmount),
+	}
+}
@@ -0,0 +1,188 @@
+package liquidatoor
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/exp"
+)
+
+// randomInput returns a snapshot of markets of random decimals and
+// prices, some paused or unpriced, and accounts holding random
+// positions in them.
+func randomInput(r *rand.Rand, markets, accounts int) *StrategyInput {
+	s := &Snapshot{
+		Block:                big.NewInt(1),
+		CloseFactor:          big.NewInt(5e17),
+		LiquidationIncentive: big.NewInt(108e16),
+		Markets:              make(map[common.Address]MarketSnapshot, markets),
+	}
+	addresses := make([]common.Address, markets)
+	for i := range addresses {
+		addresses[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+		decimals := []uint8{6, 8, 18}[r.Intn(3)]
+		m := MarketSnapshot{
+			Address:     addresses[i],
+			Decimals:    decimals,
+			Price:       randomInt(r, 36-int(decimals)),
+			SeizePaused: r.Intn(10) == 0,
+			Cash:        randomInt(r, 30),
+		}
+		if r.Intn(20) == 0 {
+			m.Price = new(big.Int)
+		}
+		s.Markets[addresses[i]] = m
+	}
+	input := &StrategyInput{Snapshot: s, Candidates: make([]AccountPositions, accounts)}
+	for i := range input.Candidates {
+		c := AccountPositions{
+			Account:   common.BigToAddress(big.NewInt(int64(1000 + i))),
+			Shortfall: randomInt(r, 24),
+			Illiquid:  r.Intn(5) == 0,
+		}
+		for _, address := range addresses {
+			if r.Intn(2) == 0 {
+				continue
+			}
+			position := Position{Market: address, Supplied: randomInt(r, 30), Borrowed: randomInt(r, 30)}
+			// Missing amounts count as zero
+			if r.Intn(10) == 0 {
+				position.Borrowed = nil
+			}
+			c.Positions = append(c.Positions, position)
+		}
+		input.Candidates[i] = c
+	}
+	return input
+}
+
+// randomInt returns an integer of up to digits decimal digits, zero
+// one time in ten.
+func randomInt(r *rand.Rand, digits int) *big.Int {
+	if r.Intn(10) == 0 {
+		return new(big.Int)
+	}
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(1+r.Intn(digits))), nil)
+	return new(big.Int).Rand(r, max)
+}
+
+// referencePlan is the default strategy valuing every position in
+// freshly allocated integers, as it did before scratch values.
+func referencePlan(input *StrategyInput) []LiquidationPlan {
+	s := input.Snapshot
+	candidates := make([]AccountPositions, len(input.Candidates))
+	copy(candidates, input.Candidates)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Shortfall.Cmp(candidates[j].Shortfall) == 1
+	})
+	plans := make([]LiquidationPlan, 0, len(candidates))
+	for _, candidate := range candidates {
+		var borrow, collateral *positionValue
+		for _, position := range candidate.Positions {
+			market, ok := s.Markets[position.Market]
+			if !ok || market.Price.Sign() == 0 {
+				continue
+			}
+			if borrowed := market.Value(orZero(position.Borrowed)); borrow == nil || borrowed.Cmp(borrow.value) == 1 {
+				borrow = &positionValue{market: market, amount: position.Borrowed, value: borrowed}
+			}
+			if supplied := market.Value(orZero(position.Supplied)); !market.SeizePaused && (collateral == nil || supplied.Cmp(collateral.value) == 1) {
+				collateral = &positionValue{market: market, amount: position.Supplied, value: supplied}
+			}
+		}
+		if borrow == nil || collateral == nil || borrow.value.Sign() == 0 || collateral.value.Sign() == 0 {
+			continue
+		}
+		if m := collateral.market; candidate.Illiquid && m.Cash != nil {
+			if cash := m.Value(m.Cash); collateral.value.Cmp(cash) == 1 {
+				collateral.value = cash
+			}
+		}
+
+		repayValue := new(big.Int).Mul(borrow.value, s.CloseFactor)
+		repayValue.Div(repayValue, expScale)
+		maxRepayValue := new(big.Int).Mul(collateral.value, expScale)
+		maxRepayValue.Div(maxRepayValue, s.LiquidationIncentive)
+		if repayValue.Cmp(maxRepayValue) == 1 {
+			repayValue = maxRepayValue
+		}
+		repayAmount := new(big.Int).Mul(repayValue, expScale)
+		repayAmount.Div(repayAmount, borrow.market.Price)
+		if repayAmount.Sign() == 0 {
+			continue
+		}
+		plans = append(plans, LiquidationPlan{
+			Borrower:         candidate.Account,
+			BorrowMarket:     borrow.market.Address,
+			CollateralMarket: collateral.market.Address,
+			RepayAmount:      repayAmount,
+			RepayValue:       exp.MulScalarCeil(new(big.Int), borrow.market.Price, repayAmount),
+			SeizeValue:       exp.MulScalarTruncate(new(big.Int), s.LiquidationIncentive, borrow.market.Value(repayAmount)),
+		})
+	}
+	return plans
+}
+
+// equalPlans compares plans by value, as big.Ints of equal value may
+// differ in their internal representation.
+func equalPlans(a, b []LiquidationPlan) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+func FuzzPlanMatchesReference(f *testing.F) {
+	for _, seed := range []int64{0, 1, 2, 42, 1 << 40} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		input := randomInput(rand.New(rand.NewSource(seed)), 6, 50)
+		plans, err := defaultStrategy{}.Plan(context.Background(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := referencePlan(input); !equalPlans(plans, expected) {
+			t.Fatalf("pooled plans differ from the reference:\n%v\n%v", plans, expected)
+		}
+	})
+}
+
+// TestPlanDoesNotAliasScratch plans twice in a row and checks the
+// first plans are not overwritten by scratch values reused in the
+// second.
+func TestPlanDoesNotAliasScratch(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	first, second := randomInput(r, 6, 100), randomInput(r, 6, 100)
+	plans, err := defaultStrategy{}.Plan(context.Background(), first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (defaultStrategy{}).Plan(context.Background(), second); err != nil {
+		t.Fatal(err)
+	}
+	if expected := referencePlan(first); !equalPlans(plans, expected) {
+		t.Fatal("plans changed after planning again")
+	}
+}
+
+func BenchmarkValuation10k(b *testing.B) {
+	input := randomInput(rand.New(rand.NewSource(1)), 20, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := (defaultStrategy{}).Plan(context.Background(), input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
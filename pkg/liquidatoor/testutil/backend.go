@@ -0,0 +1,114 @@
+// Package testutil runs the liquidatoor against an in-memory chain
+// with mock pool contracts.
+package testutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+)
+
+// ChainID is the chain ID of the simulated backend.
+var ChainID = big.NewInt(1337)
+
+const gasLimit = 30_000_000
+
+// Backend is a simulated chain with a single funded account used to
+// deploy fixtures and send liquidations.
+type Backend struct {
+	*backends.SimulatedBackend
+
+	Key  *ecdsa.PrivateKey
+	Opts *bind.TransactOpts
+}
+
+func NewBackend() (*Backend, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate key: %w", err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(key, ChainID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create transactor: %w", err)
+	}
+
+	balance := new(big.Int).Mul(big.NewInt(1000), expScale)
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{
+		opts.From: {Balance: balance},
+	}, gasLimit)
+
+	return &Backend{SimulatedBackend: sim, Key: key, Opts: opts}, nil
+}
+
+// Mine commits n empty blocks.
+func (b *Backend) Mine(n int) {
+	for i := 0; i < n; i++ {
+		b.Commit()
+	}
+}
+
+// Receipt mines the pending block and returns the receipt of tx.
+func (b *Backend) Receipt(tx *types.Transaction) (*types.Receipt, error) {
+	b.Commit()
+	receipt, err := b.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("cannot get receipt of %s: %w", tx.Hash(), err)
+	}
+	return receipt, nil
+}
+
+// wait mines tx and fails if it reverted.
+func (b *Backend) wait(tx *types.Transaction) error {
+	receipt, err := b.Receipt(tx)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("transaction %s reverted", tx.Hash())
+	}
+	return nil
+}
+
+// Balance returns the native balance of account at the latest block.
+func (b *Backend) Balance(account common.Address) (*big.Int, error) {
+	return b.BalanceAt(context.Background(), account, nil)
+}
+
+// Accounts returns n distinct addresses to hold positions.
+func Accounts(n int) []common.Address {
+	accounts := make([]common.Address, n)
+	for i := range accounts {
+		accounts[i] = common.BigToAddress(big.NewInt(int64(0x1000 + i)))
+	}
+	return accounts
+}
+
+// Config returns a configuration using the funded account as the
+// liquidatoor wallet.
+func (b *Backend) Config() *liquidatoor.Config {
+	return &liquidatoor.Config{
+		PrivateKey:            hex.EncodeToString(crypto.FromECDSA(b.Key)),
+		ExplorerURL:           "http://localhost",
+		BorrowerCacheInterval: time.Second,
+	}
+}
+
+// Connect connects the liquidatoor to the backend.
+func (b *Backend) Connect(ctx context.Context, cfg *liquidatoor.Config, opts ...liquidatoor.Option) (*liquidatoor.Connection, error) {
+	return liquidatoor.ConnectBackend(ctx, cfg, b, liquidatoor.BackendOptions{
+		ChainID: ChainID,
+		Batcher: &Batcher{Caller: b},
+	}, opts...)
+}
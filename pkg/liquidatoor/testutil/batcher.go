@@ -0,0 +1,37 @@
+package testutil
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+)
+
+// Batcher executes batches one call at a time against any backend,
+// so no multicall contract needs to be deployed.
+type Batcher struct {
+	Caller bind.ContractCaller
+}
+
+func (b *Batcher) Aggregate(opts *bind.CallOpts, calls []abis.MulticallCall) ([]liquidatoor.CallResult, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	results := make([]liquidatoor.CallResult, len(calls))
+	for i, call := range calls {
+		target := call.Target
+		data, err := b.Caller.CallContract(ctx, ethereum.CallMsg{
+			To:   &target,
+			Data: call.CallData,
+		}, opts.BlockNumber)
+		results[i] = liquidatoor.CallResult{Success: err == nil, ReturnData: data}
+	}
+	return results, nil
+}
+
+var _ liquidatoor.CallBatcher = (*Batcher)(nil)
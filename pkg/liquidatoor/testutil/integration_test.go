@@ -0,0 +1,403 @@
+package testutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor"
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/testutil"
+)
+
+var e18 = big.NewInt(1e18)
+
+func ether(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), e18)
+}
+
+// fixture is a pool with an ETH and a DAI market, and an account
+// supplying DAI and borrowing ETH, which the wallet can repay.
+type fixture struct {
+	backend *testutil.Backend
+	pool    *testutil.Pool
+	account common.Address
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	b, err := testutil.NewBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	p, err := b.DeployPool(
+		testutil.MarketConfig{Symbol: "ETH", Decimals: 18, Native: true, CollateralFactor: big.NewInt(75e16), Price: ether(2000)},
+		testutil.MarketConfig{Symbol: "DAI", Decimals: 18, CollateralFactor: big.NewInt(75e16), Price: new(big.Int).Set(e18)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := testutil.Accounts(1)[0]
+	if err := p.Supply(account, p.Markets[1], ether(2000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Borrow(account, p.Markets[0], new(big.Int).Div(e18, big.NewInt(2))); err != nil {
+		t.Fatal(err)
+	}
+	return &fixture{backend: b, pool: p, account: account}
+}
+
+// connect connects to the pool with cfg customized by configure.
+func (f *fixture) connect(ctx context.Context, t *testing.T, configure func(*liquidatoor.Config)) *liquidatoor.Liquidatoor {
+	t.Helper()
+	cfg := f.backend.Config()
+	cfg.Comptrollers = []common.Address{f.pool.Comptroller.Address}
+	if configure != nil {
+		configure(cfg)
+	}
+	conn, err := f.backend.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	l, err := conn.NewLiquidatoor(ctx, f.pool.Comptroller.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Start(ctx)
+	return l
+}
+
+// process mines a block and processes it.
+func (f *fixture) process(ctx context.Context, t *testing.T, l *liquidatoor.Liquidatoor) *liquidatoor.BlockResult {
+	t.Helper()
+	f.backend.Commit()
+	header, err := f.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := l.ProcessBlock(ctx, header)
+	if err != nil {
+		t.Fatalf("cannot process block %v: %v", header.Number, err)
+	}
+	return result
+}
+
+func TestShortfallIsDetected(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	f := newFixture(t)
+	// The price moves far from its recent average
+	l := f.connect(ctx, t, func(cfg *liquidatoor.Config) { cfg.MaxPriceDeviation = ether(1) })
+
+	result := f.process(ctx, t, l)
+	if result.Borrowers != 1 || result.Underwater != 0 {
+		t.Fatalf("expected 1 healthy borrower, got %d borrowers and %d underwater", result.Borrowers, result.Underwater)
+	}
+
+	// 2000 DAI at 75% cover 0.5 ETH up to 3000 DAI per ETH
+	if err := f.pool.SetPrice(f.pool.Markets[0], ether(3500)); err != nil {
+		t.Fatal(err)
+	}
+	_, shortfall := f.pool.Liquidity(f.account)
+	result = f.process(ctx, t, l)
+	if result.Underwater != 1 || len(result.Candidates) != 1 {
+		t.Fatalf("expected 1 underwater candidate, got %d underwater and %d candidates", result.Underwater, len(result.Candidates))
+	}
+	c := result.Candidates[0]
+	if c.Account != f.account || c.Shortfall.Cmp(shortfall) != 0 {
+		t.Fatalf("expected account %s underwater by %v, got %s underwater by %v", f.account, shortfall, c.Account, c.Shortfall)
+	}
+	if c.Err != nil {
+		t.Fatalf("expected a liquidatable candidate, got %v", c.Err)
+	}
+	if c.Plan == nil || c.Plan.BorrowMarket != f.pool.Markets[0].CToken.Address || c.Plan.CollateralMarket != f.pool.Markets[1].CToken.Address {
+		t.Fatalf("expected a plan repaying ETH for DAI, got %v", c.Plan)
+	}
+	// Without an executor, nothing is executed
+	if len(result.Executions) != 0 {
+		t.Fatalf("expected no executions, got %d", len(result.Executions))
+	}
+}
+
+func TestLiquidationIsMined(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	f := newFixture(t)
+	l := f.connect(ctx, t, func(cfg *liquidatoor.Config) { cfg.ExecuteLiquidations = true })
+	l.SetExecution(liquidatoor.ExecuteInline)
+	if err := f.pool.SetPrice(f.pool.Markets[0], ether(3500)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine the liquidation while the block waits for it
+	mined := make(chan struct{})
+	defer close(mined)
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-mined:
+				return
+			case <-ticker.C:
+				f.backend.Commit()
+			}
+		}
+	}()
+
+	result := f.process(ctx, t, l)
+	if len(result.Executions) != 1 {
+		t.Fatalf("expected 1 execution, got %d: %+v", len(result.Executions), result.Candidates)
+	}
+	execution := result.Executions[0]
+	if execution.Err != nil {
+		t.Fatalf("liquidation failed: %v", execution.Err)
+	}
+	if execution.Account != f.account || execution.Outcome == nil || execution.Outcome.Tx == (common.Hash{}) {
+		t.Fatalf("expected a mined liquidation of %s, got %+v", f.account, execution)
+	}
+	receipt, err := f.backend.TransactionReceipt(ctx, execution.Outcome.Tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("liquidation %s reverted", execution.Outcome.Tx)
+	}
+	tx, _, err := f.backend.TransactionByHash(ctx, execution.Outcome.Tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *tx.To() != f.pool.Markets[0].CToken.Address || tx.Value().Sign() <= 0 {
+		t.Fatalf("expected liquidateBorrow on the ETH market with a value, got %v to %s", tx.Value(), tx.To())
+	}
+}
+
+func TestHealthyAccountIsNotLiquidated(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	f := newFixture(t)
+	executed := make(chan liquidatoor.Candidate, 1)
+	l := f.connect(ctx, t, func(cfg *liquidatoor.Config) {
+		cfg.Executor = liquidatoor.ExecutorFunc(func(_ context.Context, c liquidatoor.Candidate) (*liquidatoor.Outcome, error) {
+			executed <- c
+			return nil, nil
+		})
+	})
+	l.SetExecution(liquidatoor.ExecuteInline)
+
+	for i := 0; i < 3; i++ {
+		if result := f.process(ctx, t, l); result.Underwater != 0 {
+			t.Fatalf("expected no underwater accounts, got %d", result.Underwater)
+		}
+	}
+	select {
+	case c := <-executed:
+		t.Fatalf("healthy account %s was executed", c.Account)
+	default:
+	}
+}
+
+func TestScanFindsCandidate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	f := newFixture(t)
+	if err := f.pool.SetPrice(f.pool.Markets[0], ether(3500)); err != nil {
+		t.Fatal(err)
+	}
+	f.backend.Commit()
+	cfg := f.backend.Config()
+	cfg.Comptrollers = []common.Address{f.pool.Comptroller.Address}
+	// Candidates are only queued with an executor, which scans do not
+	// run without execute
+	cfg.Executor = liquidatoor.ExecutorFunc(func(context.Context, liquidatoor.Candidate) (*liquidatoor.Outcome, error) {
+		t.Fatal("candidate executed without execute")
+		return nil, nil
+	})
+	conn, err := f.backend.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	summary, err := conn.Scan(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Unscanned != 0 || summary.Candidates != 1 || len(summary.Pools[0].Candidates) != 1 || summary.Pools[0].Candidates[0].Account != f.account {
+		t.Fatalf("expected account %s to be the only candidate, got %+v", f.account, summary)
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generic struct {
+		Block interface{} `json:"block"`
+	}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		t.Fatal(err)
+	}
+	header, err := f.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block, ok := generic.Block.(string); !ok || block != header.Number.String() {
+		t.Fatalf("expected block %v encoded as a string, got %s", header.Number, data)
+	}
+}
+
+func TestLiquidateAccountOutlivesCall(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	f := newFixture(t)
+	path := filepath.Join(t.TempDir(), "pending.json")
+	l := f.connect(ctx, t, func(cfg *liquidatoor.Config) {
+		cfg.PendingTxPath = path
+		cfg.BlockTime = 50 * time.Millisecond
+	})
+	if err := f.pool.SetPrice(f.pool.Markets[0], ether(3500)); err != nil {
+		t.Fatal(err)
+	}
+	// Reads the start of the block LiquidateAccount plans against
+	f.process(ctx, t, l)
+
+	_, shortfall := f.pool.Liquidity(f.account)
+	call, cancelCall := context.WithCancel(ctx)
+	hash, err := l.LiquidateAccount(call, liquidatoor.Borrower{
+		Address:   f.account,
+		Assets:    []common.Address{f.pool.Markets[0].CToken.Address, f.pool.Markets[1].CToken.Address},
+		Shortfall: shortfall,
+	})
+	// The transaction is still watched once the call is done
+	cancelCall()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(strings.ToLower(string(data)), strings.ToLower(hash.Hex())) {
+		t.Fatalf("expected transaction %s pending, got %s, %v", hash, data, err)
+	}
+	// Mined after a watch interval, once a watch tied to the call would
+	// have returned
+	time.Sleep(200 * time.Millisecond)
+	f.backend.Commit()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(strings.ToLower(string(data)), strings.ToLower(hash.Hex())) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected transaction %s forgotten once mined", hash)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	receipt, err := f.backend.TransactionReceipt(ctx, hash)
+	if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("expected liquidation %s mined, got %v, %v", hash, receipt, err)
+	}
+}
+
+func TestConnectionLiquidate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	f := newFixture(t)
+	cfg := f.backend.Config()
+	cfg.Comptrollers = []common.Address{f.pool.Comptroller.Address}
+	cfg.BlockTime = 50 * time.Millisecond
+	conn, err := f.backend.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	f.backend.Commit()
+
+	if _, err := conn.Liquidate(ctx, f.pool.Comptroller.Address, f.account); err == nil || !strings.Contains(err.Error(), "not underwater") {
+		t.Fatalf("expected a healthy account refused, got %v", err)
+	}
+
+	if err := f.pool.SetPrice(f.pool.Markets[0], ether(3500)); err != nil {
+		t.Fatal(err)
+	}
+	mined := make(chan struct{})
+	defer close(mined)
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-mined:
+				return
+			case <-ticker.C:
+				f.backend.Commit()
+			}
+		}
+	}()
+	receipt, err := conn.Liquidate(ctx, f.pool.Comptroller.Address, f.account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, _, err := f.backend.TransactionByHash(ctx, receipt.TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *tx.To() != f.pool.Markets[0].CToken.Address {
+		t.Fatalf("expected liquidateBorrow on the ETH market, got a transaction to %s", tx.To())
+	}
+}
+
+func TestPoolWithoutBorrowersIsReady(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	b, err := testutil.NewBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	p, err := b.DeployPool(
+		testutil.MarketConfig{Symbol: "ETH", Decimals: 18, Native: true, CollateralFactor: big.NewInt(75e16), Price: ether(2000)},
+		testutil.MarketConfig{Symbol: "DAI", Decimals: 18, CollateralFactor: big.NewInt(75e16), Price: new(big.Int).Set(e18)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := b.Config()
+	cfg.Comptrollers = []common.Address{p.Comptroller.Address}
+	conn, err := b.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	l, err := conn.NewLiquidatoor(ctx, p.Comptroller.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Start(ctx)
+
+	// The primed cache is empty, and checking it completes
+	b.Commit()
+	header, err := b.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := l.ProcessBlock(ctx, header)
+	if err != nil {
+		t.Fatalf("expected the check to complete, got %v", err)
+	}
+	if result.Borrowers != 0 {
+		t.Fatalf("expected no borrowers, got %d", result.Borrowers)
+	}
+	if !conn.Ready() {
+		t.Fatal("expected the pool ready")
+	}
+}
@@ -0,0 +1,192 @@
+package testutil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mockSetSelector marks calls programming a response. The call data
+// is the selector followed by the 32-byte response key and the raw
+// response.
+var mockSetSelector = []byte{0xff, 0xff, 0xff, 0xff}
+
+// mockRuntime is the code of a contract answering any call with a
+// programmed response. Responses are looked up by the hash of the full
+// call data first and the hash of the selector second; calls without
+// a response revert, like calls to missing methods do.
+//
+// A response of n bytes for key k is stored as n+1 at slot k followed
+// by its words at slots k+1 onwards.
+var mockRuntime = assemble(
+	// Dispatch on the selector
+	push1(0), vm.CALLDATALOAD, push1(0xe0), vm.SHR,
+	vm.PUSH4, op(0xff), op(0xff), op(0xff), op(0xff), vm.EQ, pushLabel("set"), vm.JUMPI,
+
+	// Look up the response of the full call data
+	vm.CALLDATASIZE, push1(0), push1(0), vm.CALLDATACOPY,
+	vm.CALLDATASIZE, push1(0), vm.KECCAK256,
+	vm.DUP1, vm.SLOAD, vm.DUP1, pushLabel("found"), vm.JUMPI,
+	vm.POP, vm.POP,
+
+	// Look up the response of the selector
+	push1(4), push1(0), vm.KECCAK256,
+	vm.DUP1, vm.SLOAD, vm.DUP1, pushLabel("found"), vm.JUMPI,
+	push1(0), vm.DUP1, vm.REVERT,
+
+	// Copy the response words to memory and return them
+	label("found"),
+	push1(1), vm.SWAP1, vm.SUB,
+	push1(0),
+	label("load"),
+	vm.DUP2, vm.DUP2, push1(5), vm.SHL, vm.LT, vm.ISZERO, pushLabel("return"), vm.JUMPI,
+	vm.DUP1, vm.DUP4, vm.ADD, push1(1), vm.ADD, vm.SLOAD,
+	vm.DUP2, push1(5), vm.SHL, vm.MSTORE,
+	push1(1), vm.ADD, pushLabel("load"), vm.JUMP,
+	label("return"),
+	vm.POP, push1(0), vm.RETURN,
+
+	// Store a response
+	label("set"),
+	push1(4), vm.CALLDATALOAD,
+	push1(36), vm.CALLDATASIZE, vm.SUB,
+	vm.DUP1, push1(1), vm.ADD, vm.DUP3, vm.SSTORE,
+	vm.DUP1, push1(36), push1(0), vm.CALLDATACOPY,
+	push1(0),
+	label("store"),
+	vm.DUP2, vm.DUP2, push1(5), vm.SHL, vm.LT, vm.ISZERO, pushLabel("stored"), vm.JUMPI,
+	vm.DUP1, push1(5), vm.SHL, vm.MLOAD,
+	vm.DUP2, vm.DUP5, vm.ADD, push1(1), vm.ADD, vm.SSTORE,
+	push1(1), vm.ADD, pushLabel("store"), vm.JUMP,
+	label("stored"),
+	vm.STOP,
+)
+
+// mockCode returns the creation code of the mock contract.
+func mockCode() []byte {
+	size := len(mockRuntime)
+	init := assemble(
+		vm.PUSH2, op(byte(size>>8)), op(byte(size)), vm.DUP1,
+		vm.PUSH2, op(0), op(13), push1(0), vm.CODECOPY,
+		push1(0), vm.RETURN,
+	)
+	return append(init, mockRuntime...)
+}
+
+// Mock is a deployed contract answering calls with programmed
+// responses.
+type Mock struct {
+	Address common.Address
+	backend *Backend
+}
+
+// DeployMock deploys a mock contract without any responses.
+func (b *Backend) DeployMock() (*Mock, error) {
+	address, tx, _, err := bind.DeployContract(b.Opts, abi.ABI{}, mockCode(), b)
+	if err != nil {
+		return nil, fmt.Errorf("cannot deploy mock: %w", err)
+	}
+	if err := b.wait(tx); err != nil {
+		return nil, fmt.Errorf("cannot deploy mock: %w", err)
+	}
+	return &Mock{Address: address, backend: b}, nil
+}
+
+// Returns programs the response of method when called with args.
+func (m *Mock) Returns(method abi.Method, args []interface{}, results ...interface{}) error {
+	inputs, err := method.Inputs.Pack(args...)
+	if err != nil {
+		return fmt.Errorf("cannot pack %s inputs: %w", method.Name, err)
+	}
+	return m.set(append(method.ID[:4:4], inputs...), method, results)
+}
+
+// ReturnsAny programs the response of method regardless of the
+// arguments. Responses programmed through Returns take precedence.
+func (m *Mock) ReturnsAny(method abi.Method, results ...interface{}) error {
+	return m.set(method.ID[:4], method, results)
+}
+
+func (m *Mock) set(callData []byte, method abi.Method, results []interface{}) error {
+	output, err := method.Outputs.Pack(results...)
+	if err != nil {
+		return fmt.Errorf("cannot pack %s outputs: %w", method.Name, err)
+	}
+
+	data := append([]byte{}, mockSetSelector...)
+	data = append(data, crypto.Keccak256(callData)...)
+	data = append(data, output...)
+
+	contract := bind.NewBoundContract(m.Address, abi.ABI{}, m.backend, m.backend, m.backend)
+	tx, err := contract.RawTransact(m.backend.Opts, data)
+	if err != nil {
+		return fmt.Errorf("cannot program %s response: %w", method.Name, err)
+	}
+	return m.backend.wait(tx)
+}
+
+// Minimal assembler resolving jump labels to two-byte pushes.
+
+type asmLabel string
+
+type asmPushLabel string
+
+func op(b byte) vm.OpCode { return vm.OpCode(b) }
+
+func label(name string) asmLabel { return asmLabel(name) }
+
+func pushLabel(name string) asmPushLabel { return asmPushLabel(name) }
+
+func push1(v byte) []vm.OpCode { return []vm.OpCode{vm.PUSH1, op(v)} }
+
+func assemble(program ...interface{}) []byte {
+	labels := make(map[asmLabel]int)
+	pc := 0
+	for _, item := range program {
+		switch item := item.(type) {
+		case vm.OpCode, int:
+			// DUP and SWAP opcodes are untyped constants
+			pc++
+		case []vm.OpCode:
+			pc += len(item)
+		case asmLabel:
+			labels[item] = pc
+			pc++
+		case asmPushLabel:
+			pc += 3
+		}
+	}
+
+	code := make([]byte, 0, pc)
+	for _, item := range program {
+		switch item := item.(type) {
+		case vm.OpCode:
+			code = append(code, byte(item))
+		case int:
+			code = append(code, byte(item))
+		case []vm.OpCode:
+			for _, o := range item {
+				code = append(code, byte(o))
+			}
+		case asmLabel:
+			code = append(code, byte(vm.JUMPDEST))
+		case asmPushLabel:
+			dest, ok := labels[asmLabel(item)]
+			if !ok {
+				panic(fmt.Sprintf("unknown label %q", item))
+			}
+			code = append(code, byte(vm.PUSH2), 0, 0)
+			binary.BigEndian.PutUint16(code[len(code)-2:], uint16(dest))
+		}
+	}
+	return code
+}
+
+// expScale is the 1e18 mantissa scale used by the comptroller.
+var expScale = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
@@ -0,0 +1,315 @@
+package testutil
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// Defaults used by pool fixtures, matching common Compound settings.
+var (
+	CloseFactor          = big.NewInt(5e17)
+	LiquidationIncentive = big.NewInt(108e16)
+)
+
+type MarketConfig struct {
+	Symbol string
+	// Decimals of the underlying token
+	Decimals uint8
+	// Native token markets have no underlying
+	Native bool
+	// Collateral factor mantissa, eg., 75e16 for 75%
+	CollateralFactor *big.Int
+	// Oracle price scaled by 1e(36-decimals)
+	Price *big.Int
+}
+
+type Market struct {
+	MarketConfig
+
+	CToken *Mock
+	// Nil for native token markets
+	Underlying *Mock
+
+	totalBorrows *big.Int
+}
+
+// Pool is a mock comptroller, price oracle and set of markets. Account
+// positions are tracked off-chain and the resulting account liquidity
+// is programmed into the comptroller whenever they or prices change.
+type Pool struct {
+	backend *Backend
+
+	Comptroller *Mock
+	Oracle      *Mock
+	Markets     []*Market
+
+	borrowers []common.Address
+	positions map[common.Address]*position
+
+	comptrollerABI *abi.ABI
+	cTokenABI      *abi.ABI
+	cEtherABI      *abi.ABI
+	oracleABI      *abi.ABI
+}
+
+type position struct {
+	assets   []common.Address
+	supplied map[*Market]*big.Int
+	borrowed map[*Market]*big.Int
+}
+
+// DeployPool deploys a pool with the provided markets and no accounts.
+func (b *Backend) DeployPool(configs ...MarketConfig) (*Pool, error) {
+	p := &Pool{
+		backend:   b,
+		borrowers: make([]common.Address, 0),
+		positions: make(map[common.Address]*position),
+	}
+
+	var err error
+	if p.comptrollerABI, err = abis.ComptrollerMetaData.GetAbi(); err != nil {
+		return nil, fmt.Errorf("cannot get comptroller ABI: %w", err)
+	}
+	if p.cTokenABI, err = abis.CTokenMetaData.GetAbi(); err != nil {
+		return nil, fmt.Errorf("cannot get ctoken ABI: %w", err)
+	}
+	if p.cEtherABI, err = abis.CEtherMetaData.GetAbi(); err != nil {
+		return nil, fmt.Errorf("cannot get cether ABI: %w", err)
+	}
+	if p.oracleABI, err = abis.PriceOracleMetaData.GetAbi(); err != nil {
+		return nil, fmt.Errorf("cannot get price oracle ABI: %w", err)
+	}
+
+	if p.Comptroller, err = b.DeployMock(); err != nil {
+		return nil, err
+	}
+	if p.Oracle, err = b.DeployMock(); err != nil {
+		return nil, err
+	}
+
+	markets := make([]common.Address, 0, len(configs))
+	for _, config := range configs {
+		market, err := p.deployMarket(config)
+		if err != nil {
+			return nil, fmt.Errorf("cannot deploy market %s: %w", config.Symbol, err)
+		}
+		p.Markets = append(p.Markets, market)
+		markets = append(markets, market.CToken.Address)
+	}
+
+	comptroller := p.comptrollerABI.Methods
+	if err := p.Comptroller.ReturnsAny(comptroller["getAllMarkets"], markets); err != nil {
+		return nil, err
+	}
+	if err := p.Comptroller.ReturnsAny(comptroller["getAllBorrowers"], p.borrowers); err != nil {
+		return nil, err
+	}
+	if err := p.Comptroller.ReturnsAny(comptroller["isComptroller"], true); err != nil {
+		return nil, err
+	}
+	if err := p.Comptroller.ReturnsAny(comptroller["oracle"], p.Oracle.Address); err != nil {
+		return nil, err
+	}
+	if err := p.Comptroller.ReturnsAny(comptroller["admin"], b.Opts.From); err != nil {
+		return nil, err
+	}
+	if err := p.Comptroller.ReturnsAny(comptroller["closeFactorMantissa"], CloseFactor); err != nil {
+		return nil, err
+	}
+	if err := p.Comptroller.ReturnsAny(comptroller["liquidationIncentiveMantissa"], LiquidationIncentive); err != nil {
+		return nil, err
+	}
+	if err := p.Comptroller.ReturnsAny(comptroller["seizeGuardianPaused"], false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Pool) deployMarket(config MarketConfig) (*Market, error) {
+	cToken, err := p.backend.DeployMock()
+	if err != nil {
+		return nil, err
+	}
+	m := &Market{MarketConfig: config, CToken: cToken, totalBorrows: new(big.Int)}
+
+	methods := p.cTokenABI.Methods
+	if err := cToken.ReturnsAny(methods["symbol"], "c"+config.Symbol); err != nil {
+		return nil, err
+	}
+	if err := cToken.ReturnsAny(methods["decimals"], uint8(8)); err != nil {
+		return nil, err
+	}
+	if err := cToken.ReturnsAny(methods["totalBorrows"], m.totalBorrows); err != nil {
+		return nil, err
+	}
+	if err := cToken.ReturnsAny(methods["balanceOfUnderlying"], new(big.Int)); err != nil {
+		return nil, err
+	}
+	if err := cToken.ReturnsAny(methods["borrowBalanceStored"], new(big.Int)); err != nil {
+		return nil, err
+	}
+
+	if config.Native {
+		if err := cToken.ReturnsAny(p.cEtherABI.Methods["liquidateBorrow"]); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := cToken.ReturnsAny(methods["liquidateBorrow"], new(big.Int)); err != nil {
+			return nil, err
+		}
+		if m.Underlying, err = p.backend.DeployMock(); err != nil {
+			return nil, err
+		}
+		if err := cToken.ReturnsAny(methods["underlying"], m.Underlying.Address); err != nil {
+			return nil, err
+		}
+		if err := m.Underlying.ReturnsAny(methods["name"], config.Symbol); err != nil {
+			return nil, err
+		}
+		if err := m.Underlying.ReturnsAny(methods["symbol"], config.Symbol); err != nil {
+			return nil, err
+		}
+		if err := m.Underlying.ReturnsAny(methods["decimals"], config.Decimals); err != nil {
+			return nil, err
+		}
+		if err := m.Underlying.ReturnsAny(methods["balanceOf"], new(big.Int)); err != nil {
+			return nil, err
+		}
+		if err := m.Underlying.ReturnsAny(methods["approve"], true); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.Comptroller.Returns(p.comptrollerABI.Methods["markets"], []interface{}{cToken.Address}, true, config.CollateralFactor); err != nil {
+		return nil, err
+	}
+	if err := p.Oracle.Returns(p.oracleABI.Methods["getUnderlyingPrice"], []interface{}{cToken.Address}, config.Price); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Supply records amount of the market's underlying as supplied by
+// account.
+func (p *Pool) Supply(account common.Address, market *Market, amount *big.Int) error {
+	pos := p.position(account)
+	pos.add(market, pos.supplied, amount)
+
+	balance := new(big.Int).Set(pos.supplied[market])
+	if err := market.CToken.Returns(p.cTokenABI.Methods["balanceOfUnderlying"], []interface{}{account}, balance); err != nil {
+		return err
+	}
+	return p.updateAccount(account)
+}
+
+// Borrow records amount of the market's underlying as borrowed by
+// account, making account a pool borrower.
+func (p *Pool) Borrow(account common.Address, market *Market, amount *big.Int) error {
+	pos := p.position(account)
+	pos.add(market, pos.borrowed, amount)
+
+	methods := p.cTokenABI.Methods
+	balance := new(big.Int).Set(pos.borrowed[market])
+	if err := market.CToken.Returns(methods["borrowBalanceStored"], []interface{}{account}, balance); err != nil {
+		return err
+	}
+	market.totalBorrows.Add(market.totalBorrows, amount)
+	if err := market.CToken.ReturnsAny(methods["totalBorrows"], market.totalBorrows); err != nil {
+		return err
+	}
+
+	if !p.isBorrower(account) {
+		p.borrowers = append(p.borrowers, account)
+		if err := p.Comptroller.ReturnsAny(p.comptrollerABI.Methods["getAllBorrowers"], p.borrowers); err != nil {
+			return err
+		}
+	}
+	return p.updateAccount(account)
+}
+
+// SetPrice moves the oracle price of the market and updates the
+// liquidity of every account, eg., to force a shortfall.
+func (p *Pool) SetPrice(market *Market, price *big.Int) error {
+	market.Price = price
+	if err := p.Oracle.Returns(p.oracleABI.Methods["getUnderlyingPrice"], []interface{}{market.CToken.Address}, price); err != nil {
+		return err
+	}
+	for account := range p.positions {
+		if err := p.updateAccount(account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Liquidity returns the liquidity and shortfall of account, in the
+// oracle's unit of account scaled by 1e18.
+func (p *Pool) Liquidity(account common.Address) (*big.Int, *big.Int) {
+	pos := p.position(account)
+
+	collateral := new(big.Int)
+	for market, amount := range pos.supplied {
+		value := new(big.Int).Mul(amount, market.Price)
+		value.Mul(value, market.CollateralFactor)
+		value.Div(value, expScale)
+		collateral.Add(collateral, value.Div(value, expScale))
+	}
+	borrowed := new(big.Int)
+	for market, amount := range pos.borrowed {
+		value := new(big.Int).Mul(amount, market.Price)
+		borrowed.Add(borrowed, value.Div(value, expScale))
+	}
+
+	if collateral.Cmp(borrowed) >= 0 {
+		return collateral.Sub(collateral, borrowed), new(big.Int)
+	}
+	return new(big.Int), borrowed.Sub(borrowed, collateral)
+}
+
+func (p *Pool) updateAccount(account common.Address) error {
+	methods := p.comptrollerABI.Methods
+	if err := p.Comptroller.Returns(methods["getAssetsIn"], []interface{}{account}, p.position(account).assets); err != nil {
+		return err
+	}
+	liquidity, shortfall := p.Liquidity(account)
+	return p.Comptroller.Returns(methods["getAccountLiquidity"], []interface{}{account}, new(big.Int), liquidity, shortfall)
+}
+
+func (p *Pool) position(account common.Address) *position {
+	pos, ok := p.positions[account]
+	if !ok {
+		pos = &position{
+			assets:   make([]common.Address, 0),
+			supplied: make(map[*Market]*big.Int),
+			borrowed: make(map[*Market]*big.Int),
+		}
+		p.positions[account] = pos
+	}
+	return pos
+}
+
+func (p *Pool) isBorrower(account common.Address) bool {
+	for _, borrower := range p.borrowers {
+		if borrower == account {
+			return true
+		}
+	}
+	return false
+}
+
+func (pos *position) add(market *Market, balances map[*Market]*big.Int, amount *big.Int) {
+	if _, ok := pos.supplied[market]; !ok {
+		if _, ok := pos.borrowed[market]; !ok {
+			pos.assets = append(pos.assets, market.CToken.Address)
+		}
+	}
+	if _, ok := balances[market]; !ok {
+		balances[market] = new(big.Int)
+	}
+	balances[market].Add(balances[market], amount)
+}
@@ -0,0 +1,48 @@
+package liquidatoor
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// sendCall signs and sends the provided call from our wallet, under
+// the gas cap, with the next nonce of pending and the access list of
+// lists, if it saves gas.
+func sendCall(ctx context.Context, client Backend, txOpts *bind.TransactOpts, gasCap *gasCap, pending *pendingTxs, lists *accessLists, call *RepayCall) (*types.Transaction, error) {
+	opts, err := gasCap.transactOpts(ctx, client, txOpts)
+	if err != nil {
+		return nil, err
+	}
+	if err := pending.assign(ctx, client, opts); err != nil {
+		return nil, err
+	}
+	opts.Value = call.Value
+	var list types.AccessList
+	if lists != nil && opts.GasLimit == 0 {
+		// Calls that cannot be estimated fail as bind reports them
+		msg := ethereum.CallMsg{From: opts.From, To: &call.To, Value: call.Value, Data: call.Data}
+		if gas, err := client.EstimateGas(ctx, msg); err == nil {
+			list, opts.GasLimit = lists.saving(ctx, client, msg, gas)
+		}
+	}
+	var tx *types.Transaction
+	if list != nil {
+		tx, err = lists.transact(ctx, client, opts, call, list)
+	} else {
+		contract := bind.NewBoundContract(call.To, abi.ABI{}, client, client, client)
+		tx, err = contract.RawTransact(opts, call.Data)
+	}
+	pending.release(opts, err)
+	return tx, err
+}
+
+// withContext returns a copy of txOpts bound to ctx.
+func withContext(ctx context.Context, txOpts *bind.TransactOpts) *bind.TransactOpts {
+	opts := *txOpts
+	opts.Context = ctx
+	return &opts
+}
@@ -0,0 +1,259 @@
+package liquidatoor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Blocks below the last block processed whose logs are kept to undo
+// them if reorgs remove them
+const logReorgDepth = 64
+
+var errLogQueryChanged = errors.New("log query changed")
+
+// logKey identifies a log across subscriptions and backfills.
+type logKey struct {
+	block common.Hash
+	index uint
+}
+
+// logWatch follows the logs of a query without losing the ones emitted
+// while its subscription is down: every subscription but the first
+// backfills the blocks since the last block processed before going
+// live, and logs already processed are skipped. Logs processed that
+// reorgs remove are processed again as removed, whether the
+// subscription delivers them as such or the backfill no longer finds
+// them.
+type logWatch struct {
+	logger Logger
+	client Backend
+	logs   *logBackfill
+	// Describes the logs
+	name string
+	// Wait before subscribing again on failure
+	retry time.Duration
+
+	lock  sync.Mutex
+	query ethereum.FilterQuery
+	// Signals a new query
+	changed chan struct{}
+
+	// First block followed, and last block processed, from which the
+	// next subscription backfills; zero until the first subscription
+	firstBlock, lastBlock uint64
+	// Logs processed in the last logReorgDepth blocks
+	seen map[logKey]types.Log
+}
+
+func newLogWatch(logger Logger, client Backend, logs *logBackfill, name string, query ethereum.FilterQuery, retry time.Duration) *logWatch {
+	return &logWatch{
+		logger:  logger,
+		client:  client,
+		logs:    logs,
+		name:    name,
+		retry:   retry,
+		query:   query,
+		changed: make(chan struct{}, 1),
+		seen:    make(map[logKey]types.Log),
+	}
+}
+
+// setQuery replaces the query, subscribing to it from the last block
+// processed.
+func (w *logWatch) setQuery(query ethereum.FilterQuery) {
+	w.lock.Lock()
+	w.query = query
+	w.lock.Unlock()
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// run calls fn with every log of the query in order until ctx is
+// cancelled, subscribing again on failure, and again with Removed set
+// if a reorg removes it, to undo it. failed, if set, is called on
+// every failure, eg., to read what the logs update instead.
+func (w *logWatch) run(ctx context.Context, fn func(types.Log), failed func(error)) {
+	for {
+		err := w.watch(ctx, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errLogQueryChanged) {
+			continue
+		}
+		w.logger.Warn(fmt.Sprintf("Subscription to %s failed: %v", w.name, err), F("logs", w.name), F("err", err))
+		if failed != nil {
+			failed(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.retry):
+		}
+	}
+}
+
+func (w *logWatch) watch(ctx context.Context, fn func(types.Log)) error {
+	w.lock.Lock()
+	query := w.query
+	w.lock.Unlock()
+
+	// Subscribe before backfilling so no block falls in between
+	logs := make(chan types.Log, 64)
+	sub, err := w.client.SubscribeFilterLogs(ctx, query, logs)
+	if err != nil {
+		return fmt.Errorf("cannot subscribe to %s: %w", w.name, err)
+	}
+	defer sub.Unsubscribe()
+
+	header, err := w.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot get latest block: %w", err)
+	}
+	head := header.Number.Uint64()
+	if w.lastBlock == 0 {
+		// The head was mined before subscribing; only the blocks after
+		// it are followed
+		w.firstBlock, w.lastBlock = head+1, head
+	} else if w.lastBlock <= head {
+		if err := w.backfill(ctx, query, head, fn); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case <-w.changed:
+			return errLogQueryChanged
+		case log := <-logs:
+			w.process(log, fn)
+		}
+	}
+}
+
+// backfill processes the logs up to head missed since the last block
+// processed, and the ones processed in the blocks before it that
+// reorgs removed meanwhile.
+func (w *logWatch) backfill(ctx context.Context, query ethereum.FilterQuery, head uint64, fn func(types.Log)) error {
+	from := w.firstBlock
+	if w.lastBlock > from+logReorgDepth {
+		from = w.lastBlock - logReorgDepth
+	}
+	found := make(map[logKey]bool)
+	backfilled := 0
+	err := w.logs.filter(ctx, w.name, query, from, head, func(logs []types.Log, _ uint64) error {
+		for _, log := range logs {
+			found[logKey{block: log.BlockHash, index: log.Index}] = true
+			if w.process(log, fn) {
+				backfilled++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	removed := make([]types.Log, 0)
+	for key, log := range w.seen {
+		// Logs of an earlier query are not removed but left out
+		if !found[key] && log.BlockNumber >= from && log.BlockNumber <= head && queryMatches(query, log) {
+			removed = append(removed, log)
+		}
+	}
+	// Undo the latest first
+	sort.Slice(removed, func(i, j int) bool {
+		if removed[i].BlockNumber != removed[j].BlockNumber {
+			return removed[i].BlockNumber > removed[j].BlockNumber
+		}
+		return removed[i].Index > removed[j].Index
+	})
+	for _, log := range removed {
+		log.Removed = true
+		w.process(log, fn)
+	}
+	if backfilled > 0 || len(removed) > 0 {
+		w.logger.Info(fmt.Sprintf("Backfilled %d %s missed while unsubscribed, %d removed by reorgs", backfilled, w.name, len(removed)),
+			F("logs", w.name), F("backfilled", backfilled), F("removed", len(removed)))
+	}
+	w.advance(head)
+	return nil
+}
+
+// process calls fn with log unless it was processed, or it was removed
+// before being processed, and reports whether it did.
+func (w *logWatch) process(log types.Log, fn func(types.Log)) bool {
+	key := logKey{block: log.BlockHash, index: log.Index}
+	_, ok := w.seen[key]
+	if log.Removed {
+		if !ok {
+			return false
+		}
+		delete(w.seen, key)
+		fn(log)
+		return true
+	}
+	// Logs before the window kept cannot be told apart from processed
+	// ones
+	if ok || log.BlockNumber < w.firstBlock || log.BlockNumber+logReorgDepth < w.lastBlock {
+		return false
+	}
+	w.seen[key] = log
+	w.advance(log.BlockNumber)
+	fn(log)
+	return true
+}
+
+// advance moves the last block processed forward, forgetting the logs
+// of the blocks no longer kept.
+func (w *logWatch) advance(block uint64) {
+	if block <= w.lastBlock {
+		return
+	}
+	w.lastBlock = block
+	for key, log := range w.seen {
+		if log.BlockNumber+logReorgDepth < block {
+			delete(w.seen, key)
+		}
+	}
+}
+
+// queryMatches reports whether log is of the addresses and first topics
+// of query; the other topics are not matched.
+func queryMatches(query ethereum.FilterQuery, log types.Log) bool {
+	if len(query.Addresses) > 0 {
+		found := false
+		for _, address := range query.Addresses {
+			found = found || address == log.Address
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(query.Topics) == 0 || len(query.Topics[0]) == 0 {
+		return true
+	}
+	if len(log.Topics) == 0 {
+		return false
+	}
+	for _, topic := range query.Topics[0] {
+		if topic == log.Topics[0] {
+			return true
+		}
+	}
+	return false
+}
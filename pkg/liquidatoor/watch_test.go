@@ -0,0 +1,233 @@
+package liquidatoor
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/kargakis/liquidatoor/pkg/liquidatoor/internal/fakes"
+)
+
+// logsBackend serves headers and logs from a fake chain of logs.
+type logsBackend struct {
+	Backend
+	logs *fakes.Logs
+}
+
+func (b logsBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return b.logs.HeaderByNumber(ctx, number)
+}
+
+func (b logsBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return b.logs.FilterLogs(ctx, query)
+}
+
+func (b logsBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return b.logs.SubscribeFilterLogs(ctx, query, ch)
+}
+
+var watchedAddress = common.HexToAddress("0xa")
+
+// testLog is the index-th log of block.
+func testLog(block uint64, index uint) types.Log {
+	return types.Log{
+		Address:     watchedAddress,
+		BlockNumber: block,
+		BlockHash:   common.BigToHash(new(big.Int).SetUint64(block)),
+		Index:       index,
+	}
+}
+
+// logRecorder records the logs a watch processes.
+type logRecorder struct {
+	lock sync.Mutex
+	logs []types.Log
+}
+
+func (r *logRecorder) record(log types.Log) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.logs = append(r.logs, log)
+}
+
+func (r *logRecorder) recorded() []types.Log {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]types.Log(nil), r.logs...)
+}
+
+// waitFor waits until n logs are recorded.
+func (r *logRecorder) waitFor(t *testing.T, n int) []types.Log {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if logs := r.recorded(); len(logs) >= n {
+			return logs
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d logs, got %d", n, len(r.recorded()))
+	return nil
+}
+
+// startWatch runs a watch of the logs of watchedAddress on chain until
+// the test ends, waiting for it to subscribe.
+func startWatch(t *testing.T, chain *fakes.Logs) *logRecorder {
+	t.Helper()
+	client := logsBackend{logs: chain}
+	w := newLogWatch(NewStdLogger(), client, newLogBackfill(NewStdLogger(), client, nil, 10, 0), "test logs",
+		ethereum.FilterQuery{Addresses: []common.Address{watchedAddress}}, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	r := &logRecorder{}
+	go func() {
+		defer close(done)
+		w.run(ctx, r.record, nil)
+	}()
+	waitSubscribed(t, chain)
+	return r
+}
+
+func waitSubscribed(t *testing.T, chain *fakes.Logs) {
+	t.Helper()
+	select {
+	case <-chain.Subscribed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a subscription")
+	}
+}
+
+// checkExactlyOnce checks logs are the logs of blocks from-to, one per
+// block, each once and in order.
+func checkExactlyOnce(t *testing.T, logs []types.Log, from, to uint64) {
+	t.Helper()
+	if len(logs) != int(to-from+1) {
+		t.Fatalf("expected %d logs, got %d: %v", to-from+1, len(logs), blocksOf(logs))
+	}
+	for i, log := range logs {
+		if log.BlockNumber != from+uint64(i) || log.Removed {
+			t.Fatalf("expected the logs of blocks %d-%d once each, in order, got %v", from, to, blocksOf(logs))
+		}
+	}
+}
+
+func blocksOf(logs []types.Log) []uint64 {
+	blocks := make([]uint64, len(logs))
+	for i, log := range logs {
+		blocks[i] = log.BlockNumber
+	}
+	return blocks
+}
+
+func TestLogWatchBackfillsWhileDown(t *testing.T) {
+	chain := fakes.NewLogs(100)
+	r := startWatch(t, chain)
+
+	for block := uint64(101); block <= 105; block++ {
+		chain.Emit(testLog(block, 0))
+	}
+	r.waitFor(t, 5)
+
+	// Logs emitted while the subscription is down are only found by
+	// backfilling, which finds the ones already processed again too
+	chain.Kill()
+	for block := uint64(106); block <= 130; block++ {
+		chain.Emit(testLog(block, 0))
+	}
+	chain.Restore()
+	waitSubscribed(t, chain)
+
+	for block := uint64(131); block <= 135; block++ {
+		chain.Emit(testLog(block, 0))
+	}
+	r.waitFor(t, 35)
+	// Nothing else arrives late
+	time.Sleep(50 * time.Millisecond)
+	checkExactlyOnce(t, r.recorded(), 101, 135)
+}
+
+func TestLogWatchSurvivesRepeatedKills(t *testing.T) {
+	chain := fakes.NewLogs(100)
+	r := startWatch(t, chain)
+
+	// Logs in flight when subscriptions die may be delivered, dropped
+	// or both live and backfilled
+	block := uint64(100)
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 20; i++ {
+			block++
+			chain.Emit(testLog(block, 0))
+		}
+		chain.Kill()
+		chain.Restore()
+		waitSubscribed(t, chain)
+	}
+	// The last subscription backfills before processing the last log
+	block++
+	chain.Emit(testLog(block, 0))
+	r.waitFor(t, int(block-100))
+	time.Sleep(50 * time.Millisecond)
+	checkExactlyOnce(t, r.recorded(), 101, block)
+}
+
+func TestLogWatchSkipsLogsBeforeFirstBlock(t *testing.T) {
+	chain := fakes.NewLogs(100)
+	// Emitted before the watch started
+	chain.Emit(testLog(99, 0), testLog(100, 0))
+	r := startWatch(t, chain)
+
+	chain.Kill()
+	chain.Emit(testLog(101, 0))
+	chain.Restore()
+	waitSubscribed(t, chain)
+	chain.Emit(testLog(102, 0))
+	r.waitFor(t, 2)
+	time.Sleep(50 * time.Millisecond)
+	checkExactlyOnce(t, r.recorded(), 101, 102)
+}
+
+func TestLogWatchUndoesRemovedLogs(t *testing.T) {
+	chain := fakes.NewLogs(100)
+	r := startWatch(t, chain)
+
+	// Delivered removed by the subscription
+	chain.Emit(testLog(101, 0), testLog(102, 0))
+	r.waitFor(t, 2)
+	chain.Remove(testLog(102, 0))
+	logs := r.waitFor(t, 3)
+	if removed := logs[2]; !removed.Removed || removed.BlockNumber != 102 {
+		t.Fatalf("expected the log of block 102 removed, got %+v", removed)
+	}
+	// Removing a log never processed, or removed already, does nothing
+	chain.Remove(testLog(102, 0))
+	chain.Remove(testLog(103, 0))
+
+	// Removed while unsubscribed, so only the backfill can tell
+	chain.Kill()
+	chain.Remove(testLog(101, 0))
+	chain.Emit(testLog(103, 1))
+	chain.Restore()
+	waitSubscribed(t, chain)
+	r.waitFor(t, 5)
+	time.Sleep(50 * time.Millisecond)
+	logs = r.recorded()
+	if len(logs) != 5 {
+		t.Fatalf("expected 5 logs, got %d: %v", len(logs), logs)
+	}
+	if log := logs[3]; log.BlockNumber != 103 || log.Removed {
+		t.Fatalf("expected the log of block 103 backfilled, got %+v", log)
+	}
+	if log := logs[4]; log.BlockNumber != 101 || !log.Removed {
+		t.Fatalf("expected the log of block 101 removed, got %+v", log)
+	}
+}
@@ -0,0 +1,180 @@
+package liquidatoor
+
+import (
+	"bytes"
+	"container/heap"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// watchlist keeps the borrowers of a pool ordered by how close they
+// are to liquidation across blocks: underwater accounts by decreasing
+// shortfall, then healthy accounts by increasing liquidity. Accounts
+// are only moved when their liquidity changes, so blocks do not sort
+// the whole pool. It is safe for concurrent use.
+type watchlist struct {
+	lock    sync.Mutex
+	entries map[common.Address]*watchEntry
+	heap    watchHeap
+	// Incremented on every sync to find accounts that are gone
+	generation uint64
+}
+
+type watchEntry struct {
+	borrower  Borrower
+	liquidity accountLiquidity
+	seen      uint64
+	index     int
+}
+
+func newWatchlist() *watchlist {
+	return &watchlist{entries: make(map[common.Address]*watchEntry)}
+}
+
+// sync updates the liquidity of every borrower and forgets accounts
+// that are no longer borrowers or whose liquidity is unknown. Full
+// syncs rebuild the order from scratch, in linear time.
+func (w *watchlist) sync(borrowers []Borrower, liquidities []accountLiquidity, full bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.generation++
+	for i, borrower := range borrowers {
+		liquidity := liquidities[i]
+		if liquidity == nil || liquidity.failed() {
+			continue
+		}
+		entry, ok := w.entries[borrower.Address]
+		if !ok {
+			entry = &watchEntry{borrower: borrower, liquidity: liquidity, seen: w.generation}
+			w.entries[borrower.Address] = entry
+			if !full {
+				heap.Push(&w.heap, entry)
+			}
+			continue
+		}
+		entry.borrower = borrower
+		entry.seen = w.generation
+		if bytes.Equal(entry.liquidity, liquidity) {
+			continue
+		}
+		entry.liquidity = liquidity
+		if !full {
+			heap.Fix(&w.heap, entry.index)
+		}
+	}
+
+	for account, entry := range w.entries {
+		if entry.seen == w.generation {
+			continue
+		}
+		delete(w.entries, account)
+		if !full {
+			heap.Remove(&w.heap, entry.index)
+		}
+	}
+
+	if full {
+		w.heap = w.heap[:0]
+		for _, entry := range w.entries {
+			entry.index = len(w.heap)
+			w.heap = append(w.heap, entry)
+		}
+		heap.Init(&w.heap)
+	}
+}
+
+// underwater returns the underwater accounts by decreasing shortfall
+// in time proportional to their number.
+func (w *watchlist) underwater() []Borrower {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var popped []*watchEntry
+	for len(w.heap) > 0 && w.heap[0].liquidity.underwater() {
+		popped = append(popped, heap.Pop(&w.heap).(*watchEntry))
+	}
+	accounts := make([]Borrower, 0, len(popped))
+	for _, entry := range popped {
+		accounts = append(accounts, Borrower{
+			Address:   entry.borrower.Address,
+			Assets:    entry.borrower.Assets,
+			Shortfall: entry.liquidity.shortfall(),
+		})
+		heap.Push(&w.heap, entry)
+	}
+	return accounts
+}
+
+// closest returns up to n healthy accounts closest to liquidation, by
+// increasing liquidity, and their liquidity, in time proportional to
+// their number and the number of underwater accounts.
+func (w *watchlist) closest(n int) ([]Borrower, []*big.Int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var popped []*watchEntry
+	accounts, liquidities := make([]Borrower, 0, n), make([]*big.Int, 0, n)
+	for len(w.heap) > 0 && len(accounts) < n {
+		entry := heap.Pop(&w.heap).(*watchEntry)
+		popped = append(popped, entry)
+		if entry.liquidity.underwater() {
+			continue
+		}
+		accounts = append(accounts, Borrower{Address: entry.borrower.Address, Assets: entry.borrower.Assets})
+		liquidities = append(liquidities, entry.liquidity.liquidity())
+	}
+	for _, entry := range popped {
+		heap.Push(&w.heap, entry)
+	}
+	return accounts, liquidities
+}
+
+// liquidity returns the liquidity of an account, if watched.
+func (w *watchlist) liquidity(account common.Address) (*big.Int, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	entry, ok := w.entries[account]
+	if !ok {
+		return nil, false
+	}
+	return entry.liquidity.liquidity(), true
+}
+
+// watchHeap implements heap.Interface with the account closest to
+// liquidation first.
+type watchHeap []*watchEntry
+
+func (h watchHeap) Len() int { return len(h) }
+
+func (h watchHeap) Less(i, j int) bool {
+	a, b := h[i].liquidity, h[j].liquidity
+	if au, bu := a.underwater(), b.underwater(); au != bu {
+		return au
+	} else if au {
+		return bytes.Compare(a[64:96], b[64:96]) == 1
+	}
+	return bytes.Compare(a[32:64], b[32:64]) == -1
+}
+
+func (h watchHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *watchHeap) Push(x interface{}) {
+	entry := x.(*watchEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *watchHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
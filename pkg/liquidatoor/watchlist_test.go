@@ -0,0 +1,204 @@
+package liquidatoor
+
+import (
+	"math/big"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// liquidityOf returns the getAccountLiquidity output of an account
+// with liquidity or shortfall, failed if code is not zero.
+func liquidityOf(code int64, liquidity, shortfall *big.Int) accountLiquidity {
+	words := make(accountLiquidity, 0, 96)
+	for _, word := range []*big.Int{big.NewInt(code), liquidity, shortfall} {
+		words = append(words, common.LeftPadBytes(word.Bytes(), 32)...)
+	}
+	return words
+}
+
+// randomLiquidity returns the liquidity of a healthy or underwater
+// account, unknown or failed sometimes. Amounts are unique with
+// overwhelming probability, so accounts are strictly ordered.
+func randomLiquidity(r *rand.Rand) accountLiquidity {
+	amount := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), 128))
+	switch r.Intn(10) {
+	case 0:
+		return nil
+	case 1:
+		return liquidityOf(3, new(big.Int), new(big.Int))
+	case 2, 3, 4:
+		return liquidityOf(0, new(big.Int), amount)
+	}
+	return liquidityOf(0, amount, new(big.Int))
+}
+
+// watchedBorrowers is the state of the borrowers a watchlist is synced
+// with.
+type watchedBorrowers struct {
+	borrowers   []Borrower
+	liquidities []accountLiquidity
+}
+
+// next moves some accounts, drops some and adds new ones.
+func (s *watchedBorrowers) next(r *rand.Rand, account *int64) {
+	var next watchedBorrowers
+	for i, borrower := range s.borrowers {
+		switch r.Intn(10) {
+		case 0:
+			// No longer borrowing
+			continue
+		case 1, 2:
+			next.borrowers = append(next.borrowers, borrower)
+			next.liquidities = append(next.liquidities, randomLiquidity(r))
+		default:
+			next.borrowers = append(next.borrowers, borrower)
+			next.liquidities = append(next.liquidities, s.liquidities[i])
+		}
+	}
+	for i := r.Intn(20); i > 0; i-- {
+		*account++
+		next.borrowers = append(next.borrowers, Borrower{Address: common.BigToAddress(big.NewInt(*account)), Assets: []common.Address{{1}}})
+		next.liquidities = append(next.liquidities, randomLiquidity(r))
+	}
+	*s = next
+}
+
+// rebuilt returns the underwater accounts by decreasing shortfall and
+// the healthy ones by increasing liquidity, sorting the known
+// liquidities from scratch.
+func (s *watchedBorrowers) rebuilt() ([]common.Address, []common.Address) {
+	type ranked struct {
+		account   common.Address
+		liquidity accountLiquidity
+	}
+	var underwater, healthy []ranked
+	for i, liquidity := range s.liquidities {
+		switch {
+		case liquidity == nil || liquidity.failed():
+		case liquidity.underwater():
+			underwater = append(underwater, ranked{s.borrowers[i].Address, liquidity})
+		default:
+			healthy = append(healthy, ranked{s.borrowers[i].Address, liquidity})
+		}
+	}
+	sort.Slice(underwater, func(i, j int) bool {
+		return underwater[i].liquidity.shortfall().Cmp(underwater[j].liquidity.shortfall()) == 1
+	})
+	sort.Slice(healthy, func(i, j int) bool {
+		return healthy[i].liquidity.liquidity().Cmp(healthy[j].liquidity.liquidity()) == -1
+	})
+	addresses := func(ranked []ranked) []common.Address {
+		accounts := make([]common.Address, len(ranked))
+		for i, r := range ranked {
+			accounts[i] = r.account
+		}
+		return accounts
+	}
+	return addresses(underwater), addresses(healthy)
+}
+
+// checkWatchlist checks w orders the borrowers s was last synced with
+// as a rebuild does.
+func checkWatchlist(t *testing.T, w *watchlist, s *watchedBorrowers) {
+	t.Helper()
+	underwater, healthy := s.rebuilt()
+	got := w.underwater()
+	if len(got) != len(underwater) {
+		t.Fatalf("expected %d underwater accounts, got %d", len(underwater), len(got))
+	}
+	for i, borrower := range got {
+		if borrower.Address != underwater[i] {
+			t.Fatalf("expected underwater account %d to be %s, got %s", i, underwater[i], borrower.Address)
+		}
+		if borrower.Shortfall == nil || borrower.Shortfall.Sign() != 1 {
+			t.Fatalf("expected the shortfall of underwater account %s, got %v", borrower.Address, borrower.Shortfall)
+		}
+	}
+	closest, liquidities := w.closest(len(healthy) + 1)
+	if len(closest) != len(healthy) {
+		t.Fatalf("expected %d healthy accounts, got %d", len(healthy), len(closest))
+	}
+	for i, borrower := range closest {
+		if borrower.Address != healthy[i] {
+			t.Fatalf("expected healthy account %d to be %s, got %s", i, healthy[i], borrower.Address)
+		}
+		if liquidity, ok := w.liquidity(borrower.Address); !ok || liquidity.Cmp(liquidities[i]) != 0 {
+			t.Fatalf("expected the liquidity of %s to be %v, got %v", borrower.Address, liquidities[i], liquidity)
+		}
+	}
+	if len(w.entries) != len(w.heap) {
+		t.Fatalf("expected %d accounts in the heap, got %d", len(w.entries), len(w.heap))
+	}
+	for i, entry := range w.heap {
+		if entry.index != i || w.entries[entry.borrower.Address] != entry {
+			t.Fatalf("expected the entry of %s at index %d", entry.borrower.Address, i)
+		}
+	}
+}
+
+func TestWatchlistMatchesRebuild(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	w := newWatchlist()
+	var s watchedBorrowers
+	account := int64(0)
+	for block := 0; block < 200; block++ {
+		s.next(r, &account)
+		// Every tenth block is a full scan
+		w.sync(s.borrowers, s.liquidities, block%10 == 0)
+		checkWatchlist(t, w, &s)
+
+		// Popping the top accounts leaves the order intact
+		w.underwater()
+		w.closest(r.Intn(10))
+		checkWatchlist(t, w, &s)
+	}
+}
+
+// TestWatchlistConcurrentUpdates syncs and reads the watchlist from
+// several goroutines, as the block loop and the watchers do, checking
+// the order stays consistent. Run with -race.
+func TestWatchlistConcurrentUpdates(t *testing.T) {
+	w := newWatchlist()
+	var lock sync.Mutex
+	var last watchedBorrowers
+	account := int64(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for j := 0; j < 100; j++ {
+				switch r.Intn(4) {
+				case 0:
+					// Syncs are serialized with the state they sync, to
+					// check the last one
+					lock.Lock()
+					last.next(r, &account)
+					w.sync(last.borrowers, last.liquidities, r.Intn(5) == 0)
+					lock.Unlock()
+				case 1:
+					w.underwater()
+				case 2:
+					w.closest(r.Intn(20))
+				case 3:
+					w.liquidity(common.BigToAddress(big.NewInt(int64(r.Intn(100)))))
+				}
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	// The heap invariant holds after the concurrent pops and pushes
+	for i := 1; i < len(w.heap); i++ {
+		if w.heap.Less(i, (i-1)/2) {
+			t.Fatalf("expected entry %d ordered after its parent", i)
+		}
+	}
+	checkWatchlist(t, w, &last)
+}
@@ -0,0 +1,80 @@
+package liquidatoor
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/kargakis/liquidatoor/pkg/abis"
+)
+
+// whitelistCheck tracks whether a Fuse pool enforcing a supplier
+// whitelist permits our wallet, as seizing or redeeming collateral
+// reverts otherwise. It is read at startup and along the market state
+// of every block, so enforcement changes apply from the next block.
+// Liquidations in pools that do not permit us are still detected but
+// not executed.
+type whitelistCheck struct {
+	logger      Logger
+	comptroller common.Address
+	// Enforcement is known not to affect liquidations
+	ignore bool
+
+	calldata []abis.MulticallCall
+	// Nil until first read
+	permitted *bool
+}
+
+func newWhitelistCheck(logger Logger, comptroller, account common.Address, comptrollerABI *abi.ABI, ignore bool) (*whitelistCheck, error) {
+	inputs, err := comptrollerABI.Methods["whitelist"].Inputs.Pack(account)
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack account: %w", err)
+	}
+	return &whitelistCheck{
+		logger:      logger,
+		comptroller: comptroller,
+		ignore:      ignore,
+		calldata: []abis.MulticallCall{
+			{Target: comptroller, CallData: comptrollerABI.Methods["enforceWhitelist"].ID},
+			{Target: comptroller, CallData: append(comptrollerABI.Methods["whitelist"].ID, inputs...)},
+		},
+	}, nil
+}
+
+// calls returns the reads of the enforcement and of our membership.
+func (w *whitelistCheck) calls() []abis.MulticallCall {
+	return w.calldata
+}
+
+// observe updates the permission from the results of calls, alerting
+// on changes. Pools without a whitelist permit everyone.
+func (w *whitelistCheck) observe(results []CallResult) {
+	if len(results) < len(w.calldata) {
+		return
+	}
+	enforced := results[0].Success && len(results[0].ReturnData) >= 32 && results[0].ReturnData[31] == 1
+	listed := results[1].Success && len(results[1].ReturnData) >= 32 && results[1].ReturnData[31] == 1
+	permitted := !enforced || listed || w.ignore
+	previous := w.permitted
+	if previous != nil && *previous == permitted {
+		return
+	}
+	w.permitted = &permitted
+
+	switch {
+	case !permitted:
+		w.logger.Error(fmt.Sprintf("Pool %s enforces a supplier whitelist without us; liquidations are detected but not executed", w.comptroller),
+			F("pool", w.comptroller))
+	case enforced && !listed:
+		w.logger.Warn(fmt.Sprintf("Pool %s enforces a supplier whitelist without us; executing anyway as configured", w.comptroller),
+			F("pool", w.comptroller))
+	case previous != nil:
+		w.logger.Info(fmt.Sprintf("Pool %s permits our liquidations again", w.comptroller), F("pool", w.comptroller))
+	}
+}
+
+// blocked reports whether liquidations in the pool cannot be executed.
+func (w *whitelistCheck) blocked() bool {
+	return w.permitted != nil && !*w.permitted
+}